@@ -1,50 +1,87 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"math/big"
 	"net/http"
+	"time"
 
 	db "example.com/db/sqlc"
+	"example.com/token"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// createAccountRequest opens an account for owner, which defaults to the caller.
+type createAccountRequest struct {
+	Owner       string         `json:"owner"`
+	Currency    string         `json:"currency" binding:"required,currency"`
+	AccountType db.AccountType `json:"account_type"`
+}
+
+// validate defaults the account type to checking and rejects unknown types.
+func (req *createAccountRequest) validate() error {
+	if req.AccountType == "" {
+		req.AccountType = db.AccountTypeChecking
+	}
+
+	if !req.AccountType.Valid() {
+		return invalidArgument(fmt.Errorf("invalid account type %q", req.AccountType))
+	}
+
+	return nil
+}
+
 func (server *Server) CreateAccount(c *gin.Context) {
-	var payload db.CreateAccountParams
+	var req createAccountRequest
 
-	if err := c.ShouldBindBodyWithJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
 		return
 	}
 
-	payload.Balance = pgtype.Numeric{
-		Int:   big.NewInt(0),
-		Exp:   0,
-		Valid: true,
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
 	}
 
-	account, err := server.Store.CreateAccount(c, db.CreateAccountParams{
-		Owner:    payload.Owner,
-		Currency: payload.Currency,
-		Balance:  payload.Balance,
-	})
+	payload := authPayload(c)
+	if req.Owner == "" {
+		req.Owner = payload.Username
+	}
+	if err := checkOwner(req.Owner, payload, permCreateAnyAccount); err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
 
+	account, err := server.createAccount(c, req)
 	if err != nil {
-		if pgErr, ok  := err.(*pgconn.PgError); ok {
-			switch pgErr.Code {
-				case "23505", "23503":
-				c.JSON(http.StatusForbidden, errorResponse(pgErr))
-				return
-			}
-		}
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		c.JSON(errorStatus(err), errorResponse(c, err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, account)
+	c.JSON(http.StatusCreated, accountBody(c, account))
+
+}
+
+// createAccount opens an account for an owner who has verified their email address.
+func (server *Server) createAccount(ctx context.Context, req createAccountRequest) (db.Account, error) {
+	if err := server.requireVerifiedEmail(ctx, req.Owner); err != nil {
+		return db.Account{}, err
+	}
 
+	return server.Store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:    req.Owner,
+		Currency: req.Currency,
+		Balance: pgtype.Numeric{
+			Int:   big.NewInt(0),
+			Exp:   0,
+			Valid: true,
+		},
+		AccountType: req.AccountType,
+	})
 }
 
 type getAccountRequest struct {
@@ -59,7 +96,7 @@ func (server *Server) GetAccount(c *gin.Context) {
 	// account, err := server.Store.GetAccount(c, int64(idInt))
 
 	// if err != nil {
-	// 	c.JSON(http.StatusNotFound, errorResponse(err))
+	// 	c.JSON(http.StatusNotFound, errorResponse(c, err))
 	// 	return
 	// }
 
@@ -68,23 +105,22 @@ func (server *Server) GetAccount(c *gin.Context) {
 	var req getAccountRequest
 
 	if err := c.ShouldBindUri(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
 		return
 	}
 
 	account, err := server.Store.GetAccount(c, req.ID)
 
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			c.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
 
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+	if !authorizeAccount(c, account, permViewAnyAccount) {
 		return
 	}
 
-	c.JSON(http.StatusAccepted, account)
+	c.JSON(http.StatusAccepted, accountBody(c, account))
 }
 
 type listAccountsRequest struct {
@@ -96,17 +132,240 @@ func (server *Server) ListAccounts(c *gin.Context) {
 	var req listAccountsRequest
 
 	if err := c.BindQuery(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
 		return
 	}
-	accounts, err := server.Store.ListAccounts(c, db.ListAccountsParams{
+	accounts, err := server.listAccounts(c, authPayload(c), req)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	response := make([]any, len(accounts))
+	for i, account := range accounts {
+		response[i] = accountBody(c, account)
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// listAccounts lists every account to callers allowed to see them all, and only their own
+// accounts to everyone else.
+func (server *Server) listAccounts(ctx context.Context, payload *token.Payload, req listAccountsRequest) ([]db.Account, error) {
+	if hasPermission(payload, permListAllAccounts) {
+		return server.Store.ListAccounts(ctx, db.ListAccountsParams{
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		})
+	}
+
+	return server.Store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{
+		Owner:  payload.Username,
 		Limit:  req.PageSize,
 		Offset: (req.PageID - 1) * req.PageSize,
 	})
+}
+
+type accountResponse struct {
+	db.Account
+	AvailableBalance pgtype.Numeric `json:"available_balance"`
+}
+
+// newAccountResponse reports how much an account can still spend: its balance plus its overdraft limit.
+// Funds are never held yet, so there is nothing to subtract for holds.
+func newAccountResponse(account db.Account) accountResponse {
+	return accountResponse{
+		Account:          account,
+		AvailableBalance: db.AddNumeric(account.Balance, account.OverdraftLimit),
+	}
+}
+
+// accountResponseV2 is the v2 shape of an account. Amounts are decimal strings with two
+// places instead of JSON numbers, so clients never have to parse money as floats.
+type accountResponseV2 struct {
+	ID               int64          `json:"id"`
+	Owner            string         `json:"owner"`
+	Currency         string         `json:"currency"`
+	AccountType      db.AccountType `json:"account_type"`
+	Balance          string         `json:"balance"`
+	OverdraftLimit   string         `json:"overdraft_limit"`
+	AvailableBalance string         `json:"available_balance"`
+	Frozen           bool           `json:"frozen"`
+	CreatedAt        time.Time      `json:"created_at"`
+	// ClosedAt is set once the account is closed.
+	ClosedAt pgtype.Timestamptz `json:"closed_at"`
+}
+
+func newAccountResponseV2(account db.Account) accountResponseV2 {
+	response := newAccountResponse(account)
+	return accountResponseV2{
+		ID:               account.ID,
+		Owner:            account.Owner,
+		Currency:         account.Currency,
+		AccountType:      account.AccountType,
+		Balance:          formatMoney(account.Balance),
+		OverdraftLimit:   formatMoney(account.OverdraftLimit),
+		AvailableBalance: formatMoney(response.AvailableBalance),
+		Frozen:           account.Frozen,
+		CreatedAt:        account.CreatedAt.Time,
+		ClosedAt:         account.ClosedAt,
+	}
+}
 
+// accountBody renders an account in the shape of the request's API version.
+func accountBody(c *gin.Context, account db.Account) any {
+	if requestVersion(c) >= apiV2 {
+		return newAccountResponseV2(account)
+	}
+	return newAccountResponse(account)
+}
+
+func formatMoney(n pgtype.Numeric) string {
+	return db.NumericToRat(n).FloatString(2)
+}
+
+type updateOverdraftLimitRequest struct {
+	OverdraftLimit *int64 `json:"overdraft_limit" binding:"required,min=0"`
+}
+
+func (server *Server) UpdateOverdraftLimit(c *gin.Context) {
+	var uri getAccountRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var req updateOverdraftLimitRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	account, err := server.Store.UpdateOverdraftLimitTx(c, db.UpdateAccountOverdraftLimitParams{
+		ID: uri.ID,
+		OverdraftLimit: pgtype.Numeric{
+			Int:   big.NewInt(*req.OverdraftLimit),
+			Exp:   0,
+			Valid: true,
+		},
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, errorResponse(c, err))
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, accountBody(c, account))
+}
+
+type freezeAccountRequest struct {
+	Frozen *bool `json:"frozen" binding:"required"`
+}
+
+// FreezeAccount freezes or unfreezes an account, blocking or allowing transfers in and out.
+func (server *Server) FreezeAccount(c *gin.Context) {
+	var uri getAccountRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var req freezeAccountRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	account, err := server.Store.FreezeAccountTx(c, db.FreezeAccountTxParams{AccountID: uri.ID, Frozen: *req.Frozen})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, accountBody(c, account))
+}
+
+// CloseAccount closes one of the caller's accounts, or any account for admins. The
+// balance must be zero; the account is kept, with its history, but takes no more transfers.
+func (server *Server) CloseAccount(c *gin.Context) {
+	var uri getAccountRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
 		return
 	}
-	c.JSON(http.StatusAccepted, accounts)
+
+	account, err := server.Store.GetAccount(c, uri.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	if !authorizeAccount(c, account, permCloseAnyAccount) {
+		return
+	}
+
+	account, err = server.Store.CloseAccountTx(c, account.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, accountBody(c, account))
+}
+
+type entryResponse struct {
+	ID        int64     `json:"id"`
+	AccountID int64     `json:"account_id"`
+	Amount    string    `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListEntries returns a page of an account's ledger, oldest entry first.
+func (server *Server) ListEntries(c *gin.Context) {
+	var uri getAccountRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var req listAccountsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	account, err := server.Store.GetAccount(c, uri.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	if !authorizeAccount(c, account, permViewAnyAccount) {
+		return
+	}
+
+	entries, err := server.Store.ListEntriesForAccount(c, db.ListEntriesForAccountParams{
+		AccountID: account.ID,
+		Limit:     req.PageSize,
+		Offset:    (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	response := make([]entryResponse, len(entries))
+	for i, entry := range entries {
+		response[i] = entryResponse{
+			ID:        entry.ID,
+			AccountID: entry.AccountID,
+			Amount:    formatMoney(entry.Amount),
+			CreatedAt: entry.CreatedAt.Time,
+		}
+	}
+	c.JSON(http.StatusAccepted, response)
 }
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"example.com/db/mock"
 	"example.com/db/sqlc"
@@ -20,6 +22,8 @@ import (
 type TestCases struct {
 	Name          string
 	AccountId     interface{}
+	Username      string
+	Role          db.UserRole
 	BuildStub     func(*mock.MockStore)
 	CheckResponse func(*testing.T, *httptest.ResponseRecorder)
 }
@@ -31,6 +35,7 @@ func TestGetAccount(t *testing.T) {
 		{
 			Name:      "OK",
 			AccountId: account.ID,
+			Username:  account.Owner,
 			BuildStub: func(ms *mock.MockStore) {
 				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
 			},
@@ -42,6 +47,7 @@ func TestGetAccount(t *testing.T) {
 		{
 			Name:      "Not Found",
 			AccountId: account.ID,
+			Username:  account.Owner,
 			BuildStub: func(ms *mock.MockStore) {
 				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, pgx.ErrNoRows)
 			},
@@ -53,6 +59,7 @@ func TestGetAccount(t *testing.T) {
 		{
 			Name:      "Internal Server Error",
 			AccountId: account.ID,
+			Username:  account.Owner,
 			BuildStub: func(ms *mock.MockStore) {
 				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, fmt.Errorf("database connection failed"))
 			},
@@ -64,6 +71,7 @@ func TestGetAccount(t *testing.T) {
 		{
 			Name:      "Bad Request - Invalid ID",
 			AccountId: "invalid", // This will cause ShouldBindUri to fail
+			Username:  account.Owner,
 			BuildStub: func(ms *mock.MockStore) {
 				// No expectations since the request should fail before reaching the store
 			},
@@ -72,6 +80,55 @@ func TestGetAccount(t *testing.T) {
 				requireBodyMatchError(t, rr)
 			},
 		},
+		{
+			Name:      "Unauthorized User",
+			AccountId: account.ID,
+			Username:  account.Owner + "x",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:      "Admin Views Any Account",
+			AccountId: account.ID,
+			Username:  account.Owner + "x",
+			Role:      db.UserRoleAdmin,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				requireBodyMatchAccount(t, rr, account)
+			},
+		},
+		{
+			Name:      "Teller Cannot View Others",
+			AccountId: account.ID,
+			Username:  account.Owner + "x",
+			Role:      db.UserRoleTeller,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:      "No Authorization",
+			AccountId: account.ID,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -82,12 +139,17 @@ func TestGetAccount(t *testing.T) {
 			mockStore := mock.NewMockStore(ctrl)
 			tc.BuildStub(mockStore)
 
-			server := NewServer(mockStore)
+			server := newTestServer(t, newTestConfig(), mockStore)
 			recorder := httptest.NewRecorder()
 
-			url := fmt.Sprintf("/accounts/%v", tc.AccountId)
+			url := fmt.Sprintf("/v1/accounts/%v", tc.AccountId)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.Role != "" {
+				addRoleAuthorization(t, request, server.TokenMaker, tc.Username, tc.Role)
+			} else if tc.Username != "" {
+				addAuthorization(t, request, server.TokenMaker, tc.Username)
+			}
 
 			server.Router.ServeHTTP(recorder, request)
 			tc.CheckResponse(t, recorder)
@@ -136,16 +198,43 @@ func createAccountWithId(id int64) db.Account {
 
 func TestCreateAccount(t *testing.T) {
 	account := randomAccount()
+	owner := db.User{
+		Username:        account.Owner,
+		Email:           account.Owner + "@example.com",
+		EmailVerifiedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
 
 	testCases := []struct {
 		Name          string
+		Username      string
+		Role          db.UserRole
 		BuildStub     func(*mock.MockStore)
 		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
 	}{
 		{
-			Name: "Status Created",
+			Name:     "Status Created",
+			Username: account.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(account.Owner)).Times(1).Return(owner, nil)
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+				requireBodyMatchAccount(t, rr, account)
+			},
+		},
+		{
+			Name:     "Teller For Customer",
+			Username: util.RandomOwner(),
+			Role:     db.UserRoleTeller,
 			BuildStub: func(ms *mock.MockStore) {
-				ms.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).Return(account, nil)
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(account.Owner)).Times(1).Return(owner, nil)
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.CreateAccountParams) (db.Account, error) {
+						require.Equal(t, account.Owner, arg.Owner)
+						return account, nil
+					})
 			},
 			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusCreated, rr.Code)
@@ -153,9 +242,48 @@ func TestCreateAccount(t *testing.T) {
 			},
 		},
 		{
-			Name: "Internal Server Error",
+			Name:     "Customer For Someone Else",
+			Username: util.RandomOwner(),
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name: "No Authorization",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Email Not Verified",
+			Username: account.Owner,
+			Role:     db.UserRoleCustomer,
 			BuildStub: func(ms *mock.MockStore) {
-				ms.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, fmt.Errorf("database connection failed"))
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(account.Owner)).Times(1).
+					Return(db.User{Username: account.Owner, Email: owner.Email}, nil)
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Internal Server Error",
+			Username: account.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(account.Owner)).Times(1).Return(owner, nil)
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, fmt.Errorf("database connection failed"))
 			},
 			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, rr.Code)
@@ -163,9 +291,11 @@ func TestCreateAccount(t *testing.T) {
 			},
 		},
 		{
-			Name: "Bad Request",
+			Name:     "Bad Request",
+			Username: account.Owner,
+			Role:     db.UserRoleCustomer,
 			BuildStub: func(ms *mock.MockStore) {
-				ms.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(0)
+				ms.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, rr.Code)
@@ -182,10 +312,10 @@ func TestCreateAccount(t *testing.T) {
 			mockStore := mock.NewMockStore(ctrl)
 			tc.BuildStub(mockStore)
 
-			server := NewServer(mockStore)
+			server := newTestServer(t, newTestConfig(), mockStore)
 			recorder := httptest.NewRecorder()
 
-			url := "/accounts"
+			url := "/v1/accounts"
 
 			var requestBody []byte
 			switch tc.Name {
@@ -207,6 +337,9 @@ func TestCreateAccount(t *testing.T) {
 
 			request, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
 			require.NoError(t, err)
+			if tc.Username != "" {
+				addRoleAuthorization(t, request, server.TokenMaker, tc.Username, tc.Role)
+			}
 
 			server.Router.ServeHTTP(recorder, request)
 			tc.CheckResponse(t, recorder)
@@ -222,10 +355,13 @@ func TestListAccounts(t *testing.T) {
 		accounts[i] = createAccountWithId(int64(i+1)) 
 	}
 
+	owner := util.RandomOwner()
+
 	testCases := []struct{
 		Name string
 		PageID interface{} 
 		PageSize interface{}
+		Role db.UserRole
 		BuildStub func(*mock.MockStore)
 		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
 	}{
@@ -233,18 +369,39 @@ func TestListAccounts(t *testing.T) {
 			Name:      "Accepted",
 			PageID: 1,
 			PageSize: 10,
+			Role: db.UserRoleAdmin,
 			BuildStub: func(ms *mock.MockStore) {
 				ms.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(1).Return(accounts, nil)
+				ms.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
 			},
 			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusAccepted, rr.Code)
 				requireBodyMatchAccounts(t, rr, accounts)
 			},
 		},
+		{
+			Name:      "Customer Lists Own Accounts",
+			PageID: 2,
+			PageSize: 5,
+			Role: db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+				ms.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Eq(db.ListAccountsByOwnerParams{
+					Owner:  owner,
+					Limit:  5,
+					Offset: 5,
+				})).Times(1).Return(accounts[:5], nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				requireBodyMatchAccounts(t, rr, accounts[:5])
+			},
+		},
 		{
 			Name:      "Internal Server Error",
 			PageID: 1,
 			PageSize: 10,
+			Role: db.UserRoleAdmin,
 			BuildStub: func(ms *mock.MockStore) {
 				ms.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(1).Return([]db.Account{}, fmt.Errorf("database connection failed"))
 			},
@@ -257,6 +414,7 @@ func TestListAccounts(t *testing.T) {
 			Name:      "Bad Request",
 			PageID: 1,
 			PageSize: 10,
+			Role: db.UserRoleAdmin,
 			BuildStub: func(ms *mock.MockStore) {
 				ms.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
 			},
@@ -275,7 +433,7 @@ func TestListAccounts(t *testing.T) {
 			mockStore := mock.NewMockStore(ctrl)
 			tc.BuildStub(mockStore)
 
-			server := NewServer(mockStore)
+			server := newTestServer(t, newTestConfig(), mockStore)
 			recorder := httptest.NewRecorder()
 
 			if tc.Name == "Bad Request" {
@@ -283,9 +441,187 @@ func TestListAccounts(t *testing.T) {
 				tc.PageSize = "In the way"
 			}
 
-			url := fmt.Sprintf("/accounts?page_id=%d&page_size=%d", tc.PageID, tc.PageSize)
+			url := fmt.Sprintf("/v1/accounts?page_id=%d&page_size=%d", tc.PageID, tc.PageSize)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			addRoleAuthorization(t, request, server.TokenMaker, owner, tc.Role)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+
+
+func TestUpdateOverdraftLimit(t *testing.T) {
+	account := randomAccount()
+	config := newTestConfig()
+
+	testCases := []struct {
+		Name          string
+		Role          db.UserRole
+		Body          string
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name:  "OK",
+			Role:  db.UserRoleAdmin,
+			Body:  `{"overdraft_limit": 500}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateOverdraftLimitTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+						require.Equal(t, account.ID, arg.ID)
+						require.Equal(t, int64(500), arg.OverdraftLimit.Int.Int64())
+						updated := account
+						updated.OverdraftLimit = arg.OverdraftLimit
+						return updated, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rr.Code)
+
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.Contains(t, body, "available_balance")
+				require.EqualValues(t, 500, body["overdraft_limit"])
+			},
+		},
+		{
+			Name:  "Zero Limit",
+			Role:  db.UserRoleAdmin,
+			Body:  `{"overdraft_limit": 0}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateOverdraftLimitTx(gomock.Any(), gomock.Any()).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rr.Code)
+			},
+		},
+		{
+			Name:  "Not Admin",
+			Role:  db.UserRoleTeller,
+			Body:  `{"overdraft_limit": 500}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateOverdraftLimitTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:  "Negative Limit",
+			Role:  db.UserRoleAdmin,
+			Body:  `{"overdraft_limit": -1}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateOverdraftLimitTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:  "Not Found",
+			Role:  db.UserRoleAdmin,
+			Body:  `{"overdraft_limit": 500}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateOverdraftLimitTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, config, mockStore)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/v1/admin/accounts/%d/overdraft", account.ID)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewBufferString(tc.Body))
+			require.NoError(t, err)
+			addRoleAuthorization(t, request, server.TokenMaker, util.RandomOwner(), tc.Role)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestFreezeAccount(t *testing.T) {
+	account := randomAccount()
+
+	testCases := []struct {
+		Name          string
+		Body          string
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "OK",
+			Body: `{"frozen": true}`,
+			BuildStub: func(ms *mock.MockStore) {
+				frozen := account
+				frozen.Frozen = true
+				ms.EXPECT().FreezeAccountTx(gomock.Any(), gomock.Eq(db.FreezeAccountTxParams{AccountID: account.ID, Frozen: true})).
+					Times(1).Return(frozen, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rr.Code)
+
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.Equal(t, true, body["frozen"])
+			},
+		},
+		{
+			Name: "Missing Frozen",
+			Body: `{}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().FreezeAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name: "Not Found",
+			Body: `{"frozen": false}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().FreezeAccountTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/v2/accounts/%d/freeze", account.ID)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewBufferString(tc.Body))
+			require.NoError(t, err)
+			addRoleAuthorization(t, request, server.TokenMaker, util.RandomOwner(), db.UserRoleAdmin)
 
 			server.Router.ServeHTTP(recorder, request)
 			tc.CheckResponse(t, recorder)
@@ -293,4 +629,85 @@ func TestListAccounts(t *testing.T) {
 	}
 }
 
+func TestListEntries(t *testing.T) {
+	account := randomAccount()
+	entries := []db.Entry{
+		{ID: 1, AccountID: account.ID, Amount: pgtype.Numeric{Int: big.NewInt(1050), Exp: -2, Valid: true}},
+		{ID: 2, AccountID: account.ID, Amount: pgtype.Numeric{Int: big.NewInt(-300), Exp: -2, Valid: true}},
+	}
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		Role          db.UserRole
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "Owner",
+			Username: account.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				ms.EXPECT().ListEntriesForAccount(gomock.Any(), gomock.Eq(db.ListEntriesForAccountParams{
+					AccountID: account.ID,
+					Limit:     5,
+					Offset:    0,
+				})).Times(1).Return(entries, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+
+				var body []entryResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.Len(t, body, 2)
+				require.Equal(t, "10.50", body[0].Amount)
+				require.Equal(t, "-3.00", body[1].Amount)
+			},
+		},
+		{
+			Name:     "Admin",
+			Username: account.Owner + "x",
+			Role:     db.UserRoleAdmin,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				ms.EXPECT().ListEntriesForAccount(gomock.Any(), gomock.Any()).Times(1).Return(entries, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+			},
+		},
+		{
+			Name:     "Other Customer",
+			Username: account.Owner + "x",
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				ms.EXPECT().ListEntriesForAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
 
+			url := fmt.Sprintf("/v2/accounts/%d/entries?page_id=1&page_size=5", account.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			addRoleAuthorization(t, request, server.TokenMaker, tc.Username, tc.Role)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/token"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// apiKeyHeaderKey is the header, or gRPC metadata key, that carries an API key in place of
+// an access token.
+const apiKeyHeaderKey = "X-API-Key"
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to recognize.
+const apiKeyPrefix = "sbk_"
+
+// apiKeyShownPrefix is how much of a key the listing shows, to tell the keys apart.
+const apiKeyShownPrefix = len(apiKeyPrefix) + 8
+
+var (
+	errInvalidAPIKey      = errors.New("API key is invalid, expired or revoked")
+	errAPIKeyIPNotAllowed = errors.New("API key is not allowed from this IP address")
+	errAPIKeyNotFound     = errors.New("API key not found")
+	errBothCredentials    = errors.New("send either an access token or an API key, not both")
+)
+
+// newAPIKey returns a random API key and the hash to store in its place.
+func newAPIKey() (key, hash string) {
+	key = apiKeyPrefix + rand.Text()
+	return key, hashAPIKey(key)
+}
+
+// hashAPIKey hashes an API key for storage and lookup. Keys are random, so a fast hash is
+// as good as a slow one.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIKey checks an API key presented from clientIP and returns a payload that
+// acts for its user, with the user's current role, limited to the key's scopes. It notes
+// when the key was last used.
+func authenticateAPIKey(ctx context.Context, store db.Querier, key, clientIP string) (*token.Payload, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, errInvalidAPIKey
+	}
+
+	apiKey, err := store.GetApiKeyByHash(ctx, hashAPIKey(key))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, errInvalidAPIKey
+	case err != nil:
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt.Valid || (apiKey.ExpiresAt.Valid && !now.Before(apiKey.ExpiresAt.Time)) {
+		return nil, errInvalidAPIKey
+	}
+	if len(apiKey.AllowedIps) > 0 && !ipAllowed(clientIP, apiKey.AllowedIps) {
+		return nil, errAPIKeyIPNotAllowed
+	}
+
+	user, err := store.GetUser(ctx, apiKey.Username)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, errInvalidAPIKey
+	case err != nil:
+		return nil, err
+	}
+
+	if err := store.TouchApiKey(ctx, apiKey.ID); err != nil {
+		return nil, err
+	}
+
+	return &token.Payload{
+		Username:  user.Username,
+		Role:      string(user.Role),
+		APIKeyID:  apiKey.ID,
+		Scopes:    apiKey.Scopes,
+		IssuedAt:  apiKey.CreatedAt.Time,
+		ExpiredAt: apiKey.ExpiresAt.Time,
+	}, nil
+}
+
+// ipAllowed reports whether ip is one of the addresses or in one of the networks listed.
+func ipAllowed(ip string, allowed []string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, entry := range allowed {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if other, err := netip.ParseAddr(entry); err == nil && other.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+var validAPIKeyScope validator.Func = func(fl validator.FieldLevel) bool {
+	scope, ok := fl.Field().Interface().(string)
+	_, known := apiKeyScopes[scope]
+	return ok && known
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=64"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,api_key_scope"`
+	// AllowedIPs lists the addresses and networks the key works from. Empty allows any.
+	AllowedIPs []string   `json:"allowed_ips" binding:"omitempty,dive,cidr|ip"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	// RequireSignature gives the key a signing secret, and makes it sign its transfers.
+	RequireSignature bool `json:"require_signature"`
+}
+
+// apiKeyResponse leaves out the key, which is only shown once at creation.
+type apiKeyResponse struct {
+	ID         int64              `json:"id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	Scopes     []string           `json:"scopes"`
+	AllowedIPs []string           `json:"allowed_ips"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	// SignatureRequired is set for keys that must sign their transfers.
+	SignatureRequired bool `json:"signature_required"`
+}
+
+type createAPIKeyResponse struct {
+	apiKeyResponse
+	Key           string `json:"key"`
+	SigningSecret string `json:"signing_secret,omitempty"`
+}
+
+func newAPIKeyResponse(key db.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		AllowedIPs: key.AllowedIps,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+
+		SignatureRequired: key.SigningSecret.Valid,
+	}
+}
+
+// CreateAPIKey gives the caller a new API key. Like a webhook, a key lets account data
+// out, so users enrolled in two-factor authentication need a fresh TOTP code for it.
+func (server *Server) CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var expiresAt pgtype.Timestamptz
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			err := invalidArgument(fmt.Errorf("expires_at %s is in the past", req.ExpiresAt.Format(time.RFC3339)))
+			c.JSON(errorStatus(err), errorResponse(c, err))
+			return
+		}
+		expiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	username := authPayload(c).Username
+	if err := server.requireFreshTOTP(c, username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	allowedIPs := req.AllowedIPs
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+	slices.Sort(req.Scopes)
+
+	var signingSecret pgtype.Text
+	if req.RequireSignature {
+		signingSecret = pgtype.Text{String: newSigningSecret(), Valid: true}
+	}
+
+	key, hash := newAPIKey()
+	apiKey, err := server.Store.CreateApiKeyTx(c, db.CreateApiKeyParams{
+		Username:   username,
+		Name:       req.Name,
+		KeyHash:    hash,
+		Prefix:     key[:apiKeyShownPrefix],
+		Scopes:     slices.Compact(req.Scopes),
+		AllowedIps: allowedIPs,
+		ExpiresAt:  expiresAt,
+
+		SigningSecret: signingSecret,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+	server.logAudit(c, "api_key.created", "api_key", strconv.FormatInt(apiKey.ID, 10), "user", username, "scopes", apiKey.Scopes,
+		"signature_required", req.RequireSignature)
+
+	c.JSON(http.StatusCreated, createAPIKeyResponse{
+		apiKeyResponse: newAPIKeyResponse(apiKey),
+		Key:            key,
+		SigningSecret:  signingSecret.String,
+	})
+}
+
+// ListAPIKeys lists the caller's API keys that are not revoked.
+func (server *Server) ListAPIKeys(c *gin.Context) {
+	keys, err := server.Store.ListApiKeys(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	response := make([]apiKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = newAPIKeyResponse(key)
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+type apiKeyIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// RevokeAPIKey stops one of the caller's API keys from working.
+func (server *Server) RevokeAPIKey(c *gin.Context) {
+	var req apiKeyIDRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	username := authPayload(c).Username
+	revoked, err := server.Store.RevokeApiKeyTx(c, db.RevokeApiKeyParams{ID: req.ID, Username: username})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+	if revoked == 0 {
+		c.JSON(http.StatusNotFound, errorResponse(c, errAPIKeyNotFound))
+		return
+	}
+	server.logAudit(c, "api_key.revoked", "api_key", strconv.FormatInt(req.ID, 10), "user", username)
+
+	c.Status(http.StatusNoContent)
+}
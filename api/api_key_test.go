@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/pb"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// withAPIKey calls the server with an API key from clientIP.
+func (s *passwordTestServer) withAPIKey(method, path, key, clientIP string, body any) int {
+	data, err := json.Marshal(body)
+	require.NoError(s.t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(method, path, bytes.NewReader(data))
+	require.NoError(s.t, err)
+	request.Header.Set(apiKeyHeaderKey, key)
+	request.RemoteAddr = clientIP + ":4321"
+	s.server.Router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	email := username + "@example.com"
+	headers := bearer(s.signUp(username, email))
+	require.Equal(t, http.StatusOK, s.verifyEmail(s.verificationCode(email)))
+
+	create := func(req createAPIKeyRequest) (int, createAPIKeyResponse) {
+		var response createAPIKeyResponse
+		code := s.call(http.MethodPost, "/v2/users/me/api_keys", req, headers, &response)
+		return code, response
+	}
+
+	code, _ := create(createAPIKeyRequest{Name: "reports", Scopes: []string{"accounts:admin"}})
+	require.Equal(t, http.StatusBadRequest, code, "unknown scope")
+	code, _ = create(createAPIKeyRequest{Name: "reports", Scopes: []string{"accounts:read"}, AllowedIPs: []string{"not an ip"}})
+	require.Equal(t, http.StatusBadRequest, code, "invalid IP")
+	past := time.Now().Add(-time.Minute)
+	code, _ = create(createAPIKeyRequest{Name: "reports", Scopes: []string{"accounts:read"}, ExpiresAt: &past})
+	require.Equal(t, http.StatusBadRequest, code, "expiry in the past")
+
+	code, reports := create(createAPIKeyRequest{Name: "reports", Scopes: []string{"accounts:read"}})
+	require.Equal(t, http.StatusCreated, code)
+	require.True(t, strings.HasPrefix(reports.Key, apiKeyPrefix))
+	require.True(t, strings.HasPrefix(reports.Key, reports.Prefix))
+	code, _ = create(createAPIKeyRequest{Name: "reports", Scopes: []string{"accounts:read"}})
+	require.Equal(t, http.StatusForbidden, code, "duplicate name")
+
+	// The key does what its scopes allow, and nothing else.
+	listAccounts := "/v2/accounts?page_id=1&page_size=5"
+	require.Equal(t, http.StatusAccepted, s.withAPIKey(http.MethodGet, listAccounts, reports.Key, "192.0.2.1", nil))
+	require.Equal(t, http.StatusForbidden, s.withAPIKey(http.MethodPost, "/v2/accounts", reports.Key, "192.0.2.1", createAccountRequest{Currency: "USD"}))
+	require.Equal(t, http.StatusForbidden, s.withAPIKey(http.MethodGet, "/v2/users/me/api_keys", reports.Key, "192.0.2.1", nil))
+	require.Equal(t, http.StatusUnauthorized, s.withAPIKey(http.MethodGet, listAccounts, reports.Key[:len(reports.Key)-1], "192.0.2.1", nil))
+
+	both := bearer(createTestToken(t, s.server, username))
+	both[apiKeyHeaderKey] = reports.Key
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodGet, listAccounts, nil, both, nil))
+
+	code, writer := create(createAPIKeyRequest{
+		Name:       "payments",
+		Scopes:     []string{"accounts:write", "transfers:write"},
+		AllowedIPs: []string{"192.0.2.0/24", "2001:db8::1"},
+	})
+	require.Equal(t, http.StatusCreated, code)
+	require.Equal(t, http.StatusCreated, s.withAPIKey(http.MethodPost, "/v2/accounts", writer.Key, "192.0.2.7", createAccountRequest{Currency: "USD"}))
+	require.Equal(t, http.StatusForbidden, s.withAPIKey(http.MethodPost, "/v2/accounts", writer.Key, "198.51.100.7", createAccountRequest{Currency: "EUR"}))
+	require.Equal(t, http.StatusCreated, s.withAPIKey(http.MethodPost, "/v2/accounts", writer.Key, "[2001:db8::1]", createAccountRequest{Currency: "EUR"}))
+
+	var keys []apiKeyResponse
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodGet, "/v2/users/me/api_keys", nil, headers, &keys))
+	require.Len(t, keys, 2)
+	require.Equal(t, "reports", keys[0].Name)
+	require.True(t, keys[0].LastUsedAt.Valid)
+
+	// A revoked key stops working at once.
+	require.Equal(t, http.StatusNoContent, s.call(http.MethodDelete, "/v2/users/me/api_keys/"+fmt.Sprint(reports.ID), nil, headers, nil))
+	require.Equal(t, http.StatusNotFound, s.call(http.MethodDelete, "/v2/users/me/api_keys/"+fmt.Sprint(reports.ID), nil, headers, nil))
+	require.Equal(t, http.StatusUnauthorized, s.withAPIKey(http.MethodGet, listAccounts, reports.Key, "192.0.2.1", nil))
+
+	// Others cannot revoke the user's keys.
+	other := util.RandomOwner()
+	otherHeaders := bearer(s.signUp(other, other+"@example.com"))
+	require.Equal(t, http.StatusNotFound, s.call(http.MethodDelete, "/v2/users/me/api_keys/"+fmt.Sprint(writer.ID), nil, otherHeaders, nil))
+}
+
+func TestAPIKeyExpires(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	s.signUp(username, username+"@example.com")
+
+	key, hash := newAPIKey()
+	_, err := s.store.CreateApiKey(t.Context(), db.CreateApiKeyParams{
+		Username:   username,
+		Name:       "expired",
+		KeyHash:    hash,
+		Prefix:     key[:apiKeyShownPrefix],
+		Scopes:     []string{"accounts:read"},
+		AllowedIps: []string{},
+		ExpiresAt:  pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusUnauthorized, s.withAPIKey(http.MethodGet, "/v2/accounts?page_id=1&page_size=5", key, "192.0.2.1", nil))
+}
+
+func TestAPIKeyForwardedFor(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	s.signUp(username, username+"@example.com")
+
+	key, hash := newAPIKey()
+	_, err := s.store.CreateApiKey(t.Context(), db.CreateApiKeyParams{
+		Username:   username,
+		Name:       "office",
+		KeyHash:    hash,
+		Prefix:     key[:apiKeyShownPrefix],
+		Scopes:     []string{"accounts:read"},
+		AllowedIps: []string{"192.0.2.0/24"},
+	})
+	require.NoError(t, err)
+
+	listAccounts := func(server *Server, peer, forwardedFor string) int {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/v2/accounts?page_id=1&page_size=5", nil)
+		require.NoError(t, err)
+		request.Header.Set(apiKeyHeaderKey, key)
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		request.RemoteAddr = peer + ":4321"
+		server.Router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// By default no proxy is trusted, so a client cannot name an allowed IP for itself.
+	require.Equal(t, http.StatusForbidden, listAccounts(s.server, "198.51.100.7", "192.0.2.7"))
+	require.Equal(t, http.StatusAccepted, listAccounts(s.server, "192.0.2.7", "198.51.100.7"))
+
+	// Behind a trusted proxy, the client is the one the proxy names.
+	config := newTestConfig()
+	config.TrustedProxies = []string{"10.0.0.1"}
+	proxied := newTestServer(t, config, s.store)
+	require.Equal(t, http.StatusAccepted, listAccounts(proxied, "10.0.0.1", "192.0.2.7"))
+	require.Equal(t, http.StatusForbidden, listAccounts(proxied, "10.0.0.1", "198.51.100.7"))
+	require.Equal(t, http.StatusForbidden, listAccounts(proxied, "198.51.100.7", "192.0.2.7"))
+}
+
+func TestGRPCAPIKey(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	headers := bearer(s.signUp(username, username+"@example.com"))
+
+	var created createAPIKeyResponse
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/users/me/api_keys",
+		createAPIKeyRequest{Name: "reports", Scopes: []string{"accounts:read"}}, headers, &created))
+
+	client := newTestGRPCClient(t, s.server)
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "x-api-key", created.Key)
+
+	_, err := client.ListAccounts(ctx, &pb.ListAccountsRequest{PageId: 1, PageSize: 5})
+	require.NoError(t, err)
+	_, err = client.CreateAccount(ctx, &pb.CreateAccountRequest{Currency: "USD"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(t.Context(), "x-api-key", apiKeyPrefix+"unknown")
+	_, err = client.ListAccounts(ctx, &pb.ListAccountsRequest{PageId: 1, PageSize: 5})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// auditExportPageSize is how many entries ExportAuditLog reads at a time.
+	auditExportPageSize = 500
+)
+
+// audit records an event that is not part of a store transaction, such as a lockout, in
+// the audit log, as done by the actor of ctx to a resource; attrs, in key-value pairs,
+// become the state after it. Every event is also logged with the message "audit" and an
+// event attribute, so that they can be picked out of the request logs. The change has
+// already been made, so an entry that cannot be written is only logged.
+func (server *Server) audit(ctx context.Context, event, resourceType, resourceID string, attrs ...any) {
+	server.logAudit(ctx, event, resourceType, resourceID, attrs...)
+
+	var after map[string]any
+	if len(attrs) > 0 {
+		after = make(map[string]any, len(attrs)/2)
+		for i := 0; i+1 < len(attrs); i += 2 {
+			value := attrs[i+1]
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			after[fmt.Sprint(attrs[i])] = value
+		}
+	}
+
+	_, err := db.WriteAudit(ctx, server.Store, db.AuditEntry{
+		Action:       event,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		After:        after,
+	})
+	if err != nil {
+		server.Logger.ErrorContext(ctx, "cannot write audit log", "event", event, "error", err)
+	}
+}
+
+// logAudit logs an audit event without writing it to the audit log.
+func (server *Server) logAudit(ctx context.Context, event, resourceType, resourceID string, attrs ...any) {
+	actor := db.AuditActorFrom(ctx)
+	server.Logger.InfoContext(ctx, "audit", append([]any{
+		"event", event, resourceType, resourceID, "actor_type", actor.Type, "actor", actor.Username, "client_ip", actor.ClientIP,
+	}, attrs...)...)
+}
+
+// auditLogin records that user logged in. The actor is the user, from where the
+// anonymous caller was.
+func (server *Server) auditLogin(ctx context.Context, user db.User) {
+	actor := db.AuditActorFrom(ctx)
+	actor.Type, actor.Username, actor.APIKeyID = db.AuditActorUser, user.Username, 0
+	server.audit(db.WithAuditActor(ctx, actor), "login.succeeded", "user", user.Username)
+}
+
+// auditActorMiddleware makes every request anonymous until authMiddleware knows better, so
+// that what it changes is recorded with the client IP.
+func auditActorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setAuditActor(c, db.AuditActor{Type: db.AuditActorAnonymous, ClientIP: c.ClientIP()})
+		c.Next()
+	}
+}
+
+// setAuditActor puts actor in the request context, which the store reads it from.
+func setAuditActor(c *gin.Context, actor db.AuditActor) {
+	c.Request = c.Request.WithContext(db.WithAuditActor(c.Request.Context(), actor))
+}
+
+type auditLogFilter struct {
+	Actor        string    `form:"actor"`
+	Action       string    `form:"action"`
+	ResourceType string    `form:"resource_type"`
+	ResourceID   string    `form:"resource_id"`
+	Since        time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until        time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type listAuditLogRequest struct {
+	auditLogFilter
+	AfterID  int64 `form:"after_id" binding:"min=0"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=100"`
+}
+
+// params returns the query for the entries after afterID that match the filter.
+func (f auditLogFilter) params(afterID int64, limit int32) (db.ListAuditLogParams, error) {
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return db.ListAuditLogParams{}, errors.New("since must be before until")
+	}
+
+	text := func(s string) pgtype.Text { return pgtype.Text{String: s, Valid: s != ""} }
+	timestamp := func(t time.Time) pgtype.Timestamptz { return pgtype.Timestamptz{Time: t, Valid: !t.IsZero()} }
+	return db.ListAuditLogParams{
+		AfterID:      afterID,
+		Actor:        text(f.Actor),
+		Action:       text(f.Action),
+		ResourceType: text(f.ResourceType),
+		ResourceID:   text(f.ResourceID),
+		Since:        timestamp(f.Since),
+		Until:        timestamp(f.Until),
+		LimitCount:   limit,
+	}, nil
+}
+
+type auditLogResponse struct {
+	ID           int64           `json:"id"`
+	ActorType    string          `json:"actor_type"`
+	Actor        string          `json:"actor,omitempty"`
+	APIKeyID     int64           `json:"api_key_id,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	ClientIP     string          `json:"client_ip,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func newAuditLogResponse(entry db.AuditLog) auditLogResponse {
+	return auditLogResponse{
+		ID:           entry.ID,
+		ActorType:    entry.ActorType,
+		Actor:        entry.Actor.String,
+		APIKeyID:     entry.ApiKeyID.Int64,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		ClientIP:     entry.ClientIp.String,
+		RequestID:    entry.RequestID.String,
+		Before:       entry.Before,
+		After:        entry.After,
+		CreatedAt:    entry.CreatedAt.Time,
+	}
+}
+
+// ListAuditLog lists the audit log, oldest first, to admins and auditors. A page ends
+// with the entry whose ID the next page passes as after_id.
+func (server *Server) ListAuditLog(c *gin.Context) {
+	var req listAuditLogRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	arg, err := req.params(req.AfterID, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	entries, err := server.Store.ListAuditLog(c, arg)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	response := make([]auditLogResponse, len(entries))
+	for i, entry := range entries {
+		response[i] = newAuditLogResponse(entry)
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// ExportAuditLog writes every entry of the audit log that matches the filter as
+// newline-delimited JSON, one entry per line, oldest first. It reads the log a page at a
+// time, so that an export of the whole log does not hold it in memory. An export that
+// fails once it has started ends with an error line, so that it cannot pass for complete.
+func (server *Server) ExportAuditLog(c *gin.Context) {
+	var req auditLogFilter
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	arg, err := req.params(0, auditExportPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	encoder := json.NewEncoder(c.Writer)
+	for started := false; ; {
+		entries, err := server.Store.ListAuditLog(c, arg)
+		if err != nil {
+			if !started {
+				c.JSON(errorStatus(err), errorResponse(c, err))
+				return
+			}
+			c.Error(err)
+			encoder.Encode(errorResponse(c, err))
+			return
+		}
+
+		if !started {
+			c.Header("Content-Type", ndjsonContentType)
+			c.Header("Content-Disposition", `attachment; filename="audit_log.ndjson"`)
+			c.Status(http.StatusOK)
+			started = true
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(newAuditLogResponse(entry)); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+
+		if len(entries) < auditExportPageSize {
+			return
+		}
+		arg.AfterID = entries[len(entries)-1].ID
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	s := newPasswordTestServer(t)
+
+	// signUpAs signs up a user with role and logs them in again to get a token with it.
+	signUpAs := func(role db.UserRole) (string, map[string]string) {
+		username := util.RandomOwner()
+		s.signUp(username, username+"@example.com")
+		_, err := s.store.UpdateUserRole(t.Context(), db.UpdateUserRoleParams{Username: username, Role: role})
+		require.NoError(t, err)
+		code, accessToken := s.login(username, "first password")
+		require.Equal(t, http.StatusAccepted, code)
+		return username, bearer(accessToken)
+	}
+	customer, customerHeaders := signUpAs(db.UserRoleCustomer)
+	admin, adminHeaders := signUpAs(db.UserRoleAdmin)
+	_, auditorHeaders := signUpAs(db.UserRoleAuditor)
+
+	code, _ := s.login(customer, "wrong password")
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, "/v2/users/"+customer+"/role",
+		updateUserRoleRequest{Role: db.UserRoleTeller}, adminHeaders, nil))
+
+	account, err := s.store.CreateAccount(t.Context(), db.CreateAccountParams{
+		Owner:       customer,
+		Balance:     pgtype.Numeric{Int: big.NewInt(0), Valid: true},
+		Currency:    "USD",
+		AccountType: db.AccountTypeChecking,
+	})
+	require.NoError(t, err)
+	limit := int64(500)
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, fmt.Sprintf("/v2/admin/accounts/%d/overdraft", account.ID),
+		updateOverdraftLimitRequest{OverdraftLimit: &limit}, adminHeaders, nil))
+
+	list := func(query url.Values, headers map[string]string) (int, []auditLogResponse) {
+		var entries []auditLogResponse
+		code := s.call(http.MethodGet, "/v2/audit_log?"+query.Encode(), nil, headers, &entries)
+		return code, entries
+	}
+	actions := func(entries []auditLogResponse) []string {
+		actions := make([]string, len(entries))
+		for i, entry := range entries {
+			actions[i] = entry.Action
+		}
+		return actions
+	}
+
+	query := url.Values{"resource_type": {"user"}, "resource_id": {customer}, "page_size": {"10"}}
+	code, entries := list(query, auditorHeaders)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, []string{"user.created", "login.succeeded", "login.succeeded", "login.failed", "user.role_changed"}, actions(entries))
+
+	login := entries[1]
+	require.Equal(t, db.AuditActorUser, login.ActorType)
+	require.Equal(t, customer, login.Actor)
+	require.NotEmpty(t, login.RequestID)
+
+	changed := entries[4]
+	require.Equal(t, db.AuditActorUser, changed.ActorType)
+	require.Equal(t, admin, changed.Actor)
+	require.JSONEq(t, fmt.Sprintf(`{"username":%q,"role":"customer"}`, customer), string(changed.Before))
+	require.JSONEq(t, fmt.Sprintf(`{"username":%q,"role":"teller"}`, customer), string(changed.After))
+
+	// The next page starts after the last entry of this one.
+	query.Set("after_id", fmt.Sprint(entries[2].ID))
+	code, entries = list(query, adminHeaders)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, []string{"login.failed", "user.role_changed"}, actions(entries))
+
+	code, entries = list(url.Values{"resource_type": {"account"}, "page_size": {"10"}}, auditorHeaders)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, []string{"account.overdraft_limit_changed"}, actions(entries))
+	require.Equal(t, db.AuditActorUser, entries[0].ActorType)
+	require.Equal(t, admin, entries[0].Actor)
+
+	code, entries = list(url.Values{"actor": {admin}, "action": {"user.role_changed"}, "page_size": {"10"}}, auditorHeaders)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Len(t, entries, 1)
+
+	now := time.Now()
+	code, entries = list(url.Values{"since": {now.Add(time.Minute).Format(time.RFC3339)}, "page_size": {"10"}}, auditorHeaders)
+	require.Equal(t, http.StatusAccepted, code)
+	require.Empty(t, entries)
+	code, _ = list(url.Values{
+		"since": {now.Format(time.RFC3339)}, "until": {now.Add(-time.Hour).Format(time.RFC3339)}, "page_size": {"10"},
+	}, auditorHeaders)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = list(url.Values{"page_size": {"1000"}}, auditorHeaders)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// The token issued before the change of role no longer works, and customers, even with
+	// the right query, cannot read the log.
+	code, _ = list(url.Values{"page_size": {"10"}}, customerHeaders)
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, _ = list(url.Values{"page_size": {"10"}}, bearer(createTestToken(t, s.server, customer)))
+	require.Equal(t, http.StatusForbidden, code)
+
+	// The export holds every matching entry, one JSON object per line.
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/v2/audit_log/export?action=login.succeeded", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, auditorHeaders[authorizationHeaderKey])
+	s.server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, ndjsonContentType, recorder.Header().Get("Content-Type"))
+
+	var exported []auditLogResponse
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var entry auditLogResponse
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		require.Equal(t, "login.succeeded", entry.Action)
+		exported = append(exported, entry)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, exported, 6, "two logins each of three users")
+	for i := 1; i < len(exported); i++ {
+		require.Less(t, exported[i-1].ID, exported[i].ID)
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type createDataRequestRequest struct {
+	Kind   db.DataRequestKind `json:"kind" binding:"required,oneof=erasure"`
+	Reason string             `json:"reason" binding:"max=1000"`
+}
+
+type dataRequestResponse struct {
+	ID         int64                `json:"id"`
+	Username   string               `json:"username"`
+	Kind       db.DataRequestKind   `json:"kind"`
+	Status     db.DataRequestStatus `json:"status"`
+	Reason     string               `json:"reason"`
+	ReviewedBy string               `json:"reviewed_by,omitempty"`
+	ReviewNote string               `json:"review_note,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	ReviewedAt pgtype.Timestamptz   `json:"reviewed_at"`
+}
+
+func newDataRequestResponse(request db.DataRequest) dataRequestResponse {
+	return dataRequestResponse{
+		ID:         request.ID,
+		Username:   request.Username,
+		Kind:       request.Kind,
+		Status:     request.Status,
+		Reason:     request.Reason,
+		ReviewedBy: request.ReviewedBy.String,
+		ReviewNote: request.ReviewNote,
+		CreatedAt:  request.CreatedAt.Time,
+		ReviewedAt: request.ReviewedAt,
+	}
+}
+
+func newDataRequestResponses(requests []db.DataRequest) []dataRequestResponse {
+	response := make([]dataRequestResponse, len(requests))
+	for i, request := range requests {
+		response[i] = newDataRequestResponse(request)
+	}
+	return response
+}
+
+// CreateDataRequest asks for the caller's data to be erased, once every account of theirs
+// is closed. An admin reviews the request. Erasure cannot be undone, so users enrolled in
+// two-factor authentication need a fresh TOTP code for it.
+func (server *Server) CreateDataRequest(c *gin.Context) {
+	var req createDataRequestRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	username := authPayload(c).Username
+	if err := server.requireFreshTOTP(c, username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	request, err := server.Store.RequestErasureTx(c, db.RequestErasureTxParams{Username: username, Reason: req.Reason})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, newDataRequestResponse(request))
+}
+
+// ListCurrentUserDataRequests lists the caller's data requests, oldest first.
+func (server *Server) ListCurrentUserDataRequests(c *gin.Context) {
+	requests, err := server.Store.ListDataRequestsByUser(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, newDataRequestResponses(requests))
+}
+
+type listDataRequestsRequest struct {
+	Status   db.DataRequestStatus `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	AfterID  int64                `form:"after_id" binding:"min=0"`
+	PageSize int32                `form:"page_size" binding:"required,min=5,max=100"`
+}
+
+// ListDataRequests lists the data requests of every user, oldest first, to admins. A page
+// ends with the request whose ID the next page passes as after_id.
+func (server *Server) ListDataRequests(c *gin.Context) {
+	var req listDataRequestsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	requests, err := server.Store.ListDataRequests(c, db.ListDataRequestsParams{
+		AfterID:    req.AfterID,
+		Status:     db.NullDataRequestStatus{DataRequestStatus: req.Status, Valid: req.Status != ""},
+		LimitCount: req.PageSize,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, newDataRequestResponses(requests))
+}
+
+type dataRequestIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type reviewDataRequestRequest struct {
+	Status db.DataRequestStatus `json:"status" binding:"required,oneof=approved rejected"`
+	Note   string               `json:"note" binding:"max=1000"`
+}
+
+// ReviewDataRequest approves or rejects a pending data request. Approving an erasure
+// carries it out: the user's personal data is replaced, they can no longer log in, and
+// their financial records are kept under their username.
+func (server *Server) ReviewDataRequest(c *gin.Context) {
+	var uri dataRequestIDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var req reviewDataRequestRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	request, err := server.Store.ReviewDataRequestTx(c, db.ReviewDataRequestTxParams{
+		ID:         uri.ID,
+		Approve:    req.Status == db.DataRequestStatusApproved,
+		ReviewedBy: authPayload(c).Username,
+		Note:       req.Note,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newDataRequestResponse(request))
+}
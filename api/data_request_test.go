@@ -0,0 +1,280 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestAccount creates an account for owner straight in the store, with balance.
+func (s *passwordTestServer) createTestAccount(owner string, balance int64) db.Account {
+	account, err := s.store.CreateAccount(s.t.Context(), db.CreateAccountParams{
+		Owner:       owner,
+		Balance:     pgtype.Numeric{Int: big.NewInt(balance), Valid: true},
+		Currency:    "USD",
+		AccountType: db.AccountTypeChecking,
+	})
+	require.NoError(s.t, err)
+	return account
+}
+
+// readZip reads every file of a ZIP archive by name.
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		files[file.Name], err = io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+	}
+	return files
+}
+
+func TestExportCurrentUser(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username, other := util.RandomOwner(), util.RandomOwner()
+	headers := bearer(s.signUp(username, username+"@example.com"))
+	s.signUp(other, other+"@example.com")
+
+	account := s.createTestAccount(username, 100)
+	otherAccount := s.createTestAccount(other, 100)
+	_, err := s.store.TransferTx(t.Context(), db.TransferTxParams{FromAccountId: account.ID, ToAccountId: otherAccount.ID, Amount: 10})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/v2/users/me/export", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, headers[authorizationHeaderKey])
+	s.server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, zipContentType, recorder.Header().Get("Content-Type"))
+	require.Contains(t, recorder.Header().Get("Content-Disposition"), "simplebank-"+username+".zip")
+
+	files := readZip(t, recorder.Body.Bytes())
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	require.ElementsMatch(t, []string{
+		"profile.json", "profile.csv", "accounts.json", "accounts.csv", "entries.json", "entries.csv",
+		"transfers.json", "transfers.csv", "sessions.json", "sessions.csv", "audit_events.json", "audit_events.csv",
+	}, names)
+
+	var profile []userResponse
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	require.Len(t, profile, 1)
+	require.Equal(t, username, profile[0].Username)
+	require.Equal(t, username+"@example.com", profile[0].Email)
+
+	var accounts []accountResponseV2
+	require.NoError(t, json.Unmarshal(files["accounts.json"], &accounts))
+	require.Len(t, accounts, 1)
+	require.Equal(t, account.ID, accounts[0].ID)
+
+	var entries []entryResponse
+	require.NoError(t, json.Unmarshal(files["entries.json"], &entries))
+	require.Len(t, entries, 1, "only the entry of the user's own account")
+	require.Equal(t, "-10.00", entries[0].Amount)
+
+	var transfers []transferExport
+	require.NoError(t, json.Unmarshal(files["transfers.json"], &transfers))
+	require.Len(t, transfers, 1)
+	require.Equal(t, otherAccount.ID, transfers[0].ToAccountID)
+
+	var sessions []sessionExport
+	require.NoError(t, json.Unmarshal(files["sessions.json"], &sessions))
+	require.Len(t, sessions, 1)
+
+	var events []auditLogResponse
+	require.NoError(t, json.Unmarshal(files["audit_events.json"], &events))
+	for _, event := range events {
+		require.NotEqual(t, other, event.ResourceID, "nothing about other users")
+	}
+
+	// Every CSV has a row per JSON object, with the JSON names as its header.
+	for name, data := range files {
+		table, ok := strings.CutSuffix(name, ".csv")
+		if !ok {
+			continue
+		}
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		require.NoError(t, err, name)
+
+		var rows []map[string]any
+		require.NoError(t, json.Unmarshal(files[table+".json"], &rows))
+		require.Len(t, records, len(rows)+1, name)
+		for _, row := range rows {
+			for key := range row {
+				require.True(t, slices.Contains(records[0], key), "%s has no %s column", name, key)
+			}
+		}
+	}
+	require.Equal(t, []string{"id", "account_id", "amount", "created_at"}, mustReadCSVHeader(t, files["entries.csv"]))
+
+	// The export itself is in the audit log.
+	exported, err := s.store.ListAuditLog(t.Context(), db.ListAuditLogParams{
+		Action:     pgtype.Text{String: "user.exported", Valid: true},
+		LimitCount: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, exported, 1)
+	require.Equal(t, username, exported[0].ResourceID)
+}
+
+func TestExportCurrentUserHidesStaff(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username, admin := util.RandomOwner(), util.RandomOwner()
+	headers := bearer(s.signUp(username, username+"@example.com"))
+	s.signUp(admin, admin+"@example.com")
+	_, err := s.store.UpdateUserRole(t.Context(), db.UpdateUserRoleParams{Username: admin, Role: db.UserRoleAdmin})
+	require.NoError(t, err)
+	code, adminToken := s.login(admin, "first password")
+	require.Equal(t, http.StatusAccepted, code)
+
+	account := s.createTestAccount(username, 100)
+	frozen := true
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, fmt.Sprintf("/v2/accounts/%d/freeze", account.ID),
+		freezeAccountRequest{Frozen: &frozen}, bearer(adminToken), nil))
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/v2/users/me/export", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, headers[authorizationHeaderKey])
+	s.server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	files := readZip(t, recorder.Body.Bytes())
+	var events []auditLogResponse
+	require.NoError(t, json.Unmarshal(files["audit_events.json"], &events))
+
+	var freezes, own int
+	for _, event := range events {
+		switch {
+		case event.Action == db.EventAccountFrozen:
+			freezes++
+			require.Equal(t, strconv.FormatInt(account.ID, 10), event.ResourceID)
+			require.Empty(t, event.Actor, "the staff member is not named")
+			require.Empty(t, event.ClientIP, "nor is where they worked from")
+		case event.Actor == username:
+			own++
+		}
+	}
+	require.Equal(t, 1, freezes)
+	require.NotZero(t, own, "the user's own events still name them")
+	for name, data := range files {
+		require.NotContains(t, string(data), admin, name)
+	}
+}
+
+func mustReadCSVHeader(t *testing.T, data []byte) []string {
+	header, err := csv.NewReader(bytes.NewReader(data)).Read()
+	require.NoError(t, err)
+	return header
+}
+
+func TestCloseAccount(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username, other := util.RandomOwner(), util.RandomOwner()
+	headers := bearer(s.signUp(username, username+"@example.com"))
+	otherHeaders := bearer(s.signUp(other, other+"@example.com"))
+
+	account := s.createTestAccount(username, 10)
+	path := fmt.Sprintf("/v2/accounts/%d/close", account.ID)
+
+	require.Equal(t, http.StatusForbidden, s.call(http.MethodPatch, path, nil, otherHeaders, nil))
+	require.Equal(t, http.StatusConflict, s.call(http.MethodPatch, path, nil, headers, nil), "balance is not zero")
+
+	_, err := s.store.AddAccountBalance(t.Context(), db.AddAccountBalanceParams{
+		Amount: pgtype.Numeric{Int: big.NewInt(-10), Valid: true},
+		ID:     account.ID,
+	})
+	require.NoError(t, err)
+
+	var closed accountResponseV2
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, path, nil, headers, &closed))
+	require.True(t, closed.ClosedAt.Valid)
+
+	// Closing it again changes nothing.
+	var again accountResponseV2
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, path, nil, headers, &again))
+	require.Equal(t, closed.ClosedAt, again.ClosedAt)
+
+	require.Equal(t, http.StatusNotFound, s.call(http.MethodPatch, "/v2/accounts/999999/close", nil, headers, nil))
+}
+
+func TestDataRequests(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username, admin := util.RandomOwner(), util.RandomOwner()
+	headers := bearer(s.signUp(username, username+"@example.com"))
+	s.signUp(admin, admin+"@example.com")
+	_, err := s.store.UpdateUserRole(t.Context(), db.UpdateUserRoleParams{Username: admin, Role: db.UserRoleAdmin})
+	require.NoError(t, err)
+	code, adminToken := s.login(admin, "first password")
+	require.Equal(t, http.StatusAccepted, code)
+	adminHeaders := bearer(adminToken)
+
+	account := s.createTestAccount(username, 0)
+	erasure := createDataRequestRequest{Kind: db.DataRequestKindErasure, Reason: "leaving"}
+
+	require.Equal(t, http.StatusBadRequest, s.call(http.MethodPost, "/v2/users/me/data_requests",
+		createDataRequestRequest{Kind: "export"}, headers, nil))
+	require.Equal(t, http.StatusConflict, s.call(http.MethodPost, "/v2/users/me/data_requests", erasure, headers, nil),
+		"an account is open")
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, fmt.Sprintf("/v2/accounts/%d/close", account.ID), nil, headers, nil))
+
+	var request dataRequestResponse
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/users/me/data_requests", erasure, headers, &request))
+	require.Equal(t, db.DataRequestStatusPending, request.Status)
+	require.Equal(t, http.StatusForbidden, s.call(http.MethodPost, "/v2/users/me/data_requests", erasure, headers, nil),
+		"already pending")
+
+	// Only admins see and review the requests.
+	path := fmt.Sprintf("/v2/data_requests/%d", request.ID)
+	approve := reviewDataRequestRequest{Status: db.DataRequestStatusApproved, Note: "checked"}
+	require.Equal(t, http.StatusForbidden, s.call(http.MethodGet, "/v2/data_requests?page_size=5", nil, headers, nil))
+	require.Equal(t, http.StatusForbidden, s.call(http.MethodPatch, path, approve, headers, nil))
+
+	var pending []dataRequestResponse
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodGet, "/v2/data_requests?status=pending&page_size=5", nil, adminHeaders, &pending))
+	require.Len(t, pending, 1)
+	require.Equal(t, request.ID, pending[0].ID)
+
+	var reviewed dataRequestResponse
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, path, approve, adminHeaders, &reviewed))
+	require.Equal(t, db.DataRequestStatusApproved, reviewed.Status)
+	require.Equal(t, admin, reviewed.ReviewedBy)
+	require.True(t, reviewed.ReviewedAt.Valid)
+	require.Equal(t, http.StatusConflict, s.call(http.MethodPatch, path, approve, adminHeaders, nil), "already reviewed")
+
+	// The erased user can no longer log in, and their sessions have ended.
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodGet, "/v2/users/me", nil, headers, nil))
+	code, _ = s.login(username, "first password")
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	// Their account stays, but not their name or email.
+	user, err := s.store.GetUser(t.Context(), username)
+	require.NoError(t, err)
+	require.Equal(t, db.ErasedFullName, user.FullName)
+	require.NotContains(t, user.Email, username)
+	_, err = s.store.GetAccount(t.Context(), account.ID)
+	require.NoError(t, err)
+}
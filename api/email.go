@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	errInvalidVerificationCode = errors.New("verification code is invalid or has expired")
+	errEmailNotVerified        = errors.New("email address is not verified")
+	errEmailAlreadyVerified    = errors.New("email address is already verified")
+)
+
+// emailVerificationMAC signs a verification row. The signature covers the address, so a
+// code stops working once the user changes it.
+func (server *Server) emailVerificationMAC(verification db.VerifyEmail) []byte {
+	mac := hmac.New(sha256.New, []byte(server.Config.TokenSymmetricKey))
+	mac.Write([]byte("verify_email\x00"))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(verification.ID)))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(verification.ExpiresAt.Time.UnixMicro())))
+	mac.Write([]byte(verification.Username + "\x00" + strings.ToLower(verification.Email)))
+	return mac.Sum(nil)
+}
+
+// emailVerificationCode returns the code that verifies a row: its ID and its signature.
+func (server *Server) emailVerificationCode(verification db.VerifyEmail) string {
+	return strconv.FormatInt(verification.ID, 10) + "." + base64.RawURLEncoding.EncodeToString(server.emailVerificationMAC(verification))
+}
+
+// checkEmailVerificationCode returns the row a code verifies, if its signature is right
+// and it is neither used nor expired.
+func (server *Server) checkEmailVerificationCode(ctx context.Context, code string) (db.VerifyEmail, error) {
+	idPart, sigPart, ok := strings.Cut(code, ".")
+	if !ok {
+		return db.VerifyEmail{}, errInvalidVerificationCode
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return db.VerifyEmail{}, errInvalidVerificationCode
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return db.VerifyEmail{}, errInvalidVerificationCode
+	}
+
+	verification, err := server.Store.GetVerifyEmail(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errInvalidVerificationCode
+		}
+		return db.VerifyEmail{}, err
+	}
+
+	if !hmac.Equal(signature, server.emailVerificationMAC(verification)) ||
+		verification.UsedAt.Valid || !time.Now().Before(verification.ExpiresAt.Time) {
+		return db.VerifyEmail{}, errInvalidVerificationCode
+	}
+	return verification, nil
+}
+
+// sendEmailVerification sends the user a code to verify their email address with.
+func (server *Server) sendEmailVerification(ctx context.Context, user db.User) (db.VerifyEmail, error) {
+	duration := server.Config.EmailVerificationDuration
+	verification, err := server.Store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username:  user.Username,
+		Email:     user.Email,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(duration), Valid: true},
+	})
+	if err != nil {
+		return db.VerifyEmail{}, err
+	}
+
+	err = server.Notifier.Notify(ctx, notify.Message{
+		Username: user.Username,
+		To:       user.Email,
+		Subject:  "Verify your Simple Bank email address",
+		Body: fmt.Sprintf("Your email verification code is %s. It is good for %s.\n"+
+			"If you did not sign up for Simple Bank, ignore this message.", server.emailVerificationCode(verification), duration),
+	})
+	return verification, err
+}
+
+// GetCurrentUser returns the caller's profile.
+func (server *Server) GetCurrentUser(c *gin.Context) {
+	user, err := server.Store.GetUser(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, newUserResponse(user))
+}
+
+// updateCurrentUserRequest changes the fields it sets and leaves the others alone.
+type updateCurrentUserRequest struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+// UpdateCurrentUser changes the caller's full name or email address. A new address needs
+// a fresh TOTP code from users enrolled in two-factor authentication, and is unverified
+// until the user enters the code sent to it; the old address is told of the change.
+func (server *Server) UpdateCurrentUser(c *gin.Context) {
+	var req updateCurrentUserRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+	if req.FullName == nil && req.Email == nil {
+		err := invalidArgument(errors.New("nothing to update: set full_name or email"))
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	user, err := server.Store.GetUser(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, user.Email)
+	if emailChanged {
+		if err := server.requireFreshTOTP(c, user.Username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+			loginErrorResponse(c, err)
+			return
+		}
+	}
+
+	arg := db.UpdateUserParams{Username: user.Username, EmailChanged: emailChanged}
+	if req.FullName != nil {
+		arg.FullName = pgtype.Text{String: *req.FullName, Valid: true}
+	}
+	if req.Email != nil {
+		arg.Email = pgtype.Text{String: *req.Email, Valid: true}
+	}
+	updated, err := server.Store.UpdateUserTx(c, arg)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	if emailChanged {
+		if _, err := server.sendEmailVerification(c, updated); err != nil {
+			server.Logger.ErrorContext(c, "cannot send email verification", "user", updated.Username, "error", err)
+		}
+		err = server.Notifier.Notify(c, notify.Message{
+			Username: user.Username,
+			To:       user.Email,
+			Subject:  "Your Simple Bank email address was changed",
+			Body: fmt.Sprintf("The email address of your Simple Bank user %s was changed to %s.\n"+
+				"If you did not change it, contact us right away.", user.Username, updated.Email),
+		})
+		if err != nil {
+			server.Logger.ErrorContext(c, "cannot send email change notice", "user", user.Username, "error", err)
+		}
+		server.logAudit(c, "user.email_changed", "user", user.Username)
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(updated))
+}
+
+type emailVerificationSentResponse struct {
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ResendEmailVerification sends the caller a new code for their unverified address. The
+// codes sent before keep working until they expire.
+func (server *Server) ResendEmailVerification(c *gin.Context) {
+	user, err := server.Store.GetUser(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		c.JSON(errorStatus(errEmailAlreadyVerified), errorResponse(c, errEmailAlreadyVerified))
+		return
+	}
+
+	verification, err := server.sendEmailVerification(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, emailVerificationSentResponse{
+		Message:   "a verification code has been sent to " + user.Email,
+		ExpiresAt: verification.ExpiresAt.Time,
+	})
+}
+
+type verifyEmailRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// VerifyEmail marks an email address as verified with the code sent to it. It needs no
+// access token: holding the code proves the address is the user's.
+func (server *Server) VerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	verification, err := server.checkEmailVerificationCode(c, req.Code)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	user, err := server.Store.VerifyEmailTx(c, db.VerifyEmailTxParams{
+		ID:       verification.ID,
+		Username: verification.Username,
+		Email:    verification.Email,
+	})
+	if err != nil {
+		// The code was used at the same time, or the address has changed since.
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errInvalidVerificationCode
+		}
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// requireVerifiedEmail refuses a user whose email address is not verified.
+func (server *Server) requireVerifiedEmail(ctx context.Context, username string) error {
+	user, err := server.Store.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return errEmailNotVerified
+	}
+	return nil
+}
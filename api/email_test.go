@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/totp"
+	"github.com/stretchr/testify/require"
+)
+
+// verificationCode reads the code out of the latest verification sent to the address.
+func (s *passwordTestServer) verificationCode(email string) string {
+	messages := s.notifier.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.To != email || !strings.Contains(msg.Body, "verification code is ") {
+			continue
+		}
+
+		var code string
+		_, err := fmt.Sscanf(msg.Body[strings.Index(msg.Body, "code is "):], "code is %s", &code)
+		require.NoError(s.t, err)
+		return strings.TrimSuffix(code, ".")
+	}
+	require.FailNow(s.t, "no verification code sent to "+email)
+	return ""
+}
+
+func (s *passwordTestServer) verifyEmail(code string) int {
+	return s.call(http.MethodPost, "/v2/users/email/verify", verifyEmailRequest{Code: code}, nil, nil)
+}
+
+// signUp creates a user whose password is "first password" and returns their access token.
+func (s *passwordTestServer) signUp(username, email string) string {
+	require.Equal(s.t, http.StatusCreated, s.call(http.MethodPost, "/v2/users",
+		CreateUserRequest{Username: username, FullName: "Test User", Email: email, Password: "first password"}, nil, nil))
+	code, accessToken := s.login(username, "first password")
+	require.Equal(s.t, http.StatusAccepted, code)
+	return accessToken
+}
+
+func TestEmailVerification(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	email := username + "@example.com"
+
+	require.Equal(t, http.StatusBadRequest, s.call(http.MethodPost, "/v2/users",
+		CreateUserRequest{Username: username, FullName: "Test User", Email: "not an email", Password: "first password"}, nil, nil))
+	headers := bearer(s.signUp(username, email))
+	code := s.verificationCode(email)
+
+	var user userResponse
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodGet, "/v2/users/me", nil, headers, &user))
+	require.Equal(t, email, user.Email)
+	require.False(t, user.EmailVerifiedAt.Valid)
+
+	// Only verified users open accounts.
+	openAccount := createAccountRequest{Currency: "USD"}
+	require.Equal(t, http.StatusForbidden, s.call(http.MethodPost, "/v2/accounts", openAccount, headers, nil))
+
+	id, signature, _ := strings.Cut(code, ".")
+	require.Equal(t, http.StatusUnauthorized, s.verifyEmail(id+".AAAA"+signature[4:]))
+	require.Equal(t, http.StatusUnauthorized, s.verifyEmail(id))
+	require.Equal(t, http.StatusUnauthorized, s.verifyEmail("12345."+signature))
+
+	require.Equal(t, http.StatusOK, s.call(http.MethodPost, "/v2/users/email/verify", verifyEmailRequest{Code: code}, nil, &user))
+	require.True(t, user.EmailVerifiedAt.Valid)
+	require.Equal(t, http.StatusUnauthorized, s.verifyEmail(code), "used code")
+
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/accounts", openAccount, headers, nil))
+	require.Equal(t, http.StatusConflict, s.call(http.MethodPost, "/v2/users/me/email/verification", nil, headers, nil))
+}
+
+func TestEmailVerificationExpires(t *testing.T) {
+	s := newPasswordTestServer(t)
+	s.server.Config.EmailVerificationDuration = -1
+	username := util.RandomOwner()
+	email := username + "@example.com"
+	headers := bearer(s.signUp(username, email))
+
+	require.Equal(t, http.StatusUnauthorized, s.verifyEmail(s.verificationCode(email)))
+
+	s.server.Config.EmailVerificationDuration = newTestConfig().EmailVerificationDuration
+	var sent emailVerificationSentResponse
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodPost, "/v2/users/me/email/verification", nil, headers, &sent))
+	require.Equal(t, http.StatusOK, s.verifyEmail(s.verificationCode(email)))
+}
+
+func TestUpdateCurrentUser(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	email := username + "@example.com"
+	headers := bearer(s.signUp(username, email))
+	firstCode := s.verificationCode(email)
+
+	other := util.RandomOwner()
+	s.signUp(other, other+"@example.com")
+
+	update := func(req updateCurrentUserRequest) (int, userResponse) {
+		var user userResponse
+		code := s.call(http.MethodPatch, "/v2/users/me", req, headers, &user)
+		return code, user
+	}
+	ptr := func(s string) *string { return &s }
+
+	code, _ := update(updateCurrentUserRequest{})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = update(updateCurrentUserRequest{Email: ptr("not an email")})
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = update(updateCurrentUserRequest{Email: ptr(strings.ToUpper(other) + "@EXAMPLE.COM")})
+	require.Equal(t, http.StatusForbidden, code, "address of another user")
+
+	code, user := update(updateCurrentUserRequest{FullName: ptr("New Name")})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "New Name", user.FullName)
+	require.Equal(t, email, user.Email)
+
+	// A new address must be verified again, and the old one is told of the change.
+	newEmail := "new-" + email
+	sent := len(s.notifier.Messages())
+	code, user = update(updateCurrentUserRequest{Email: &newEmail})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, newEmail, user.Email)
+	require.False(t, user.EmailVerifiedAt.Valid)
+
+	messages := s.notifier.Messages()[sent:]
+	require.Len(t, messages, 2)
+	require.Equal(t, newEmail, messages[0].To)
+	require.Equal(t, email, messages[1].To)
+	require.Contains(t, messages[1].Body, newEmail)
+
+	// The code sent to the old address verifies nothing now.
+	require.Equal(t, http.StatusUnauthorized, s.verifyEmail(firstCode))
+	require.Equal(t, http.StatusOK, s.verifyEmail(s.verificationCode(newEmail)))
+
+	// A change of case only keeps the address verified.
+	code, user = update(updateCurrentUserRequest{Email: ptr(strings.ToUpper(newEmail))})
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, user.EmailVerifiedAt.Valid)
+}
+
+func TestUpdateCurrentUserTOTP(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	s.signUp(username, username+"@example.com")
+
+	totpUser, secret := newTOTPUser(t, username)
+	_, err := s.store.SetUserTOTPSecret(t.Context(), db.SetUserTOTPSecretParams{Username: username, TotpSecret: totpUser.TotpSecret})
+	require.NoError(t, err)
+	_, err = s.store.EnableTOTPTx(t.Context(), db.EnableTOTPTxParams{Username: username, Step: totp.Step(totpUser.TotpEnabledAt.Time) - 2})
+	require.NoError(t, err)
+
+	headers := bearer(createTestToken(t, s.server, username))
+	newName := "New Name"
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, "/v2/users/me", updateCurrentUserRequest{FullName: &newName}, headers, nil))
+
+	newEmail := "new-" + username + "@example.com"
+	body := updateCurrentUserRequest{Email: &newEmail}
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodPatch, "/v2/users/me", body, headers, nil))
+
+	headers[totpCodeHeaderKey] = currentTOTPCode(t, secret, 0)
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, "/v2/users/me", body, headers, nil))
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	db "example.com/db/sqlc"
+	"example.com/signing"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorKind classifies request, store and authorization errors once, so that the HTTP
+// and gRPC servers report the same failure the same way.
+type errorKind int
+
+const (
+	kindInternal errorKind = iota
+	kindInvalidArgument
+	kindUnauthenticated
+	kindPermissionDenied
+	kindNotFound
+	kindAlreadyExists
+	kindMissingReference
+	kindInsufficientFunds
+	kindFailedPrecondition
+	kindTooManyRequests
+)
+
+var kindHTTPStatus = map[errorKind]int{
+	kindInternal:           http.StatusInternalServerError,
+	kindInvalidArgument:    http.StatusBadRequest,
+	kindUnauthenticated:    http.StatusUnauthorized,
+	kindPermissionDenied:   http.StatusForbidden,
+	kindNotFound:           http.StatusNotFound,
+	kindAlreadyExists:      http.StatusForbidden,
+	kindMissingReference:   http.StatusForbidden,
+	kindInsufficientFunds:  http.StatusUnprocessableEntity,
+	kindFailedPrecondition: http.StatusConflict,
+	kindTooManyRequests:    http.StatusTooManyRequests,
+}
+
+var kindGRPCCode = map[errorKind]codes.Code{
+	kindInternal:           codes.Internal,
+	kindInvalidArgument:    codes.InvalidArgument,
+	kindUnauthenticated:    codes.Unauthenticated,
+	kindPermissionDenied:   codes.PermissionDenied,
+	kindNotFound:           codes.NotFound,
+	kindAlreadyExists:      codes.AlreadyExists,
+	kindMissingReference:   codes.FailedPrecondition,
+	kindInsufficientFunds:  codes.FailedPrecondition,
+	kindFailedPrecondition: codes.FailedPrecondition,
+	kindTooManyRequests:    codes.ResourceExhausted,
+}
+
+// errInvalidArgument marks an error as the caller's fault.
+type errInvalidArgument struct{ err error }
+
+func (e errInvalidArgument) Error() string { return e.err.Error() }
+func (e errInvalidArgument) Unwrap() error { return e.err }
+
+func invalidArgument(err error) error {
+	return errInvalidArgument{err}
+}
+
+func classifyError(err error) errorKind {
+	var validationErrors validator.ValidationErrors
+	var invalid errInvalidArgument
+	var pgErr *pgconn.PgError
+	var throttled *loginThrottledError
+
+	switch {
+	case errors.As(err, &validationErrors), errors.As(err, &invalid):
+		return kindInvalidArgument
+	case errors.Is(err, errAccountNotOwned), errors.Is(err, errPermissionDenied), errors.Is(err, errEmailNotVerified),
+		errors.Is(err, errAPIKeyIPNotAllowed), errors.Is(err, errSignedRequestsOnly):
+		return kindPermissionDenied
+	case errors.Is(err, errTOTPRequired), errors.Is(err, errInvalidTOTPCode), errors.Is(err, errTokenRevoked),
+		errors.Is(err, errWrongPassword), errors.Is(err, errInvalidResetToken), errors.Is(err, errInvalidCredentials),
+		errors.Is(err, errInvalidVerificationCode), errors.Is(err, errInvalidAPIKey), errors.Is(err, errBothCredentials),
+		errors.Is(err, signing.ErrMissingSignature), errors.Is(err, signing.ErrInvalidSignature),
+		errors.Is(err, signing.ErrStaleSignature), errors.Is(err, signing.ErrReplayed):
+		return kindUnauthenticated
+	case errors.As(err, &throttled):
+		return kindTooManyRequests
+	case errors.Is(err, errTOTPDisabled), errors.Is(err, errTOTPNotPending), errors.Is(err, errTOTPAlreadyEnabled),
+		errors.Is(err, errEmailAlreadyVerified):
+		return kindFailedPrecondition
+	case errors.Is(err, pgx.ErrNoRows):
+		return kindNotFound
+	case errors.Is(err, db.ErrInsufficientFunds):
+		return kindInsufficientFunds
+	case errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrTransferReversed), errors.Is(err, db.ErrReversalNotReversible),
+		errors.Is(err, db.ErrAccountClosed), errors.Is(err, db.ErrAccountNotEmpty), errors.Is(err, db.ErrAccountsOpen),
+		errors.Is(err, db.ErrDataRequestReviewed):
+		return kindFailedPrecondition
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case "23505":
+			return kindAlreadyExists
+		case "23503":
+			return kindMissingReference
+		}
+	}
+
+	return kindInternal
+}
+
+// errorStatus returns the HTTP status for an error from a handler.
+func errorStatus(err error) int {
+	return kindHTTPStatus[classifyError(err)]
+}
+
+// grpcError converts an error from a handler into a gRPC status error.
+func grpcError(err error) error {
+	return status.Error(kindGRPCCode[classifyError(err)], err.Error())
+}
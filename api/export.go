@@ -0,0 +1,354 @@
+package api
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	zipContentType = "application/zip"
+	// exportPageSize is how many rows the export of a user's data reads at a time.
+	exportPageSize = 500
+)
+
+type transferExport struct {
+	ID            int64     `json:"id"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        string    `json:"amount"`
+	ReversalOf    *int64    `json:"reversal_of,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// sessionExport is a login, as the audit log recorded it.
+type sessionExport struct {
+	LoggedInAt time.Time `json:"logged_in_at"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// userExport is everything the bank holds about a user, one table per file.
+type userExport struct {
+	Profile     []userResponse
+	Accounts    []accountResponseV2
+	Entries     []entryResponse
+	Transfers   []transferExport
+	Sessions    []sessionExport
+	AuditEvents []auditLogResponse
+}
+
+// exportFile is a table of the export, written as name.json and name.csv.
+type exportFile struct {
+	name string
+	rows any
+}
+
+// files returns the tables of the export, in the order the archive holds them.
+func (export userExport) files() []exportFile {
+	return []exportFile{
+		{"profile", export.Profile},
+		{"accounts", export.Accounts},
+		{"entries", export.Entries},
+		{"transfers", export.Transfers},
+		{"sessions", export.Sessions},
+		{"audit_events", export.AuditEvents},
+	}
+}
+
+// ExportCurrentUser sends the caller a ZIP archive of their data: their profile, accounts,
+// ledger entries, transfers, logins and the audit events about them, each as JSON and as
+// CSV. The export lets account data out, so users enrolled in two-factor authentication
+// need a fresh TOTP code for it. It is read before anything is sent, so that a failure is
+// reported as an error rather than as a truncated archive.
+func (server *Server) ExportCurrentUser(c *gin.Context) {
+	username := authPayload(c).Username
+	if err := server.requireFreshTOTP(c, username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	export, err := server.exportUser(c, username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.Header("Content-Type", zipContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="simplebank-%s.zip"`, username))
+	c.Status(http.StatusOK)
+	if err := writeExportZip(c.Writer, export); err != nil {
+		c.Error(err)
+		return
+	}
+	server.audit(c, "user.exported", "user", username)
+}
+
+func (server *Server) exportUser(ctx context.Context, username string) (userExport, error) {
+	var export userExport
+
+	user, err := server.Store.GetUser(ctx, username)
+	if err != nil {
+		return export, err
+	}
+	export.Profile = []userResponse{newUserResponse(user)}
+
+	export.Accounts = []accountResponseV2{}
+	export.Entries = []entryResponse{}
+	accountIDs := []string{}
+	for offset := int32(0); ; offset += exportPageSize {
+		accounts, err := server.Store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: username, Limit: exportPageSize, Offset: offset})
+		if err != nil {
+			return export, err
+		}
+		for _, account := range accounts {
+			export.Accounts = append(export.Accounts, newAccountResponseV2(account))
+			accountIDs = append(accountIDs, strconv.FormatInt(account.ID, 10))
+			if export.Entries, err = server.appendEntries(ctx, export.Entries, account.ID); err != nil {
+				return export, err
+			}
+		}
+		if len(accounts) < exportPageSize {
+			break
+		}
+	}
+
+	export.Transfers = []transferExport{}
+	arg := db.ListTransfersByOwnerParams{Owner: username, LimitCount: exportPageSize}
+	for {
+		transfers, err := server.Store.ListTransfersByOwner(ctx, arg)
+		if err != nil {
+			return export, err
+		}
+		for _, transfer := range transfers {
+			row := transferExport{
+				ID:            transfer.ID,
+				FromAccountID: transfer.FromAccountID,
+				ToAccountID:   transfer.ToAccountID,
+				Amount:        formatMoney(transfer.Amount),
+				CreatedAt:     transfer.CreatedAt.Time,
+			}
+			if transfer.ReversalOf.Valid {
+				row.ReversalOf = &transfer.ReversalOf.Int64
+			}
+			export.Transfers = append(export.Transfers, row)
+		}
+		if len(transfers) < exportPageSize {
+			break
+		}
+		arg.AfterID = transfers[len(transfers)-1].ID
+	}
+
+	// The audit events are those the user made and those about the user or their accounts.
+	// Events someone else made, such as a freeze by staff, do not say who made them or from where.
+	events := make(map[int64]db.AuditLog)
+	filters := []db.ListAuditLogParams{
+		{Actor: pgtype.Text{String: username, Valid: true}},
+		{ResourceType: pgtype.Text{String: "user", Valid: true}, ResourceID: pgtype.Text{String: username, Valid: true}},
+	}
+	for _, id := range accountIDs {
+		filters = append(filters, db.ListAuditLogParams{
+			ResourceType: pgtype.Text{String: "account", Valid: true},
+			ResourceID:   pgtype.Text{String: id, Valid: true},
+		})
+	}
+	for _, arg := range filters {
+		if err := server.collectAuditLog(ctx, arg, events); err != nil {
+			return export, err
+		}
+	}
+
+	export.Sessions = []sessionExport{}
+	export.AuditEvents = []auditLogResponse{}
+	for _, id := range slices.Sorted(maps.Keys(events)) {
+		entry := events[id]
+		event := newAuditLogResponse(entry)
+		if entry.Actor.String != username {
+			event.Actor, event.APIKeyID, event.ClientIP = "", 0, ""
+		}
+		export.AuditEvents = append(export.AuditEvents, event)
+		if entry.Action == "login.succeeded" && entry.ResourceID == username {
+			export.Sessions = append(export.Sessions, sessionExport{
+				LoggedInAt: entry.CreatedAt.Time,
+				ClientIP:   entry.ClientIp.String,
+				RequestID:  entry.RequestID.String,
+			})
+		}
+	}
+	return export, nil
+}
+
+// appendEntries appends the ledger of an account to entries, oldest entry first.
+func (server *Server) appendEntries(ctx context.Context, entries []entryResponse, accountID int64) ([]entryResponse, error) {
+	arg := db.ListEntriesForAccountAfterParams{AccountID: accountID, Limit: exportPageSize}
+	for {
+		page, err := server.Store.ListEntriesForAccountAfter(ctx, arg)
+		if err != nil {
+			return entries, err
+		}
+		for _, entry := range page {
+			entries = append(entries, entryResponse{
+				ID:        entry.ID,
+				AccountID: entry.AccountID,
+				Amount:    formatMoney(entry.Amount),
+				CreatedAt: entry.CreatedAt.Time,
+			})
+		}
+		if len(page) < exportPageSize {
+			return entries, nil
+		}
+		arg.ID = page[len(page)-1].ID
+	}
+}
+
+// collectAuditLog adds every audit log entry that matches arg to events.
+func (server *Server) collectAuditLog(ctx context.Context, arg db.ListAuditLogParams, events map[int64]db.AuditLog) error {
+	arg.LimitCount = exportPageSize
+	for {
+		entries, err := server.Store.ListAuditLog(ctx, arg)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			events[entry.ID] = entry
+		}
+		if len(entries) < exportPageSize {
+			return nil
+		}
+		arg.AfterID = entries[len(entries)-1].ID
+	}
+}
+
+// writeExportZip writes every table of export to w as name.json and name.csv.
+func writeExportZip(w io.Writer, export userExport) error {
+	archive := zip.NewWriter(w)
+	for _, file := range export.files() {
+		jsonFile, err := archive.Create(file.name + ".json")
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(jsonFile)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.rows); err != nil {
+			return err
+		}
+
+		csvFile, err := archive.Create(file.name + ".csv")
+		if err != nil {
+			return err
+		}
+		if err := writeCSV(csvFile, file.rows); err != nil {
+			return fmt.Errorf("%s.csv: %w", file.name, err)
+		}
+	}
+	return archive.Close()
+}
+
+// writeCSV writes a slice of structs as CSV, with a column for each field named by its
+// JSON name, in the same order as the JSON export.
+func writeCSV(w io.Writer, rows any) error {
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot write %T as CSV", rows)
+	}
+
+	fields := csvFields(value.Type().Elem())
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for i := range value.Len() {
+		row := value.Index(i)
+		for j, field := range fields {
+			cell, err := csvCell(row.FieldByIndex(field.index))
+			if err != nil {
+				return fmt.Errorf("%s: %w", field.name, err)
+			}
+			record[j] = cell
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+// csvFields returns the exported fields of a struct that are in its JSON, embedded
+// structs flattened.
+func csvFields(t reflect.Type) []csvField {
+	fields := []csvField{}
+	for _, field := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" || (field.Anonymous && name == "") {
+			continue
+		}
+		fields = append(fields, csvField{name: cmp.Or(name, field.Name), index: field.Index})
+	}
+	return fields
+}
+
+// csvCell formats a value as it reads in the JSON export: times in RFC 3339, null as an
+// empty cell, and anything else that is not a plain value as its JSON.
+func csvCell(value reflect.Value) (string, error) {
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case pgtype.Timestamptz:
+		if !v.Valid {
+			return "", nil
+		}
+		return v.Time.Format(time.RFC3339Nano), nil
+	case json.RawMessage:
+		return string(v), nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	}
+
+	encoded, err := json.Marshal(value.Interface())
+	if err != nil {
+		return "", err
+	}
+	if string(encoded) == "null" {
+		return "", nil
+	}
+	return string(encoded), nil
+}
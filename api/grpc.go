@@ -0,0 +1,410 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	db "example.com/db/sqlc"
+	"example.com/pb"
+	"example.com/token"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcPublicMethods can be called without an access token.
+var grpcPublicMethods = map[string]bool{
+	pb.SimpleBank_CreateUser_FullMethodName: true,
+	pb.SimpleBank_LoginUser_FullMethodName:  true,
+}
+
+var errGRPCTOTPLogin = errors.New("two-factor authentication is enabled: log in with POST /v2/users/login and /v2/users/login/totp")
+
+type grpcPayloadKey struct{}
+
+// grpcServer implements pb.SimpleBankServer on top of the same store, token maker,
+// request validation and error mapping as the HTTP handlers.
+type grpcServer struct {
+	pb.UnimplementedSimpleBankServer
+	server *Server
+}
+
+// NewGRPCServer returns a gRPC server with the SimpleBank service and server reflection registered.
+func (server *Server) NewGRPCServer() *grpc.Server {
+	grpcSrv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcLoggingInterceptor(server.Logger),
+		grpcAuthInterceptor(server.TokenMaker, server.Store),
+	))
+
+	pb.RegisterSimpleBankServer(grpcSrv, &grpcServer{server: server})
+	reflection.Register(grpcSrv)
+
+	return grpcSrv
+}
+
+// grpcAuthInterceptor is the gRPC counterpart of authMiddleware: it reads a bearer token
+// or an API key from the metadata and stores its payload in the context, with the caller
+// as the actor for the audit log.
+func grpcAuthInterceptor(tokenMaker token.Maker, store db.Querier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		actor := db.AuditActor{Type: db.AuditActorAnonymous, ClientIP: grpcClientIP(ctx)}
+		ctx = db.WithAuditActor(ctx, actor)
+		if grpcPublicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		payload, err := grpcAuthenticate(ctx, tokenMaker, store)
+		if err != nil {
+			return nil, err
+		}
+
+		// A method missing from grpcMethodPermissions needs a permission no role holds.
+		if err := checkPermission(payload, grpcMethodPermissions[info.FullMethod]); err != nil {
+			return nil, grpcError(err)
+		}
+
+		if call, ok := ctx.Value(grpcCallKey{}).(*grpcCall); ok {
+			call.username = payload.Username
+		}
+		actor.Type, actor.Username, actor.APIKeyID = db.AuditActorUser, payload.Username, payload.APIKeyID
+		ctx = db.WithAuditActor(ctx, actor)
+		return handler(context.WithValue(ctx, grpcPayloadKey{}, payload), req)
+	}
+}
+
+// grpcAuthenticate checks the access token in the "authorization" metadata, or the API key
+// in the "x-api-key" metadata, and returns its payload.
+func grpcAuthenticate(ctx context.Context, tokenMaker token.Maker, store db.Querier) (*token.Payload, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(strings.ToLower(authorizationHeaderKey))
+
+	if apiKeys := md.Get(strings.ToLower(apiKeyHeaderKey)); len(apiKeys) > 0 {
+		if len(values) > 0 {
+			return nil, status.Error(codes.Unauthenticated, errBothCredentials.Error())
+		}
+		payload, err := authenticateAPIKey(ctx, store, apiKeys[0], grpcClientIP(ctx))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		return payload, nil
+	}
+
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is not provided")
+	}
+
+	fields := strings.Fields(values[0])
+	if len(fields) != 2 || strings.ToLower(fields[0]) != authorizationTypeBearer {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+
+	payload, err := tokenMaker.VerifyToken(fields[1])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err := checkTokenCurrent(ctx, store, payload); err != nil {
+		return nil, grpcError(err)
+	}
+	return payload, nil
+}
+
+func grpcAuthPayload(ctx context.Context) *token.Payload {
+	return ctx.Value(grpcPayloadKey{}).(*token.Payload)
+}
+
+// grpcMetadata returns the first value of the incoming metadata key, the gRPC counterpart
+// of a request header.
+func grpcMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(strings.ToLower(key)); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcClientIP returns the IP address of the caller, the gRPC counterpart of
+// gin.Context.ClientIP, or "" if it has none.
+func grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// validateRequest applies the request struct's binding tags, exactly as gin does for HTTP requests.
+func validateRequest(req any) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func (s *grpcServer) CreateUser(ctx context.Context, in *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	req := CreateUserRequest{
+		Username: in.GetUsername(),
+		FullName: in.GetFullName(),
+		Email:    in.GetEmail(),
+		Password: in.GetPassword(),
+	}
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	user, err := s.server.createUser(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateUserResponse{User: newPbUser(user)}, nil
+}
+
+func (s *grpcServer) LoginUser(ctx context.Context, in *pb.LoginUserRequest) (*pb.LoginUserResponse, error) {
+	req := getUserRequest{Username: in.GetUsername(), Password: in.GetPassword()}
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	user, err := s.server.authenticate(ctx, req.Username, req.Password, grpcClientIP(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	// The second step of a two-factor login is only served over HTTP.
+	if user.TotpEnabledAt.Valid {
+		return nil, status.Error(codes.FailedPrecondition, errGRPCTOTPLogin.Error())
+	}
+	if err := s.server.clearLoginFailures(ctx, user.Username); err != nil {
+		return nil, grpcError(err)
+	}
+
+	accessToken, payload, err := s.server.TokenMaker.CreateToken(user.Username, string(user.Role), s.server.Config.AccessTokenDuration)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	s.server.auditLogin(ctx, user)
+
+	return &pb.LoginUserResponse{
+		User:                 newPbUser(user),
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: timestamppb.New(payload.ExpiredAt),
+	}, nil
+}
+
+func (s *grpcServer) CreateAccount(ctx context.Context, in *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	req := createAccountRequest{
+		Owner:       grpcAuthPayload(ctx).Username,
+		Currency:    in.GetCurrency(),
+		AccountType: db.AccountType(in.GetAccountType()),
+	}
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+	if err := req.validate(); err != nil {
+		return nil, grpcError(err)
+	}
+
+	account, err := s.server.createAccount(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateAccountResponse{Account: newPbAccount(account)}, nil
+}
+
+func (s *grpcServer) GetAccount(ctx context.Context, in *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	account, err := s.ownedAccount(ctx, in.GetId(), permViewAnyAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetAccountResponse{Account: newPbAccount(account)}, nil
+}
+
+func (s *grpcServer) ListAccounts(ctx context.Context, in *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	req := listAccountsRequest{PageID: in.GetPageId(), PageSize: in.GetPageSize()}
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.server.listAccounts(ctx, grpcAuthPayload(ctx), req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	response := &pb.ListAccountsResponse{Accounts: make([]*pb.Account, len(accounts))}
+	for i, account := range accounts {
+		response.Accounts[i] = newPbAccount(account)
+	}
+	return response, nil
+}
+
+func (s *grpcServer) CreateTransfer(ctx context.Context, in *pb.CreateTransferRequest) (*pb.CreateTransferResponse, error) {
+	req := RequestParams{
+		FromAccountId: in.GetFromAccountId(),
+		ToAccountId:   in.GetToAccountId(),
+		Amount:        in.GetAmount(),
+		Currency:      in.GetCurrency(),
+	}
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	// gRPC has no signature to check, so keys that must sign their transfers use HTTP.
+	secret, err := s.server.signingSecret(ctx, grpcAuthPayload(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if secret != "" {
+		return nil, grpcError(errSignedRequestsOnly)
+	}
+
+	if _, err := s.ownedAccount(ctx, req.FromAccountId, permTransferAny); err != nil {
+		return nil, err
+	}
+
+	if s.server.isHighRiskTransfer(req.Amount) {
+		err := s.server.requireFreshTOTP(ctx, grpcAuthPayload(ctx).Username, grpcMetadata(ctx, totpCodeHeaderKey))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+	}
+
+	if !s.server.IsSameCurrency(req.ToAccountId, req.Currency) {
+		return nil, status.Error(codes.InvalidArgument, "currencies don't match")
+	}
+
+	result, err := s.server.Store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountId: req.FromAccountId,
+		ToAccountId:   req.ToAccountId,
+		Amount:        req.Amount,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateTransferResponse{
+		Transfer:    newPbTransfer(result.Transfer),
+		FromAccount: newPbAccount(result.FromAccount),
+		FromEntry:   newPbEntry(result.FromEntry),
+	}, nil
+}
+
+func (s *grpcServer) ListEntries(ctx context.Context, in *pb.ListEntriesRequest) (*pb.ListEntriesResponse, error) {
+	req := listAccountsRequest{PageID: in.GetPageId(), PageSize: in.GetPageSize()}
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	account, err := s.ownedAccount(ctx, in.GetAccountId(), permViewAnyAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.server.Store.ListEntriesForAccount(ctx, db.ListEntriesForAccountParams{
+		AccountID: account.ID,
+		Limit:     req.PageSize,
+		Offset:    (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	response := &pb.ListEntriesResponse{Entries: make([]*pb.Entry, len(entries))}
+	for i, entry := range entries {
+		response.Entries[i] = newPbEntry(entry)
+	}
+	return response, nil
+}
+
+// ownedAccount loads an account and checks that the caller owns it or holds anyPerm, like
+// GetAccount does over HTTP.
+func (s *grpcServer) ownedAccount(ctx context.Context, id int64, anyPerm permission) (db.Account, error) {
+	req := getAccountRequest{ID: id}
+	if err := validateRequest(&req); err != nil {
+		return db.Account{}, err
+	}
+
+	account, err := s.server.Store.GetAccount(ctx, req.ID)
+	if err != nil {
+		return db.Account{}, grpcError(err)
+	}
+
+	if err := checkOwner(account.Owner, grpcAuthPayload(ctx), anyPerm); err != nil {
+		return db.Account{}, grpcError(err)
+	}
+
+	return account, nil
+}
+
+func newPbUser(user db.User) *pb.User {
+	return &pb.User{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		PasswordChangedAt: pbTimestamp(user.PasswordChangedAt),
+		CreatedAt:         pbTimestamp(user.CraetedAt),
+	}
+}
+
+func newPbAccount(account db.Account) *pb.Account {
+	response := newAccountResponse(account)
+	return &pb.Account{
+		Id:               account.ID,
+		Owner:            account.Owner,
+		Balance:          numericString(account.Balance),
+		Currency:         account.Currency,
+		AccountType:      string(account.AccountType),
+		OverdraftLimit:   numericString(account.OverdraftLimit),
+		AvailableBalance: numericString(response.AvailableBalance),
+		CreatedAt:        pbTimestamp(account.CreatedAt),
+	}
+}
+
+func newPbTransfer(transfer db.Transfer) *pb.Transfer {
+	return &pb.Transfer{
+		Id:            transfer.ID,
+		FromAccountId: transfer.FromAccountID,
+		ToAccountId:   transfer.ToAccountID,
+		Amount:        numericString(transfer.Amount),
+		CreatedAt:     pbTimestamp(transfer.CreatedAt),
+	}
+}
+
+func newPbEntry(entry db.Entry) *pb.Entry {
+	return &pb.Entry{
+		Id:        entry.ID,
+		AccountId: entry.AccountID,
+		Amount:    numericString(entry.Amount),
+		CreatedAt: pbTimestamp(entry.CreatedAt),
+	}
+}
+
+func pbTimestamp(t pgtype.Timestamptz) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+// numericString formats a numeric as a plain decimal string; NULL becomes "".
+func numericString(n pgtype.Numeric) string {
+	value, err := n.Value()
+	if err != nil {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/pb"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient serves the server's gRPC API over an in-memory connection.
+func newTestGRPCClient(t *testing.T, server *Server) pb.SimpleBankClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	grpcSrv := server.NewGRPCServer()
+	go grpcSrv.Serve(listener)
+	t.Cleanup(grpcSrv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewSimpleBankClient(conn)
+}
+
+// grpcAuthContext authenticates the call as a customer.
+func grpcAuthContext(t *testing.T, server *Server, username string) context.Context {
+	return grpcRoleAuthContext(t, server, username, db.UserRoleCustomer)
+}
+
+func grpcRoleAuthContext(t *testing.T, server *Server, username string, role db.UserRole) context.Context {
+	accessToken, _, err := server.TokenMaker.CreateToken(username, string(role), time.Minute)
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+accessToken)
+}
+
+func TestGRPCLoginUser(t *testing.T) {
+	password := util.RandomString(8)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	user := db.User{Username: util.RandomOwner(), FullName: "Test User", Email: "test@example.com", PasswordHash: string(hashedPassword)}
+
+	testCases := []struct {
+		Name      string
+		Request   *pb.LoginUserRequest
+		BuildStub func(*mock.MockStore)
+		Check     func(*testing.T, *pb.LoginUserResponse, error)
+	}{
+		{
+			Name:    "OK",
+			Request: &pb.LoginUserRequest{Username: user.Username, Password: password},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+			},
+			Check: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				require.NoError(t, err)
+				require.NotEmpty(t, res.AccessToken)
+				require.Equal(t, user.Username, res.User.Username)
+			},
+		},
+		{
+			Name:    "Wrong Password",
+			Request: &pb.LoginUserRequest{Username: user.Username, Password: "wrong-password"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+			},
+			Check: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				require.Equal(t, codes.Unauthenticated, status.Code(err))
+			},
+		},
+		{
+			Name:    "Two-Factor Authentication",
+			Request: &pb.LoginUserRequest{Username: user.Username, Password: password},
+			BuildStub: func(ms *mock.MockStore) {
+				totpUser := user
+				totpUser.TotpEnabledAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				ms.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(totpUser, nil)
+			},
+			Check: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				require.Equal(t, codes.FailedPrecondition, status.Code(err))
+			},
+		},
+		{
+			Name:    "Unknown User",
+			Request: &pb.LoginUserRequest{Username: user.Username, Password: password},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(db.User{}, pgx.ErrNoRows)
+			},
+			Check: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				require.Equal(t, codes.Unauthenticated, status.Code(err))
+			},
+		},
+		{
+			Name:    "Missing Username",
+			Request: &pb.LoginUserRequest{Password: password},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			client := newTestGRPCClient(t, newTestServer(t, newTestConfig(), mockStore))
+			res, err := client.LoginUser(context.Background(), tc.Request)
+			tc.Check(t, res, err)
+		})
+	}
+}
+
+func TestGRPCGetAccount(t *testing.T) {
+	account := randomAccount()
+
+	testCases := []struct {
+		Name      string
+		Username  string
+		Role      db.UserRole
+		NoAuth    bool
+		BuildStub func(*mock.MockStore)
+		Check     func(*testing.T, *pb.GetAccountResponse, error)
+	}{
+		{
+			Name:     "OK",
+			Username: account.Owner,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			},
+			Check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, account.ID, res.Account.Id)
+				require.Equal(t, numericString(account.Balance), res.Account.Balance)
+			},
+		},
+		{
+			Name:     "Not Found",
+			Username: account.Owner,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+			},
+			Check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.Equal(t, codes.NotFound, status.Code(err))
+			},
+		},
+		{
+			Name:     "Someone Else's Account",
+			Username: account.Owner + "x",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			},
+			Check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.Equal(t, codes.PermissionDenied, status.Code(err))
+			},
+		},
+		{
+			Name:     "Admin",
+			Username: account.Owner + "x",
+			Role:     db.UserRoleAdmin,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			},
+			Check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, account.ID, res.Account.Id)
+			},
+		},
+		{
+			Name:     "Unknown Role",
+			Username: account.Owner,
+			Role:     "intern",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.Equal(t, codes.PermissionDenied, status.Code(err))
+			},
+		},
+		{
+			Name:   "No Authorization",
+			NoAuth: true,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, res *pb.GetAccountResponse, err error) {
+				require.Equal(t, codes.Unauthenticated, status.Code(err))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			client := newTestGRPCClient(t, server)
+
+			ctx := context.Background()
+			if !tc.NoAuth {
+				role := tc.Role
+				if role == "" {
+					role = db.UserRoleCustomer
+				}
+				ctx = grpcRoleAuthContext(t, server, tc.Username, role)
+			}
+
+			res, err := client.GetAccount(ctx, &pb.GetAccountRequest{Id: account.ID})
+			tc.Check(t, res, err)
+		})
+	}
+}
+
+func TestGRPCCreateTransfer(t *testing.T) {
+	fromAccount := createAccountWithId(1)
+	toAccount := createAccountWithId(2)
+	toAccount.Currency = fromAccount.Currency
+
+	testCases := []struct {
+		Name      string
+		Currency  string
+		Amount    int64 // 10 if zero
+		BuildStub func(*mock.MockStore)
+		Check     func(*testing.T, *pb.CreateTransferResponse, error)
+	}{
+		{
+			Name:     "OK",
+			Currency: fromAccount.Currency,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), fromAccount.ID).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), toAccount.ID).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), db.TransferTxParams{
+					FromAccountId: fromAccount.ID,
+					ToAccountId:   toAccount.ID,
+					Amount:        10,
+				}).Times(1).Return(db.TransferTxResult{Transfer: db.Transfer{ID: 7}}, nil)
+			},
+			Check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, int64(7), res.Transfer.Id)
+			},
+		},
+		{
+			Name:     "Insufficient Funds",
+			Currency: fromAccount.Currency,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), fromAccount.ID).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), toAccount.ID).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			Check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				require.Equal(t, codes.FailedPrecondition, status.Code(err))
+			},
+		},
+		{
+			Name:     "Internal Error",
+			Currency: fromAccount.Currency,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), fromAccount.ID).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), toAccount.ID).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, fmt.Errorf("database connection failed"))
+			},
+			Check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				require.Equal(t, codes.Internal, status.Code(err))
+			},
+		},
+		{
+			Name:     "Negative Amount",
+			Currency: fromAccount.Currency,
+			Amount:   -10,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		{
+			Name:     "Invalid Currency",
+			Currency: "XYZ",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, res *pb.CreateTransferResponse, err error) {
+				require.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			client := newTestGRPCClient(t, server)
+
+			res, err := client.CreateTransfer(grpcAuthContext(t, server, fromAccount.Owner), &pb.CreateTransferRequest{
+				FromAccountId: fromAccount.ID,
+				ToAccountId:   toAccount.ID,
+				Amount:        cmp.Or(tc.Amount, 10),
+				Currency:      tc.Currency,
+			})
+			tc.Check(t, res, err)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+
+	// readyTimeout bounds the readiness check, so a hung database fails the probe
+	// instead of making it time out.
+	readyTimeout = 2 * time.Second
+)
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Healthz reports that the process is up. It does not touch the database, so a database
+// outage takes the instance out of rotation without getting it restarted.
+func (server *Server) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the instance can serve traffic: the database answers and its
+// schema has been migrated to the version this build expects.
+func (server *Server) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	if err := server.Store.Ping(ctx); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, healthResponse{Status: "ready"})
+}
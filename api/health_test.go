@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHealthProbes(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Path          string
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "Live",
+			Path: healthzPath,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().Ping(gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rr.Code)
+				require.Equal(t, "ok", decodeBody(t, rr)["status"])
+			},
+		},
+		{
+			Name: "Ready",
+			Path: readyzPath,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rr.Code)
+				require.Equal(t, "ready", decodeBody(t, rr)["status"])
+			},
+		},
+		{
+			Name: "Schema Behind",
+			Path: readyzPath,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().Ping(gomock.Any()).Times(1).
+					Return(fmt.Errorf("%w: at version 4, want %d", db.ErrSchemaNotReady, db.SchemaVersion))
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+				body := decodeBody(t, rr)
+				require.Equal(t, "unavailable", body["status"])
+				require.Contains(t, body["error"], "at version 4")
+			},
+		},
+		{
+			Name: "Database Down",
+			Path: readyzPath,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().Ping(gomock.Any()).Times(1).Return(errors.New("connection refused"))
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, tc.Path, nil)
+			require.NoError(t, err)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestShutdownEndsStreams(t *testing.T) {
+	account := randomAccount()
+
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().GetAccount(gomock.Any(), account.ID).AnyTimes().Return(account, nil)
+	mockStore.EXPECT().GetLatestEntryIDForAccount(gomock.Any(), account.ID).AnyTimes().Return(int64(0), nil)
+	mockStore.EXPECT().ListEntriesForAccountAfter(gomock.Any(), gomock.Any()).AnyTimes().Return([]db.Entry{}, nil)
+
+	config := newTestConfig()
+	config.HTTPWriteTimeout = 100 * time.Millisecond
+	server := newTestServer(t, config, mockStore)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	started := make(chan error, 1)
+	go func() { started <- server.Start(addr) }()
+
+	var response *http.Response
+	require.Eventually(t, func() bool {
+		request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/v1/accounts/%d/stream", addr, account.ID), nil)
+		require.NoError(t, err)
+		addAuthorization(t, request, server.TokenMaker, account.Owner)
+		response, err = http.DefaultClient.Do(request)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	// The stream outlives the write timeout.
+	reader := bufio.NewReader(response.Body)
+	_, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	time.Sleep(2 * config.HTTPWriteTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	require.NoError(t, <-started)
+
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	db "example.com/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type upsertInterestProductRequest struct {
+	Currency    string `json:"currency" binding:"required,currency"`
+	AccountType string `json:"account_type" binding:"required,oneof=checking savings business"`
+	AnnualRate  string `json:"annual_rate" binding:"required,numeric"`
+	DayCount    string `json:"day_count" binding:"omitempty,oneof=actual_365 actual_360 actual_actual thirty_360"`
+}
+
+func (server *Server) UpsertInterestProduct(c *gin.Context) {
+	var req upsertInterestProductRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var rate pgtype.Numeric
+	if err := rate.Scan(req.AnnualRate); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+	if db.NumericToRat(rate).Sign() < 0 {
+		c.JSON(http.StatusBadRequest, errorResponse(c, errors.New("annual rate must not be negative")))
+		return
+	}
+
+	if req.DayCount == "" {
+		req.DayCount = string(db.DayCountConventionActual365)
+	}
+
+	product, err := server.Store.UpsertInterestProductTx(c, db.UpsertInterestProductParams{
+		Currency:    req.Currency,
+		AccountType: db.AccountType(req.AccountType),
+		AnnualRate:  rate,
+		DayCount:    db.DayCountConvention(req.DayCount),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func (server *Server) ListInterestProducts(c *gin.Context) {
+	products, err := server.Store.ListInterestProducts(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, products)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"example.com/logging"
+	"example.com/token"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+)
+
+// validRequestID limits propagated request IDs to something safe to log and echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID returns the caller's request ID if it is usable, or a new one.
+func requestID(presented string) string {
+	if validRequestID.MatchString(presented) {
+		return presented
+	}
+	return uuid.NewString()
+}
+
+// requestIDMiddleware propagates the caller's X-Request-ID, or generates one, and stores
+// it in both the gin context and the request context so that store calls can log it.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestID(c.GetHeader(requestIDHeader))
+
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// loggerMiddleware writes one structured line per request. The route is logged as its
+// template and the query string with sensitive parameters masked, so that credentials
+// and account numbers never reach the logs.
+func loggerMiddleware(logger *slog.Logger, redactor *logging.Redactor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if query := c.Request.URL.Query(); len(query) > 0 {
+			attrs = append(attrs, slog.String("query", redactor.Query(query)))
+		}
+		if payload, ok := c.Get(authorizationPayloadKey); ok {
+			payload := payload.(*token.Payload)
+			attrs = append(attrs, slog.String("user", payload.Username))
+			if payload.APIKeyID != 0 {
+				attrs = append(attrs, slog.Int64("api_key_id", payload.APIKeyID))
+			}
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+// grpcCall collects what inner interceptors learn about a call for its log line.
+type grpcCall struct {
+	username string
+}
+
+type grpcCallKey struct{}
+
+// grpcLoggingInterceptor assigns the request ID from the x-request-id metadata, or a new
+// one, and logs one structured line per call.
+func grpcLoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var presented string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDHeader); len(values) > 0 {
+				presented = values[0]
+			}
+		}
+		id := requestID(presented)
+		ctx = logging.WithRequestID(ctx, id)
+
+		call := &grpcCall{}
+		ctx = context.WithValue(ctx, grpcCallKey{}, call)
+		grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		attrs := []slog.Attr{
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if call.username != "" {
+			attrs = append(attrs, slog.String("user", call.username))
+		}
+
+		level := slog.LevelInfo
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(ctx, level, "grpc request", attrs...)
+
+		return resp, err
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/logging"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newLoggedTestServer returns a test server whose request logs go to the returned buffer.
+func newLoggedTestServer(t *testing.T, store db.Store) (*Server, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "debug", logging.NewRedactor(nil))
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return newTestServer(t, newTestConfig(), store), &buf
+}
+
+func TestRequestID(t *testing.T) {
+	account := randomAccount()
+
+	testCases := []struct {
+		Name      string
+		RequestID string
+		Check     func(t *testing.T, id string)
+	}{
+		{
+			Name:      "Propagated",
+			RequestID: "client-chosen-id",
+			Check: func(t *testing.T, id string) {
+				require.Equal(t, "client-chosen-id", id)
+			},
+		},
+		{
+			Name: "Generated",
+			Check: func(t *testing.T, id string) {
+				require.Len(t, id, 36)
+			},
+		},
+		{
+			Name:      "Unsafe Value Replaced",
+			RequestID: "bad id\nwith newline",
+			Check: func(t *testing.T, id string) {
+				require.Len(t, id, 36)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			mockStore.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+
+			server, logs := newLoggedTestServer(t, mockStore)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/accounts/%d", account.ID), nil)
+			require.NoError(t, err)
+			if tc.RequestID != "" {
+				request.Header.Set(requestIDHeader, tc.RequestID)
+			}
+			addAuthorization(t, request, server.TokenMaker, account.Owner)
+
+			server.Router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+
+			id := recorder.Header().Get(requestIDHeader)
+			tc.Check(t, id)
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			require.Equal(t, id, body["request_id"])
+
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+			require.Equal(t, id, line[logging.RequestIDAttr])
+			require.Equal(t, "/v1/accounts/:id", line["route"])
+			require.Equal(t, float64(http.StatusNotFound), line["status"])
+			require.Equal(t, account.Owner, line["user"])
+			require.Contains(t, line, "latency_ms")
+		})
+	}
+}
+
+func TestRequestLogRedactsCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().GetUser(gomock.Any(), "alice").Times(1).Return(db.User{}, pgx.ErrNoRows)
+
+	server, logs := newLoggedTestServer(t, mockStore)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/v1/users?username=alice&password=hunter2&email=alice@example.com", nil)
+	require.NoError(t, err)
+	server.Router.ServeHTTP(recorder, request)
+
+	require.NotContains(t, logs.String(), "hunter2")
+	require.NotContains(t, logs.String(), "alice@example.com")
+	require.True(t, strings.Contains(logs.String(), "username=alice"))
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/token"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+const testTOTPKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func newTestConfig() util.Config {
+	return util.Config{
+		TokenSymmetricKey:   util.RandomString(32),
+		AccessTokenDuration: time.Minute,
+
+		TOTPEncryptionKey:      testTOTPKey,
+		TOTPIssuer:             "Simple Bank",
+		TOTPChallengeDuration:  time.Minute,
+		HighRiskTransferAmount: 1000,
+
+		PasswordMinLength:          8,
+		PasswordHistory:            3,
+		PasswordResetTokenDuration: time.Minute,
+
+		LoginFailureWindow:   time.Hour,
+		LoginBackoffAfter:    3,
+		LoginIPBackoffAfter:  20,
+		LoginBackoffBase:     time.Hour,
+		LoginBackoffMax:      time.Hour,
+		LoginLockoutAfter:    10,
+		LoginLockoutDuration: time.Hour,
+
+		EmailVerificationDuration: time.Hour,
+		SignatureWindow:           5 * time.Minute,
+
+		DeprecatedRoutesSunset: time.Now().AddDate(1, 0, 0).Format(time.DateOnly),
+	}
+}
+
+// newTestServer returns a server on store. With a mock store, every user's password is
+// taken never to have changed and no login ever to have failed, so that the tests need not
+// expect the lookup authMiddleware makes for each token, the bookkeeping of logins, the
+// verification code sent to a new user or the events recorded in the audit log.
+func newTestServer(t *testing.T, config util.Config, store db.Store) *Server {
+	if mockStore, ok := store.(*mock.MockStore); ok {
+		mockStore.EXPECT().GetUserTokensValidFrom(gomock.Any(), gomock.Any()).AnyTimes().Return(pgtype.Timestamptz{}, nil)
+		mockStore.EXPECT().GetLoginFailure(gomock.Any(), gomock.Any()).AnyTimes().Return(db.LoginFailure{}, pgx.ErrNoRows)
+		mockStore.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any()).AnyTimes().Return(db.LoginFailure{}, nil)
+		mockStore.EXPECT().DeleteLoginFailure(gomock.Any(), gomock.Any()).AnyTimes().Return(int64(0), nil)
+		mockStore.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).AnyTimes().Return(db.VerifyEmail{ID: 1}, nil)
+		mockStore.EXPECT().CreateAuditLog(gomock.Any(), gomock.Any()).AnyTimes().Return(db.AuditLog{}, nil)
+	}
+
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	return server
+}
+
+// addAuthorization authorizes the request as a customer.
+func addAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, username string) {
+	addRoleAuthorization(t, request, tokenMaker, username, db.UserRoleCustomer)
+}
+
+func addRoleAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, username string, role db.UserRole) {
+	accessToken, _, err := tokenMaker.CreateToken(username, string(role), time.Minute)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("Bearer %s", accessToken))
+}
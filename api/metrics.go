@@ -0,0 +1,28 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"example.com/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+const metricsPath = "/metrics"
+
+// metricsMiddleware counts requests and observes their latency per route template, so
+// that paths with IDs do not blow up the number of series.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
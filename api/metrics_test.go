@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	account := randomAccount()
+
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+
+	server := newTestServer(t, newTestConfig(), mockStore)
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/accounts/%d", account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.TokenMaker, account.Owner)
+	server.Router.ServeHTTP(httptest.NewRecorder(), request)
+
+	recorder := httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, metricsPath, nil)
+	require.NoError(t, err)
+	server.Router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	require.Contains(t, body, `simplebank_http_requests_total{method="GET",route="/v1/accounts/:id",status="404"}`)
+	require.Contains(t, body, `simplebank_http_request_duration_seconds_bucket{method="GET",route="/v1/accounts/:id"`)
+	require.NotContains(t, body, fmt.Sprintf("/v1/accounts/%d", account.ID))
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	db "example.com/db/sqlc"
+	"example.com/token"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	authorizationHeaderKey  = "Authorization"
+	authorizationTypeBearer = "bearer"
+	authorizationPayloadKey = "authorization_payload"
+)
+
+var errTokenRevoked = errors.New("token was issued before the password or role was last changed")
+
+// authMiddleware requires a valid access token or API key and stores its payload in the
+// context.
+func authMiddleware(tokenMaker token.Maker, store db.Querier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader(apiKeyHeaderKey); apiKey != "" {
+			if c.GetHeader(authorizationHeaderKey) != "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(c, errBothCredentials))
+				return
+			}
+
+			payload, err := authenticateAPIKey(c, store, apiKey, c.ClientIP())
+			if err != nil {
+				c.AbortWithStatusJSON(errorStatus(err), errorResponse(c, err))
+				return
+			}
+
+			setAuthPayload(c, payload)
+			c.Next()
+			return
+		}
+
+		accessToken, err := bearerToken(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(c, err))
+			return
+		}
+
+		payload, err := tokenMaker.VerifyToken(accessToken)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(c, err))
+			return
+		}
+
+		if err := checkTokenCurrent(c, store, payload); err != nil {
+			c.AbortWithStatusJSON(errorStatus(err), errorResponse(c, err))
+			return
+		}
+
+		setAuthPayload(c, payload)
+		c.Next()
+	}
+}
+
+// setAuthPayload stores the payload of the caller, who from then on is the actor of the
+// changes the request makes.
+func setAuthPayload(c *gin.Context, payload *token.Payload) {
+	c.Set(authorizationPayloadKey, payload)
+	setAuditActor(c, db.AuditActor{
+		Type:     db.AuditActorUser,
+		Username: payload.Username,
+		APIKeyID: payload.APIKeyID,
+		ClientIP: c.ClientIP(),
+	})
+}
+
+// checkTokenCurrent rejects the tokens issued before the user last changed their password,
+// which ends all of their sessions, or their role, which the tokens carry, and the tokens
+// of users who no longer exist.
+func checkTokenCurrent(ctx context.Context, store db.Querier, payload *token.Payload) error {
+	changedAt, err := store.GetUserTokensValidFrom(ctx, payload.Username)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return errTokenRevoked
+	case err != nil:
+		return err
+	case changedAt.Valid && payload.IssuedAt.Before(changedAt.Time):
+		return errTokenRevoked
+	}
+	return nil
+}
+
+func authPayload(c *gin.Context) *token.Payload {
+	return c.MustGet(authorizationPayloadKey).(*token.Payload)
+}
+
+func bearerToken(c *gin.Context) (string, error) {
+	header := c.GetHeader(authorizationHeaderKey)
+	if header == "" {
+		return "", errors.New("authorization header is not provided")
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return "", errors.New("invalid authorization header format")
+	}
+
+	if strings.ToLower(fields[0]) != authorizationTypeBearer {
+		return "", errors.New("unsupported authorization type")
+	}
+
+	return fields[1], nil
+}
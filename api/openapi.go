@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/swaggest/swgui/v5emb"
+)
+
+const (
+	openAPIPath  = "/openapi.json"
+	swaggerUIDir = "/docs"
+
+	bearerAuth = "bearerAuth"
+	apiKeyAuth = "apiKeyAuth"
+)
+
+// errorBody documents the JSON written by errorResponse.
+type errorBody struct {
+	Error     string `json:"Error"`
+	RequestID string `json:"request_id"`
+}
+
+// apiRoutes documents every route registered in NewServer for one API version, except the
+// Swagger UI and the legacy unversioned aliases of v1. TestOpenAPIDocumentsEveryRoute fails
+// when a route is missing here.
+func apiRoutes(version apiVersion) []openapi.Route {
+	var account any = accountResponse{}
+	var accounts any = []accountResponse{}
+	if version >= apiV2 {
+		account = accountResponseV2{}
+		accounts = []accountResponseV2{}
+	}
+
+	routes := []openapi.Route{
+		{Method: http.MethodPost, Path: "/users", Summary: "Create a user", Tags: []string{"users"},
+			Body: CreateUserRequest{}, Status: http.StatusCreated, Response: userResponse{}},
+
+		{Method: http.MethodPatch, Path: "/users/:username/role", Summary: "Change a user's role (admins)", Tags: []string{"users"}, Security: bearerAuth,
+			URI: usernameRequest{}, Body: updateUserRoleRequest{}, Status: http.StatusOK, Response: userResponse{}},
+		{Method: http.MethodDelete, Path: "/users/:username/lockout", Summary: "Unlock a user locked out after too many failed logins (admins)", Tags: []string{"users"}, Security: bearerAuth,
+			URI: usernameRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/users/password/forgot", Summary: "Send a password reset token to the user with an email address", Tags: []string{"users"},
+			Body: forgotPasswordRequest{}, Status: http.StatusAccepted, Response: forgotPasswordResponse{}},
+		{Method: http.MethodPost, Path: "/users/password/reset", Summary: "Set a new password with a reset token (X-TOTP-Code header under two-factor authentication)", Tags: []string{"users"},
+			Body: resetPasswordRequest{}, Status: http.StatusOK, Response: userResponse{}},
+		{Method: http.MethodPost, Path: "/users/email/verify", Summary: "Verify an email address with the code sent to it", Tags: []string{"users"},
+			Body: verifyEmailRequest{}, Status: http.StatusOK, Response: userResponse{}},
+		{Method: http.MethodGet, Path: "/users/me", Summary: "Get your profile", Tags: []string{"users"}, Security: bearerAuth,
+			Status: http.StatusAccepted, Response: userResponse{}},
+		{Method: http.MethodPatch, Path: "/users/me", Summary: "Change your full name or email address (X-TOTP-Code header under two-factor authentication to change the address)", Tags: []string{"users"}, Security: bearerAuth,
+			Body: updateCurrentUserRequest{}, Status: http.StatusOK, Response: userResponse{}},
+		{Method: http.MethodPost, Path: "/users/me/email/verification", Summary: "Send a new code to verify your email address", Tags: []string{"users"}, Security: bearerAuth,
+			Status: http.StatusAccepted, Response: emailVerificationSentResponse{}},
+		{Method: http.MethodPost, Path: "/users/me/api_keys", Summary: "Create an API key, shown only in this response with its signing secret, if any (X-TOTP-Code header under two-factor authentication)", Tags: []string{"users"}, Security: bearerAuth,
+			Body: createAPIKeyRequest{}, Status: http.StatusCreated, Response: createAPIKeyResponse{}},
+		{Method: http.MethodGet, Path: "/users/me/api_keys", Summary: "List your API keys", Tags: []string{"users"}, Security: bearerAuth,
+			Status: http.StatusAccepted, Response: []apiKeyResponse{}},
+		{Method: http.MethodDelete, Path: "/users/me/api_keys/:id", Summary: "Revoke one of your API keys", Tags: []string{"users"}, Security: bearerAuth,
+			URI: apiKeyIDRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodPut, Path: "/users/me/password", Summary: "Change your password and end your other sessions (X-TOTP-Code header under two-factor authentication)", Tags: []string{"users"}, Security: bearerAuth,
+			Body: changePasswordRequest{}, Status: http.StatusAccepted, Response: loginUserResponse{}},
+		{Method: http.MethodPost, Path: "/users/me/totp", Summary: "Enroll in two-factor authentication (X-TOTP-Code header to re-enroll)", Tags: []string{"users"}, Security: bearerAuth,
+			Status: http.StatusOK, Response: enrollTOTPResponse{}},
+		{Method: http.MethodPost, Path: "/users/me/totp/confirm", Summary: "Turn on two-factor authentication and get backup codes", Tags: []string{"users"}, Security: bearerAuth,
+			Body: totpCodeRequest{}, Status: http.StatusOK, Response: confirmTOTPResponse{}},
+		{Method: http.MethodGet, Path: "/users/me/export", Summary: "Export your profile, accounts, entries, transfers, logins and audit events as a ZIP of JSON and CSV files (X-TOTP-Code header under two-factor authentication)", Tags: []string{"users"}, Security: bearerAuth,
+			Status: http.StatusOK, Response: "", ContentType: zipContentType},
+		{Method: http.MethodPost, Path: "/users/me/data_requests", Summary: "Ask for your personal data to be erased once all your accounts are closed (X-TOTP-Code header under two-factor authentication)", Tags: []string{"users"}, Security: bearerAuth,
+			Body: createDataRequestRequest{}, Status: http.StatusCreated, Response: dataRequestResponse{}},
+		{Method: http.MethodGet, Path: "/users/me/data_requests", Summary: "List your data requests", Tags: []string{"users"}, Security: bearerAuth,
+			Status: http.StatusAccepted, Response: []dataRequestResponse{}},
+		{Method: http.MethodGet, Path: "/data_requests", Summary: "List the data requests of every user, oldest first (admins)", Tags: []string{"users"}, Security: bearerAuth,
+			Query: listDataRequestsRequest{}, Status: http.StatusAccepted, Response: []dataRequestResponse{}},
+		{Method: http.MethodPatch, Path: "/data_requests/:id", Summary: "Approve, which carries it out, or reject a pending data request (admins)", Tags: []string{"users"}, Security: bearerAuth,
+			URI: dataRequestIDRequest{}, Body: reviewDataRequestRequest{}, Status: http.StatusOK, Response: dataRequestResponse{}},
+
+		{Method: http.MethodPost, Path: "/accounts", Summary: "Open an account for yourself, or for a customer (tellers and admins), once the owner's email address is verified", Tags: []string{"accounts"}, Security: bearerAuth,
+			Body: createAccountRequest{}, Status: http.StatusCreated, Response: account},
+		{Method: http.MethodGet, Path: "/accounts", Summary: "List your accounts, or every account (admins)", Tags: []string{"accounts"}, Security: bearerAuth,
+			Query: listAccountsRequest{}, Status: http.StatusAccepted, Response: accounts},
+		{Method: http.MethodGet, Path: "/accounts/:id", Summary: "Get one of your accounts", Tags: []string{"accounts"}, Security: bearerAuth,
+			URI: getAccountRequest{}, Status: http.StatusAccepted, Response: account},
+		{Method: http.MethodGet, Path: "/accounts/:id/entries", Summary: "List an account's ledger entries", Tags: []string{"accounts"}, Security: bearerAuth,
+			URI: getAccountRequest{}, Query: listAccountsRequest{}, Status: http.StatusAccepted, Response: []entryResponse{}},
+		{Method: http.MethodPatch, Path: "/accounts/:id/freeze", Summary: "Freeze or unfreeze an account (admins)", Tags: []string{"accounts"}, Security: bearerAuth,
+			URI: getAccountRequest{}, Body: freezeAccountRequest{}, Status: http.StatusOK, Response: account},
+		{Method: http.MethodPatch, Path: "/accounts/:id/close", Summary: "Close one of your accounts, or any account (admins), once its balance is zero", Tags: []string{"accounts"}, Security: bearerAuth,
+			URI: getAccountRequest{}, Status: http.StatusOK, Response: account},
+		{Method: http.MethodGet, Path: "/accounts/:id/stream", Summary: "Stream entries and balance as server-sent events", Tags: []string{"accounts"}, Security: bearerAuth,
+			URI: getAccountRequest{}, Status: http.StatusOK, Response: "", ContentType: "text/event-stream"},
+
+		{Method: http.MethodPost, Path: "/transfers", Summary: "Transfer money out of your account, or any account (tellers and admins); large transfers need an X-TOTP-Code header under two-factor authentication, and API keys with a signing secret need a Signature header", Tags: []string{"transfers"}, Security: bearerAuth,
+			Body: RequestParams{}, Status: http.StatusCreated, Response: transferResponse{}},
+
+		{Method: http.MethodPost, Path: "/webhooks", Summary: "Subscribe to webhook events (X-TOTP-Code header under two-factor authentication)", Tags: []string{"webhooks"}, Security: bearerAuth,
+			Body: createWebhookSubscriptionRequest{}, Status: http.StatusCreated, Response: createWebhookSubscriptionResponse{}},
+		{Method: http.MethodGet, Path: "/webhooks", Summary: "List your webhook subscriptions", Tags: []string{"webhooks"}, Security: bearerAuth,
+			Status: http.StatusAccepted, Response: []webhookSubscriptionResponse{}},
+		{Method: http.MethodDelete, Path: "/webhooks/:id", Summary: "Delete a webhook subscription", Tags: []string{"webhooks"}, Security: bearerAuth,
+			URI: webhookIDRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Summary: "List a subscription's deliveries", Tags: []string{"webhooks"}, Security: bearerAuth,
+			URI: webhookIDRequest{}, Query: listWebhookDeliveriesRequest{}, Status: http.StatusAccepted, Response: []db.WebhookDelivery{}},
+		{Method: http.MethodPost, Path: "/webhooks/deliveries/:id/replay", Summary: "Queue a delivery again", Tags: []string{"webhooks"}, Security: bearerAuth,
+			URI: webhookIDRequest{}, Status: http.StatusAccepted, Response: db.WebhookDelivery{}},
+
+		{Method: http.MethodGet, Path: "/audit_log", Summary: "List the audit log, oldest first (admins and auditors)", Tags: []string{"audit"}, Security: bearerAuth,
+			Query: listAuditLogRequest{}, Status: http.StatusAccepted, Response: []auditLogResponse{}},
+		{Method: http.MethodGet, Path: "/audit_log/export", Summary: "Export the audit log as newline-delimited JSON (admins and auditors)", Tags: []string{"audit"}, Security: bearerAuth,
+			Query: auditLogFilter{}, Status: http.StatusOK, Response: "", ContentType: ndjsonContentType},
+
+		{Method: http.MethodPatch, Path: "/admin/accounts/:id/overdraft", Summary: "Set an account's overdraft limit (admins)", Tags: []string{"admin"}, Security: bearerAuth,
+			URI: getAccountRequest{}, Body: updateOverdraftLimitRequest{}, Status: http.StatusOK, Response: account},
+		{Method: http.MethodPut, Path: "/admin/interest_products", Summary: "Create or update an interest product (admins)", Tags: []string{"admin"}, Security: bearerAuth,
+			Body: upsertInterestProductRequest{}, Status: http.StatusOK, Response: db.InterestProduct{}},
+		{Method: http.MethodGet, Path: "/admin/interest_products", Summary: "List interest products (admins)", Tags: []string{"admin"}, Security: bearerAuth,
+			Status: http.StatusAccepted, Response: []db.InterestProduct{}},
+	}
+
+	if version >= apiV2 {
+		routes = append(routes,
+			openapi.Route{Method: http.MethodPost, Path: "/users/login", Summary: "Log in and get an access token, or a TOTP challenge under two-factor authentication", Tags: []string{"users"},
+				Body: getUserRequest{}, Status: http.StatusAccepted, Response: loginUserResponse{}},
+			openapi.Route{Method: http.MethodPost, Path: "/users/login/totp", Summary: "Answer a TOTP challenge with a code or backup code and get an access token", Tags: []string{"users"},
+				Body: loginTOTPRequest{}, Status: http.StatusAccepted, Response: loginUserResponse{}},
+		)
+	} else {
+		routes = append(routes, openapi.Route{Method: http.MethodGet, Path: "/users", Summary: "Log in and get an access token (deprecated, use POST /v2/users/login)", Tags: []string{"users"},
+			Query: getUserRequest{}, Status: http.StatusAccepted, Response: loginUserResponse{}})
+	}
+
+	for i := range routes {
+		routes[i].Path = version.prefix() + routes[i].Path
+	}
+	return routes
+}
+
+// newOpenAPIDocument generates the API description from the request and response types.
+func newOpenAPIDocument() *openapi.Document {
+	g := openapi.New("Simple Bank", "1.0.0")
+
+	g.Type(pgtype.Numeric{}, openapi.Schema{Type: []string{"number", "null"}})
+	g.Type(pgtype.Timestamptz{}, openapi.Schema{Type: []string{"string", "null"}, Format: "date-time"})
+	g.Type(pgtype.Date{}, openapi.Schema{Type: []string{"string", "null"}, Format: "date"})
+	g.Type(pgtype.Int4{}, openapi.Schema{Type: []string{"integer", "null"}, Format: "int32"})
+	g.Type(pgtype.Text{}, openapi.Schema{Type: []string{"string", "null"}})
+	g.Type(json.RawMessage{}, openapi.Schema{Type: "object"})
+
+	g.Enum(db.AccountType(""), enumValues(db.AllAccountTypeValues())...)
+	g.Enum(db.DayCountConvention(""), enumValues(db.AllDayCountConventionValues())...)
+	g.Enum(db.WebhookDeliveryStatus(""), enumValues(db.AllWebhookDeliveryStatusValues())...)
+	g.Enum(db.UserRole(""), enumValues(db.AllUserRoleValues())...)
+	g.Enum(db.DataRequestKind(""), enumValues(db.AllDataRequestKindValues())...)
+	g.Enum(db.DataRequestStatus(""), enumValues(db.AllDataRequestStatusValues())...)
+
+	g.Validation("currency", openapi.Schema{Enum: enumValues(util.Currencies)})
+	g.Validation("webhook_event", openapi.Schema{Enum: enumValues(db.EventTypes)})
+	g.Validation("webhook_url", openapi.Schema{Format: "uri", Pattern: "^https://"})
+	g.Validation("api_key_scope", openapi.Schema{Enum: enumValues(slices.Sorted(maps.Keys(apiKeyScopes)))})
+
+	g.ErrorResponse(errorBody{})
+	g.SecurityScheme(bearerAuth, openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+	g.SecurityScheme(apiKeyAuth, openapi.SecurityScheme{Type: "apiKey", In: "header", Name: apiKeyHeaderKey, Description: "An API key, limited to its scopes. Keys created with require_signature sign their transfers in a Signature header: t=<unix time>,nonce=<nonce>,v1=<hex HMAC-SHA256 of the method, path, time, nonce and body SHA-256, one per line>"})
+	g.AlternativeSecurity(bearerAuth, apiKeyAuth)
+
+	for _, version := range apiVersions {
+		for _, route := range apiRoutes(version) {
+			g.Add(route)
+		}
+	}
+	g.Add(openapi.Route{Method: http.MethodGet, Path: openAPIPath, Summary: "This document", Tags: []string{"docs"},
+		Status: http.StatusOK, Response: map[string]any{}})
+	g.Add(openapi.Route{Method: http.MethodGet, Path: metricsPath, Summary: "Prometheus metrics", Tags: []string{"operations"},
+		Status: http.StatusOK, Response: "", ContentType: "text/plain"})
+	g.Add(openapi.Route{Method: http.MethodGet, Path: healthzPath, Summary: "Liveness probe", Tags: []string{"operations"},
+		Status: http.StatusOK, Response: healthResponse{}})
+	g.Add(openapi.Route{Method: http.MethodGet, Path: readyzPath, Summary: "Readiness probe: the database answers and is migrated", Tags: []string{"operations"},
+		Status: http.StatusOK, Response: healthResponse{}})
+
+	return g.Document()
+}
+
+func enumValues[T ~string](values []T) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = string(value)
+	}
+	return result
+}
+
+// registerDocs serves the OpenAPI document and a Swagger UI that renders it.
+func (server *Server) registerDocs() {
+	document := newOpenAPIDocument()
+	server.Router.GET(openAPIPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	})
+
+	swaggerUI := v5emb.New("Simple Bank", openAPIPath, swaggerUIDir+"/")
+	server.Router.GET(swaggerUIDir+"/*any", gin.WrapH(swaggerUI))
+}
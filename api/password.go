@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/notify"
+	"example.com/password"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	errWrongPassword      = errors.New("old password is incorrect")
+	errInvalidResetToken  = errors.New("reset token is invalid or has expired")
+	errPasswordPolicyFail = errors.New("password does not meet the policy")
+)
+
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword replaces the caller's password. Every access token issued before stops
+// working, so the response carries a new one.
+func (server *Server) ChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	// The old password is checked like a login, so that a stolen access token cannot be
+	// used to guess it faster than the login throttle allows.
+	user, err := server.authenticate(c, authPayload(c).Username, req.OldPassword, c.ClientIP())
+	if errors.Is(err, errInvalidCredentials) {
+		err = errWrongPassword
+	}
+	if err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	if err := server.requireFreshTOTP(c, user.Username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	user, err = server.setPassword(c, user, req.NewPassword, "")
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	server.respondWithAccessToken(c, user)
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type forgotPasswordResponse struct {
+	Message string `json:"message"`
+}
+
+var resetTokenSent = forgotPasswordResponse{Message: "if the address belongs to a user, a reset token has been sent to it"}
+
+// ForgotPassword sends a reset token to the user with the email address. It answers the
+// same, and as fast, whether or not there is one, so that it does not tell who has an
+// account: the user is looked up and the token sent in the background. Requests are
+// throttled per address and per client IP; see throttlePasswordReset.
+func (server *Server) ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	if err := server.throttlePasswordReset(c, req.Email, c.ClientIP()); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	server.background.Add(1)
+	go func() {
+		defer server.background.Done()
+		server.sendResetToken(ctx, req.Email)
+	}()
+
+	c.JSON(http.StatusAccepted, resetTokenSent)
+}
+
+// sendResetToken creates a reset token for the user with the email address, if there is
+// one, and sends it to them. The caller has had its answer, so errors are only logged.
+func (server *Server) sendResetToken(ctx context.Context, email string) {
+	user, err := server.Store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: email})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		server.Logger.ErrorContext(ctx, "cannot look up user for password reset", "error", err)
+		return
+	}
+
+	token, hash := password.NewResetToken()
+	duration := server.Config.PasswordResetTokenDuration
+	_, err = server.Store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		TokenHash: hash,
+		Username:  user.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(duration), Valid: true},
+	})
+	if err != nil {
+		server.Logger.ErrorContext(ctx, "cannot create password reset token", "user", user.Username, "error", err)
+		return
+	}
+
+	err = server.Notifier.Notify(ctx, notify.Message{
+		Username: user.Username,
+		To:       user.Email,
+		Subject:  "Reset your Simple Bank password",
+		Body: fmt.Sprintf("Your password reset token is %s. It can be used once in the next %s.\n"+
+			"If you did not ask to reset your password, ignore this message.", token, duration),
+	})
+	if err != nil {
+		server.Logger.ErrorContext(ctx, "cannot send password reset token", "user", user.Username, "error", err)
+	}
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. Users enrolled in
+// two-factor authentication also need a fresh TOTP code.
+func (server *Server) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	hash := password.HashResetToken(req.Token)
+	resetToken, err := server.Store.GetPasswordResetToken(c, hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errInvalidResetToken
+		}
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	user, err := server.Store.GetUser(c, resetToken.Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	if err := server.requireFreshTOTP(c, user.Username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	user, err = server.setPassword(c, user, req.NewPassword, hash)
+	if err != nil {
+		// Another reset used the token first.
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errInvalidResetToken
+		}
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// setPassword checks a new password against the policy and the user's recent passwords
+// and stores it, using up resetTokenHash if it is set.
+func (server *Server) setPassword(ctx context.Context, user db.User, newPassword, resetTokenHash string) (db.User, error) {
+	if err := server.passwordPolicy.Check(newPassword); err != nil {
+		return db.User{}, invalidArgument(fmt.Errorf("%w: %w", errPasswordPolicyFail, err))
+	}
+
+	if history := server.passwordPolicy.History; history > 0 {
+		hashes := []string{user.PasswordHash}
+		if history > 1 {
+			previous, err := server.Store.ListPasswordHistory(ctx, db.ListPasswordHistoryParams{
+				Username: user.Username,
+				Limit:    int32(history - 1),
+			})
+			if err != nil {
+				return db.User{}, err
+			}
+			for _, entry := range previous {
+				hashes = append(hashes, entry.PasswordHash)
+			}
+		}
+		if err := server.passwordPolicy.CheckReuse(newPassword, hashes); err != nil {
+			return db.User{}, invalidArgument(fmt.Errorf("%w: %w", errPasswordPolicyFail, err))
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	return server.Store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{
+		Username:       user.Username,
+		PasswordHash:   string(hash),
+		ChangedAt:      time.Now(),
+		ResetTokenHash: resetTokenHash,
+	})
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/db/memstore"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/notify"
+	"example.com/totp"
+	"github.com/stretchr/testify/require"
+)
+
+// passwordTestServer serves a memory store and records the notifications it sends.
+type passwordTestServer struct {
+	t        *testing.T
+	server   *Server
+	store    *memstore.Store
+	notifier *notify.Recorder
+}
+
+func newPasswordTestServer(t *testing.T) *passwordTestServer {
+	store := memstore.New()
+	server := newTestServer(t, newTestConfig(), store)
+	notifier := &notify.Recorder{}
+	server.Notifier = notifier
+	return &passwordTestServer{t: t, server: server, store: store, notifier: notifier}
+}
+
+func (s *passwordTestServer) call(method, path string, body any, headers map[string]string, response any) int {
+	data, err := json.Marshal(body)
+	require.NoError(s.t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(method, path, bytes.NewReader(data))
+	require.NoError(s.t, err)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	s.server.Router.ServeHTTP(recorder, request)
+
+	if response != nil && recorder.Code < 300 {
+		require.NoError(s.t, json.Unmarshal(recorder.Body.Bytes(), response))
+	}
+	return recorder.Code
+}
+
+func (s *passwordTestServer) login(username, password string) (int, string) {
+	var response loginUserResponse
+	code := s.call(http.MethodPost, "/v2/users/login", getUserRequest{Username: username, Password: password}, nil, &response)
+	return code, response.AccessToken
+}
+
+func bearer(accessToken string) map[string]string {
+	return map[string]string{authorizationHeaderKey: "Bearer " + accessToken}
+}
+
+func TestChangePassword(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+
+	require.Equal(t, http.StatusBadRequest, s.call(http.MethodPost, "/v2/users",
+		CreateUserRequest{Username: username, FullName: "Test User", Email: "test@example.com", Password: "short"}, nil, nil))
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/users",
+		CreateUserRequest{Username: username, FullName: "Test User", Email: "test@example.com", Password: "first password"}, nil, nil))
+
+	code, oldToken := s.login(username, "first password")
+	require.Equal(t, http.StatusAccepted, code)
+
+	change := func(accessToken, oldPassword, newPassword string) (int, loginUserResponse) {
+		var response loginUserResponse
+		code := s.call(http.MethodPut, "/v2/users/me/password",
+			changePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword}, bearer(accessToken), &response)
+		return code, response
+	}
+
+	code, _ = change(oldToken, "wrong password", "second password")
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, _ = change(oldToken, "first password", "short")
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = change(oldToken, "first password", "first password")
+	require.Equal(t, http.StatusBadRequest, code, "current password")
+
+	code, changed := change(oldToken, "first password", "second password")
+	require.Equal(t, http.StatusAccepted, code)
+	require.NotEmpty(t, changed.AccessToken)
+	require.True(t, changed.User.PasswordChangedAt.Valid)
+
+	// Changing the password ends the sessions from before, but not the new one.
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodGet, "/v2/accounts?page_id=1&page_size=5", nil, bearer(oldToken), nil))
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodGet, "/v2/accounts?page_id=1&page_size=5", nil, bearer(changed.AccessToken), nil))
+
+	code, _ = s.login(username, "first password")
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	// The policy remembers the last three passwords, the current one included.
+	code, changed = change(changed.AccessToken, "second password", "third password")
+	require.Equal(t, http.StatusAccepted, code)
+	code, _ = change(changed.AccessToken, "third password", "first password")
+	require.Equal(t, http.StatusBadRequest, code, "password from the history")
+	code, changed = change(changed.AccessToken, "third password", "fourth password")
+	require.Equal(t, http.StatusAccepted, code)
+	code, _ = change(changed.AccessToken, "fourth password", "first password")
+	require.Equal(t, http.StatusAccepted, code, "password older than the history")
+}
+
+func TestChangePasswordTOTP(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/users",
+		CreateUserRequest{Username: username, FullName: "Test User", Email: "test@example.com", Password: "first password"}, nil, nil))
+
+	totpUser, secret := newTOTPUser(t, username)
+	_, err := s.store.SetUserTOTPSecret(t.Context(), db.SetUserTOTPSecretParams{Username: username, TotpSecret: totpUser.TotpSecret})
+	require.NoError(t, err)
+	_, err = s.store.EnableTOTPTx(t.Context(), db.EnableTOTPTxParams{Username: username, Step: totp.Step(totpUser.TotpEnabledAt.Time) - 2})
+	require.NoError(t, err)
+
+	headers := bearer(createTestToken(t, s.server, username))
+	body := changePasswordRequest{OldPassword: "first password", NewPassword: "second password"}
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodPut, "/v2/users/me/password", body, headers, nil))
+
+	headers[totpCodeHeaderKey] = currentTOTPCode(t, secret, 0)
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodPut, "/v2/users/me/password", body, headers, nil))
+}
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	s := newPasswordTestServer(t)
+	forgot := func(email string) *httptest.ResponseRecorder {
+		data, err := json.Marshal(forgotPasswordRequest{Email: email})
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/v2/users/password/forgot", bytes.NewReader(data))
+		require.NoError(t, err)
+		request.RemoteAddr = "192.0.2.1:4321"
+		s.server.Router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// An address is asked for LoginBackoffAfter times, whether or not it has an account,
+	// before the next request must wait.
+	for range s.server.Config.LoginBackoffAfter {
+		require.Equal(t, http.StatusAccepted, forgot("Nobody@example.com").Code)
+	}
+	recorder := forgot("nobody@example.com")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	// So is a client that asks for many addresses.
+	for i := s.server.Config.LoginBackoffAfter; i < s.server.Config.LoginIPBackoffAfter; i++ {
+		require.Equal(t, http.StatusAccepted, forgot(fmt.Sprintf("user%d@example.com", i)).Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, forgot("someone@example.com").Code)
+	s.server.background.Wait()
+}
+
+func TestResetPassword(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	email := username + "@example.com"
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/users",
+		CreateUserRequest{Username: username, FullName: "Test User", Email: email, Password: "first password"}, nil, nil))
+	_, oldToken := s.login(username, "first password")
+
+	forgot := func(email string) int {
+		return s.call(http.MethodPost, "/v2/users/password/forgot", forgotPasswordRequest{Email: email}, nil, nil)
+	}
+	reset := func(token, newPassword string) int {
+		return s.call(http.MethodPost, "/v2/users/password/reset", resetPasswordRequest{Token: token, NewPassword: newPassword}, nil, nil)
+	}
+	// resetToken reads the token out of the latest notification, once it has been sent.
+	resetToken := func() string {
+		s.server.background.Wait()
+		messages := s.notifier.Messages()
+		require.NotEmpty(t, messages)
+		msg := messages[len(messages)-1]
+		require.Equal(t, username, msg.Username)
+		require.Equal(t, email, msg.To)
+
+		var token string
+		_, err := fmt.Sscanf(msg.Body[strings.Index(msg.Body, "token is "):], "token is %s", &token)
+		require.NoError(t, err)
+		return strings.TrimSuffix(token, ".")
+	}
+
+	// Unknown addresses get the same answer and no message.
+	sent := len(s.notifier.Messages())
+	require.Equal(t, http.StatusAccepted, forgot("nobody@example.com"))
+	s.server.background.Wait()
+	require.Len(t, s.notifier.Messages(), sent)
+	require.Equal(t, http.StatusBadRequest, forgot("not an email"))
+
+	require.Equal(t, http.StatusAccepted, forgot(email))
+	first := resetToken()
+	require.Equal(t, http.StatusAccepted, forgot(email))
+	second := resetToken()
+	require.NotEqual(t, first, second)
+
+	require.Equal(t, http.StatusUnauthorized, reset("not-a-token", "second password"))
+	require.Equal(t, http.StatusBadRequest, reset(first, "short"))
+	require.Equal(t, http.StatusBadRequest, reset(first, "first password"))
+	require.Equal(t, http.StatusOK, reset(first, "second password"))
+
+	// The token is used up, and so are the others issued before the change.
+	require.Equal(t, http.StatusUnauthorized, reset(first, "third password"))
+	require.Equal(t, http.StatusUnauthorized, reset(second, "third password"))
+
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodGet, "/v2/accounts?page_id=1&page_size=5", nil, bearer(oldToken), nil))
+	code, _ := s.login(username, "second password")
+	require.Equal(t, http.StatusAccepted, code)
+}
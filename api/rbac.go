@@ -0,0 +1,151 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	db "example.com/db/sqlc"
+	"example.com/pb"
+	"example.com/token"
+	"github.com/gin-gonic/gin"
+)
+
+// permission is something a caller may do. Every authenticated route declares the one it
+// needs with requirePermission; the roles that hold each permission are listed in
+// rolePermissions and nowhere else.
+type permission string
+
+const (
+	permCreateAccount          permission = "accounts:create"
+	permCreateAnyAccount       permission = "accounts:create_any"
+	permListAccounts           permission = "accounts:list"
+	permListAllAccounts        permission = "accounts:list_all"
+	permViewAccount            permission = "accounts:view"
+	permViewAnyAccount         permission = "accounts:view_any"
+	permFreezeAccount          permission = "accounts:freeze"
+	permCloseAccount           permission = "accounts:close"
+	permCloseAnyAccount        permission = "accounts:close_any"
+	permTransfer               permission = "transfers:create"
+	permTransferAny            permission = "transfers:create_any"
+	permManageWebhooks         permission = "webhooks:manage"
+	permManageRoles            permission = "users:manage_roles"
+	permUnlockUsers            permission = "users:unlock"
+	permEraseUsers             permission = "users:erase"
+	permManageProfile          permission = "users:manage_self"
+	permViewAuditLog           permission = "audit_log:view"
+	permSetOverdraft           permission = "accounts:set_overdraft"
+	permManageInterestProducts permission = "interest_products:manage"
+)
+
+// rolePermissions grants permissions to roles. The "any" permissions extend one that a
+// customer holds for their own accounts to the accounts of every user.
+var rolePermissions = map[db.UserRole][]permission{
+	db.UserRoleCustomer: {
+		permCreateAccount, permListAccounts, permViewAccount, permTransfer, permManageWebhooks, permManageProfile,
+		permCloseAccount,
+	},
+	// Tellers act for customers at the counter: they open accounts and make transfers for them.
+	db.UserRoleTeller: {
+		permCreateAccount, permListAccounts, permViewAccount, permTransfer, permManageWebhooks, permManageProfile,
+		permCloseAccount, permCreateAnyAccount, permTransferAny,
+	},
+	db.UserRoleAdmin: {
+		permCreateAccount, permListAccounts, permViewAccount, permTransfer, permManageWebhooks, permManageProfile,
+		permCloseAccount, permCreateAnyAccount, permTransferAny,
+		permListAllAccounts, permViewAnyAccount, permFreezeAccount, permCloseAnyAccount, permManageRoles, permUnlockUsers,
+		permEraseUsers, permViewAuditLog, permSetOverdraft, permManageInterestProducts,
+	},
+	// Auditors read the audit log and every account, and change nothing but their own profile.
+	db.UserRoleAuditor: {
+		permListAccounts, permViewAccount, permManageProfile,
+		permListAllAccounts, permViewAnyAccount, permViewAuditLog,
+	},
+}
+
+// apiKeyScopes are the scopes an API key can be given, and the permissions each allows.
+// Managing the user's profile, API keys included, is left to the user themselves.
+var apiKeyScopes = map[string][]permission{
+	"accounts:read":   {permListAccounts, permListAllAccounts, permViewAccount, permViewAnyAccount},
+	"accounts:write":  {permCreateAccount, permCreateAnyAccount, permFreezeAccount, permCloseAccount, permCloseAnyAccount},
+	"transfers:write": {permTransfer, permTransferAny},
+	"webhooks:manage": {permManageWebhooks},
+	"audit_log:read":  {permViewAuditLog},
+}
+
+// grpcMethodPermissions declares the permission each authenticated gRPC method needs, as
+// requirePermission does for the HTTP routes.
+var grpcMethodPermissions = map[string]permission{
+	pb.SimpleBank_CreateAccount_FullMethodName:  permCreateAccount,
+	pb.SimpleBank_GetAccount_FullMethodName:     permViewAccount,
+	pb.SimpleBank_ListAccounts_FullMethodName:   permListAccounts,
+	pb.SimpleBank_CreateTransfer_FullMethodName: permTransfer,
+	pb.SimpleBank_ListEntries_FullMethodName:    permViewAccount,
+}
+
+var (
+	errPermissionDenied = errors.New("permission denied")
+	errAccountNotOwned  = errors.New("account doesn't belong to the authenticated user")
+)
+
+// hasPermission reports whether the caller's role grants p and, for an API key, one of its
+// scopes does. A token without a known role, such as one issued before roles existed,
+// grants nothing.
+func hasPermission(payload *token.Payload, p permission) bool {
+	return slices.Contains(rolePermissions[db.UserRole(payload.Role)], p) && scopesAllow(payload, p)
+}
+
+// scopesAllow reports whether the scopes of the caller's API key allow p. Access tokens
+// have no scopes and allow everything.
+func scopesAllow(payload *token.Payload, p permission) bool {
+	if payload.APIKeyID == 0 {
+		return true
+	}
+	for _, scope := range payload.Scopes {
+		if slices.Contains(apiKeyScopes[scope], p) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPermission(payload *token.Payload, p permission) error {
+	if !slices.Contains(rolePermissions[db.UserRole(payload.Role)], p) {
+		return fmt.Errorf("%w: role %q cannot %s", errPermissionDenied, payload.Role, p)
+	}
+	if !scopesAllow(payload, p) {
+		return fmt.Errorf("%w: the scopes of the API key do not allow %s", errPermissionDenied, p)
+	}
+	return nil
+}
+
+// requirePermission only lets requests through whose caller holds p. It must run after
+// authMiddleware.
+func requirePermission(p permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := checkPermission(authPayload(c), p); err != nil {
+			c.AbortWithStatusJSON(errorStatus(err), errorResponse(c, err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkOwner lets the caller act for owner if it is the caller, or if their role holds
+// anyPerm, the permission to do so for every user.
+func checkOwner(owner string, payload *token.Payload, anyPerm permission) error {
+	if owner == payload.Username || hasPermission(payload, anyPerm) {
+		return nil
+	}
+	return errAccountNotOwned
+}
+
+// authorizeAccount checks that the caller may act on the account: they own it, or their
+// role holds anyPerm. If not, it writes a 403 response and returns false.
+func authorizeAccount(c *gin.Context, account db.Account, anyPerm permission) bool {
+	if err := checkOwner(account.Owner, authPayload(c), anyPerm); err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"example.com/db/memstore"
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/token"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// routeAccess is who may call a route.
+type routeAccess struct {
+	public bool
+	roles  []db.UserRole
+}
+
+var (
+	everyone   = routeAccess{roles: db.AllUserRoleValues()}
+	adminsOnly = routeAccess{roles: []db.UserRole{db.UserRoleAdmin}}
+	// banking is every role but auditors, who only look.
+	banking           = routeAccess{roles: []db.UserRole{db.UserRoleCustomer, db.UserRoleTeller, db.UserRoleAdmin}}
+	adminsAndAuditors = routeAccess{roles: []db.UserRole{db.UserRoleAdmin, db.UserRoleAuditor}}
+)
+
+// routeAccessRules lists every route registered in NewServer, without its version prefix.
+// TestRoutePermissions fails when a route is missing here.
+var routeAccessRules = map[string]routeAccess{
+	"POST /users":                          {public: true},
+	"GET /users":                           {public: true},
+	"POST /users/login":                    {public: true},
+	"POST /users/login/totp":               {public: true},
+	"POST /users/password/forgot":          {public: true},
+	"POST /users/password/reset":           {public: true},
+	"POST /users/email/verify":             {public: true},
+	"GET /users/me":                        everyone,
+	"PATCH /users/me":                      everyone,
+	"POST /users/me/email/verification":    everyone,
+	"POST /users/me/api_keys":              everyone,
+	"GET /users/me/api_keys":               everyone,
+	"DELETE /users/me/api_keys/:id":        everyone,
+	"PUT /users/me/password":               everyone,
+	"PATCH /users/:username/role":          adminsOnly,
+	"DELETE /users/:username/lockout":      adminsOnly,
+	"POST /users/me/totp":                  everyone,
+	"POST /users/me/totp/confirm":          everyone,
+	"GET /users/me/export":                 everyone,
+	"POST /users/me/data_requests":         everyone,
+	"GET /users/me/data_requests":          everyone,
+	"GET /data_requests":                   adminsOnly,
+	"PATCH /data_requests/:id":             adminsOnly,
+	"POST /accounts":                       banking,
+	"GET /accounts":                        everyone,
+	"GET /accounts/:id":                    everyone,
+	"GET /accounts/:id/entries":            everyone,
+	"GET /accounts/:id/stream":             everyone,
+	"PATCH /accounts/:id/freeze":           adminsOnly,
+	"PATCH /accounts/:id/close":            banking,
+	"POST /transfers":                      banking,
+	"POST /webhooks":                       banking,
+	"GET /webhooks":                        banking,
+	"DELETE /webhooks/:id":                 banking,
+	"GET /webhooks/:id/deliveries":         banking,
+	"POST /webhooks/deliveries/:id/replay": banking,
+	"GET /audit_log":                       adminsAndAuditors,
+	"GET /audit_log/export":                adminsAndAuditors,
+	"PATCH /admin/accounts/:id/overdraft":  adminsOnly,
+	"PUT /admin/interest_products":         adminsOnly,
+	"GET /admin/interest_products":         adminsOnly,
+	"GET " + openAPIPath:                   {public: true},
+	"GET " + swaggerUIDir + "/*any":        {public: true},
+	"GET " + metricsPath:                   {public: true},
+	"GET " + healthzPath:                   {public: true},
+	"GET " + readyzPath:                    {public: true},
+}
+
+// unversionedPath strips the API version from a route's path.
+func unversionedPath(path string) string {
+	for _, version := range apiVersions {
+		if rest, ok := strings.CutPrefix(path, version.prefix()+"/"); ok {
+			return "/" + rest
+		}
+	}
+	return path
+}
+
+// TestRoutePermissions calls every route as each role against a store with only the
+// caller in it. A role that may call a route gets past the permission check, to a 400 or a
+// 404; any other gets a 403.
+func TestRoutePermissions(t *testing.T) {
+	store := memstore.New()
+	server := newTestServer(t, newTestConfig(), store)
+
+	for _, route := range server.Router.Routes() {
+		access, ok := routeAccessRules[route.Method+" "+unversionedPath(route.Path)]
+
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			require.True(t, ok, "route has no access rule")
+
+			path := strings.NewReplacer(":id", "1", ":username", "nobody", "*any", "").Replace(route.Path)
+			call := func(role db.UserRole) int {
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest(route.Method, path, bytes.NewBufferString("{}"))
+				require.NoError(t, err)
+				if role != "" {
+					username := util.RandomOwner()
+					_, err := store.CreateUser(t.Context(), db.CreateUserParams{Username: username, Email: username + "@example.com"})
+					require.NoError(t, err)
+					addRoleAuthorization(t, request, server.TokenMaker, username, role)
+				}
+				server.Router.ServeHTTP(recorder, request)
+				return recorder.Code
+			}
+
+			switch {
+			case access.public:
+				require.NotEqual(t, http.StatusUnauthorized, call(""))
+			default:
+				require.Equal(t, http.StatusUnauthorized, call(""))
+				for _, role := range db.AllUserRoleValues() {
+					code := call(role)
+					if slices.Contains(access.roles, role) {
+						require.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, code, "role %s", role)
+					} else {
+						require.Equal(t, http.StatusForbidden, code, "role %s", role)
+					}
+				}
+				require.Equal(t, http.StatusForbidden, call("intern"), "unknown role")
+			}
+		})
+	}
+}
+
+// TestCheckOwner covers who may act on another user's accounts.
+func TestCheckOwner(t *testing.T) {
+	testCases := []struct {
+		Role    db.UserRole
+		AnyPerm permission
+		Allowed bool
+	}{
+		{Role: db.UserRoleCustomer, AnyPerm: permViewAnyAccount, Allowed: false},
+		{Role: db.UserRoleCustomer, AnyPerm: permCreateAnyAccount, Allowed: false},
+		{Role: db.UserRoleCustomer, AnyPerm: permTransferAny, Allowed: false},
+		{Role: db.UserRoleTeller, AnyPerm: permViewAnyAccount, Allowed: false},
+		{Role: db.UserRoleTeller, AnyPerm: permCreateAnyAccount, Allowed: true},
+		{Role: db.UserRoleTeller, AnyPerm: permTransferAny, Allowed: true},
+		{Role: db.UserRoleAdmin, AnyPerm: permViewAnyAccount, Allowed: true},
+		{Role: db.UserRoleAdmin, AnyPerm: permCreateAnyAccount, Allowed: true},
+		{Role: db.UserRoleAdmin, AnyPerm: permTransferAny, Allowed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.Role)+" "+string(tc.AnyPerm), func(t *testing.T) {
+			payload := &token.Payload{Username: "alice", Role: string(tc.Role)}
+			require.NoError(t, checkOwner("alice", payload, tc.AnyPerm))
+
+			err := checkOwner("bob", payload, tc.AnyPerm)
+			if tc.Allowed {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, errAccountNotOwned)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	user := db.User{Username: util.RandomOwner(), FullName: "Test User", Email: "test@example.com", Role: db.UserRoleTeller}
+
+	testCases := []struct {
+		Name          string
+		Body          string
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "OK",
+			Body: `{"role": "teller"}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Eq(db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleTeller})).
+					Times(1).Return(user, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, rr.Code)
+
+				var body userResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.Equal(t, db.UserRoleTeller, body.Role)
+			},
+		},
+		{
+			Name: "Invalid Role",
+			Body: `{"role": "root"}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name: "Not Found",
+			Body: `{"role": "admin"}`,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPatch, "/v2/users/"+user.Username+"/role", bytes.NewBufferString(tc.Body))
+			require.NoError(t, err)
+			addRoleAuthorization(t, request, server.TokenMaker, util.RandomOwner(), db.UserRoleAdmin)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateUserRoleRevokesTokens(t *testing.T) {
+	s := newPasswordTestServer(t)
+	admin, other := util.RandomOwner(), util.RandomOwner()
+	s.signUp(admin, admin+"@example.com")
+	s.signUp(other, other+"@example.com")
+	_, err := s.store.UpdateUserRole(t.Context(), db.UpdateUserRoleParams{Username: admin, Role: db.UserRoleAdmin})
+	require.NoError(t, err)
+	code, adminToken := s.login(admin, "first password")
+	require.Equal(t, http.StatusAccepted, code)
+	_, otherToken := s.login(other, "first password")
+
+	// Demoted by another admin, the admin's token no longer works, and no longer holds the role.
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, "/v2/users/"+other+"/role",
+		updateUserRoleRequest{Role: db.UserRoleAdmin}, bearer(adminToken), nil))
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodGet, "/v2/data_requests?page_size=5", nil, bearer(otherToken), nil),
+		"the promoted user logs in again to get the role")
+	_, otherToken = s.login(other, "first password")
+	require.Equal(t, http.StatusOK, s.call(http.MethodPatch, "/v2/users/"+admin+"/role",
+		updateUserRoleRequest{Role: db.UserRoleCustomer}, bearer(otherToken), nil))
+
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodGet, "/v2/data_requests?page_size=5", nil, bearer(adminToken), nil))
+	require.Equal(t, http.StatusUnauthorized, s.call(http.MethodPatch, "/v2/users/"+other+"/role",
+		updateUserRoleRequest{Role: db.UserRoleCustomer}, bearer(adminToken), nil))
+
+	_, adminToken = s.login(admin, "first password")
+	require.Equal(t, http.StatusForbidden, s.call(http.MethodGet, "/v2/data_requests?page_size=5", nil, bearer(adminToken), nil))
+}
@@ -1,44 +1,242 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
 	db "example.com/db/sqlc"
 	"example.com/db/util"
+	"example.com/logging"
+	"example.com/notify"
+	"example.com/password"
+	"example.com/stream"
+	"example.com/token"
+	"example.com/totp"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
-	Store  db.Store
-	Router *gin.Engine
+	Config     util.Config
+	Store      db.Store
+	TokenMaker token.Maker
+	Broker     *stream.Broker
+	Logger     *slog.Logger
+	Router     *gin.Engine
+	// Notifier sends users their password reset tokens and email verification codes,
+	// the way util.Config.Notifier names.
+	Notifier notify.Notifier
+
+	httpServer *http.Server
+	// totpCipher seals TOTP secrets. It is nil when no key is configured, and then users
+	// cannot enroll in two-factor authentication.
+	totpCipher     *totp.Cipher
+	passwordPolicy *password.Policy
+	// shutdown is closed when Shutdown starts, to end the account streams that would
+	// otherwise keep their connections open until the drain times out.
+	shutdown chan struct{}
+	// background counts the work handlers leave running after they answer, such as
+	// sending a password reset token. Shutdown waits for it.
+	background sync.WaitGroup
 }
 
-func NewServer(store db.Store) *Server {
-	r := gin.Default()
+func NewServer(config util.Config, store db.Store) (*Server, error) {
+	tokenMaker, err := token.NewJWTMaker(config.TokenSymmetricKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token maker: %w", err)
+	}
+
+	logger := slog.Default()
+	redactor := logging.NewRedactor(config.LogRedactKeys)
+
+	r := gin.New()
+	// The client IP is checked against API key allowlists, counts toward the login
+	// throttle and goes in the audit log, so only the configured proxies may name it.
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	// Handlers pass the gin context to the store; let it reach the request context so
+	// that the request ID and the trace span get to the queries.
+	r.ContextWithFallback = true
+	r.Use(requestIDMiddleware(), auditActorMiddleware(), tracingMiddleware(), loggerMiddleware(logger, redactor), metricsMiddleware(), gin.Recovery())
+
 	server := &Server{
-		Store:  store,
-		Router: r,
+		Config:     config,
+		Store:      store,
+		TokenMaker: tokenMaker,
+		Broker:     stream.NewBroker(),
+		Logger:     logger,
+		Router:     r,
+		Notifier:   newNotifier(config, logger),
+		shutdown:   make(chan struct{}),
+	}
+
+	server.passwordPolicy, err = password.NewPolicy(config.PasswordMinLength, config.PasswordHistory, config.PasswordBreachedFile)
+	if err != nil {
+		return nil, err
 	}
 
+	if config.TOTPEncryptionKey != "" {
+		server.totpCipher, err = totp.NewCipher(config.TOTPEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create TOTP cipher: %w", err)
+		}
+	}
+
+	server.httpServer = &http.Server{
+		Handler:           r,
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		ReadTimeout:       config.HTTPReadTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+	}
+	server.httpServer.RegisterOnShutdown(func() { close(server.shutdown) })
+
 	if value, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		value.RegisterValidation("currency", util.Currency)
+		value.RegisterValidation("webhook_event", validWebhookEvent)
+		value.RegisterValidation("webhook_url", validWebhookURL)
+		value.RegisterValidation("api_key_scope", validAPIKeyScope)
 	}
 
-	server.Router.POST("/accounts", server.CreateAccount)
-	server.Router.GET("/accounts/:id", server.GetAccount)
-	server.Router.GET("/accounts", server.ListAccounts)
-	server.Router.POST("/transfers", server.CreateTransfer)
-	server.Router.POST("/users",server.CreateUser)
-	server.Router.GET("/users", server.GetUser)
+	sunset, err := parseSunset(config.DeprecatedRoutesSunset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range apiVersions {
+		group := server.Router.Group(version.prefix(), versionMiddleware(version))
+		server.registerRoutes(group, version, sunset)
+	}
 
+	// The unversioned paths stay available as v1 aliases until the sunset.
+	if !sunset.IsZero() {
+		legacy := server.Router.Group("/", deprecatedMiddleware(sunset, legacyAliasSuccessor), versionMiddleware(0))
+		server.registerRoutes(legacy, apiV1, sunset)
+	}
 
-	return server
+	server.registerDocs()
+	server.Router.GET(metricsPath, gin.WrapH(promhttp.Handler()))
+	server.Router.GET(healthzPath, server.Healthz)
+	server.Router.GET(readyzPath, server.Readyz)
+
+	return server, nil
+}
+
+// newNotifier returns the notifier config.Notifier names.
+func newNotifier(config util.Config, logger *slog.Logger) notify.Notifier {
+	switch config.Notifier {
+	case "smtp":
+		return &notify.SMTPNotifier{
+			Addr:     config.SMTPAddr,
+			From:     config.SMTPFrom,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+		}
+	case "file":
+		return &notify.FileNotifier{Dir: config.NotifyDir, From: config.SMTPFrom}
+	default:
+		return notify.NewLogNotifier(logger)
+	}
+}
+
+// registerRoutes registers the routes of one API version on group.
+func (server *Server) registerRoutes(group *gin.RouterGroup, version apiVersion, sunset time.Time) {
+	group.POST("/users", server.CreateUser)
+	group.POST("/users/password/forgot", server.ForgotPassword)
+	group.POST("/users/password/reset", server.ResetPassword)
+	group.POST("/users/email/verify", server.VerifyEmail)
+
+	switch {
+	case version >= apiV2:
+		group.POST("/users/login", server.LoginUser)
+		group.POST("/users/login/totp", server.LoginUserTOTP)
+	case !sunset.IsZero():
+		// v1 logs in with the password in the query string; v2 replaces it with POST /users/login.
+		group.GET("/users", deprecatedMiddleware(sunset, func(*gin.Context) string {
+			return apiV2.prefix() + "/users/login"
+		}), server.GetUser)
+	}
+
+	// Every authenticated route declares the permission it needs; see rolePermissions.
+	authRoutes := group.Group("").Use(authMiddleware(server.TokenMaker, server.Store))
+	authRoutes.POST("/accounts", requirePermission(permCreateAccount), server.CreateAccount)
+	authRoutes.GET("/accounts", requirePermission(permListAccounts), server.ListAccounts)
+	authRoutes.GET("/accounts/:id", requirePermission(permViewAccount), server.GetAccount)
+	authRoutes.GET("/accounts/:id/entries", requirePermission(permViewAccount), server.ListEntries)
+	authRoutes.GET("/accounts/:id/stream", requirePermission(permViewAccount), server.StreamAccount)
+	authRoutes.PATCH("/accounts/:id/freeze", requirePermission(permFreezeAccount), server.FreezeAccount)
+	authRoutes.PATCH("/accounts/:id/close", requirePermission(permCloseAccount), server.CloseAccount)
+	authRoutes.POST("/transfers", requirePermission(permTransfer), server.requireSignature, server.CreateTransfer)
+	authRoutes.PATCH("/users/:username/role", requirePermission(permManageRoles), server.UpdateUserRole)
+	authRoutes.DELETE("/users/:username/lockout", requirePermission(permUnlockUsers), server.UnlockUser)
+	authRoutes.GET("/users/me", requirePermission(permManageProfile), server.GetCurrentUser)
+	authRoutes.PATCH("/users/me", requirePermission(permManageProfile), server.UpdateCurrentUser)
+	authRoutes.POST("/users/me/email/verification", requirePermission(permManageProfile), server.ResendEmailVerification)
+	authRoutes.POST("/users/me/api_keys", requirePermission(permManageProfile), server.CreateAPIKey)
+	authRoutes.GET("/users/me/api_keys", requirePermission(permManageProfile), server.ListAPIKeys)
+	authRoutes.DELETE("/users/me/api_keys/:id", requirePermission(permManageProfile), server.RevokeAPIKey)
+	authRoutes.PUT("/users/me/password", requirePermission(permManageProfile), server.ChangePassword)
+	authRoutes.POST("/users/me/totp", requirePermission(permManageProfile), server.EnrollTOTP)
+	authRoutes.POST("/users/me/totp/confirm", requirePermission(permManageProfile), server.ConfirmTOTP)
+	authRoutes.GET("/users/me/export", requirePermission(permManageProfile), server.ExportCurrentUser)
+	authRoutes.POST("/users/me/data_requests", requirePermission(permManageProfile), server.CreateDataRequest)
+	authRoutes.GET("/users/me/data_requests", requirePermission(permManageProfile), server.ListCurrentUserDataRequests)
+	authRoutes.POST("/webhooks", requirePermission(permManageWebhooks), server.CreateWebhookSubscription)
+	authRoutes.GET("/webhooks", requirePermission(permManageWebhooks), server.ListWebhookSubscriptions)
+	authRoutes.DELETE("/webhooks/:id", requirePermission(permManageWebhooks), server.DeleteWebhookSubscription)
+	authRoutes.GET("/webhooks/:id/deliveries", requirePermission(permManageWebhooks), server.ListWebhookDeliveries)
+	authRoutes.POST("/webhooks/deliveries/:id/replay", requirePermission(permManageWebhooks), server.ReplayWebhookDelivery)
+	authRoutes.GET("/audit_log", requirePermission(permViewAuditLog), server.ListAuditLog)
+	authRoutes.GET("/audit_log/export", requirePermission(permViewAuditLog), server.ExportAuditLog)
+	authRoutes.GET("/data_requests", requirePermission(permEraseUsers), server.ListDataRequests)
+	authRoutes.PATCH("/data_requests/:id", requirePermission(permEraseUsers), server.ReviewDataRequest)
+	authRoutes.PATCH("/admin/accounts/:id/overdraft", requirePermission(permSetOverdraft), server.UpdateOverdraftLimit)
+	authRoutes.PUT("/admin/interest_products", requirePermission(permManageInterestProducts), server.UpsertInterestProduct)
+	authRoutes.GET("/admin/interest_products", requirePermission(permManageInterestProducts), server.ListInterestProducts)
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"Error": err.Error()}
+// errorResponse is the body of every error. It carries the request ID so that a failed
+// request can be found in the logs.
+func errorResponse(c *gin.Context, err error) gin.H {
+	return gin.H{"Error": err.Error(), "request_id": c.GetString(requestIDKey)}
 }
 
+// Start serves HTTP on address until Shutdown is called, after which it returns nil.
 func (server *Server) Start(address string) error {
-	return server.Router.Run(address)
+	server.httpServer.Addr = address
+
+	err := server.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections, ends the open account streams and waits for the
+// requests in flight, such as transfers, and then the work they left in the background to
+// complete, or for ctx to expire.
+func (server *Server) Shutdown(ctx context.Context) error {
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
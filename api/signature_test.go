@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/pb"
+	"example.com/signing"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestSignedTransfers(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	email := username + "@example.com"
+	headers := bearer(s.signUp(username, email))
+	require.Equal(t, http.StatusOK, s.verifyEmail(s.verificationCode(email)))
+
+	payee := util.RandomOwner()
+	s.signUp(payee, payee+"@example.com")
+
+	var accounts [2]db.Account
+	for i, owner := range []string{username, payee} {
+		var err error
+		accounts[i], err = s.store.CreateAccount(t.Context(), db.CreateAccountParams{
+			Owner:       owner,
+			Balance:     pgtype.Numeric{Int: big.NewInt(100), Valid: true},
+			Currency:    "USD",
+			AccountType: db.AccountTypeChecking,
+		})
+		require.NoError(t, err)
+	}
+
+	var key createAPIKeyResponse
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/users/me/api_keys", createAPIKeyRequest{
+		Name:             "payments",
+		Scopes:           []string{"transfers:write"},
+		RequireSignature: true,
+	}, headers, &key))
+	require.True(t, key.SignatureRequired)
+	require.True(t, strings.HasPrefix(key.SigningSecret, signingSecretPrefix))
+
+	var keys []apiKeyResponse
+	require.Equal(t, http.StatusAccepted, s.call(http.MethodGet, "/v2/users/me/api_keys", nil, headers, &keys))
+	require.True(t, keys[0].SignatureRequired)
+
+	body, err := json.Marshal(RequestParams{FromAccountId: accounts[0].ID, ToAccountId: accounts[1].ID, Amount: 10, Currency: "USD"})
+	require.NoError(t, err)
+
+	// sendTo posts a transfer with the key to server; sign, if set, signs the request just
+	// before. send posts it to s.
+	sendTo := func(server *Server, body []byte, sign func(*http.Request)) int {
+		request, err := http.NewRequest(http.MethodPost, "/v2/transfers", bytes.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set(apiKeyHeaderKey, key.Key)
+		if sign != nil {
+			sign(request)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.Router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	send := func(body []byte, sign func(*http.Request)) int { return sendTo(s.server, body, sign) }
+	signWith := func(secret string) func(*http.Request) {
+		return func(request *http.Request) { require.NoError(t, signing.SignRequest(request, secret)) }
+	}
+
+	require.Equal(t, http.StatusUnauthorized, send(body, nil), "unsigned")
+	require.Equal(t, http.StatusUnauthorized, send(body, signWith(signingSecretPrefix+"wrong")))
+
+	var signed http.Header
+	require.Equal(t, http.StatusCreated, send(body, func(request *http.Request) {
+		signWith(key.SigningSecret)(request)
+		signed = request.Header.Clone()
+	}))
+
+	// The same request, sent again, is refused.
+	replay := func(request *http.Request) { request.Header = signed.Clone() }
+	require.Equal(t, http.StatusUnauthorized, send(body, replay), "replayed")
+
+	// Even by another server: the nonces are kept in the store they share.
+	replica := newTestServer(t, s.server.Config, s.store)
+	require.Equal(t, http.StatusUnauthorized, sendTo(replica, body, replay), "replayed on another server")
+
+	// So is a signed request whose body was changed on the way.
+	tampered := bytes.Replace(body, []byte(`"amount":10`), []byte(`"amount":90`), 1)
+	require.Equal(t, http.StatusUnauthorized, send(tampered, func(request *http.Request) {
+		request.Header.Set(signing.Header, signing.Sign(key.SigningSecret, http.MethodPost, "/v2/transfers", time.Now(), signing.NewNonce(), body))
+	}), "tampered")
+
+	// And one signed outside the window.
+	require.Equal(t, http.StatusUnauthorized, send(body, func(request *http.Request) {
+		request.Header.Set(signing.Header, signing.Sign(key.SigningSecret, http.MethodPost, "/v2/transfers", time.Now().Add(-time.Hour), signing.NewNonce(), body))
+	}), "stale")
+
+	require.Equal(t, http.StatusCreated, send(body, signWith(key.SigningSecret)))
+
+	// Access tokens and keys without a signing secret transfer as before.
+	require.Equal(t, http.StatusCreated, s.call(http.MethodPost, "/v2/transfers", json.RawMessage(body), headers, nil))
+
+	// gRPC cannot check a signature, so it refuses the key.
+	client := newTestGRPCClient(t, s.server)
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "x-api-key", key.Key)
+	_, err = client.CreateTransfer(ctx, &pb.CreateTransferRequest{
+		FromAccountId: accounts[0].ID, ToAccountId: accounts[1].ID, Amount: 10, Currency: "USD",
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	account, err := s.store.GetAccount(t.Context(), accounts[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "70.00", formatMoney(account.Balance))
+}
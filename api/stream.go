@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	lastEventIDHeader   = "Last-Event-ID"
+	streamPageSize      = 100
+	streamHeartbeatTime = 15 * time.Second
+)
+
+type balanceEvent struct {
+	AccountID        int64          `json:"account_id"`
+	Balance          pgtype.Numeric `json:"balance"`
+	AvailableBalance pgtype.Numeric `json:"available_balance"`
+}
+
+// StreamAccount pushes an account's new entries and balance as server-sent events.
+// Every entry event carries the entry ID as its event ID, so a reconnecting client that sends
+// Last-Event-ID receives the entries it missed before the live updates resume.
+func (server *Server) StreamAccount(c *gin.Context) {
+	var req getAccountRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	account, err := server.Store.GetAccount(c, req.ID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, errorResponse(c, err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	if !authorizeAccount(c, account, permViewAnyAccount) {
+		return
+	}
+
+	// Subscribe before reading so that nothing committed in between is missed.
+	updates, unsubscribe := server.Broker.Subscribe(account.ID)
+	defer unsubscribe()
+
+	lastEntryID, err := server.streamStartID(c, account.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// A stream outlives any write timeout; it ends when the client leaves or the server shuts down.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		c.Error(err)
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatTime)
+	defer heartbeat.Stop()
+
+	if lastEntryID, err = server.sendAccountUpdates(c, account.ID, lastEntryID); err != nil {
+		sendStreamError(c, err)
+		return
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-server.shutdown:
+			return
+		case <-heartbeat.C:
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		case <-updates:
+			if lastEntryID, err = server.sendAccountUpdates(c, account.ID, lastEntryID); err != nil {
+				sendStreamError(c, err)
+				return
+			}
+		}
+	}
+}
+
+func sendStreamError(c *gin.Context, err error) {
+	c.Render(-1, sse.Event{Event: "error", Data: errorResponse(c, err)})
+	c.Writer.Flush()
+}
+
+// streamStartID returns the entry ID to resume after. Without a Last-Event-ID the stream
+// starts at the account's latest entry, so only entries committed from now on are sent.
+func (server *Server) streamStartID(c *gin.Context, accountID int64) (int64, error) {
+	lastEventID := c.GetHeader(lastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	if lastEventID != "" {
+		return strconv.ParseInt(lastEventID, 10, 64)
+	}
+
+	return server.Store.GetLatestEntryIDForAccount(c, accountID)
+}
+
+// sendAccountUpdates sends every entry after lastEntryID followed by the current balance
+// and returns the ID of the last entry sent.
+func (server *Server) sendAccountUpdates(c *gin.Context, accountID int64, lastEntryID int64) (int64, error) {
+	for {
+		entries, err := server.Store.ListEntriesForAccountAfter(c, db.ListEntriesForAccountAfterParams{
+			AccountID: accountID,
+			ID:        lastEntryID,
+			Limit:     streamPageSize,
+		})
+		if err != nil {
+			return lastEntryID, err
+		}
+
+		for _, entry := range entries {
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatInt(entry.ID, 10),
+				Event: "entry",
+				Data:  entry,
+			})
+			lastEntryID = entry.ID
+		}
+
+		if len(entries) < streamPageSize {
+			break
+		}
+	}
+
+	account, err := server.Store.GetAccount(c, accountID)
+	if err != nil {
+		return lastEntryID, err
+	}
+
+	response := newAccountResponse(account)
+	c.Render(-1, sse.Event{
+		Event: "balance",
+		Data: balanceEvent{
+			AccountID:        account.ID,
+			Balance:          response.Balance,
+			AvailableBalance: response.AvailableBalance,
+		},
+	})
+	c.Writer.Flush()
+
+	return lastEntryID, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStreamAccountResumesAndPushes(t *testing.T) {
+	account := randomAccount()
+
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	store.EXPECT().GetAccount(gomock.Any(), account.ID).AnyTimes().Return(account, nil)
+	gomock.InOrder(
+		store.EXPECT().ListEntriesForAccountAfter(gomock.Any(), db.ListEntriesForAccountAfterParams{AccountID: account.ID, ID: 5, Limit: streamPageSize}).
+			Return([]db.Entry{{ID: 6, AccountID: account.ID}, {ID: 7, AccountID: account.ID}}, nil),
+		store.EXPECT().ListEntriesForAccountAfter(gomock.Any(), db.ListEntriesForAccountAfterParams{AccountID: account.ID, ID: 7, Limit: streamPageSize}).
+			Return([]db.Entry{{ID: 8, AccountID: account.ID}}, nil),
+		store.EXPECT().ListEntriesForAccountAfter(gomock.Any(), gomock.Any()).AnyTimes().Return([]db.Entry{}, nil),
+	)
+
+	server := newTestServer(t, newTestConfig(), store)
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/accounts/%d/stream", ts.URL, account.ID), nil)
+	require.NoError(t, err)
+	request.Header.Set(lastEventIDHeader, "5")
+	addAuthorization(t, request, server.TokenMaker, account.Owner)
+
+	response, err := ts.Client().Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Contains(t, response.Header.Get("Content-Type"), "text/event-stream")
+
+	reader := bufio.NewReader(response.Body)
+	readEvent := func() map[string]string {
+		event := make(map[string]string)
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			if line == "" {
+				if len(event) > 0 {
+					return event
+				}
+				continue
+			}
+			key, value, _ := strings.Cut(line, ":")
+			event[key] = value
+		}
+	}
+
+	// missed entries are replayed before the current balance
+	require.Equal(t, map[string]string{"id": "6", "event": "entry"}, pick(readEvent(), "id", "event"))
+	require.Equal(t, map[string]string{"id": "7", "event": "entry"}, pick(readEvent(), "id", "event"))
+	require.Equal(t, "balance", readEvent()["event"])
+
+	// a committed change is pushed
+	server.Broker.Publish(account.ID)
+	require.Equal(t, map[string]string{"id": "8", "event": "entry"}, pick(readEvent(), "id", "event"))
+	require.Equal(t, "balance", readEvent()["event"])
+}
+
+func TestStreamAccountForbidden(t *testing.T) {
+	account := randomAccount()
+
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+	store.EXPECT().ListEntriesForAccountAfter(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, newTestConfig(), store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/accounts/%d/stream", account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.TokenMaker, util.RandomOwner()+"x")
+
+	server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func pick(event map[string]string, keys ...string) map[string]string {
+	picked := make(map[string]string)
+	for _, key := range keys {
+		picked[key] = event[key]
+	}
+	return picked
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errInvalidCredentials = errors.New("invalid username or password")
+
+// loginThrottledError refuses a login attempt without checking the password, because of
+// the failures before it, or a password reset request because of the requests before it.
+type loginThrottledError struct {
+	RetryAt time.Time
+	// Locked is set when the username is locked out rather than backing off.
+	Locked bool
+}
+
+func (e *loginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins: locked until %s", e.RetryAt.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("too many attempts: try again in %s", time.Until(e.RetryAt).Round(time.Second))
+}
+
+// dummyPasswordHash is compared with the password of a username that does not exist, so
+// that it takes as long to reject as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// authenticate checks a username and password from clientIP. The attempt is refused
+// without comparing the password if the username or the IP failed too often recently;
+// see util.Config.LoginBackoffAfter. A failure counts against both, and an unknown
+// username fails the same way, as slowly, as a wrong password.
+//
+// A success does not forget the failures: the caller does, with clearLoginFailures,
+// once the login is complete.
+func (server *Server) authenticate(ctx context.Context, username, password, clientIP string) (db.User, error) {
+	if err := server.checkLoginThrottle(ctx, username, clientIP); err != nil {
+		return db.User{}, err
+	}
+
+	user, err := server.Store.GetUser(ctx, username)
+	unknown := errors.Is(err, pgx.ErrNoRows)
+	if err != nil && !unknown {
+		return db.User{}, err
+	}
+
+	hash := []byte(user.PasswordHash)
+	if unknown {
+		hash = dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || unknown {
+		if err := server.recordLoginFailure(ctx, username, clientIP); err != nil {
+			return db.User{}, err
+		}
+		return db.User{}, errInvalidCredentials
+	}
+	return user, nil
+}
+
+// checkLoginThrottle refuses a login attempt for a locked username, or one that comes
+// before the backoff after the latest failure of the username or the IP has passed.
+func (server *Server) checkLoginThrottle(ctx context.Context, username, clientIP string) error {
+	now := time.Now()
+
+	failure, err := server.getLoginFailure(ctx, db.LoginFailureScopeUsername, username)
+	if err != nil {
+		return err
+	}
+	if failure.LockedUntil.Valid && now.Before(failure.LockedUntil.Time) {
+		return &loginThrottledError{RetryAt: failure.LockedUntil.Time, Locked: true}
+	}
+	if retryAt := server.loginRetryAt(failure, server.Config.LoginBackoffAfter); now.Before(retryAt) {
+		return &loginThrottledError{RetryAt: retryAt}
+	}
+
+	if clientIP == "" {
+		return nil
+	}
+	failure, err = server.getLoginFailure(ctx, db.LoginFailureScopeIp, clientIP)
+	if err != nil {
+		return err
+	}
+	if retryAt := server.loginRetryAt(failure, server.Config.LoginIPBackoffAfter); now.Before(retryAt) {
+		return &loginThrottledError{RetryAt: retryAt}
+	}
+	return nil
+}
+
+// getLoginFailure returns the failures of a username or IP, a zero row if it has none.
+func (server *Server) getLoginFailure(ctx context.Context, scope db.LoginFailureScope, key string) (db.LoginFailure, error) {
+	failure, err := server.Store.GetLoginFailure(ctx, db.GetLoginFailureParams{Scope: scope, Key: key})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.LoginFailure{}, nil
+	}
+	return failure, err
+}
+
+// loginRetryAt returns when the next attempt is allowed after the failures: right away
+// for the first backoffAfter of them, then LoginBackoffBase after the latest one, doubled
+// with each failure up to LoginBackoffMax.
+func (server *Server) loginRetryAt(failure db.LoginFailure, backoffAfter int32) time.Time {
+	lastFailedAt := failure.LastFailedAt.Time
+	if failure.Failures < backoffAfter || lastFailedAt.Before(time.Now().Add(-server.Config.LoginFailureWindow)) {
+		return time.Time{}
+	}
+
+	delay := server.Config.LoginBackoffBase
+	for range failure.Failures - backoffAfter {
+		if delay >= server.Config.LoginBackoffMax {
+			break
+		}
+		delay *= 2
+	}
+	return lastFailedAt.Add(min(delay, server.Config.LoginBackoffMax))
+}
+
+// recordLoginFailure counts a failed login against the username and the IP, and locks
+// the username out once it has failed LoginLockoutAfter times.
+func (server *Server) recordLoginFailure(ctx context.Context, username, clientIP string) error {
+	windowStart := pgtype.Timestamptz{Time: time.Now().Add(-server.Config.LoginFailureWindow), Valid: true}
+
+	failure, err := server.Store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Scope:       db.LoginFailureScopeUsername,
+		Key:         username,
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		return err
+	}
+	// Every failure is logged, but only the first of a window and those from the backoff on,
+	// which the throttle spaces out, go in the audit log: a client guessing passwords must
+	// not be able to fill it as fast as it can send requests.
+	if failure.Failures == 1 || failure.Failures >= server.Config.LoginBackoffAfter {
+		server.audit(ctx, "login.failed", "user", username, "failures", failure.Failures)
+	} else {
+		server.logAudit(ctx, "login.failed", "user", username, "failures", failure.Failures)
+	}
+
+	if lockoutAfter := server.Config.LoginLockoutAfter; lockoutAfter > 0 && failure.Failures >= lockoutAfter && !failure.LockedUntil.Valid {
+		lockedUntil := time.Now().Add(server.Config.LoginLockoutDuration)
+		_, err := server.Store.LockLogin(ctx, db.LockLoginParams{
+			Scope:       db.LoginFailureScopeUsername,
+			Key:         username,
+			LockedUntil: pgtype.Timestamptz{Time: lockedUntil, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		server.audit(ctx, "login.locked", "user", username, "failures", failure.Failures, "locked_until", lockedUntil)
+	}
+
+	if clientIP == "" {
+		return nil
+	}
+	_, err = server.Store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Scope:       db.LoginFailureScopeIp,
+		Key:         clientIP,
+		WindowStart: windowStart,
+	})
+	return err
+}
+
+// throttlePasswordReset refuses a password reset request for an address that was asked
+// for LoginBackoffAfter times lately, or from an IP that asked LoginIPBackoffAfter times,
+// with the backoff of failed logins. Every request counts, whether or not the address
+// belongs to a user.
+func (server *Server) throttlePasswordReset(ctx context.Context, email, clientIP string) error {
+	limits := []struct {
+		scope db.LoginFailureScope
+		key   string
+		after int32
+	}{
+		{db.LoginFailureScopePasswordResetEmail, strings.ToLower(email), server.Config.LoginBackoffAfter},
+		{db.LoginFailureScopePasswordResetIp, clientIP, server.Config.LoginIPBackoffAfter},
+	}
+
+	now := time.Now()
+	for _, limit := range limits {
+		if limit.key == "" {
+			continue
+		}
+		failure, err := server.getLoginFailure(ctx, limit.scope, limit.key)
+		if err != nil {
+			return err
+		}
+		if retryAt := server.loginRetryAt(failure, limit.after); now.Before(retryAt) {
+			return &loginThrottledError{RetryAt: retryAt}
+		}
+	}
+
+	windowStart := pgtype.Timestamptz{Time: now.Add(-server.Config.LoginFailureWindow), Valid: true}
+	for _, limit := range limits {
+		if limit.key == "" {
+			continue
+		}
+		_, err := server.Store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+			Scope:       limit.scope,
+			Key:         limit.key,
+			WindowStart: windowStart,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failures of a user who logged in. Those of the IP stay:
+// a client guessing the passwords of many users must not reset its count with its own.
+func (server *Server) clearLoginFailures(ctx context.Context, username string) error {
+	_, err := server.Store.DeleteLoginFailure(ctx, db.DeleteLoginFailureParams{Scope: db.LoginFailureScopeUsername, Key: username})
+	return err
+}
+
+// loginErrorResponse writes the response to a failed login or step-up code, telling a
+// throttled client when to try again.
+func loginErrorResponse(c *gin.Context, err error) {
+	var throttled *loginThrottledError
+	if errors.As(err, &throttled) {
+		seconds := math.Ceil(time.Until(throttled.RetryAt).Seconds())
+		c.Header("Retry-After", strconv.Itoa(max(int(seconds), 1)))
+	}
+	c.JSON(errorStatus(err), errorResponse(c, err))
+}
+
+// UnlockUser lifts the lockout of a user after too many failed logins and forgets their
+// failures.
+func (server *Server) UnlockUser(c *gin.Context) {
+	var uri usernameRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	if _, err := server.Store.UnlockLoginTx(c, uri.Username); err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+	server.logAudit(c, "login.unlocked", "user", uri.Username)
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/db/memstore"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/logging"
+	"example.com/pb"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// throttleTestServer serves a memory store holding users whose password is "password1".
+type throttleTestServer struct {
+	t      *testing.T
+	server *Server
+	store  *memstore.Store
+	logs   *bytes.Buffer
+}
+
+func newThrottleTestServer(t *testing.T, configure func(*util.Config)) *throttleTestServer {
+	config := newTestConfig()
+	configure(&config)
+
+	store := memstore.New()
+	server := newTestServer(t, config, store)
+
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, "info", logging.NewRedactor(nil))
+	require.NoError(t, err)
+	server.Logger = logger
+
+	return &throttleTestServer{t: t, server: server, store: store, logs: &logs}
+}
+
+func (s *throttleTestServer) createUser(role db.UserRole) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	require.NoError(s.t, err)
+
+	user, err := s.store.CreateUser(s.t.Context(), db.CreateUserParams{
+		Username:     util.RandomOwner(),
+		FullName:     util.RandomOwner(),
+		Email:        util.RandomOwner() + "@example.com",
+		PasswordHash: string(hash),
+	})
+	require.NoError(s.t, err)
+
+	_, err = s.store.UpdateUserRole(s.t.Context(), db.UpdateUserRoleParams{Username: user.Username, Role: role})
+	require.NoError(s.t, err)
+	return user.Username
+}
+
+func (s *throttleTestServer) login(username, password, clientIP string) *httptest.ResponseRecorder {
+	body, err := json.Marshal(getUserRequest{Username: username, Password: password})
+	require.NoError(s.t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/v2/users/login", bytes.NewReader(body))
+	require.NoError(s.t, err)
+	request.RemoteAddr = clientIP + ":4321"
+	s.server.Router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestLoginBackoff(t *testing.T) {
+	s := newThrottleTestServer(t, func(*util.Config) {})
+	username := s.createUser(db.UserRoleCustomer)
+
+	// Unknown usernames and wrong passwords fail alike.
+	unknown := s.login(util.RandomOwner(), "password1", "192.0.2.1")
+	require.Equal(t, http.StatusUnauthorized, unknown.Code)
+	for range 3 {
+		recorder := s.login(username, "wrong password", "192.0.2.1")
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		require.Equal(t, decodeBody(t, unknown)["Error"], decodeBody(t, recorder)["Error"])
+	}
+
+	// After three failures the next attempt must wait, even with the right password.
+	recorder := s.login(username, "password1", "192.0.2.2")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, time.Hour.Seconds(), retryAfter, 5)
+
+	// Other users are not held up.
+	require.Equal(t, http.StatusAccepted, s.login(s.createUser(db.UserRoleCustomer), "password1", "192.0.2.1").Code)
+}
+
+func TestLoginClearsFailures(t *testing.T) {
+	s := newThrottleTestServer(t, func(*util.Config) {})
+	username := s.createUser(db.UserRoleCustomer)
+
+	for range 2 {
+		require.Equal(t, http.StatusUnauthorized, s.login(username, "wrong password", "192.0.2.1").Code)
+	}
+	require.Equal(t, http.StatusAccepted, s.login(username, "password1", "192.0.2.1").Code)
+	for range 2 {
+		require.Equal(t, http.StatusUnauthorized, s.login(username, "wrong password", "192.0.2.1").Code)
+	}
+	require.Equal(t, http.StatusAccepted, s.login(username, "password1", "192.0.2.1").Code)
+}
+
+func TestLoginIPBackoff(t *testing.T) {
+	s := newThrottleTestServer(t, func(config *util.Config) {
+		config.LoginIPBackoffAfter = 3
+	})
+
+	// One client guessing the passwords of many users.
+	for range 3 {
+		require.Equal(t, http.StatusUnauthorized, s.login(s.createUser(db.UserRoleCustomer), "wrong password", "192.0.2.1").Code)
+	}
+	username := s.createUser(db.UserRoleCustomer)
+	require.Equal(t, http.StatusTooManyRequests, s.login(username, "password1", "192.0.2.1").Code)
+	require.Equal(t, http.StatusAccepted, s.login(username, "password1", "192.0.2.2").Code)
+
+	// Logging in to its own account does not reset the client's count.
+	require.Equal(t, http.StatusTooManyRequests, s.login(username, "password1", "192.0.2.1").Code)
+}
+
+func TestLoginIPBackoffForwardedFor(t *testing.T) {
+	s := newThrottleTestServer(t, func(config *util.Config) {
+		config.LoginIPBackoffAfter = 3
+	})
+
+	// A client that names a new address on every attempt is still counted by its own.
+	login := func(username, password, forwardedFor string) int {
+		body, err := json.Marshal(getUserRequest{Username: username, Password: password})
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/v2/users/login", bytes.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		request.RemoteAddr = "192.0.2.1:4321"
+		s.server.Router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	for i := range 3 {
+		require.Equal(t, http.StatusUnauthorized, login(s.createUser(db.UserRoleCustomer), "wrong password", fmt.Sprintf("198.51.100.%d", i+1)))
+	}
+	require.Equal(t, http.StatusTooManyRequests, login(s.createUser(db.UserRoleCustomer), "password1", "198.51.100.9"))
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newThrottleTestServer(t, func(config *util.Config) {
+		config.LoginBackoffAfter = 10
+		config.LoginLockoutAfter = 3
+	})
+	username := s.createUser(db.UserRoleCustomer)
+	admin := s.createUser(db.UserRoleAdmin)
+
+	for range 3 {
+		require.Equal(t, http.StatusUnauthorized, s.login(username, "wrong password", "192.0.2.1").Code)
+	}
+	recorder := s.login(username, "password1", "192.0.2.2")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Contains(t, decodeBody(t, recorder)["Error"], "locked until")
+
+	var event map[string]any
+	for line := range bytes.Lines(s.logs.Bytes()) {
+		require.NoError(t, json.Unmarshal(line, &event))
+		if event["event"] == "login.locked" {
+			break
+		}
+	}
+	require.Equal(t, "audit", event["msg"])
+	require.Equal(t, "login.locked", event["event"])
+	require.Equal(t, username, event["user"])
+	require.Equal(t, "192.0.2.1", event["client_ip"])
+
+	unlock := func(username string) int {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodDelete, "/v2/users/"+username+"/lockout", nil)
+		require.NoError(t, err)
+		addRoleAuthorization(t, request, s.server.TokenMaker, admin, db.UserRoleAdmin)
+		s.server.Router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	require.Equal(t, http.StatusNotFound, unlock(util.RandomOwner()))
+	s.logs.Reset()
+	require.Equal(t, http.StatusNoContent, unlock(username))
+	require.Contains(t, s.logs.String(), `"event":"login.unlocked"`)
+	require.Contains(t, s.logs.String(), `"actor":"`+admin+`"`)
+
+	require.Equal(t, http.StatusAccepted, s.login(username, "password1", "192.0.2.1").Code)
+}
+
+func TestLoginFailureAudit(t *testing.T) {
+	s := newThrottleTestServer(t, func(config *util.Config) {
+		config.LoginBackoffAfter = 5
+	})
+	username := s.createUser(db.UserRoleCustomer)
+
+	for range 5 {
+		require.Equal(t, http.StatusUnauthorized, s.login(username, "wrong password", "192.0.2.1").Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, s.login(username, "wrong password", "192.0.2.1").Code)
+
+	// Every failure is logged, but only the first and the one that starts the backoff are
+	// in the audit log, and the throttled attempt is in neither.
+	require.Equal(t, 5, strings.Count(s.logs.String(), `"event":"login.failed"`))
+	entries, err := s.store.ListAuditLog(t.Context(), db.ListAuditLogParams{
+		Action:     pgtype.Text{String: "login.failed", Valid: true},
+		ResourceID: pgtype.Text{String: username, Valid: true},
+		LimitCount: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.JSONEq(t, `{"failures":1}`, string(entries[0].After))
+	require.JSONEq(t, `{"failures":5}`, string(entries[1].After))
+}
+
+func TestChangePasswordBackoff(t *testing.T) {
+	s := newThrottleTestServer(t, func(*util.Config) {})
+	username := s.createUser(db.UserRoleCustomer)
+
+	change := func(oldPassword string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(changePasswordRequest{OldPassword: oldPassword, NewPassword: "second password"})
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPut, "/v2/users/me/password", bytes.NewReader(body))
+		require.NoError(t, err)
+		request.RemoteAddr = "192.0.2.1:4321"
+		addRoleAuthorization(t, request, s.server.TokenMaker, username, db.UserRoleCustomer)
+		s.server.Router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Guessing the old password with an access token counts against the login throttle.
+	for range 3 {
+		require.Equal(t, http.StatusUnauthorized, change("wrong password").Code)
+	}
+	recorder := change("password1")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusTooManyRequests, s.login(username, "password1", "192.0.2.2").Code)
+}
+
+func TestGRPCLoginBackoff(t *testing.T) {
+	s := newThrottleTestServer(t, func(*util.Config) {})
+	username := s.createUser(db.UserRoleCustomer)
+	client := newTestGRPCClient(t, s.server)
+
+	for range 3 {
+		_, err := client.LoginUser(t.Context(), &pb.LoginUserRequest{Username: username, Password: "wrong password"})
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+	_, err := client.LoginUser(t.Context(), &pb.LoginUserRequest{Username: username, Password: "password1"})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/token"
+	"example.com/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// totpCodeHeaderKey carries the fresh TOTP code that high-risk requests of users enrolled
+// in two-factor authentication must present.
+const totpCodeHeaderKey = "X-TOTP-Code"
+
+var (
+	errTOTPDisabled       = errors.New("two-factor authentication is not configured on this server")
+	errTOTPNotPending     = errors.New("no two-factor enrollment to confirm")
+	errTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	errTOTPRequired       = errors.New("a fresh TOTP code is required in the " + totpCodeHeaderKey + " header")
+	errInvalidTOTPCode    = errors.New("invalid or already used code")
+)
+
+type enrollTOTPResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// QRCodePNG is encoded in base64.
+	QRCodePNG []byte `json:"qr_code_png"`
+}
+
+// EnrollTOTP creates a new TOTP secret for the caller. Two-factor authentication is only
+// turned on by ConfirmTOTP, once the user shows a code from their app. A user who is
+// already enrolled needs a fresh code to replace their secret.
+func (server *Server) EnrollTOTP(c *gin.Context) {
+	if server.totpCipher == nil {
+		c.JSON(errorStatus(errTOTPDisabled), errorResponse(c, errTOTPDisabled))
+		return
+	}
+
+	username := authPayload(c).Username
+	if err := server.requireFreshTOTP(c, username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	enrollment, err := totp.Enroll(server.Config.TOTPIssuer, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	sealed, err := server.totpCipher.Seal(username, enrollment.Secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	_, err = server.Store.SetUserTOTPSecret(c, db.SetUserTOTPSecretParams{Username: username, TotpSecret: sealed})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.URI,
+		QRCodePNG:       enrollment.QRCodePNG,
+	})
+}
+
+type totpCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type confirmTOTPResponse struct {
+	// BackupCodes each log in once without the app. They are not shown again.
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// ConfirmTOTP turns on two-factor authentication with a code from the secret that
+// EnrollTOTP created, and returns the user's backup codes.
+func (server *Server) ConfirmTOTP(c *gin.Context) {
+	var req totpCodeRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	if server.totpCipher == nil {
+		c.JSON(errorStatus(errTOTPDisabled), errorResponse(c, errTOTPDisabled))
+		return
+	}
+
+	user, err := server.Store.GetUser(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+	switch {
+	case user.TotpEnabledAt.Valid:
+		c.JSON(errorStatus(errTOTPAlreadyEnabled), errorResponse(c, errTOTPAlreadyEnabled))
+		return
+	case len(user.TotpSecret) == 0:
+		c.JSON(errorStatus(errTOTPNotPending), errorResponse(c, errTOTPNotPending))
+		return
+	}
+
+	secret, err := server.totpCipher.Open(user.Username, user.TotpSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		c.JSON(errorStatus(errInvalidTOTPCode), errorResponse(c, errInvalidTOTPCode))
+		return
+	}
+
+	backupCodes := totp.BackupCodes()
+	hashes := make([]string, len(backupCodes))
+	for i, code := range backupCodes {
+		hashes[i] = totp.HashBackupCode(code)
+	}
+
+	_, err = server.Store.EnableTOTPTx(c, db.EnableTOTPTxParams{
+		Username:         user.Username,
+		Step:             step,
+		BackupCodeHashes: hashes,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, confirmTOTPResponse{BackupCodes: backupCodes})
+}
+
+type loginTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code is a code from the user's app or one of their backup codes.
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginUserTOTP is the second step of logging in for users enrolled in two-factor
+// authentication: it trades the challenge token from LoginUser and a code for an access
+// token.
+func (server *Server) LoginUserTOTP(c *gin.Context) {
+	var req loginTOTPRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	payload, err := server.TokenMaker.VerifyPurposeToken(req.ChallengeToken, token.PurposeTOTPChallenge)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, err))
+		return
+	}
+
+	// Wrong codes count as failed logins, so that the code cannot be guessed either.
+	if err := server.checkLoginThrottle(c, payload.Username, c.ClientIP()); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	user, err := server.Store.GetUser(c, payload.Username)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	if err := server.verifyLoginCode(c, user, req.Code); err != nil {
+		if errors.Is(err, errInvalidTOTPCode) {
+			if recordErr := server.recordLoginFailure(c, user.Username, c.ClientIP()); recordErr != nil {
+				err = recordErr
+			}
+		}
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
+
+	server.respondWithAccessToken(c, user)
+}
+
+// verifyTOTP checks a code from the user's app and uses up its time step, so that no code
+// is accepted twice.
+func (server *Server) verifyTOTP(ctx context.Context, user db.User, code string) error {
+	if server.totpCipher == nil {
+		return errTOTPDisabled
+	}
+	if !user.TotpEnabledAt.Valid {
+		return errInvalidTOTPCode
+	}
+
+	secret, err := server.totpCipher.Open(user.Username, user.TotpSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return errInvalidTOTPCode
+	}
+
+	used, err := server.Store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		Username:     user.Username,
+		TotpLastStep: pgtype.Int8{Int64: step, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errInvalidTOTPCode
+	}
+	return nil
+}
+
+// verifyLoginCode accepts a code from the user's app or, failing that, one of their unused
+// backup codes, which it uses up.
+func (server *Server) verifyLoginCode(ctx context.Context, user db.User, code string) error {
+	err := server.verifyTOTP(ctx, user, code)
+	if !errors.Is(err, errInvalidTOTPCode) || !user.TotpEnabledAt.Valid {
+		return err
+	}
+
+	used, err := server.Store.UseTOTPBackupCode(ctx, db.UseTOTPBackupCodeParams{
+		Username: user.Username,
+		CodeHash: totp.HashBackupCode(code),
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errInvalidTOTPCode
+	}
+	return nil
+}
+
+// requireFreshTOTP guards a high-risk operation: a user enrolled in two-factor
+// authentication must present a current code from their app with it. Backup codes are
+// only good for logging in. Users who are not enrolled pass. A throttled user is refused
+// without the code being checked; see authenticate.
+func (server *Server) requireFreshTOTP(ctx context.Context, username, code string) error {
+	user, err := server.Store.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !user.TotpEnabledAt.Valid {
+		return nil
+	}
+	if code == "" {
+		return errTOTPRequired
+	}
+
+	// Wrong codes count as failed logins, as at LoginUserTOTP, so that a stolen access token
+	// or API key cannot be used to guess the code.
+	clientIP := db.AuditActorFrom(ctx).ClientIP
+	if err := server.checkLoginThrottle(ctx, username, clientIP); err != nil {
+		return err
+	}
+
+	err = server.verifyTOTP(ctx, user, code)
+	if errors.Is(err, errInvalidTOTPCode) {
+		if recordErr := server.recordLoginFailure(ctx, username, clientIP); recordErr != nil {
+			return recordErr
+		}
+	}
+	return err
+}
+
+// isHighRiskTransfer reports whether a transfer is large enough to need a fresh TOTP code.
+func (server *Server) isHighRiskTransfer(amount int64) bool {
+	return amount > server.Config.HighRiskTransferAmount
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/db/memstore"
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/totp"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTOTPUser returns a user enrolled in two-factor authentication, with their secret
+// sealed under testTOTPKey, and the secret.
+func newTOTPUser(t *testing.T, username string) (db.User, string) {
+	enrollment, err := totp.Enroll("Simple Bank", username)
+	require.NoError(t, err)
+
+	cipher, err := totp.NewCipher(testTOTPKey)
+	require.NoError(t, err)
+	sealed, err := cipher.Seal(username, enrollment.Secret)
+	require.NoError(t, err)
+
+	return db.User{
+		Username:      username,
+		Role:          db.UserRoleCustomer,
+		TotpSecret:    sealed,
+		TotpEnabledAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}, enrollment.Secret
+}
+
+// currentTOTPCode returns the code of secret offset steps from now. Each step's code is
+// accepted once, so a test that needs several codes takes them from neighbouring steps.
+func currentTOTPCode(t *testing.T, secret string, offset int64) string {
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+// TestTOTPFlow enrolls a user against the in-memory store, logs in with a code and a
+// backup code, and checks that codes are not accepted twice.
+func TestTOTPFlow(t *testing.T) {
+	store := memstore.New()
+	server := newTestServer(t, newTestConfig(), store)
+
+	username, password := util.RandomOwner(), util.RandomString(8)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = store.CreateUser(t.Context(), db.CreateUserParams{Username: username, FullName: "Test User", Email: "test@example.com", PasswordHash: string(hash)})
+	require.NoError(t, err)
+
+	call := func(method, path, body string, headers map[string]string, response any) int {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		server.Router.ServeHTTP(recorder, request)
+		if response != nil && recorder.Code < 300 {
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response))
+		}
+		return recorder.Code
+	}
+	login := func() loginTOTPChallengeResponse {
+		var challenge loginTOTPChallengeResponse
+		code := call(http.MethodPost, "/v2/users/login", fmt.Sprintf(`{"username":%q,"password":%q}`, username, password), nil, &challenge)
+		require.Equal(t, http.StatusAccepted, code)
+		return challenge
+	}
+	loginTOTP := func(challengeToken, code string) (int, loginUserResponse) {
+		var response loginUserResponse
+		status := call(http.MethodPost, "/v2/users/login/totp", fmt.Sprintf(`{"challenge_token":%q,"code":%q}`, challengeToken, code), nil, &response)
+		return status, response
+	}
+
+	auth := map[string]string{authorizationHeaderKey: "Bearer " + createTestToken(t, server, username)}
+
+	// Confirming before enrolling has nothing to confirm.
+	require.Equal(t, http.StatusConflict, call(http.MethodPost, "/v2/users/me/totp/confirm", `{"code":"123456"}`, auth, nil))
+
+	var enrollment enrollTOTPResponse
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/v2/users/me/totp", "", auth, &enrollment))
+	require.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/")
+	require.Equal(t, []byte("\x89PNG"), enrollment.QRCodePNG[:4])
+
+	// The enrollment is pending until it is confirmed: logging in still takes a password.
+	var pending loginUserResponse
+	call(http.MethodPost, "/v2/users/login", fmt.Sprintf(`{"username":%q,"password":%q}`, username, password), nil, &pending)
+	require.NotEmpty(t, pending.AccessToken)
+
+	require.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/v2/users/me/totp/confirm", `{"code":"000000x"}`, auth, nil))
+
+	var confirmed confirmTOTPResponse
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/v2/users/me/totp/confirm",
+		fmt.Sprintf(`{"code":%q}`, currentTOTPCode(t, enrollment.Secret, -1)), auth, &confirmed))
+	require.Len(t, confirmed.BackupCodes, totp.BackupCodeCount)
+	require.Equal(t, http.StatusConflict, call(http.MethodPost, "/v2/users/me/totp/confirm",
+		fmt.Sprintf(`{"code":%q}`, currentTOTPCode(t, enrollment.Secret, 0)), auth, nil))
+
+	// The password alone now only gets a challenge, which is not an access token.
+	challenge := login()
+	require.True(t, challenge.TOTPRequired)
+	require.NotEmpty(t, challenge.ChallengeToken)
+	challengeAuth := map[string]string{authorizationHeaderKey: "Bearer " + challenge.ChallengeToken}
+	require.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/v2/accounts", "", challengeAuth, nil))
+
+	// The code that confirmed the enrollment is used up.
+	status, _ := loginTOTP(challenge.ChallengeToken, currentTOTPCode(t, enrollment.Secret, -1))
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	code := currentTOTPCode(t, enrollment.Secret, 0)
+	status, loggedIn := loginTOTP(challenge.ChallengeToken, code)
+	require.Equal(t, http.StatusAccepted, status)
+	require.NotEmpty(t, loggedIn.AccessToken)
+	require.True(t, loggedIn.User.TOTPEnabled)
+
+	status, _ = loginTOTP(login().ChallengeToken, code)
+	require.Equal(t, http.StatusUnauthorized, status, "replayed code")
+
+	// Backup codes work once, with any case and separators.
+	status, _ = loginTOTP(login().ChallengeToken, "nope-nope")
+	require.Equal(t, http.StatusUnauthorized, status)
+	status, _ = loginTOTP(login().ChallengeToken, confirmed.BackupCodes[0])
+	require.Equal(t, http.StatusAccepted, status)
+	status, _ = loginTOTP(login().ChallengeToken, confirmed.BackupCodes[0])
+	require.Equal(t, http.StatusUnauthorized, status, "reused backup code")
+
+	// An access token is not a challenge token.
+	status, _ = loginTOTP(loggedIn.AccessToken, currentTOTPCode(t, enrollment.Secret, 1))
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// Enrolling again, and other high-risk operations, need a fresh code and no backup code.
+	require.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/v2/users/me/totp", "", auth, nil))
+	withCode := map[string]string{authorizationHeaderKey: auth[authorizationHeaderKey], totpCodeHeaderKey: confirmed.BackupCodes[1]}
+	require.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/v2/users/me/totp", "", withCode, nil))
+	withCode[totpCodeHeaderKey] = currentTOTPCode(t, enrollment.Secret, 1)
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/v2/users/me/totp", "", withCode, nil))
+}
+
+func createTestToken(t *testing.T, server *Server, username string) string {
+	accessToken, _, err := server.TokenMaker.CreateToken(username, string(db.UserRoleCustomer), time.Minute)
+	require.NoError(t, err)
+	return accessToken
+}
+
+func TestEnrollTOTPDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().SetUserTOTPSecret(gomock.Any(), gomock.Any()).Times(0)
+
+	config := newTestConfig()
+	config.TOTPEncryptionKey = ""
+	server := newTestServer(t, config, mockStore)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/v2/users/me/totp", nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.TokenMaker, util.RandomOwner())
+
+	server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	requireBodyMatchError(t, recorder)
+}
+
+func TestHighRiskTransfer(t *testing.T) {
+	fromAccount := createAccountWithId(1)
+	toAccount := createAccountWithId(2)
+	toAccount.Currency = fromAccount.Currency
+	totpUser, secret := newTOTPUser(t, fromAccount.Owner)
+
+	testCases := []struct {
+		Name          string
+		Amount        int64
+		Code          string
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name:   "Below Threshold",
+			Amount: 1000,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+			},
+		},
+		{
+			Name:   "Not Enrolled",
+			Amount: 1001,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).Times(1).Return(db.User{Username: fromAccount.Owner}, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+			},
+		},
+		{
+			Name:   "TOTP Code",
+			Amount: 1001,
+			Code:   currentTOTPCode(t, secret, 0),
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).Times(1).Return(totpUser, nil)
+				ms.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+			},
+		},
+		{
+			Name:   "TOTP Code Required",
+			Amount: 1001,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).Times(1).Return(totpUser, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:   "Wrong TOTP Code",
+			Amount: 1001,
+			Code:   "000000",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(fromAccount.Owner)).Times(1).Return(totpUser, nil)
+				ms.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			body := fmt.Sprintf(`{"from_account_id":%d,"to_account_id":%d,"amount":%d,"currency":%q}`, fromAccount.ID, toAccount.ID, tc.Amount, fromAccount.Currency)
+			request, err := http.NewRequest(http.MethodPost, "/v1/transfers", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.TokenMaker, fromAccount.Owner)
+			if tc.Code != "" {
+				request.Header.Set(totpCodeHeaderKey, tc.Code)
+			}
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestStepUpTOTPBackoff(t *testing.T) {
+	s := newPasswordTestServer(t)
+	username := util.RandomOwner()
+	s.signUp(username, username+"@example.com")
+
+	totpUser, secret := newTOTPUser(t, username)
+	_, err := s.store.SetUserTOTPSecret(t.Context(), db.SetUserTOTPSecretParams{Username: username, TotpSecret: totpUser.TotpSecret})
+	require.NoError(t, err)
+	_, err = s.store.EnableTOTPTx(t.Context(), db.EnableTOTPTxParams{Username: username, Step: totp.Step(totpUser.TotpEnabledAt.Time) - 2})
+	require.NoError(t, err)
+
+	accessToken := createTestToken(t, s.server, username)
+	export := func(code string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/v2/users/me/export", nil)
+		require.NoError(t, err)
+		request.Header.Set(authorizationHeaderKey, "Bearer "+accessToken)
+		request.Header.Set(totpCodeHeaderKey, code)
+		s.server.Router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Wrong step-up codes count as failed logins, so after LoginBackoffAfter of them even
+	// the right code must wait.
+	for range s.server.Config.LoginBackoffAfter {
+		require.Equal(t, http.StatusUnauthorized, export(currentTOTPCode(t, secret, 10)).Code)
+	}
+	recorder := export(currentTOTPCode(t, secret, 0))
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	code, _ := s.login(username, "first password")
+	require.Equal(t, http.StatusTooManyRequests, code)
+}
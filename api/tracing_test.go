@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	account := randomAccount()
+
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).
+		DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+			// The store sees the request span, so its queries join the caller's trace.
+			span := trace.SpanContextFromContext(ctx)
+			require.Equal(t, traceID, span.TraceID().String())
+			return db.Account{}, pgx.ErrNoRows
+		})
+
+	server := newTestServer(t, newTestConfig(), mockStore)
+	httpRecorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/accounts/%d", account.ID), nil)
+	require.NoError(t, err)
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	addAuthorization(t, request, server.TokenMaker, account.Owner)
+
+	server.Router.ServeHTTP(httpRecorder, request)
+	require.Equal(t, http.StatusNotFound, httpRecorder.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	require.Equal(t, "GET /v1/accounts/:id", span.Name())
+	require.Equal(t, trace.SpanKindServer, span.SpanKind())
+	require.Equal(t, traceID, span.SpanContext().TraceID().String())
+	require.True(t, span.Parent().IsRemote())
+	require.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+	require.Contains(t, span.Attributes(), attribute.String(requestIDKey, httpRecorder.Header().Get(requestIDHeader)))
+}
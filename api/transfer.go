@@ -13,17 +13,44 @@ import (
 type RequestParams struct {
 	FromAccountId int64  `json:"from_account_id"`
 	ToAccountId   int64  `json:"to_account_id"`
-	Amount        int64  `json:"amount"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
 	Currency      string `json:"currency" binding:"currency,required"`
 }
 
+// transferResponse is the payer's side of a transfer. The payee's account and entry are
+// not the caller's to see, as in the gRPC response.
+type transferResponse struct {
+	Transfer    db.Transfer `json:"transfer"`
+	FromAccount db.Account  `json:"from_account"`
+	FromEntry   db.Entry    `json:"from_entry"`
+}
+
 func (server *Server) CreateTransfer(c *gin.Context) {
 	var payload RequestParams
 	if err := c.ShouldBindBodyWithJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	fromAccount, err := server.Store.GetAccount(c, payload.FromAccountId)
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
 		return
 	}
 
+	// Customers move money out of their own accounts; tellers make assisted transfers
+	// out of anyone's.
+	if !authorizeAccount(c, fromAccount, permTransferAny) {
+		return
+	}
+
+	if server.isHighRiskTransfer(payload.Amount) {
+		if err := server.requireFreshTOTP(c, authPayload(c).Username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+			loginErrorResponse(c, err)
+			return
+		}
+	}
+
 	ok := server.IsSameCurrency(payload.ToAccountId, payload.Currency)
 
 	if !ok {
@@ -31,18 +58,22 @@ func (server *Server) CreateTransfer(c *gin.Context) {
 		return
 	}
 
-	transfer, err := server.Store.TransferTx(c, db.TransferTxParams{
+	result, err := server.Store.TransferTx(c, db.TransferTxParams{
 		FromAccountId: payload.FromAccountId,
 		ToAccountId: payload.ToAccountId,
 		Amount: payload.Amount,
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		c.JSON(errorStatus(err), errorResponse(c, err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, transfer)
+	c.JSON(http.StatusCreated, transferResponse{
+		Transfer:    result.Transfer,
+		FromAccount: result.FromAccount,
+		FromEntry:   result.FromEntry,
+	})
 
 }
 
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/db/mock"
+	"example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateTransfer(t *testing.T) {
+	fromAccount := createAccountWithId(1)
+	toAccount := createAccountWithId(2)
+	toAccount.Currency = fromAccount.Currency
+
+	testCases := []struct {
+		Name          string
+		Currency      string
+		Username      string
+		Role          db.UserRole
+		Amount        int64 // 10 if zero
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "Created",
+			Currency: fromAccount.Currency,
+			Username: fromAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+					FromAccountId: fromAccount.ID,
+					ToAccountId:   toAccount.ID,
+					Amount:        10,
+				})).Times(1).Return(db.TransferTxResult{
+					Transfer:    db.Transfer{ID: 7, FromAccountID: fromAccount.ID, ToAccountID: toAccount.ID},
+					FromAccount: fromAccount,
+					ToAccount:   toAccount,
+					FromEntry:   db.Entry{ID: 8, AccountID: fromAccount.ID},
+					ToEntry:     db.Entry{ID: 9, AccountID: toAccount.ID},
+				}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+
+				// The payee's account and entry are left out.
+				var response map[string]json.RawMessage
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+				require.Len(t, response, 3)
+				require.Contains(t, response, "transfer")
+				require.Contains(t, response, "from_account")
+				require.Contains(t, response, "from_entry")
+			},
+		},
+		{
+			Name:     "Teller Assisted",
+			Currency: fromAccount.Currency,
+			Username: util.RandomOwner(),
+			Role:     db.UserRoleTeller,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+			},
+		},
+		{
+			Name:     "Not Account Owner",
+			Currency: fromAccount.Currency,
+			Username: toAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "No Authorization",
+			Currency: fromAccount.Currency,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Insufficient Funds",
+			Currency: fromAccount.Currency,
+			Username: fromAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Account Frozen",
+			Currency: fromAccount.Currency,
+			Username: fromAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrAccountFrozen)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Internal Server Error",
+			Currency: fromAccount.Currency,
+			Username: fromAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, fmt.Errorf("database connection failed"))
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Negative Amount",
+			Currency: fromAccount.Currency,
+			Username: fromAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			Amount:   -10,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name:     "Currency Mismatch",
+			Currency: "XYZ",
+			Username: fromAccount.Owner,
+			Role:     db.UserRoleCustomer,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(RequestParams{
+				FromAccountId: fromAccount.ID,
+				ToAccountId:   toAccount.ID,
+				Amount:        cmp.Or(tc.Amount, 10),
+				Currency:      tc.Currency,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/v1/transfers", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			if tc.Username != "" {
+				addRoleAuthorization(t, request, server.TokenMaker, tc.Username, tc.Role)
+			}
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
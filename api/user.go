@@ -1,11 +1,15 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	db "example.com/db/sqlc"
+	"example.com/token"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -13,7 +17,7 @@ import (
 type CreateUserRequest struct {
 	Username     string `json:"username" binding:"required"`
     FullName     string `json:"full_name" binding:"required"`
-    Email        string `json:"email" binding:"required"`
+    Email        string `json:"email" binding:"required,email"`
     Password 	 string `json:"password" binding:"required"`
 }
 
@@ -21,75 +25,198 @@ func (server *Server) CreateUser(c *gin.Context) {
 	var payload CreateUserRequest
 
 	if err := c.ShouldBindBodyWithJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
-
+	user, err := server.createUser(c, payload)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(errorStatus(err), errorResponse(c, err))
 		return
 	}
 
-	user, err := server.Store.CreateUser(c, db.CreateUserParams{
-		Username:    payload.Username,
-		FullName:  	payload.FullName,
-		Email:   payload.Email,
+	c.JSON(http.StatusCreated, newUserResponse(user))
+
+}
+
+func (server *Server) createUser(ctx context.Context, req CreateUserRequest) (db.User, error) {
+	if err := server.passwordPolicy.Check(req.Password); err != nil {
+		return db.User{}, invalidArgument(fmt.Errorf("%w: %w", errPasswordPolicyFail, err))
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return db.User{}, invalidArgument(err)
+	}
+
+	user, err := server.Store.CreateUserTx(ctx, db.CreateUserParams{
+		Username:     req.Username,
+		FullName:     req.FullName,
+		Email:        req.Email,
 		PasswordHash: string(hashedPassword),
 	})
-
 	if err != nil {
-		if pgErr, ok  := err.(*pgconn.PgError); ok {
-			switch pgErr.Code {
-				case "23505":
-				c.JSON(http.StatusForbidden, errorResponse(pgErr))
-				return
-			}
-		}
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+		return db.User{}, err
+	}
+	server.logAudit(ctx, "user.created", "user", user.Username, "role", user.Role)
+
+	// The user can ask for another code; a lost one must not fail the sign-up.
+	if _, err := server.sendEmailVerification(ctx, user); err != nil {
+		server.Logger.ErrorContext(ctx, "cannot send email verification", "user", user.Username, "error", err)
 	}
+	return user, nil
+}
 
-	c.JSON(http.StatusCreated, user)
+type userResponse struct {
+	Username          string             `json:"username"`
+	FullName          string             `json:"full_name"`
+	Email             string             `json:"email"`
+	EmailVerifiedAt   pgtype.Timestamptz `json:"email_verified_at"`
+	Role              db.UserRole        `json:"role"`
+	TOTPEnabled       bool               `json:"totp_enabled"`
+	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+}
 
+func newUserResponse(user db.User) userResponse {
+	return userResponse{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		EmailVerifiedAt:   user.EmailVerifiedAt,
+		Role:              user.Role,
+		TOTPEnabled:       user.TotpEnabledAt.Valid,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CraetedAt,
+	}
 }
 
 
 type getUserRequest struct {
-	Username string `form:"username" binding:"required,min=1"`
-	Password string `form:"password" binding:"required,min=1"`
+	Username string `form:"username" json:"username" binding:"required,min=1"`
+	Password string `form:"password" json:"password" binding:"required,min=1"`
 }
 
+// GetUser logs a user in with the credentials in the query string (v1).
 func (server *Server) GetUser(c *gin.Context) {
 
 
 	var req getUserRequest
 
 	if err := c.BindQuery(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
 		return
 	}
 
-	// fmt.Printf("%+v\n",req)
+	server.loginUser(c, req)
+}
 
-	user, err := server.Store.GetUser(c, req.Username)
+// LoginUser logs a user in with the credentials in a JSON body (v2).
+func (server *Server) LoginUser(c *gin.Context) {
+	var req getUserRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	server.loginUser(c, req)
+}
+
+func (server *Server) loginUser(c *gin.Context, req getUserRequest) {
+	user, err := server.authenticate(c, req.Username, req.Password, c.ClientIP())
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		loginErrorResponse(c, err)
+		return
+	}
+
+	// Users enrolled in two-factor authentication finish logging in at LoginUserTOTP.
+	if user.TotpEnabledAt.Valid {
+		challengeToken, payload, err := server.TokenMaker.CreatePurposeToken(user.Username, token.PurposeTOTPChallenge, server.Config.TOTPChallengeDuration)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+			return
+		}
+
+		c.JSON(http.StatusAccepted, loginTOTPChallengeResponse{
+			TOTPRequired:            true,
+			ChallengeToken:          challengeToken,
+			ChallengeTokenExpiresAt: payload.ExpiredAt,
+		})
 		return
 	}
 
+	server.respondWithAccessToken(c, user)
+}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+// respondWithAccessToken completes a login, records it in the audit log and forgets the
+// user's failed attempts.
+func (server *Server) respondWithAccessToken(c *gin.Context, user db.User) {
+	if err := server.clearLoginFailures(c, user.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
 
+	accessToken, payload, err := server.TokenMaker.CreateToken(user.Username, string(user.Role), server.Config.AccessTokenDuration)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, errorResponse(err))
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
 		return
 	}
+	server.auditLogin(c, user)
+
+	c.JSON(http.StatusAccepted, loginUserResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: payload.ExpiredAt,
+		User:                 newUserResponse(user),
+	})
+}
+
+type loginUserResponse struct {
+	AccessToken          string       `json:"access_token"`
+	AccessTokenExpiresAt time.Time    `json:"access_token_expires_at"`
+	User                 userResponse `json:"user"`
+}
+
+// loginTOTPChallengeResponse is the answer to a correct password of a user enrolled in
+// two-factor authentication.
+type loginTOTPChallengeResponse struct {
+	TOTPRequired            bool      `json:"totp_required"`
+	ChallengeToken          string    `json:"challenge_token"`
+	ChallengeTokenExpiresAt time.Time `json:"challenge_token_expires_at"`
+}
 
+type usernameRequest struct {
+	Username string `uri:"username" binding:"required"`
+}
 
+type updateUserRoleRequest struct {
+	Role db.UserRole `json:"role" binding:"required"`
+}
 
+// UpdateUserRole makes a user an admin, teller, auditor or customer. The access tokens the
+// user already has stop working, so the new role applies from their next login.
+func (server *Server) UpdateUserRole(c *gin.Context) {
+	var uri usernameRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var req updateUserRoleRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, errorResponse(c, fmt.Errorf("invalid role %q", req.Role)))
+		return
+	}
+
+	user, err := server.Store.UpdateUserRoleTx(c, db.UpdateUserRoleParams{Username: uri.Username, Role: req.Role})
+	if err != nil {
+		c.JSON(errorStatus(err), errorResponse(c, err))
+		return
+	}
 
-	c.JSON(http.StatusAccepted, user)
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
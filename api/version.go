@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion is a major version of the HTTP API. Every version is served under /v<N>;
+// the unversioned paths are legacy aliases of v1.
+type apiVersion int
+
+const (
+	apiV1 apiVersion = 1
+	apiV2 apiVersion = 2
+)
+
+var apiVersions = []apiVersion{apiV1, apiV2}
+
+const (
+	apiVersionKey    = "api_version"
+	apiVersionHeader = "API-Version"
+
+	deprecationHeader = "Deprecation"
+	sunsetHeader      = "Sunset"
+	linkHeader        = "Link"
+)
+
+// legacyRoutesDeprecatedAt is when the unversioned paths and the v1 login endpoint were deprecated.
+var legacyRoutesDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// acceptVersionPattern matches media types such as application/vnd.simplebank.v2+json.
+var acceptVersionPattern = regexp.MustCompile(`application/vnd\.simplebank\.v(\d+)\+json`)
+
+var (
+	errUnsupportedVersion = errors.New("unsupported API version")
+	errRouteRetired       = errors.New("this endpoint has been retired")
+)
+
+func (version apiVersion) prefix() string {
+	return fmt.Sprintf("/v%d", version)
+}
+
+func (version apiVersion) supported() bool {
+	for _, v := range apiVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// versionMiddleware resolves the version a request is served with. Routes under /v<N> are
+// pinned to that version and reject an Accept header asking for another one. Legacy
+// unversioned routes (pathVersion 0) negotiate the version through the Accept header
+// and fall back to v1.
+func versionMiddleware(pathVersion apiVersion) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := pathVersion
+
+		if match := acceptVersionPattern.FindStringSubmatch(c.GetHeader("Accept")); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			accepted := apiVersion(n)
+
+			if !accepted.supported() || (pathVersion != 0 && accepted != pathVersion) {
+				c.AbortWithStatusJSON(http.StatusNotAcceptable, errorResponse(c, errUnsupportedVersion))
+				return
+			}
+			version = accepted
+			c.Header("Vary", "Accept")
+		}
+
+		if version == 0 {
+			version = apiV1
+		}
+
+		c.Set(apiVersionKey, version)
+		c.Header(apiVersionHeader, strconv.Itoa(int(version)))
+		c.Next()
+	}
+}
+
+// requestVersion returns the version resolved by versionMiddleware, v1 if there is none.
+func requestVersion(c *gin.Context) apiVersion {
+	if version, ok := c.Get(apiVersionKey); ok {
+		return version.(apiVersion)
+	}
+	return apiV1
+}
+
+// deprecatedMiddleware marks a retired endpoint with Deprecation and Sunset headers
+// (RFC 9745, RFC 8594) and a link to its successor. Once the sunset has passed, the
+// endpoint answers 410 Gone.
+func deprecatedMiddleware(sunset time.Time, successor func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !time.Now().Before(sunset) {
+			c.AbortWithStatusJSON(http.StatusGone, errorResponse(c, errRouteRetired))
+			return
+		}
+
+		c.Header(deprecationHeader, fmt.Sprintf("@%d", legacyRoutesDeprecatedAt.Unix()))
+		c.Header(sunsetHeader, sunset.UTC().Format(http.TimeFormat))
+		if successor != nil {
+			c.Header(linkHeader, fmt.Sprintf(`<%s>; rel="successor-version"`, successor(c)))
+		}
+		c.Next()
+	}
+}
+
+// legacyAliasSuccessor points an unversioned path at the same path under /v1.
+func legacyAliasSuccessor(c *gin.Context) string {
+	return apiV1.prefix() + c.Request.URL.Path
+}
+
+// parseSunset reads a sunset date such as 2027-04-30. An empty value means there is
+// no deprecation window, so deprecated routes are not served at all.
+func parseSunset(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	sunset, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid DEPRECATED_ROUTES_SUNSET %q: %w", value, err)
+	}
+	return sunset, nil
+}
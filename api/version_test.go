@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAPIVersions(t *testing.T) {
+	account := randomAccount()
+	account.Balance = pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Valid: true}
+	account.OverdraftLimit = pgtype.Numeric{Int: big.NewInt(100), Exp: 0, Valid: true}
+
+	testCases := []struct {
+		Name          string
+		Path          string
+		Accept        string
+		Sunset        string
+		Stubbed       bool
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name:    "V1 Shape",
+			Path:    "/v1/accounts/%d",
+			Stubbed: true,
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				require.Equal(t, "1", rr.Header().Get(apiVersionHeader))
+				require.Empty(t, rr.Header().Get(deprecationHeader))
+
+				body := decodeBody(t, rr)
+				require.Equal(t, 123.45, body["balance"])
+			},
+		},
+		{
+			Name:    "V2 Shape",
+			Path:    "/v2/accounts/%d",
+			Stubbed: true,
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				require.Equal(t, "2", rr.Header().Get(apiVersionHeader))
+
+				body := decodeBody(t, rr)
+				require.Equal(t, "123.45", body["balance"])
+				require.Equal(t, "223.45", body["available_balance"])
+			},
+		},
+		{
+			Name:    "Legacy Alias",
+			Path:    "/accounts/%d",
+			Stubbed: true,
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				require.Equal(t, "1", rr.Header().Get(apiVersionHeader))
+				require.Equal(t, fmt.Sprintf("@%d", legacyRoutesDeprecatedAt.Unix()), rr.Header().Get(deprecationHeader))
+				require.NotEmpty(t, rr.Header().Get(sunsetHeader))
+				require.Equal(t, fmt.Sprintf(`</v1/accounts/%d>; rel="successor-version"`, account.ID), rr.Header().Get(linkHeader))
+			},
+		},
+		{
+			Name:    "Legacy Alias Negotiates V2",
+			Path:    "/accounts/%d",
+			Accept:  "application/vnd.simplebank.v2+json",
+			Stubbed: true,
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				require.Equal(t, "2", rr.Header().Get(apiVersionHeader))
+				require.Equal(t, "123.45", decodeBody(t, rr)["balance"])
+			},
+		},
+		{
+			Name:   "Conflicting Accept",
+			Path:   "/v1/accounts/%d",
+			Accept: "application/vnd.simplebank.v2+json",
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotAcceptable, rr.Code)
+			},
+		},
+		{
+			Name:   "Unknown Version",
+			Path:   "/accounts/%d",
+			Accept: "application/vnd.simplebank.v9+json",
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotAcceptable, rr.Code)
+			},
+		},
+		{
+			Name:   "Legacy Alias After Sunset",
+			Path:   "/accounts/%d",
+			Sunset: time.Now().AddDate(0, 0, -1).Format(time.DateOnly),
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusGone, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			if tc.Stubbed {
+				mockStore.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			}
+
+			config := newTestConfig()
+			if tc.Sunset != "" {
+				config.DeprecatedRoutesSunset = tc.Sunset
+			}
+			server := newTestServer(t, config, mockStore)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.Path, account.ID), nil)
+			require.NoError(t, err)
+			if tc.Accept != "" {
+				request.Header.Set("Accept", tc.Accept)
+			}
+			addAuthorization(t, request, server.TokenMaker, account.Owner)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginVersions(t *testing.T) {
+	password := "secret-password"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	user := db.User{Username: "alice", PasswordHash: string(hashedPassword)}
+
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().GetUser(gomock.Any(), user.Username).Times(2).Return(user, nil)
+	server := newTestServer(t, newTestConfig(), mockStore)
+
+	// v1 still logs in through the query string, flagged as deprecated.
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/v1/users?username=alice&password="+password, nil)
+	require.NoError(t, err)
+	server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get(deprecationHeader))
+	require.Equal(t, `</v2/users/login>; rel="successor-version"`, recorder.Header().Get(linkHeader))
+
+	// v2 replaces it with a JSON body.
+	recorder = httptest.NewRecorder()
+	body, err := json.Marshal(getUserRequest{Username: user.Username, Password: password})
+	require.NoError(t, err)
+	request, err = http.NewRequest(http.MethodPost, "/v2/users/login", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Empty(t, recorder.Header().Get(deprecationHeader))
+	require.NotEmpty(t, decodeBody(t, recorder)["access_token"])
+
+	// The query string login does not exist in v2.
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/v2/users?username=alice&password="+password, nil)
+	require.NoError(t, err)
+	server.Router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func decodeBody(t *testing.T, rr *httptest.ResponseRecorder) map[string]any {
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	return body
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	db "example.com/db/sqlc"
+	"example.com/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errWebhookNotFound = errors.New("webhook not found")
+
+type createWebhookSubscriptionRequest struct {
+	Url        string   `json:"url" binding:"required,url,webhook_url"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,webhook_event"`
+}
+
+// webhookSubscriptionResponse leaves out the signing secret, which is only shown once at creation.
+type webhookSubscriptionResponse struct {
+	ID         int64              `json:"id"`
+	Url        string             `json:"url"`
+	EventTypes []string           `json:"event_types"`
+	Active     bool               `json:"active"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type createWebhookSubscriptionResponse struct {
+	webhookSubscriptionResponse
+	Secret string `json:"secret"`
+}
+
+func newWebhookSubscriptionResponse(subscription db.WebhookSubscription) webhookSubscriptionResponse {
+	return webhookSubscriptionResponse{
+		ID:         subscription.ID,
+		Url:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
+
+func (server *Server) CreateWebhookSubscription(c *gin.Context) {
+	var req createWebhookSubscriptionRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	// A webhook sends account activity elsewhere, so adding one needs a fresh TOTP code.
+	if err := server.requireFreshTOTP(c, authPayload(c).Username, c.GetHeader(totpCodeHeaderKey)); err != nil {
+		loginErrorResponse(c, err)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	subscription, err := server.Store.CreateWebhookSubscription(c, db.CreateWebhookSubscriptionParams{
+		Username:   authPayload(c).Username,
+		Url:        req.Url,
+		Secret:     "whsec_" + hex.EncodeToString(secret),
+		EventTypes: req.EventTypes,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, createWebhookSubscriptionResponse{
+		webhookSubscriptionResponse: newWebhookSubscriptionResponse(subscription),
+		Secret:                      subscription.Secret,
+	})
+}
+
+func (server *Server) ListWebhookSubscriptions(c *gin.Context) {
+	subscriptions, err := server.Store.ListWebhookSubscriptions(c, authPayload(c).Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	response := make([]webhookSubscriptionResponse, len(subscriptions))
+	for i, subscription := range subscriptions {
+		response[i] = newWebhookSubscriptionResponse(subscription)
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+type webhookIDRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) DeleteWebhookSubscription(c *gin.Context) {
+	var req webhookIDRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	deleted, err := server.Store.DeleteWebhookSubscription(c, db.DeleteWebhookSubscriptionParams{
+		ID:       req.ID,
+		Username: authPayload(c).Username,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, errorResponse(c, errWebhookNotFound))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type listWebhookDeliveriesRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+func (server *Server) ListWebhookDeliveries(c *gin.Context) {
+	var uri webhookIDRequest
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	var req listWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	if _, ok := server.ownedWebhookSubscription(c, uri.ID); !ok {
+		return
+	}
+
+	deliveries, err := server.Store.ListWebhookDeliveries(c, db.ListWebhookDeliveriesParams{
+		SubscriptionID: uri.ID,
+		Limit:          req.PageSize,
+		Offset:         (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, deliveries)
+}
+
+// ReplayWebhookDelivery puts a delivery, typically a dead-lettered one, back in the queue with a fresh retry budget.
+func (server *Server) ReplayWebhookDelivery(c *gin.Context) {
+	var req webhookIDRequest
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, err))
+		return
+	}
+
+	delivery, err := server.Store.GetWebhookDelivery(c, req.ID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, errorResponse(c, errWebhookNotFound))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	if _, ok := server.ownedWebhookSubscription(c, delivery.SubscriptionID); !ok {
+		return
+	}
+
+	delivery, err = server.Store.ReplayWebhookDelivery(c, delivery.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// ownedWebhookSubscription loads a subscription of the authenticated user. Subscriptions of other
+// users are reported as not found. On failure it writes the response and returns false.
+func (server *Server) ownedWebhookSubscription(c *gin.Context, id int64) (db.WebhookSubscription, bool) {
+	subscription, err := server.Store.GetWebhookSubscription(c, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, errorResponse(c, errWebhookNotFound))
+			return subscription, false
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(c, err))
+		return subscription, false
+	}
+
+	if subscription.Username != authPayload(c).Username {
+		c.JSON(http.StatusNotFound, errorResponse(c, errWebhookNotFound))
+		return subscription, false
+	}
+
+	return subscription, true
+}
+
+var validWebhookEvent validator.Func = func(fl validator.FieldLevel) bool {
+	if eventType, ok := fl.Field().Interface().(string); ok {
+		return slices.Contains(db.EventTypes, eventType)
+	}
+	return false
+}
+
+// validWebhookURL accepts https URLs that do not name an internal address. Hostnames are
+// checked again by the dispatcher when it connects, after they are resolved.
+var validWebhookURL validator.Func = func(fl validator.FieldLevel) bool {
+	raw, ok := fl.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+
+	target, err := url.Parse(raw)
+	if err != nil || target.Scheme != "https" || target.Hostname() == "" {
+		return false
+	}
+	if ip, err := netip.ParseAddr(target.Hostname()); err == nil {
+		return worker.PublicWebhookAddress(ip)
+	}
+	return target.Hostname() != "localhost"
+}
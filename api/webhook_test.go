@@ -0,0 +1,243 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateWebhookSubscription(t *testing.T) {
+	username := util.RandomOwner()
+	totpUser, secret := newTOTPUser(t, username)
+
+	testCases := []struct {
+		Name          string
+		Body          string
+		SetupAuth     func(*testing.T, *http.Request, *Server)
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "Created",
+			Body: `{"url":"https://partner.example.com/hooks","event_types":["transfer.created","account.created"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.TokenMaker, username)
+			},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(db.User{Username: username}, nil)
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+						require.Equal(t, username, arg.Username)
+						require.True(t, strings.HasPrefix(arg.Secret, "whsec_"))
+						return db.WebhookSubscription{ID: 1, Username: arg.Username, Url: arg.Url, Secret: arg.Secret, EventTypes: arg.EventTypes, Active: true}, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				require.NotEmpty(t, body["secret"])
+			},
+		},
+		{
+			Name: "TOTP Code",
+			Body: `{"url":"https://partner.example.com/hooks","event_types":["transfer.created"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.TokenMaker, username)
+				request.Header.Set(totpCodeHeaderKey, currentTOTPCode(t, secret, 0))
+			},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(totpUser, nil)
+				ms.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(1).Return(db.WebhookSubscription{ID: 1, Username: username}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, rr.Code)
+			},
+		},
+		{
+			Name: "TOTP Code Required",
+			Body: `{"url":"https://partner.example.com/hooks","event_types":["transfer.created"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.TokenMaker, username)
+			},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(totpUser, nil)
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+		{
+			Name: "TOTP Code Replayed",
+			Body: `{"url":"https://partner.example.com/hooks","event_types":["transfer.created"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.TokenMaker, username)
+				request.Header.Set(totpCodeHeaderKey, currentTOTPCode(t, secret, 0))
+			},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(totpUser, nil)
+				ms.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), nil)
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+			},
+		},
+		{
+			Name: "Unknown Event Type",
+			Body: `{"url":"https://partner.example.com/hooks","event_types":["account.exploded"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.TokenMaker, username)
+			},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+			},
+		},
+		{
+			Name: "Internal URL",
+			Body: `{"url":"https://169.254.169.254/latest/meta-data","event_types":["transfer.created"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.TokenMaker, username)
+			},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, rr.Code)
+			},
+		},
+		{
+			Name:      "No Authorization",
+			Body:      `{"url":"https://partner.example.com/hooks","event_types":["transfer.created"]}`,
+			SetupAuth: func(t *testing.T, request *http.Request, server *Server) {},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, rr.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/v1/webhooks", bytes.NewBufferString(tc.Body))
+			require.NoError(t, err)
+			tc.SetupAuth(t, request, server)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestValidWebhookURL(t *testing.T) {
+	validate := validator.New()
+	require.NoError(t, validate.RegisterValidation("webhook_url", validWebhookURL))
+
+	for url, valid := range map[string]bool{
+		"https://partner.example.com/hooks": true,
+		"https://8.8.8.8:8443/hooks":        true,
+		"http://partner.example.com/hooks":  false,
+		"https://localhost/hooks":           false,
+		"https://127.0.0.1:8080/hooks":      false,
+		"https://[::1]/hooks":               false,
+		"https://10.0.0.5/hooks":            false,
+		"https://192.168.1.1/hooks":         false,
+		"https://169.254.169.254/latest":    false,
+		"https://0.0.0.0/hooks":             false,
+		"https://100.64.0.1/hooks":          false,
+		"https://[64:ff9b::7f00:1]/hooks":   false,
+		"https:///hooks":                    false,
+	} {
+		require.Equal(t, valid, validate.Var(url, "webhook_url") == nil, url)
+	}
+}
+
+func TestReplayWebhookDelivery(t *testing.T) {
+	owner := util.RandomOwner()
+	subscription := db.WebhookSubscription{ID: 3, Username: owner, Active: true}
+	delivery := db.WebhookDelivery{ID: 8, SubscriptionID: subscription.ID, Status: db.WebhookDeliveryStatusDead, Attempts: 8}
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		BuildStub     func(*mock.MockStore)
+		CheckResponse func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "Replayed",
+			Username: owner,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetWebhookDelivery(gomock.Any(), delivery.ID).Times(1).Return(delivery, nil)
+				ms.EXPECT().GetWebhookSubscription(gomock.Any(), subscription.ID).Times(1).Return(subscription, nil)
+				ms.EXPECT().ReplayWebhookDelivery(gomock.Any(), delivery.ID).Times(1).
+					Return(db.WebhookDelivery{ID: delivery.ID, SubscriptionID: subscription.ID, Status: db.WebhookDeliveryStatusPending}, nil)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, rr.Code)
+			},
+		},
+		{
+			Name:     "Someone Else's Delivery",
+			Username: util.RandomOwner() + "x",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetWebhookDelivery(gomock.Any(), delivery.ID).Times(1).Return(delivery, nil)
+				ms.EXPECT().GetWebhookSubscription(gomock.Any(), subscription.ID).Times(1).Return(subscription, nil)
+				ms.EXPECT().ReplayWebhookDelivery(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, rr.Code)
+				requireBodyMatchError(t, rr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			server := newTestServer(t, newTestConfig(), mockStore)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/v1/webhooks/deliveries/%d/replay", delivery.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.TokenMaker, tc.Username)
+
+			server.Router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
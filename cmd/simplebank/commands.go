@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// pageSize is how many rows the listing commands read per query.
+const pageSize = 100
+
+type userResult struct {
+	Username  string    `json:"username"`
+	FullName  string    `json:"full_name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cli *cli) userCreate(ctx context.Context, args []string) error {
+	flags := cli.flags("user create", "")
+	username := flags.String("username", "", "username (required)")
+	fullName := flags.String("full-name", "", "full name (required)")
+	email := flags.String("email", "", "email address (required)")
+	password := flags.String("password", "", "initial password (required)")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *username == "" || *fullName == "" || *email == "" || *password == "" {
+		return errors.New("--username, --full-name, --email and --password are required")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user, err := cli.store.CreateUserTx(ctx, db.CreateUserParams{
+		Username:     *username,
+		FullName:     *fullName,
+		Email:        *email,
+		PasswordHash: string(hash),
+	})
+	if err != nil {
+		return err
+	}
+	return cli.printUser(user)
+}
+
+// userRole changes a user's role. It is how the first admin is made, since only admins
+// can change roles over the API.
+func (cli *cli) userRole(ctx context.Context, args []string) error {
+	flags := cli.flags("user role", "USERNAME ROLE")
+	if err := cli.parse(flags, args, 2); err != nil {
+		return err
+	}
+	role := db.UserRole(flags.Arg(1))
+	if !role.Valid() {
+		return fmt.Errorf("invalid role %q: must be one of %v", role, db.AllUserRoleValues())
+	}
+
+	user, err := cli.store.UpdateUserRoleTx(ctx, db.UpdateUserRoleParams{Username: flags.Arg(0), Role: role})
+	if err != nil {
+		return err
+	}
+	return cli.printUser(user)
+}
+
+// userUnlock lifts the lockout of a user after too many failed logins. It is how a locked
+// out admin gets back in, with no other admin to unlock them over the API.
+func (cli *cli) userUnlock(ctx context.Context, args []string) error {
+	flags := cli.flags("user unlock", "USERNAME")
+	if err := cli.parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	user, err := cli.store.UnlockLoginTx(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return cli.printUser(user)
+}
+
+func (cli *cli) printUser(user db.User) error {
+	result := userResult{Username: user.Username, FullName: user.FullName, Email: user.Email, Role: string(user.Role), CreatedAt: user.CraetedAt.Time}
+	t := table{headers: []string{"USERNAME", "FULL NAME", "EMAIL", "ROLE", "CREATED AT"}}
+	t.add(user.Username, user.FullName, user.Email, string(user.Role), timestamp(user.CraetedAt))
+	return cli.print(result, t)
+}
+
+func (cli *cli) accountCreate(ctx context.Context, args []string) error {
+	flags := cli.flags("account create", "")
+	owner := flags.String("owner", "", "username of the owner (required)")
+	currency := flags.String("currency", "", "currency, one of "+fmt.Sprint(util.Currencies)+" (required)")
+	accountType := flags.String("type", string(db.AccountTypeChecking), "account type: checking, savings or business")
+	balance := flags.String("balance", "0", "opening balance, recorded as an entry")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *owner == "" {
+		return errors.New("--owner is required")
+	}
+	if !slices.Contains(util.Currencies, *currency) {
+		return fmt.Errorf("unsupported currency %q", *currency)
+	}
+	if !db.AccountType(*accountType).Valid() {
+		return fmt.Errorf("invalid account type %q", *accountType)
+	}
+
+	var opening pgtype.Numeric
+	if err := opening.Scan(*balance); err != nil {
+		return fmt.Errorf("invalid balance %q: %w", *balance, err)
+	}
+
+	account, err := cli.store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:       *owner,
+		Balance:     opening,
+		Currency:    *currency,
+		AccountType: db.AccountType(*accountType),
+	})
+	if err != nil {
+		return err
+	}
+	return cli.print(account, accountTable(account))
+}
+
+func (cli *cli) accountFreeze(ctx context.Context, args []string) error {
+	return cli.setFrozen(ctx, "account freeze", args, true)
+}
+
+func (cli *cli) accountUnfreeze(ctx context.Context, args []string) error {
+	return cli.setFrozen(ctx, "account unfreeze", args, false)
+}
+
+func (cli *cli) setFrozen(ctx context.Context, name string, args []string, frozen bool) error {
+	flags := cli.flags(name, "ACCOUNT_ID")
+	if err := cli.parse(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	account, err := cli.store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: id, Frozen: frozen})
+	if err != nil {
+		return err
+	}
+	return cli.print(account, accountTable(account))
+}
+
+func (cli *cli) transfer(ctx context.Context, args []string) error {
+	flags := cli.flags("transfer", "")
+	from := flags.Int64("from", 0, "account to debit (required)")
+	to := flags.Int64("to", 0, "account to credit (required)")
+	amount := flags.Int64("amount", 0, "amount in whole units of the accounts' currency (required)")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *from <= 0 || *to <= 0 || *amount <= 0 {
+		return errors.New("--from, --to and --amount must be positive")
+	}
+
+	// Like the API, refuse to move money between currencies.
+	fromAccount, err := cli.store.GetAccount(ctx, *from)
+	if err != nil {
+		return fmt.Errorf("account %d: %w", *from, err)
+	}
+	toAccount, err := cli.store.GetAccount(ctx, *to)
+	if err != nil {
+		return fmt.Errorf("account %d: %w", *to, err)
+	}
+	if fromAccount.Currency != toAccount.Currency {
+		return fmt.Errorf("account %d is in %s but account %d is in %s", *from, fromAccount.Currency, *to, toAccount.Currency)
+	}
+
+	result, err := cli.store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountId: *from,
+		ToAccountId:   *to,
+		Amount:        *amount,
+	})
+	if err != nil {
+		return err
+	}
+	return cli.print(result, transferTable(result))
+}
+
+func (cli *cli) reverse(ctx context.Context, args []string) error {
+	flags := cli.flags("reverse", "TRANSFER_ID")
+	if err := cli.parse(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	result, err := cli.store.ReverseTransferTx(ctx, id)
+	if err != nil {
+		return err
+	}
+	return cli.print(result, transferTable(result))
+}
+
+func (cli *cli) balances(ctx context.Context, args []string) error {
+	flags := cli.flags("balances", "")
+	owner := flags.String("owner", "", "only print the accounts of this user")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	accounts := []db.Account{}
+	for offset := int32(0); ; offset += pageSize {
+		var page []db.Account
+		var err error
+		if *owner != "" {
+			page, err = cli.store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: *owner, Limit: pageSize, Offset: offset})
+		} else {
+			page, err = cli.store.ListAccounts(ctx, db.ListAccountsParams{Limit: pageSize, Offset: offset})
+		}
+		if err != nil {
+			return err
+		}
+
+		accounts = append(accounts, page...)
+		if len(page) < pageSize {
+			break
+		}
+	}
+	return cli.print(accounts, accountTable(accounts...))
+}
+
+type ledgerLine struct {
+	EntryID   int64     `json:"entry_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Amount    string    `json:"amount"`
+	Balance   string    `json:"balance"`
+}
+
+func (cli *cli) ledger(ctx context.Context, args []string) error {
+	flags := cli.flags("ledger", "ACCOUNT_ID")
+	if err := cli.parse(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if _, err := cli.store.GetAccount(ctx, id); err != nil {
+		return fmt.Errorf("account %d: %w", id, err)
+	}
+
+	lines := []ledgerLine{}
+	t := table{headers: []string{"ENTRY", "CREATED AT", "AMOUNT", "BALANCE"}}
+	balance := new(big.Rat)
+
+	for offset := int32(0); ; offset += pageSize {
+		entries, err := cli.store.ListEntriesForAccount(ctx, db.ListEntriesForAccountParams{AccountID: id, Limit: pageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			balance.Add(balance, db.NumericToRat(entry.Amount))
+			line := ledgerLine{
+				EntryID:   entry.ID,
+				CreatedAt: entry.CreatedAt.Time,
+				Amount:    money(entry.Amount),
+				Balance:   balance.FloatString(2),
+			}
+			lines = append(lines, line)
+			t.add(fmt.Sprint(line.EntryID), timestamp(entry.CreatedAt), line.Amount, line.Balance)
+		}
+		if len(entries) < pageSize {
+			break
+		}
+	}
+	return cli.print(lines, t)
+}
+
+type reconciliation struct {
+	Checked    int                    `json:"checked"`
+	Mismatches []reconciliationResult `json:"mismatches"`
+}
+
+type reconciliationResult struct {
+	AccountID  int64  `json:"account_id"`
+	Owner      string `json:"owner"`
+	Currency   string `json:"currency"`
+	Balance    string `json:"balance"`
+	Ledger     string `json:"ledger"`
+	Difference string `json:"difference"`
+}
+
+// reconcile compares every account's balance with the sum of its entries. It prints the
+// accounts that disagree and fails if there are any.
+func (cli *cli) reconcile(ctx context.Context, args []string) error {
+	flags := cli.flags("reconcile", "")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	rows, err := cli.store.ReconcileAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := reconciliation{Checked: len(rows), Mismatches: []reconciliationResult{}}
+	t := table{headers: []string{"ACCOUNT", "OWNER", "CURRENCY", "BALANCE", "LEDGER", "DIFFERENCE"}}
+
+	for _, row := range rows {
+		balance := db.NumericToRat(row.Account.Balance)
+		ledger := db.NumericToRat(row.LedgerBalance)
+		if balance.Cmp(ledger) == 0 {
+			continue
+		}
+
+		mismatch := reconciliationResult{
+			AccountID:  row.Account.ID,
+			Owner:      row.Account.Owner,
+			Currency:   row.Account.Currency,
+			Balance:    balance.FloatString(2),
+			Ledger:     ledger.FloatString(2),
+			Difference: new(big.Rat).Sub(balance, ledger).FloatString(2),
+		}
+		result.Mismatches = append(result.Mismatches, mismatch)
+		t.add(fmt.Sprint(mismatch.AccountID), mismatch.Owner, mismatch.Currency, mismatch.Balance, mismatch.Ledger, mismatch.Difference)
+	}
+
+	if err := cli.print(result, t); err != nil {
+		return err
+	}
+	if len(result.Mismatches) > 0 {
+		return fmt.Errorf("%d of %d accounts do not match their ledger", len(result.Mismatches), result.Checked)
+	}
+	fmt.Fprintf(cli.stderr, "all %d accounts match their ledger\n", result.Checked)
+	return nil
+}
+
+type rekeyResult struct {
+	KeyID   string `json:"key_id"`
+	Rekeyed int    `json:"rekeyed"`
+}
+
+// piiRekey moves the personal data onto the current master key now, rather than waiting
+// for the server's rekey job, so that an older key can be retired.
+func (cli *cli) piiRekey(ctx context.Context, args []string) error {
+	flags := cli.flags("pii rekey", "")
+	batchSize := flags.Int("batch-size", 100, "number of rows to read at a time")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return errors.New("--batch-size must be positive")
+	}
+	if cli.piiStore == nil {
+		return errors.New("PII_MASTER_KEYS is not set, so personal data is not encrypted")
+	}
+
+	rekeyed, err := cli.piiStore.Rekey(ctx, int32(*batchSize))
+	if err != nil {
+		return err
+	}
+
+	result := rekeyResult{KeyID: cli.piiStore.CurrentKeyID(), Rekeyed: rekeyed}
+	t := table{headers: []string{"KEY ID", "REKEYED"}}
+	t.add(result.KeyID, fmt.Sprint(result.Rekeyed))
+	return cli.print(result, t)
+}
+
+type seedResult struct {
+	Users           []string `json:"users"`
+	Accounts        []int64  `json:"accounts"`
+	Transfers       []int64  `json:"transfers"`
+	FailedTransfers int      `json:"failed_transfers"`
+}
+
+// seedPassword is the password of every seeded user, so that demo users can log in.
+const seedPassword = "secret"
+
+// seed creates random users, each with an account in a random currency and a random
+// opening balance, and random transfers between accounts that share a currency.
+func (cli *cli) seed(ctx context.Context, args []string) error {
+	flags := cli.flags("seed", "")
+	users := flags.Int("users", 10, "number of users to create")
+	transfers := flags.Int("transfers", 50, "number of transfers to attempt")
+	if err := cli.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(seedPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	result := seedResult{Users: []string{}, Accounts: []int64{}, Transfers: []int64{}}
+	byCurrency := make(map[string][]int64)
+
+	for range *users {
+		user, err := cli.store.CreateUser(ctx, db.CreateUserParams{
+			Username:     util.RandomOwner(),
+			FullName:     util.RandomOwner() + " " + util.RandomOwner(),
+			Email:        util.RandomString(8) + "@example.com",
+			PasswordHash: string(hash),
+		})
+		if err != nil {
+			return err
+		}
+		result.Users = append(result.Users, user.Username)
+
+		account, err := cli.store.CreateAccountTx(ctx, db.CreateAccountParams{
+			Owner:       user.Username,
+			Balance:     util.RandomMoney(),
+			Currency:    util.RandomCurrency(),
+			AccountType: db.AccountTypeChecking,
+		})
+		if err != nil {
+			return err
+		}
+		result.Accounts = append(result.Accounts, account.ID)
+		byCurrency[account.Currency] = append(byCurrency[account.Currency], account.ID)
+	}
+
+	var pairs [][]int64
+	for _, ids := range byCurrency {
+		if len(ids) > 1 {
+			pairs = append(pairs, ids)
+		}
+	}
+
+	for i := 0; i < *transfers && len(pairs) > 0; i++ {
+		ids := pairs[util.RandomInt(0, int64(len(pairs)-1))]
+		from := util.RandomInt(0, int64(len(ids)-1))
+		to := (from + util.RandomInt(1, int64(len(ids)-1))) % int64(len(ids))
+
+		transfer, err := cli.store.TransferTx(ctx, db.TransferTxParams{
+			FromAccountId: ids[from],
+			ToAccountId:   ids[to],
+			Amount:        util.RandomInt(1, 100),
+		})
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			result.FailedTransfers++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		result.Transfers = append(result.Transfers, transfer.Transfer.ID)
+	}
+
+	t := table{headers: []string{"USERS", "ACCOUNTS", "TRANSFERS", "FAILED TRANSFERS"}}
+	t.add(fmt.Sprint(len(result.Users)), fmt.Sprint(len(result.Accounts)), fmt.Sprint(len(result.Transfers)), fmt.Sprint(result.FailedTransfers))
+	return cli.print(result, t)
+}
+
+func parseID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid ID %q", arg)
+	}
+	return id, nil
+}
@@ -0,0 +1,193 @@
+// Command simplebank operates the bank from the command line. It works through db.Store,
+// like the server, so freezes, transfers and reversals publish the same events and keep
+// the same ledger invariants as changes made through the API.
+//
+// Usage:
+//
+//	simplebank [--config app.env] [--output table|json] <command> [flags] [args]
+//
+// Every config key can be overridden with a flag, such as --db-host for DB_HOST. Run
+// simplebank without a command to list the commands and flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"syscall"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"example.com/pii"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "simplebank:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags, connects to the configured database and runs the command.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	global := flag.NewFlagSet("simplebank", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	configPath := global.String("config", "app.env", "env file to load before the environment and flags")
+	output := global.String("output", outputTable, "output format: table or json")
+	loader := util.NewLoader("")
+	loader.RegisterFlags(global)
+	global.Usage = func() { printUsage(stderr, global) }
+
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return flag.ErrHelp
+	}
+
+	loader.File = *configPath
+	config, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("cannot load config: %w", err)
+	}
+
+	poolConfig, err := config.PoolConfig()
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	cli := newCLI(db.NewStore(pool), stdout, stderr)
+	if config.PIIMasterKeys != "" {
+		keys, err := pii.NewLocalKeyProvider(config.PIIMasterKeys, config.PIIBlindIndexKey)
+		if err != nil {
+			return fmt.Errorf("invalid PII keys: %w", err)
+		}
+		cli.piiStore = pii.NewStore(cli.store, keys)
+		cli.store = cli.piiStore
+	}
+	cli.output = *output
+	return cli.execute(ctx, global.Args())
+}
+
+// cli runs commands against a store and prints their results.
+type cli struct {
+	store db.Store
+	// piiStore is store, when it encrypts personal data.
+	piiStore *pii.Store
+	stdout   io.Writer
+	stderr   io.Writer
+	output   string
+}
+
+func newCLI(store db.Store, stdout, stderr io.Writer) *cli {
+	return &cli{store: store, stdout: stdout, stderr: stderr, output: outputTable}
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, ctx context.Context, args []string) error
+}
+
+// commands are matched on their first one or two words, so that "account freeze 7" runs
+// the "account freeze" command with the argument 7.
+var commands = []command{
+	{name: "user create", summary: "create a user", run: (*cli).userCreate},
+	{name: "user role", args: "USERNAME ROLE", summary: "make a user an admin, teller, auditor or customer", run: (*cli).userRole},
+	{name: "user unlock", args: "USERNAME", summary: "unlock a user locked out after too many failed logins", run: (*cli).userUnlock},
+	{name: "account create", summary: "open an account", run: (*cli).accountCreate},
+	{name: "account freeze", args: "ACCOUNT_ID", summary: "freeze an account, blocking transfers in and out", run: (*cli).accountFreeze},
+	{name: "account unfreeze", args: "ACCOUNT_ID", summary: "unfreeze an account", run: (*cli).accountUnfreeze},
+	{name: "transfer", summary: "move money between two accounts", run: (*cli).transfer},
+	{name: "reverse", args: "TRANSFER_ID", summary: "reverse a transfer", run: (*cli).reverse},
+	{name: "balances", summary: "print account balances", run: (*cli).balances},
+	{name: "ledger", summary: "export an account's entries with running balance", run: (*cli).ledger},
+	{name: "reconcile", summary: "check every balance against the sum of its entries", run: (*cli).reconcile},
+	{name: "pii rekey", summary: "move all personal data onto the current master key", run: (*cli).piiRekey},
+	{name: "seed", summary: "create random demo users, accounts and transfers", run: (*cli).seed},
+}
+
+// execute runs the command args name. Its changes are recorded in the audit log as made
+// from the CLI by the operator's OS user.
+func (cli *cli) execute(ctx context.Context, args []string) error {
+	cmd, rest, ok := findCommand(args)
+	if !ok {
+		return fmt.Errorf("unknown command %q; run simplebank without arguments for help", strings.Join(args, " "))
+	}
+	ctx = db.WithAuditActor(ctx, db.AuditActor{Type: db.AuditActorCLI, Username: operator()})
+	return cmd.run(cli, ctx, rest)
+}
+
+// operator returns the login of the OS user running the CLI, or "" if it is unknown.
+func operator() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// flags returns the flag set of a command, with the --output flag every command accepts.
+func (cli *cli) flags(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(cli.stderr)
+	flags.StringVar(&cli.output, "output", cli.output, "output format: table or json")
+	flags.Usage = func() {
+		fmt.Fprintf(cli.stderr, "usage: simplebank %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parse parses a command's flags and checks that it got want positional arguments.
+func (cli *cli) parse(flags *flag.FlagSet, args []string, want int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cli.output != outputTable && cli.output != outputJSON {
+		return fmt.Errorf("unknown output format %q", cli.output)
+	}
+	if flags.NArg() != want {
+		flags.Usage()
+		return fmt.Errorf("%s takes %d arguments, got %d", flags.Name(), want, flags.NArg())
+	}
+	return nil
+}
+
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+func printUsage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: simplebank [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-30s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	global.PrintDefaults()
+}
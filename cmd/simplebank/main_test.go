@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func numeric(n int64) pgtype.Numeric {
+	return pgtype.Numeric{Int: big.NewInt(n), Valid: true}
+}
+
+func testAccount(id int64, currency string) db.Account {
+	return db.Account{
+		ID:             id,
+		Owner:          "alice",
+		Balance:        numeric(100),
+		OverdraftLimit: numeric(0),
+		Currency:       currency,
+		AccountType:    db.AccountTypeChecking,
+	}
+}
+
+func TestCommands(t *testing.T) {
+	frozen := testAccount(7, "USD")
+	frozen.Frozen = true
+
+	testCases := []struct {
+		Name      string
+		Args      []string
+		BuildStub func(*mock.MockStore)
+		Check     func(t *testing.T, stdout string, err error)
+	}{
+		{
+			Name: "Freeze Table",
+			Args: []string{"account", "freeze", "7"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().FreezeAccountTx(gomock.Any(), db.FreezeAccountTxParams{AccountID: 7, Frozen: true}).Times(1).Return(frozen, nil)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.NoError(t, err)
+				require.Contains(t, stdout, "FROZEN")
+				require.Regexp(t, `7\s+alice\s+checking\s+USD\s+100.00\s+0.00\s+true`, stdout)
+			},
+		},
+		{
+			Name: "Unfreeze JSON",
+			Args: []string{"account", "unfreeze", "--output", "json", "7"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().FreezeAccountTx(gomock.Any(), db.FreezeAccountTxParams{AccountID: 7, Frozen: false}).Times(1).Return(testAccount(7, "USD"), nil)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.NoError(t, err)
+				var account db.Account
+				require.NoError(t, json.Unmarshal([]byte(stdout), &account))
+				require.Equal(t, int64(7), account.ID)
+				require.False(t, account.Frozen)
+			},
+		},
+		{
+			Name: "Freeze Invalid ID",
+			Args: []string{"account", "freeze", "abc"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().FreezeAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorContains(t, err, "invalid ID")
+			},
+		},
+		{
+			Name: "User Role",
+			Args: []string{"user", "role", "alice", "admin"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateUserRoleTx(gomock.Any(), db.UpdateUserRoleParams{Username: "alice", Role: db.UserRoleAdmin}).Times(1).
+					Return(db.User{Username: "alice", FullName: "Alice", Email: "alice@example.com", Role: db.UserRoleAdmin}, nil)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.NoError(t, err)
+				require.Regexp(t, `alice\s+Alice\s+alice@example.com\s+admin`, stdout)
+			},
+		},
+		{
+			Name: "User Role Invalid",
+			Args: []string{"user", "role", "alice", "root"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UpdateUserRoleTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorContains(t, err, `invalid role "root"`)
+			},
+		},
+		{
+			Name: "User Unlock",
+			Args: []string{"user", "unlock", "alice"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().UnlockLoginTx(gomock.Any(), "alice").Times(1).
+					DoAndReturn(func(ctx context.Context, username string) (db.User, error) {
+						require.Equal(t, db.AuditActorCLI, db.AuditActorFrom(ctx).Type)
+						return db.User{Username: "alice", FullName: "Alice", Email: "alice@example.com", Role: db.UserRoleAdmin}, nil
+					})
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.NoError(t, err)
+				require.Regexp(t, `alice\s+Alice\s+alice@example.com\s+admin`, stdout)
+			},
+		},
+		{
+			Name: "Transfer",
+			Args: []string{"transfer", "--from", "1", "--to", "2", "--amount", "10"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), int64(1)).Times(1).Return(testAccount(1, "USD"), nil)
+				ms.EXPECT().GetAccount(gomock.Any(), int64(2)).Times(1).Return(testAccount(2, "USD"), nil)
+				ms.EXPECT().TransferTx(gomock.Any(), db.TransferTxParams{FromAccountId: 1, ToAccountId: 2, Amount: 10}).Times(1).
+					Return(db.TransferTxResult{Transfer: db.Transfer{ID: 3, FromAccountID: 1, ToAccountID: 2, Amount: numeric(10)}}, nil)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.NoError(t, err)
+				require.Regexp(t, `3\s+1\s+2\s+10.00`, stdout)
+			},
+		},
+		{
+			Name: "Transfer Currency Mismatch",
+			Args: []string{"transfer", "--from", "1", "--to", "2", "--amount", "10"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().GetAccount(gomock.Any(), int64(1)).Times(1).Return(testAccount(1, "USD"), nil)
+				ms.EXPECT().GetAccount(gomock.Any(), int64(2)).Times(1).Return(testAccount(2, "EUR"), nil)
+				ms.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorContains(t, err, "is in USD but account 2 is in EUR")
+			},
+		},
+		{
+			Name: "Reverse Already Reversed",
+			Args: []string{"reverse", "3"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().ReverseTransferTx(gomock.Any(), int64(3)).Times(1).Return(db.TransferTxResult{}, db.ErrTransferReversed)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorIs(t, err, db.ErrTransferReversed)
+			},
+		},
+		{
+			Name: "Reconcile Clean",
+			Args: []string{"reconcile"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().ReconcileAccounts(gomock.Any()).Times(1).Return([]db.ReconcileAccountsRow{
+					{Account: testAccount(1, "USD"), LedgerBalance: numeric(100)},
+				}, nil)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			Name: "Reconcile Mismatch",
+			Args: []string{"--output", "json", "reconcile"},
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().ReconcileAccounts(gomock.Any()).Times(1).Return([]db.ReconcileAccountsRow{
+					{Account: testAccount(1, "USD"), LedgerBalance: numeric(100)},
+					{Account: testAccount(2, "USD"), LedgerBalance: numeric(90)},
+				}, nil)
+			},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorContains(t, err, "1 of 2 accounts")
+				var result reconciliation
+				require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+				require.Equal(t, 2, result.Checked)
+				require.Len(t, result.Mismatches, 1)
+				require.Equal(t, int64(2), result.Mismatches[0].AccountID)
+				require.Equal(t, "10.00", result.Mismatches[0].Difference)
+			},
+		},
+		{
+			Name:      "Unknown Command",
+			Args:      []string{"account", "close", "7"},
+			BuildStub: func(ms *mock.MockStore) {},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorContains(t, err, "unknown command")
+			},
+		},
+		{
+			Name:      "Unknown Output",
+			Args:      []string{"balances", "--output", "yaml"},
+			BuildStub: func(ms *mock.MockStore) {},
+			Check: func(t *testing.T, stdout string, err error) {
+				require.ErrorContains(t, err, "unknown output format")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStub(store)
+
+			var stdout, stderr bytes.Buffer
+			cli := newCLI(store, &stdout, &stderr)
+			// the global --output flag is parsed by run, which needs a database
+			if tc.Args[0] == "--output" {
+				cli.output = tc.Args[1]
+				tc.Args = tc.Args[2:]
+			}
+
+			err := cli.execute(context.Background(), tc.Args)
+			tc.Check(t, stdout.String(), err)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// table is the table output of a command: a header row and one row per record.
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// print writes value as indented JSON, or t as aligned columns.
+func (cli *cli) print(value any, t table) error {
+	if cli.output == outputJSON {
+		encoder := json.NewEncoder(cli.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(cli.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func money(n pgtype.Numeric) string {
+	return db.NumericToRat(n).FloatString(2)
+}
+
+func timestamp(t pgtype.Timestamptz) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format("2006-01-02 15:04:05")
+}
+
+func accountTable(accounts ...db.Account) table {
+	t := table{headers: []string{"ID", "OWNER", "TYPE", "CURRENCY", "BALANCE", "OVERDRAFT LIMIT", "FROZEN"}}
+	for _, account := range accounts {
+		t.add(fmt.Sprint(account.ID), account.Owner, string(account.AccountType), account.Currency,
+			money(account.Balance), money(account.OverdraftLimit), fmt.Sprint(account.Frozen))
+	}
+	return t
+}
+
+func transferTable(result db.TransferTxResult) table {
+	t := table{headers: []string{"TRANSFER", "FROM", "TO", "AMOUNT", "FROM BALANCE", "TO BALANCE", "REVERSAL OF"}}
+	reversalOf := ""
+	if result.Transfer.ReversalOf.Valid {
+		reversalOf = fmt.Sprint(result.Transfer.ReversalOf.Int64)
+	}
+	t.add(fmt.Sprint(result.Transfer.ID), fmt.Sprint(result.Transfer.FromAccountID), fmt.Sprint(result.Transfer.ToAccountID),
+		money(result.Transfer.Amount), money(result.FromAccount.Balance), money(result.ToAccount.Balance), reversalOf)
+	return t
+}
@@ -0,0 +1,298 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/big"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func accountsByID(a, b db.Account) int {
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func (q *queries) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	balance, err := money("balance", arg.Balance)
+	if err != nil {
+		return db.Account{}, err
+	}
+	if !arg.AccountType.Valid() {
+		return db.Account{}, invalidEnum("account_type", arg.AccountType)
+	}
+	for _, account := range q.tables.accounts {
+		if account.Owner == arg.Owner && account.Currency == arg.Currency && account.AccountType == arg.AccountType {
+			return db.Account{}, uniqueViolation("accounts", "owner_currency_account_type_key")
+		}
+	}
+	if _, ok := q.tables.users[arg.Owner]; !ok {
+		return db.Account{}, foreignKeyViolation("accounts", "accounts_owner_fkey")
+	}
+
+	account := db.Account{
+		ID:             q.nextID("accounts"),
+		Owner:          arg.Owner,
+		Balance:        balance,
+		Currency:       arg.Currency,
+		CreatedAt:      q.timestamp(),
+		OverdraftLimit: pgtype.Numeric{Int: big.NewInt(0), Exp: -2, Valid: true},
+		AccountType:    arg.AccountType,
+	}
+	q.tables.accounts[account.ID] = account
+	return account, nil
+}
+
+func (q *queries) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	account, ok := q.tables.accounts[id]
+	if !ok {
+		return db.Account{}, pgx.ErrNoRows
+	}
+	return account, nil
+}
+
+// GetAccountForUpdate needs no lock of its own: transactions already run one at a time.
+func (q *queries) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	return q.GetAccount(ctx, id)
+}
+
+func (q *queries) GetAccountByOwnerCurrencyAndType(ctx context.Context, arg db.GetAccountByOwnerCurrencyAndTypeParams) (db.Account, error) {
+	for _, account := range q.tables.accounts {
+		if account.Owner == arg.Owner && account.Currency == arg.Currency && account.AccountType == arg.AccountType {
+			return account, nil
+		}
+	}
+	return db.Account{}, pgx.ErrNoRows
+}
+
+func (q *queries) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	return page(selectRows(q.tables.accounts, nil, accountsByID), arg.Limit, arg.Offset)
+}
+
+func (q *queries) ListAccountsByOwner(ctx context.Context, arg db.ListAccountsByOwnerParams) ([]db.Account, error) {
+	accounts := selectRows(q.tables.accounts, func(account db.Account) bool {
+		return account.Owner == arg.Owner
+	}, accountsByID)
+	return page(accounts, arg.Limit, arg.Offset)
+}
+
+func (q *queries) ListOverdrawnAccounts(ctx context.Context) ([]db.Account, error) {
+	return selectRows(q.tables.accounts, func(account db.Account) bool {
+		return sign(account.Balance) < 0
+	}, accountsByID), nil
+}
+
+// updateAccount applies change to a copy of the account and stores it. The account must
+// exist; change returns an error to leave it as it was.
+func (q *queries) updateAccount(id int64, change func(*db.Account) error) (db.Account, error) {
+	account, ok := q.tables.accounts[id]
+	if !ok {
+		return db.Account{}, pgx.ErrNoRows
+	}
+	if err := change(&account); err != nil {
+		return db.Account{}, err
+	}
+	q.tables.accounts[id] = account
+	return account, nil
+}
+
+// ignoreNoRows turns a missing row into success, for :exec queries that update nothing.
+func ignoreNoRows(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func (q *queries) setBalance(account *db.Account, balance pgtype.Numeric) error {
+	balance, err := money("balance", balance)
+	if err != nil {
+		return err
+	}
+	account.Balance = balance
+	return nil
+}
+
+func (q *queries) UpdateAccountBalance(ctx context.Context, arg db.UpdateAccountBalanceParams) error {
+	_, err := q.updateAccount(arg.ID, func(account *db.Account) error {
+		return q.setBalance(account, arg.Balance)
+	})
+	return ignoreNoRows(err)
+}
+
+func (q *queries) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
+	return q.updateAccount(arg.ID, func(account *db.Account) error {
+		return q.setBalance(account, db.AddNumeric(account.Balance, arg.Amount))
+	})
+}
+
+func (q *queries) SubtractAccountBalance(ctx context.Context, arg db.SubtractAccountBalanceParams) error {
+	_, err := q.updateAccount(arg.ID, func(account *db.Account) error {
+		return q.setBalance(account, subtract(account.Balance, arg.Amount))
+	})
+	return ignoreNoRows(err)
+}
+
+// DebitAccountBalance, like its SQL, matches no row when the debit would take the balance
+// below the overdraft limit.
+func (q *queries) DebitAccountBalance(ctx context.Context, arg db.DebitAccountBalanceParams) (db.Account, error) {
+	return q.updateAccount(arg.ID, func(account *db.Account) error {
+		balance := subtract(account.Balance, arg.Amount)
+		floor := new(big.Rat).Neg(db.NumericToRat(account.OverdraftLimit))
+		if db.NumericToRat(balance).Cmp(floor) < 0 {
+			return pgx.ErrNoRows
+		}
+		return q.setBalance(account, balance)
+	})
+}
+
+func (q *queries) UpdateAccountOverdraftLimit(ctx context.Context, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+	return q.updateAccount(arg.ID, func(account *db.Account) error {
+		limit, err := money("overdraft_limit", arg.OverdraftLimit)
+		if err != nil {
+			return err
+		}
+		if sign(limit) < 0 {
+			return checkViolation("accounts", "accounts_overdraft_limit_check")
+		}
+		account.OverdraftLimit = limit
+		return nil
+	})
+}
+
+func (q *queries) SetAccountFrozen(ctx context.Context, arg db.SetAccountFrozenParams) (db.Account, error) {
+	return q.updateAccount(arg.ID, func(account *db.Account) error {
+		account.Frozen = arg.Frozen
+		return nil
+	})
+}
+
+func (q *queries) CloseAccount(ctx context.Context, id int64) (db.Account, error) {
+	return q.updateAccount(id, func(account *db.Account) error {
+		account.ClosedAt = q.timestamp()
+		return nil
+	})
+}
+
+func (q *queries) DeleteAccount(ctx context.Context, id int64) error {
+	for _, entry := range q.tables.entries {
+		if entry.AccountID == id {
+			return stillReferenced("accounts", "entries_account_id_fkey", "entries")
+		}
+	}
+	for _, transfer := range q.tables.transfers {
+		if transfer.FromAccountID == id {
+			return stillReferenced("accounts", "transfers_from_account_id_fkey", "transfers")
+		}
+		if transfer.ToAccountID == id {
+			return stillReferenced("accounts", "transfers_to_account_id_fkey", "transfers")
+		}
+	}
+	for _, charge := range q.tables.overdraftCharges {
+		if charge.AccountID == id {
+			return stillReferenced("accounts", "overdraft_charges_account_id_fkey", "overdraft_charges")
+		}
+	}
+	for _, accrual := range q.tables.interestAccruals {
+		if accrual.AccountID == id {
+			return stillReferenced("accounts", "interest_accruals_account_id_fkey", "interest_accruals")
+		}
+	}
+	for _, posting := range q.tables.interestPostings {
+		if posting.AccountID == id {
+			return stillReferenced("accounts", "interest_postings_account_id_fkey", "interest_postings")
+		}
+	}
+
+	delete(q.tables.accounts, id)
+	return nil
+}
+
+func (q *queries) ReconcileAccounts(ctx context.Context) ([]db.ReconcileAccountsRow, error) {
+	amounts := make(map[int64][]pgtype.Numeric)
+	for _, entry := range q.tables.entries {
+		amounts[entry.AccountID] = append(amounts[entry.AccountID], entry.Amount)
+	}
+
+	rows := []db.ReconcileAccountsRow{}
+	for _, account := range selectRows(q.tables.accounts, nil, accountsByID) {
+		rows = append(rows, db.ReconcileAccountsRow{
+			Account:       account,
+			LedgerBalance: sum(amounts[account.ID], 2),
+		})
+	}
+	return rows, nil
+}
+
+func subtract(a, b pgtype.Numeric) pgtype.Numeric {
+	if !a.Valid || !b.Valid {
+		return pgtype.Numeric{}
+	}
+	return db.AddNumeric(a, pgtype.Numeric{Int: new(big.Int).Neg(b.Int), Exp: b.Exp, Valid: true})
+}
+
+func (store *Store) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.CreateAccount(ctx, arg) })
+}
+
+func (store *Store) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.GetAccount(ctx, id) })
+}
+
+func (store *Store) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.GetAccountForUpdate(ctx, id) })
+}
+
+func (store *Store) GetAccountByOwnerCurrencyAndType(ctx context.Context, arg db.GetAccountByOwnerCurrencyAndTypeParams) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.GetAccountByOwnerCurrencyAndType(ctx, arg) })
+}
+
+func (store *Store) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Account, error) { return q.ListAccounts(ctx, arg) })
+}
+
+func (store *Store) ListAccountsByOwner(ctx context.Context, arg db.ListAccountsByOwnerParams) ([]db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Account, error) { return q.ListAccountsByOwner(ctx, arg) })
+}
+
+func (store *Store) ListOverdrawnAccounts(ctx context.Context) ([]db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Account, error) { return q.ListOverdrawnAccounts(ctx) })
+}
+
+func (store *Store) UpdateAccountBalance(ctx context.Context, arg db.UpdateAccountBalanceParams) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.UpdateAccountBalance(ctx, arg) })
+}
+
+func (store *Store) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.AddAccountBalance(ctx, arg) })
+}
+
+func (store *Store) SubtractAccountBalance(ctx context.Context, arg db.SubtractAccountBalanceParams) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.SubtractAccountBalance(ctx, arg) })
+}
+
+func (store *Store) DebitAccountBalance(ctx context.Context, arg db.DebitAccountBalanceParams) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.DebitAccountBalance(ctx, arg) })
+}
+
+func (store *Store) UpdateAccountOverdraftLimit(ctx context.Context, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.UpdateAccountOverdraftLimit(ctx, arg) })
+}
+
+func (store *Store) SetAccountFrozen(ctx context.Context, arg db.SetAccountFrozenParams) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.SetAccountFrozen(ctx, arg) })
+}
+
+func (store *Store) CloseAccount(ctx context.Context, id int64) (db.Account, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Account, error) { return q.CloseAccount(ctx, id) })
+}
+
+func (store *Store) DeleteAccount(ctx context.Context, id int64) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeleteAccount(ctx, id) })
+}
+
+func (store *Store) ReconcileAccounts(ctx context.Context) ([]db.ReconcileAccountsRow, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.ReconcileAccountsRow, error) { return q.ReconcileAccounts(ctx) })
+}
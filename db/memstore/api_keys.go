@@ -0,0 +1,139 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+)
+
+// cloneApiKey copies the slices of a key, which the caller may change.
+func cloneApiKey(key db.ApiKey) db.ApiKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	key.AllowedIps = slices.Clone(key.AllowedIps)
+	return key
+}
+
+func (q *queries) CreateApiKey(ctx context.Context, arg db.CreateApiKeyParams) (db.ApiKey, error) {
+	if arg.Scopes == nil {
+		return db.ApiKey{}, notNull("scopes")
+	}
+	if arg.AllowedIps == nil {
+		return db.ApiKey{}, notNull("allowed_ips")
+	}
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.ApiKey{}, foreignKeyViolation("api_keys", "api_keys_username_fkey")
+	}
+	for _, key := range q.tables.apiKeys {
+		if key.KeyHash == arg.KeyHash {
+			return db.ApiKey{}, uniqueViolation("api_keys", "api_keys_key_hash_key")
+		}
+		if key.Username == arg.Username && key.Name == arg.Name && !key.RevokedAt.Valid {
+			return db.ApiKey{}, uniqueViolation("api_keys", "api_keys_username_name_key")
+		}
+	}
+
+	key := cloneApiKey(db.ApiKey{
+		ID:            q.nextID("api_keys"),
+		Username:      arg.Username,
+		Name:          arg.Name,
+		KeyHash:       arg.KeyHash,
+		Prefix:        arg.Prefix,
+		Scopes:        arg.Scopes,
+		AllowedIps:    arg.AllowedIps,
+		ExpiresAt:     timestamptz(arg.ExpiresAt),
+		SigningSecret: arg.SigningSecret,
+		CreatedAt:     q.timestamp(),
+	})
+	q.tables.apiKeys[key.ID] = key
+	return cloneApiKey(key), nil
+}
+
+func (q *queries) GetApiKey(ctx context.Context, id int64) (db.ApiKey, error) {
+	key, ok := q.tables.apiKeys[id]
+	if !ok {
+		return db.ApiKey{}, pgx.ErrNoRows
+	}
+	return cloneApiKey(key), nil
+}
+
+func (q *queries) GetApiKeyByHash(ctx context.Context, keyHash string) (db.ApiKey, error) {
+	for _, key := range q.tables.apiKeys {
+		if key.KeyHash == keyHash {
+			return cloneApiKey(key), nil
+		}
+	}
+	return db.ApiKey{}, pgx.ErrNoRows
+}
+
+func (q *queries) ListApiKeys(ctx context.Context, username string) ([]db.ApiKey, error) {
+	keys := selectRows(q.tables.apiKeys, func(key db.ApiKey) bool {
+		return key.Username == username && !key.RevokedAt.Valid
+	}, func(a, b db.ApiKey) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for i := range keys {
+		keys[i] = cloneApiKey(keys[i])
+	}
+	return keys, nil
+}
+
+func (q *queries) RevokeApiKey(ctx context.Context, arg db.RevokeApiKeyParams) (int64, error) {
+	key, ok := q.tables.apiKeys[arg.ID]
+	if !ok || key.Username != arg.Username || key.RevokedAt.Valid {
+		return 0, nil
+	}
+	key.RevokedAt = q.timestamp()
+	q.tables.apiKeys[arg.ID] = key
+	return 1, nil
+}
+
+func (q *queries) RevokeApiKeys(ctx context.Context, username string) error {
+	for id, key := range q.tables.apiKeys {
+		if key.Username == username && !key.RevokedAt.Valid {
+			key.RevokedAt = q.timestamp()
+			q.tables.apiKeys[id] = key
+		}
+	}
+	return nil
+}
+
+func (q *queries) TouchApiKey(ctx context.Context, id int64) error {
+	key, ok := q.tables.apiKeys[id]
+	if !ok {
+		return nil
+	}
+	key.LastUsedAt = q.timestamp()
+	q.tables.apiKeys[id] = key
+	return nil
+}
+
+func (store *Store) CreateApiKey(ctx context.Context, arg db.CreateApiKeyParams) (db.ApiKey, error) {
+	return autocommit(ctx, store, func(q *queries) (db.ApiKey, error) { return q.CreateApiKey(ctx, arg) })
+}
+
+func (store *Store) GetApiKey(ctx context.Context, id int64) (db.ApiKey, error) {
+	return autocommit(ctx, store, func(q *queries) (db.ApiKey, error) { return q.GetApiKey(ctx, id) })
+}
+
+func (store *Store) GetApiKeyByHash(ctx context.Context, keyHash string) (db.ApiKey, error) {
+	return autocommit(ctx, store, func(q *queries) (db.ApiKey, error) { return q.GetApiKeyByHash(ctx, keyHash) })
+}
+
+func (store *Store) ListApiKeys(ctx context.Context, username string) ([]db.ApiKey, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.ApiKey, error) { return q.ListApiKeys(ctx, username) })
+}
+
+func (store *Store) RevokeApiKey(ctx context.Context, arg db.RevokeApiKeyParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.RevokeApiKey(ctx, arg) })
+}
+
+func (store *Store) RevokeApiKeys(ctx context.Context, username string) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.RevokeApiKeys(ctx, username) })
+}
+
+func (store *Store) TouchApiKey(ctx context.Context, id int64) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.TouchApiKey(ctx, id) })
+}
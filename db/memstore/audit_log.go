@@ -0,0 +1,75 @@
+package memstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+
+	db "example.com/db/sqlc"
+)
+
+// The audit log has no query that changes or deletes a row, so like the Postgres table,
+// which refuses to with a trigger, it only grows.
+
+func cloneAuditLog(entry db.AuditLog) db.AuditLog {
+	entry.Before = bytes.Clone(entry.Before)
+	entry.After = bytes.Clone(entry.After)
+	return entry
+}
+
+func (q *queries) CreateAuditLog(ctx context.Context, arg db.CreateAuditLogParams) (db.AuditLog, error) {
+	for _, state := range [][]byte{arg.Before, arg.After} {
+		if state != nil && !json.Valid(state) {
+			return db.AuditLog{}, pgError(codeInvalidTextRepr, "invalid input syntax for type json")
+		}
+	}
+
+	entry := cloneAuditLog(db.AuditLog{
+		ID:           q.nextID("audit_log"),
+		ActorType:    arg.ActorType,
+		Actor:        arg.Actor,
+		ApiKeyID:     arg.ApiKeyID,
+		Action:       arg.Action,
+		ResourceType: arg.ResourceType,
+		ResourceID:   arg.ResourceID,
+		ClientIp:     arg.ClientIp,
+		RequestID:    arg.RequestID,
+		Before:       arg.Before,
+		After:        arg.After,
+		CreatedAt:    q.timestamp(),
+	})
+	q.tables.auditLog[entry.ID] = entry
+	return cloneAuditLog(entry), nil
+}
+
+func (q *queries) ListAuditLog(ctx context.Context, arg db.ListAuditLogParams) ([]db.AuditLog, error) {
+	entries := selectRows(q.tables.auditLog, func(entry db.AuditLog) bool {
+		return entry.ID > arg.AfterID &&
+			(!arg.Actor.Valid || entry.Actor.Valid && entry.Actor.String == arg.Actor.String) &&
+			(!arg.Action.Valid || entry.Action == arg.Action.String) &&
+			(!arg.ResourceType.Valid || entry.ResourceType == arg.ResourceType.String) &&
+			(!arg.ResourceID.Valid || entry.ResourceID == arg.ResourceID.String) &&
+			(!arg.Since.Valid || !entry.CreatedAt.Time.Before(arg.Since.Time)) &&
+			(!arg.Until.Valid || entry.CreatedAt.Time.Before(arg.Until.Time))
+	}, func(a, b db.AuditLog) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	entries, err := page(entries, arg.LimitCount, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i] = cloneAuditLog(entries[i])
+	}
+	return entries, nil
+}
+
+func (store *Store) CreateAuditLog(ctx context.Context, arg db.CreateAuditLogParams) (db.AuditLog, error) {
+	return autocommit(ctx, store, func(q *queries) (db.AuditLog, error) { return q.CreateAuditLog(ctx, arg) })
+}
+
+func (store *Store) ListAuditLog(ctx context.Context, arg db.ListAuditLogParams) ([]db.AuditLog, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.AuditLog, error) { return q.ListAuditLog(ctx, arg) })
+}
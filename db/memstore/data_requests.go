@@ -0,0 +1,100 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+)
+
+func dataRequestsByID(a, b db.DataRequest) int {
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func (q *queries) CreateDataRequest(ctx context.Context, arg db.CreateDataRequestParams) (db.DataRequest, error) {
+	if !arg.Kind.Valid() {
+		return db.DataRequest{}, invalidEnum("data_request_kind", arg.Kind)
+	}
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.DataRequest{}, foreignKeyViolation("data_requests", "data_requests_username_fkey")
+	}
+	for _, request := range q.tables.dataRequests {
+		if request.Username == arg.Username && request.Kind == arg.Kind && request.Status == db.DataRequestStatusPending {
+			return db.DataRequest{}, uniqueViolation("data_requests", "data_requests_pending_key")
+		}
+	}
+
+	request := db.DataRequest{
+		ID:        q.nextID("data_requests"),
+		Username:  arg.Username,
+		Kind:      arg.Kind,
+		Status:    db.DataRequestStatusPending,
+		Reason:    arg.Reason,
+		CreatedAt: q.timestamp(),
+	}
+	q.tables.dataRequests[request.ID] = request
+	return request, nil
+}
+
+// GetDataRequestForUpdate needs no lock of its own, like GetAccountForUpdate.
+func (q *queries) GetDataRequestForUpdate(ctx context.Context, id int64) (db.DataRequest, error) {
+	request, ok := q.tables.dataRequests[id]
+	if !ok {
+		return db.DataRequest{}, pgx.ErrNoRows
+	}
+	return request, nil
+}
+
+func (q *queries) ListDataRequests(ctx context.Context, arg db.ListDataRequestsParams) ([]db.DataRequest, error) {
+	if arg.Status.Valid && !arg.Status.DataRequestStatus.Valid() {
+		return nil, invalidEnum("data_request_status", arg.Status.DataRequestStatus)
+	}
+	requests := selectRows(q.tables.dataRequests, func(request db.DataRequest) bool {
+		return request.ID > arg.AfterID && (!arg.Status.Valid || request.Status == arg.Status.DataRequestStatus)
+	}, dataRequestsByID)
+	return page(requests, arg.LimitCount, 0)
+}
+
+func (q *queries) ListDataRequestsByUser(ctx context.Context, username string) ([]db.DataRequest, error) {
+	return selectRows(q.tables.dataRequests, func(request db.DataRequest) bool {
+		return request.Username == username
+	}, dataRequestsByID), nil
+}
+
+// ReviewDataRequest, like its SQL, matches no row once the request has been reviewed.
+func (q *queries) ReviewDataRequest(ctx context.Context, arg db.ReviewDataRequestParams) (db.DataRequest, error) {
+	request, ok := q.tables.dataRequests[arg.ID]
+	if !ok || request.Status != db.DataRequestStatusPending {
+		return db.DataRequest{}, pgx.ErrNoRows
+	}
+	if !arg.Status.Valid() {
+		return db.DataRequest{}, invalidEnum("data_request_status", arg.Status)
+	}
+	request.Status = arg.Status
+	request.ReviewedBy = arg.ReviewedBy
+	request.ReviewNote = arg.ReviewNote
+	request.ReviewedAt = q.timestamp()
+	q.tables.dataRequests[request.ID] = request
+	return request, nil
+}
+
+func (store *Store) CreateDataRequest(ctx context.Context, arg db.CreateDataRequestParams) (db.DataRequest, error) {
+	return autocommit(ctx, store, func(q *queries) (db.DataRequest, error) { return q.CreateDataRequest(ctx, arg) })
+}
+
+func (store *Store) GetDataRequestForUpdate(ctx context.Context, id int64) (db.DataRequest, error) {
+	return autocommit(ctx, store, func(q *queries) (db.DataRequest, error) { return q.GetDataRequestForUpdate(ctx, id) })
+}
+
+func (store *Store) ListDataRequests(ctx context.Context, arg db.ListDataRequestsParams) ([]db.DataRequest, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.DataRequest, error) { return q.ListDataRequests(ctx, arg) })
+}
+
+func (store *Store) ListDataRequestsByUser(ctx context.Context, username string) ([]db.DataRequest, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.DataRequest, error) { return q.ListDataRequestsByUser(ctx, username) })
+}
+
+func (store *Store) ReviewDataRequest(ctx context.Context, arg db.ReviewDataRequestParams) (db.DataRequest, error) {
+	return autocommit(ctx, store, func(q *queries) (db.DataRequest, error) { return q.ReviewDataRequest(ctx, arg) })
+}
@@ -0,0 +1,125 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+)
+
+func entriesByID(a, b db.Entry) int {
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func (q *queries) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+	amount, err := money("amount", arg.Amount)
+	if err != nil {
+		return db.Entry{}, err
+	}
+	if _, ok := q.tables.accounts[arg.AccountID]; !ok {
+		return db.Entry{}, foreignKeyViolation("entries", "entries_account_id_fkey")
+	}
+
+	entry := db.Entry{
+		ID:        q.nextID("entries"),
+		AccountID: arg.AccountID,
+		Amount:    amount,
+		CreatedAt: q.timestamp(),
+	}
+	q.tables.entries[entry.ID] = entry
+	return entry, nil
+}
+
+func (q *queries) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	entry, ok := q.tables.entries[id]
+	if !ok {
+		return db.Entry{}, pgx.ErrNoRows
+	}
+	return entry, nil
+}
+
+func (q *queries) ListEntries(ctx context.Context, arg db.ListEntriesParams) ([]db.Entry, error) {
+	return page(selectRows(q.tables.entries, nil, entriesByID), arg.Limit, arg.Offset)
+}
+
+func (q *queries) ListEntriesForAccount(ctx context.Context, arg db.ListEntriesForAccountParams) ([]db.Entry, error) {
+	entries := selectRows(q.tables.entries, func(entry db.Entry) bool {
+		return entry.AccountID == arg.AccountID
+	}, entriesByID)
+	return page(entries, arg.Limit, arg.Offset)
+}
+
+func (q *queries) ListEntriesForAccountAfter(ctx context.Context, arg db.ListEntriesForAccountAfterParams) ([]db.Entry, error) {
+	entries := selectRows(q.tables.entries, func(entry db.Entry) bool {
+		return entry.AccountID == arg.AccountID && entry.ID > arg.ID
+	}, entriesByID)
+	return page(entries, arg.Limit, 0)
+}
+
+func (q *queries) GetLatestEntryIDForAccount(ctx context.Context, accountID int64) (int64, error) {
+	var latest int64
+	for _, entry := range q.tables.entries {
+		if entry.AccountID == accountID {
+			latest = max(latest, entry.ID)
+		}
+	}
+	return latest, nil
+}
+
+func (q *queries) UpdateEntryAmount(ctx context.Context, arg db.UpdateEntryAmountParams) error {
+	entry, ok := q.tables.entries[arg.ID]
+	if !ok {
+		return nil
+	}
+	amount, err := money("amount", arg.Amount)
+	if err != nil {
+		return err
+	}
+	entry.Amount = amount
+	q.tables.entries[entry.ID] = entry
+	return nil
+}
+
+func (q *queries) DeleteEntry(ctx context.Context, id int64) error {
+	for _, charge := range q.tables.overdraftCharges {
+		if charge.EntryID == id {
+			return stillReferenced("entries", "overdraft_charges_entry_id_fkey", "overdraft_charges")
+		}
+	}
+
+	delete(q.tables.entries, id)
+	return nil
+}
+
+func (store *Store) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Entry, error) { return q.CreateEntry(ctx, arg) })
+}
+
+func (store *Store) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Entry, error) { return q.GetEntry(ctx, id) })
+}
+
+func (store *Store) ListEntries(ctx context.Context, arg db.ListEntriesParams) ([]db.Entry, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Entry, error) { return q.ListEntries(ctx, arg) })
+}
+
+func (store *Store) ListEntriesForAccount(ctx context.Context, arg db.ListEntriesForAccountParams) ([]db.Entry, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Entry, error) { return q.ListEntriesForAccount(ctx, arg) })
+}
+
+func (store *Store) ListEntriesForAccountAfter(ctx context.Context, arg db.ListEntriesForAccountAfterParams) ([]db.Entry, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Entry, error) { return q.ListEntriesForAccountAfter(ctx, arg) })
+}
+
+func (store *Store) GetLatestEntryIDForAccount(ctx context.Context, accountID int64) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.GetLatestEntryIDForAccount(ctx, accountID) })
+}
+
+func (store *Store) UpdateEntryAmount(ctx context.Context, arg db.UpdateEntryAmountParams) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.UpdateEntryAmount(ctx, arg) })
+}
+
+func (store *Store) DeleteEntry(ctx context.Context, id int64) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeleteEntry(ctx, id) })
+}
@@ -0,0 +1,73 @@
+package memstore
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// The Postgres error codes the queries can fail with.
+const (
+	codeUniqueViolation       = "23505"
+	codeForeignKeyViolation   = "23503"
+	codeCheckViolation        = "23514"
+	codeNotNullViolation      = "23502"
+	codeNumericOutOfRange     = "22003"
+	codeInvalidTextRepr       = "22P02"
+	codeInvalidRowCountLimit  = "2201W"
+	codeInvalidRowCountOffset = "2201X"
+)
+
+func pgError(code, message string) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, Message: message}
+}
+
+func uniqueViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           codeUniqueViolation,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+// foreignKeyViolation reports a row of table pointing at a row that does not exist.
+func foreignKeyViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           codeForeignKeyViolation,
+		Message:        fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+// stillReferenced reports a delete from table that rows of referencing still point at.
+func stillReferenced(table, constraint, referencing string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           codeForeignKeyViolation,
+		Message:        fmt.Sprintf("update or delete on table %q violates foreign key constraint %q on table %q", table, constraint, referencing),
+		TableName:      referencing,
+		ConstraintName: constraint,
+	}
+}
+
+func notNull(column string) error {
+	return pgError(codeNotNullViolation, fmt.Sprintf("null value in column %q violates not-null constraint", column))
+}
+
+func checkViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           codeCheckViolation,
+		Message:        fmt.Sprintf("new row for relation %q violates check constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+func invalidEnum(enum string, value any) error {
+	return pgError(codeInvalidTextRepr, fmt.Sprintf("invalid input value for enum %s: %q", enum, value))
+}
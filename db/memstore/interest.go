@@ -0,0 +1,300 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// compareAccountTypes orders account types the way Postgres orders an enum: by declaration.
+func compareAccountTypes(a, b db.AccountType) int {
+	values := db.AllAccountTypeValues()
+	return cmp.Compare(slices.Index(values, a), slices.Index(values, b))
+}
+
+func (q *queries) UpsertInterestProduct(ctx context.Context, arg db.UpsertInterestProductParams) (db.InterestProduct, error) {
+	if !arg.AccountType.Valid() {
+		return db.InterestProduct{}, invalidEnum("account_type", arg.AccountType)
+	}
+	if !arg.DayCount.Valid() {
+		return db.InterestProduct{}, invalidEnum("day_count_convention", arg.DayCount)
+	}
+	annualRate, err := rate("annual_rate", arg.AnnualRate)
+	if err != nil {
+		return db.InterestProduct{}, err
+	}
+	if sign(annualRate) < 0 {
+		return db.InterestProduct{}, checkViolation("interest_products", "interest_products_annual_rate_check")
+	}
+
+	for _, product := range q.tables.interestProducts {
+		if product.Currency == arg.Currency && product.AccountType == arg.AccountType {
+			product.AnnualRate = annualRate
+			product.DayCount = arg.DayCount
+			q.tables.interestProducts[product.ID] = product
+			return product, nil
+		}
+	}
+
+	product := db.InterestProduct{
+		ID:          q.nextID("interest_products"),
+		Currency:    arg.Currency,
+		AccountType: arg.AccountType,
+		AnnualRate:  annualRate,
+		DayCount:    arg.DayCount,
+		CreatedAt:   q.timestamp(),
+	}
+	q.tables.interestProducts[product.ID] = product
+	return product, nil
+}
+
+func (q *queries) GetInterestProduct(ctx context.Context, arg db.GetInterestProductParams) (db.InterestProduct, error) {
+	for _, product := range q.tables.interestProducts {
+		if product.Currency == arg.Currency && product.AccountType == arg.AccountType {
+			return product, nil
+		}
+	}
+	return db.InterestProduct{}, pgx.ErrNoRows
+}
+
+func (q *queries) ListInterestProducts(ctx context.Context) ([]db.InterestProduct, error) {
+	return selectRows(q.tables.interestProducts, nil, func(a, b db.InterestProduct) int {
+		return cmp.Or(cmp.Compare(a.Currency, b.Currency), compareAccountTypes(a.AccountType, b.AccountType))
+	}), nil
+}
+
+func (q *queries) ListInterestBearingAccounts(ctx context.Context) ([]db.ListInterestBearingAccountsRow, error) {
+	rows := []db.ListInterestBearingAccountsRow{}
+	for _, account := range selectRows(q.tables.accounts, nil, accountsByID) {
+		if sign(account.Balance) <= 0 {
+			continue
+		}
+		for _, product := range q.tables.interestProducts {
+			if product.Currency == account.Currency && product.AccountType == account.AccountType && sign(product.AnnualRate) > 0 {
+				rows = append(rows, db.ListInterestBearingAccountsRow{Account: account, InterestProduct: product})
+			}
+		}
+	}
+	return rows, nil
+}
+
+// CreateInterestAccrual inserts nothing, and reports no rows, when the account already has
+// an accrual for the day.
+func (q *queries) CreateInterestAccrual(ctx context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
+	if !arg.DayCount.Valid() {
+		return 0, invalidEnum("day_count_convention", arg.DayCount)
+	}
+	balance, err := money("balance", arg.Balance)
+	if err != nil {
+		return 0, err
+	}
+	annualRate, err := rate("annual_rate", arg.AnnualRate)
+	if err != nil {
+		return 0, err
+	}
+	amount, err := numeric("amount", arg.Amount, 20, 10)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := q.tables.accounts[arg.AccountID]; !ok {
+		return 0, foreignKeyViolation("interest_accruals", "interest_accruals_account_id_fkey")
+	}
+	if _, ok := q.tables.interestProducts[arg.ProductID]; !ok {
+		return 0, foreignKeyViolation("interest_accruals", "interest_accruals_product_id_fkey")
+	}
+	for _, accrual := range q.tables.interestAccruals {
+		if accrual.AccountID == arg.AccountID && compareDates(accrual.AccrualDate, arg.AccrualDate) == 0 {
+			return 0, nil
+		}
+	}
+
+	accrual := db.InterestAccrual{
+		ID:          q.nextID("interest_accruals"),
+		AccountID:   arg.AccountID,
+		ProductID:   arg.ProductID,
+		AccrualDate: arg.AccrualDate,
+		Balance:     balance,
+		AnnualRate:  annualRate,
+		DayCount:    arg.DayCount,
+		Amount:      amount,
+		CreatedAt:   q.timestamp(),
+	}
+	q.tables.interestAccruals[accrual.ID] = accrual
+	return 1, nil
+}
+
+func (q *queries) GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error) {
+	var latest pgtype.Date
+	for _, accrual := range q.tables.interestAccruals {
+		if !latest.Valid || compareDates(accrual.AccrualDate, latest) > 0 {
+			latest = accrual.AccrualDate
+		}
+	}
+	return latest, nil
+}
+
+func (q *queries) ListInterestAccrualsForAccount(ctx context.Context, arg db.ListInterestAccrualsForAccountParams) ([]db.InterestAccrual, error) {
+	accruals := selectRows(q.tables.interestAccruals, func(accrual db.InterestAccrual) bool {
+		return accrual.AccountID == arg.AccountID
+	}, func(a, b db.InterestAccrual) int {
+		return compareDates(a.AccrualDate, b.AccrualDate)
+	})
+	return page(accruals, arg.Limit, arg.Offset)
+}
+
+// monthStart is date_trunc('month', date).
+func monthStart(date pgtype.Date) pgtype.Date {
+	year, month, _ := date.Time.Date()
+	return pgtype.Date{Time: time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+}
+
+func (q *queries) ListUnpostedInterestPeriods(ctx context.Context, before pgtype.Date) ([]db.ListUnpostedInterestPeriodsRow, error) {
+	periods := make(map[db.ListUnpostedInterestPeriodsRow]bool)
+	for _, accrual := range q.tables.interestAccruals {
+		if !accrual.PostingID.Valid && compareDates(accrual.AccrualDate, before) < 0 {
+			periods[db.ListUnpostedInterestPeriodsRow{AccountID: accrual.AccountID, Period: monthStart(accrual.AccrualDate)}] = true
+		}
+	}
+
+	rows := []db.ListUnpostedInterestPeriodsRow{}
+	for period := range periods {
+		rows = append(rows, period)
+	}
+	slices.SortFunc(rows, func(a, b db.ListUnpostedInterestPeriodsRow) int {
+		return cmp.Or(compareDates(a.Period, b.Period), cmp.Compare(a.AccountID, b.AccountID))
+	})
+	return rows, nil
+}
+
+// unposted reports whether accrual is one of the account's unposted accruals in [start, end).
+func unposted(accrual db.InterestAccrual, accountID int64, start, end pgtype.Date) bool {
+	return accrual.AccountID == accountID &&
+		!accrual.PostingID.Valid &&
+		compareDates(accrual.AccrualDate, start) >= 0 &&
+		compareDates(accrual.AccrualDate, end) < 0
+}
+
+func (q *queries) SumUnpostedInterestAccruals(ctx context.Context, arg db.SumUnpostedInterestAccrualsParams) (pgtype.Numeric, error) {
+	var amounts []pgtype.Numeric
+	for _, accrual := range q.tables.interestAccruals {
+		if unposted(accrual, arg.AccountID, arg.PeriodStart, arg.PeriodEnd) {
+			amounts = append(amounts, accrual.Amount)
+		}
+	}
+	return sum(amounts, 10), nil
+}
+
+func (q *queries) MarkInterestAccrualsPosted(ctx context.Context, arg db.MarkInterestAccrualsPostedParams) (int64, error) {
+	if arg.PostingID.Valid {
+		if _, ok := q.tables.interestPostings[arg.PostingID.Int64]; !ok {
+			return 0, foreignKeyViolation("interest_accruals", "interest_accruals_posting_id_fkey")
+		}
+	}
+
+	var marked int64
+	for id, accrual := range q.tables.interestAccruals {
+		if unposted(accrual, arg.AccountID, arg.PeriodStart, arg.PeriodEnd) {
+			accrual.PostingID = arg.PostingID
+			q.tables.interestAccruals[id] = accrual
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (q *queries) GetInterestPosting(ctx context.Context, arg db.GetInterestPostingParams) (db.InterestPosting, error) {
+	for _, posting := range q.tables.interestPostings {
+		if posting.AccountID == arg.AccountID && compareDates(posting.Period, arg.Period) == 0 {
+			return posting, nil
+		}
+	}
+	return db.InterestPosting{}, pgx.ErrNoRows
+}
+
+func (q *queries) CreateInterestPosting(ctx context.Context, arg db.CreateInterestPostingParams) (db.InterestPosting, error) {
+	amount, err := money("amount", arg.Amount)
+	if err != nil {
+		return db.InterestPosting{}, err
+	}
+	for _, posting := range q.tables.interestPostings {
+		if posting.AccountID == arg.AccountID && compareDates(posting.Period, arg.Period) == 0 {
+			return db.InterestPosting{}, uniqueViolation("interest_postings", "interest_postings_account_id_period_idx")
+		}
+	}
+	if _, ok := q.tables.accounts[arg.AccountID]; !ok {
+		return db.InterestPosting{}, foreignKeyViolation("interest_postings", "interest_postings_account_id_fkey")
+	}
+	if arg.TransferID.Valid {
+		if _, ok := q.tables.transfers[arg.TransferID.Int64]; !ok {
+			return db.InterestPosting{}, foreignKeyViolation("interest_postings", "interest_postings_transfer_id_fkey")
+		}
+	}
+
+	posting := db.InterestPosting{
+		ID:         q.nextID("interest_postings"),
+		AccountID:  arg.AccountID,
+		Period:     arg.Period,
+		Amount:     amount,
+		TransferID: arg.TransferID,
+		CreatedAt:  q.timestamp(),
+	}
+	q.tables.interestPostings[posting.ID] = posting
+	return posting, nil
+}
+
+func (store *Store) UpsertInterestProduct(ctx context.Context, arg db.UpsertInterestProductParams) (db.InterestProduct, error) {
+	return autocommit(ctx, store, func(q *queries) (db.InterestProduct, error) { return q.UpsertInterestProduct(ctx, arg) })
+}
+
+func (store *Store) GetInterestProduct(ctx context.Context, arg db.GetInterestProductParams) (db.InterestProduct, error) {
+	return autocommit(ctx, store, func(q *queries) (db.InterestProduct, error) { return q.GetInterestProduct(ctx, arg) })
+}
+
+func (store *Store) ListInterestProducts(ctx context.Context) ([]db.InterestProduct, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.InterestProduct, error) { return q.ListInterestProducts(ctx) })
+}
+
+func (store *Store) ListInterestBearingAccounts(ctx context.Context) ([]db.ListInterestBearingAccountsRow, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.ListInterestBearingAccountsRow, error) {
+		return q.ListInterestBearingAccounts(ctx)
+	})
+}
+
+func (store *Store) CreateInterestAccrual(ctx context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.CreateInterestAccrual(ctx, arg) })
+}
+
+func (store *Store) GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error) {
+	return autocommit(ctx, store, func(q *queries) (pgtype.Date, error) { return q.GetLatestInterestAccrualDate(ctx) })
+}
+
+func (store *Store) ListInterestAccrualsForAccount(ctx context.Context, arg db.ListInterestAccrualsForAccountParams) ([]db.InterestAccrual, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.InterestAccrual, error) { return q.ListInterestAccrualsForAccount(ctx, arg) })
+}
+
+func (store *Store) ListUnpostedInterestPeriods(ctx context.Context, before pgtype.Date) ([]db.ListUnpostedInterestPeriodsRow, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.ListUnpostedInterestPeriodsRow, error) {
+		return q.ListUnpostedInterestPeriods(ctx, before)
+	})
+}
+
+func (store *Store) SumUnpostedInterestAccruals(ctx context.Context, arg db.SumUnpostedInterestAccrualsParams) (pgtype.Numeric, error) {
+	return autocommit(ctx, store, func(q *queries) (pgtype.Numeric, error) { return q.SumUnpostedInterestAccruals(ctx, arg) })
+}
+
+func (store *Store) MarkInterestAccrualsPosted(ctx context.Context, arg db.MarkInterestAccrualsPostedParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.MarkInterestAccrualsPosted(ctx, arg) })
+}
+
+func (store *Store) GetInterestPosting(ctx context.Context, arg db.GetInterestPostingParams) (db.InterestPosting, error) {
+	return autocommit(ctx, store, func(q *queries) (db.InterestPosting, error) { return q.GetInterestPosting(ctx, arg) })
+}
+
+func (store *Store) CreateInterestPosting(ctx context.Context, arg db.CreateInterestPostingParams) (db.InterestPosting, error) {
+	return autocommit(ctx, store, func(q *queries) (db.InterestPosting, error) { return q.CreateInterestPosting(ctx, arg) })
+}
@@ -0,0 +1,83 @@
+package memstore
+
+import (
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type loginFailureKey struct {
+	scope db.LoginFailureScope
+	key   string
+}
+
+func (q *queries) GetLoginFailure(ctx context.Context, arg db.GetLoginFailureParams) (db.LoginFailure, error) {
+	failure, ok := q.tables.loginFailures[loginFailureKey{arg.Scope, arg.Key}]
+	if !ok {
+		return db.LoginFailure{}, pgx.ErrNoRows
+	}
+	return failure, nil
+}
+
+func (q *queries) RecordLoginFailure(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+	if !arg.Scope.Valid() {
+		return db.LoginFailure{}, invalidEnum("login_failure_scope", arg.Scope)
+	}
+
+	key := loginFailureKey{arg.Scope, arg.Key}
+	failure, ok := q.tables.loginFailures[key]
+	lockEnded := failure.LockedUntil.Valid && !failure.LockedUntil.Time.After(q.now)
+	switch {
+	case !ok:
+		failure = db.LoginFailure{Scope: arg.Scope, Key: arg.Key, Failures: 1}
+	case failure.LastFailedAt.Time.Before(arg.WindowStart.Time) || lockEnded:
+		failure.Failures = 1
+	default:
+		failure.Failures++
+	}
+	if lockEnded {
+		failure.LockedUntil = pgtype.Timestamptz{}
+	}
+	failure.LastFailedAt = q.timestamp()
+
+	q.tables.loginFailures[key] = failure
+	return failure, nil
+}
+
+func (q *queries) LockLogin(ctx context.Context, arg db.LockLoginParams) (db.LoginFailure, error) {
+	key := loginFailureKey{arg.Scope, arg.Key}
+	failure, ok := q.tables.loginFailures[key]
+	if !ok {
+		return db.LoginFailure{}, pgx.ErrNoRows
+	}
+	failure.LockedUntil = arg.LockedUntil
+	q.tables.loginFailures[key] = failure
+	return failure, nil
+}
+
+func (q *queries) DeleteLoginFailure(ctx context.Context, arg db.DeleteLoginFailureParams) (int64, error) {
+	key := loginFailureKey{arg.Scope, arg.Key}
+	if _, ok := q.tables.loginFailures[key]; !ok {
+		return 0, nil
+	}
+	delete(q.tables.loginFailures, key)
+	return 1, nil
+}
+
+func (store *Store) GetLoginFailure(ctx context.Context, arg db.GetLoginFailureParams) (db.LoginFailure, error) {
+	return autocommit(ctx, store, func(q *queries) (db.LoginFailure, error) { return q.GetLoginFailure(ctx, arg) })
+}
+
+func (store *Store) RecordLoginFailure(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+	return autocommit(ctx, store, func(q *queries) (db.LoginFailure, error) { return q.RecordLoginFailure(ctx, arg) })
+}
+
+func (store *Store) LockLogin(ctx context.Context, arg db.LockLoginParams) (db.LoginFailure, error) {
+	return autocommit(ctx, store, func(q *queries) (db.LoginFailure, error) { return q.LockLogin(ctx, arg) })
+}
+
+func (store *Store) DeleteLoginFailure(ctx context.Context, arg db.DeleteLoginFailureParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.DeleteLoginFailure(ctx, arg) })
+}
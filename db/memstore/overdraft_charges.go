@@ -0,0 +1,85 @@
+package memstore
+
+import (
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func compareDates(a, b pgtype.Date) int {
+	return a.Time.Compare(b.Time)
+}
+
+func (q *queries) CreateOverdraftCharge(ctx context.Context, arg db.CreateOverdraftChargeParams) (db.OverdraftCharge, error) {
+	balance, err := money("balance", arg.Balance)
+	if err != nil {
+		return db.OverdraftCharge{}, err
+	}
+	annualRate, err := rate("annual_rate", arg.AnnualRate)
+	if err != nil {
+		return db.OverdraftCharge{}, err
+	}
+	amount, err := money("amount", arg.Amount)
+	if err != nil {
+		return db.OverdraftCharge{}, err
+	}
+	if sign(amount) <= 0 {
+		return db.OverdraftCharge{}, checkViolation("overdraft_charges", "overdraft_charges_amount_check")
+	}
+	for _, charge := range q.tables.overdraftCharges {
+		if charge.AccountID == arg.AccountID && compareDates(charge.ChargeDate, arg.ChargeDate) == 0 {
+			return db.OverdraftCharge{}, uniqueViolation("overdraft_charges", "overdraft_charges_account_id_charge_date_idx")
+		}
+	}
+	if _, ok := q.tables.accounts[arg.AccountID]; !ok {
+		return db.OverdraftCharge{}, foreignKeyViolation("overdraft_charges", "overdraft_charges_account_id_fkey")
+	}
+	if _, ok := q.tables.entries[arg.EntryID]; !ok {
+		return db.OverdraftCharge{}, foreignKeyViolation("overdraft_charges", "overdraft_charges_entry_id_fkey")
+	}
+
+	charge := db.OverdraftCharge{
+		ID:         q.nextID("overdraft_charges"),
+		AccountID:  arg.AccountID,
+		EntryID:    arg.EntryID,
+		ChargeDate: arg.ChargeDate,
+		Balance:    balance,
+		AnnualRate: annualRate,
+		Amount:     amount,
+		CreatedAt:  q.timestamp(),
+	}
+	q.tables.overdraftCharges[charge.ID] = charge
+	return charge, nil
+}
+
+func (q *queries) GetOverdraftCharge(ctx context.Context, arg db.GetOverdraftChargeParams) (db.OverdraftCharge, error) {
+	for _, charge := range q.tables.overdraftCharges {
+		if charge.AccountID == arg.AccountID && compareDates(charge.ChargeDate, arg.ChargeDate) == 0 {
+			return charge, nil
+		}
+	}
+	return db.OverdraftCharge{}, pgx.ErrNoRows
+}
+
+func (q *queries) ListOverdraftChargesForAccount(ctx context.Context, arg db.ListOverdraftChargesForAccountParams) ([]db.OverdraftCharge, error) {
+	charges := selectRows(q.tables.overdraftCharges, func(charge db.OverdraftCharge) bool {
+		return charge.AccountID == arg.AccountID
+	}, func(a, b db.OverdraftCharge) int {
+		return compareDates(a.ChargeDate, b.ChargeDate)
+	})
+	return page(charges, arg.Limit, arg.Offset)
+}
+
+func (store *Store) CreateOverdraftCharge(ctx context.Context, arg db.CreateOverdraftChargeParams) (db.OverdraftCharge, error) {
+	return autocommit(ctx, store, func(q *queries) (db.OverdraftCharge, error) { return q.CreateOverdraftCharge(ctx, arg) })
+}
+
+func (store *Store) GetOverdraftCharge(ctx context.Context, arg db.GetOverdraftChargeParams) (db.OverdraftCharge, error) {
+	return autocommit(ctx, store, func(q *queries) (db.OverdraftCharge, error) { return q.GetOverdraftCharge(ctx, arg) })
+}
+
+func (store *Store) ListOverdraftChargesForAccount(ctx context.Context, arg db.ListOverdraftChargesForAccountParams) ([]db.OverdraftCharge, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.OverdraftCharge, error) { return q.ListOverdraftChargesForAccount(ctx, arg) })
+}
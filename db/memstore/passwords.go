@@ -0,0 +1,163 @@
+package memstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"strings"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (q *queries) GetUserByEmail(ctx context.Context, arg db.GetUserByEmailParams) (db.User, error) {
+	for _, user := range q.tables.users {
+		if user.EmailIndex != nil && arg.EmailIndex != nil && bytes.Equal(user.EmailIndex, arg.EmailIndex) ||
+			user.DataKey == nil && strings.EqualFold(user.Email, arg.Email) {
+			return user, nil
+		}
+	}
+	return db.User{}, pgx.ErrNoRows
+}
+
+func (q *queries) GetUserTokensValidFrom(ctx context.Context, username string) (pgtype.Timestamptz, error) {
+	user, ok := q.tables.users[username]
+	if !ok {
+		return pgtype.Timestamptz{}, pgx.ErrNoRows
+	}
+	if user.TokensRevokedAt.Valid && (!user.PasswordChangedAt.Valid || user.TokensRevokedAt.Time.After(user.PasswordChangedAt.Time)) {
+		return user.TokensRevokedAt, nil
+	}
+	return user.PasswordChangedAt, nil
+}
+
+func (q *queries) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	return q.updateUser(arg.Username, func(user *db.User) error {
+		user.PasswordHash = arg.PasswordHash
+		user.PasswordChangedAt = arg.PasswordChangedAt
+		return nil
+	})
+}
+
+func (q *queries) CreatePasswordHistory(ctx context.Context, arg db.CreatePasswordHistoryParams) (db.PasswordHistory, error) {
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.PasswordHistory{}, foreignKeyViolation("password_history", "password_history_username_fkey")
+	}
+
+	entry := db.PasswordHistory{
+		ID:           q.nextID("password_history"),
+		Username:     arg.Username,
+		PasswordHash: arg.PasswordHash,
+		CreatedAt:    q.timestamp(),
+	}
+	q.tables.passwordHistory[entry.ID] = entry
+	return entry, nil
+}
+
+func (q *queries) ListPasswordHistory(ctx context.Context, arg db.ListPasswordHistoryParams) ([]db.PasswordHistory, error) {
+	history := selectRows(q.tables.passwordHistory,
+		func(entry db.PasswordHistory) bool { return entry.Username == arg.Username },
+		func(a, b db.PasswordHistory) int { return cmp.Compare(b.ID, a.ID) })
+	return page(history, arg.Limit, 0)
+}
+
+func (q *queries) CreatePasswordResetToken(ctx context.Context, arg db.CreatePasswordResetTokenParams) (db.PasswordResetToken, error) {
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.PasswordResetToken{}, foreignKeyViolation("password_reset_tokens", "password_reset_tokens_username_fkey")
+	}
+	if _, ok := q.tables.passwordResetTokens[arg.TokenHash]; ok {
+		return db.PasswordResetToken{}, uniqueViolation("password_reset_tokens", "password_reset_tokens_pkey")
+	}
+
+	token := db.PasswordResetToken{
+		TokenHash: arg.TokenHash,
+		Username:  arg.Username,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: q.timestamp(),
+	}
+	q.tables.passwordResetTokens[token.TokenHash] = token
+	return token, nil
+}
+
+// validResetToken reports whether a token exists and has not expired at the time of the
+// query, like expires_at > now().
+func (q *queries) validResetToken(tokenHash string) (db.PasswordResetToken, bool) {
+	token, ok := q.tables.passwordResetTokens[tokenHash]
+	return token, ok && token.ExpiresAt.Time.After(q.now)
+}
+
+func (q *queries) GetPasswordResetToken(ctx context.Context, tokenHash string) (db.PasswordResetToken, error) {
+	token, ok := q.validResetToken(tokenHash)
+	if !ok {
+		return db.PasswordResetToken{}, pgx.ErrNoRows
+	}
+	return token, nil
+}
+
+func (q *queries) UsePasswordResetToken(ctx context.Context, arg db.UsePasswordResetTokenParams) (int64, error) {
+	token, ok := q.validResetToken(arg.TokenHash)
+	if !ok || token.Username != arg.Username {
+		return 0, nil
+	}
+	delete(q.tables.passwordResetTokens, arg.TokenHash)
+	return 1, nil
+}
+
+func (q *queries) DeletePasswordResetTokens(ctx context.Context, username string) error {
+	for hash, token := range q.tables.passwordResetTokens {
+		if token.Username == username {
+			delete(q.tables.passwordResetTokens, hash)
+		}
+	}
+	return nil
+}
+
+func (q *queries) DeletePasswordHistory(ctx context.Context, username string) error {
+	for id, entry := range q.tables.passwordHistory {
+		if entry.Username == username {
+			delete(q.tables.passwordHistory, id)
+		}
+	}
+	return nil
+}
+
+func (store *Store) GetUserByEmail(ctx context.Context, arg db.GetUserByEmailParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.GetUserByEmail(ctx, arg) })
+}
+
+func (store *Store) GetUserTokensValidFrom(ctx context.Context, username string) (pgtype.Timestamptz, error) {
+	return autocommit(ctx, store, func(q *queries) (pgtype.Timestamptz, error) { return q.GetUserTokensValidFrom(ctx, username) })
+}
+
+func (store *Store) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.UpdateUserPassword(ctx, arg) })
+}
+
+func (store *Store) CreatePasswordHistory(ctx context.Context, arg db.CreatePasswordHistoryParams) (db.PasswordHistory, error) {
+	return autocommit(ctx, store, func(q *queries) (db.PasswordHistory, error) { return q.CreatePasswordHistory(ctx, arg) })
+}
+
+func (store *Store) ListPasswordHistory(ctx context.Context, arg db.ListPasswordHistoryParams) ([]db.PasswordHistory, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.PasswordHistory, error) { return q.ListPasswordHistory(ctx, arg) })
+}
+
+func (store *Store) CreatePasswordResetToken(ctx context.Context, arg db.CreatePasswordResetTokenParams) (db.PasswordResetToken, error) {
+	return autocommit(ctx, store, func(q *queries) (db.PasswordResetToken, error) { return q.CreatePasswordResetToken(ctx, arg) })
+}
+
+func (store *Store) GetPasswordResetToken(ctx context.Context, tokenHash string) (db.PasswordResetToken, error) {
+	return autocommit(ctx, store, func(q *queries) (db.PasswordResetToken, error) { return q.GetPasswordResetToken(ctx, tokenHash) })
+}
+
+func (store *Store) UsePasswordResetToken(ctx context.Context, arg db.UsePasswordResetTokenParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.UsePasswordResetToken(ctx, arg) })
+}
+
+func (store *Store) DeletePasswordResetTokens(ctx context.Context, username string) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeletePasswordResetTokens(ctx, username) })
+}
+
+func (store *Store) DeletePasswordHistory(ctx context.Context, username string) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeletePasswordHistory(ctx, username) })
+}
@@ -0,0 +1,47 @@
+package memstore
+
+import (
+	"context"
+
+	db "example.com/db/sqlc"
+)
+
+type requestNonceKey struct {
+	apiKeyID int64
+	nonce    string
+}
+
+func (q *queries) UseRequestNonce(ctx context.Context, arg db.UseRequestNonceParams) (int64, error) {
+	if !arg.ExpiresAt.Valid {
+		return 0, notNull("expires_at")
+	}
+	if _, ok := q.tables.apiKeys[arg.ApiKeyID]; !ok {
+		return 0, foreignKeyViolation("request_nonces", "request_nonces_api_key_id_fkey")
+	}
+
+	key := requestNonceKey{arg.ApiKeyID, arg.Nonce}
+	if used, ok := q.tables.requestNonces[key]; ok && used.ExpiresAt.Time.After(q.now) {
+		return 0, nil
+	}
+	q.tables.requestNonces[key] = db.RequestNonce{ApiKeyID: arg.ApiKeyID, Nonce: arg.Nonce, ExpiresAt: arg.ExpiresAt}
+	return 1, nil
+}
+
+func (q *queries) DeleteExpiredRequestNonces(ctx context.Context) (int64, error) {
+	var deleted int64
+	for key, used := range q.tables.requestNonces {
+		if !used.ExpiresAt.Time.After(q.now) {
+			delete(q.tables.requestNonces, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (store *Store) UseRequestNonce(ctx context.Context, arg db.UseRequestNonceParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.UseRequestNonce(ctx, arg) })
+}
+
+func (store *Store) DeleteExpiredRequestNonces(ctx context.Context) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.DeleteExpiredRequestNonces(ctx) })
+}
@@ -0,0 +1,82 @@
+package memstore
+
+import (
+	"math/big"
+	"slices"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// numeric stores n in a numeric(precision, scale) column: it is rounded to scale places
+// and must fit in precision digits.
+func numeric(column string, n pgtype.Numeric, precision, scale int32) (pgtype.Numeric, error) {
+	if !n.Valid {
+		return n, notNull(column)
+	}
+
+	rounded := db.RatToNumeric(db.NumericToRat(n), scale)
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	if new(big.Int).Abs(rounded.Int).Cmp(limit) >= 0 {
+		return n, pgError(codeNumericOutOfRange, "numeric field overflow")
+	}
+	return rounded, nil
+}
+
+// money stores n in one of the numeric(10,2) amount columns.
+func money(column string, n pgtype.Numeric) (pgtype.Numeric, error) {
+	return numeric(column, n, 10, 2)
+}
+
+// rate stores n in one of the numeric(8,6) rate columns.
+func rate(column string, n pgtype.Numeric) (pgtype.Numeric, error) {
+	return numeric(column, n, 8, 6)
+}
+
+func sign(n pgtype.Numeric) int {
+	return db.NumericToRat(n).Sign()
+}
+
+// sum adds up amounts like SUM in Postgres, at the scale of the column; no rows sum to a plain 0.
+func sum(amounts []pgtype.Numeric, scale int32) pgtype.Numeric {
+	if len(amounts) == 0 {
+		return pgtype.Numeric{Int: big.NewInt(0), Valid: true}
+	}
+
+	total := new(big.Rat)
+	for _, amount := range amounts {
+		total.Add(total, db.NumericToRat(amount))
+	}
+	return db.RatToNumeric(total, scale)
+}
+
+// selectRows returns the rows of a table that match where, sorted by compare.
+func selectRows[K comparable, T any](table map[K]T, where func(T) bool, compare func(a, b T) int) []T {
+	rows := []T{}
+	for _, row := range table {
+		if where == nil || where(row) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, compare)
+	return rows
+}
+
+// page applies LIMIT and OFFSET.
+func page[T any](rows []T, limit, offset int32) ([]T, error) {
+	if limit < 0 {
+		return nil, pgError(codeInvalidRowCountLimit, "LIMIT must not be negative")
+	}
+	if offset < 0 {
+		return nil, pgError(codeInvalidRowCountOffset, "OFFSET must not be negative")
+	}
+
+	if int(offset) >= len(rows) {
+		return []T{}, nil
+	}
+	rows = rows[offset:]
+	if int(limit) < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
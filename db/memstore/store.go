@@ -0,0 +1,246 @@
+// Package memstore keeps the bank in memory. Its Store implements db.Store with the same
+// transactions as the Postgres store, and its queries fail the way Postgres does: a missing
+// row is pgx.ErrNoRows and a violated constraint is a *pgconn.PgError with the same code.
+// It is meant for tests and demos; nothing survives a restart.
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"sync"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Store is an in-memory db.Store. Transactions run one at a time, which is stricter than
+// the row locks Postgres takes but gives the same results to TransferTx and the other
+// transactions: they never see each other's uncommitted changes or lose an update.
+type Store struct {
+	db.Transactions
+
+	mu        sync.Mutex
+	tables    *tables
+	sequences map[string]int64
+	listeners []func(db.AccountEvent)
+}
+
+var _ db.Store = (*Store)(nil)
+
+// New returns an empty store holding the same house user the migrations create.
+func New() *Store {
+	store := &Store{
+		tables:    newTables(),
+		sequences: make(map[string]int64),
+	}
+	store.Transactions = db.NewTransactions(store)
+
+	// The house user owns the bank's own accounts; see migration 000004.
+	store.tables.users["bank"] = db.User{
+		Username:     "bank",
+		PasswordHash: "!",
+		FullName:     "Simple Bank",
+		Email:        "house@simplebank.invalid",
+		Role:         db.UserRoleCustomer,
+		CraetedAt:    pgtype.Timestamptz{Time: time.Now().Truncate(time.Microsecond), Valid: true},
+	}
+	return store
+}
+
+// Listen calls fn with every AccountEvent after the transaction that raised it commits,
+// as the Postgres store's LISTEN connection would hear it.
+func (store *Store) Listen(fn func(db.AccountEvent)) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.listeners = append(store.listeners, fn)
+}
+
+// ExecTx runs fn on a copy of the tables and keeps the copy if fn succeeds.
+func (store *Store) ExecTx(ctx context.Context, fn func(db.Querier) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return store.run(true, func(q *queries) error { return fn(q) })
+}
+
+// Ping always succeeds: there is no connection to lose and no schema to migrate.
+func (store *Store) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// autocommit runs a query outside a transaction. Every query checks its constraints before
+// it changes anything, so like a single statement in Postgres it applies entirely or not at all.
+func autocommit[T any](ctx context.Context, store *Store, query func(*queries) (T, error)) (T, error) {
+	var result T
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	err := store.run(false, func(q *queries) error {
+		var err error
+		result, err = query(q)
+		return err
+	})
+	return result, err
+}
+
+func autocommitExec(ctx context.Context, store *Store, exec func(*queries) error) error {
+	_, err := autocommit(ctx, store, func(q *queries) (struct{}, error) {
+		return struct{}{}, exec(q)
+	})
+	return err
+}
+
+// run runs fn with the store locked, on a copy of the tables or on the tables themselves,
+// and commits the tables fn worked on if it succeeds. Notifications go out after the commit.
+func (store *Store) run(copyTables bool, fn func(*queries) error) error {
+	q, listeners, err := func() (*queries, []func(db.AccountEvent), error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		t := store.tables
+		if copyTables {
+			t = t.clone()
+		}
+		q := &queries{
+			tables:    t,
+			sequences: store.sequences,
+			now:       time.Now().Truncate(time.Microsecond),
+		}
+		if err := fn(q); err != nil {
+			return nil, nil, err
+		}
+
+		store.tables = q.tables
+		return q, store.listeners, nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	for _, event := range q.notifications {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+	return nil
+}
+
+// queries implements db.Querier on one version of the tables.
+type queries struct {
+	tables *tables
+	// sequences hand out IDs. Like Postgres sequences they are not rolled back, so an ID is
+	// never reused.
+	sequences map[string]int64
+	// now is the time of every default timestamp in a transaction, like now() in Postgres.
+	now time.Time
+
+	notifications []db.AccountEvent
+}
+
+var _ db.Querier = (*queries)(nil)
+
+func (q *queries) nextID(table string) int64 {
+	q.sequences[table]++
+	return q.sequences[table]
+}
+
+func (q *queries) timestamp() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: q.now, Valid: true}
+}
+
+func (q *queries) NotifyAccountEvent(ctx context.Context, arg db.NotifyAccountEventParams) error {
+	if arg.Channel != db.AccountEventsChannel {
+		return nil
+	}
+
+	var event db.AccountEvent
+	if err := json.Unmarshal([]byte(arg.Payload), &event); err != nil {
+		return err
+	}
+	q.notifications = append(q.notifications, event)
+	return nil
+}
+
+func (store *Store) NotifyAccountEvent(ctx context.Context, arg db.NotifyAccountEventParams) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.NotifyAccountEvent(ctx, arg) })
+}
+
+// tables holds a version of every table.
+type tables struct {
+	users                map[string]db.User
+	accounts             map[int64]db.Account
+	entries              map[int64]db.Entry
+	transfers            map[int64]db.Transfer
+	overdraftCharges     map[int64]db.OverdraftCharge
+	interestProducts     map[int64]db.InterestProduct
+	interestAccruals     map[int64]db.InterestAccrual
+	interestPostings     map[int64]db.InterestPosting
+	webhookSubscriptions map[int64]db.WebhookSubscription
+	webhookEvents        map[int64]db.WebhookEvent
+	webhookDeliveries    map[int64]db.WebhookDelivery
+	totpBackupCodes      map[int64]db.TotpBackupCode
+	passwordHistory      map[int64]db.PasswordHistory
+	passwordResetTokens  map[string]db.PasswordResetToken
+	loginFailures        map[loginFailureKey]db.LoginFailure
+	verifyEmails         map[int64]db.VerifyEmail
+	apiKeys              map[int64]db.ApiKey
+	requestNonces        map[requestNonceKey]db.RequestNonce
+	auditLog             map[int64]db.AuditLog
+	dataRequests         map[int64]db.DataRequest
+}
+
+func newTables() *tables {
+	return &tables{
+		users:                make(map[string]db.User),
+		accounts:             make(map[int64]db.Account),
+		entries:              make(map[int64]db.Entry),
+		transfers:            make(map[int64]db.Transfer),
+		overdraftCharges:     make(map[int64]db.OverdraftCharge),
+		interestProducts:     make(map[int64]db.InterestProduct),
+		interestAccruals:     make(map[int64]db.InterestAccrual),
+		interestPostings:     make(map[int64]db.InterestPosting),
+		webhookSubscriptions: make(map[int64]db.WebhookSubscription),
+		webhookEvents:        make(map[int64]db.WebhookEvent),
+		webhookDeliveries:    make(map[int64]db.WebhookDelivery),
+		totpBackupCodes:      make(map[int64]db.TotpBackupCode),
+		passwordHistory:      make(map[int64]db.PasswordHistory),
+		passwordResetTokens:  make(map[string]db.PasswordResetToken),
+		loginFailures:        make(map[loginFailureKey]db.LoginFailure),
+		verifyEmails:         make(map[int64]db.VerifyEmail),
+		apiKeys:              make(map[int64]db.ApiKey),
+		requestNonces:        make(map[requestNonceKey]db.RequestNonce),
+		auditLog:             make(map[int64]db.AuditLog),
+		dataRequests:         make(map[int64]db.DataRequest),
+	}
+}
+
+// clone copies the maps. Rows are values and are replaced rather than changed in place, so
+// the copies can share them.
+func (t *tables) clone() *tables {
+	return &tables{
+		users:                maps.Clone(t.users),
+		accounts:             maps.Clone(t.accounts),
+		entries:              maps.Clone(t.entries),
+		transfers:            maps.Clone(t.transfers),
+		overdraftCharges:     maps.Clone(t.overdraftCharges),
+		interestProducts:     maps.Clone(t.interestProducts),
+		interestAccruals:     maps.Clone(t.interestAccruals),
+		interestPostings:     maps.Clone(t.interestPostings),
+		webhookSubscriptions: maps.Clone(t.webhookSubscriptions),
+		webhookEvents:        maps.Clone(t.webhookEvents),
+		webhookDeliveries:    maps.Clone(t.webhookDeliveries),
+		totpBackupCodes:      maps.Clone(t.totpBackupCodes),
+		passwordHistory:      maps.Clone(t.passwordHistory),
+		passwordResetTokens:  maps.Clone(t.passwordResetTokens),
+		loginFailures:        maps.Clone(t.loginFailures),
+		verifyEmails:         maps.Clone(t.verifyEmails),
+		apiKeys:              maps.Clone(t.apiKeys),
+		requestNonces:        maps.Clone(t.requestNonces),
+		auditLog:             maps.Clone(t.auditLog),
+		dataRequests:         maps.Clone(t.dataRequests),
+	}
+}
@@ -0,0 +1,89 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	db "example.com/db/sqlc"
+	"example.com/db/storetest"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Store {
+		return New()
+	})
+}
+
+func TestListenAfterCommit(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	var events []db.AccountEvent
+	store.Listen(func(event db.AccountEvent) {
+		events = append(events, event)
+	})
+
+	_, err := store.CreateUser(ctx, db.CreateUserParams{Username: "alice", Email: "alice@example.com"})
+	require.NoError(t, err)
+	from, err := store.CreateAccountTx(ctx, db.CreateAccountParams{Owner: "alice", Balance: amount(100), Currency: "USD", AccountType: db.AccountTypeChecking})
+	require.NoError(t, err)
+	to, err := store.CreateAccountTx(ctx, db.CreateAccountParams{Owner: "alice", Balance: amount(0), Currency: "EUR", AccountType: db.AccountTypeChecking})
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: from.ID, ToAccountId: to.ID, Amount: 10})
+	require.NoError(t, err)
+	require.Equal(t, []db.AccountEvent{
+		{AccountID: from.ID, EntryID: result.FromEntry.ID},
+		{AccountID: to.ID, EntryID: result.ToEntry.ID},
+	}, events)
+
+	// a rolled back transaction notifies nobody
+	events = nil
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: from.ID, ToAccountId: to.ID, Amount: 1000})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+	require.Empty(t, events)
+}
+
+func TestExecTxIsolation(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+	errRollback := errors.New("roll back")
+
+	err := store.ExecTx(ctx, func(q db.Querier) error {
+		_, err := q.CreateUser(ctx, db.CreateUserParams{Username: "bob", Email: "bob@example.com"})
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	// IDs handed out in a rolled back transaction are not reused
+	_, err = store.CreateUser(ctx, db.CreateUserParams{Username: "bob", Email: "bob@example.com"})
+	require.NoError(t, err)
+	first, err := store.CreateAccount(ctx, db.CreateAccountParams{Owner: "bob", Balance: amount(0), Currency: "USD", AccountType: db.AccountTypeChecking})
+	require.NoError(t, err)
+	err = store.ExecTx(ctx, func(q db.Querier) error {
+		_, err := q.CreateAccount(ctx, db.CreateAccountParams{Owner: "bob", Balance: amount(0), Currency: "EUR", AccountType: db.AccountTypeChecking})
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	second, err := store.CreateAccount(ctx, db.CreateAccountParams{Owner: "bob", Balance: amount(0), Currency: "EUR", AccountType: db.AccountTypeChecking})
+	require.NoError(t, err)
+	require.Equal(t, first.ID+2, second.ID)
+
+	// a panicking transaction releases the store
+	require.Panics(t, func() {
+		_ = store.ExecTx(ctx, func(q db.Querier) error { panic("boom") })
+	})
+	require.NoError(t, store.Ping(ctx))
+	_, err = store.GetAccount(ctx, first.ID)
+	require.NoError(t, err)
+}
+
+func amount(n int64) pgtype.Numeric {
+	return db.RatToNumeric(new(big.Rat).SetInt64(n), 2)
+}
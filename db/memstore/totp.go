@@ -0,0 +1,104 @@
+package memstore
+
+import (
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (q *queries) SetUserTOTPSecret(ctx context.Context, arg db.SetUserTOTPSecretParams) (db.User, error) {
+	return q.updateUser(arg.Username, func(user *db.User) error {
+		user.TotpSecret = arg.TotpSecret
+		user.TotpEnabledAt = pgtype.Timestamptz{}
+		user.TotpLastStep = pgtype.Int8{}
+		return nil
+	})
+}
+
+func (q *queries) EnableUserTOTP(ctx context.Context, arg db.EnableUserTOTPParams) (db.User, error) {
+	return q.updateUser(arg.Username, func(user *db.User) error {
+		if user.TotpSecret == nil {
+			return pgx.ErrNoRows
+		}
+		user.TotpEnabledAt = q.timestamp()
+		user.TotpLastStep = arg.TotpLastStep
+		return nil
+	})
+}
+
+func (q *queries) UseTOTPStep(ctx context.Context, arg db.UseTOTPStepParams) (int64, error) {
+	user, ok := q.tables.users[arg.Username]
+	// Like the SQL, a NULL step matches nothing.
+	if !ok || !arg.TotpLastStep.Valid || (user.TotpLastStep.Valid && user.TotpLastStep.Int64 >= arg.TotpLastStep.Int64) {
+		return 0, nil
+	}
+	user.TotpLastStep = arg.TotpLastStep
+	q.tables.users[user.Username] = user
+	return 1, nil
+}
+
+func (q *queries) CreateTOTPBackupCode(ctx context.Context, arg db.CreateTOTPBackupCodeParams) (db.TotpBackupCode, error) {
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.TotpBackupCode{}, foreignKeyViolation("totp_backup_codes", "totp_backup_codes_username_fkey")
+	}
+	for _, code := range q.tables.totpBackupCodes {
+		if code.Username == arg.Username && code.CodeHash == arg.CodeHash {
+			return db.TotpBackupCode{}, uniqueViolation("totp_backup_codes", "totp_backup_codes_username_code_hash_idx")
+		}
+	}
+
+	code := db.TotpBackupCode{
+		ID:        q.nextID("totp_backup_codes"),
+		Username:  arg.Username,
+		CodeHash:  arg.CodeHash,
+		CreatedAt: q.timestamp(),
+	}
+	q.tables.totpBackupCodes[code.ID] = code
+	return code, nil
+}
+
+func (q *queries) DeleteTOTPBackupCodes(ctx context.Context, username string) error {
+	for id, code := range q.tables.totpBackupCodes {
+		if code.Username == username {
+			delete(q.tables.totpBackupCodes, id)
+		}
+	}
+	return nil
+}
+
+func (q *queries) UseTOTPBackupCode(ctx context.Context, arg db.UseTOTPBackupCodeParams) (int64, error) {
+	for id, code := range q.tables.totpBackupCodes {
+		if code.Username == arg.Username && code.CodeHash == arg.CodeHash && !code.UsedAt.Valid {
+			code.UsedAt = q.timestamp()
+			q.tables.totpBackupCodes[id] = code
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (store *Store) SetUserTOTPSecret(ctx context.Context, arg db.SetUserTOTPSecretParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.SetUserTOTPSecret(ctx, arg) })
+}
+
+func (store *Store) EnableUserTOTP(ctx context.Context, arg db.EnableUserTOTPParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.EnableUserTOTP(ctx, arg) })
+}
+
+func (store *Store) UseTOTPStep(ctx context.Context, arg db.UseTOTPStepParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.UseTOTPStep(ctx, arg) })
+}
+
+func (store *Store) CreateTOTPBackupCode(ctx context.Context, arg db.CreateTOTPBackupCodeParams) (db.TotpBackupCode, error) {
+	return autocommit(ctx, store, func(q *queries) (db.TotpBackupCode, error) { return q.CreateTOTPBackupCode(ctx, arg) })
+}
+
+func (store *Store) DeleteTOTPBackupCodes(ctx context.Context, username string) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeleteTOTPBackupCodes(ctx, username) })
+}
+
+func (store *Store) UseTOTPBackupCode(ctx context.Context, arg db.UseTOTPBackupCodeParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.UseTOTPBackupCode(ctx, arg) })
+}
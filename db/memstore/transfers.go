@@ -0,0 +1,186 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// transfersByID orders transfers for every list, including the queries whose SQL has no
+// ORDER BY and returns them in whatever order Postgres finds them.
+func transfersByID(a, b db.Transfer) int {
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// transferAmount stores amount in transfers.amount, which must stay positive once rounded.
+func transferAmount(amount pgtype.Numeric) (pgtype.Numeric, error) {
+	amount, err := money("amount", amount)
+	if err != nil {
+		return amount, err
+	}
+	if sign(amount) <= 0 {
+		return amount, checkViolation("transfers", "transfers_amount_check")
+	}
+	return amount, nil
+}
+
+func (q *queries) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+	amount, err := transferAmount(arg.Amount)
+	if err != nil {
+		return db.Transfer{}, err
+	}
+	if _, ok := q.tables.accounts[arg.FromAccountID]; !ok {
+		return db.Transfer{}, foreignKeyViolation("transfers", "transfers_from_account_id_fkey")
+	}
+	if _, ok := q.tables.accounts[arg.ToAccountID]; !ok {
+		return db.Transfer{}, foreignKeyViolation("transfers", "transfers_to_account_id_fkey")
+	}
+
+	transfer := db.Transfer{
+		ID:            q.nextID("transfers"),
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        amount,
+		CreatedAt:     q.timestamp(),
+	}
+	q.tables.transfers[transfer.ID] = transfer
+	return transfer, nil
+}
+
+func (q *queries) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	transfer, ok := q.tables.transfers[id]
+	if !ok {
+		return db.Transfer{}, pgx.ErrNoRows
+	}
+	return transfer, nil
+}
+
+func (q *queries) GetTransferFromAccount(ctx context.Context, arg db.GetTransferFromAccountParams) ([]db.Transfer, error) {
+	transfers := selectRows(q.tables.transfers, func(transfer db.Transfer) bool {
+		return transfer.FromAccountID == arg.FromAccountID
+	}, transfersByID)
+	return page(transfers, arg.Limit, arg.Offset)
+}
+
+func (q *queries) GetTransferToAccount(ctx context.Context, arg db.GetTransferToAccountParams) ([]db.Transfer, error) {
+	transfers := selectRows(q.tables.transfers, func(transfer db.Transfer) bool {
+		return transfer.ToAccountID == arg.ToAccountID
+	}, transfersByID)
+	return page(transfers, arg.Limit, arg.Offset)
+}
+
+func (q *queries) GetTransferFromAndToAccount(ctx context.Context, arg db.GetTransferFromAndToAccountParams) ([]db.Transfer, error) {
+	transfers := selectRows(q.tables.transfers, func(transfer db.Transfer) bool {
+		return transfer.FromAccountID == arg.FromAccountID && transfer.ToAccountID == arg.ToAccountID
+	}, transfersByID)
+	return page(transfers, arg.Limit, arg.Offset)
+}
+
+func (q *queries) ListTransfers(ctx context.Context, arg db.ListTransfersParams) ([]db.Transfer, error) {
+	return page(selectRows(q.tables.transfers, nil, transfersByID), arg.Limit, arg.Offset)
+}
+
+func (q *queries) ListTransfersByOwner(ctx context.Context, arg db.ListTransfersByOwnerParams) ([]db.Transfer, error) {
+	owned := func(id int64) bool {
+		account, ok := q.tables.accounts[id]
+		return ok && account.Owner == arg.Owner
+	}
+	transfers := selectRows(q.tables.transfers, func(transfer db.Transfer) bool {
+		return transfer.ID > arg.AfterID && (owned(transfer.FromAccountID) || owned(transfer.ToAccountID))
+	}, transfersByID)
+	return page(transfers, arg.LimitCount, 0)
+}
+
+func (q *queries) UpdateTransferAmount(ctx context.Context, arg db.UpdateTransferAmountParams) error {
+	transfer, ok := q.tables.transfers[arg.ID]
+	if !ok {
+		return nil
+	}
+	amount, err := transferAmount(arg.Amount)
+	if err != nil {
+		return err
+	}
+	transfer.Amount = amount
+	q.tables.transfers[transfer.ID] = transfer
+	return nil
+}
+
+func (q *queries) SetTransferReversalOf(ctx context.Context, arg db.SetTransferReversalOfParams) (db.Transfer, error) {
+	transfer, ok := q.tables.transfers[arg.ID]
+	if !ok {
+		return db.Transfer{}, pgx.ErrNoRows
+	}
+	if arg.ReversalOf.Valid {
+		if _, ok := q.tables.transfers[arg.ReversalOf.Int64]; !ok {
+			return db.Transfer{}, foreignKeyViolation("transfers", "transfers_reversal_of_fkey")
+		}
+		for _, other := range q.tables.transfers {
+			if other.ID != transfer.ID && other.ReversalOf == arg.ReversalOf {
+				return db.Transfer{}, uniqueViolation("transfers", "transfers_reversal_of_key")
+			}
+		}
+	}
+
+	transfer.ReversalOf = arg.ReversalOf
+	q.tables.transfers[transfer.ID] = transfer
+	return transfer, nil
+}
+
+func (q *queries) DeleteTransfer(ctx context.Context, id int64) error {
+	for _, transfer := range q.tables.transfers {
+		if transfer.ReversalOf.Valid && transfer.ReversalOf.Int64 == id && transfer.ID != id {
+			return stillReferenced("transfers", "transfers_reversal_of_fkey", "transfers")
+		}
+	}
+	for _, posting := range q.tables.interestPostings {
+		if posting.TransferID.Valid && posting.TransferID.Int64 == id {
+			return stillReferenced("transfers", "interest_postings_transfer_id_fkey", "interest_postings")
+		}
+	}
+
+	delete(q.tables.transfers, id)
+	return nil
+}
+
+func (store *Store) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Transfer, error) { return q.CreateTransfer(ctx, arg) })
+}
+
+func (store *Store) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Transfer, error) { return q.GetTransfer(ctx, id) })
+}
+
+func (store *Store) GetTransferFromAccount(ctx context.Context, arg db.GetTransferFromAccountParams) ([]db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Transfer, error) { return q.GetTransferFromAccount(ctx, arg) })
+}
+
+func (store *Store) GetTransferToAccount(ctx context.Context, arg db.GetTransferToAccountParams) ([]db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Transfer, error) { return q.GetTransferToAccount(ctx, arg) })
+}
+
+func (store *Store) GetTransferFromAndToAccount(ctx context.Context, arg db.GetTransferFromAndToAccountParams) ([]db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Transfer, error) { return q.GetTransferFromAndToAccount(ctx, arg) })
+}
+
+func (store *Store) ListTransfers(ctx context.Context, arg db.ListTransfersParams) ([]db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Transfer, error) { return q.ListTransfers(ctx, arg) })
+}
+
+func (store *Store) ListTransfersByOwner(ctx context.Context, arg db.ListTransfersByOwnerParams) ([]db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.Transfer, error) { return q.ListTransfersByOwner(ctx, arg) })
+}
+
+func (store *Store) UpdateTransferAmount(ctx context.Context, arg db.UpdateTransferAmountParams) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.UpdateTransferAmount(ctx, arg) })
+}
+
+func (store *Store) SetTransferReversalOf(ctx context.Context, arg db.SetTransferReversalOfParams) (db.Transfer, error) {
+	return autocommit(ctx, store, func(q *queries) (db.Transfer, error) { return q.SetTransferReversalOf(ctx, arg) })
+}
+
+func (store *Store) DeleteTransfer(ctx context.Context, id int64) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeleteTransfer(ctx, id) })
+}
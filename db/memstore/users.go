@@ -0,0 +1,218 @@
+package memstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"strings"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (q *queries) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	if _, ok := q.tables.users[arg.Username]; ok {
+		return db.User{}, uniqueViolation("users", "users_pkey")
+	}
+	if err := q.checkEmailUnique("", arg.Email, arg.EmailIndex); err != nil {
+		return db.User{}, err
+	}
+
+	user := db.User{
+		Username:     arg.Username,
+		PasswordHash: arg.PasswordHash,
+		FullName:     arg.FullName,
+		Email:        arg.Email,
+		CraetedAt:    q.timestamp(),
+		Role:         db.UserRoleCustomer,
+		EmailIndex:   bytes.Clone(arg.EmailIndex),
+		DataKey:      bytes.Clone(arg.DataKey),
+		DataKeyID:    arg.DataKeyID,
+	}
+	q.tables.users[user.Username] = user
+	return user, nil
+}
+
+func (q *queries) GetUser(ctx context.Context, username string) (db.User, error) {
+	user, ok := q.tables.users[username]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+// checkEmailUnique enforces the users_email_key and users_email_index_key constraints and
+// the case-insensitive users_email_lower_key index, leaving out the user with the
+// username, if any.
+func (q *queries) checkEmailUnique(username, email string, emailIndex []byte) error {
+	for _, user := range q.tables.users {
+		switch {
+		case user.Username == username:
+		case user.Email == email:
+			return uniqueViolation("users", "users_email_key")
+		case strings.EqualFold(user.Email, email):
+			return uniqueViolation("users", "users_email_lower_key")
+		case emailIndex != nil && bytes.Equal(user.EmailIndex, emailIndex):
+			return uniqueViolation("users", "users_email_index_key")
+		}
+	}
+	return nil
+}
+
+// updateUser applies change to a copy of the user and stores it, like updateAccount.
+func (q *queries) updateUser(username string, change func(*db.User) error) (db.User, error) {
+	user, ok := q.tables.users[username]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	if err := change(&user); err != nil {
+		return db.User{}, err
+	}
+	q.tables.users[username] = user
+	return user, nil
+}
+
+func (q *queries) UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	return q.updateUser(arg.Username, func(user *db.User) error {
+		if !arg.Role.Valid() {
+			return invalidEnum("user_role", arg.Role)
+		}
+		user.Role = arg.Role
+		user.TokensRevokedAt = q.timestamp()
+		return nil
+	})
+}
+
+func (q *queries) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	return q.updateUser(arg.Username, func(user *db.User) error {
+		if !bytes.Equal(user.DataKey, arg.PreviousDataKey) || (user.DataKey == nil) != (arg.PreviousDataKey == nil) {
+			return pgx.ErrNoRows
+		}
+		if arg.FullName.Valid {
+			user.FullName = arg.FullName.String
+		}
+		if arg.Email.Valid || arg.EmailIndex != nil {
+			email := cmp.Or(arg.Email.String, user.Email)
+			if err := q.checkEmailUnique(user.Username, email, arg.EmailIndex); err != nil {
+				return err
+			}
+			user.Email = email
+		}
+		if arg.EmailIndex != nil {
+			user.EmailIndex = bytes.Clone(arg.EmailIndex)
+		}
+		if arg.DataKey != nil {
+			user.DataKey = bytes.Clone(arg.DataKey)
+		}
+		if arg.DataKeyID.Valid {
+			user.DataKeyID = arg.DataKeyID
+		}
+		if arg.EmailChanged {
+			user.EmailVerifiedAt = pgtype.Timestamptz{}
+		}
+		return nil
+	})
+}
+
+func (q *queries) ListUsersToRekey(ctx context.Context, arg db.ListUsersToRekeyParams) ([]db.User, error) {
+	users := selectRows(q.tables.users, func(user db.User) bool {
+		return (!user.DataKeyID.Valid || user.DataKeyID.String != arg.KeyID) && user.Username > arg.AfterUsername
+	}, func(a, b db.User) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+	return page(users, arg.LimitCount, 0)
+}
+
+func (q *queries) RekeyUser(ctx context.Context, arg db.RekeyUserParams) (int64, error) {
+	user, ok := q.tables.users[arg.Username]
+	if !ok || user.FullName != arg.OldFullName || user.Email != arg.OldEmail ||
+		!bytes.Equal(user.DataKey, arg.OldDataKey) || (user.DataKey == nil) != (arg.OldDataKey == nil) {
+		return 0, nil
+	}
+	if err := q.checkEmailUnique(user.Username, arg.Email, arg.EmailIndex); err != nil {
+		return 0, err
+	}
+	user.FullName = arg.FullName
+	user.Email = arg.Email
+	user.EmailIndex = bytes.Clone(arg.EmailIndex)
+	user.DataKey = bytes.Clone(arg.DataKey)
+	user.DataKeyID = arg.DataKeyID
+	q.tables.users[user.Username] = user
+	return 1, nil
+}
+
+func (q *queries) ListUsersToIndex(ctx context.Context, arg db.ListUsersToIndexParams) ([]db.User, error) {
+	users := selectRows(q.tables.users, func(user db.User) bool {
+		return user.DataKey == nil && user.EmailIndex == nil && user.Username > arg.AfterUsername
+	}, func(a, b db.User) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+	return page(users, arg.LimitCount, 0)
+}
+
+func (q *queries) IndexUserEmail(ctx context.Context, arg db.IndexUserEmailParams) (int64, error) {
+	user, ok := q.tables.users[arg.Username]
+	if !ok || user.Email != arg.OldEmail || user.DataKey != nil {
+		return 0, nil
+	}
+	if err := q.checkEmailUnique(user.Username, user.Email, arg.EmailIndex); err != nil {
+		return 0, err
+	}
+	user.EmailIndex = bytes.Clone(arg.EmailIndex)
+	q.tables.users[user.Username] = user
+	return 1, nil
+}
+
+func (q *queries) EraseUser(ctx context.Context, arg db.EraseUserParams) (db.User, error) {
+	return q.updateUser(arg.Username, func(user *db.User) error {
+		if err := q.checkEmailUnique(user.Username, arg.Email, nil); err != nil {
+			return err
+		}
+		user.FullName = arg.FullName
+		user.Email = arg.Email
+		user.EmailIndex, user.DataKey, user.DataKeyID = nil, nil, pgtype.Text{}
+		user.PasswordHash = "!"
+		user.PasswordChangedAt = q.timestamp()
+		user.TotpSecret, user.TotpEnabledAt, user.TotpLastStep = nil, pgtype.Timestamptz{}, pgtype.Int8{}
+		user.EmailVerifiedAt = pgtype.Timestamptz{}
+		user.ErasedAt = q.timestamp()
+		return nil
+	})
+}
+
+func (store *Store) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.CreateUser(ctx, arg) })
+}
+
+func (store *Store) GetUser(ctx context.Context, username string) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.GetUser(ctx, username) })
+}
+
+func (store *Store) UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.UpdateUserRole(ctx, arg) })
+}
+
+func (store *Store) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.UpdateUser(ctx, arg) })
+}
+
+func (store *Store) ListUsersToRekey(ctx context.Context, arg db.ListUsersToRekeyParams) ([]db.User, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.User, error) { return q.ListUsersToRekey(ctx, arg) })
+}
+
+func (store *Store) RekeyUser(ctx context.Context, arg db.RekeyUserParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.RekeyUser(ctx, arg) })
+}
+
+func (store *Store) ListUsersToIndex(ctx context.Context, arg db.ListUsersToIndexParams) ([]db.User, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.User, error) { return q.ListUsersToIndex(ctx, arg) })
+}
+
+func (store *Store) IndexUserEmail(ctx context.Context, arg db.IndexUserEmailParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.IndexUserEmail(ctx, arg) })
+}
+
+func (store *Store) EraseUser(ctx context.Context, arg db.EraseUserParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.EraseUser(ctx, arg) })
+}
@@ -0,0 +1,117 @@
+package memstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *queries) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.VerifyEmail{}, foreignKeyViolation("verify_emails", "verify_emails_username_fkey")
+	}
+
+	verifyEmail := db.VerifyEmail{
+		ID:        q.nextID("verify_emails"),
+		Username:  arg.Username,
+		Email:     arg.Email,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: q.timestamp(),
+		DataKey:   bytes.Clone(arg.DataKey),
+		DataKeyID: arg.DataKeyID,
+	}
+	q.tables.verifyEmails[verifyEmail.ID] = verifyEmail
+	return verifyEmail, nil
+}
+
+func (q *queries) GetVerifyEmail(ctx context.Context, id int64) (db.VerifyEmail, error) {
+	verifyEmail, ok := q.tables.verifyEmails[id]
+	if !ok {
+		return db.VerifyEmail{}, pgx.ErrNoRows
+	}
+	return verifyEmail, nil
+}
+
+func (q *queries) UseVerifyEmail(ctx context.Context, id int64) (int64, error) {
+	verifyEmail, ok := q.tables.verifyEmails[id]
+	if !ok || verifyEmail.UsedAt.Valid || !verifyEmail.ExpiresAt.Time.After(q.now) {
+		return 0, nil
+	}
+	verifyEmail.UsedAt = q.timestamp()
+	q.tables.verifyEmails[id] = verifyEmail
+	return 1, nil
+}
+
+func (q *queries) SetUserEmailVerified(ctx context.Context, arg db.SetUserEmailVerifiedParams) (db.User, error) {
+	user, ok := q.tables.users[arg.Username]
+	matches := user.EmailIndex != nil && arg.EmailIndex != nil && bytes.Equal(user.EmailIndex, arg.EmailIndex) ||
+		user.EmailIndex == nil && user.Email == arg.Email
+	if !ok || !matches {
+		return db.User{}, pgx.ErrNoRows
+	}
+	user.EmailVerifiedAt = q.timestamp()
+	q.tables.users[arg.Username] = user
+	return user, nil
+}
+
+func (q *queries) ListVerifyEmailsToRekey(ctx context.Context, arg db.ListVerifyEmailsToRekeyParams) ([]db.VerifyEmail, error) {
+	verifyEmails := selectRows(q.tables.verifyEmails, func(verifyEmail db.VerifyEmail) bool {
+		return (!verifyEmail.DataKeyID.Valid || verifyEmail.DataKeyID.String != arg.KeyID) && verifyEmail.ID > arg.AfterID
+	}, func(a, b db.VerifyEmail) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return page(verifyEmails, arg.LimitCount, 0)
+}
+
+func (q *queries) RekeyVerifyEmail(ctx context.Context, arg db.RekeyVerifyEmailParams) (int64, error) {
+	verifyEmail, ok := q.tables.verifyEmails[arg.ID]
+	if !ok || verifyEmail.Email != arg.OldEmail ||
+		!bytes.Equal(verifyEmail.DataKey, arg.OldDataKey) || (verifyEmail.DataKey == nil) != (arg.OldDataKey == nil) {
+		return 0, nil
+	}
+	verifyEmail.Email = arg.Email
+	verifyEmail.DataKey = bytes.Clone(arg.DataKey)
+	verifyEmail.DataKeyID = arg.DataKeyID
+	q.tables.verifyEmails[arg.ID] = verifyEmail
+	return 1, nil
+}
+
+func (q *queries) DeleteVerifyEmails(ctx context.Context, username string) error {
+	for id, verifyEmail := range q.tables.verifyEmails {
+		if verifyEmail.Username == username {
+			delete(q.tables.verifyEmails, id)
+		}
+	}
+	return nil
+}
+
+func (store *Store) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+	return autocommit(ctx, store, func(q *queries) (db.VerifyEmail, error) { return q.CreateVerifyEmail(ctx, arg) })
+}
+
+func (store *Store) GetVerifyEmail(ctx context.Context, id int64) (db.VerifyEmail, error) {
+	return autocommit(ctx, store, func(q *queries) (db.VerifyEmail, error) { return q.GetVerifyEmail(ctx, id) })
+}
+
+func (store *Store) UseVerifyEmail(ctx context.Context, id int64) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.UseVerifyEmail(ctx, id) })
+}
+
+func (store *Store) SetUserEmailVerified(ctx context.Context, arg db.SetUserEmailVerifiedParams) (db.User, error) {
+	return autocommit(ctx, store, func(q *queries) (db.User, error) { return q.SetUserEmailVerified(ctx, arg) })
+}
+
+func (store *Store) ListVerifyEmailsToRekey(ctx context.Context, arg db.ListVerifyEmailsToRekeyParams) ([]db.VerifyEmail, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.VerifyEmail, error) { return q.ListVerifyEmailsToRekey(ctx, arg) })
+}
+
+func (store *Store) RekeyVerifyEmail(ctx context.Context, arg db.RekeyVerifyEmailParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.RekeyVerifyEmail(ctx, arg) })
+}
+
+func (store *Store) DeleteVerifyEmails(ctx context.Context, username string) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeleteVerifyEmails(ctx, username) })
+}
@@ -0,0 +1,301 @@
+package memstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// timestamptz stores t at the microsecond precision of a timestamptz column.
+func timestamptz(t pgtype.Timestamptz) pgtype.Timestamptz {
+	t.Time = t.Time.Truncate(time.Microsecond)
+	return t
+}
+
+func (q *queries) CreateWebhookSubscription(ctx context.Context, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	if arg.EventTypes == nil {
+		return db.WebhookSubscription{}, notNull("event_types")
+	}
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.WebhookSubscription{}, foreignKeyViolation("webhook_subscriptions", "webhook_subscriptions_username_fkey")
+	}
+
+	subscription := db.WebhookSubscription{
+		ID:         q.nextID("webhook_subscriptions"),
+		Username:   arg.Username,
+		Url:        arg.Url,
+		Secret:     arg.Secret,
+		EventTypes: slices.Clone(arg.EventTypes),
+		Active:     true,
+		CreatedAt:  q.timestamp(),
+	}
+	q.tables.webhookSubscriptions[subscription.ID] = subscription
+	return subscription, nil
+}
+
+func (q *queries) GetWebhookSubscription(ctx context.Context, id int64) (db.WebhookSubscription, error) {
+	subscription, ok := q.tables.webhookSubscriptions[id]
+	if !ok {
+		return db.WebhookSubscription{}, pgx.ErrNoRows
+	}
+	subscription.EventTypes = slices.Clone(subscription.EventTypes)
+	return subscription, nil
+}
+
+func (q *queries) ListWebhookSubscriptions(ctx context.Context, username string) ([]db.WebhookSubscription, error) {
+	subscriptions := selectRows(q.tables.webhookSubscriptions, func(subscription db.WebhookSubscription) bool {
+		return subscription.Username == username
+	}, func(a, b db.WebhookSubscription) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for i := range subscriptions {
+		subscriptions[i].EventTypes = slices.Clone(subscriptions[i].EventTypes)
+	}
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription deletes the subscription's deliveries with it, as ON DELETE
+// CASCADE does.
+func (q *queries) DeleteWebhookSubscription(ctx context.Context, arg db.DeleteWebhookSubscriptionParams) (int64, error) {
+	subscription, ok := q.tables.webhookSubscriptions[arg.ID]
+	if !ok || subscription.Username != arg.Username {
+		return 0, nil
+	}
+
+	delete(q.tables.webhookSubscriptions, arg.ID)
+	for id, delivery := range q.tables.webhookDeliveries {
+		if delivery.SubscriptionID == arg.ID {
+			delete(q.tables.webhookDeliveries, id)
+		}
+	}
+	return 1, nil
+}
+
+func (q *queries) DeleteWebhookSubscriptions(ctx context.Context, username string) error {
+	for id, subscription := range q.tables.webhookSubscriptions {
+		if subscription.Username == username {
+			if _, err := q.DeleteWebhookSubscription(ctx, db.DeleteWebhookSubscriptionParams{ID: id, Username: username}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *queries) CreateWebhookEvent(ctx context.Context, arg db.CreateWebhookEventParams) (db.WebhookEvent, error) {
+	if arg.Payload == nil {
+		return db.WebhookEvent{}, notNull("payload")
+	}
+	if !json.Valid(arg.Payload) {
+		return db.WebhookEvent{}, pgError(codeInvalidTextRepr, "invalid input syntax for type json")
+	}
+	if _, ok := q.tables.users[arg.Username]; !ok {
+		return db.WebhookEvent{}, foreignKeyViolation("webhook_events", "webhook_events_username_fkey")
+	}
+
+	event := db.WebhookEvent{
+		ID:        q.nextID("webhook_events"),
+		Username:  arg.Username,
+		EventType: arg.EventType,
+		Payload:   bytes.Clone(arg.Payload),
+		CreatedAt: q.timestamp(),
+	}
+	q.tables.webhookEvents[event.ID] = event
+	return event, nil
+}
+
+func (q *queries) GetWebhookEvent(ctx context.Context, id int64) (db.WebhookEvent, error) {
+	event, ok := q.tables.webhookEvents[id]
+	if !ok {
+		return db.WebhookEvent{}, pgx.ErrNoRows
+	}
+	event.Payload = bytes.Clone(event.Payload)
+	return event, nil
+}
+
+// CreateWebhookDeliveriesForEvent queues a delivery of the event to each of the user's
+// active subscriptions to its type.
+func (q *queries) CreateWebhookDeliveriesForEvent(ctx context.Context, arg db.CreateWebhookDeliveriesForEventParams) (int64, error) {
+	subscriptions := selectRows(q.tables.webhookSubscriptions, func(subscription db.WebhookSubscription) bool {
+		return subscription.Username == arg.Username &&
+			subscription.Active &&
+			slices.Contains(subscription.EventTypes, arg.EventType)
+	}, func(a, b db.WebhookSubscription) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if len(subscriptions) == 0 {
+		return 0, nil
+	}
+	if _, ok := q.tables.webhookEvents[arg.EventID]; !ok {
+		return 0, foreignKeyViolation("webhook_deliveries", "webhook_deliveries_event_id_fkey")
+	}
+
+	for _, subscription := range subscriptions {
+		delivery := db.WebhookDelivery{
+			ID:             q.nextID("webhook_deliveries"),
+			EventID:        arg.EventID,
+			SubscriptionID: subscription.ID,
+			Status:         db.WebhookDeliveryStatusPending,
+			NextAttemptAt:  q.timestamp(),
+			CreatedAt:      q.timestamp(),
+		}
+		q.tables.webhookDeliveries[delivery.ID] = delivery
+	}
+	return int64(len(subscriptions)), nil
+}
+
+func (q *queries) GetWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	delivery, ok := q.tables.webhookDeliveries[id]
+	if !ok {
+		return db.WebhookDelivery{}, pgx.ErrNoRows
+	}
+	return delivery, nil
+}
+
+func (q *queries) ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	deliveries := selectRows(q.tables.webhookDeliveries, func(delivery db.WebhookDelivery) bool {
+		return delivery.SubscriptionID == arg.SubscriptionID
+	}, func(a, b db.WebhookDelivery) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return page(deliveries, arg.Limit, arg.Offset)
+}
+
+// ClaimDueWebhookDeliveries leases the pending deliveries that are due, oldest first.
+func (q *queries) ClaimDueWebhookDeliveries(ctx context.Context, arg db.ClaimDueWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	if !arg.LeaseUntil.Valid {
+		return nil, notNull("next_attempt_at")
+	}
+
+	due := selectRows(q.tables.webhookDeliveries, func(delivery db.WebhookDelivery) bool {
+		return delivery.Status == db.WebhookDeliveryStatusPending && !delivery.NextAttemptAt.Time.After(q.now)
+	}, func(a, b db.WebhookDelivery) int {
+		return cmp.Or(a.NextAttemptAt.Time.Compare(b.NextAttemptAt.Time), cmp.Compare(a.ID, b.ID))
+	})
+	due, err := page(due, arg.BatchSize, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range due {
+		due[i].NextAttemptAt = timestamptz(arg.LeaseUntil)
+		q.tables.webhookDeliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// updateDelivery applies change to a copy of the delivery and stores it.
+func (q *queries) updateDelivery(id int64, change func(*db.WebhookDelivery) error) (db.WebhookDelivery, error) {
+	delivery, ok := q.tables.webhookDeliveries[id]
+	if !ok {
+		return db.WebhookDelivery{}, pgx.ErrNoRows
+	}
+	if err := change(&delivery); err != nil {
+		return db.WebhookDelivery{}, err
+	}
+	q.tables.webhookDeliveries[id] = delivery
+	return delivery, nil
+}
+
+func (q *queries) MarkWebhookDeliverySucceeded(ctx context.Context, arg db.MarkWebhookDeliverySucceededParams) (db.WebhookDelivery, error) {
+	return q.updateDelivery(arg.ID, func(delivery *db.WebhookDelivery) error {
+		delivery.Status = db.WebhookDeliveryStatusSucceeded
+		delivery.Attempts++
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = pgtype.Text{}
+		delivery.DeliveredAt = q.timestamp()
+		return nil
+	})
+}
+
+func (q *queries) MarkWebhookDeliveryFailed(ctx context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+	return q.updateDelivery(arg.ID, func(delivery *db.WebhookDelivery) error {
+		if !arg.Status.Valid() {
+			return invalidEnum("webhook_delivery_status", arg.Status)
+		}
+		if !arg.NextAttemptAt.Valid {
+			return notNull("next_attempt_at")
+		}
+		delivery.Status = arg.Status
+		delivery.Attempts++
+		delivery.NextAttemptAt = timestamptz(arg.NextAttemptAt)
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = arg.LastError
+		return nil
+	})
+}
+
+func (q *queries) ReplayWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	return q.updateDelivery(id, func(delivery *db.WebhookDelivery) error {
+		delivery.Status = db.WebhookDeliveryStatusPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = q.timestamp()
+		delivery.LastStatusCode = pgtype.Int4{}
+		delivery.LastError = pgtype.Text{}
+		delivery.DeliveredAt = pgtype.Timestamptz{}
+		return nil
+	})
+}
+
+func (store *Store) CreateWebhookSubscription(ctx context.Context, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookSubscription, error) { return q.CreateWebhookSubscription(ctx, arg) })
+}
+
+func (store *Store) GetWebhookSubscription(ctx context.Context, id int64) (db.WebhookSubscription, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookSubscription, error) { return q.GetWebhookSubscription(ctx, id) })
+}
+
+func (store *Store) ListWebhookSubscriptions(ctx context.Context, username string) ([]db.WebhookSubscription, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.WebhookSubscription, error) { return q.ListWebhookSubscriptions(ctx, username) })
+}
+
+func (store *Store) DeleteWebhookSubscription(ctx context.Context, arg db.DeleteWebhookSubscriptionParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.DeleteWebhookSubscription(ctx, arg) })
+}
+
+func (store *Store) DeleteWebhookSubscriptions(ctx context.Context, username string) error {
+	return autocommitExec(ctx, store, func(q *queries) error { return q.DeleteWebhookSubscriptions(ctx, username) })
+}
+
+func (store *Store) CreateWebhookEvent(ctx context.Context, arg db.CreateWebhookEventParams) (db.WebhookEvent, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookEvent, error) { return q.CreateWebhookEvent(ctx, arg) })
+}
+
+func (store *Store) GetWebhookEvent(ctx context.Context, id int64) (db.WebhookEvent, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookEvent, error) { return q.GetWebhookEvent(ctx, id) })
+}
+
+func (store *Store) CreateWebhookDeliveriesForEvent(ctx context.Context, arg db.CreateWebhookDeliveriesForEventParams) (int64, error) {
+	return autocommit(ctx, store, func(q *queries) (int64, error) { return q.CreateWebhookDeliveriesForEvent(ctx, arg) })
+}
+
+func (store *Store) GetWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookDelivery, error) { return q.GetWebhookDelivery(ctx, id) })
+}
+
+func (store *Store) ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.WebhookDelivery, error) { return q.ListWebhookDeliveries(ctx, arg) })
+}
+
+func (store *Store) ClaimDueWebhookDeliveries(ctx context.Context, arg db.ClaimDueWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	return autocommit(ctx, store, func(q *queries) ([]db.WebhookDelivery, error) { return q.ClaimDueWebhookDeliveries(ctx, arg) })
+}
+
+func (store *Store) MarkWebhookDeliverySucceeded(ctx context.Context, arg db.MarkWebhookDeliverySucceededParams) (db.WebhookDelivery, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookDelivery, error) { return q.MarkWebhookDeliverySucceeded(ctx, arg) })
+}
+
+func (store *Store) MarkWebhookDeliveryFailed(ctx context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookDelivery, error) { return q.MarkWebhookDeliveryFailed(ctx, arg) })
+}
+
+func (store *Store) ReplayWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	return autocommit(ctx, store, func(q *queries) (db.WebhookDelivery, error) { return q.ReplayWebhookDelivery(ctx, id) })
+}
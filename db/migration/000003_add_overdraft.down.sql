@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "overdraft_charges";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "overdraft_limit";
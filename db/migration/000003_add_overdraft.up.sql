@@ -0,0 +1,18 @@
+ALTER TABLE "accounts" ADD COLUMN "overdraft_limit" numeric(10,2) NOT NULL DEFAULT 0 CHECK ("overdraft_limit" >= 0);
+
+CREATE TABLE "overdraft_charges" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "entry_id" bigint NOT NULL,
+  "charge_date" date NOT NULL,
+  "balance" numeric(10,2) NOT NULL,
+  "annual_rate" numeric(8,6) NOT NULL,
+  "amount" numeric(10,2) NOT NULL CHECK (amount > 0),
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "overdraft_charges" ("account_id", "charge_date");
+
+ALTER TABLE "overdraft_charges" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "overdraft_charges" ADD FOREIGN KEY ("entry_id") REFERENCES "entries" ("id");
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "interest_accruals";
+DROP TABLE IF EXISTS "interest_postings";
+DROP TABLE IF EXISTS "interest_products";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "account_type";
+
+DROP TYPE IF EXISTS "day_count_convention";
+DROP TYPE IF EXISTS "account_type";
@@ -0,0 +1,60 @@
+CREATE TYPE "account_type" AS ENUM ('checking', 'savings', 'business');
+
+CREATE TYPE "day_count_convention" AS ENUM ('actual_365', 'actual_360', 'actual_actual', 'thirty_360');
+
+ALTER TABLE "accounts" ADD COLUMN "account_type" account_type NOT NULL DEFAULT 'checking';
+
+CREATE TABLE "interest_products" (
+  "id" bigserial PRIMARY KEY,
+  "currency" varchar NOT NULL,
+  "account_type" account_type NOT NULL,
+  "annual_rate" numeric(8,6) NOT NULL CHECK (annual_rate >= 0),
+  "day_count" day_count_convention NOT NULL DEFAULT 'actual_365',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "interest_postings" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "period" date NOT NULL,
+  "amount" numeric(10,2) NOT NULL,
+  "transfer_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "interest_accruals" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "product_id" bigint NOT NULL,
+  "accrual_date" date NOT NULL,
+  "balance" numeric(10,2) NOT NULL,
+  "annual_rate" numeric(8,6) NOT NULL,
+  "day_count" day_count_convention NOT NULL,
+  "amount" numeric(20,10) NOT NULL,
+  "posting_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "interest_products" ("currency", "account_type");
+
+CREATE UNIQUE INDEX ON "interest_postings" ("account_id", "period");
+
+CREATE UNIQUE INDEX ON "interest_accruals" ("account_id", "accrual_date");
+
+CREATE INDEX ON "interest_accruals" ("posting_id");
+
+ALTER TABLE "interest_postings" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "interest_postings" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "interest_accruals" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "interest_accruals" ADD FOREIGN KEY ("product_id") REFERENCES "interest_products" ("id");
+
+ALTER TABLE "interest_accruals" ADD FOREIGN KEY ("posting_id") REFERENCES "interest_postings" ("id");
+
+-- House user that owns the bank's own accounts, such as the interest-expense account per currency.
+-- The password hash is not a valid bcrypt hash, so nobody can log in as this user.
+INSERT INTO "users" ("username", "password_hash", "full_name", "email")
+VALUES ('bank', '!', 'Simple Bank', 'house@simplebank.invalid')
+ON CONFLICT DO NOTHING;
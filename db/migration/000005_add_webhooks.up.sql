@@ -0,0 +1,49 @@
+CREATE TYPE "webhook_delivery_status" AS ENUM ('pending', 'succeeded', 'dead');
+
+CREATE TABLE "webhook_subscriptions" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "url" varchar NOT NULL,
+  "secret" varchar NOT NULL,
+  "event_types" varchar[] NOT NULL,
+  "active" boolean NOT NULL DEFAULT true,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- Transactional outbox: rows are written in the same transaction as the change they describe.
+CREATE TABLE "webhook_events" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "event_type" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "webhook_deliveries" (
+  "id" bigserial PRIMARY KEY,
+  "event_id" bigint NOT NULL,
+  "subscription_id" bigint NOT NULL,
+  "status" webhook_delivery_status NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+  "last_status_code" integer,
+  "last_error" varchar,
+  "delivered_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "webhook_subscriptions" ("username");
+
+CREATE INDEX ON "webhook_events" ("username");
+
+CREATE INDEX ON "webhook_deliveries" ("status", "next_attempt_at");
+
+CREATE INDEX ON "webhook_deliveries" ("subscription_id");
+
+ALTER TABLE "webhook_subscriptions" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "webhook_events" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("event_id") REFERENCES "webhook_events" ("id");
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE;
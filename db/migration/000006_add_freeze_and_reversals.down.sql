@@ -0,0 +1,3 @@
+ALTER TABLE "transfers" DROP COLUMN IF EXISTS "reversal_of";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "frozen";
@@ -0,0 +1,6 @@
+ALTER TABLE "accounts" ADD COLUMN "frozen" boolean NOT NULL DEFAULT false;
+
+-- A reversal is an ordinary transfer in the opposite direction that points at the transfer it undoes.
+ALTER TABLE "transfers" ADD COLUMN "reversal_of" bigint UNIQUE;
+
+ALTER TABLE "transfers" ADD FOREIGN KEY ("reversal_of") REFERENCES "transfers" ("id");
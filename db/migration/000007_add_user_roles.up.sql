@@ -0,0 +1,4 @@
+CREATE TYPE "user_role" AS ENUM ('admin', 'teller', 'customer');
+
+-- Every existing user is a customer; admins and tellers are promoted explicitly.
+ALTER TABLE "users" ADD COLUMN "role" user_role NOT NULL DEFAULT 'customer';
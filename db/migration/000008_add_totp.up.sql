@@ -0,0 +1,18 @@
+-- totp_secret is sealed with AES-GCM under TOTP_ENCRYPTION_KEY. Two-factor authentication is
+-- on once totp_enabled_at is set; totp_last_step is the time step of the last code accepted,
+-- so that no code is accepted twice.
+ALTER TABLE "users" ADD COLUMN "totp_secret" bytea;
+ALTER TABLE "users" ADD COLUMN "totp_enabled_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint;
+
+CREATE TABLE "totp_backup_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "totp_backup_codes" ("username", "code_hash");
+
+ALTER TABLE "totp_backup_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,25 @@
+-- The hashes of the passwords users had before their current one, so that a new password
+-- cannot be one of the last few.
+CREATE TABLE "password_history" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "password_hash" varchar(255) NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- Reset tokens are stored hashed; only the user is sent the token itself. Changing the
+-- password deletes all of the user's tokens, so each is good once.
+CREATE TABLE "password_reset_tokens" (
+  "token_hash" varchar PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "password_history" ("username", "id");
+
+CREATE INDEX ON "password_reset_tokens" ("username");
+
+ALTER TABLE "password_history" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "password_reset_tokens" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
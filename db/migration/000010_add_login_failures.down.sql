@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "login_failures";
+
+DROP TYPE IF EXISTS "login_failure_scope";
@@ -0,0 +1,12 @@
+CREATE TYPE "login_failure_scope" AS ENUM ('username', 'ip');
+
+-- Failed logins, counted per username and per client IP. Usernames are counted whether or
+-- not they exist, so that throttling does not tell which ones do; there is no foreign key.
+CREATE TABLE "login_failures" (
+  "scope" login_failure_scope NOT NULL,
+  "key" varchar NOT NULL,
+  "failures" int NOT NULL,
+  "last_failed_at" timestamptz NOT NULL DEFAULT (now()),
+  "locked_until" timestamptz,
+  PRIMARY KEY ("scope", "key")
+);
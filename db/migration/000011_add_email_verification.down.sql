@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "verify_emails";
+
+DROP INDEX IF EXISTS "users_email_lower_key";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "email_verified_at";
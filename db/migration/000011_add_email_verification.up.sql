@@ -0,0 +1,20 @@
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+
+-- Addresses are unique whatever their case: Alice@example.com and alice@example.com reach
+-- the same mailbox.
+CREATE UNIQUE INDEX "users_email_lower_key" ON "users" (lower("email"));
+
+-- A verification code is not stored: the row records which address was sent one and until
+-- when it is good, and the code carries a signature of the row.
+CREATE TABLE "verify_emails" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "verify_emails" ("username");
+
+ALTER TABLE "verify_emails" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,21 @@
+-- API keys let a user's own programs call the API. Keys are stored hashed; the key itself
+-- is only shown when it is created. A key does no more than its scopes allow, and only
+-- from the allowed IPs and networks if any are listed.
+CREATE TABLE "api_keys" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "key_hash" varchar UNIQUE NOT NULL,
+  "prefix" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "allowed_ips" varchar[] NOT NULL,
+  "expires_at" timestamptz,
+  "last_used_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- Revoked keys are kept, and free their name for a new key.
+CREATE UNIQUE INDEX "api_keys_username_name_key" ON "api_keys" ("username", "name") WHERE "revoked_at" IS NULL;
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
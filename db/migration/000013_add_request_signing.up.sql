@@ -0,0 +1,3 @@
+-- A key with a signing_secret must sign the requests that move money with it. The server
+-- needs the secret itself to check the signatures, so unlike the key it is not hashed.
+ALTER TABLE "api_keys" ADD COLUMN "signing_secret" varchar;
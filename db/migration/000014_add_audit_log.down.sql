@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS "audit_log";
+DROP FUNCTION IF EXISTS "audit_log_immutable"();
+
+-- Postgres cannot drop an enum value, so the type is rebuilt without it.
+UPDATE "users" SET "role" = 'customer' WHERE "role" = 'auditor';
+ALTER TABLE "users" ALTER COLUMN "role" DROP DEFAULT;
+ALTER TYPE "user_role" RENAME TO "user_role_old";
+CREATE TYPE "user_role" AS ENUM ('admin', 'teller', 'customer');
+ALTER TABLE "users" ALTER COLUMN "role" TYPE "user_role" USING "role"::text::"user_role";
+ALTER TABLE "users" ALTER COLUMN "role" SET DEFAULT 'customer';
+DROP TYPE "user_role_old";
@@ -0,0 +1,36 @@
+-- The audit log records who changed what, when and from where, with the state before and
+-- after. Rows are only ever added: the triggers below refuse to change or delete them.
+-- actor_type is user, cli, system or anonymous; actor is the username, if any.
+-- It is not a foreign key, so that the log outlives the users it names.
+CREATE TABLE "audit_log" (
+  "id" bigserial PRIMARY KEY,
+  "actor_type" varchar NOT NULL,
+  "actor" varchar,
+  "api_key_id" bigint,
+  "action" varchar NOT NULL,
+  "resource_type" varchar NOT NULL,
+  "resource_id" varchar NOT NULL,
+  "client_ip" varchar,
+  "request_id" varchar,
+  "before" jsonb,
+  "after" jsonb,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "audit_log" ("actor", "id");
+CREATE INDEX ON "audit_log" ("resource_type", "resource_id", "id");
+CREATE INDEX ON "audit_log" ("created_at");
+
+CREATE FUNCTION "audit_log_immutable"() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only' USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_log_no_update" BEFORE UPDATE OR DELETE ON "audit_log"
+  FOR EACH ROW EXECUTE FUNCTION "audit_log_immutable"();
+CREATE TRIGGER "audit_log_no_truncate" BEFORE TRUNCATE ON "audit_log"
+  FOR EACH STATEMENT EXECUTE FUNCTION "audit_log_immutable"();
+
+-- Auditors read the audit log and every account, and change nothing.
+ALTER TYPE "user_role" ADD VALUE IF NOT EXISTS 'auditor';
@@ -0,0 +1,17 @@
+-- The database cannot decrypt what the application encrypted, so going back would leave
+-- ciphertext where plaintext is expected.
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM users WHERE data_key IS NOT NULL)
+    OR EXISTS (SELECT 1 FROM verify_emails WHERE data_key IS NOT NULL) THEN
+    RAISE EXCEPTION 'encrypted rows remain: decrypt them before migrating down';
+  END IF;
+END $$;
+
+ALTER TABLE "verify_emails" DROP COLUMN IF EXISTS "data_key_id";
+ALTER TABLE "verify_emails" DROP COLUMN IF EXISTS "data_key";
+
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_email_index_key";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "email_index";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "data_key_id";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "data_key";
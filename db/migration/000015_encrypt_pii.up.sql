@@ -0,0 +1,14 @@
+-- The full name and email of a user, and the address a verification code was sent to, are
+-- encrypted with a data key of their own, stored wrapped by a master key. A row without a
+-- data key is still in plaintext, until the re-encryption job gets to it.
+ALTER TABLE "users" ADD COLUMN "data_key" bytea;
+ALTER TABLE "users" ADD COLUMN "data_key_id" varchar;
+-- An HMAC of the lowercased email, to look a user up by it and keep it unique without
+-- decrypting every row.
+ALTER TABLE "users" ADD COLUMN "email_index" bytea;
+ALTER TABLE "users" ADD CONSTRAINT "users_email_index_key" UNIQUE ("email_index");
+CREATE INDEX ON "users" ("data_key_id");
+
+ALTER TABLE "verify_emails" ADD COLUMN "data_key" bytea;
+ALTER TABLE "verify_emails" ADD COLUMN "data_key_id" varchar;
+CREATE INDEX ON "verify_emails" ("data_key_id");
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "data_requests";
+DROP TYPE IF EXISTS "data_request_status";
+DROP TYPE IF EXISTS "data_request_kind";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "erased_at";
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "closed_at";
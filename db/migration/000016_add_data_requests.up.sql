@@ -0,0 +1,29 @@
+-- A closed account takes no more transfers. An account can only be closed with a zero
+-- balance, and it is kept, with its entries and transfers, for as long as the records
+-- have to be.
+ALTER TABLE "accounts" ADD COLUMN "closed_at" timestamptz;
+
+-- An erased user keeps their username, which the financial records point to, but their
+-- name and email are replaced and they can no longer log in.
+ALTER TABLE "users" ADD COLUMN "erased_at" timestamptz;
+
+CREATE TYPE "data_request_kind" AS ENUM ('erasure');
+CREATE TYPE "data_request_status" AS ENUM ('pending', 'approved', 'rejected');
+
+-- A user's request to have their data erased. An admin approves it, which carries it out,
+-- or rejects it; either way it is kept as the record of what was asked and decided.
+CREATE TABLE "data_requests" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL REFERENCES "users" ("username"),
+  "kind" data_request_kind NOT NULL,
+  "status" data_request_status NOT NULL DEFAULT 'pending',
+  "reason" varchar NOT NULL DEFAULT '',
+  "reviewed_by" varchar,
+  "review_note" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "reviewed_at" timestamptz
+);
+
+-- A user has at most one request of each kind waiting for review.
+CREATE UNIQUE INDEX "data_requests_pending_key" ON "data_requests" ("username", "kind") WHERE "status" = 'pending';
+CREATE INDEX ON "data_requests" ("status", "id");
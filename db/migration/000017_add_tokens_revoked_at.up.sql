@@ -0,0 +1,4 @@
+-- The access tokens a user was issued before tokens_revoked_at no longer work, as those
+-- issued before password_changed_at. A change of role sets it, so that no token carries
+-- the role the user had before.
+ALTER TABLE "users" ADD COLUMN "tokens_revoked_at" timestamptz;
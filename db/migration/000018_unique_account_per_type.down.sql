@@ -0,0 +1,5 @@
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "owner_currency_account_type_key";
+
+CREATE UNIQUE INDEX ON "accounts" ("owner", "currency");
+
+ALTER TABLE "accounts" ADD CONSTRAINT "owner_currency_key" UNIQUE ("owner", "currency");
@@ -0,0 +1,7 @@
+-- An owner has one account per currency and account type, so that a customer with a
+-- checking account can also open a savings account in the same currency.
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "owner_currency_key";
+
+DROP INDEX IF EXISTS "accounts_owner_currency_idx";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "owner_currency_account_type_key" UNIQUE ("owner", "currency", "account_type");
@@ -0,0 +1,10 @@
+DELETE FROM "login_failures" WHERE "scope" IN ('password_reset_email', 'password_reset_ip');
+
+-- Values cannot be dropped from an enum, so the type is made again without them.
+ALTER TYPE "login_failure_scope" RENAME TO "login_failure_scope_old";
+
+CREATE TYPE "login_failure_scope" AS ENUM ('username', 'ip');
+
+ALTER TABLE "login_failures" ALTER COLUMN "scope" TYPE login_failure_scope USING "scope"::text::login_failure_scope;
+
+DROP TYPE "login_failure_scope_old";
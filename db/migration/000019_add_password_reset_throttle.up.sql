@@ -0,0 +1,5 @@
+-- Password reset requests are counted per email address and per client IP, the way failed
+-- logins are, so that the reset endpoint cannot be used to flood an inbox.
+ALTER TYPE "login_failure_scope" ADD VALUE IF NOT EXISTS 'password_reset_email';
+
+ALTER TYPE "login_failure_scope" ADD VALUE IF NOT EXISTS 'password_reset_ip';
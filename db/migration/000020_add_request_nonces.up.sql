@@ -0,0 +1,13 @@
+-- The nonces of the signed requests accepted lately, per API key, so that a request is
+-- accepted once by whichever server gets it. A nonce is kept until its signature would be
+-- too old to verify anyway; the expired ones are swept.
+CREATE TABLE "request_nonces" (
+  "api_key_id" bigint NOT NULL,
+  "nonce" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("api_key_id", "nonce")
+);
+
+CREATE INDEX ON "request_nonces" ("expires_at");
+
+ALTER TABLE "request_nonces" ADD FOREIGN KEY ("api_key_id") REFERENCES "api_keys" ("id") ON DELETE CASCADE;
@@ -0,0 +1,158 @@
+// Package migration embeds the SQL migrations in this directory into the binary and applies
+// them with golang-migrate, which records the version in the same schema_migrations table as
+// the migrate CLI. The files stay the schema sqlc generates the queries from.
+package migration
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Version is the newest embedded migration, the schema version this build expects.
+var Version = mustLatest(files)
+
+// Status describes how far the database is migrated.
+type Status struct {
+	Version uint   `json:"version"`
+	Dirty   bool   `json:"dirty"`
+	Latest  uint   `json:"latest"`
+	Pending []uint `json:"pending"`
+}
+
+// Migrator applies the embedded migrations to the database behind a pool. Every change
+// runs under a Postgres advisory lock, so replicas migrating at the same time take turns
+// and all but the first find nothing to do.
+type Migrator struct {
+	migrate *migrate.Migrate
+}
+
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	source, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := pgxmigrate.WithInstance(stdlib.OpenDBFromPool(pool), &pgxmigrate.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{migrate: m}, nil
+}
+
+// Up applies every pending migration.
+func (migrator *Migrator) Up() error {
+	return ignoreNoChange(migrator.migrate.Up())
+}
+
+// UpN applies the next n pending migrations.
+func (migrator *Migrator) UpN(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("cannot apply %d migrations", n)
+	}
+	return ignoreNoChange(migrator.migrate.Steps(n))
+}
+
+// DownN reverts the n most recent migrations.
+func (migrator *Migrator) DownN(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("cannot revert %d migrations", n)
+	}
+	return ignoreNoChange(migrator.migrate.Steps(-n))
+}
+
+// DownAll reverts every migration.
+func (migrator *Migrator) DownAll() error {
+	return ignoreNoChange(migrator.migrate.Down())
+}
+
+// Force records version as applied and clean without running anything, to recover from a
+// migration that failed halfway once the database has been fixed by hand.
+func (migrator *Migrator) Force(version int) error {
+	return migrator.migrate.Force(version)
+}
+
+// Status returns the applied version and the migrations still to run.
+func (migrator *Migrator) Status() (Status, error) {
+	status := Status{Latest: Version, Pending: []uint{}}
+
+	version, dirty, err := migrator.migrate.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, err
+	}
+	status.Version, status.Dirty = version, dirty
+
+	versions, err := versions(files)
+	if err != nil {
+		return status, err
+	}
+	for _, v := range versions {
+		if v > status.Version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+	return status, nil
+}
+
+// Close releases the migrator's connection. The pool stays open.
+func (migrator *Migrator) Close() error {
+	sourceErr, dbErr := migrator.migrate.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+// versions returns the versions of the up migrations in fsys, in order.
+func versions(fsys fs.FS) ([]uint, error) {
+	names, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]uint, 0, len(names))
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s has no version prefix", name)
+		}
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		result = append(result, uint(version))
+	}
+	return result, nil
+}
+
+func mustLatest(fsys fs.FS) uint {
+	versions, err := versions(fsys)
+	if err != nil {
+		panic(err)
+	}
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1]
+}
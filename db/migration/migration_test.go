@@ -0,0 +1,48 @@
+package migration
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ups, err := fs.Glob(files, "*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, ups)
+
+	// Every migration can be reverted.
+	for _, up := range ups {
+		_, err := fs.Stat(files, strings.TrimSuffix(up, ".up.sql")+".down.sql")
+		require.NoError(t, err, up)
+	}
+
+	all, err := versions(files)
+	require.NoError(t, err)
+	require.Len(t, all, len(ups))
+	require.Equal(t, all[len(all)-1], Version)
+	for i := range all {
+		require.Equal(t, uint(i+1), all[i], "migration versions must have no gaps")
+	}
+}
+
+func TestVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_b.up.sql":   {},
+		"000002_b.down.sql": {},
+		"000010_c.up.sql":   {},
+		"000001_a.up.sql":   {},
+	}
+
+	all, err := versions(fsys)
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2, 10}, all)
+	require.Equal(t, uint(10), mustLatest(fsys))
+	require.Zero(t, mustLatest(fstest.MapFS{}))
+
+	_, err = versions(fstest.MapFS{"init.up.sql": {}})
+	require.Error(t, err)
+}
@@ -14,6 +14,7 @@ import (
 	reflect "reflect"
 
 	db "example.com/db/sqlc"
+	pgtype "github.com/jackc/pgx/v5/pgtype"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -42,11 +43,12 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 }
 
 // AddAccountBalance mocks base method.
-func (m *MockStore) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) error {
+func (m *MockStore) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddAccountBalance", ctx, arg)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AddAccountBalance indicates an expected call of AddAccountBalance.
@@ -55,6 +57,81 @@ func (mr *MockStoreMockRecorder) AddAccountBalance(ctx, arg any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountBalance", reflect.TypeOf((*MockStore)(nil).AddAccountBalance), ctx, arg)
 }
 
+// ChangePasswordTx mocks base method.
+func (m *MockStore) ChangePasswordTx(ctx context.Context, arg db.ChangePasswordTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePasswordTx", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePasswordTx indicates an expected call of ChangePasswordTx.
+func (mr *MockStoreMockRecorder) ChangePasswordTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePasswordTx", reflect.TypeOf((*MockStore)(nil).ChangePasswordTx), ctx, arg)
+}
+
+// ChargeOverdraftInterestTx mocks base method.
+func (m *MockStore) ChargeOverdraftInterestTx(ctx context.Context, arg db.ChargeOverdraftInterestTxParams) (db.ChargeOverdraftInterestTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChargeOverdraftInterestTx", ctx, arg)
+	ret0, _ := ret[0].(db.ChargeOverdraftInterestTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChargeOverdraftInterestTx indicates an expected call of ChargeOverdraftInterestTx.
+func (mr *MockStoreMockRecorder) ChargeOverdraftInterestTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChargeOverdraftInterestTx", reflect.TypeOf((*MockStore)(nil).ChargeOverdraftInterestTx), ctx, arg)
+}
+
+// ClaimDueWebhookDeliveries mocks base method.
+func (m *MockStore) ClaimDueWebhookDeliveries(ctx context.Context, arg db.ClaimDueWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueWebhookDeliveries", ctx, arg)
+	ret0, _ := ret[0].([]db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueWebhookDeliveries indicates an expected call of ClaimDueWebhookDeliveries.
+func (mr *MockStoreMockRecorder) ClaimDueWebhookDeliveries(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueWebhookDeliveries", reflect.TypeOf((*MockStore)(nil).ClaimDueWebhookDeliveries), ctx, arg)
+}
+
+// CloseAccount mocks base method.
+func (m *MockStore) CloseAccount(ctx context.Context, id int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseAccount", ctx, id)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseAccount indicates an expected call of CloseAccount.
+func (mr *MockStoreMockRecorder) CloseAccount(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseAccount", reflect.TypeOf((*MockStore)(nil).CloseAccount), ctx, id)
+}
+
+// CloseAccountTx mocks base method.
+func (m *MockStore) CloseAccountTx(ctx context.Context, accountID int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseAccountTx", ctx, accountID)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseAccountTx indicates an expected call of CloseAccountTx.
+func (mr *MockStoreMockRecorder) CloseAccountTx(ctx, accountID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseAccountTx", reflect.TypeOf((*MockStore)(nil).CloseAccountTx), ctx, accountID)
+}
+
 // CreateAccount mocks base method.
 func (m *MockStore) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -70,340 +147,1936 @@ func (mr *MockStoreMockRecorder) CreateAccount(ctx, arg any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockStore)(nil).CreateAccount), ctx, arg)
 }
 
-// CreateEntry mocks base method.
-func (m *MockStore) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+// CreateAccountTx mocks base method.
+func (m *MockStore) CreateAccountTx(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateEntry", ctx, arg)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "CreateAccountTx", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateEntry indicates an expected call of CreateEntry.
-func (mr *MockStoreMockRecorder) CreateEntry(ctx, arg any) *gomock.Call {
+// CreateAccountTx indicates an expected call of CreateAccountTx.
+func (mr *MockStoreMockRecorder) CreateAccountTx(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntry", reflect.TypeOf((*MockStore)(nil).CreateEntry), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccountTx", reflect.TypeOf((*MockStore)(nil).CreateAccountTx), ctx, arg)
 }
 
-// CreateTransfer mocks base method.
-func (m *MockStore) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+// CreateApiKey mocks base method.
+func (m *MockStore) CreateApiKey(ctx context.Context, arg db.CreateApiKeyParams) (db.ApiKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTransfer", ctx, arg)
-	ret0, _ := ret[0].(db.Transfer)
+	ret := m.ctrl.Call(m, "CreateApiKey", ctx, arg)
+	ret0, _ := ret[0].(db.ApiKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateTransfer indicates an expected call of CreateTransfer.
-func (mr *MockStoreMockRecorder) CreateTransfer(ctx, arg any) *gomock.Call {
+// CreateApiKey indicates an expected call of CreateApiKey.
+func (mr *MockStoreMockRecorder) CreateApiKey(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApiKey", reflect.TypeOf((*MockStore)(nil).CreateApiKey), ctx, arg)
 }
 
-// CreateUser mocks base method.
-func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+// CreateApiKeyTx mocks base method.
+func (m *MockStore) CreateApiKeyTx(ctx context.Context, arg db.CreateApiKeyParams) (db.ApiKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
-	ret0, _ := ret[0].(db.User)
+	ret := m.ctrl.Call(m, "CreateApiKeyTx", ctx, arg)
+	ret0, _ := ret[0].(db.ApiKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
+// CreateApiKeyTx indicates an expected call of CreateApiKeyTx.
+func (mr *MockStoreMockRecorder) CreateApiKeyTx(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApiKeyTx", reflect.TypeOf((*MockStore)(nil).CreateApiKeyTx), ctx, arg)
 }
 
-// DeleteAccount mocks base method.
-func (m *MockStore) DeleteAccount(ctx context.Context, id int64) error {
+// CreateAuditLog mocks base method.
+func (m *MockStore) CreateAuditLog(ctx context.Context, arg db.CreateAuditLogParams) (db.AuditLog, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAccount", ctx, id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateAuditLog", ctx, arg)
+	ret0, _ := ret[0].(db.AuditLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteAccount indicates an expected call of DeleteAccount.
-func (mr *MockStoreMockRecorder) DeleteAccount(ctx, id any) *gomock.Call {
+// CreateAuditLog indicates an expected call of CreateAuditLog.
+func (mr *MockStoreMockRecorder) CreateAuditLog(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditLog", reflect.TypeOf((*MockStore)(nil).CreateAuditLog), ctx, arg)
 }
 
-// DeleteEntry mocks base method.
-func (m *MockStore) DeleteEntry(ctx context.Context, id int64) error {
+// CreateDataRequest mocks base method.
+func (m *MockStore) CreateDataRequest(ctx context.Context, arg db.CreateDataRequestParams) (db.DataRequest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteEntry", ctx, id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateDataRequest", ctx, arg)
+	ret0, _ := ret[0].(db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteEntry indicates an expected call of DeleteEntry.
-func (mr *MockStoreMockRecorder) DeleteEntry(ctx, id any) *gomock.Call {
+// CreateDataRequest indicates an expected call of CreateDataRequest.
+func (mr *MockStoreMockRecorder) CreateDataRequest(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntry", reflect.TypeOf((*MockStore)(nil).DeleteEntry), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDataRequest", reflect.TypeOf((*MockStore)(nil).CreateDataRequest), ctx, arg)
 }
 
-// DeleteTransfer mocks base method.
-func (m *MockStore) DeleteTransfer(ctx context.Context, id int64) error {
+// CreateEntry mocks base method.
+func (m *MockStore) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTransfer", ctx, id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateEntry", ctx, arg)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteTransfer indicates an expected call of DeleteTransfer.
-func (mr *MockStoreMockRecorder) DeleteTransfer(ctx, id any) *gomock.Call {
+// CreateEntry indicates an expected call of CreateEntry.
+func (mr *MockStoreMockRecorder) CreateEntry(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTransfer", reflect.TypeOf((*MockStore)(nil).DeleteTransfer), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntry", reflect.TypeOf((*MockStore)(nil).CreateEntry), ctx, arg)
 }
 
-// GetAccount mocks base method.
-func (m *MockStore) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+// CreateInterestAccrual mocks base method.
+func (m *MockStore) CreateInterestAccrual(ctx context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", ctx, id)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreateInterestAccrual", ctx, arg)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccount indicates an expected call of GetAccount.
-func (mr *MockStoreMockRecorder) GetAccount(ctx, id any) *gomock.Call {
+// CreateInterestAccrual indicates an expected call of CreateInterestAccrual.
+func (mr *MockStoreMockRecorder) CreateInterestAccrual(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInterestAccrual", reflect.TypeOf((*MockStore)(nil).CreateInterestAccrual), ctx, arg)
 }
 
-// GetAccountForUpdate mocks base method.
-func (m *MockStore) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+// CreateInterestPosting mocks base method.
+func (m *MockStore) CreateInterestPosting(ctx context.Context, arg db.CreateInterestPostingParams) (db.InterestPosting, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountForUpdate", ctx, id)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreateInterestPosting", ctx, arg)
+	ret0, _ := ret[0].(db.InterestPosting)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
-func (mr *MockStoreMockRecorder) GetAccountForUpdate(ctx, id any) *gomock.Call {
+// CreateInterestPosting indicates an expected call of CreateInterestPosting.
+func (mr *MockStoreMockRecorder) CreateInterestPosting(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInterestPosting", reflect.TypeOf((*MockStore)(nil).CreateInterestPosting), ctx, arg)
 }
 
-// GetEntry mocks base method.
-func (m *MockStore) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+// CreateOverdraftCharge mocks base method.
+func (m *MockStore) CreateOverdraftCharge(ctx context.Context, arg db.CreateOverdraftChargeParams) (db.OverdraftCharge, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEntry", ctx, id)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "CreateOverdraftCharge", ctx, arg)
+	ret0, _ := ret[0].(db.OverdraftCharge)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetEntry indicates an expected call of GetEntry.
-func (mr *MockStoreMockRecorder) GetEntry(ctx, id any) *gomock.Call {
+// CreateOverdraftCharge indicates an expected call of CreateOverdraftCharge.
+func (mr *MockStoreMockRecorder) CreateOverdraftCharge(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntry", reflect.TypeOf((*MockStore)(nil).GetEntry), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOverdraftCharge", reflect.TypeOf((*MockStore)(nil).CreateOverdraftCharge), ctx, arg)
 }
 
-// GetTransfer mocks base method.
-func (m *MockStore) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+// CreatePasswordHistory mocks base method.
+func (m *MockStore) CreatePasswordHistory(ctx context.Context, arg db.CreatePasswordHistoryParams) (db.PasswordHistory, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransfer", ctx, id)
-	ret0, _ := ret[0].(db.Transfer)
+	ret := m.ctrl.Call(m, "CreatePasswordHistory", ctx, arg)
+	ret0, _ := ret[0].(db.PasswordHistory)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransfer indicates an expected call of GetTransfer.
-func (mr *MockStoreMockRecorder) GetTransfer(ctx, id any) *gomock.Call {
+// CreatePasswordHistory indicates an expected call of CreatePasswordHistory.
+func (mr *MockStoreMockRecorder) CreatePasswordHistory(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockStore)(nil).GetTransfer), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordHistory", reflect.TypeOf((*MockStore)(nil).CreatePasswordHistory), ctx, arg)
 }
 
-// GetTransferFromAccount mocks base method.
-func (m *MockStore) GetTransferFromAccount(ctx context.Context, arg db.GetTransferFromAccountParams) ([]db.Transfer, error) {
+// CreatePasswordResetToken mocks base method.
+func (m *MockStore) CreatePasswordResetToken(ctx context.Context, arg db.CreatePasswordResetTokenParams) (db.PasswordResetToken, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransferFromAccount", ctx, arg)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "CreatePasswordResetToken", ctx, arg)
+	ret0, _ := ret[0].(db.PasswordResetToken)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransferFromAccount indicates an expected call of GetTransferFromAccount.
-func (mr *MockStoreMockRecorder) GetTransferFromAccount(ctx, arg any) *gomock.Call {
+// CreatePasswordResetToken indicates an expected call of CreatePasswordResetToken.
+func (mr *MockStoreMockRecorder) CreatePasswordResetToken(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferFromAccount", reflect.TypeOf((*MockStore)(nil).GetTransferFromAccount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetToken", reflect.TypeOf((*MockStore)(nil).CreatePasswordResetToken), ctx, arg)
 }
 
-// GetTransferFromAndToAccount mocks base method.
-func (m *MockStore) GetTransferFromAndToAccount(ctx context.Context, arg db.GetTransferFromAndToAccountParams) ([]db.Transfer, error) {
+// CreateTOTPBackupCode mocks base method.
+func (m *MockStore) CreateTOTPBackupCode(ctx context.Context, arg db.CreateTOTPBackupCodeParams) (db.TotpBackupCode, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransferFromAndToAccount", ctx, arg)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "CreateTOTPBackupCode", ctx, arg)
+	ret0, _ := ret[0].(db.TotpBackupCode)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransferFromAndToAccount indicates an expected call of GetTransferFromAndToAccount.
-func (mr *MockStoreMockRecorder) GetTransferFromAndToAccount(ctx, arg any) *gomock.Call {
+// CreateTOTPBackupCode indicates an expected call of CreateTOTPBackupCode.
+func (mr *MockStoreMockRecorder) CreateTOTPBackupCode(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferFromAndToAccount", reflect.TypeOf((*MockStore)(nil).GetTransferFromAndToAccount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTOTPBackupCode", reflect.TypeOf((*MockStore)(nil).CreateTOTPBackupCode), ctx, arg)
 }
 
-// GetTransferToAccount mocks base method.
-func (m *MockStore) GetTransferToAccount(ctx context.Context, arg db.GetTransferToAccountParams) ([]db.Transfer, error) {
+// CreateTransfer mocks base method.
+func (m *MockStore) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransferToAccount", ctx, arg)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "CreateTransfer", ctx, arg)
+	ret0, _ := ret[0].(db.Transfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransferToAccount indicates an expected call of GetTransferToAccount.
-func (mr *MockStoreMockRecorder) GetTransferToAccount(ctx, arg any) *gomock.Call {
+// CreateTransfer indicates an expected call of CreateTransfer.
+func (mr *MockStoreMockRecorder) CreateTransfer(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferToAccount", reflect.TypeOf((*MockStore)(nil).GetTransferToAccount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), ctx, arg)
 }
 
-// GetUser mocks base method.
-func (m *MockStore) GetUser(ctx context.Context, username string) (db.User, error) {
+// CreateUser mocks base method.
+func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", ctx, username)
+	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
 	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockStoreMockRecorder) GetUser(ctx, username any) *gomock.Call {
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
 }
 
-// ListAccounts mocks base method.
-func (m *MockStore) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+// CreateUserTx mocks base method.
+func (m *MockStore) CreateUserTx(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAccounts", ctx, arg)
-	ret0, _ := ret[0].([]db.Account)
+	ret := m.ctrl.Call(m, "CreateUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListAccounts indicates an expected call of ListAccounts.
-func (mr *MockStoreMockRecorder) ListAccounts(ctx, arg any) *gomock.Call {
+// CreateUserTx indicates an expected call of CreateUserTx.
+func (mr *MockStoreMockRecorder) CreateUserTx(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockStore)(nil).ListAccounts), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserTx", reflect.TypeOf((*MockStore)(nil).CreateUserTx), ctx, arg)
 }
 
-// ListEntries mocks base method.
-func (m *MockStore) ListEntries(ctx context.Context, arg db.ListEntriesParams) ([]db.Entry, error) {
+// CreateVerifyEmail mocks base method.
+func (m *MockStore) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListEntries", ctx, arg)
-	ret0, _ := ret[0].([]db.Entry)
+	ret := m.ctrl.Call(m, "CreateVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmail)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListEntries indicates an expected call of ListEntries.
-func (mr *MockStoreMockRecorder) ListEntries(ctx, arg any) *gomock.Call {
+// CreateVerifyEmail indicates an expected call of CreateVerifyEmail.
+func (mr *MockStoreMockRecorder) CreateVerifyEmail(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockStore)(nil).ListEntries), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVerifyEmail", reflect.TypeOf((*MockStore)(nil).CreateVerifyEmail), ctx, arg)
 }
 
-// ListEntriesForAccount mocks base method.
-func (m *MockStore) ListEntriesForAccount(ctx context.Context, arg db.ListEntriesForAccountParams) ([]db.Entry, error) {
+// CreateWebhookDeliveriesForEvent mocks base method.
+func (m *MockStore) CreateWebhookDeliveriesForEvent(ctx context.Context, arg db.CreateWebhookDeliveriesForEventParams) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListEntriesForAccount", ctx, arg)
-	ret0, _ := ret[0].([]db.Entry)
+	ret := m.ctrl.Call(m, "CreateWebhookDeliveriesForEvent", ctx, arg)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListEntriesForAccount indicates an expected call of ListEntriesForAccount.
-func (mr *MockStoreMockRecorder) ListEntriesForAccount(ctx, arg any) *gomock.Call {
+// CreateWebhookDeliveriesForEvent indicates an expected call of CreateWebhookDeliveriesForEvent.
+func (mr *MockStoreMockRecorder) CreateWebhookDeliveriesForEvent(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntriesForAccount", reflect.TypeOf((*MockStore)(nil).ListEntriesForAccount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookDeliveriesForEvent", reflect.TypeOf((*MockStore)(nil).CreateWebhookDeliveriesForEvent), ctx, arg)
 }
 
-// ListTransfers mocks base method.
-func (m *MockStore) ListTransfers(ctx context.Context, arg db.ListTransfersParams) ([]db.Transfer, error) {
+// CreateWebhookEvent mocks base method.
+func (m *MockStore) CreateWebhookEvent(ctx context.Context, arg db.CreateWebhookEventParams) (db.WebhookEvent, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTransfers", ctx, arg)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "CreateWebhookEvent", ctx, arg)
+	ret0, _ := ret[0].(db.WebhookEvent)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListTransfers indicates an expected call of ListTransfers.
-func (mr *MockStoreMockRecorder) ListTransfers(ctx, arg any) *gomock.Call {
+// CreateWebhookEvent indicates an expected call of CreateWebhookEvent.
+func (mr *MockStoreMockRecorder) CreateWebhookEvent(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockStore)(nil).ListTransfers), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookEvent", reflect.TypeOf((*MockStore)(nil).CreateWebhookEvent), ctx, arg)
 }
 
-// SubtractAccountBalance mocks base method.
-func (m *MockStore) SubtractAccountBalance(ctx context.Context, arg db.SubtractAccountBalanceParams) error {
+// CreateWebhookSubscription mocks base method.
+func (m *MockStore) CreateWebhookSubscription(ctx context.Context, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SubtractAccountBalance", ctx, arg)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", ctx, arg)
+	ret0, _ := ret[0].(db.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// SubtractAccountBalance indicates an expected call of SubtractAccountBalance.
-func (mr *MockStoreMockRecorder) SubtractAccountBalance(ctx, arg any) *gomock.Call {
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockStoreMockRecorder) CreateWebhookSubscription(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubtractAccountBalance", reflect.TypeOf((*MockStore)(nil).SubtractAccountBalance), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockStore)(nil).CreateWebhookSubscription), ctx, arg)
 }
 
-// TransferTx mocks base method.
-func (m *MockStore) TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+// DebitAccountBalance mocks base method.
+func (m *MockStore) DebitAccountBalance(ctx context.Context, arg db.DebitAccountBalanceParams) (db.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransferTx", ctx, arg)
-	ret0, _ := ret[0].(db.TransferTxResult)
+	ret := m.ctrl.Call(m, "DebitAccountBalance", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TransferTx indicates an expected call of TransferTx.
-func (mr *MockStoreMockRecorder) TransferTx(ctx, arg any) *gomock.Call {
+// DebitAccountBalance indicates an expected call of DebitAccountBalance.
+func (mr *MockStoreMockRecorder) DebitAccountBalance(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebitAccountBalance", reflect.TypeOf((*MockStore)(nil).DebitAccountBalance), ctx, arg)
 }
 
-// UpdateAccountBalance mocks base method.
-func (m *MockStore) UpdateAccountBalance(ctx context.Context, arg db.UpdateAccountBalanceParams) error {
+// DeleteAccount mocks base method.
+func (m *MockStore) DeleteAccount(ctx context.Context, id int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateAccountBalance", ctx, arg)
+	ret := m.ctrl.Call(m, "DeleteAccount", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateAccountBalance indicates an expected call of UpdateAccountBalance.
-func (mr *MockStoreMockRecorder) UpdateAccountBalance(ctx, arg any) *gomock.Call {
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockStoreMockRecorder) DeleteAccount(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountBalance", reflect.TypeOf((*MockStore)(nil).UpdateAccountBalance), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), ctx, id)
 }
 
-// UpdateEntryAmount mocks base method.
-func (m *MockStore) UpdateEntryAmount(ctx context.Context, arg db.UpdateEntryAmountParams) error {
+// DeleteEntry mocks base method.
+func (m *MockStore) DeleteEntry(ctx context.Context, id int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateEntryAmount", ctx, arg)
+	ret := m.ctrl.Call(m, "DeleteEntry", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateEntryAmount indicates an expected call of UpdateEntryAmount.
-func (mr *MockStoreMockRecorder) UpdateEntryAmount(ctx, arg any) *gomock.Call {
+// DeleteEntry indicates an expected call of DeleteEntry.
+func (mr *MockStoreMockRecorder) DeleteEntry(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEntryAmount", reflect.TypeOf((*MockStore)(nil).UpdateEntryAmount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntry", reflect.TypeOf((*MockStore)(nil).DeleteEntry), ctx, id)
 }
 
-// UpdateTransferAmount mocks base method.
-func (m *MockStore) UpdateTransferAmount(ctx context.Context, arg db.UpdateTransferAmountParams) error {
+// DeleteExpiredRequestNonces mocks base method.
+func (m *MockStore) DeleteExpiredRequestNonces(ctx context.Context) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTransferAmount", ctx, arg)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "DeleteExpiredRequestNonces", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// UpdateTransferAmount indicates an expected call of UpdateTransferAmount.
-func (mr *MockStoreMockRecorder) UpdateTransferAmount(ctx, arg any) *gomock.Call {
+// DeleteExpiredRequestNonces indicates an expected call of DeleteExpiredRequestNonces.
+func (mr *MockStoreMockRecorder) DeleteExpiredRequestNonces(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransferAmount", reflect.TypeOf((*MockStore)(nil).UpdateTransferAmount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRequestNonces", reflect.TypeOf((*MockStore)(nil).DeleteExpiredRequestNonces), ctx)
+}
+
+// DeleteLoginFailure mocks base method.
+func (m *MockStore) DeleteLoginFailure(ctx context.Context, arg db.DeleteLoginFailureParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLoginFailure", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteLoginFailure indicates an expected call of DeleteLoginFailure.
+func (mr *MockStoreMockRecorder) DeleteLoginFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginFailure", reflect.TypeOf((*MockStore)(nil).DeleteLoginFailure), ctx, arg)
+}
+
+// DeletePasswordHistory mocks base method.
+func (m *MockStore) DeletePasswordHistory(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePasswordHistory", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePasswordHistory indicates an expected call of DeletePasswordHistory.
+func (mr *MockStoreMockRecorder) DeletePasswordHistory(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePasswordHistory", reflect.TypeOf((*MockStore)(nil).DeletePasswordHistory), ctx, username)
+}
+
+// DeletePasswordResetTokens mocks base method.
+func (m *MockStore) DeletePasswordResetTokens(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePasswordResetTokens", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePasswordResetTokens indicates an expected call of DeletePasswordResetTokens.
+func (mr *MockStoreMockRecorder) DeletePasswordResetTokens(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePasswordResetTokens", reflect.TypeOf((*MockStore)(nil).DeletePasswordResetTokens), ctx, username)
+}
+
+// DeleteTOTPBackupCodes mocks base method.
+func (m *MockStore) DeleteTOTPBackupCodes(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTPBackupCodes", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTPBackupCodes indicates an expected call of DeleteTOTPBackupCodes.
+func (mr *MockStoreMockRecorder) DeleteTOTPBackupCodes(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTPBackupCodes", reflect.TypeOf((*MockStore)(nil).DeleteTOTPBackupCodes), ctx, username)
+}
+
+// DeleteTransfer mocks base method.
+func (m *MockStore) DeleteTransfer(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTransfer", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTransfer indicates an expected call of DeleteTransfer.
+func (mr *MockStoreMockRecorder) DeleteTransfer(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTransfer", reflect.TypeOf((*MockStore)(nil).DeleteTransfer), ctx, id)
+}
+
+// DeleteVerifyEmails mocks base method.
+func (m *MockStore) DeleteVerifyEmails(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVerifyEmails", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteVerifyEmails indicates an expected call of DeleteVerifyEmails.
+func (mr *MockStoreMockRecorder) DeleteVerifyEmails(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVerifyEmails", reflect.TypeOf((*MockStore)(nil).DeleteVerifyEmails), ctx, username)
+}
+
+// DeleteWebhookSubscription mocks base method.
+func (m *MockStore) DeleteWebhookSubscription(ctx context.Context, arg db.DeleteWebhookSubscriptionParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscription", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebhookSubscription indicates an expected call of DeleteWebhookSubscription.
+func (mr *MockStoreMockRecorder) DeleteWebhookSubscription(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscription", reflect.TypeOf((*MockStore)(nil).DeleteWebhookSubscription), ctx, arg)
+}
+
+// DeleteWebhookSubscriptions mocks base method.
+func (m *MockStore) DeleteWebhookSubscriptions(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscriptions", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookSubscriptions indicates an expected call of DeleteWebhookSubscriptions.
+func (mr *MockStoreMockRecorder) DeleteWebhookSubscriptions(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscriptions", reflect.TypeOf((*MockStore)(nil).DeleteWebhookSubscriptions), ctx, username)
+}
+
+// EnableTOTPTx mocks base method.
+func (m *MockStore) EnableTOTPTx(ctx context.Context, arg db.EnableTOTPTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTPTx", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableTOTPTx indicates an expected call of EnableTOTPTx.
+func (mr *MockStoreMockRecorder) EnableTOTPTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTPTx", reflect.TypeOf((*MockStore)(nil).EnableTOTPTx), ctx, arg)
+}
+
+// EnableUserTOTP mocks base method.
+func (m *MockStore) EnableUserTOTP(ctx context.Context, arg db.EnableUserTOTPParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableUserTOTP", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableUserTOTP indicates an expected call of EnableUserTOTP.
+func (mr *MockStoreMockRecorder) EnableUserTOTP(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableUserTOTP", reflect.TypeOf((*MockStore)(nil).EnableUserTOTP), ctx, arg)
+}
+
+// EraseUser mocks base method.
+func (m *MockStore) EraseUser(ctx context.Context, arg db.EraseUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseUser", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EraseUser indicates an expected call of EraseUser.
+func (mr *MockStoreMockRecorder) EraseUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseUser", reflect.TypeOf((*MockStore)(nil).EraseUser), ctx, arg)
+}
+
+// FreezeAccountTx mocks base method.
+func (m *MockStore) FreezeAccountTx(ctx context.Context, arg db.FreezeAccountTxParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FreezeAccountTx", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FreezeAccountTx indicates an expected call of FreezeAccountTx.
+func (mr *MockStoreMockRecorder) FreezeAccountTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FreezeAccountTx", reflect.TypeOf((*MockStore)(nil).FreezeAccountTx), ctx, arg)
+}
+
+// GetAccount mocks base method.
+func (m *MockStore) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", ctx, id)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockStoreMockRecorder) GetAccount(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), ctx, id)
+}
+
+// GetAccountByOwnerCurrencyAndType mocks base method.
+func (m *MockStore) GetAccountByOwnerCurrencyAndType(ctx context.Context, arg db.GetAccountByOwnerCurrencyAndTypeParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountByOwnerCurrencyAndType", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountByOwnerCurrencyAndType indicates an expected call of GetAccountByOwnerCurrencyAndType.
+func (mr *MockStoreMockRecorder) GetAccountByOwnerCurrencyAndType(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountByOwnerCurrencyAndType", reflect.TypeOf((*MockStore)(nil).GetAccountByOwnerCurrencyAndType), ctx, arg)
+}
+
+// GetAccountForUpdate mocks base method.
+func (m *MockStore) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
+func (mr *MockStoreMockRecorder) GetAccountForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), ctx, id)
+}
+
+// GetApiKey mocks base method.
+func (m *MockStore) GetApiKey(ctx context.Context, id int64) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKey", ctx, id)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKey indicates an expected call of GetApiKey.
+func (mr *MockStoreMockRecorder) GetApiKey(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKey", reflect.TypeOf((*MockStore)(nil).GetApiKey), ctx, id)
+}
+
+// GetApiKeyByHash mocks base method.
+func (m *MockStore) GetApiKeyByHash(ctx context.Context, keyHash string) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKeyByHash", ctx, keyHash)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKeyByHash indicates an expected call of GetApiKeyByHash.
+func (mr *MockStoreMockRecorder) GetApiKeyByHash(ctx, keyHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKeyByHash", reflect.TypeOf((*MockStore)(nil).GetApiKeyByHash), ctx, keyHash)
+}
+
+// GetDataRequestForUpdate mocks base method.
+func (m *MockStore) GetDataRequestForUpdate(ctx context.Context, id int64) (db.DataRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataRequestForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataRequestForUpdate indicates an expected call of GetDataRequestForUpdate.
+func (mr *MockStoreMockRecorder) GetDataRequestForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataRequestForUpdate", reflect.TypeOf((*MockStore)(nil).GetDataRequestForUpdate), ctx, id)
+}
+
+// GetEntry mocks base method.
+func (m *MockStore) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEntry", ctx, id)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEntry indicates an expected call of GetEntry.
+func (mr *MockStoreMockRecorder) GetEntry(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntry", reflect.TypeOf((*MockStore)(nil).GetEntry), ctx, id)
+}
+
+// GetInterestPosting mocks base method.
+func (m *MockStore) GetInterestPosting(ctx context.Context, arg db.GetInterestPostingParams) (db.InterestPosting, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInterestPosting", ctx, arg)
+	ret0, _ := ret[0].(db.InterestPosting)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInterestPosting indicates an expected call of GetInterestPosting.
+func (mr *MockStoreMockRecorder) GetInterestPosting(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInterestPosting", reflect.TypeOf((*MockStore)(nil).GetInterestPosting), ctx, arg)
+}
+
+// GetInterestProduct mocks base method.
+func (m *MockStore) GetInterestProduct(ctx context.Context, arg db.GetInterestProductParams) (db.InterestProduct, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInterestProduct", ctx, arg)
+	ret0, _ := ret[0].(db.InterestProduct)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInterestProduct indicates an expected call of GetInterestProduct.
+func (mr *MockStoreMockRecorder) GetInterestProduct(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInterestProduct", reflect.TypeOf((*MockStore)(nil).GetInterestProduct), ctx, arg)
+}
+
+// GetLatestEntryIDForAccount mocks base method.
+func (m *MockStore) GetLatestEntryIDForAccount(ctx context.Context, accountID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestEntryIDForAccount", ctx, accountID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestEntryIDForAccount indicates an expected call of GetLatestEntryIDForAccount.
+func (mr *MockStoreMockRecorder) GetLatestEntryIDForAccount(ctx, accountID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestEntryIDForAccount", reflect.TypeOf((*MockStore)(nil).GetLatestEntryIDForAccount), ctx, accountID)
+}
+
+// GetLatestInterestAccrualDate mocks base method.
+func (m *MockStore) GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestInterestAccrualDate", ctx)
+	ret0, _ := ret[0].(pgtype.Date)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestInterestAccrualDate indicates an expected call of GetLatestInterestAccrualDate.
+func (mr *MockStoreMockRecorder) GetLatestInterestAccrualDate(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestInterestAccrualDate", reflect.TypeOf((*MockStore)(nil).GetLatestInterestAccrualDate), ctx)
+}
+
+// GetLoginFailure mocks base method.
+func (m *MockStore) GetLoginFailure(ctx context.Context, arg db.GetLoginFailureParams) (db.LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginFailure", ctx, arg)
+	ret0, _ := ret[0].(db.LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginFailure indicates an expected call of GetLoginFailure.
+func (mr *MockStoreMockRecorder) GetLoginFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginFailure", reflect.TypeOf((*MockStore)(nil).GetLoginFailure), ctx, arg)
+}
+
+// GetOverdraftCharge mocks base method.
+func (m *MockStore) GetOverdraftCharge(ctx context.Context, arg db.GetOverdraftChargeParams) (db.OverdraftCharge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOverdraftCharge", ctx, arg)
+	ret0, _ := ret[0].(db.OverdraftCharge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOverdraftCharge indicates an expected call of GetOverdraftCharge.
+func (mr *MockStoreMockRecorder) GetOverdraftCharge(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOverdraftCharge", reflect.TypeOf((*MockStore)(nil).GetOverdraftCharge), ctx, arg)
+}
+
+// GetPasswordResetToken mocks base method.
+func (m *MockStore) GetPasswordResetToken(ctx context.Context, tokenHash string) (db.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordResetToken", ctx, tokenHash)
+	ret0, _ := ret[0].(db.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordResetToken indicates an expected call of GetPasswordResetToken.
+func (mr *MockStoreMockRecorder) GetPasswordResetToken(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordResetToken", reflect.TypeOf((*MockStore)(nil).GetPasswordResetToken), ctx, tokenHash)
+}
+
+// GetTransfer mocks base method.
+func (m *MockStore) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfer", ctx, id)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfer indicates an expected call of GetTransfer.
+func (mr *MockStoreMockRecorder) GetTransfer(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockStore)(nil).GetTransfer), ctx, id)
+}
+
+// GetTransferFromAccount mocks base method.
+func (m *MockStore) GetTransferFromAccount(ctx context.Context, arg db.GetTransferFromAccountParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferFromAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferFromAccount indicates an expected call of GetTransferFromAccount.
+func (mr *MockStoreMockRecorder) GetTransferFromAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferFromAccount", reflect.TypeOf((*MockStore)(nil).GetTransferFromAccount), ctx, arg)
+}
+
+// GetTransferFromAndToAccount mocks base method.
+func (m *MockStore) GetTransferFromAndToAccount(ctx context.Context, arg db.GetTransferFromAndToAccountParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferFromAndToAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferFromAndToAccount indicates an expected call of GetTransferFromAndToAccount.
+func (mr *MockStoreMockRecorder) GetTransferFromAndToAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferFromAndToAccount", reflect.TypeOf((*MockStore)(nil).GetTransferFromAndToAccount), ctx, arg)
+}
+
+// GetTransferToAccount mocks base method.
+func (m *MockStore) GetTransferToAccount(ctx context.Context, arg db.GetTransferToAccountParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferToAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferToAccount indicates an expected call of GetTransferToAccount.
+func (mr *MockStoreMockRecorder) GetTransferToAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferToAccount", reflect.TypeOf((*MockStore)(nil).GetTransferToAccount), ctx, arg)
+}
+
+// GetUser mocks base method.
+func (m *MockStore) GetUser(ctx context.Context, username string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, username)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStoreMockRecorder) GetUser(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), ctx, username)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockStore) GetUserByEmail(ctx context.Context, arg db.GetUserByEmailParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockStoreMockRecorder) GetUserByEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), ctx, arg)
+}
+
+// GetUserTokensValidFrom mocks base method.
+func (m *MockStore) GetUserTokensValidFrom(ctx context.Context, username string) (pgtype.Timestamptz, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTokensValidFrom", ctx, username)
+	ret0, _ := ret[0].(pgtype.Timestamptz)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTokensValidFrom indicates an expected call of GetUserTokensValidFrom.
+func (mr *MockStoreMockRecorder) GetUserTokensValidFrom(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTokensValidFrom", reflect.TypeOf((*MockStore)(nil).GetUserTokensValidFrom), ctx, username)
+}
+
+// GetVerifyEmail mocks base method.
+func (m *MockStore) GetVerifyEmail(ctx context.Context, id int64) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVerifyEmail", ctx, id)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVerifyEmail indicates an expected call of GetVerifyEmail.
+func (mr *MockStoreMockRecorder) GetVerifyEmail(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVerifyEmail", reflect.TypeOf((*MockStore)(nil).GetVerifyEmail), ctx, id)
+}
+
+// GetWebhookDelivery mocks base method.
+func (m *MockStore) GetWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDelivery", ctx, id)
+	ret0, _ := ret[0].(db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDelivery indicates an expected call of GetWebhookDelivery.
+func (mr *MockStoreMockRecorder) GetWebhookDelivery(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDelivery", reflect.TypeOf((*MockStore)(nil).GetWebhookDelivery), ctx, id)
+}
+
+// GetWebhookEvent mocks base method.
+func (m *MockStore) GetWebhookEvent(ctx context.Context, id int64) (db.WebhookEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookEvent", ctx, id)
+	ret0, _ := ret[0].(db.WebhookEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookEvent indicates an expected call of GetWebhookEvent.
+func (mr *MockStoreMockRecorder) GetWebhookEvent(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookEvent", reflect.TypeOf((*MockStore)(nil).GetWebhookEvent), ctx, id)
+}
+
+// GetWebhookSubscription mocks base method.
+func (m *MockStore) GetWebhookSubscription(ctx context.Context, id int64) (db.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookSubscription", ctx, id)
+	ret0, _ := ret[0].(db.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookSubscription indicates an expected call of GetWebhookSubscription.
+func (mr *MockStoreMockRecorder) GetWebhookSubscription(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookSubscription", reflect.TypeOf((*MockStore)(nil).GetWebhookSubscription), ctx, id)
+}
+
+// IndexUserEmail mocks base method.
+func (m *MockStore) IndexUserEmail(ctx context.Context, arg db.IndexUserEmailParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IndexUserEmail", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IndexUserEmail indicates an expected call of IndexUserEmail.
+func (mr *MockStoreMockRecorder) IndexUserEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IndexUserEmail", reflect.TypeOf((*MockStore)(nil).IndexUserEmail), ctx, arg)
+}
+
+// ListAccounts mocks base method.
+func (m *MockStore) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccounts", ctx, arg)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccounts indicates an expected call of ListAccounts.
+func (mr *MockStoreMockRecorder) ListAccounts(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockStore)(nil).ListAccounts), ctx, arg)
+}
+
+// ListAccountsByOwner mocks base method.
+func (m *MockStore) ListAccountsByOwner(ctx context.Context, arg db.ListAccountsByOwnerParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountsByOwner", ctx, arg)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountsByOwner indicates an expected call of ListAccountsByOwner.
+func (mr *MockStoreMockRecorder) ListAccountsByOwner(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountsByOwner", reflect.TypeOf((*MockStore)(nil).ListAccountsByOwner), ctx, arg)
+}
+
+// ListApiKeys mocks base method.
+func (m *MockStore) ListApiKeys(ctx context.Context, username string) ([]db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListApiKeys", ctx, username)
+	ret0, _ := ret[0].([]db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListApiKeys indicates an expected call of ListApiKeys.
+func (mr *MockStoreMockRecorder) ListApiKeys(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListApiKeys", reflect.TypeOf((*MockStore)(nil).ListApiKeys), ctx, username)
+}
+
+// ListAuditLog mocks base method.
+func (m *MockStore) ListAuditLog(ctx context.Context, arg db.ListAuditLogParams) ([]db.AuditLog, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditLog", ctx, arg)
+	ret0, _ := ret[0].([]db.AuditLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditLog indicates an expected call of ListAuditLog.
+func (mr *MockStoreMockRecorder) ListAuditLog(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditLog", reflect.TypeOf((*MockStore)(nil).ListAuditLog), ctx, arg)
+}
+
+// ListDataRequests mocks base method.
+func (m *MockStore) ListDataRequests(ctx context.Context, arg db.ListDataRequestsParams) ([]db.DataRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDataRequests", ctx, arg)
+	ret0, _ := ret[0].([]db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDataRequests indicates an expected call of ListDataRequests.
+func (mr *MockStoreMockRecorder) ListDataRequests(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDataRequests", reflect.TypeOf((*MockStore)(nil).ListDataRequests), ctx, arg)
+}
+
+// ListDataRequestsByUser mocks base method.
+func (m *MockStore) ListDataRequestsByUser(ctx context.Context, username string) ([]db.DataRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDataRequestsByUser", ctx, username)
+	ret0, _ := ret[0].([]db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDataRequestsByUser indicates an expected call of ListDataRequestsByUser.
+func (mr *MockStoreMockRecorder) ListDataRequestsByUser(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDataRequestsByUser", reflect.TypeOf((*MockStore)(nil).ListDataRequestsByUser), ctx, username)
+}
+
+// ListEntries mocks base method.
+func (m *MockStore) ListEntries(ctx context.Context, arg db.ListEntriesParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntries", ctx, arg)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntries indicates an expected call of ListEntries.
+func (mr *MockStoreMockRecorder) ListEntries(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockStore)(nil).ListEntries), ctx, arg)
+}
+
+// ListEntriesForAccount mocks base method.
+func (m *MockStore) ListEntriesForAccount(ctx context.Context, arg db.ListEntriesForAccountParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntriesForAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntriesForAccount indicates an expected call of ListEntriesForAccount.
+func (mr *MockStoreMockRecorder) ListEntriesForAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntriesForAccount", reflect.TypeOf((*MockStore)(nil).ListEntriesForAccount), ctx, arg)
+}
+
+// ListEntriesForAccountAfter mocks base method.
+func (m *MockStore) ListEntriesForAccountAfter(ctx context.Context, arg db.ListEntriesForAccountAfterParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntriesForAccountAfter", ctx, arg)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntriesForAccountAfter indicates an expected call of ListEntriesForAccountAfter.
+func (mr *MockStoreMockRecorder) ListEntriesForAccountAfter(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntriesForAccountAfter", reflect.TypeOf((*MockStore)(nil).ListEntriesForAccountAfter), ctx, arg)
+}
+
+// ListInterestAccrualsForAccount mocks base method.
+func (m *MockStore) ListInterestAccrualsForAccount(ctx context.Context, arg db.ListInterestAccrualsForAccountParams) ([]db.InterestAccrual, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInterestAccrualsForAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.InterestAccrual)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInterestAccrualsForAccount indicates an expected call of ListInterestAccrualsForAccount.
+func (mr *MockStoreMockRecorder) ListInterestAccrualsForAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInterestAccrualsForAccount", reflect.TypeOf((*MockStore)(nil).ListInterestAccrualsForAccount), ctx, arg)
+}
+
+// ListInterestBearingAccounts mocks base method.
+func (m *MockStore) ListInterestBearingAccounts(ctx context.Context) ([]db.ListInterestBearingAccountsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInterestBearingAccounts", ctx)
+	ret0, _ := ret[0].([]db.ListInterestBearingAccountsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInterestBearingAccounts indicates an expected call of ListInterestBearingAccounts.
+func (mr *MockStoreMockRecorder) ListInterestBearingAccounts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInterestBearingAccounts", reflect.TypeOf((*MockStore)(nil).ListInterestBearingAccounts), ctx)
+}
+
+// ListInterestProducts mocks base method.
+func (m *MockStore) ListInterestProducts(ctx context.Context) ([]db.InterestProduct, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInterestProducts", ctx)
+	ret0, _ := ret[0].([]db.InterestProduct)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInterestProducts indicates an expected call of ListInterestProducts.
+func (mr *MockStoreMockRecorder) ListInterestProducts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInterestProducts", reflect.TypeOf((*MockStore)(nil).ListInterestProducts), ctx)
+}
+
+// ListOverdraftChargesForAccount mocks base method.
+func (m *MockStore) ListOverdraftChargesForAccount(ctx context.Context, arg db.ListOverdraftChargesForAccountParams) ([]db.OverdraftCharge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOverdraftChargesForAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.OverdraftCharge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOverdraftChargesForAccount indicates an expected call of ListOverdraftChargesForAccount.
+func (mr *MockStoreMockRecorder) ListOverdraftChargesForAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOverdraftChargesForAccount", reflect.TypeOf((*MockStore)(nil).ListOverdraftChargesForAccount), ctx, arg)
+}
+
+// ListOverdrawnAccounts mocks base method.
+func (m *MockStore) ListOverdrawnAccounts(ctx context.Context) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOverdrawnAccounts", ctx)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOverdrawnAccounts indicates an expected call of ListOverdrawnAccounts.
+func (mr *MockStoreMockRecorder) ListOverdrawnAccounts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOverdrawnAccounts", reflect.TypeOf((*MockStore)(nil).ListOverdrawnAccounts), ctx)
+}
+
+// ListPasswordHistory mocks base method.
+func (m *MockStore) ListPasswordHistory(ctx context.Context, arg db.ListPasswordHistoryParams) ([]db.PasswordHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPasswordHistory", ctx, arg)
+	ret0, _ := ret[0].([]db.PasswordHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPasswordHistory indicates an expected call of ListPasswordHistory.
+func (mr *MockStoreMockRecorder) ListPasswordHistory(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPasswordHistory", reflect.TypeOf((*MockStore)(nil).ListPasswordHistory), ctx, arg)
+}
+
+// ListTransfers mocks base method.
+func (m *MockStore) ListTransfers(ctx context.Context, arg db.ListTransfersParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransfers", ctx, arg)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransfers indicates an expected call of ListTransfers.
+func (mr *MockStoreMockRecorder) ListTransfers(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockStore)(nil).ListTransfers), ctx, arg)
+}
+
+// ListTransfersByOwner mocks base method.
+func (m *MockStore) ListTransfersByOwner(ctx context.Context, arg db.ListTransfersByOwnerParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransfersByOwner", ctx, arg)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransfersByOwner indicates an expected call of ListTransfersByOwner.
+func (mr *MockStoreMockRecorder) ListTransfersByOwner(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfersByOwner", reflect.TypeOf((*MockStore)(nil).ListTransfersByOwner), ctx, arg)
+}
+
+// ListUnpostedInterestPeriods mocks base method.
+func (m *MockStore) ListUnpostedInterestPeriods(ctx context.Context, before pgtype.Date) ([]db.ListUnpostedInterestPeriodsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnpostedInterestPeriods", ctx, before)
+	ret0, _ := ret[0].([]db.ListUnpostedInterestPeriodsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnpostedInterestPeriods indicates an expected call of ListUnpostedInterestPeriods.
+func (mr *MockStoreMockRecorder) ListUnpostedInterestPeriods(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnpostedInterestPeriods", reflect.TypeOf((*MockStore)(nil).ListUnpostedInterestPeriods), ctx, before)
+}
+
+// ListUsersToIndex mocks base method.
+func (m *MockStore) ListUsersToIndex(ctx context.Context, arg db.ListUsersToIndexParams) ([]db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersToIndex", ctx, arg)
+	ret0, _ := ret[0].([]db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersToIndex indicates an expected call of ListUsersToIndex.
+func (mr *MockStoreMockRecorder) ListUsersToIndex(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersToIndex", reflect.TypeOf((*MockStore)(nil).ListUsersToIndex), ctx, arg)
+}
+
+// ListUsersToRekey mocks base method.
+func (m *MockStore) ListUsersToRekey(ctx context.Context, arg db.ListUsersToRekeyParams) ([]db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersToRekey", ctx, arg)
+	ret0, _ := ret[0].([]db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersToRekey indicates an expected call of ListUsersToRekey.
+func (mr *MockStoreMockRecorder) ListUsersToRekey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersToRekey", reflect.TypeOf((*MockStore)(nil).ListUsersToRekey), ctx, arg)
+}
+
+// ListVerifyEmailsToRekey mocks base method.
+func (m *MockStore) ListVerifyEmailsToRekey(ctx context.Context, arg db.ListVerifyEmailsToRekeyParams) ([]db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVerifyEmailsToRekey", ctx, arg)
+	ret0, _ := ret[0].([]db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVerifyEmailsToRekey indicates an expected call of ListVerifyEmailsToRekey.
+func (mr *MockStoreMockRecorder) ListVerifyEmailsToRekey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVerifyEmailsToRekey", reflect.TypeOf((*MockStore)(nil).ListVerifyEmailsToRekey), ctx, arg)
+}
+
+// ListWebhookDeliveries mocks base method.
+func (m *MockStore) ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", ctx, arg)
+	ret0, _ := ret[0].([]db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockStoreMockRecorder) ListWebhookDeliveries(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockStore)(nil).ListWebhookDeliveries), ctx, arg)
+}
+
+// ListWebhookSubscriptions mocks base method.
+func (m *MockStore) ListWebhookSubscriptions(ctx context.Context, username string) ([]db.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookSubscriptions", ctx, username)
+	ret0, _ := ret[0].([]db.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookSubscriptions indicates an expected call of ListWebhookSubscriptions.
+func (mr *MockStoreMockRecorder) ListWebhookSubscriptions(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookSubscriptions", reflect.TypeOf((*MockStore)(nil).ListWebhookSubscriptions), ctx, username)
+}
+
+// LockLogin mocks base method.
+func (m *MockStore) LockLogin(ctx context.Context, arg db.LockLoginParams) (db.LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", ctx, arg)
+	ret0, _ := ret[0].(db.LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockStoreMockRecorder) LockLogin(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockStore)(nil).LockLogin), ctx, arg)
+}
+
+// MarkInterestAccrualsPosted mocks base method.
+func (m *MockStore) MarkInterestAccrualsPosted(ctx context.Context, arg db.MarkInterestAccrualsPostedParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkInterestAccrualsPosted", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkInterestAccrualsPosted indicates an expected call of MarkInterestAccrualsPosted.
+func (mr *MockStoreMockRecorder) MarkInterestAccrualsPosted(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkInterestAccrualsPosted", reflect.TypeOf((*MockStore)(nil).MarkInterestAccrualsPosted), ctx, arg)
+}
+
+// MarkWebhookDeliveryFailed mocks base method.
+func (m *MockStore) MarkWebhookDeliveryFailed(ctx context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkWebhookDeliveryFailed", ctx, arg)
+	ret0, _ := ret[0].(db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkWebhookDeliveryFailed indicates an expected call of MarkWebhookDeliveryFailed.
+func (mr *MockStoreMockRecorder) MarkWebhookDeliveryFailed(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkWebhookDeliveryFailed", reflect.TypeOf((*MockStore)(nil).MarkWebhookDeliveryFailed), ctx, arg)
+}
+
+// MarkWebhookDeliverySucceeded mocks base method.
+func (m *MockStore) MarkWebhookDeliverySucceeded(ctx context.Context, arg db.MarkWebhookDeliverySucceededParams) (db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkWebhookDeliverySucceeded", ctx, arg)
+	ret0, _ := ret[0].(db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkWebhookDeliverySucceeded indicates an expected call of MarkWebhookDeliverySucceeded.
+func (mr *MockStoreMockRecorder) MarkWebhookDeliverySucceeded(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkWebhookDeliverySucceeded", reflect.TypeOf((*MockStore)(nil).MarkWebhookDeliverySucceeded), ctx, arg)
+}
+
+// NotifyAccountEvent mocks base method.
+func (m *MockStore) NotifyAccountEvent(ctx context.Context, arg db.NotifyAccountEventParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyAccountEvent", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyAccountEvent indicates an expected call of NotifyAccountEvent.
+func (mr *MockStoreMockRecorder) NotifyAccountEvent(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyAccountEvent", reflect.TypeOf((*MockStore)(nil).NotifyAccountEvent), ctx, arg)
+}
+
+// Ping mocks base method.
+func (m *MockStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStoreMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx)
+}
+
+// PostInterestTx mocks base method.
+func (m *MockStore) PostInterestTx(ctx context.Context, arg db.PostInterestTxParams) (db.PostInterestTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostInterestTx", ctx, arg)
+	ret0, _ := ret[0].(db.PostInterestTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PostInterestTx indicates an expected call of PostInterestTx.
+func (mr *MockStoreMockRecorder) PostInterestTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostInterestTx", reflect.TypeOf((*MockStore)(nil).PostInterestTx), ctx, arg)
+}
+
+// ReconcileAccounts mocks base method.
+func (m *MockStore) ReconcileAccounts(ctx context.Context) ([]db.ReconcileAccountsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileAccounts", ctx)
+	ret0, _ := ret[0].([]db.ReconcileAccountsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileAccounts indicates an expected call of ReconcileAccounts.
+func (mr *MockStoreMockRecorder) ReconcileAccounts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileAccounts", reflect.TypeOf((*MockStore)(nil).ReconcileAccounts), ctx)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockStore) RecordLoginFailure(ctx context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, arg)
+	ret0, _ := ret[0].(db.LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockStoreMockRecorder) RecordLoginFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockStore)(nil).RecordLoginFailure), ctx, arg)
+}
+
+// RekeyUser mocks base method.
+func (m *MockStore) RekeyUser(ctx context.Context, arg db.RekeyUserParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RekeyUser", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RekeyUser indicates an expected call of RekeyUser.
+func (mr *MockStoreMockRecorder) RekeyUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RekeyUser", reflect.TypeOf((*MockStore)(nil).RekeyUser), ctx, arg)
+}
+
+// RekeyVerifyEmail mocks base method.
+func (m *MockStore) RekeyVerifyEmail(ctx context.Context, arg db.RekeyVerifyEmailParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RekeyVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RekeyVerifyEmail indicates an expected call of RekeyVerifyEmail.
+func (mr *MockStoreMockRecorder) RekeyVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RekeyVerifyEmail", reflect.TypeOf((*MockStore)(nil).RekeyVerifyEmail), ctx, arg)
+}
+
+// ReplayWebhookDelivery mocks base method.
+func (m *MockStore) ReplayWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayWebhookDelivery", ctx, id)
+	ret0, _ := ret[0].(db.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayWebhookDelivery indicates an expected call of ReplayWebhookDelivery.
+func (mr *MockStoreMockRecorder) ReplayWebhookDelivery(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayWebhookDelivery", reflect.TypeOf((*MockStore)(nil).ReplayWebhookDelivery), ctx, id)
+}
+
+// RequestErasureTx mocks base method.
+func (m *MockStore) RequestErasureTx(ctx context.Context, arg db.RequestErasureTxParams) (db.DataRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestErasureTx", ctx, arg)
+	ret0, _ := ret[0].(db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestErasureTx indicates an expected call of RequestErasureTx.
+func (mr *MockStoreMockRecorder) RequestErasureTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestErasureTx", reflect.TypeOf((*MockStore)(nil).RequestErasureTx), ctx, arg)
+}
+
+// ReverseTransferTx mocks base method.
+func (m *MockStore) ReverseTransferTx(ctx context.Context, transferID int64) (db.TransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransferTx", ctx, transferID)
+	ret0, _ := ret[0].(db.TransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransferTx indicates an expected call of ReverseTransferTx.
+func (mr *MockStoreMockRecorder) ReverseTransferTx(ctx, transferID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransferTx", reflect.TypeOf((*MockStore)(nil).ReverseTransferTx), ctx, transferID)
+}
+
+// ReviewDataRequest mocks base method.
+func (m *MockStore) ReviewDataRequest(ctx context.Context, arg db.ReviewDataRequestParams) (db.DataRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewDataRequest", ctx, arg)
+	ret0, _ := ret[0].(db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewDataRequest indicates an expected call of ReviewDataRequest.
+func (mr *MockStoreMockRecorder) ReviewDataRequest(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewDataRequest", reflect.TypeOf((*MockStore)(nil).ReviewDataRequest), ctx, arg)
+}
+
+// ReviewDataRequestTx mocks base method.
+func (m *MockStore) ReviewDataRequestTx(ctx context.Context, arg db.ReviewDataRequestTxParams) (db.DataRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewDataRequestTx", ctx, arg)
+	ret0, _ := ret[0].(db.DataRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewDataRequestTx indicates an expected call of ReviewDataRequestTx.
+func (mr *MockStoreMockRecorder) ReviewDataRequestTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewDataRequestTx", reflect.TypeOf((*MockStore)(nil).ReviewDataRequestTx), ctx, arg)
+}
+
+// RevokeApiKey mocks base method.
+func (m *MockStore) RevokeApiKey(ctx context.Context, arg db.RevokeApiKeyParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKey", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeApiKey indicates an expected call of RevokeApiKey.
+func (mr *MockStoreMockRecorder) RevokeApiKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKey", reflect.TypeOf((*MockStore)(nil).RevokeApiKey), ctx, arg)
+}
+
+// RevokeApiKeyTx mocks base method.
+func (m *MockStore) RevokeApiKeyTx(ctx context.Context, arg db.RevokeApiKeyParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKeyTx", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeApiKeyTx indicates an expected call of RevokeApiKeyTx.
+func (mr *MockStoreMockRecorder) RevokeApiKeyTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKeyTx", reflect.TypeOf((*MockStore)(nil).RevokeApiKeyTx), ctx, arg)
+}
+
+// RevokeApiKeys mocks base method.
+func (m *MockStore) RevokeApiKeys(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKeys", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeApiKeys indicates an expected call of RevokeApiKeys.
+func (mr *MockStoreMockRecorder) RevokeApiKeys(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKeys", reflect.TypeOf((*MockStore)(nil).RevokeApiKeys), ctx, username)
+}
+
+// SetAccountFrozen mocks base method.
+func (m *MockStore) SetAccountFrozen(ctx context.Context, arg db.SetAccountFrozenParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountFrozen", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAccountFrozen indicates an expected call of SetAccountFrozen.
+func (mr *MockStoreMockRecorder) SetAccountFrozen(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountFrozen", reflect.TypeOf((*MockStore)(nil).SetAccountFrozen), ctx, arg)
+}
+
+// SetTransferReversalOf mocks base method.
+func (m *MockStore) SetTransferReversalOf(ctx context.Context, arg db.SetTransferReversalOfParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTransferReversalOf", ctx, arg)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTransferReversalOf indicates an expected call of SetTransferReversalOf.
+func (mr *MockStoreMockRecorder) SetTransferReversalOf(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTransferReversalOf", reflect.TypeOf((*MockStore)(nil).SetTransferReversalOf), ctx, arg)
+}
+
+// SetUserEmailVerified mocks base method.
+func (m *MockStore) SetUserEmailVerified(ctx context.Context, arg db.SetUserEmailVerifiedParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserEmailVerified", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserEmailVerified indicates an expected call of SetUserEmailVerified.
+func (mr *MockStoreMockRecorder) SetUserEmailVerified(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserEmailVerified", reflect.TypeOf((*MockStore)(nil).SetUserEmailVerified), ctx, arg)
+}
+
+// SetUserTOTPSecret mocks base method.
+func (m *MockStore) SetUserTOTPSecret(ctx context.Context, arg db.SetUserTOTPSecretParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserTOTPSecret", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserTOTPSecret indicates an expected call of SetUserTOTPSecret.
+func (mr *MockStoreMockRecorder) SetUserTOTPSecret(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserTOTPSecret", reflect.TypeOf((*MockStore)(nil).SetUserTOTPSecret), ctx, arg)
+}
+
+// SubtractAccountBalance mocks base method.
+func (m *MockStore) SubtractAccountBalance(ctx context.Context, arg db.SubtractAccountBalanceParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubtractAccountBalance", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SubtractAccountBalance indicates an expected call of SubtractAccountBalance.
+func (mr *MockStoreMockRecorder) SubtractAccountBalance(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubtractAccountBalance", reflect.TypeOf((*MockStore)(nil).SubtractAccountBalance), ctx, arg)
+}
+
+// SumUnpostedInterestAccruals mocks base method.
+func (m *MockStore) SumUnpostedInterestAccruals(ctx context.Context, arg db.SumUnpostedInterestAccrualsParams) (pgtype.Numeric, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumUnpostedInterestAccruals", ctx, arg)
+	ret0, _ := ret[0].(pgtype.Numeric)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumUnpostedInterestAccruals indicates an expected call of SumUnpostedInterestAccruals.
+func (mr *MockStoreMockRecorder) SumUnpostedInterestAccruals(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumUnpostedInterestAccruals", reflect.TypeOf((*MockStore)(nil).SumUnpostedInterestAccruals), ctx, arg)
+}
+
+// TouchApiKey mocks base method.
+func (m *MockStore) TouchApiKey(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchApiKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchApiKey indicates an expected call of TouchApiKey.
+func (mr *MockStoreMockRecorder) TouchApiKey(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchApiKey", reflect.TypeOf((*MockStore)(nil).TouchApiKey), ctx, id)
+}
+
+// TransferTx mocks base method.
+func (m *MockStore) TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferTx", ctx, arg)
+	ret0, _ := ret[0].(db.TransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferTx indicates an expected call of TransferTx.
+func (mr *MockStoreMockRecorder) TransferTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), ctx, arg)
+}
+
+// UnlockLoginTx mocks base method.
+func (m *MockStore) UnlockLoginTx(ctx context.Context, username string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockLoginTx", ctx, username)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnlockLoginTx indicates an expected call of UnlockLoginTx.
+func (mr *MockStoreMockRecorder) UnlockLoginTx(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockLoginTx", reflect.TypeOf((*MockStore)(nil).UnlockLoginTx), ctx, username)
+}
+
+// UpdateAccountBalance mocks base method.
+func (m *MockStore) UpdateAccountBalance(ctx context.Context, arg db.UpdateAccountBalanceParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountBalance", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAccountBalance indicates an expected call of UpdateAccountBalance.
+func (mr *MockStoreMockRecorder) UpdateAccountBalance(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountBalance", reflect.TypeOf((*MockStore)(nil).UpdateAccountBalance), ctx, arg)
+}
+
+// UpdateAccountOverdraftLimit mocks base method.
+func (m *MockStore) UpdateAccountOverdraftLimit(ctx context.Context, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountOverdraftLimit", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountOverdraftLimit indicates an expected call of UpdateAccountOverdraftLimit.
+func (mr *MockStoreMockRecorder) UpdateAccountOverdraftLimit(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountOverdraftLimit", reflect.TypeOf((*MockStore)(nil).UpdateAccountOverdraftLimit), ctx, arg)
+}
+
+// UpdateEntryAmount mocks base method.
+func (m *MockStore) UpdateEntryAmount(ctx context.Context, arg db.UpdateEntryAmountParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEntryAmount", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEntryAmount indicates an expected call of UpdateEntryAmount.
+func (mr *MockStoreMockRecorder) UpdateEntryAmount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEntryAmount", reflect.TypeOf((*MockStore)(nil).UpdateEntryAmount), ctx, arg)
+}
+
+// UpdateOverdraftLimitTx mocks base method.
+func (m *MockStore) UpdateOverdraftLimitTx(ctx context.Context, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOverdraftLimitTx", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOverdraftLimitTx indicates an expected call of UpdateOverdraftLimitTx.
+func (mr *MockStoreMockRecorder) UpdateOverdraftLimitTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOverdraftLimitTx", reflect.TypeOf((*MockStore)(nil).UpdateOverdraftLimitTx), ctx, arg)
+}
+
+// UpdateTransferAmount mocks base method.
+func (m *MockStore) UpdateTransferAmount(ctx context.Context, arg db.UpdateTransferAmountParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTransferAmount", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTransferAmount indicates an expected call of UpdateTransferAmount.
+func (mr *MockStoreMockRecorder) UpdateTransferAmount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransferAmount", reflect.TypeOf((*MockStore)(nil).UpdateTransferAmount), ctx, arg)
+}
+
+// UpdateUser mocks base method.
+func (m *MockStore) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockStoreMockRecorder) UpdateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), ctx, arg)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockStore) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockStoreMockRecorder) UpdateUserPassword(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), ctx, arg)
+}
+
+// UpdateUserRole mocks base method.
+func (m *MockStore) UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRole", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRole indicates an expected call of UpdateUserRole.
+func (mr *MockStoreMockRecorder) UpdateUserRole(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRole", reflect.TypeOf((*MockStore)(nil).UpdateUserRole), ctx, arg)
+}
+
+// UpdateUserRoleTx mocks base method.
+func (m *MockStore) UpdateUserRoleTx(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserRoleTx", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserRoleTx indicates an expected call of UpdateUserRoleTx.
+func (mr *MockStoreMockRecorder) UpdateUserRoleTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserRoleTx", reflect.TypeOf((*MockStore)(nil).UpdateUserRoleTx), ctx, arg)
+}
+
+// UpdateUserTx mocks base method.
+func (m *MockStore) UpdateUserTx(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserTx indicates an expected call of UpdateUserTx.
+func (mr *MockStoreMockRecorder) UpdateUserTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTx", reflect.TypeOf((*MockStore)(nil).UpdateUserTx), ctx, arg)
+}
+
+// UpsertInterestProduct mocks base method.
+func (m *MockStore) UpsertInterestProduct(ctx context.Context, arg db.UpsertInterestProductParams) (db.InterestProduct, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertInterestProduct", ctx, arg)
+	ret0, _ := ret[0].(db.InterestProduct)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertInterestProduct indicates an expected call of UpsertInterestProduct.
+func (mr *MockStoreMockRecorder) UpsertInterestProduct(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertInterestProduct", reflect.TypeOf((*MockStore)(nil).UpsertInterestProduct), ctx, arg)
+}
+
+// UpsertInterestProductTx mocks base method.
+func (m *MockStore) UpsertInterestProductTx(ctx context.Context, arg db.UpsertInterestProductParams) (db.InterestProduct, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertInterestProductTx", ctx, arg)
+	ret0, _ := ret[0].(db.InterestProduct)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertInterestProductTx indicates an expected call of UpsertInterestProductTx.
+func (mr *MockStoreMockRecorder) UpsertInterestProductTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertInterestProductTx", reflect.TypeOf((*MockStore)(nil).UpsertInterestProductTx), ctx, arg)
+}
+
+// UsePasswordResetToken mocks base method.
+func (m *MockStore) UsePasswordResetToken(ctx context.Context, arg db.UsePasswordResetTokenParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordResetToken", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordResetToken indicates an expected call of UsePasswordResetToken.
+func (mr *MockStoreMockRecorder) UsePasswordResetToken(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordResetToken", reflect.TypeOf((*MockStore)(nil).UsePasswordResetToken), ctx, arg)
+}
+
+// UseRequestNonce mocks base method.
+func (m *MockStore) UseRequestNonce(ctx context.Context, arg db.UseRequestNonceParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRequestNonce", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRequestNonce indicates an expected call of UseRequestNonce.
+func (mr *MockStoreMockRecorder) UseRequestNonce(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRequestNonce", reflect.TypeOf((*MockStore)(nil).UseRequestNonce), ctx, arg)
+}
+
+// UseTOTPBackupCode mocks base method.
+func (m *MockStore) UseTOTPBackupCode(ctx context.Context, arg db.UseTOTPBackupCodeParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPBackupCode", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPBackupCode indicates an expected call of UseTOTPBackupCode.
+func (mr *MockStoreMockRecorder) UseTOTPBackupCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPBackupCode", reflect.TypeOf((*MockStore)(nil).UseTOTPBackupCode), ctx, arg)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockStore) UseTOTPStep(ctx context.Context, arg db.UseTOTPStepParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockStoreMockRecorder) UseTOTPStep(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStore)(nil).UseTOTPStep), ctx, arg)
+}
+
+// UseVerifyEmail mocks base method.
+func (m *MockStore) UseVerifyEmail(ctx context.Context, id int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseVerifyEmail", ctx, id)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseVerifyEmail indicates an expected call of UseVerifyEmail.
+func (mr *MockStoreMockRecorder) UseVerifyEmail(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseVerifyEmail", reflect.TypeOf((*MockStore)(nil).UseVerifyEmail), ctx, id)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockStore) VerifyEmailTx(ctx context.Context, arg db.VerifyEmailTxParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockStoreMockRecorder) VerifyEmailTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockStore)(nil).VerifyEmailTx), ctx, arg)
 }
@@ -1,8 +1,8 @@
 -- name: CreateAccount :one
 INSERT INTO accounts (
-  owner, balance, currency
+  owner, balance, currency, account_type
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4
 )
 RETURNING *;
 
@@ -11,6 +11,11 @@ SELECT * FROM accounts
 WHERE id = $1
 LIMIT 1;
 
+-- name: GetAccountByOwnerCurrencyAndType :one
+SELECT * FROM accounts
+WHERE owner = $1 AND currency = $2 AND account_type = $3
+LIMIT 1;
+
 -- name: GetAccountForUpdate :one
 SELECT * FROM accounts
 WHERE id = $1
@@ -24,17 +29,50 @@ ORDER BY id
 LIMIT $1 
 OFFSET $2;
 
+-- name: ListAccountsByOwner :many
+SELECT * FROM accounts
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
 -- name: UpdateAccountBalance :exec
 UPDATE accounts SET balance = $2 WHERE id = $1 RETURNING *;
 
 
--- name: AddAccountBalance :exec
+-- name: AddAccountBalance :one
 UPDATE accounts SET balance = balance + sqlc.arg(amount) WHERE id = sqlc.arg(id) RETURNING *;
 
 -- name: SubtractAccountBalance :exec
 UPDATE accounts SET balance = balance - sqlc.arg(amount) WHERE id = sqlc.arg(id) RETURNING *;
 
+-- name: DebitAccountBalance :one
+UPDATE accounts SET balance = balance - sqlc.arg(amount)
+WHERE id = sqlc.arg(id) AND balance - sqlc.arg(amount) >= -overdraft_limit
+RETURNING *;
+
+-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts SET overdraft_limit = sqlc.arg(overdraft_limit) WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: ListOverdrawnAccounts :many
+SELECT * FROM accounts
+WHERE balance < 0
+ORDER BY id;
+
 
 -- name: DeleteAccount :exec
 DELETE FROM accounts WHERE id = $1;
 
+
+-- name: SetAccountFrozen :one
+UPDATE accounts SET frozen = sqlc.arg(frozen) WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: ReconcileAccounts :many
+SELECT sqlc.embed(accounts), COALESCE(SUM(entries.amount), 0)::numeric AS ledger_balance
+FROM accounts
+LEFT JOIN entries ON entries.account_id = accounts.id
+GROUP BY accounts.id
+ORDER BY accounts.id;
+
+-- name: CloseAccount :one
+UPDATE accounts SET closed_at = now() WHERE id = $1 RETURNING *;
@@ -0,0 +1,37 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (
+  username, name, key_hash, prefix, scopes, allowed_ips, expires_at, signing_secret
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetApiKey :one
+SELECT * FROM api_keys
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetApiKeyByHash :one
+SELECT * FROM api_keys
+WHERE key_hash = $1
+LIMIT 1;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys
+WHERE username = $1 AND revoked_at IS NULL
+ORDER BY id;
+
+-- name: RevokeApiKey :execrows
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1 AND username = $2 AND revoked_at IS NULL;
+
+-- name: TouchApiKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1;
+
+-- name: RevokeApiKeys :exec
+UPDATE api_keys
+SET revoked_at = now()
+WHERE username = $1 AND revoked_at IS NULL;
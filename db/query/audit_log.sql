@@ -0,0 +1,19 @@
+-- name: CreateAuditLog :one
+INSERT INTO audit_log (
+  actor_type, actor, api_key_id, action, resource_type, resource_id, client_ip, request_id, before, after
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+RETURNING *;
+
+-- name: ListAuditLog :many
+SELECT * FROM audit_log
+WHERE id > sqlc.arg(after_id)
+  AND (sqlc.narg(actor)::varchar IS NULL OR actor = sqlc.narg(actor))
+  AND (sqlc.narg(action)::varchar IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(resource_type)::varchar IS NULL OR resource_type = sqlc.narg(resource_type))
+  AND (sqlc.narg(resource_id)::varchar IS NULL OR resource_id = sqlc.narg(resource_id))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id
+LIMIT sqlc.arg(limit_count);
@@ -0,0 +1,32 @@
+-- name: CreateDataRequest :one
+INSERT INTO data_requests (
+  username, kind, reason
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetDataRequestForUpdate :one
+SELECT * FROM data_requests
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListDataRequests :many
+-- The requests with the status, or all of them, oldest first.
+SELECT * FROM data_requests
+WHERE id > sqlc.arg(after_id)
+  AND (sqlc.narg(status)::data_request_status IS NULL OR status = sqlc.narg(status))
+ORDER BY id
+LIMIT sqlc.arg(limit_count);
+
+-- name: ListDataRequestsByUser :many
+SELECT * FROM data_requests
+WHERE username = $1
+ORDER BY id;
+
+-- name: ReviewDataRequest :one
+UPDATE data_requests
+SET status = sqlc.arg(status), reviewed_by = sqlc.arg(reviewed_by), review_note = sqlc.arg(review_note), reviewed_at = now()
+WHERE id = sqlc.arg(id) AND status = 'pending'
+RETURNING *;
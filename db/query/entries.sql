@@ -20,6 +20,7 @@ OFFSET $2;
 -- name: ListEntriesForAccount :many
 SELECT * FROM entries
 WHERE account_id = $1
+ORDER BY id
 LIMIT $2
 OFFSET $3;
 
@@ -30,3 +31,17 @@ UPDATE entries SET amount = $2 WHERE id = $1 RETURNING *;
 DELETE FROM entries WHERE id = $1;
 
 
+
+-- name: ListEntriesForAccountAfter :many
+SELECT * FROM entries
+WHERE account_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3;
+
+-- name: GetLatestEntryIDForAccount :one
+SELECT COALESCE(MAX(id), 0)::bigint AS id
+FROM entries
+WHERE account_id = $1;
+
+-- name: NotifyAccountEvent :exec
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
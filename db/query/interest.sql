@@ -0,0 +1,82 @@
+-- name: UpsertInterestProduct :one
+INSERT INTO interest_products (
+  currency, account_type, annual_rate, day_count
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (currency, account_type) DO UPDATE
+SET annual_rate = EXCLUDED.annual_rate, day_count = EXCLUDED.day_count
+RETURNING *;
+
+-- name: GetInterestProduct :one
+SELECT * FROM interest_products
+WHERE currency = $1 AND account_type = $2
+LIMIT 1;
+
+-- name: ListInterestProducts :many
+SELECT * FROM interest_products
+ORDER BY currency, account_type;
+
+-- name: ListInterestBearingAccounts :many
+SELECT sqlc.embed(accounts), sqlc.embed(interest_products)
+FROM accounts
+JOIN interest_products
+  ON interest_products.currency = accounts.currency
+  AND interest_products.account_type = accounts.account_type
+WHERE accounts.balance > 0 AND interest_products.annual_rate > 0
+ORDER BY accounts.id;
+
+-- name: CreateInterestAccrual :execrows
+INSERT INTO interest_accruals (
+  account_id, product_id, accrual_date, balance, annual_rate, day_count, amount
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (account_id, accrual_date) DO NOTHING;
+
+-- name: GetLatestInterestAccrualDate :one
+-- The date is NULL, not valid, before the first accrual.
+SELECT MAX(accrual_date)::date AS accrual_date
+FROM interest_accruals;
+
+-- name: ListInterestAccrualsForAccount :many
+SELECT * FROM interest_accruals
+WHERE account_id = $1
+ORDER BY accrual_date
+LIMIT $2
+OFFSET $3;
+
+-- name: ListUnpostedInterestPeriods :many
+SELECT account_id, date_trunc('month', accrual_date)::date AS period
+FROM interest_accruals
+WHERE posting_id IS NULL AND accrual_date < sqlc.arg(before)
+GROUP BY account_id, period
+ORDER BY period, account_id;
+
+-- name: SumUnpostedInterestAccruals :one
+SELECT COALESCE(SUM(amount), 0)::numeric AS total
+FROM interest_accruals
+WHERE account_id = sqlc.arg(account_id)
+  AND posting_id IS NULL
+  AND accrual_date >= sqlc.arg(period_start)
+  AND accrual_date < sqlc.arg(period_end);
+
+-- name: MarkInterestAccrualsPosted :execrows
+UPDATE interest_accruals SET posting_id = sqlc.arg(posting_id)
+WHERE account_id = sqlc.arg(account_id)
+  AND posting_id IS NULL
+  AND accrual_date >= sqlc.arg(period_start)
+  AND accrual_date < sqlc.arg(period_end);
+
+-- name: GetInterestPosting :one
+SELECT * FROM interest_postings
+WHERE account_id = $1 AND period = $2
+LIMIT 1;
+
+-- name: CreateInterestPosting :one
+INSERT INTO interest_postings (
+  account_id, period, amount, transfer_id
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
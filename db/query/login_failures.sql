@@ -0,0 +1,33 @@
+-- name: GetLoginFailure :one
+SELECT * FROM login_failures
+WHERE scope = $1 AND key = $2;
+
+-- name: RecordLoginFailure :one
+-- The count starts over once the failures before are older than window_start or the
+-- lockout they led to has ended.
+INSERT INTO login_failures (
+  scope, key, failures
+) VALUES (
+  $1, $2, 1
+)
+ON CONFLICT (scope, key) DO UPDATE
+SET failures = CASE
+      WHEN login_failures.last_failed_at < sqlc.arg(window_start) OR login_failures.locked_until <= now() THEN 1
+      ELSE login_failures.failures + 1
+    END,
+    locked_until = CASE
+      WHEN login_failures.locked_until <= now() THEN NULL
+      ELSE login_failures.locked_until
+    END,
+    last_failed_at = now()
+RETURNING *;
+
+-- name: LockLogin :one
+UPDATE login_failures
+SET locked_until = $3
+WHERE scope = $1 AND key = $2
+RETURNING *;
+
+-- name: DeleteLoginFailure :execrows
+DELETE FROM login_failures
+WHERE scope = $1 AND key = $2;
@@ -0,0 +1,19 @@
+-- name: CreateOverdraftCharge :one
+INSERT INTO overdraft_charges (
+  account_id, entry_id, charge_date, balance, annual_rate, amount
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetOverdraftCharge :one
+SELECT * FROM overdraft_charges
+WHERE account_id = $1 AND charge_date = $2
+LIMIT 1;
+
+-- name: ListOverdraftChargesForAccount :many
+SELECT * FROM overdraft_charges
+WHERE account_id = $1
+ORDER BY charge_date
+LIMIT $2
+OFFSET $3;
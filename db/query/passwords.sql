@@ -0,0 +1,57 @@
+-- name: GetUserByEmail :one
+-- An encrypted email is found by its blind index, one still in plaintext by itself.
+SELECT * FROM users
+WHERE email_index = sqlc.narg(email_index)
+  OR (data_key IS NULL AND lower(email) = lower(sqlc.arg(email)))
+LIMIT 1;
+
+-- name: GetUserTokensValidFrom :one
+-- The access tokens issued before the user last changed their password or their role are
+-- revoked.
+SELECT GREATEST(password_changed_at, tokens_revoked_at)::timestamptz AS valid_from FROM users
+WHERE username = $1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET password_hash = $2, password_changed_at = $3
+WHERE username = $1
+RETURNING *;
+
+-- name: CreatePasswordHistory :one
+INSERT INTO password_history (
+  username, password_hash
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: ListPasswordHistory :many
+SELECT * FROM password_history
+WHERE username = $1
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (
+  token_hash, username, expires_at
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetPasswordResetToken :one
+SELECT * FROM password_reset_tokens
+WHERE token_hash = $1 AND expires_at > now()
+LIMIT 1;
+
+-- name: UsePasswordResetToken :execrows
+DELETE FROM password_reset_tokens
+WHERE token_hash = $1 AND username = $2 AND expires_at > now();
+
+-- name: DeletePasswordResetTokens :exec
+DELETE FROM password_reset_tokens
+WHERE username = $1;
+
+-- name: DeletePasswordHistory :exec
+DELETE FROM password_history
+WHERE username = $1;
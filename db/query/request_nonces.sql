@@ -0,0 +1,14 @@
+-- name: UseRequestNonce :execrows
+-- A nonce used before that has not expired affects no row: the request is a replay.
+INSERT INTO request_nonces (
+  api_key_id, nonce, expires_at
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (api_key_id, nonce) DO UPDATE
+SET expires_at = EXCLUDED.expires_at
+WHERE request_nonces.expires_at <= now();
+
+-- name: DeleteExpiredRequestNonces :execrows
+DELETE FROM request_nonces
+WHERE expires_at <= now();
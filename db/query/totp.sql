@@ -0,0 +1,33 @@
+-- name: SetUserTOTPSecret :one
+UPDATE users
+SET totp_secret = $2, totp_enabled_at = NULL, totp_last_step = NULL
+WHERE username = $1
+RETURNING *;
+
+-- name: EnableUserTOTP :one
+UPDATE users
+SET totp_enabled_at = now(), totp_last_step = $2
+WHERE username = $1 AND totp_secret IS NOT NULL
+RETURNING *;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE username = $1 AND (totp_last_step IS NULL OR totp_last_step < $2);
+
+-- name: CreateTOTPBackupCode :one
+INSERT INTO totp_backup_codes (
+  username, code_hash
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: DeleteTOTPBackupCodes :exec
+DELETE FROM totp_backup_codes
+WHERE username = $1;
+
+-- name: UseTOTPBackupCode :execrows
+UPDATE totp_backup_codes
+SET used_at = now()
+WHERE username = $1 AND code_hash = $2 AND used_at IS NULL;
@@ -44,3 +44,16 @@ UPDATE transfers SET amount = $2 WHERE id = $1 RETURNING *;
 
 -- name: DeleteTransfer :exec
 DELETE FROM transfers WHERE id = $1;
+
+-- name: SetTransferReversalOf :one
+UPDATE transfers SET reversal_of = sqlc.arg(reversal_of) WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: ListTransfersByOwner :many
+-- The transfers into or out of any account of owner.
+SELECT t.* FROM transfers t
+WHERE t.id > sqlc.arg(after_id) AND (
+  t.from_account_id IN (SELECT a.id FROM accounts a WHERE a.owner = sqlc.arg(owner))
+  OR t.to_account_id IN (SELECT a.id FROM accounts a WHERE a.owner = sqlc.arg(owner))
+)
+ORDER BY t.id
+LIMIT sqlc.arg(limit_count);
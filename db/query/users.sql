@@ -1,8 +1,8 @@
 -- name: CreateUser :one
 INSERT INTO users (
-  username, full_name, email, password_hash
+  username, full_name, email, password_hash, email_index, data_key, data_key_id
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7
 )
 RETURNING *;
 
@@ -10,3 +10,86 @@ RETURNING *;
 SELECT * FROM users
 WHERE username = $1
 LIMIT 1;
+
+-- name: UpdateUserRole :one
+-- The tokens issued with the old role stop working.
+UPDATE users
+SET role = $2, tokens_revoked_at = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: UpdateUser :one
+-- The caller, who can compare the addresses when they are encrypted, says whether the
+-- email changed: a new one has to be verified again. A row whose data key is no longer
+-- previous_data_key was re-encrypted since it was read, and is left alone.
+UPDATE users
+SET
+  full_name = COALESCE(sqlc.narg(full_name), full_name),
+  email = COALESCE(sqlc.narg(email), email),
+  email_index = COALESCE(sqlc.narg(email_index), email_index),
+  data_key = COALESCE(sqlc.narg(data_key), data_key),
+  data_key_id = COALESCE(sqlc.narg(data_key_id), data_key_id),
+  email_verified_at = CASE
+    WHEN sqlc.arg(email_changed)::bool THEN NULL
+    ELSE email_verified_at
+  END
+WHERE username = sqlc.arg(username) AND data_key IS NOT DISTINCT FROM sqlc.narg(previous_data_key)
+RETURNING *;
+
+-- name: ListUsersToRekey :many
+-- The users in plaintext, or whose data key is wrapped by another master key than the
+-- current one.
+SELECT * FROM users
+WHERE data_key_id IS DISTINCT FROM sqlc.arg(key_id)::varchar AND username > sqlc.arg(after_username)
+ORDER BY username
+LIMIT sqlc.arg(limit_count);
+
+-- name: RekeyUser :execrows
+-- A user changed since it was read, as every change re-encrypts, is left for the next run.
+UPDATE users
+SET
+  full_name = sqlc.arg(full_name),
+  email = sqlc.arg(email),
+  email_index = sqlc.arg(email_index),
+  data_key = sqlc.arg(data_key),
+  data_key_id = sqlc.arg(data_key_id)
+WHERE username = sqlc.arg(username)
+  AND full_name = sqlc.arg(old_full_name)
+  AND email = sqlc.arg(old_email)
+  AND data_key IS NOT DISTINCT FROM sqlc.narg(old_data_key);
+
+-- name: ListUsersToIndex :many
+-- The users in plaintext whose email has no blind index yet.
+SELECT * FROM users
+WHERE data_key IS NULL AND email_index IS NULL AND username > sqlc.arg(after_username)
+ORDER BY username
+LIMIT sqlc.arg(limit_count);
+
+-- name: IndexUserEmail :execrows
+-- A user changed since it was read is left for the next run.
+UPDATE users
+SET email_index = sqlc.arg(email_index)
+WHERE username = sqlc.arg(username)
+  AND email = sqlc.arg(old_email)
+  AND data_key IS NULL;
+
+-- name: EraseUser :one
+-- The name and email are replaced with placeholders, in plaintext, so the data key that
+-- encrypted them goes too. Every way of logging in goes with them, and the tokens already
+-- issued stop working.
+UPDATE users
+SET
+  full_name = sqlc.arg(full_name),
+  email = sqlc.arg(email),
+  email_index = NULL,
+  data_key = NULL,
+  data_key_id = NULL,
+  password_hash = '!',
+  password_changed_at = now(),
+  totp_secret = NULL,
+  totp_enabled_at = NULL,
+  totp_last_step = NULL,
+  email_verified_at = NULL,
+  erased_at = now()
+WHERE username = sqlc.arg(username)
+RETURNING *;
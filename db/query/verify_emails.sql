@@ -0,0 +1,42 @@
+-- name: CreateVerifyEmail :one
+INSERT INTO verify_emails (
+  username, email, expires_at, data_key, data_key_id
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetVerifyEmail :one
+SELECT * FROM verify_emails
+WHERE id = $1;
+
+-- name: UseVerifyEmail :execrows
+UPDATE verify_emails
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND expires_at > now();
+
+-- name: SetUserEmailVerified :one
+-- The address must still be the user's: a code sent to an old one verifies nothing. An
+-- encrypted email is compared by its blind index.
+UPDATE users
+SET email_verified_at = now()
+WHERE username = sqlc.arg(username) AND (
+  email_index = sqlc.narg(email_index)
+  OR (email_index IS NULL AND email = sqlc.arg(email))
+)
+RETURNING *;
+
+-- name: ListVerifyEmailsToRekey :many
+SELECT * FROM verify_emails
+WHERE data_key_id IS DISTINCT FROM sqlc.arg(key_id)::varchar AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(limit_count);
+
+-- name: RekeyVerifyEmail :execrows
+UPDATE verify_emails
+SET email = sqlc.arg(email), data_key = sqlc.arg(data_key), data_key_id = sqlc.arg(data_key_id)
+WHERE id = sqlc.arg(id) AND email = sqlc.arg(old_email) AND data_key IS NOT DISTINCT FROM sqlc.narg(old_data_key);
+
+-- name: DeleteVerifyEmails :exec
+DELETE FROM verify_emails
+WHERE username = $1;
@@ -0,0 +1,89 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (
+  username, url, secret, event_types
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE username = $1
+ORDER BY id;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND username = $2;
+
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (
+  username, event_type, payload
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1
+LIMIT 1;
+
+-- name: CreateWebhookDeliveriesForEvent :execrows
+INSERT INTO webhook_deliveries (event_id, subscription_id)
+SELECT sqlc.arg(event_id), id
+FROM webhook_subscriptions
+WHERE username = sqlc.arg(username)
+  AND active
+  AND sqlc.arg(event_type)::varchar = ANY(event_types);
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY id DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Leases due deliveries to one worker by pushing next_attempt_at to lease_until,
+-- so concurrent workers skip them until the lease runs out.
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending' AND next_attempt_at <= now()
+  ORDER BY next_attempt_at
+  LIMIT sqlc.arg(batch_size)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :one
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkWebhookDeliveryFailed :one
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = now(), last_status_code = NULL, last_error = NULL, delivered_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWebhookSubscriptions :exec
+DELETE FROM webhook_subscriptions
+WHERE username = $1;
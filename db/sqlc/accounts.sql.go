@@ -11,8 +11,8 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const addAccountBalance = `-- name: AddAccountBalance :exec
-UPDATE accounts SET balance = balance + $1 WHERE id = $2 RETURNING id, owner, balance, currency, created_at
+const addAccountBalance = `-- name: AddAccountBalance :one
+UPDATE accounts SET balance = balance + $1 WHERE id = $2 RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
 `
 
 type AddAccountBalanceParams struct {
@@ -20,28 +20,95 @@ type AddAccountBalanceParams struct {
 	ID     int64          `json:"id"`
 }
 
-func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) error {
-	_, err := q.db.Exec(ctx, addAccountBalance, arg.Amount, arg.ID)
-	return err
+func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error) {
+	row := q.db.QueryRow(ctx, addAccountBalance, arg.Amount, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const closeAccount = `-- name: CloseAccount :one
+UPDATE accounts SET closed_at = now() WHERE id = $1 RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
+`
+
+func (q *Queries) CloseAccount(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRow(ctx, closeAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
+	)
+	return i, err
 }
 
 const createAccount = `-- name: CreateAccount :one
 INSERT INTO accounts (
-  owner, balance, currency
+  owner, balance, currency, account_type
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4
 )
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
 `
 
 type CreateAccountParams struct {
-	Owner    string         `json:"owner"`
-	Balance  pgtype.Numeric `json:"balance"`
-	Currency string         `json:"currency"`
+	Owner       string         `json:"owner"`
+	Balance     pgtype.Numeric `json:"balance"`
+	Currency    string         `json:"currency"`
+	AccountType AccountType    `json:"account_type"`
 }
 
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
-	row := q.db.QueryRow(ctx, createAccount, arg.Owner, arg.Balance, arg.Currency)
+	row := q.db.QueryRow(ctx, createAccount,
+		arg.Owner,
+		arg.Balance,
+		arg.Currency,
+		arg.AccountType,
+	)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const debitAccountBalance = `-- name: DebitAccountBalance :one
+UPDATE accounts SET balance = balance - $1
+WHERE id = $2 AND balance - $1 >= -overdraft_limit
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
+`
+
+type DebitAccountBalanceParams struct {
+	Amount pgtype.Numeric `json:"amount"`
+	ID     int64          `json:"id"`
+}
+
+func (q *Queries) DebitAccountBalance(ctx context.Context, arg DebitAccountBalanceParams) (Account, error) {
+	row := q.db.QueryRow(ctx, debitAccountBalance, arg.Amount, arg.ID)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -49,6 +116,10 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
@@ -63,7 +134,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at FROM accounts
 WHERE id = $1
 LIMIT 1
 `
@@ -77,12 +148,45 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getAccountByOwnerCurrencyAndType = `-- name: GetAccountByOwnerCurrencyAndType :one
+SELECT id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at FROM accounts
+WHERE owner = $1 AND currency = $2 AND account_type = $3
+LIMIT 1
+`
+
+type GetAccountByOwnerCurrencyAndTypeParams struct {
+	Owner       string      `json:"owner"`
+	Currency    string      `json:"currency"`
+	AccountType AccountType `json:"account_type"`
+}
+
+func (q *Queries) GetAccountByOwnerCurrencyAndType(ctx context.Context, arg GetAccountByOwnerCurrencyAndTypeParams) (Account, error) {
+	row := q.db.QueryRow(ctx, getAccountByOwnerCurrencyAndType, arg.Owner, arg.Currency, arg.AccountType)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at FROM accounts
 WHERE id = $1
 LIMIT 1
 FOR NO KEY UPDATE
@@ -97,12 +201,16 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at FROM accounts
 ORDER BY id 
 LIMIT $1 
 OFFSET $2
@@ -128,6 +236,54 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.AccountType,
+			&i.Frozen,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByOwner = `-- name: ListAccountsByOwner :many
+SELECT id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at FROM accounts
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3
+`
+
+type ListAccountsByOwnerParams struct {
+	Owner  string `json:"owner"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) ListAccountsByOwner(ctx context.Context, arg ListAccountsByOwnerParams) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listAccountsByOwner, arg.Owner, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.AccountType,
+			&i.Frozen,
+			&i.ClosedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -139,8 +295,114 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	return items, nil
 }
 
+const listOverdrawnAccounts = `-- name: ListOverdrawnAccounts :many
+SELECT id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at FROM accounts
+WHERE balance < 0
+ORDER BY id
+`
+
+func (q *Queries) ListOverdrawnAccounts(ctx context.Context) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listOverdrawnAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.AccountType,
+			&i.Frozen,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reconcileAccounts = `-- name: ReconcileAccounts :many
+SELECT accounts.id, accounts.owner, accounts.balance, accounts.currency, accounts.created_at, accounts.overdraft_limit, accounts.account_type, accounts.frozen, accounts.closed_at, COALESCE(SUM(entries.amount), 0)::numeric AS ledger_balance
+FROM accounts
+LEFT JOIN entries ON entries.account_id = accounts.id
+GROUP BY accounts.id
+ORDER BY accounts.id
+`
+
+type ReconcileAccountsRow struct {
+	Account       Account        `json:"account"`
+	LedgerBalance pgtype.Numeric `json:"ledger_balance"`
+}
+
+func (q *Queries) ReconcileAccounts(ctx context.Context) ([]ReconcileAccountsRow, error) {
+	rows, err := q.db.Query(ctx, reconcileAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ReconcileAccountsRow{}
+	for rows.Next() {
+		var i ReconcileAccountsRow
+		if err := rows.Scan(
+			&i.Account.ID,
+			&i.Account.Owner,
+			&i.Account.Balance,
+			&i.Account.Currency,
+			&i.Account.CreatedAt,
+			&i.Account.OverdraftLimit,
+			&i.Account.AccountType,
+			&i.Account.Frozen,
+			&i.Account.ClosedAt,
+			&i.LedgerBalance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setAccountFrozen = `-- name: SetAccountFrozen :one
+UPDATE accounts SET frozen = $1 WHERE id = $2 RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
+`
+
+type SetAccountFrozenParams struct {
+	Frozen bool  `json:"frozen"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) SetAccountFrozen(ctx context.Context, arg SetAccountFrozenParams) (Account, error) {
+	row := q.db.QueryRow(ctx, setAccountFrozen, arg.Frozen, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
 const subtractAccountBalance = `-- name: SubtractAccountBalance :exec
-UPDATE accounts SET balance = balance - $1 WHERE id = $2 RETURNING id, owner, balance, currency, created_at
+UPDATE accounts SET balance = balance - $1 WHERE id = $2 RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
 `
 
 type SubtractAccountBalanceParams struct {
@@ -154,7 +416,7 @@ func (q *Queries) SubtractAccountBalance(ctx context.Context, arg SubtractAccoun
 }
 
 const updateAccountBalance = `-- name: UpdateAccountBalance :exec
-UPDATE accounts SET balance = $2 WHERE id = $1 RETURNING id, owner, balance, currency, created_at
+UPDATE accounts SET balance = $2 WHERE id = $1 RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
 `
 
 type UpdateAccountBalanceParams struct {
@@ -166,3 +428,29 @@ func (q *Queries) UpdateAccountBalance(ctx context.Context, arg UpdateAccountBal
 	_, err := q.db.Exec(ctx, updateAccountBalance, arg.ID, arg.Balance)
 	return err
 }
+
+const updateAccountOverdraftLimit = `-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts SET overdraft_limit = $1 WHERE id = $2 RETURNING id, owner, balance, currency, created_at, overdraft_limit, account_type, frozen, closed_at
+`
+
+type UpdateAccountOverdraftLimitParams struct {
+	OverdraftLimit pgtype.Numeric `json:"overdraft_limit"`
+	ID             int64          `json:"id"`
+}
+
+func (q *Queries) UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error) {
+	row := q.db.QueryRow(ctx, updateAccountOverdraftLimit, arg.OverdraftLimit, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.AccountType,
+		&i.Frozen,
+		&i.ClosedAt,
+	)
+	return i, err
+}
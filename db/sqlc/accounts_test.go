@@ -8,10 +8,16 @@ import (
 
 
 func CreateRandomAccount(ctx context.Context) (Account, error) {
+	user, err := CreateRandomUser(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+
 	arg := CreateAccountParams{
-		Owner:    util.RandomOwner(),
-		Balance:  util.RandomMoney(),
-		Currency: util.RandomCurrency(),
+		Owner:       user.Username,
+		Balance:     util.RandomMoney(),
+		Currency:    util.RandomCurrency(),
+		AccountType: AccountTypeChecking,
 	}
 
 	account, err := testQueries.CreateAccount(ctx, arg)
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"strconv"
+)
+
+// auditAPIKey is the part of an API key the audit log keeps: never its hash or signing
+// secret.
+type auditAPIKey struct {
+	Username          string   `json:"username"`
+	Scopes            []string `json:"scopes"`
+	SignatureRequired bool     `json:"signature_required"`
+}
+
+// CreateApiKeyTx creates an API key and records it in the audit log.
+func (t Transactions) CreateApiKeyTx(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error) {
+	ctx, span := tracer.Start(ctx, "CreateApiKeyTx")
+	defer span.End()
+
+	var apiKey ApiKey
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+		apiKey, err = q.CreateApiKey(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "api_key.created",
+			ResourceType: "api_key",
+			ResourceID:   strconv.FormatInt(apiKey.ID, 10),
+			After:        auditAPIKey{Username: apiKey.Username, Scopes: apiKey.Scopes, SignatureRequired: apiKey.SigningSecret.Valid},
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return apiKey, err
+}
+
+// RevokeApiKeyTx revokes one of a user's API keys and records it in the audit log. It
+// returns how many keys it revoked: none for a key that is not the user's or was revoked
+// already, which records nothing.
+func (t Transactions) RevokeApiKeyTx(ctx context.Context, arg RevokeApiKeyParams) (int64, error) {
+	ctx, span := tracer.Start(ctx, "RevokeApiKeyTx")
+	defer span.End()
+
+	var revoked int64
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+		revoked, err = q.RevokeApiKey(ctx, arg)
+		if err != nil || revoked == 0 {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "api_key.revoked",
+			ResourceType: "api_key",
+			ResourceID:   strconv.FormatInt(arg.ID, 10),
+			After:        map[string]string{"username": arg.Username},
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return revoked, err
+}
@@ -0,0 +1,193 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_keys.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createApiKey = `-- name: CreateApiKey :one
+INSERT INTO api_keys (
+  username, name, key_hash, prefix, scopes, allowed_ips, expires_at, signing_secret
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING id, username, name, key_hash, prefix, scopes, allowed_ips, expires_at, last_used_at, revoked_at, created_at, signing_secret
+`
+
+type CreateApiKeyParams struct {
+	Username      string             `json:"username"`
+	Name          string             `json:"name"`
+	KeyHash       string             `json:"key_hash"`
+	Prefix        string             `json:"prefix"`
+	Scopes        []string           `json:"scopes"`
+	AllowedIps    []string           `json:"allowed_ips"`
+	ExpiresAt     pgtype.Timestamptz `json:"expires_at"`
+	SigningSecret pgtype.Text        `json:"signing_secret"`
+}
+
+func (q *Queries) CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, createApiKey,
+		arg.Username,
+		arg.Name,
+		arg.KeyHash,
+		arg.Prefix,
+		arg.Scopes,
+		arg.AllowedIps,
+		arg.ExpiresAt,
+		arg.SigningSecret,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.KeyHash,
+		&i.Prefix,
+		&i.Scopes,
+		&i.AllowedIps,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.SigningSecret,
+	)
+	return i, err
+}
+
+const getApiKey = `-- name: GetApiKey :one
+SELECT id, username, name, key_hash, prefix, scopes, allowed_ips, expires_at, last_used_at, revoked_at, created_at, signing_secret FROM api_keys
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetApiKey(ctx context.Context, id int64) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, getApiKey, id)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.KeyHash,
+		&i.Prefix,
+		&i.Scopes,
+		&i.AllowedIps,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.SigningSecret,
+	)
+	return i, err
+}
+
+const getApiKeyByHash = `-- name: GetApiKeyByHash :one
+SELECT id, username, name, key_hash, prefix, scopes, allowed_ips, expires_at, last_used_at, revoked_at, created_at, signing_secret FROM api_keys
+WHERE key_hash = $1
+LIMIT 1
+`
+
+func (q *Queries) GetApiKeyByHash(ctx context.Context, keyHash string) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, getApiKeyByHash, keyHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.KeyHash,
+		&i.Prefix,
+		&i.Scopes,
+		&i.AllowedIps,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.SigningSecret,
+	)
+	return i, err
+}
+
+const listApiKeys = `-- name: ListApiKeys :many
+SELECT id, username, name, key_hash, prefix, scopes, allowed_ips, expires_at, last_used_at, revoked_at, created_at, signing_secret FROM api_keys
+WHERE username = $1 AND revoked_at IS NULL
+ORDER BY id
+`
+
+func (q *Queries) ListApiKeys(ctx context.Context, username string) ([]ApiKey, error) {
+	rows, err := q.db.Query(ctx, listApiKeys, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ApiKey{}
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Name,
+			&i.KeyHash,
+			&i.Prefix,
+			&i.Scopes,
+			&i.AllowedIps,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+			&i.SigningSecret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeApiKey = `-- name: RevokeApiKey :execrows
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1 AND username = $2 AND revoked_at IS NULL
+`
+
+type RevokeApiKeyParams struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeApiKey, arg.ID, arg.Username)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeApiKeys = `-- name: RevokeApiKeys :exec
+UPDATE api_keys
+SET revoked_at = now()
+WHERE username = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeApiKeys(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, revokeApiKeys, username)
+	return err
+}
+
+const touchApiKey = `-- name: TouchApiKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) TouchApiKey(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, touchApiKey, id)
+	return err
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"example.com/logging"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Actor types of the audit log.
+const (
+	AuditActorUser      = "user"
+	AuditActorCLI       = "cli"
+	AuditActorSystem    = "system"
+	AuditActorAnonymous = "anonymous"
+)
+
+// AuditActor is who makes a change, and from where. The API puts one in the context of
+// every request; WriteAudit reads it from there, with the request ID.
+type AuditActor struct {
+	Type string
+	// Username is the user acting, or for the CLI the operator's login, if known.
+	Username string
+	// APIKeyID is the key the user acts with, zero for an access token.
+	APIKeyID int64
+	ClientIP string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a context in which the changes are made by actor.
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFrom returns the actor of ctx. Without one, the change is the system's own,
+// such as the workers posting interest.
+func AuditActorFrom(ctx context.Context) AuditActor {
+	if actor, ok := ctx.Value(auditActorKey{}).(AuditActor); ok {
+		return actor
+	}
+	return AuditActor{Type: AuditActorSystem}
+}
+
+// AuditEntry is a change to record in the audit log. Before and After are written as JSON;
+// nil leaves them NULL, as for a row that did not exist before.
+type AuditEntry struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       any
+	After        any
+}
+
+// WriteAudit records an entry made by the actor of ctx. The transactions call it with their
+// own Querier, like publishEvent, so that the entry is recorded if and only if the change
+// commits.
+func WriteAudit(ctx context.Context, q Querier, entry AuditEntry) (AuditLog, error) {
+	before, err := auditJSON(entry.Before)
+	if err != nil {
+		return AuditLog{}, err
+	}
+	after, err := auditJSON(entry.After)
+	if err != nil {
+		return AuditLog{}, err
+	}
+
+	actor := AuditActorFrom(ctx)
+	return q.CreateAuditLog(ctx, CreateAuditLogParams{
+		ActorType:    actor.Type,
+		Actor:        pgtype.Text{String: actor.Username, Valid: actor.Username != ""},
+		ApiKeyID:     pgtype.Int8{Int64: actor.APIKeyID, Valid: actor.APIKeyID != 0},
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		ClientIp:     pgtype.Text{String: actor.ClientIP, Valid: actor.ClientIP != ""},
+		RequestID:    pgtype.Text{String: logging.RequestID(ctx), Valid: logging.RequestID(ctx) != ""},
+		Before:       before,
+		After:        after,
+	})
+}
+
+func auditJSON(state any) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// auditUser is the part of a user the audit log keeps: never their secrets.
+type auditUser struct {
+	Username string   `json:"username"`
+	Role     UserRole `json:"role"`
+}
+
+// transferAuditBefore returns the balances of the accounts of a transfer before it, worked
+// out from the entries it made.
+func transferAuditBefore(result TransferTxResult) map[string]Account {
+	before := func(account Account, entry Entry) Account {
+		account.Balance = AddNumeric(account.Balance, pgtype.Numeric{
+			Int:   new(big.Int).Neg(entry.Amount.Int),
+			Exp:   entry.Amount.Exp,
+			Valid: entry.Amount.Valid,
+		})
+		return account
+	}
+	return map[string]Account{
+		"from_account": before(result.FromAccount, result.FromEntry),
+		"to_account":   before(result.ToAccount, result.ToEntry),
+	}
+}
@@ -0,0 +1,131 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_log.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAuditLog = `-- name: CreateAuditLog :one
+INSERT INTO audit_log (
+  actor_type, actor, api_key_id, action, resource_type, resource_id, client_ip, request_id, before, after
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+RETURNING id, actor_type, actor, api_key_id, action, resource_type, resource_id, client_ip, request_id, before, after, created_at
+`
+
+type CreateAuditLogParams struct {
+	ActorType    string      `json:"actor_type"`
+	Actor        pgtype.Text `json:"actor"`
+	ApiKeyID     pgtype.Int8 `json:"api_key_id"`
+	Action       string      `json:"action"`
+	ResourceType string      `json:"resource_type"`
+	ResourceID   string      `json:"resource_id"`
+	ClientIp     pgtype.Text `json:"client_ip"`
+	RequestID    pgtype.Text `json:"request_id"`
+	Before       []byte      `json:"before"`
+	After        []byte      `json:"after"`
+}
+
+func (q *Queries) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error) {
+	row := q.db.QueryRow(ctx, createAuditLog,
+		arg.ActorType,
+		arg.Actor,
+		arg.ApiKeyID,
+		arg.Action,
+		arg.ResourceType,
+		arg.ResourceID,
+		arg.ClientIp,
+		arg.RequestID,
+		arg.Before,
+		arg.After,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.ActorType,
+		&i.Actor,
+		&i.ApiKeyID,
+		&i.Action,
+		&i.ResourceType,
+		&i.ResourceID,
+		&i.ClientIp,
+		&i.RequestID,
+		&i.Before,
+		&i.After,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAuditLog = `-- name: ListAuditLog :many
+SELECT id, actor_type, actor, api_key_id, action, resource_type, resource_id, client_ip, request_id, before, after, created_at FROM audit_log
+WHERE id > $1
+  AND ($2::varchar IS NULL OR actor = $2)
+  AND ($3::varchar IS NULL OR action = $3)
+  AND ($4::varchar IS NULL OR resource_type = $4)
+  AND ($5::varchar IS NULL OR resource_id = $5)
+  AND ($6::timestamptz IS NULL OR created_at >= $6)
+  AND ($7::timestamptz IS NULL OR created_at < $7)
+ORDER BY id
+LIMIT $8
+`
+
+type ListAuditLogParams struct {
+	AfterID      int64              `json:"after_id"`
+	Actor        pgtype.Text        `json:"actor"`
+	Action       pgtype.Text        `json:"action"`
+	ResourceType pgtype.Text        `json:"resource_type"`
+	ResourceID   pgtype.Text        `json:"resource_id"`
+	Since        pgtype.Timestamptz `json:"since"`
+	Until        pgtype.Timestamptz `json:"until"`
+	LimitCount   int32              `json:"limit_count"`
+}
+
+func (q *Queries) ListAuditLog(ctx context.Context, arg ListAuditLogParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditLog,
+		arg.AfterID,
+		arg.Actor,
+		arg.Action,
+		arg.ResourceType,
+		arg.ResourceID,
+		arg.Since,
+		arg.Until,
+		arg.LimitCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditLog{}
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorType,
+			&i.Actor,
+			&i.ApiKeyID,
+			&i.Action,
+			&i.ResourceType,
+			&i.ResourceID,
+			&i.ClientIp,
+			&i.RequestID,
+			&i.Before,
+			&i.After,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
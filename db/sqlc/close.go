@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrAccountClosed is returned when a transfer would move money out of or into a closed account.
+	ErrAccountClosed = errors.New("account is closed")
+	// ErrAccountNotEmpty is returned when closing an account whose balance is not zero.
+	ErrAccountNotEmpty = errors.New("account balance is not zero")
+)
+
+// CloseAccountTx closes an account, records it in the audit log and publishes an
+// account.closed event. Only an account with a zero balance can be closed; it is kept,
+// with its entries and transfers, but takes no more transfers. Closing an account that is
+// already closed changes nothing and records nothing.
+func (t Transactions) CloseAccountTx(ctx context.Context, accountID int64) (Account, error) {
+	ctx, span := tracer.Start(ctx, "CloseAccountTx")
+	defer span.End()
+
+	var account Account
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		before, err := q.GetAccountForUpdate(ctx, accountID)
+		account = before
+		if err != nil || account.ClosedAt.Valid {
+			return err
+		}
+		if NumericToRat(account.Balance).Sign() != 0 {
+			return ErrAccountNotEmpty
+		}
+
+		account, err = q.CloseAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       EventAccountClosed,
+			ResourceType: "account",
+			ResourceID:   strconv.FormatInt(account.ID, 10),
+			Before:       before,
+			After:        account,
+		})
+		if err != nil {
+			return err
+		}
+		return publishEvent(ctx, q, account.Owner, EventAccountClosed, account)
+	})
+
+	recordSpanError(span, err)
+	return account, err
+}
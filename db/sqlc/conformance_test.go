@@ -0,0 +1,14 @@
+package db_test
+
+import (
+	"testing"
+
+	db "example.com/db/sqlc"
+	"example.com/db/storetest"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Store {
+		return db.NewTestStore()
+	})
+}
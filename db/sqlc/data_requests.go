@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrAccountsOpen is returned when erasing a user who still has an account open.
+	ErrAccountsOpen = errors.New("user has open accounts")
+	// ErrDataRequestReviewed is returned when reviewing a data request that has already been
+	// approved or rejected.
+	ErrDataRequestReviewed = errors.New("data request has already been reviewed")
+)
+
+// ErasedFullName is the name an erased user is left with.
+const ErasedFullName = "Erased user"
+
+type RequestErasureTxParams struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+// RequestErasureTx records a user's request to have their data erased, for an admin to
+// review, and records it in the audit log. It fails with ErrAccountsOpen unless every
+// account of the user is closed, which takes a zero balance.
+func (t Transactions) RequestErasureTx(ctx context.Context, arg RequestErasureTxParams) (DataRequest, error) {
+	ctx, span := tracer.Start(ctx, "RequestErasureTx")
+	defer span.End()
+
+	var request DataRequest
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		if err := checkAccountsClosed(ctx, q, arg.Username); err != nil {
+			return err
+		}
+
+		var err error
+		request, err = q.CreateDataRequest(ctx, CreateDataRequestParams{
+			Username: arg.Username,
+			Kind:     DataRequestKindErasure,
+			Reason:   arg.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "data_request.created",
+			ResourceType: "data_request",
+			ResourceID:   strconv.FormatInt(request.ID, 10),
+			After:        request,
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return request, err
+}
+
+type ReviewDataRequestTxParams struct {
+	ID         int64  `json:"id"`
+	Approve    bool   `json:"approve"`
+	ReviewedBy string `json:"reviewed_by"`
+	Note       string `json:"note"`
+}
+
+// ReviewDataRequestTx approves or rejects a pending data request and records it in the
+// audit log. Approving an erasure carries it out: the user's name and email are replaced
+// with placeholders, and their password, second factor, API keys, webhook subscriptions
+// and pending codes are removed, so they can no longer log in. Their accounts, entries,
+// transfers and audit log stay, under their username, as the financial records have to be
+// kept. It fails with ErrDataRequestReviewed if the request is not pending, and with
+// ErrAccountsOpen if the user opened an account since asking.
+func (t Transactions) ReviewDataRequestTx(ctx context.Context, arg ReviewDataRequestTxParams) (DataRequest, error) {
+	ctx, span := tracer.Start(ctx, "ReviewDataRequestTx")
+	defer span.End()
+
+	var request DataRequest
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		before, err := q.GetDataRequestForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+		if before.Status != DataRequestStatusPending {
+			return ErrDataRequestReviewed
+		}
+
+		status, action := DataRequestStatusRejected, "data_request.rejected"
+		if arg.Approve {
+			status, action = DataRequestStatusApproved, "data_request.approved"
+			if err := pseudonymizeUser(ctx, q, before); err != nil {
+				return err
+			}
+		}
+
+		request, err = q.ReviewDataRequest(ctx, ReviewDataRequestParams{
+			Status:     status,
+			ReviewedBy: pgtype.Text{String: arg.ReviewedBy, Valid: arg.ReviewedBy != ""},
+			ReviewNote: arg.Note,
+			ID:         arg.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       action,
+			ResourceType: "data_request",
+			ResourceID:   strconv.FormatInt(request.ID, 10),
+			Before:       before,
+			After:        request,
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return request, err
+}
+
+// pseudonymizeUser pseudonymizes the user of an approved erasure request.
+func pseudonymizeUser(ctx context.Context, q Querier, request DataRequest) error {
+	if err := checkAccountsClosed(ctx, q, request.Username); err != nil {
+		return err
+	}
+
+	user, err := q.EraseUser(ctx, EraseUserParams{
+		FullName: ErasedFullName,
+		// The email stays unique, and .invalid never delivers.
+		Email:    fmt.Sprintf("erased+%d@simplebank.invalid", request.ID),
+		Username: request.Username,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, remove := range []func(context.Context, string) error{
+		q.DeletePasswordHistory,
+		q.DeletePasswordResetTokens,
+		q.DeleteVerifyEmails,
+		q.DeleteTOTPBackupCodes,
+		q.DeleteWebhookSubscriptions,
+		q.RevokeApiKeys,
+	} {
+		if err := remove(ctx, user.Username); err != nil {
+			return err
+		}
+	}
+	_, err = q.DeleteLoginFailure(ctx, DeleteLoginFailureParams{Scope: LoginFailureScopeUsername, Key: user.Username})
+	if err != nil {
+		return err
+	}
+
+	_, err = WriteAudit(ctx, q, AuditEntry{
+		Action:       "user.erased",
+		ResourceType: "user",
+		ResourceID:   user.Username,
+		After:        auditUser{Username: user.Username, Role: user.Role},
+	})
+	return err
+}
+
+// checkAccountsClosed returns ErrAccountsOpen unless every account of the user is closed.
+func checkAccountsClosed(ctx context.Context, q Querier, username string) error {
+	const pageSize = 100
+	for offset := int32(0); ; offset += pageSize {
+		accounts, err := q.ListAccountsByOwner(ctx, ListAccountsByOwnerParams{Owner: username, Limit: pageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			if !account.ClosedAt.Valid {
+				return ErrAccountsOpen
+			}
+		}
+		if len(accounts) < pageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: data_requests.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createDataRequest = `-- name: CreateDataRequest :one
+INSERT INTO data_requests (
+  username, kind, reason
+) VALUES (
+  $1, $2, $3
+)
+RETURNING id, username, kind, status, reason, reviewed_by, review_note, created_at, reviewed_at
+`
+
+type CreateDataRequestParams struct {
+	Username string          `json:"username"`
+	Kind     DataRequestKind `json:"kind"`
+	Reason   string          `json:"reason"`
+}
+
+func (q *Queries) CreateDataRequest(ctx context.Context, arg CreateDataRequestParams) (DataRequest, error) {
+	row := q.db.QueryRow(ctx, createDataRequest, arg.Username, arg.Kind, arg.Reason)
+	var i DataRequest
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Kind,
+		&i.Status,
+		&i.Reason,
+		&i.ReviewedBy,
+		&i.ReviewNote,
+		&i.CreatedAt,
+		&i.ReviewedAt,
+	)
+	return i, err
+}
+
+const getDataRequestForUpdate = `-- name: GetDataRequestForUpdate :one
+SELECT id, username, kind, status, reason, reviewed_by, review_note, created_at, reviewed_at FROM data_requests
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetDataRequestForUpdate(ctx context.Context, id int64) (DataRequest, error) {
+	row := q.db.QueryRow(ctx, getDataRequestForUpdate, id)
+	var i DataRequest
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Kind,
+		&i.Status,
+		&i.Reason,
+		&i.ReviewedBy,
+		&i.ReviewNote,
+		&i.CreatedAt,
+		&i.ReviewedAt,
+	)
+	return i, err
+}
+
+const listDataRequests = `-- name: ListDataRequests :many
+SELECT id, username, kind, status, reason, reviewed_by, review_note, created_at, reviewed_at FROM data_requests
+WHERE id > $1
+  AND ($2::data_request_status IS NULL OR status = $2)
+ORDER BY id
+LIMIT $3
+`
+
+type ListDataRequestsParams struct {
+	AfterID    int64                 `json:"after_id"`
+	Status     NullDataRequestStatus `json:"status"`
+	LimitCount int32                 `json:"limit_count"`
+}
+
+// The requests with the status, or all of them, oldest first.
+func (q *Queries) ListDataRequests(ctx context.Context, arg ListDataRequestsParams) ([]DataRequest, error) {
+	rows, err := q.db.Query(ctx, listDataRequests, arg.AfterID, arg.Status, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DataRequest{}
+	for rows.Next() {
+		var i DataRequest
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Kind,
+			&i.Status,
+			&i.Reason,
+			&i.ReviewedBy,
+			&i.ReviewNote,
+			&i.CreatedAt,
+			&i.ReviewedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDataRequestsByUser = `-- name: ListDataRequestsByUser :many
+SELECT id, username, kind, status, reason, reviewed_by, review_note, created_at, reviewed_at FROM data_requests
+WHERE username = $1
+ORDER BY id
+`
+
+func (q *Queries) ListDataRequestsByUser(ctx context.Context, username string) ([]DataRequest, error) {
+	rows, err := q.db.Query(ctx, listDataRequestsByUser, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DataRequest{}
+	for rows.Next() {
+		var i DataRequest
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Kind,
+			&i.Status,
+			&i.Reason,
+			&i.ReviewedBy,
+			&i.ReviewNote,
+			&i.CreatedAt,
+			&i.ReviewedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reviewDataRequest = `-- name: ReviewDataRequest :one
+UPDATE data_requests
+SET status = $1, reviewed_by = $2, review_note = $3, reviewed_at = now()
+WHERE id = $4 AND status = 'pending'
+RETURNING id, username, kind, status, reason, reviewed_by, review_note, created_at, reviewed_at
+`
+
+type ReviewDataRequestParams struct {
+	Status     DataRequestStatus `json:"status"`
+	ReviewedBy pgtype.Text       `json:"reviewed_by"`
+	ReviewNote string            `json:"review_note"`
+	ID         int64             `json:"id"`
+}
+
+func (q *Queries) ReviewDataRequest(ctx context.Context, arg ReviewDataRequestParams) (DataRequest, error) {
+	row := q.db.QueryRow(ctx, reviewDataRequest,
+		arg.Status,
+		arg.ReviewedBy,
+		arg.ReviewNote,
+		arg.ID,
+	)
+	var i DataRequest
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Kind,
+		&i.Status,
+		&i.Reason,
+		&i.ReviewedBy,
+		&i.ReviewNote,
+		&i.CreatedAt,
+		&i.ReviewedAt,
+	)
+	return i, err
+}
@@ -64,6 +64,19 @@ func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
 	return i, err
 }
 
+const getLatestEntryIDForAccount = `-- name: GetLatestEntryIDForAccount :one
+SELECT COALESCE(MAX(id), 0)::bigint AS id
+FROM entries
+WHERE account_id = $1
+`
+
+func (q *Queries) GetLatestEntryIDForAccount(ctx context.Context, accountID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, getLatestEntryIDForAccount, accountID)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const listEntries = `-- name: ListEntries :many
 SELECT id, account_id, amount, created_at FROM entries
 ORDER BY id
@@ -104,6 +117,7 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 const listEntriesForAccount = `-- name: ListEntriesForAccount :many
 SELECT id, account_id, amount, created_at FROM entries
 WHERE account_id = $1
+ORDER BY id
 LIMIT $2
 OFFSET $3
 `
@@ -139,6 +153,58 @@ func (q *Queries) ListEntriesForAccount(ctx context.Context, arg ListEntriesForA
 	return items, nil
 }
 
+const listEntriesForAccountAfter = `-- name: ListEntriesForAccountAfter :many
+SELECT id, account_id, amount, created_at FROM entries
+WHERE account_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListEntriesForAccountAfterParams struct {
+	AccountID int64 `json:"account_id"`
+	ID        int64 `json:"id"`
+	Limit     int32 `json:"limit"`
+}
+
+func (q *Queries) ListEntriesForAccountAfter(ctx context.Context, arg ListEntriesForAccountAfterParams) ([]Entry, error) {
+	rows, err := q.db.Query(ctx, listEntriesForAccountAfter, arg.AccountID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyAccountEvent = `-- name: NotifyAccountEvent :exec
+SELECT pg_notify($1::text, $2::text)
+`
+
+type NotifyAccountEventParams struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+}
+
+func (q *Queries) NotifyAccountEvent(ctx context.Context, arg NotifyAccountEventParams) error {
+	_, err := q.db.Exec(ctx, notifyAccountEvent, arg.Channel, arg.Payload)
+	return err
+}
+
 const updateEntryAmount = `-- name: UpdateEntryAmount :exec
 UPDATE entries SET amount = $2 WHERE id = $1 RETURNING id, account_id, amount, created_at
 `
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// Event types that webhook subscriptions can ask for.
+const (
+	EventTransferCreated = "transfer.created"
+	EventAccountCreated  = "account.created"
+	EventAccountFrozen   = "account.frozen"
+	EventAccountUnfrozen = "account.unfrozen"
+	EventAccountClosed   = "account.closed"
+)
+
+var EventTypes = []string{
+	EventTransferCreated,
+	EventAccountCreated,
+	EventAccountFrozen,
+	EventAccountUnfrozen,
+	EventAccountClosed,
+}
+
+// publishEvent writes an event for a user to the outbox and queues a delivery for each of their
+// webhook subscriptions that asked for it. It must run inside the transaction making the change,
+// so the event is recorded if and only if the change commits.
+func publishEvent(ctx context.Context, q Querier, username, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event, err := q.CreateWebhookEvent(ctx, CreateWebhookEventParams{
+		Username:  username,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateWebhookDeliveriesForEvent(ctx, CreateWebhookDeliveriesForEventParams{
+		EventID:   event.ID,
+		Username:  username,
+		EventType: eventType,
+	})
+	return err
+}
+
+// CreateAccountTx opens an account, records it in the audit log and publishes an
+// account.created event. A non-zero
+// opening balance is recorded as an entry, so that the balance always matches the ledger.
+func (t Transactions) CreateAccountTx(ctx context.Context, arg CreateAccountParams) (Account, error) {
+	ctx, span := tracer.Start(ctx, "CreateAccountTx")
+	defer span.End()
+
+	var account Account
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+
+		account, err = q.CreateAccount(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		if arg.Balance.Valid && arg.Balance.Int.Sign() != 0 {
+			_, err = q.CreateEntry(ctx, CreateEntryParams{
+				AccountID: account.ID,
+				Amount:    arg.Balance,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "account.created",
+			ResourceType: "account",
+			ResourceID:   strconv.FormatInt(account.ID, 10),
+			After:        account,
+		})
+		if err != nil {
+			return err
+		}
+
+		return publishEvent(ctx, q, account.Owner, EventAccountCreated, account)
+	})
+
+	recordSpanError(span, err)
+	return account, err
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTxQueuesWebhookDeliveries(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+
+	account1, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+	account2, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+
+	subscription, err := store.CreateWebhookSubscription(ctx, CreateWebhookSubscriptionParams{
+		Username:   account2.Owner,
+		Url:        "http://localhost/hooks",
+		Secret:     "whsec_test",
+		EventTypes: []string{EventTransferCreated},
+	})
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountId: account1.ID,
+		ToAccountId:   account2.ID,
+		Amount:        1,
+	})
+	require.NoError(t, err)
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Limit:          10,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, WebhookDeliveryStatusPending, deliveries[0].Status)
+
+	event, err := store.GetWebhookEvent(ctx, deliveries[0].EventID)
+	require.NoError(t, err)
+	require.Equal(t, EventTransferCreated, event.EventType)
+	require.Equal(t, account2.Owner, event.Username)
+	require.Contains(t, string(event.Payload), `"id":`)
+	require.NotZero(t, result.Transfer.ID)
+}
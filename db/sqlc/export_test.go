@@ -0,0 +1,6 @@
+package db
+
+// NewTestStore returns a Store on the test database, for the tests in package db_test.
+func NewTestStore() Store {
+	return NewStore(testDB)
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// ErrAccountFrozen is returned when a transfer would move money out of or into a frozen account.
+var ErrAccountFrozen = errors.New("account is frozen")
+
+type FreezeAccountTxParams struct {
+	AccountID int64 `json:"account_id"`
+	Frozen    bool  `json:"frozen"`
+}
+
+// FreezeAccountTx freezes or unfreezes an account, records it in the audit log and
+// publishes an account.frozen or account.unfrozen event. Setting the state an account is
+// already in changes nothing and records nothing.
+func (t Transactions) FreezeAccountTx(ctx context.Context, arg FreezeAccountTxParams) (Account, error) {
+	ctx, span := tracer.Start(ctx, "FreezeAccountTx")
+	defer span.End()
+
+	var account Account
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		before, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		account = before
+		if err != nil || account.Frozen == arg.Frozen {
+			return err
+		}
+
+		account, err = q.SetAccountFrozen(ctx, SetAccountFrozenParams{
+			ID:     arg.AccountID,
+			Frozen: arg.Frozen,
+		})
+		if err != nil {
+			return err
+		}
+
+		eventType := EventAccountUnfrozen
+		if arg.Frozen {
+			eventType = EventAccountFrozen
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       eventType,
+			ResourceType: "account",
+			ResourceID:   strconv.FormatInt(account.ID, 10),
+			Before:       before,
+			After:        account,
+		})
+		if err != nil {
+			return err
+		}
+		return publishEvent(ctx, q, account.Owner, eventType, account)
+	})
+
+	recordSpanError(span, err)
+	return account, err
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeAccountTx(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+
+	account1, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+	account2, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+
+	frozen, err := store.FreezeAccountTx(ctx, FreezeAccountTxParams{AccountID: account1.ID, Frozen: true})
+	require.NoError(t, err)
+	require.True(t, frozen.Frozen)
+
+	// freezing again is a no-op
+	frozen, err = store.FreezeAccountTx(ctx, FreezeAccountTxParams{AccountID: account1.ID, Frozen: true})
+	require.NoError(t, err)
+	require.True(t, frozen.Frozen)
+
+	_, err = store.TransferTx(ctx, TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 1})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+	_, err = store.TransferTx(ctx, TransferTxParams{FromAccountId: account2.ID, ToAccountId: account1.ID, Amount: 1})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+
+	// the failed transfers rolled back
+	unchanged, err := testQueries.GetAccount(ctx, account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, 0, NumericToRat(account1.Balance).Cmp(NumericToRat(unchanged.Balance)))
+
+	unfrozen, err := store.FreezeAccountTx(ctx, FreezeAccountTxParams{AccountID: account1.ID, Frozen: false})
+	require.NoError(t, err)
+	require.False(t, unfrozen.Frozen)
+
+	_, err = store.TransferTx(ctx, TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 1})
+	require.NoError(t, err)
+}
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+
+	account1, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+	account2, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+
+	original, err := store.TransferTx(ctx, TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10})
+	require.NoError(t, err)
+
+	// a frozen account does not block returning the money
+	_, err = store.FreezeAccountTx(ctx, FreezeAccountTxParams{AccountID: account2.ID, Frozen: true})
+	require.NoError(t, err)
+
+	reversal, err := store.ReverseTransferTx(ctx, original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.ID, reversal.Transfer.FromAccountID)
+	require.Equal(t, account1.ID, reversal.Transfer.ToAccountID)
+	require.True(t, reversal.Transfer.ReversalOf.Valid)
+	require.Equal(t, original.Transfer.ID, reversal.Transfer.ReversalOf.Int64)
+	require.Equal(t, 0, NumericToRat(account1.Balance).Cmp(NumericToRat(reversal.ToAccount.Balance)))
+	require.Equal(t, 0, NumericToRat(account2.Balance).Cmp(NumericToRat(reversal.FromAccount.Balance)))
+
+	_, err = store.ReverseTransferTx(ctx, original.Transfer.ID)
+	require.ErrorIs(t, err, ErrTransferReversed)
+
+	_, err = store.ReverseTransferTx(ctx, reversal.Transfer.ID)
+	require.ErrorIs(t, err, ErrReversalNotReversible)
+}
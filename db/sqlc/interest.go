@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// accrualPlaces is the precision daily accruals are kept at, so that rounding to cents only happens once at posting.
+const accrualPlaces = 10
+
+// DayCountFraction returns the fraction of a year that accrues on the given calendar day.
+//
+// The actual conventions accrue every calendar day over a 365 day, 360 day or actual-length year.
+// 30/360 treats every month as 30 days: the 31st accrues nothing and the last day of February
+// accrues the missing days so that each month sums to 30/360.
+func DayCountFraction(convention DayCountConvention, day time.Time) (*big.Rat, error) {
+	switch convention {
+	case DayCountConventionActual365:
+		return big.NewRat(1, 365), nil
+	case DayCountConventionActual360:
+		return big.NewRat(1, 360), nil
+	case DayCountConventionActualActual:
+		return big.NewRat(1, int64(daysInYear(day.Year()))), nil
+	case DayCountConventionThirty360:
+		days := int64(1)
+		switch {
+		case day.Day() == 31:
+			days = 0
+		case day.Month() == time.February && day.Day() == daysInMonth(day):
+			days = int64(30 - day.Day() + 1)
+		}
+		return big.NewRat(days, 360), nil
+	default:
+		return nil, fmt.Errorf("unknown day count convention %q", convention)
+	}
+}
+
+// DailyInterest returns the interest a balance earns on one day at the given annual rate.
+func DailyInterest(balance, annualRate pgtype.Numeric, convention DayCountConvention, day time.Time) (pgtype.Numeric, error) {
+	fraction, err := DayCountFraction(convention, day)
+	if err != nil {
+		return pgtype.Numeric{}, err
+	}
+
+	interest := new(big.Rat).Mul(NumericToRat(balance), NumericToRat(annualRate))
+	interest.Mul(interest, fraction)
+
+	return RatToNumeric(interest, accrualPlaces), nil
+}
+
+type PostInterestTxParams struct {
+	AccountID        int64     `json:"account_id"`
+	ExpenseAccountID int64     `json:"expense_account_id"`
+	Period           time.Time `json:"period"`
+}
+
+type PostInterestTxResult struct {
+	Posting  InterestPosting  `json:"posting"`
+	Transfer TransferTxResult `json:"transfer"`
+	Posted   bool             `json:"posted"`
+}
+
+// PostInterestTx credits an account with the interest accrued during the month containing Period,
+// paid from the house interest-expense account. It is idempotent per account and month.
+func (t Transactions) PostInterestTx(ctx context.Context, arg PostInterestTxParams) (PostInterestTxResult, error) {
+	ctx, span := tracer.Start(ctx, "PostInterestTx")
+	defer span.End()
+
+	var result PostInterestTxResult
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		_, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		start := time.Date(arg.Period.Year(), arg.Period.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodStart := pgtype.Date{Time: start, Valid: true}
+		periodEnd := pgtype.Date{Time: start.AddDate(0, 1, 0), Valid: true}
+
+		_, err = q.GetInterestPosting(ctx, GetInterestPostingParams{
+			AccountID: arg.AccountID,
+			Period:    periodStart,
+		})
+		if err == nil {
+			return nil
+		}
+		if err != pgx.ErrNoRows {
+			return err
+		}
+
+		total, err := q.SumUnpostedInterestAccruals(ctx, SumUnpostedInterestAccrualsParams{
+			AccountID:   arg.AccountID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+		})
+		if err != nil {
+			return err
+		}
+
+		amount := RatToNumeric(NumericToRat(total), 2)
+
+		var transferID pgtype.Int8
+		if amount.Int.Sign() > 0 {
+			result.Transfer, err = moveMoney(ctx, q, arg.ExpenseAccountID, arg.AccountID, amount, false)
+			if err != nil {
+				return err
+			}
+			transferID = pgtype.Int8{Int64: result.Transfer.Transfer.ID, Valid: true}
+		}
+
+		result.Posting, err = q.CreateInterestPosting(ctx, CreateInterestPostingParams{
+			AccountID:  arg.AccountID,
+			Period:     periodStart,
+			Amount:     amount,
+			TransferID: transferID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.MarkInterestAccrualsPosted(ctx, MarkInterestAccrualsPostedParams{
+			PostingID:   pgtype.Int8{Int64: result.Posting.ID, Valid: true},
+			AccountID:   arg.AccountID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "interest.posted",
+			ResourceType: "account",
+			ResourceID:   strconv.FormatInt(arg.AccountID, 10),
+			After:        result.Posting,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Posted = true
+		return nil
+	})
+
+	recordSpanError(span, err)
+	return result, err
+}
+
+// UpsertInterestProductTx sets the rate of an interest product, creating it if need be, and
+// records the change in the audit log.
+func (t Transactions) UpsertInterestProductTx(ctx context.Context, arg UpsertInterestProductParams) (InterestProduct, error) {
+	ctx, span := tracer.Start(ctx, "UpsertInterestProductTx")
+	defer span.End()
+
+	var product InterestProduct
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var before any
+		existing, err := q.GetInterestProduct(ctx, GetInterestProductParams{Currency: arg.Currency, AccountType: arg.AccountType})
+		switch {
+		case err == nil:
+			before = existing
+		case err != pgx.ErrNoRows:
+			return err
+		}
+
+		product, err = q.UpsertInterestProduct(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "interest_product.changed",
+			ResourceType: "interest_product",
+			ResourceID:   strconv.FormatInt(product.ID, 10),
+			Before:       before,
+			After:        product,
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return product, err
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+func daysInMonth(day time.Time) int {
+	return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
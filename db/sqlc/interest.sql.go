@@ -0,0 +1,393 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: interest.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createInterestAccrual = `-- name: CreateInterestAccrual :execrows
+INSERT INTO interest_accruals (
+  account_id, product_id, accrual_date, balance, annual_rate, day_count, amount
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (account_id, accrual_date) DO NOTHING
+`
+
+type CreateInterestAccrualParams struct {
+	AccountID   int64              `json:"account_id"`
+	ProductID   int64              `json:"product_id"`
+	AccrualDate pgtype.Date        `json:"accrual_date"`
+	Balance     pgtype.Numeric     `json:"balance"`
+	AnnualRate  pgtype.Numeric     `json:"annual_rate"`
+	DayCount    DayCountConvention `json:"day_count"`
+	Amount      pgtype.Numeric     `json:"amount"`
+}
+
+func (q *Queries) CreateInterestAccrual(ctx context.Context, arg CreateInterestAccrualParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createInterestAccrual,
+		arg.AccountID,
+		arg.ProductID,
+		arg.AccrualDate,
+		arg.Balance,
+		arg.AnnualRate,
+		arg.DayCount,
+		arg.Amount,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const createInterestPosting = `-- name: CreateInterestPosting :one
+INSERT INTO interest_postings (
+  account_id, period, amount, transfer_id
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING id, account_id, period, amount, transfer_id, created_at
+`
+
+type CreateInterestPostingParams struct {
+	AccountID  int64          `json:"account_id"`
+	Period     pgtype.Date    `json:"period"`
+	Amount     pgtype.Numeric `json:"amount"`
+	TransferID pgtype.Int8    `json:"transfer_id"`
+}
+
+func (q *Queries) CreateInterestPosting(ctx context.Context, arg CreateInterestPostingParams) (InterestPosting, error) {
+	row := q.db.QueryRow(ctx, createInterestPosting,
+		arg.AccountID,
+		arg.Period,
+		arg.Amount,
+		arg.TransferID,
+	)
+	var i InterestPosting
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Period,
+		&i.Amount,
+		&i.TransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getInterestPosting = `-- name: GetInterestPosting :one
+SELECT id, account_id, period, amount, transfer_id, created_at FROM interest_postings
+WHERE account_id = $1 AND period = $2
+LIMIT 1
+`
+
+type GetInterestPostingParams struct {
+	AccountID int64       `json:"account_id"`
+	Period    pgtype.Date `json:"period"`
+}
+
+func (q *Queries) GetInterestPosting(ctx context.Context, arg GetInterestPostingParams) (InterestPosting, error) {
+	row := q.db.QueryRow(ctx, getInterestPosting, arg.AccountID, arg.Period)
+	var i InterestPosting
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Period,
+		&i.Amount,
+		&i.TransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getInterestProduct = `-- name: GetInterestProduct :one
+SELECT id, currency, account_type, annual_rate, day_count, created_at FROM interest_products
+WHERE currency = $1 AND account_type = $2
+LIMIT 1
+`
+
+type GetInterestProductParams struct {
+	Currency    string      `json:"currency"`
+	AccountType AccountType `json:"account_type"`
+}
+
+func (q *Queries) GetInterestProduct(ctx context.Context, arg GetInterestProductParams) (InterestProduct, error) {
+	row := q.db.QueryRow(ctx, getInterestProduct, arg.Currency, arg.AccountType)
+	var i InterestProduct
+	err := row.Scan(
+		&i.ID,
+		&i.Currency,
+		&i.AccountType,
+		&i.AnnualRate,
+		&i.DayCount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLatestInterestAccrualDate = `-- name: GetLatestInterestAccrualDate :one
+SELECT MAX(accrual_date)::date AS accrual_date
+FROM interest_accruals
+`
+
+// The date is NULL, not valid, before the first accrual.
+func (q *Queries) GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error) {
+	row := q.db.QueryRow(ctx, getLatestInterestAccrualDate)
+	var accrual_date pgtype.Date
+	err := row.Scan(&accrual_date)
+	return accrual_date, err
+}
+
+const listInterestAccrualsForAccount = `-- name: ListInterestAccrualsForAccount :many
+SELECT id, account_id, product_id, accrual_date, balance, annual_rate, day_count, amount, posting_id, created_at FROM interest_accruals
+WHERE account_id = $1
+ORDER BY accrual_date
+LIMIT $2
+OFFSET $3
+`
+
+type ListInterestAccrualsForAccountParams struct {
+	AccountID int64 `json:"account_id"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
+}
+
+func (q *Queries) ListInterestAccrualsForAccount(ctx context.Context, arg ListInterestAccrualsForAccountParams) ([]InterestAccrual, error) {
+	rows, err := q.db.Query(ctx, listInterestAccrualsForAccount, arg.AccountID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []InterestAccrual{}
+	for rows.Next() {
+		var i InterestAccrual
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.ProductID,
+			&i.AccrualDate,
+			&i.Balance,
+			&i.AnnualRate,
+			&i.DayCount,
+			&i.Amount,
+			&i.PostingID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInterestBearingAccounts = `-- name: ListInterestBearingAccounts :many
+SELECT accounts.id, accounts.owner, accounts.balance, accounts.currency, accounts.created_at, accounts.overdraft_limit, accounts.account_type, accounts.frozen, accounts.closed_at, interest_products.id, interest_products.currency, interest_products.account_type, interest_products.annual_rate, interest_products.day_count, interest_products.created_at
+FROM accounts
+JOIN interest_products
+  ON interest_products.currency = accounts.currency
+  AND interest_products.account_type = accounts.account_type
+WHERE accounts.balance > 0 AND interest_products.annual_rate > 0
+ORDER BY accounts.id
+`
+
+type ListInterestBearingAccountsRow struct {
+	Account         Account         `json:"account"`
+	InterestProduct InterestProduct `json:"interest_product"`
+}
+
+func (q *Queries) ListInterestBearingAccounts(ctx context.Context) ([]ListInterestBearingAccountsRow, error) {
+	rows, err := q.db.Query(ctx, listInterestBearingAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListInterestBearingAccountsRow{}
+	for rows.Next() {
+		var i ListInterestBearingAccountsRow
+		if err := rows.Scan(
+			&i.Account.ID,
+			&i.Account.Owner,
+			&i.Account.Balance,
+			&i.Account.Currency,
+			&i.Account.CreatedAt,
+			&i.Account.OverdraftLimit,
+			&i.Account.AccountType,
+			&i.Account.Frozen,
+			&i.Account.ClosedAt,
+			&i.InterestProduct.ID,
+			&i.InterestProduct.Currency,
+			&i.InterestProduct.AccountType,
+			&i.InterestProduct.AnnualRate,
+			&i.InterestProduct.DayCount,
+			&i.InterestProduct.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInterestProducts = `-- name: ListInterestProducts :many
+SELECT id, currency, account_type, annual_rate, day_count, created_at FROM interest_products
+ORDER BY currency, account_type
+`
+
+func (q *Queries) ListInterestProducts(ctx context.Context) ([]InterestProduct, error) {
+	rows, err := q.db.Query(ctx, listInterestProducts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []InterestProduct{}
+	for rows.Next() {
+		var i InterestProduct
+		if err := rows.Scan(
+			&i.ID,
+			&i.Currency,
+			&i.AccountType,
+			&i.AnnualRate,
+			&i.DayCount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnpostedInterestPeriods = `-- name: ListUnpostedInterestPeriods :many
+SELECT account_id, date_trunc('month', accrual_date)::date AS period
+FROM interest_accruals
+WHERE posting_id IS NULL AND accrual_date < $1
+GROUP BY account_id, period
+ORDER BY period, account_id
+`
+
+type ListUnpostedInterestPeriodsRow struct {
+	AccountID int64       `json:"account_id"`
+	Period    pgtype.Date `json:"period"`
+}
+
+func (q *Queries) ListUnpostedInterestPeriods(ctx context.Context, before pgtype.Date) ([]ListUnpostedInterestPeriodsRow, error) {
+	rows, err := q.db.Query(ctx, listUnpostedInterestPeriods, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUnpostedInterestPeriodsRow{}
+	for rows.Next() {
+		var i ListUnpostedInterestPeriodsRow
+		if err := rows.Scan(&i.AccountID, &i.Period); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markInterestAccrualsPosted = `-- name: MarkInterestAccrualsPosted :execrows
+UPDATE interest_accruals SET posting_id = $1
+WHERE account_id = $2
+  AND posting_id IS NULL
+  AND accrual_date >= $3
+  AND accrual_date < $4
+`
+
+type MarkInterestAccrualsPostedParams struct {
+	PostingID   pgtype.Int8 `json:"posting_id"`
+	AccountID   int64       `json:"account_id"`
+	PeriodStart pgtype.Date `json:"period_start"`
+	PeriodEnd   pgtype.Date `json:"period_end"`
+}
+
+func (q *Queries) MarkInterestAccrualsPosted(ctx context.Context, arg MarkInterestAccrualsPostedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markInterestAccrualsPosted,
+		arg.PostingID,
+		arg.AccountID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const sumUnpostedInterestAccruals = `-- name: SumUnpostedInterestAccruals :one
+SELECT COALESCE(SUM(amount), 0)::numeric AS total
+FROM interest_accruals
+WHERE account_id = $1
+  AND posting_id IS NULL
+  AND accrual_date >= $2
+  AND accrual_date < $3
+`
+
+type SumUnpostedInterestAccrualsParams struct {
+	AccountID   int64       `json:"account_id"`
+	PeriodStart pgtype.Date `json:"period_start"`
+	PeriodEnd   pgtype.Date `json:"period_end"`
+}
+
+func (q *Queries) SumUnpostedInterestAccruals(ctx context.Context, arg SumUnpostedInterestAccrualsParams) (pgtype.Numeric, error) {
+	row := q.db.QueryRow(ctx, sumUnpostedInterestAccruals, arg.AccountID, arg.PeriodStart, arg.PeriodEnd)
+	var total pgtype.Numeric
+	err := row.Scan(&total)
+	return total, err
+}
+
+const upsertInterestProduct = `-- name: UpsertInterestProduct :one
+INSERT INTO interest_products (
+  currency, account_type, annual_rate, day_count
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (currency, account_type) DO UPDATE
+SET annual_rate = EXCLUDED.annual_rate, day_count = EXCLUDED.day_count
+RETURNING id, currency, account_type, annual_rate, day_count, created_at
+`
+
+type UpsertInterestProductParams struct {
+	Currency    string             `json:"currency"`
+	AccountType AccountType        `json:"account_type"`
+	AnnualRate  pgtype.Numeric     `json:"annual_rate"`
+	DayCount    DayCountConvention `json:"day_count"`
+}
+
+func (q *Queries) UpsertInterestProduct(ctx context.Context, arg UpsertInterestProductParams) (InterestProduct, error) {
+	row := q.db.QueryRow(ctx, upsertInterestProduct,
+		arg.Currency,
+		arg.AccountType,
+		arg.AnnualRate,
+		arg.DayCount,
+	)
+	var i InterestProduct
+	err := row.Scan(
+		&i.ID,
+		&i.Currency,
+		&i.AccountType,
+		&i.AnnualRate,
+		&i.DayCount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
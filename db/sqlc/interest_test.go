@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDayCountFraction(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		require.NoError(t, err)
+		return d
+	}
+
+	testCases := []struct {
+		Name       string
+		Convention DayCountConvention
+		Day        time.Time
+		Expected   *big.Rat
+	}{
+		{"Actual/365", DayCountConventionActual365, date("2024-03-15"), big.NewRat(1, 365)},
+		{"Actual/360", DayCountConventionActual360, date("2024-03-15"), big.NewRat(1, 360)},
+		{"Actual/Actual Leap Year", DayCountConventionActualActual, date("2024-03-15"), big.NewRat(1, 366)},
+		{"Actual/Actual Common Year", DayCountConventionActualActual, date("2025-03-15"), big.NewRat(1, 365)},
+		{"30/360 Ordinary Day", DayCountConventionThirty360, date("2025-03-15"), big.NewRat(1, 360)},
+		{"30/360 Thirty First", DayCountConventionThirty360, date("2025-03-31"), big.NewRat(0, 360)},
+		{"30/360 End Of February", DayCountConventionThirty360, date("2025-02-28"), big.NewRat(3, 360)},
+		{"30/360 End Of Leap February", DayCountConventionThirty360, date("2024-02-29"), big.NewRat(2, 360)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			fraction, err := DayCountFraction(tc.Convention, tc.Day)
+			require.NoError(t, err)
+			require.Zero(t, tc.Expected.Cmp(fraction))
+		})
+	}
+
+	_, err := DayCountFraction("bogus", date("2025-03-15"))
+	require.Error(t, err)
+}
+
+func TestThirty360AccruesThirtyDaysPerMonth(t *testing.T) {
+	for month := time.January; month <= time.December; month++ {
+		total := new(big.Rat)
+		for day := time.Date(2025, month, 1, 0, 0, 0, 0, time.UTC); day.Month() == month; day = day.AddDate(0, 0, 1) {
+			fraction, err := DayCountFraction(DayCountConventionThirty360, day)
+			require.NoError(t, err)
+			total.Add(total, fraction)
+		}
+		require.Zero(t, big.NewRat(30, 360).Cmp(total), month.String())
+	}
+}
+
+func TestPostInterestTx(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+
+	account, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+	expense, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+
+	product, err := store.UpsertInterestProduct(ctx, UpsertInterestProductParams{
+		Currency:    account.Currency,
+		AccountType: account.AccountType,
+		AnnualRate:  mustNumeric("0.05"),
+		DayCount:    DayCountConventionActual360,
+	})
+	require.NoError(t, err)
+
+	period := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		n, err := store.CreateInterestAccrual(ctx, CreateInterestAccrualParams{
+			AccountID:   account.ID,
+			ProductID:   product.ID,
+			AccrualDate: pgtype.Date{Time: period.AddDate(0, 0, i), Valid: true},
+			Balance:     account.Balance,
+			AnnualRate:  product.AnnualRate,
+			DayCount:    product.DayCount,
+			Amount:      mustNumeric("0.3333333333"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+	}
+
+	// accruing the same day twice records nothing
+	n, err := store.CreateInterestAccrual(ctx, CreateInterestAccrualParams{
+		AccountID:   account.ID,
+		ProductID:   product.ID,
+		AccrualDate: pgtype.Date{Time: period, Valid: true},
+		Balance:     account.Balance,
+		AnnualRate:  product.AnnualRate,
+		DayCount:    product.DayCount,
+		Amount:      mustNumeric("0.3333333333"),
+	})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	arg := PostInterestTxParams{
+		AccountID:        account.ID,
+		ExpenseAccountID: expense.ID,
+		Period:           period.AddDate(0, 0, 10),
+	}
+
+	result, err := store.PostInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.True(t, result.Posted)
+	require.Zero(t, big.NewRat(1, 1).Cmp(NumericToRat(result.Posting.Amount)))
+	require.Equal(t, expense.ID, result.Transfer.Transfer.FromAccountID)
+	require.Equal(t, account.ID, result.Transfer.Transfer.ToAccountID)
+	require.Equal(t, mustAdd(account.Balance, 1), result.Transfer.ToAccount.Balance)
+
+	again, err := store.PostInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.False(t, again.Posted)
+}
@@ -0,0 +1,119 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: login_failures.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteLoginFailure = `-- name: DeleteLoginFailure :execrows
+DELETE FROM login_failures
+WHERE scope = $1 AND key = $2
+`
+
+type DeleteLoginFailureParams struct {
+	Scope LoginFailureScope `json:"scope"`
+	Key   string            `json:"key"`
+}
+
+func (q *Queries) DeleteLoginFailure(ctx context.Context, arg DeleteLoginFailureParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteLoginFailure, arg.Scope, arg.Key)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getLoginFailure = `-- name: GetLoginFailure :one
+SELECT scope, key, failures, last_failed_at, locked_until FROM login_failures
+WHERE scope = $1 AND key = $2
+`
+
+type GetLoginFailureParams struct {
+	Scope LoginFailureScope `json:"scope"`
+	Key   string            `json:"key"`
+}
+
+func (q *Queries) GetLoginFailure(ctx context.Context, arg GetLoginFailureParams) (LoginFailure, error) {
+	row := q.db.QueryRow(ctx, getLoginFailure, arg.Scope, arg.Key)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const lockLogin = `-- name: LockLogin :one
+UPDATE login_failures
+SET locked_until = $3
+WHERE scope = $1 AND key = $2
+RETURNING scope, key, failures, last_failed_at, locked_until
+`
+
+type LockLoginParams struct {
+	Scope       LoginFailureScope  `json:"scope"`
+	Key         string             `json:"key"`
+	LockedUntil pgtype.Timestamptz `json:"locked_until"`
+}
+
+func (q *Queries) LockLogin(ctx context.Context, arg LockLoginParams) (LoginFailure, error) {
+	row := q.db.QueryRow(ctx, lockLogin, arg.Scope, arg.Key, arg.LockedUntil)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_failures (
+  scope, key, failures
+) VALUES (
+  $1, $2, 1
+)
+ON CONFLICT (scope, key) DO UPDATE
+SET failures = CASE
+      WHEN login_failures.last_failed_at < $3 OR login_failures.locked_until <= now() THEN 1
+      ELSE login_failures.failures + 1
+    END,
+    locked_until = CASE
+      WHEN login_failures.locked_until <= now() THEN NULL
+      ELSE login_failures.locked_until
+    END,
+    last_failed_at = now()
+RETURNING scope, key, failures, last_failed_at, locked_until
+`
+
+type RecordLoginFailureParams struct {
+	Scope       LoginFailureScope  `json:"scope"`
+	Key         string             `json:"key"`
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+}
+
+// The count starts over once the failures before are older than window_start or the
+// lockout they led to has ended.
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error) {
+	row := q.db.QueryRow(ctx, recordLoginFailure, arg.Scope, arg.Key, arg.WindowStart)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Scope,
+		&i.Key,
+		&i.Failures,
+		&i.LastFailedAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
@@ -5,15 +5,494 @@
 package db
 
 import (
+	"database/sql/driver"
+	"fmt"
+
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccountType string
+
+const (
+	AccountTypeChecking AccountType = "checking"
+	AccountTypeSavings  AccountType = "savings"
+	AccountTypeBusiness AccountType = "business"
+)
+
+func (e *AccountType) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AccountType(s)
+	case string:
+		*e = AccountType(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AccountType: %T", src)
+	}
+	return nil
+}
+
+type NullAccountType struct {
+	AccountType AccountType `json:"account_type"`
+	Valid       bool        `json:"valid"` // Valid is true if AccountType is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAccountType) Scan(value interface{}) error {
+	if value == nil {
+		ns.AccountType, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AccountType.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAccountType) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AccountType), nil
+}
+
+func (e AccountType) Valid() bool {
+	switch e {
+	case AccountTypeChecking,
+		AccountTypeSavings,
+		AccountTypeBusiness:
+		return true
+	}
+	return false
+}
+
+func AllAccountTypeValues() []AccountType {
+	return []AccountType{
+		AccountTypeChecking,
+		AccountTypeSavings,
+		AccountTypeBusiness,
+	}
+}
+
+type DataRequestKind string
+
+const (
+	DataRequestKindErasure DataRequestKind = "erasure"
+)
+
+func (e *DataRequestKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DataRequestKind(s)
+	case string:
+		*e = DataRequestKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DataRequestKind: %T", src)
+	}
+	return nil
+}
+
+type NullDataRequestKind struct {
+	DataRequestKind DataRequestKind `json:"data_request_kind"`
+	Valid           bool            `json:"valid"` // Valid is true if DataRequestKind is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDataRequestKind) Scan(value interface{}) error {
+	if value == nil {
+		ns.DataRequestKind, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DataRequestKind.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDataRequestKind) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DataRequestKind), nil
+}
+
+func (e DataRequestKind) Valid() bool {
+	switch e {
+	case DataRequestKindErasure:
+		return true
+	}
+	return false
+}
+
+func AllDataRequestKindValues() []DataRequestKind {
+	return []DataRequestKind{
+		DataRequestKindErasure,
+	}
+}
+
+type DataRequestStatus string
+
+const (
+	DataRequestStatusPending  DataRequestStatus = "pending"
+	DataRequestStatusApproved DataRequestStatus = "approved"
+	DataRequestStatusRejected DataRequestStatus = "rejected"
+)
+
+func (e *DataRequestStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DataRequestStatus(s)
+	case string:
+		*e = DataRequestStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DataRequestStatus: %T", src)
+	}
+	return nil
+}
+
+type NullDataRequestStatus struct {
+	DataRequestStatus DataRequestStatus `json:"data_request_status"`
+	Valid             bool              `json:"valid"` // Valid is true if DataRequestStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDataRequestStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.DataRequestStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DataRequestStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDataRequestStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DataRequestStatus), nil
+}
+
+func (e DataRequestStatus) Valid() bool {
+	switch e {
+	case DataRequestStatusPending,
+		DataRequestStatusApproved,
+		DataRequestStatusRejected:
+		return true
+	}
+	return false
+}
+
+func AllDataRequestStatusValues() []DataRequestStatus {
+	return []DataRequestStatus{
+		DataRequestStatusPending,
+		DataRequestStatusApproved,
+		DataRequestStatusRejected,
+	}
+}
+
+type DayCountConvention string
+
+const (
+	DayCountConventionActual365    DayCountConvention = "actual_365"
+	DayCountConventionActual360    DayCountConvention = "actual_360"
+	DayCountConventionActualActual DayCountConvention = "actual_actual"
+	DayCountConventionThirty360    DayCountConvention = "thirty_360"
+)
+
+func (e *DayCountConvention) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DayCountConvention(s)
+	case string:
+		*e = DayCountConvention(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DayCountConvention: %T", src)
+	}
+	return nil
+}
+
+type NullDayCountConvention struct {
+	DayCountConvention DayCountConvention `json:"day_count_convention"`
+	Valid              bool               `json:"valid"` // Valid is true if DayCountConvention is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDayCountConvention) Scan(value interface{}) error {
+	if value == nil {
+		ns.DayCountConvention, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DayCountConvention.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDayCountConvention) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DayCountConvention), nil
+}
+
+func (e DayCountConvention) Valid() bool {
+	switch e {
+	case DayCountConventionActual365,
+		DayCountConventionActual360,
+		DayCountConventionActualActual,
+		DayCountConventionThirty360:
+		return true
+	}
+	return false
+}
+
+func AllDayCountConventionValues() []DayCountConvention {
+	return []DayCountConvention{
+		DayCountConventionActual365,
+		DayCountConventionActual360,
+		DayCountConventionActualActual,
+		DayCountConventionThirty360,
+	}
+}
+
+type LoginFailureScope string
+
+const (
+	LoginFailureScopeUsername           LoginFailureScope = "username"
+	LoginFailureScopeIp                 LoginFailureScope = "ip"
+	LoginFailureScopePasswordResetEmail LoginFailureScope = "password_reset_email"
+	LoginFailureScopePasswordResetIp    LoginFailureScope = "password_reset_ip"
+)
+
+func (e *LoginFailureScope) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = LoginFailureScope(s)
+	case string:
+		*e = LoginFailureScope(s)
+	default:
+		return fmt.Errorf("unsupported scan type for LoginFailureScope: %T", src)
+	}
+	return nil
+}
+
+type NullLoginFailureScope struct {
+	LoginFailureScope LoginFailureScope `json:"login_failure_scope"`
+	Valid             bool              `json:"valid"` // Valid is true if LoginFailureScope is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullLoginFailureScope) Scan(value interface{}) error {
+	if value == nil {
+		ns.LoginFailureScope, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.LoginFailureScope.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullLoginFailureScope) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.LoginFailureScope), nil
+}
+
+func (e LoginFailureScope) Valid() bool {
+	switch e {
+	case LoginFailureScopeUsername,
+		LoginFailureScopeIp,
+		LoginFailureScopePasswordResetEmail,
+		LoginFailureScopePasswordResetIp:
+		return true
+	}
+	return false
+}
+
+func AllLoginFailureScopeValues() []LoginFailureScope {
+	return []LoginFailureScope{
+		LoginFailureScopeUsername,
+		LoginFailureScopeIp,
+		LoginFailureScopePasswordResetEmail,
+		LoginFailureScopePasswordResetIp,
+	}
+}
+
+type UserRole string
+
+const (
+	UserRoleAdmin    UserRole = "admin"
+	UserRoleTeller   UserRole = "teller"
+	UserRoleCustomer UserRole = "customer"
+	UserRoleAuditor  UserRole = "auditor"
+)
+
+func (e *UserRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UserRole(s)
+	case string:
+		*e = UserRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UserRole: %T", src)
+	}
+	return nil
+}
+
+type NullUserRole struct {
+	UserRole UserRole `json:"user_role"`
+	Valid    bool     `json:"valid"` // Valid is true if UserRole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUserRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.UserRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UserRole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUserRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.UserRole), nil
+}
+
+func (e UserRole) Valid() bool {
+	switch e {
+	case UserRoleAdmin,
+		UserRoleTeller,
+		UserRoleCustomer,
+		UserRoleAuditor:
+		return true
+	}
+	return false
+}
+
+func AllUserRoleValues() []UserRole {
+	return []UserRole{
+		UserRoleAdmin,
+		UserRoleTeller,
+		UserRoleCustomer,
+		UserRoleAuditor,
+	}
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "dead"
+)
+
+func (e *WebhookDeliveryStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = WebhookDeliveryStatus(s)
+	case string:
+		*e = WebhookDeliveryStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for WebhookDeliveryStatus: %T", src)
+	}
+	return nil
+}
+
+type NullWebhookDeliveryStatus struct {
+	WebhookDeliveryStatus WebhookDeliveryStatus `json:"webhook_delivery_status"`
+	Valid                 bool                  `json:"valid"` // Valid is true if WebhookDeliveryStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullWebhookDeliveryStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.WebhookDeliveryStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.WebhookDeliveryStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullWebhookDeliveryStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.WebhookDeliveryStatus), nil
+}
+
+func (e WebhookDeliveryStatus) Valid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending,
+		WebhookDeliveryStatusSucceeded,
+		WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+func AllWebhookDeliveryStatusValues() []WebhookDeliveryStatus {
+	return []WebhookDeliveryStatus{
+		WebhookDeliveryStatusPending,
+		WebhookDeliveryStatusSucceeded,
+		WebhookDeliveryStatusDead,
+	}
+}
+
 type Account struct {
-	ID        int64              `json:"id"`
-	Owner     string             `json:"owner"`
-	Balance   pgtype.Numeric     `json:"balance"`
-	Currency  string             `json:"currency"`
-	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ID             int64              `json:"id"`
+	Owner          string             `json:"owner"`
+	Balance        pgtype.Numeric     `json:"balance"`
+	Currency       string             `json:"currency"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	OverdraftLimit pgtype.Numeric     `json:"overdraft_limit"`
+	AccountType    AccountType        `json:"account_type"`
+	Frozen         bool               `json:"frozen"`
+	ClosedAt       pgtype.Timestamptz `json:"closed_at"`
+}
+
+type ApiKey struct {
+	ID            int64              `json:"id"`
+	Username      string             `json:"username"`
+	Name          string             `json:"name"`
+	KeyHash       string             `json:"key_hash"`
+	Prefix        string             `json:"prefix"`
+	Scopes        []string           `json:"scopes"`
+	AllowedIps    []string           `json:"allowed_ips"`
+	ExpiresAt     pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt    pgtype.Timestamptz `json:"last_used_at"`
+	RevokedAt     pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	SigningSecret pgtype.Text        `json:"signing_secret"`
+}
+
+type AuditLog struct {
+	ID           int64              `json:"id"`
+	ActorType    string             `json:"actor_type"`
+	Actor        pgtype.Text        `json:"actor"`
+	ApiKeyID     pgtype.Int8        `json:"api_key_id"`
+	Action       string             `json:"action"`
+	ResourceType string             `json:"resource_type"`
+	ResourceID   string             `json:"resource_id"`
+	ClientIp     pgtype.Text        `json:"client_ip"`
+	RequestID    pgtype.Text        `json:"request_id"`
+	Before       []byte             `json:"before"`
+	After        []byte             `json:"after"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type DataRequest struct {
+	ID         int64              `json:"id"`
+	Username   string             `json:"username"`
+	Kind       DataRequestKind    `json:"kind"`
+	Status     DataRequestStatus  `json:"status"`
+	Reason     string             `json:"reason"`
+	ReviewedBy pgtype.Text        `json:"reviewed_by"`
+	ReviewNote string             `json:"review_note"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	ReviewedAt pgtype.Timestamptz `json:"reviewed_at"`
 }
 
 type Entry struct {
@@ -23,12 +502,91 @@ type Entry struct {
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
+type InterestAccrual struct {
+	ID          int64              `json:"id"`
+	AccountID   int64              `json:"account_id"`
+	ProductID   int64              `json:"product_id"`
+	AccrualDate pgtype.Date        `json:"accrual_date"`
+	Balance     pgtype.Numeric     `json:"balance"`
+	AnnualRate  pgtype.Numeric     `json:"annual_rate"`
+	DayCount    DayCountConvention `json:"day_count"`
+	Amount      pgtype.Numeric     `json:"amount"`
+	PostingID   pgtype.Int8        `json:"posting_id"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type InterestPosting struct {
+	ID         int64              `json:"id"`
+	AccountID  int64              `json:"account_id"`
+	Period     pgtype.Date        `json:"period"`
+	Amount     pgtype.Numeric     `json:"amount"`
+	TransferID pgtype.Int8        `json:"transfer_id"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type InterestProduct struct {
+	ID          int64              `json:"id"`
+	Currency    string             `json:"currency"`
+	AccountType AccountType        `json:"account_type"`
+	AnnualRate  pgtype.Numeric     `json:"annual_rate"`
+	DayCount    DayCountConvention `json:"day_count"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type LoginFailure struct {
+	Scope        LoginFailureScope  `json:"scope"`
+	Key          string             `json:"key"`
+	Failures     int32              `json:"failures"`
+	LastFailedAt pgtype.Timestamptz `json:"last_failed_at"`
+	LockedUntil  pgtype.Timestamptz `json:"locked_until"`
+}
+
+type OverdraftCharge struct {
+	ID         int64              `json:"id"`
+	AccountID  int64              `json:"account_id"`
+	EntryID    int64              `json:"entry_id"`
+	ChargeDate pgtype.Date        `json:"charge_date"`
+	Balance    pgtype.Numeric     `json:"balance"`
+	AnnualRate pgtype.Numeric     `json:"annual_rate"`
+	Amount     pgtype.Numeric     `json:"amount"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type PasswordHistory struct {
+	ID           int64              `json:"id"`
+	Username     string             `json:"username"`
+	PasswordHash string             `json:"password_hash"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	TokenHash string             `json:"token_hash"`
+	Username  string             `json:"username"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type RequestNonce struct {
+	ApiKeyID  int64              `json:"api_key_id"`
+	Nonce     string             `json:"nonce"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+type TotpBackupCode struct {
+	ID        int64              `json:"id"`
+	Username  string             `json:"username"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Transfer struct {
 	ID            int64              `json:"id"`
 	FromAccountID int64              `json:"from_account_id"`
 	ToAccountID   int64              `json:"to_account_id"`
 	Amount        pgtype.Numeric     `json:"amount"`
 	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	ReversalOf    pgtype.Int8        `json:"reversal_of"`
 }
 
 type User struct {
@@ -38,4 +596,56 @@ type User struct {
 	Email             string             `json:"email"`
 	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
 	CraetedAt         pgtype.Timestamptz `json:"craeted_at"`
+	Role              UserRole           `json:"role"`
+	TotpSecret        []byte             `json:"totp_secret"`
+	TotpEnabledAt     pgtype.Timestamptz `json:"totp_enabled_at"`
+	TotpLastStep      pgtype.Int8        `json:"totp_last_step"`
+	EmailVerifiedAt   pgtype.Timestamptz `json:"email_verified_at"`
+	DataKey           []byte             `json:"data_key"`
+	DataKeyID         pgtype.Text        `json:"data_key_id"`
+	EmailIndex        []byte             `json:"email_index"`
+	ErasedAt          pgtype.Timestamptz `json:"erased_at"`
+	TokensRevokedAt   pgtype.Timestamptz `json:"tokens_revoked_at"`
+}
+
+type VerifyEmail struct {
+	ID        int64              `json:"id"`
+	Username  string             `json:"username"`
+	Email     string             `json:"email"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	DataKey   []byte             `json:"data_key"`
+	DataKeyID pgtype.Text        `json:"data_key_id"`
+}
+
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	EventID        int64                 `json:"event_id"`
+	SubscriptionID int64                 `json:"subscription_id"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int32                 `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamptz    `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4           `json:"last_status_code"`
+	LastError      pgtype.Text           `json:"last_error"`
+	DeliveredAt    pgtype.Timestamptz    `json:"delivered_at"`
+	CreatedAt      pgtype.Timestamptz    `json:"created_at"`
+}
+
+type WebhookEvent struct {
+	ID        int64              `json:"id"`
+	Username  string             `json:"username"`
+	EventType string             `json:"event_type"`
+	Payload   []byte             `json:"payload"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type WebhookSubscription struct {
+	ID         int64              `json:"id"`
+	Username   string             `json:"username"`
+	Url        string             `json:"url"`
+	Secret     string             `json:"secret"`
+	EventTypes []string           `json:"event_types"`
+	Active     bool               `json:"active"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
 }
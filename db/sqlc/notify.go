@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// AccountEventsChannel is the Postgres channel that carries an AccountEvent for every new entry.
+const AccountEventsChannel = "account_events"
+
+// AccountEvent tells listeners that an entry was added to an account and its balance changed.
+type AccountEvent struct {
+	AccountID int64 `json:"account_id"`
+	EntryID   int64 `json:"entry_id"`
+}
+
+// notifyEntryCreated queues an AccountEvent for the entry. Postgres holds notifications back until
+// the surrounding transaction commits and drops them on rollback, so listeners never hear about
+// entries they cannot read yet.
+func notifyEntryCreated(ctx context.Context, q Querier, entry Entry) error {
+	payload, err := json.Marshal(AccountEvent{
+		AccountID: entry.AccountID,
+		EntryID:   entry.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.NotifyAccountEvent(ctx, NotifyAccountEventParams{
+		Channel: AccountEventsChannel,
+		Payload: string(payload),
+	})
+}
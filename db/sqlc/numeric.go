@@ -0,0 +1,63 @@
+package db
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NumericToRat converts a valid numeric into an exact rational. A NULL numeric converts to zero.
+func NumericToRat(n pgtype.Numeric) *big.Rat {
+	if !n.Valid || n.Int == nil {
+		return new(big.Rat)
+	}
+
+	r := new(big.Rat).SetInt(n.Int)
+	if n.Exp == 0 {
+		return r
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs32(n.Exp))), nil)
+	if n.Exp > 0 {
+		return r.Mul(r, new(big.Rat).SetInt(scale))
+	}
+	return r.Quo(r, new(big.Rat).SetInt(scale))
+}
+
+// RatToNumeric rounds r half away from zero to the given number of decimal places.
+func RatToNumeric(r *big.Rat, places int32) pgtype.Numeric {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+
+	num := new(big.Int).Abs(scaled.Num())
+	quo, rem := new(big.Int).QuoRem(num, scaled.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		quo.Neg(quo)
+	}
+
+	return pgtype.Numeric{
+		Int:   quo,
+		Exp:   -places,
+		Valid: true,
+	}
+}
+
+// AddNumeric returns a + b. The result is NULL if either operand is NULL.
+func AddNumeric(a, b pgtype.Numeric) pgtype.Numeric {
+	if !a.Valid || !b.Valid {
+		return pgtype.Numeric{Valid: false}
+	}
+
+	places := -min(a.Exp, b.Exp, 0)
+	return RatToNumeric(new(big.Rat).Add(NumericToRat(a), NumericToRat(b)), places)
+}
+
+func abs32(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
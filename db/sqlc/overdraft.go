@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type ChargeOverdraftInterestTxParams struct {
+	AccountID  int64          `json:"account_id"`
+	ChargeDate time.Time      `json:"charge_date"`
+	AnnualRate pgtype.Numeric `json:"annual_rate"`
+}
+
+type ChargeOverdraftInterestTxResult struct {
+	Charge  OverdraftCharge `json:"charge"`
+	Entry   Entry           `json:"entry"`
+	Account Account         `json:"account"`
+	Charged bool            `json:"charged"`
+}
+
+// ChargeOverdraftInterestTx debits one day of overdraft interest from an overdrawn account.
+// The charge never takes the account past its overdraft limit: it is cut to what the limit
+// leaves, so an account already at its limit is charged nothing.
+// It is idempotent per account and day: a second call for the same date charges nothing.
+func (t Transactions) ChargeOverdraftInterestTx(ctx context.Context, arg ChargeOverdraftInterestTxParams) (ChargeOverdraftInterestTxResult, error) {
+	ctx, span := tracer.Start(ctx, "ChargeOverdraftInterestTx")
+	defer span.End()
+
+	var result ChargeOverdraftInterestTxResult
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+
+		result.Account, err = q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		y, m, d := arg.ChargeDate.Date()
+		chargeDate := pgtype.Date{Time: time.Date(y, m, d, 0, 0, 0, 0, time.UTC), Valid: true}
+
+		_, err = q.GetOverdraftCharge(ctx, GetOverdraftChargeParams{
+			AccountID:  arg.AccountID,
+			ChargeDate: chargeDate,
+		})
+		if err == nil {
+			return nil
+		}
+		if err != pgx.ErrNoRows {
+			return err
+		}
+
+		amount := OverdraftInterest(result.Account.Balance, arg.AnnualRate)
+		headroom := new(big.Rat).Add(NumericToRat(result.Account.Balance), NumericToRat(result.Account.OverdraftLimit))
+		if NumericToRat(amount).Cmp(headroom) > 0 {
+			amount = RatToNumeric(headroom, 2)
+		}
+		if amount.Int.Sign() <= 0 {
+			return nil
+		}
+
+		result.Entry, err = q.CreateEntry(ctx, CreateEntryParams{
+			AccountID: arg.AccountID,
+			Amount: pgtype.Numeric{
+				Int:   new(big.Int).Neg(amount.Int),
+				Exp:   amount.Exp,
+				Valid: true,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		before := result.Account
+		result.Account, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     arg.AccountID,
+			Amount: result.Entry.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Charge, err = q.CreateOverdraftCharge(ctx, CreateOverdraftChargeParams{
+			AccountID:  arg.AccountID,
+			EntryID:    result.Entry.ID,
+			ChargeDate: chargeDate,
+			Balance:    before.Balance,
+			AnnualRate: arg.AnnualRate,
+			Amount:     amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "overdraft_interest.charged",
+			ResourceType: "account",
+			ResourceID:   strconv.FormatInt(arg.AccountID, 10),
+			Before:       before,
+			After:        result,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Charged = true
+		return notifyEntryCreated(ctx, q, result.Entry)
+	})
+
+	recordSpanError(span, err)
+	return result, err
+}
+
+// UpdateOverdraftLimitTx sets how far an account may be overdrawn and records the change in
+// the audit log.
+func (t Transactions) UpdateOverdraftLimitTx(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error) {
+	ctx, span := tracer.Start(ctx, "UpdateOverdraftLimitTx")
+	defer span.End()
+
+	var account Account
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		before, err := q.GetAccountForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		account, err = q.UpdateAccountOverdraftLimit(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "account.overdraft_limit_changed",
+			ResourceType: "account",
+			ResourceID:   strconv.FormatInt(account.ID, 10),
+			Before:       before,
+			After:        account,
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return account, err
+}
+
+// OverdraftInterest returns one day of interest on a negative balance at the given annual rate,
+// using an actual/365 day count and rounding to cents. Non-negative balances accrue nothing.
+func OverdraftInterest(balance, annualRate pgtype.Numeric) pgtype.Numeric {
+	owed := new(big.Rat).Neg(NumericToRat(balance))
+	if owed.Sign() <= 0 {
+		return RatToNumeric(new(big.Rat), 2)
+	}
+
+	interest := owed.Mul(owed, NumericToRat(annualRate))
+	interest.Quo(interest, big.NewRat(365, 1))
+
+	return RatToNumeric(interest, 2)
+}
@@ -0,0 +1,123 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: overdraft_charges.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createOverdraftCharge = `-- name: CreateOverdraftCharge :one
+INSERT INTO overdraft_charges (
+  account_id, entry_id, charge_date, balance, annual_rate, amount
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING id, account_id, entry_id, charge_date, balance, annual_rate, amount, created_at
+`
+
+type CreateOverdraftChargeParams struct {
+	AccountID  int64          `json:"account_id"`
+	EntryID    int64          `json:"entry_id"`
+	ChargeDate pgtype.Date    `json:"charge_date"`
+	Balance    pgtype.Numeric `json:"balance"`
+	AnnualRate pgtype.Numeric `json:"annual_rate"`
+	Amount     pgtype.Numeric `json:"amount"`
+}
+
+func (q *Queries) CreateOverdraftCharge(ctx context.Context, arg CreateOverdraftChargeParams) (OverdraftCharge, error) {
+	row := q.db.QueryRow(ctx, createOverdraftCharge,
+		arg.AccountID,
+		arg.EntryID,
+		arg.ChargeDate,
+		arg.Balance,
+		arg.AnnualRate,
+		arg.Amount,
+	)
+	var i OverdraftCharge
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.EntryID,
+		&i.ChargeDate,
+		&i.Balance,
+		&i.AnnualRate,
+		&i.Amount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOverdraftCharge = `-- name: GetOverdraftCharge :one
+SELECT id, account_id, entry_id, charge_date, balance, annual_rate, amount, created_at FROM overdraft_charges
+WHERE account_id = $1 AND charge_date = $2
+LIMIT 1
+`
+
+type GetOverdraftChargeParams struct {
+	AccountID  int64       `json:"account_id"`
+	ChargeDate pgtype.Date `json:"charge_date"`
+}
+
+func (q *Queries) GetOverdraftCharge(ctx context.Context, arg GetOverdraftChargeParams) (OverdraftCharge, error) {
+	row := q.db.QueryRow(ctx, getOverdraftCharge, arg.AccountID, arg.ChargeDate)
+	var i OverdraftCharge
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.EntryID,
+		&i.ChargeDate,
+		&i.Balance,
+		&i.AnnualRate,
+		&i.Amount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listOverdraftChargesForAccount = `-- name: ListOverdraftChargesForAccount :many
+SELECT id, account_id, entry_id, charge_date, balance, annual_rate, amount, created_at FROM overdraft_charges
+WHERE account_id = $1
+ORDER BY charge_date
+LIMIT $2
+OFFSET $3
+`
+
+type ListOverdraftChargesForAccountParams struct {
+	AccountID int64 `json:"account_id"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
+}
+
+func (q *Queries) ListOverdraftChargesForAccount(ctx context.Context, arg ListOverdraftChargesForAccountParams) ([]OverdraftCharge, error) {
+	rows, err := q.db.Query(ctx, listOverdraftChargesForAccount, arg.AccountID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []OverdraftCharge{}
+	for rows.Next() {
+		var i OverdraftCharge
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.EntryID,
+			&i.ChargeDate,
+			&i.Balance,
+			&i.AnnualRate,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverdraftInterest(t *testing.T) {
+	rate := mustNumeric("0.18")
+
+	testCases := []struct {
+		Name     string
+		Balance  string
+		Expected string
+	}{
+		{Name: "Positive Balance", Balance: "100.00", Expected: "0"},
+		{Name: "Zero Balance", Balance: "0", Expected: "0"},
+		{Name: "Overdrawn", Balance: "-1000.00", Expected: "0.49"},
+		{Name: "Rounds Half Up", Balance: "-2027.78", Expected: "1.00"},
+		{Name: "Tiny Overdraft", Balance: "-0.01", Expected: "0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			interest := OverdraftInterest(mustNumeric(tc.Balance), rate)
+			require.Zero(t, NumericToRat(mustNumeric(tc.Expected)).Cmp(NumericToRat(interest)))
+		})
+	}
+}
+
+func TestTransferTxOverdraft(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+
+	account1, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+	account2, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+
+	account1, err = store.UpdateAccountOverdraftLimit(ctx, UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: pgtype.Numeric{Int: big.NewInt(50), Valid: true},
+	})
+	require.NoError(t, err)
+
+	// drain the account right down to its overdraft limit
+	available := NumericToRat(AddNumeric(account1.Balance, account1.OverdraftLimit))
+	require.True(t, available.IsInt())
+
+	result, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountId: account1.ID,
+		ToAccountId:   account2.ID,
+		Amount:        available.Num().Int64(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, result.FromAccount.ID)
+	require.Zero(t, NumericToRat(result.FromAccount.Balance).Cmp(big.NewRat(-50, 1)))
+
+	// one more unit would go past the limit
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountId: account1.ID,
+		ToAccountId:   account2.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// interest is charged once per day
+	charge := ChargeOverdraftInterestTxParams{
+		AccountID:  account1.ID,
+		ChargeDate: time.Now(),
+		AnnualRate: mustNumeric("3.65"),
+	}
+
+	charged, err := store.ChargeOverdraftInterestTx(ctx, charge)
+	require.NoError(t, err)
+	require.True(t, charged.Charged)
+	require.Zero(t, NumericToRat(charged.Charge.Amount).Cmp(big.NewRat(1, 2)))
+	require.Zero(t, NumericToRat(charged.Account.Balance).Cmp(big.NewRat(-101, 2)))
+
+	again, err := store.ChargeOverdraftInterestTx(ctx, charge)
+	require.NoError(t, err)
+	require.False(t, again.Charged)
+}
+
+func mustNumeric(s string) pgtype.Numeric {
+	var n pgtype.Numeric
+	if err := n.Scan(s); err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type ChangePasswordTxParams struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	// ChangedAt becomes the user's password_changed_at. Access tokens issued before it
+	// stop working.
+	ChangedAt time.Time `json:"changed_at"`
+	// ResetTokenHash is the hash of the reset token the password is changed with, if any.
+	// It must belong to the user and not have expired.
+	ResetTokenHash string `json:"reset_token_hash"`
+}
+
+// ChangePasswordTx replaces a user's password, keeps the old hash in their password history
+// and deletes their reset tokens. It returns pgx.ErrNoRows if the reset token is not valid.
+// The audit log records the change, without the hashes.
+func (t Transactions) ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (User, error) {
+	ctx, span := tracer.Start(ctx, "ChangePasswordTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		// Using the token first means that of two resets racing with it, one fails.
+		if arg.ResetTokenHash != "" {
+			used, err := q.UsePasswordResetToken(ctx, UsePasswordResetTokenParams{TokenHash: arg.ResetTokenHash, Username: arg.Username})
+			if err != nil {
+				return err
+			}
+			if used == 0 {
+				return pgx.ErrNoRows
+			}
+		}
+
+		old, err := q.GetUser(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreatePasswordHistory(ctx, CreatePasswordHistoryParams{Username: arg.Username, PasswordHash: old.PasswordHash})
+		if err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:          arg.Username,
+			PasswordHash:      arg.PasswordHash,
+			PasswordChangedAt: pgtype.Timestamptz{Time: arg.ChangedAt, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		action := "user.password_changed"
+		if arg.ResetTokenHash != "" {
+			action = "user.password_reset"
+		}
+		_, err = WriteAudit(ctx, q, AuditEntry{Action: action, ResourceType: "user", ResourceID: arg.Username})
+		if err != nil {
+			return err
+		}
+
+		return q.DeletePasswordResetTokens(ctx, arg.Username)
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
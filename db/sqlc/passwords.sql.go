@@ -0,0 +1,246 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: passwords.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createPasswordHistory = `-- name: CreatePasswordHistory :one
+INSERT INTO password_history (
+  username, password_hash
+) VALUES (
+  $1, $2
+)
+RETURNING id, username, password_hash, created_at
+`
+
+type CreatePasswordHistoryParams struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func (q *Queries) CreatePasswordHistory(ctx context.Context, arg CreatePasswordHistoryParams) (PasswordHistory, error) {
+	row := q.db.QueryRow(ctx, createPasswordHistory, arg.Username, arg.PasswordHash)
+	var i PasswordHistory
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createPasswordResetToken = `-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (
+  token_hash, username, expires_at
+) VALUES (
+  $1, $2, $3
+)
+RETURNING token_hash, username, expires_at, created_at
+`
+
+type CreatePasswordResetTokenParams struct {
+	TokenHash string             `json:"token_hash"`
+	Username  string             `json:"username"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error) {
+	row := q.db.QueryRow(ctx, createPasswordResetToken, arg.TokenHash, arg.Username, arg.ExpiresAt)
+	var i PasswordResetToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.Username,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deletePasswordHistory = `-- name: DeletePasswordHistory :exec
+DELETE FROM password_history
+WHERE username = $1
+`
+
+func (q *Queries) DeletePasswordHistory(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, deletePasswordHistory, username)
+	return err
+}
+
+const deletePasswordResetTokens = `-- name: DeletePasswordResetTokens :exec
+DELETE FROM password_reset_tokens
+WHERE username = $1
+`
+
+func (q *Queries) DeletePasswordResetTokens(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, deletePasswordResetTokens, username)
+	return err
+}
+
+const getPasswordResetToken = `-- name: GetPasswordResetToken :one
+SELECT token_hash, username, expires_at, created_at FROM password_reset_tokens
+WHERE token_hash = $1 AND expires_at > now()
+LIMIT 1
+`
+
+func (q *Queries) GetPasswordResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error) {
+	row := q.db.QueryRow(ctx, getPasswordResetToken, tokenHash)
+	var i PasswordResetToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.Username,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at FROM users
+WHERE email_index = $1
+  OR (data_key IS NULL AND lower(email) = lower($2))
+LIMIT 1
+`
+
+type GetUserByEmailParams struct {
+	EmailIndex []byte `json:"email_index"`
+	Email      string `json:"email"`
+}
+
+// An encrypted email is found by its blind index, one still in plaintext by itself.
+func (q *Queries) GetUserByEmail(ctx context.Context, arg GetUserByEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, arg.EmailIndex, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const getUserTokensValidFrom = `-- name: GetUserTokensValidFrom :one
+SELECT GREATEST(password_changed_at, tokens_revoked_at)::timestamptz AS valid_from FROM users
+WHERE username = $1
+`
+
+// The access tokens issued before the user last changed their password or their role are
+// revoked.
+func (q *Queries) GetUserTokensValidFrom(ctx context.Context, username string) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getUserTokensValidFrom, username)
+	var valid_from pgtype.Timestamptz
+	err := row.Scan(&valid_from)
+	return valid_from, err
+}
+
+const listPasswordHistory = `-- name: ListPasswordHistory :many
+SELECT id, username, password_hash, created_at FROM password_history
+WHERE username = $1
+ORDER BY id DESC
+LIMIT $2
+`
+
+type ListPasswordHistoryParams struct {
+	Username string `json:"username"`
+	Limit    int32  `json:"limit"`
+}
+
+func (q *Queries) ListPasswordHistory(ctx context.Context, arg ListPasswordHistoryParams) ([]PasswordHistory, error) {
+	rows, err := q.db.Query(ctx, listPasswordHistory, arg.Username, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PasswordHistory{}
+	for rows.Next() {
+		var i PasswordHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.PasswordHash,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET password_hash = $2, password_changed_at = $3
+WHERE username = $1
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type UpdateUserPasswordParams struct {
+	Username          string             `json:"username"`
+	PasswordHash      string             `json:"password_hash"`
+	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserPassword, arg.Username, arg.PasswordHash, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const usePasswordResetToken = `-- name: UsePasswordResetToken :execrows
+DELETE FROM password_reset_tokens
+WHERE token_hash = $1 AND username = $2 AND expires_at > now()
+`
+
+type UsePasswordResetTokenParams struct {
+	TokenHash string `json:"token_hash"`
+	Username  string `json:"username"`
+}
+
+func (q *Queries) UsePasswordResetToken(ctx context.Context, arg UsePasswordResetTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, usePasswordResetToken, arg.TokenHash, arg.Username)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -6,33 +6,152 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
-	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) error
+	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	// Leases due deliveries to one worker by pushing next_attempt_at to lease_until,
+	// so concurrent workers skip them until the lease runs out.
+	ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	CloseAccount(ctx context.Context, id int64) (Account, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error)
+	CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error)
+	CreateDataRequest(ctx context.Context, arg CreateDataRequestParams) (DataRequest, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateInterestAccrual(ctx context.Context, arg CreateInterestAccrualParams) (int64, error)
+	CreateInterestPosting(ctx context.Context, arg CreateInterestPostingParams) (InterestPosting, error)
+	CreateOverdraftCharge(ctx context.Context, arg CreateOverdraftChargeParams) (OverdraftCharge, error)
+	CreatePasswordHistory(ctx context.Context, arg CreatePasswordHistoryParams) (PasswordHistory, error)
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error)
+	CreateTOTPBackupCode(ctx context.Context, arg CreateTOTPBackupCodeParams) (TotpBackupCode, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
+	CreateWebhookDeliveriesForEvent(ctx context.Context, arg CreateWebhookDeliveriesForEventParams) (int64, error)
+	CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error)
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error)
+	DebitAccountBalance(ctx context.Context, arg DebitAccountBalanceParams) (Account, error)
 	DeleteAccount(ctx context.Context, id int64) error
 	DeleteEntry(ctx context.Context, id int64) error
+	DeleteExpiredRequestNonces(ctx context.Context) (int64, error)
+	DeleteLoginFailure(ctx context.Context, arg DeleteLoginFailureParams) (int64, error)
+	DeletePasswordHistory(ctx context.Context, username string) error
+	DeletePasswordResetTokens(ctx context.Context, username string) error
+	DeleteTOTPBackupCodes(ctx context.Context, username string) error
 	DeleteTransfer(ctx context.Context, id int64) error
+	DeleteVerifyEmails(ctx context.Context, username string) error
+	DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error)
+	DeleteWebhookSubscriptions(ctx context.Context, username string) error
+	EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) (User, error)
+	// The name and email are replaced with placeholders, in plaintext, so the data key that
+	// encrypted them goes too. Every way of logging in goes with them, and the tokens already
+	// issued stop working.
+	EraseUser(ctx context.Context, arg EraseUserParams) (User, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	GetAccountByOwnerCurrencyAndType(ctx context.Context, arg GetAccountByOwnerCurrencyAndTypeParams) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetApiKey(ctx context.Context, id int64) (ApiKey, error)
+	GetApiKeyByHash(ctx context.Context, keyHash string) (ApiKey, error)
+	GetDataRequestForUpdate(ctx context.Context, id int64) (DataRequest, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetInterestPosting(ctx context.Context, arg GetInterestPostingParams) (InterestPosting, error)
+	GetInterestProduct(ctx context.Context, arg GetInterestProductParams) (InterestProduct, error)
+	GetLatestEntryIDForAccount(ctx context.Context, accountID int64) (int64, error)
+	// The date is NULL, not valid, before the first accrual.
+	GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error)
+	GetLoginFailure(ctx context.Context, arg GetLoginFailureParams) (LoginFailure, error)
+	GetOverdraftCharge(ctx context.Context, arg GetOverdraftChargeParams) (OverdraftCharge, error)
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
 	GetTransferFromAccount(ctx context.Context, arg GetTransferFromAccountParams) ([]Transfer, error)
 	GetTransferFromAndToAccount(ctx context.Context, arg GetTransferFromAndToAccountParams) ([]Transfer, error)
 	GetTransferToAccount(ctx context.Context, arg GetTransferToAccountParams) ([]Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	// An encrypted email is found by its blind index, one still in plaintext by itself.
+	GetUserByEmail(ctx context.Context, arg GetUserByEmailParams) (User, error)
+	// The access tokens issued before the user last changed their password or their role are
+	// revoked.
+	GetUserTokensValidFrom(ctx context.Context, username string) (pgtype.Timestamptz, error)
+	GetVerifyEmail(ctx context.Context, id int64) (VerifyEmail, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	GetWebhookEvent(ctx context.Context, id int64) (WebhookEvent, error)
+	GetWebhookSubscription(ctx context.Context, id int64) (WebhookSubscription, error)
+	// A user changed since it was read is left for the next run.
+	IndexUserEmail(ctx context.Context, arg IndexUserEmailParams) (int64, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListAccountsByOwner(ctx context.Context, arg ListAccountsByOwnerParams) ([]Account, error)
+	ListApiKeys(ctx context.Context, username string) ([]ApiKey, error)
+	ListAuditLog(ctx context.Context, arg ListAuditLogParams) ([]AuditLog, error)
+	// The requests with the status, or all of them, oldest first.
+	ListDataRequests(ctx context.Context, arg ListDataRequestsParams) ([]DataRequest, error)
+	ListDataRequestsByUser(ctx context.Context, username string) ([]DataRequest, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
 	ListEntriesForAccount(ctx context.Context, arg ListEntriesForAccountParams) ([]Entry, error)
+	ListEntriesForAccountAfter(ctx context.Context, arg ListEntriesForAccountAfterParams) ([]Entry, error)
+	ListInterestAccrualsForAccount(ctx context.Context, arg ListInterestAccrualsForAccountParams) ([]InterestAccrual, error)
+	ListInterestBearingAccounts(ctx context.Context) ([]ListInterestBearingAccountsRow, error)
+	ListInterestProducts(ctx context.Context) ([]InterestProduct, error)
+	ListOverdraftChargesForAccount(ctx context.Context, arg ListOverdraftChargesForAccountParams) ([]OverdraftCharge, error)
+	ListOverdrawnAccounts(ctx context.Context) ([]Account, error)
+	ListPasswordHistory(ctx context.Context, arg ListPasswordHistoryParams) ([]PasswordHistory, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	// The transfers into or out of any account of owner.
+	ListTransfersByOwner(ctx context.Context, arg ListTransfersByOwnerParams) ([]Transfer, error)
+	ListUnpostedInterestPeriods(ctx context.Context, before pgtype.Date) ([]ListUnpostedInterestPeriodsRow, error)
+	// The users in plaintext whose email has no blind index yet.
+	ListUsersToIndex(ctx context.Context, arg ListUsersToIndexParams) ([]User, error)
+	// The users in plaintext, or whose data key is wrapped by another master key than the
+	// current one.
+	ListUsersToRekey(ctx context.Context, arg ListUsersToRekeyParams) ([]User, error)
+	ListVerifyEmailsToRekey(ctx context.Context, arg ListVerifyEmailsToRekeyParams) ([]VerifyEmail, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookSubscriptions(ctx context.Context, username string) ([]WebhookSubscription, error)
+	LockLogin(ctx context.Context, arg LockLoginParams) (LoginFailure, error)
+	MarkInterestAccrualsPosted(ctx context.Context, arg MarkInterestAccrualsPostedParams) (int64, error)
+	MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) (WebhookDelivery, error)
+	MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) (WebhookDelivery, error)
+	NotifyAccountEvent(ctx context.Context, arg NotifyAccountEventParams) error
+	ReconcileAccounts(ctx context.Context) ([]ReconcileAccountsRow, error)
+	// The count starts over once the failures before are older than window_start or the
+	// lockout they led to has ended.
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error)
+	// A user changed since it was read, as every change re-encrypts, is left for the next run.
+	RekeyUser(ctx context.Context, arg RekeyUserParams) (int64, error)
+	RekeyVerifyEmail(ctx context.Context, arg RekeyVerifyEmailParams) (int64, error)
+	ReplayWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	ReviewDataRequest(ctx context.Context, arg ReviewDataRequestParams) (DataRequest, error)
+	RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (int64, error)
+	RevokeApiKeys(ctx context.Context, username string) error
+	SetAccountFrozen(ctx context.Context, arg SetAccountFrozenParams) (Account, error)
+	SetTransferReversalOf(ctx context.Context, arg SetTransferReversalOfParams) (Transfer, error)
+	// The address must still be the user's: a code sent to an old one verifies nothing. An
+	// encrypted email is compared by its blind index.
+	SetUserEmailVerified(ctx context.Context, arg SetUserEmailVerifiedParams) (User, error)
+	SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) (User, error)
 	SubtractAccountBalance(ctx context.Context, arg SubtractAccountBalanceParams) error
+	SumUnpostedInterestAccruals(ctx context.Context, arg SumUnpostedInterestAccrualsParams) (pgtype.Numeric, error)
+	TouchApiKey(ctx context.Context, id int64) error
 	UpdateAccountBalance(ctx context.Context, arg UpdateAccountBalanceParams) error
+	UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error)
 	UpdateEntryAmount(ctx context.Context, arg UpdateEntryAmountParams) error
 	UpdateTransferAmount(ctx context.Context, arg UpdateTransferAmountParams) error
+	// The caller, who can compare the addresses when they are encrypted, says whether the
+	// email changed: a new one has to be verified again. A row whose data key is no longer
+	// previous_data_key was re-encrypted since it was read, and is left alone.
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	// The tokens issued with the old role stop working.
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	UpsertInterestProduct(ctx context.Context, arg UpsertInterestProductParams) (InterestProduct, error)
+	UsePasswordResetToken(ctx context.Context, arg UsePasswordResetTokenParams) (int64, error)
+	// A nonce used before that has not expired affects no row: the request is a replay.
+	UseRequestNonce(ctx context.Context, arg UseRequestNonceParams) (int64, error)
+	UseTOTPBackupCode(ctx context.Context, arg UseTOTPBackupCodeParams) (int64, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+	UseVerifyEmail(ctx context.Context, id int64) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,51 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: request_nonces.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteExpiredRequestNonces = `-- name: DeleteExpiredRequestNonces :execrows
+DELETE FROM request_nonces
+WHERE expires_at <= now()
+`
+
+func (q *Queries) DeleteExpiredRequestNonces(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredRequestNonces)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useRequestNonce = `-- name: UseRequestNonce :execrows
+INSERT INTO request_nonces (
+  api_key_id, nonce, expires_at
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (api_key_id, nonce) DO UPDATE
+SET expires_at = EXCLUDED.expires_at
+WHERE request_nonces.expires_at <= now()
+`
+
+type UseRequestNonceParams struct {
+	ApiKeyID  int64              `json:"api_key_id"`
+	Nonce     string             `json:"nonce"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+// A nonce used before that has not expired affects no row: the request is a replay.
+func (q *Queries) UseRequestNonce(ctx context.Context, arg UseRequestNonceParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useRequestNonce, arg.ApiKeyID, arg.Nonce, arg.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrTransferReversed is returned when a transfer has already been reversed.
+	ErrTransferReversed = errors.New("transfer has already been reversed")
+	// ErrReversalNotReversible is returned when asked to reverse a reversal.
+	ErrReversalNotReversible = errors.New("a reversal cannot be reversed")
+)
+
+// ReverseTransferTx undoes a transfer with a new transfer of the same amount in the opposite
+// direction, recorded as its reversal and in the audit log. The original's recipient must be able to cover the
+// amount within its overdraft limit. Frozen accounts do not block a reversal, so that money
+// can be returned from an account frozen for fraud; closed ones do, since they must keep
+// a zero balance.
+func (t Transactions) ReverseTransferTx(ctx context.Context, transferID int64) (TransferTxResult, error) {
+	ctx, span := tracer.Start(ctx, "ReverseTransferTx")
+	defer span.End()
+
+	var result TransferTxResult
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		original, err := q.GetTransfer(ctx, transferID)
+		if err != nil {
+			return err
+		}
+		if original.ReversalOf.Valid {
+			return ErrReversalNotReversible
+		}
+
+		result, err = moveMoney(ctx, q, original.ToAccountID, original.FromAccountID, original.Amount, true)
+		if err != nil {
+			return err
+		}
+		if result.FromAccount.ClosedAt.Valid || result.ToAccount.ClosedAt.Valid {
+			return ErrAccountClosed
+		}
+
+		result.Transfer, err = q.SetTransferReversalOf(ctx, SetTransferReversalOfParams{
+			ID:         result.Transfer.ID,
+			ReversalOf: pgtype.Int8{Int64: original.ID, Valid: true},
+		})
+
+		// reversal_of is unique, so a second reversal of the same transfer fails here.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrTransferReversed
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "transfer.reversed",
+			ResourceType: "transfer",
+			ResourceID:   strconv.FormatInt(original.ID, 10),
+			Before:       original,
+			After:        result,
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return result, err
+}
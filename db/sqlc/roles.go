@@ -0,0 +1,37 @@
+package db
+
+import "context"
+
+// UpdateUserRoleTx gives a user a role and records the change in the audit log. Giving a
+// user the role they have changes nothing and records nothing.
+func (t Transactions) UpdateUserRoleTx(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	ctx, span := tracer.Start(ctx, "UpdateUserRoleTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		before, err := q.GetUser(ctx, arg.Username)
+		user = before
+		if err != nil || before.Role == arg.Role {
+			return err
+		}
+
+		user, err = q.UpdateUserRole(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "user.role_changed",
+			ResourceType: "user",
+			ResourceID:   user.Username,
+			Before:       auditUser{Username: before.Username, Role: before.Role},
+			After:        auditUser{Username: user.Username, Role: user.Role},
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
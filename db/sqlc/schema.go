@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"example.com/db/migration"
+)
+
+// SchemaVersion is the migration version this build of the store expects, the newest
+// migration embedded in the binary.
+var SchemaVersion = int64(migration.Version)
+
+// ErrSchemaNotReady is returned by Ping when the database is behind SchemaVersion or a
+// migration failed halfway.
+var ErrSchemaNotReady = errors.New("database schema is not ready")
+
+// schemaVersion reads the version golang-migrate records after each migration.
+const schemaVersion = `-- name: SchemaVersion :one
+SELECT version, dirty FROM schema_migrations LIMIT 1
+`
+
+// Ping checks that the database answers and that its schema is at SchemaVersion or newer.
+// A newer schema is accepted so that a previous release keeps serving during a rollout.
+func (store *SQLStore) Ping(ctx context.Context) error {
+	if err := store.db.Ping(ctx); err != nil {
+		return err
+	}
+
+	var version int64
+	var dirty bool
+	if err := store.db.QueryRow(ctx, schemaVersion).Scan(&version, &dirty); err != nil {
+		return fmt.Errorf("cannot read schema version: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: migration %d is dirty", ErrSchemaNotReady, version)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("%w: at version %d, want %d", ErrSchemaNotReady, version, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	require.NoError(t, NewStore(testDB).Ping(context.Background()))
+}
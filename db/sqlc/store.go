@@ -2,32 +2,80 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 type Store interface {
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	CreateAccountTx(ctx context.Context, arg CreateAccountParams) (Account, error)
+	ChargeOverdraftInterestTx(ctx context.Context, arg ChargeOverdraftInterestTxParams) (ChargeOverdraftInterestTxResult, error)
+	PostInterestTx(ctx context.Context, arg PostInterestTxParams) (PostInterestTxResult, error)
+	FreezeAccountTx(ctx context.Context, arg FreezeAccountTxParams) (Account, error)
+	ReverseTransferTx(ctx context.Context, transferID int64) (TransferTxResult, error)
+	EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (User, error)
+	ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (User, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (User, error)
+	UpdateUserRoleTx(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	CreateUserTx(ctx context.Context, arg CreateUserParams) (User, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserParams) (User, error)
+	UnlockLoginTx(ctx context.Context, username string) (User, error)
+	CreateApiKeyTx(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error)
+	RevokeApiKeyTx(ctx context.Context, arg RevokeApiKeyParams) (int64, error)
+	UpdateOverdraftLimitTx(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error)
+	UpsertInterestProductTx(ctx context.Context, arg UpsertInterestProductParams) (InterestProduct, error)
+	CloseAccountTx(ctx context.Context, accountID int64) (Account, error)
+	RequestErasureTx(ctx context.Context, arg RequestErasureTxParams) (DataRequest, error)
+	ReviewDataRequestTx(ctx context.Context, arg ReviewDataRequestTxParams) (DataRequest, error)
+	Ping(ctx context.Context) error
 	Querier
 }
 
+// ErrInsufficientFunds is returned when a debit would take an account below its overdraft limit.
+var ErrInsufficientFunds = errors.New("insufficient funds")
 
+
+// TxRunner runs fn in a transaction. Its changes are committed together if fn returns
+// nil and discarded otherwise.
+type TxRunner interface {
+	ExecTx(ctx context.Context, fn func(Querier) error) error
+}
+
+// Transactions implements the transactions of Store on top of a TxRunner, so that every
+// Store moves money by the same rules whatever it keeps the data in.
+type Transactions struct {
+	runner TxRunner
+}
+
+func NewTransactions(runner TxRunner) Transactions {
+	return Transactions{runner: runner}
+}
+
+// SQLStore implements Store on Postgres.
 type SQLStore struct {
 	*Queries
+	Transactions
 	db *pgxpool.Pool
 }
 
 func NewStore(db *pgxpool.Pool) Store {
-	return &SQLStore{
+	store := &SQLStore{
 		db:      db,
 		Queries: New(db),
 	}
+	store.Transactions = NewTransactions(store)
+	return store
 }
 
-func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+func (store *SQLStore) ExecTx(ctx context.Context, fn func(Querier) error) error {
 	tx, err := store.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
 		return err
@@ -57,34 +105,120 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+
+	// Attempts is how often the transaction ran; Deadlocks is how many of those runs
+	// Postgres aborted as deadlocked. Both are set on success and on failure.
+	Attempts  int `json:"-"`
+	Deadlocks int `json:"-"`
 }
 
-func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
+// maxTransferAttempts bounds how often TransferTx runs its transaction when Postgres
+// aborts it with a deadlock or a serialization failure.
+const maxTransferAttempts = 3
+
+const (
+	deadlockDetected     = "40P01"
+	serializationFailure = "40001"
+)
+
+// TransferTx moves money between two accounts in one transaction and records it in the
+// audit log. Transfers in opposite directions lock the same rows in opposite order, so a
+// deadlocked transaction is retried.
+// It fails with ErrAccountFrozen if either account is frozen, or ErrAccountClosed if either
+// is closed; the checks run on the rows the transfer has locked, so an account frozen or
+// closed concurrently cannot slip through.
+func (t Transactions) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
+	ctx, span := tracer.Start(ctx, "TransferTx")
+	defer span.End()
+
 	var result TransferTxResult
+	deadlocks := 0
 
-	err := store.execTx(ctx, func(q *Queries) error {
-		var err error
+	for attempt := 1; ; attempt++ {
+		attemptCtx, attemptSpan := tracer.Start(ctx, "TransferTx attempt", trace.WithAttributes(attribute.Int("attempt", attempt)))
+		err := t.runner.ExecTx(attemptCtx, func(q Querier) error {
+			var err error
+
+			amountNumeric := pgtype.Numeric{
+				Int:   big.NewInt(arg.Amount),
+				Exp:   0,
+				Valid: true,
+			}
 
-		amountNumeric := pgtype.Numeric{
-			Int:   big.NewInt(arg.Amount),
-			Exp:   0,
-			Valid: true,
+			result, err = moveMoney(attemptCtx, q, arg.FromAccountId, arg.ToAccountId, amountNumeric, true)
+			if err == nil && (result.FromAccount.Frozen || result.ToAccount.Frozen) {
+				return ErrAccountFrozen
+			}
+			if err == nil && (result.FromAccount.ClosedAt.Valid || result.ToAccount.ClosedAt.Valid) {
+				return ErrAccountClosed
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = WriteAudit(attemptCtx, q, AuditEntry{
+				Action:       "transfer.created",
+				ResourceType: "transfer",
+				ResourceID:   strconv.FormatInt(result.Transfer.ID, 10),
+				Before:       transferAuditBefore(result),
+				After:        result,
+			})
+			return err
+		})
+		recordSpanError(attemptSpan, err)
+		attemptSpan.End()
+
+		if IsDeadlock(err) {
+			deadlocks++
 		}
+		if err == nil || !isRetryable(err) || attempt == maxTransferAttempts {
+			result.Attempts = attempt
+			result.Deadlocks = deadlocks
+
+			span.SetAttributes(attribute.Int("attempts", attempt), attribute.Int("deadlocks", deadlocks))
+			recordSpanError(span, err)
+			return result, err
+		}
+	}
+}
 
+// IsDeadlock reports whether Postgres aborted a transaction to break a deadlock.
+func IsDeadlock(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == deadlockDetected
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == deadlockDetected || pgErr.Code == serializationFailure)
+}
+
+// moveMoney records a transfer with its pair of entries and updates both balances.
+// With enforceLimit set, the debit fails with ErrInsufficientFunds if it would take the
+// source account below its overdraft limit; house accounts move money without that check.
+func moveMoney(ctx context.Context, q Querier, fromAccountID, toAccountID int64, amount pgtype.Numeric, enforceLimit bool) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	err := traceStep(ctx, "create transfer", func(ctx context.Context) error {
+		var err error
 		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: arg.FromAccountId,
-			ToAccountID:   arg.ToAccountId,
-			Amount:        amountNumeric,
+			FromAccountID: fromAccountID,
+			ToAccountID:   toAccountID,
+			Amount:        amount,
 		})
-		if err != nil {
-			return err
-		}
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
 
+	err = traceStep(ctx, "create entries", func(ctx context.Context) error {
+		var err error
 		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountId,
+			AccountID: fromAccountID,
 			Amount: pgtype.Numeric{
-				Int:   new(big.Int).Neg(big.NewInt(arg.Amount)),
-				Exp:   0,
+				Int:   new(big.Int).Neg(amount.Int),
+				Exp:   amount.Exp,
 				Valid: true,
 			},
 		})
@@ -93,36 +227,70 @@ func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (Tr
 		}
 
 		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountId,
-			Amount:    amountNumeric,
+			AccountID: toAccountID,
+			Amount:    amount,
 		})
-		if err != nil {
-			return err
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	err = traceStep(ctx, "debit source account", func(ctx context.Context) error {
+		var err error
+		if enforceLimit {
+			result.FromAccount, err = q.DebitAccountBalance(ctx, DebitAccountBalanceParams{
+				ID:     fromAccountID,
+				Amount: amount,
+			})
+			if err == pgx.ErrNoRows {
+				return ErrInsufficientFunds
+			}
+		} else {
+			result.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+				ID:     fromAccountID,
+				Amount: result.FromEntry.Amount,
+			})
 		}
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
 
-		err = q.SubtractAccountBalance(ctx, SubtractAccountBalanceParams{
-			ID:     arg.FromAccountId,
-			Amount: amountNumeric,
+	err = traceStep(ctx, "credit destination account", func(ctx context.Context) error {
+		var err error
+		result.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     toAccountID,
+			Amount: amount,
 		})
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
 
-		if err != nil {
-			return err
+	err = traceStep(ctx, "publish events", func(ctx context.Context) error {
+		for _, entry := range []Entry{result.FromEntry, result.ToEntry} {
+			if err := notifyEntryCreated(ctx, q, entry); err != nil {
+				return err
+			}
 		}
 
-		err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-			ID:     arg.ToAccountId,
-			Amount: amountNumeric,
-		})
-
+		err := publishEvent(ctx, q, result.FromAccount.Owner, EventTransferCreated, result.Transfer)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		if result.ToAccount.Owner != result.FromAccount.Owner {
+			err = publishEvent(ctx, q, result.ToAccount.Owner, EventTransferCreated, result.Transfer)
+		}
+		return err
 	})
 
 	return result, err
 }
+
 func SubtractNumericInt64(n pgtype.Numeric, amount int64) (pgtype.Numeric, error) {
 	// If the numeric is NULL
 	if !n.Valid {
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type EnableTOTPTxParams struct {
+	Username string `json:"username"`
+	// Step is the time step of the code that confirmed the enrollment, so that the code
+	// cannot be used again.
+	Step int64 `json:"step"`
+	// BackupCodeHashes replace the user's backup codes.
+	BackupCodeHashes []string `json:"backup_code_hashes"`
+}
+
+// EnableTOTPTx turns on two-factor authentication for a user whose TOTP secret is set and
+// replaces their backup codes. It returns pgx.ErrNoRows if the user has no secret.
+func (t Transactions) EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (User, error) {
+	ctx, span := tracer.Start(ctx, "EnableTOTPTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+
+		user, err = q.EnableUserTOTP(ctx, EnableUserTOTPParams{
+			Username:     arg.Username,
+			TotpLastStep: pgtype.Int8{Int64: arg.Step, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteTOTPBackupCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		for _, hash := range arg.BackupCodeHashes {
+			_, err := q.CreateTOTPBackupCode(ctx, CreateTOTPBackupCodeParams{Username: arg.Username, CodeHash: hash})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{Action: "user.totp_enabled", ResourceType: "user", ResourceID: arg.Username})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
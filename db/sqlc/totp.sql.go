@@ -0,0 +1,159 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: totp.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createTOTPBackupCode = `-- name: CreateTOTPBackupCode :one
+INSERT INTO totp_backup_codes (
+  username, code_hash
+) VALUES (
+  $1, $2
+)
+RETURNING id, username, code_hash, used_at, created_at
+`
+
+type CreateTOTPBackupCodeParams struct {
+	Username string `json:"username"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateTOTPBackupCode(ctx context.Context, arg CreateTOTPBackupCodeParams) (TotpBackupCode, error) {
+	row := q.db.QueryRow(ctx, createTOTPBackupCode, arg.Username, arg.CodeHash)
+	var i TotpBackupCode
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.CodeHash,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteTOTPBackupCodes = `-- name: DeleteTOTPBackupCodes :exec
+DELETE FROM totp_backup_codes
+WHERE username = $1
+`
+
+func (q *Queries) DeleteTOTPBackupCodes(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, deleteTOTPBackupCodes, username)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :one
+UPDATE users
+SET totp_enabled_at = now(), totp_last_step = $2
+WHERE username = $1 AND totp_secret IS NOT NULL
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type EnableUserTOTPParams struct {
+	Username     string      `json:"username"`
+	TotpLastStep pgtype.Int8 `json:"totp_last_step"`
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) (User, error) {
+	row := q.db.QueryRow(ctx, enableUserTOTP, arg.Username, arg.TotpLastStep)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :one
+UPDATE users
+SET totp_secret = $2, totp_enabled_at = NULL, totp_last_step = NULL
+WHERE username = $1
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type SetUserTOTPSecretParams struct {
+	Username   string `json:"username"`
+	TotpSecret []byte `json:"totp_secret"`
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserTOTPSecret, arg.Username, arg.TotpSecret)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const useTOTPBackupCode = `-- name: UseTOTPBackupCode :execrows
+UPDATE totp_backup_codes
+SET used_at = now()
+WHERE username = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+type UseTOTPBackupCodeParams struct {
+	Username string `json:"username"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) UseTOTPBackupCode(ctx context.Context, arg UseTOTPBackupCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useTOTPBackupCode, arg.Username, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE username = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+`
+
+type UseTOTPStepParams struct {
+	Username     string      `json:"username"`
+	TotpLastStep pgtype.Int8 `json:"totp_last_step"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useTOTPStep, arg.Username, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the spans of the store's transactions and of their steps. The queries
+// inside them are traced by the pgx tracer the pool is configured with.
+var tracer = otel.Tracer("example.com/db/sqlc")
+
+// traceStep runs one step of a transaction in a child span of ctx.
+func traceStep(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name)
+	defer span.End()
+
+	err := fn(ctx)
+	recordSpanError(span, err)
+	return err
+}
+
+// recordSpanError marks span as failed if err is set.
+func recordSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransferTxSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx := context.Background()
+	account1, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+	account2, err := CreateRandomAccount(ctx)
+	require.NoError(t, err)
+
+	_, err = NewStore(testDB).TransferTx(ctx, TransferTxParams{
+		FromAccountId: account1.ID,
+		ToAccountId:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	transferTx := spans["TransferTx"]
+	require.NotNil(t, transferTx)
+	require.Contains(t, transferTx.Attributes(), attribute.Int("attempts", 1))
+
+	attempt := spans["TransferTx attempt"]
+	require.NotNil(t, attempt)
+	require.Equal(t, transferTx.SpanContext().SpanID(), attempt.Parent().SpanID())
+
+	for _, step := range []string{"create transfer", "create entries", "debit source account", "credit destination account", "publish events"} {
+		require.Contains(t, spans, step)
+		require.Equal(t, attempt.SpanContext().SpanID(), spans[step].Parent().SpanID())
+	}
+}
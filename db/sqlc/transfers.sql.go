@@ -17,7 +17,7 @@ INSERT INTO transfers (
 ) VALUES (
   $1, $2, $3
 )
-RETURNING id, from_account_id, to_account_id, amount, created_at
+RETURNING id, from_account_id, to_account_id, amount, created_at, reversal_of
 `
 
 type CreateTransferParams struct {
@@ -35,6 +35,7 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ReversalOf,
 	)
 	return i, err
 }
@@ -49,7 +50,7 @@ func (q *Queries) DeleteTransfer(ctx context.Context, id int64) error {
 }
 
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, reversal_of FROM transfers
 WHERE id = $1
 LIMIT 1
 `
@@ -63,12 +64,13 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ReversalOf,
 	)
 	return i, err
 }
 
 const getTransferFromAccount = `-- name: GetTransferFromAccount :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, reversal_of FROM transfers
 WHERE from_account_id= $1
 LIMIT $2
 OFFSET $3
@@ -95,6 +97,7 @@ func (q *Queries) GetTransferFromAccount(ctx context.Context, arg GetTransferFro
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -107,7 +110,7 @@ func (q *Queries) GetTransferFromAccount(ctx context.Context, arg GetTransferFro
 }
 
 const getTransferFromAndToAccount = `-- name: GetTransferFromAndToAccount :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, reversal_of FROM transfers
 WHERE to_account_id=$1
 AND from_account_id=$2
 LIMIT $3
@@ -141,6 +144,7 @@ func (q *Queries) GetTransferFromAndToAccount(ctx context.Context, arg GetTransf
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -153,7 +157,7 @@ func (q *Queries) GetTransferFromAndToAccount(ctx context.Context, arg GetTransf
 }
 
 const getTransferToAccount = `-- name: GetTransferToAccount :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, reversal_of FROM transfers
 WHERE to_account_id=$1
 LIMIT $2
 OFFSET $3
@@ -180,6 +184,7 @@ func (q *Queries) GetTransferToAccount(ctx context.Context, arg GetTransferToAcc
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -192,7 +197,7 @@ func (q *Queries) GetTransferToAccount(ctx context.Context, arg GetTransferToAcc
 }
 
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, reversal_of FROM transfers
 ORDER BY id
 LIMIT $1
 OFFSET $2
@@ -218,6 +223,7 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -229,8 +235,75 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 	return items, nil
 }
 
+const listTransfersByOwner = `-- name: ListTransfersByOwner :many
+SELECT t.id, t.from_account_id, t.to_account_id, t.amount, t.created_at, t.reversal_of FROM transfers t
+WHERE t.id > $1 AND (
+  t.from_account_id IN (SELECT a.id FROM accounts a WHERE a.owner = $2)
+  OR t.to_account_id IN (SELECT a.id FROM accounts a WHERE a.owner = $2)
+)
+ORDER BY t.id
+LIMIT $3
+`
+
+type ListTransfersByOwnerParams struct {
+	AfterID    int64  `json:"after_id"`
+	Owner      string `json:"owner"`
+	LimitCount int32  `json:"limit_count"`
+}
+
+// The transfers into or out of any account of owner.
+func (q *Queries) ListTransfersByOwner(ctx context.Context, arg ListTransfersByOwnerParams) ([]Transfer, error) {
+	rows, err := q.db.Query(ctx, listTransfersByOwner, arg.AfterID, arg.Owner, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ReversalOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setTransferReversalOf = `-- name: SetTransferReversalOf :one
+UPDATE transfers SET reversal_of = $1 WHERE id = $2 RETURNING id, from_account_id, to_account_id, amount, created_at, reversal_of
+`
+
+type SetTransferReversalOfParams struct {
+	ReversalOf pgtype.Int8 `json:"reversal_of"`
+	ID         int64       `json:"id"`
+}
+
+func (q *Queries) SetTransferReversalOf(ctx context.Context, arg SetTransferReversalOfParams) (Transfer, error) {
+	row := q.db.QueryRow(ctx, setTransferReversalOf, arg.ReversalOf, arg.ID)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ReversalOf,
+	)
+	return i, err
+}
+
 const updateTransferAmount = `-- name: UpdateTransferAmount :exec
-UPDATE transfers SET amount = $2 WHERE id = $1 RETURNING id, from_account_id, to_account_id, amount, created_at
+UPDATE transfers SET amount = $2 WHERE id = $1 RETURNING id, from_account_id, to_account_id, amount, created_at, reversal_of
 `
 
 type UpdateTransferAmountParams struct {
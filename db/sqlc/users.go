@@ -0,0 +1,81 @@
+package db
+
+import "context"
+
+// CreateUserTx creates a user and records it in the audit log.
+func (t Transactions) CreateUserTx(ctx context.Context, arg CreateUserParams) (User, error) {
+	ctx, span := tracer.Start(ctx, "CreateUserTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+		user, err = q.CreateUser(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{
+			Action:       "user.created",
+			ResourceType: "user",
+			ResourceID:   user.Username,
+			After:        auditUser{Username: user.Username, Role: user.Role},
+		})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
+
+// UpdateUserTx changes a user's full name or email. A changed email is recorded in the
+// audit log, without the addresses.
+func (t Transactions) UpdateUserTx(ctx context.Context, arg UpdateUserParams) (User, error) {
+	ctx, span := tracer.Start(ctx, "UpdateUserTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+		user, err = q.UpdateUser(ctx, arg)
+		if err != nil || !arg.EmailChanged {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{Action: "user.email_changed", ResourceType: "user", ResourceID: arg.Username})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
+
+// UnlockLoginTx forgets the failed logins of a user, which lifts their lockout, and
+// records it in the audit log. Those of the IPs they were made from stay.
+func (t Transactions) UnlockLoginTx(ctx context.Context, username string) (User, error) {
+	ctx, span := tracer.Start(ctx, "UnlockLoginTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		var err error
+		user, err = q.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteLoginFailure(ctx, DeleteLoginFailureParams{Scope: LoginFailureScopeUsername, Key: username})
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{Action: "login.unlocked", ResourceType: "user", ResourceID: username})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
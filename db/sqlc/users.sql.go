@@ -7,22 +7,27 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
-  username, full_name, email, password_hash
+  username, full_name, email, password_hash, email_index, data_key, data_key_id
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7
 )
-RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
 `
 
 type CreateUserParams struct {
-	Username     string `json:"username"`
-	FullName     string `json:"full_name"`
-	Email        string `json:"email"`
-	PasswordHash string `json:"password_hash"`
+	Username     string      `json:"username"`
+	FullName     string      `json:"full_name"`
+	Email        string      `json:"email"`
+	PasswordHash string      `json:"password_hash"`
+	EmailIndex   []byte      `json:"email_index"`
+	DataKey      []byte      `json:"data_key"`
+	DataKeyID    pgtype.Text `json:"data_key_id"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -31,6 +36,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.FullName,
 		arg.Email,
 		arg.PasswordHash,
+		arg.EmailIndex,
+		arg.DataKey,
+		arg.DataKeyID,
 	)
 	var i User
 	err := row.Scan(
@@ -40,12 +48,74 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const eraseUser = `-- name: EraseUser :one
+UPDATE users
+SET
+  full_name = $1,
+  email = $2,
+  email_index = NULL,
+  data_key = NULL,
+  data_key_id = NULL,
+  password_hash = '!',
+  password_changed_at = now(),
+  totp_secret = NULL,
+  totp_enabled_at = NULL,
+  totp_last_step = NULL,
+  email_verified_at = NULL,
+  erased_at = now()
+WHERE username = $3
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type EraseUserParams struct {
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// The name and email are replaced with placeholders, in plaintext, so the data key that
+// encrypted them goes too. Every way of logging in goes with them, and the tokens already
+// issued stop working.
+func (q *Queries) EraseUser(ctx context.Context, arg EraseUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, eraseUser, arg.FullName, arg.Email, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, password_hash, full_name, email, password_changed_at, craeted_at FROM users
+SELECT username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at FROM users
 WHERE username = $1
 LIMIT 1
 `
@@ -60,6 +130,286 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const indexUserEmail = `-- name: IndexUserEmail :execrows
+UPDATE users
+SET email_index = $1
+WHERE username = $2
+  AND email = $3
+  AND data_key IS NULL
+`
+
+type IndexUserEmailParams struct {
+	EmailIndex []byte `json:"email_index"`
+	Username   string `json:"username"`
+	OldEmail   string `json:"old_email"`
+}
+
+// A user changed since it was read is left for the next run.
+func (q *Queries) IndexUserEmail(ctx context.Context, arg IndexUserEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, indexUserEmail, arg.EmailIndex, arg.Username, arg.OldEmail)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listUsersToIndex = `-- name: ListUsersToIndex :many
+SELECT username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at FROM users
+WHERE data_key IS NULL AND email_index IS NULL AND username > $1
+ORDER BY username
+LIMIT $2
+`
+
+type ListUsersToIndexParams struct {
+	AfterUsername string `json:"after_username"`
+	LimitCount    int32  `json:"limit_count"`
+}
+
+// The users in plaintext whose email has no blind index yet.
+func (q *Queries) ListUsersToIndex(ctx context.Context, arg ListUsersToIndexParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersToIndex, arg.AfterUsername, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Username,
+			&i.PasswordHash,
+			&i.FullName,
+			&i.Email,
+			&i.PasswordChangedAt,
+			&i.CraetedAt,
+			&i.Role,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastStep,
+			&i.EmailVerifiedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+			&i.EmailIndex,
+			&i.ErasedAt,
+			&i.TokensRevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersToRekey = `-- name: ListUsersToRekey :many
+SELECT username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at FROM users
+WHERE data_key_id IS DISTINCT FROM $1::varchar AND username > $2
+ORDER BY username
+LIMIT $3
+`
+
+type ListUsersToRekeyParams struct {
+	KeyID         string `json:"key_id"`
+	AfterUsername string `json:"after_username"`
+	LimitCount    int32  `json:"limit_count"`
+}
+
+// The users in plaintext, or whose data key is wrapped by another master key than the
+// current one.
+func (q *Queries) ListUsersToRekey(ctx context.Context, arg ListUsersToRekeyParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersToRekey, arg.KeyID, arg.AfterUsername, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Username,
+			&i.PasswordHash,
+			&i.FullName,
+			&i.Email,
+			&i.PasswordChangedAt,
+			&i.CraetedAt,
+			&i.Role,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastStep,
+			&i.EmailVerifiedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+			&i.EmailIndex,
+			&i.ErasedAt,
+			&i.TokensRevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rekeyUser = `-- name: RekeyUser :execrows
+UPDATE users
+SET
+  full_name = $1,
+  email = $2,
+  email_index = $3,
+  data_key = $4,
+  data_key_id = $5
+WHERE username = $6
+  AND full_name = $7
+  AND email = $8
+  AND data_key IS NOT DISTINCT FROM $9
+`
+
+type RekeyUserParams struct {
+	FullName    string      `json:"full_name"`
+	Email       string      `json:"email"`
+	EmailIndex  []byte      `json:"email_index"`
+	DataKey     []byte      `json:"data_key"`
+	DataKeyID   pgtype.Text `json:"data_key_id"`
+	Username    string      `json:"username"`
+	OldFullName string      `json:"old_full_name"`
+	OldEmail    string      `json:"old_email"`
+	OldDataKey  []byte      `json:"old_data_key"`
+}
+
+// A user changed since it was read, as every change re-encrypts, is left for the next run.
+func (q *Queries) RekeyUser(ctx context.Context, arg RekeyUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rekeyUser,
+		arg.FullName,
+		arg.Email,
+		arg.EmailIndex,
+		arg.DataKey,
+		arg.DataKeyID,
+		arg.Username,
+		arg.OldFullName,
+		arg.OldEmail,
+		arg.OldDataKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET
+  full_name = COALESCE($1, full_name),
+  email = COALESCE($2, email),
+  email_index = COALESCE($3, email_index),
+  data_key = COALESCE($4, data_key),
+  data_key_id = COALESCE($5, data_key_id),
+  email_verified_at = CASE
+    WHEN $6::bool THEN NULL
+    ELSE email_verified_at
+  END
+WHERE username = $7 AND data_key IS NOT DISTINCT FROM $8
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type UpdateUserParams struct {
+	FullName        pgtype.Text `json:"full_name"`
+	Email           pgtype.Text `json:"email"`
+	EmailIndex      []byte      `json:"email_index"`
+	DataKey         []byte      `json:"data_key"`
+	DataKeyID       pgtype.Text `json:"data_key_id"`
+	EmailChanged    bool        `json:"email_changed"`
+	Username        string      `json:"username"`
+	PreviousDataKey []byte      `json:"previous_data_key"`
+}
+
+// The caller, who can compare the addresses when they are encrypted, says whether the
+// email changed: a new one has to be verified again. A row whose data key is no longer
+// previous_data_key was re-encrypted since it was read, and is left alone.
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUser,
+		arg.FullName,
+		arg.Email,
+		arg.EmailIndex,
+		arg.DataKey,
+		arg.DataKeyID,
+		arg.EmailChanged,
+		arg.Username,
+		arg.PreviousDataKey,
+	)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2, tokens_revoked_at = now()
+WHERE username = $1
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type UpdateUserRoleParams struct {
+	Username string   `json:"username"`
+	Role     UserRole `json:"role"`
+}
+
+// The tokens issued with the old role stop working.
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserRole, arg.Username, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
 	)
 	return i, err
 }
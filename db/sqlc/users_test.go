@@ -0,0 +1,18 @@
+package db
+
+import (
+	"context"
+
+	"example.com/db/util"
+)
+
+func CreateRandomUser(ctx context.Context) (User, error) {
+	username := util.RandomOwner()
+
+	return testQueries.CreateUser(ctx, CreateUserParams{
+		Username:     username,
+		FullName:     util.RandomString(10),
+		Email:        username + "@example.com",
+		PasswordHash: util.RandomString(32),
+	})
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type VerifyEmailTxParams struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	// EmailIndex is the blind index of Email, to compare it with an encrypted address.
+	EmailIndex []byte `json:"email_index"`
+}
+
+// VerifyEmailTx uses up a verification code and marks the address it was sent to as
+// verified. It returns pgx.ErrNoRows if the code was used or has expired, or if the
+// address is no longer the user's.
+func (t Transactions) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (User, error) {
+	ctx, span := tracer.Start(ctx, "VerifyEmailTx")
+	defer span.End()
+
+	var user User
+
+	err := t.runner.ExecTx(ctx, func(q Querier) error {
+		used, err := q.UseVerifyEmail(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return pgx.ErrNoRows
+		}
+
+		user, err = q.SetUserEmailVerified(ctx, SetUserEmailVerifiedParams{
+			Username:   arg.Username,
+			EmailIndex: arg.EmailIndex,
+			Email:      arg.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = WriteAudit(ctx, q, AuditEntry{Action: "user.email_verified", ResourceType: "user", ResourceID: arg.Username})
+		return err
+	})
+
+	recordSpanError(span, err)
+	return user, err
+}
@@ -0,0 +1,210 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: verify_emails.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createVerifyEmail = `-- name: CreateVerifyEmail :one
+INSERT INTO verify_emails (
+  username, email, expires_at, data_key, data_key_id
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING id, username, email, expires_at, used_at, created_at, data_key, data_key_id
+`
+
+type CreateVerifyEmailParams struct {
+	Username  string             `json:"username"`
+	Email     string             `json:"email"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	DataKey   []byte             `json:"data_key"`
+	DataKeyID pgtype.Text        `json:"data_key_id"`
+}
+
+func (q *Queries) CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error) {
+	row := q.db.QueryRow(ctx, createVerifyEmail,
+		arg.Username,
+		arg.Email,
+		arg.ExpiresAt,
+		arg.DataKey,
+		arg.DataKeyID,
+	)
+	var i VerifyEmail
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
+
+const deleteVerifyEmails = `-- name: DeleteVerifyEmails :exec
+DELETE FROM verify_emails
+WHERE username = $1
+`
+
+func (q *Queries) DeleteVerifyEmails(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, deleteVerifyEmails, username)
+	return err
+}
+
+const getVerifyEmail = `-- name: GetVerifyEmail :one
+SELECT id, username, email, expires_at, used_at, created_at, data_key, data_key_id FROM verify_emails
+WHERE id = $1
+`
+
+func (q *Queries) GetVerifyEmail(ctx context.Context, id int64) (VerifyEmail, error) {
+	row := q.db.QueryRow(ctx, getVerifyEmail, id)
+	var i VerifyEmail
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+	)
+	return i, err
+}
+
+const listVerifyEmailsToRekey = `-- name: ListVerifyEmailsToRekey :many
+SELECT id, username, email, expires_at, used_at, created_at, data_key, data_key_id FROM verify_emails
+WHERE data_key_id IS DISTINCT FROM $1::varchar AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListVerifyEmailsToRekeyParams struct {
+	KeyID      string `json:"key_id"`
+	AfterID    int64  `json:"after_id"`
+	LimitCount int32  `json:"limit_count"`
+}
+
+func (q *Queries) ListVerifyEmailsToRekey(ctx context.Context, arg ListVerifyEmailsToRekeyParams) ([]VerifyEmail, error) {
+	rows, err := q.db.Query(ctx, listVerifyEmailsToRekey, arg.KeyID, arg.AfterID, arg.LimitCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []VerifyEmail{}
+	for rows.Next() {
+		var i VerifyEmail
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.ExpiresAt,
+			&i.UsedAt,
+			&i.CreatedAt,
+			&i.DataKey,
+			&i.DataKeyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rekeyVerifyEmail = `-- name: RekeyVerifyEmail :execrows
+UPDATE verify_emails
+SET email = $1, data_key = $2, data_key_id = $3
+WHERE id = $4 AND email = $5 AND data_key IS NOT DISTINCT FROM $6
+`
+
+type RekeyVerifyEmailParams struct {
+	Email      string      `json:"email"`
+	DataKey    []byte      `json:"data_key"`
+	DataKeyID  pgtype.Text `json:"data_key_id"`
+	ID         int64       `json:"id"`
+	OldEmail   string      `json:"old_email"`
+	OldDataKey []byte      `json:"old_data_key"`
+}
+
+func (q *Queries) RekeyVerifyEmail(ctx context.Context, arg RekeyVerifyEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rekeyVerifyEmail,
+		arg.Email,
+		arg.DataKey,
+		arg.DataKeyID,
+		arg.ID,
+		arg.OldEmail,
+		arg.OldDataKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setUserEmailVerified = `-- name: SetUserEmailVerified :one
+UPDATE users
+SET email_verified_at = now()
+WHERE username = $1 AND (
+  email_index = $2
+  OR (email_index IS NULL AND email = $3)
+)
+RETURNING username, password_hash, full_name, email, password_changed_at, craeted_at, role, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, data_key, data_key_id, email_index, erased_at, tokens_revoked_at
+`
+
+type SetUserEmailVerifiedParams struct {
+	Username   string `json:"username"`
+	EmailIndex []byte `json:"email_index"`
+	Email      string `json:"email"`
+}
+
+// The address must still be the user's: a code sent to an old one verifies nothing. An
+// encrypted email is compared by its blind index.
+func (q *Queries) SetUserEmailVerified(ctx context.Context, arg SetUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserEmailVerified, arg.Username, arg.EmailIndex, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CraetedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.DataKey,
+		&i.DataKeyID,
+		&i.EmailIndex,
+		&i.ErasedAt,
+		&i.TokensRevokedAt,
+	)
+	return i, err
+}
+
+const useVerifyEmail = `-- name: UseVerifyEmail :execrows
+UPDATE verify_emails
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+`
+
+func (q *Queries) UseVerifyEmail(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, useVerifyEmail, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,414 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = $1
+WHERE id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending' AND next_attempt_at <= now()
+  ORDER BY next_attempt_at
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil pgtype.Timestamptz `json:"lease_until"`
+	BatchSize  int32              `json:"batch_size"`
+}
+
+// Leases due deliveries to one worker by pushing next_attempt_at to lease_until,
+// so concurrent workers skip them until the lease runs out.
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookDeliveriesForEvent = `-- name: CreateWebhookDeliveriesForEvent :execrows
+INSERT INTO webhook_deliveries (event_id, subscription_id)
+SELECT $1, id
+FROM webhook_subscriptions
+WHERE username = $2
+  AND active
+  AND $3::varchar = ANY(event_types)
+`
+
+type CreateWebhookDeliveriesForEventParams struct {
+	EventID   int64  `json:"event_id"`
+	Username  string `json:"username"`
+	EventType string `json:"event_type"`
+}
+
+func (q *Queries) CreateWebhookDeliveriesForEvent(ctx context.Context, arg CreateWebhookDeliveriesForEventParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createWebhookDeliveriesForEvent, arg.EventID, arg.Username, arg.EventType)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const createWebhookEvent = `-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (
+  username, event_type, payload
+) VALUES (
+  $1, $2, $3
+)
+RETURNING id, username, event_type, payload, created_at
+`
+
+type CreateWebhookEventParams struct {
+	Username  string `json:"username"`
+	EventType string `json:"event_type"`
+	Payload   []byte `json:"payload"`
+}
+
+func (q *Queries) CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRow(ctx, createWebhookEvent, arg.Username, arg.EventType, arg.Payload)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.EventType,
+		&i.Payload,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (
+  username, url, secret, event_types
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING id, username, url, secret, event_types, active, created_at
+`
+
+type CreateWebhookSubscriptionParams struct {
+	Username   string   `json:"username"`
+	Url        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, createWebhookSubscription,
+		arg.Username,
+		arg.Url,
+		arg.Secret,
+		arg.EventTypes,
+	)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND username = $2
+`
+
+type DeleteWebhookSubscriptionParams struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhookSubscription, arg.ID, arg.Username)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteWebhookSubscriptions = `-- name: DeleteWebhookSubscriptions :exec
+DELETE FROM webhook_subscriptions
+WHERE username = $1
+`
+
+func (q *Queries) DeleteWebhookSubscriptions(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, deleteWebhookSubscriptions, username)
+	return err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at FROM webhook_deliveries
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+SELECT id, username, event_type, payload, created_at FROM webhook_events
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id int64) (WebhookEvent, error) {
+	row := q.db.QueryRow(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.EventType,
+		&i.Payload,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhookSubscription = `-- name: GetWebhookSubscription :one
+SELECT id, username, url, secret, event_types, active, created_at FROM webhook_subscriptions
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetWebhookSubscription(ctx context.Context, id int64) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, getWebhookSubscription, id)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY id DESC
+LIMIT $2
+OFFSET $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	SubscriptionID int64 `json:"subscription_id"`
+	Limit          int32 `json:"limit"`
+	Offset         int32 `json:"offset"`
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, arg.SubscriptionID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, username, url, secret, event_types, active, created_at FROM webhook_subscriptions
+WHERE username = $1
+ORDER BY id
+`
+
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context, username string) ([]WebhookSubscription, error) {
+	rows, err := q.db.Query(ctx, listWebhookSubscriptions, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebhookSubscription{}
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookDeliveryFailed = `-- name: MarkWebhookDeliveryFailed :one
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1
+RETURNING id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+`
+
+type MarkWebhookDeliveryFailedParams struct {
+	ID             int64                 `json:"id"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	NextAttemptAt  pgtype.Timestamptz    `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4           `json:"last_status_code"`
+	LastError      pgtype.Text           `json:"last_error"`
+}
+
+func (q *Queries) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, markWebhookDeliveryFailed,
+		arg.ID,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const markWebhookDeliverySucceeded = `-- name: MarkWebhookDeliverySucceeded :one
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = now()
+WHERE id = $1
+RETURNING id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+`
+
+type MarkWebhookDeliverySucceededParams struct {
+	ID             int64       `json:"id"`
+	LastStatusCode pgtype.Int4 `json:"last_status_code"`
+}
+
+func (q *Queries) MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, markWebhookDeliverySucceeded, arg.ID, arg.LastStatusCode)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const replayWebhookDelivery = `-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = now(), last_status_code = NULL, last_error = NULL, delivered_at = NULL
+WHERE id = $1
+RETURNING id, event_id, subscription_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at
+`
+
+func (q *Queries) ReplayWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, replayWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
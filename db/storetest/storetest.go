@@ -0,0 +1,1697 @@
+// Package storetest checks that a db.Store behaves like the Postgres store: the same results,
+// the same errors and the same transaction semantics. Every implementation runs it from its
+// own tests, so the in-memory store cannot drift from the one the server runs on.
+//
+// The tests create their own users and accounts and never assume a table is empty, so they
+// can share a database with other tests.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	db "example.com/db/sqlc"
+	"example.com/db/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the suite, calling newStore for a store in each test.
+func Run(t *testing.T, newStore func(t *testing.T) db.Store) {
+	testCases := []struct {
+		Name string
+		Test func(t *testing.T, store db.Store)
+	}{
+		{"Users", testUsers},
+		{"EnableTOTPTx", testEnableTOTPTx},
+		{"ChangePasswordTx", testChangePasswordTx},
+		{"Login Failures", testLoginFailures},
+		{"VerifyEmailTx", testVerifyEmailTx},
+		{"Rekey", testRekey},
+		{"IndexUserEmail", testIndexUserEmail},
+		{"API Keys", testApiKeys},
+		{"Request Nonces", testRequestNonces},
+		{"Accounts", testAccounts},
+		{"Account Balances", testAccountBalances},
+		{"Transfers", testTransfers},
+		{"ExecTx Rollback", testExecTxRollback},
+		{"TransferTx", testTransferTx},
+		{"TransferTx Concurrent", testTransferTxConcurrent},
+		{"TransferTx Insufficient Funds", testTransferTxInsufficientFunds},
+		{"TransferTx Frozen", testTransferTxFrozen},
+		{"ReverseTransferTx", testReverseTransferTx},
+		{"CloseAccountTx", testCloseAccountTx},
+		{"Data Requests", testDataRequests},
+		{"CreateAccountTx", testCreateAccountTx},
+		{"ChargeOverdraftInterestTx", testChargeOverdraftInterestTx},
+		{"PostInterestTx", testPostInterestTx},
+		{"Webhook Deliveries", testWebhookDeliveries},
+		{"Audit Log", testAuditLog},
+		{"Ping", testPing},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Test(t, newStore(t))
+		})
+	}
+}
+
+func testUsers(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	user := createUser(t, store)
+	got, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, got.Username)
+	require.Equal(t, user.Email, got.Email)
+	require.NotZero(t, got.CraetedAt)
+	require.Equal(t, db.UserRoleCustomer, got.Role)
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username:     user.Username,
+		PasswordHash: "hash",
+		FullName:     "Someone Else",
+		Email:        util.RandomString(12) + "@example.com",
+	})
+	requirePgError(t, err, "23505")
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username:     util.RandomString(12),
+		PasswordHash: "hash",
+		FullName:     "Someone Else",
+		Email:        user.Email,
+	})
+	requirePgError(t, err, "23505")
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username:     util.RandomString(12),
+		PasswordHash: "hash",
+		FullName:     "Someone Else",
+		Email:        strings.ToUpper(user.Email),
+	})
+	requirePgError(t, err, "23505")
+
+	_, err = store.GetUser(ctx, util.RandomString(12))
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	promoted, err := store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleTeller})
+	require.NoError(t, err)
+	require.Equal(t, db.UserRoleTeller, promoted.Role)
+
+	_, err = store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: "superuser"})
+	requirePgError(t, err, "22P02")
+
+	_, err = store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: util.RandomString(12), Role: db.UserRoleAdmin})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testEnableTOTPTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	_, err := store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{Username: user.Username, Step: 1})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	pending, err := store.SetUserTOTPSecret(ctx, db.SetUserTOTPSecretParams{Username: user.Username, TotpSecret: []byte("sealed")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("sealed"), pending.TotpSecret)
+	require.False(t, pending.TotpEnabledAt.Valid)
+
+	enabled, err := store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{
+		Username:         user.Username,
+		Step:             100,
+		BackupCodeHashes: []string{"hash-1", "hash-2"},
+	})
+	require.NoError(t, err)
+	require.True(t, enabled.TotpEnabledAt.Valid)
+	require.Equal(t, int64(100), enabled.TotpLastStep.Int64)
+
+	// A step is accepted once, and only after the last one accepted.
+	for _, tc := range []struct {
+		Step int64
+		Rows int64
+	}{{100, 0}, {99, 0}, {101, 1}, {101, 0}} {
+		rows, err := store.UseTOTPStep(ctx, db.UseTOTPStepParams{Username: user.Username, TotpLastStep: pgtype.Int8{Int64: tc.Step, Valid: true}})
+		require.NoError(t, err)
+		require.Equal(t, tc.Rows, rows, "step %d", tc.Step)
+	}
+
+	rows, err := store.UseTOTPBackupCode(ctx, db.UseTOTPBackupCodeParams{Username: user.Username, CodeHash: "hash-1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+	rows, err = store.UseTOTPBackupCode(ctx, db.UseTOTPBackupCodeParams{Username: user.Username, CodeHash: "hash-1"})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	_, err = store.CreateTOTPBackupCode(ctx, db.CreateTOTPBackupCodeParams{Username: user.Username, CodeHash: "hash-2"})
+	requirePgError(t, err, "23505")
+
+	// Enrolling again turns two-factor authentication off until it is confirmed, and the
+	// confirmation replaces the backup codes.
+	pending, err = store.SetUserTOTPSecret(ctx, db.SetUserTOTPSecretParams{Username: user.Username, TotpSecret: []byte("resealed")})
+	require.NoError(t, err)
+	require.False(t, pending.TotpEnabledAt.Valid)
+	require.False(t, pending.TotpLastStep.Valid)
+
+	_, err = store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{Username: user.Username, Step: 5, BackupCodeHashes: []string{"hash-3"}})
+	require.NoError(t, err)
+	rows, err = store.UseTOTPBackupCode(ctx, db.UseTOTPBackupCodeParams{Username: user.Username, CodeHash: "hash-2"})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+	rows, err = store.UseTOTPBackupCode(ctx, db.UseTOTPBackupCodeParams{Username: user.Username, CodeHash: "hash-3"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+}
+
+func testChangePasswordTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	found, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: user.Email})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, found.Username)
+	_, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: util.RandomString(12) + "@example.com"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	changedAt, err := store.GetUserTokensValidFrom(ctx, user.Username)
+	require.NoError(t, err)
+	require.False(t, changedAt.Valid)
+	_, err = store.GetUserTokensValidFrom(ctx, util.RandomString(12))
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	now := time.Now().Truncate(time.Microsecond)
+	changed, err := store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{Username: user.Username, PasswordHash: "hash-2", ChangedAt: now})
+	require.NoError(t, err)
+	require.Equal(t, "hash-2", changed.PasswordHash)
+	require.True(t, changed.PasswordChangedAt.Time.Equal(now))
+	validFrom, err := store.GetUserTokensValidFrom(ctx, user.Username)
+	require.NoError(t, err)
+	require.True(t, validFrom.Time.Equal(now))
+
+	// A change of role revokes the tokens issued before it too.
+	_, err = store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleTeller})
+	require.NoError(t, err)
+	validFrom, err = store.GetUserTokensValidFrom(ctx, user.Username)
+	require.NoError(t, err)
+	require.True(t, validFrom.Time.After(now))
+	_, err = store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleCustomer})
+	require.NoError(t, err)
+
+	// A reset token is good once, for its own user, until it expires.
+	expiresAt := pgtype.Timestamptz{Time: now.Add(time.Hour), Valid: true}
+	token, err := store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{TokenHash: "token-1", Username: user.Username, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	_, err = store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{TokenHash: "token-1", Username: user.Username, ExpiresAt: expiresAt})
+	requirePgError(t, err, "23505")
+	_, err = store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{TokenHash: "token-2", Username: util.RandomString(12), ExpiresAt: expiresAt})
+	requirePgError(t, err, "23503")
+	_, err = store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		TokenHash: "token-expired", Username: user.Username, ExpiresAt: pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true},
+	})
+	require.NoError(t, err)
+
+	got, err := store.GetPasswordResetToken(ctx, token.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, got.Username)
+	_, err = store.GetPasswordResetToken(ctx, "token-expired")
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	other := createUser(t, store)
+	_, err = store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{Username: other.Username, PasswordHash: "stolen", ChangedAt: now, ResetTokenHash: token.TokenHash})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{Username: user.Username, PasswordHash: "hash-3", ChangedAt: now, ResetTokenHash: "token-expired"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{Username: user.Username, PasswordHash: "hash-3", ChangedAt: now, ResetTokenHash: token.TokenHash})
+	require.NoError(t, err)
+	_, err = store.GetPasswordResetToken(ctx, token.TokenHash)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{Username: user.Username, PasswordHash: "hash-4", ChangedAt: now, ResetTokenHash: token.TokenHash})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// The history holds the passwords before the current one, newest first.
+	history, err := store.ListPasswordHistory(ctx, db.ListPasswordHistoryParams{Username: user.Username, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, "hash-2", history[0].PasswordHash)
+	require.Equal(t, user.PasswordHash, history[1].PasswordHash)
+
+	history, err = store.ListPasswordHistory(ctx, db.ListPasswordHistoryParams{Username: user.Username, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+
+	_, err = store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{Username: util.RandomString(12), PasswordHash: "hash", ChangedAt: now})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testLoginFailures(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	username := util.RandomString(12)
+	key := db.GetLoginFailureParams{Scope: db.LoginFailureScopeUsername, Key: username}
+	record := func(windowStart time.Time) db.LoginFailure {
+		failure, err := store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+			Scope:       key.Scope,
+			Key:         key.Key,
+			WindowStart: pgtype.Timestamptz{Time: windowStart, Valid: true},
+		})
+		require.NoError(t, err)
+		return failure
+	}
+
+	// Usernames are counted whether or not they exist.
+	_, err := store.GetLoginFailure(ctx, key)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	require.EqualValues(t, 1, record(time.Now().Add(-time.Hour)).Failures)
+	failure := record(time.Now().Add(-time.Hour))
+	require.EqualValues(t, 2, failure.Failures)
+	require.WithinDuration(t, time.Now(), failure.LastFailedAt.Time, time.Minute)
+	require.False(t, failure.LockedUntil.Valid)
+
+	// The same key in another scope is counted apart.
+	ip, err := store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{Scope: db.LoginFailureScopeIp, Key: username})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, ip.Failures)
+	_, err = store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{Scope: "device", Key: username})
+	requirePgError(t, err, "22P02")
+
+	// Failures from before the window are forgotten.
+	require.EqualValues(t, 1, record(time.Now().Add(time.Minute)).Failures)
+	require.EqualValues(t, 2, record(time.Now().Add(-time.Hour)).Failures)
+
+	lockedUntil := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	locked, err := store.LockLogin(ctx, db.LockLoginParams{Scope: key.Scope, Key: key.Key, LockedUntil: pgtype.Timestamptz{Time: lockedUntil, Valid: true}})
+	require.NoError(t, err)
+	require.True(t, locked.LockedUntil.Time.Equal(lockedUntil))
+	failure = record(time.Now().Add(-time.Hour))
+	require.EqualValues(t, 3, failure.Failures)
+	require.True(t, failure.LockedUntil.Time.Equal(lockedUntil))
+
+	// Once the lockout ends, the count starts over.
+	_, err = store.LockLogin(ctx, db.LockLoginParams{Scope: key.Scope, Key: key.Key, LockedUntil: pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true}})
+	require.NoError(t, err)
+	failure = record(time.Now().Add(-time.Hour))
+	require.EqualValues(t, 1, failure.Failures)
+	require.False(t, failure.LockedUntil.Valid)
+
+	_, err = store.LockLogin(ctx, db.LockLoginParams{Scope: key.Scope, Key: util.RandomString(12), LockedUntil: pgtype.Timestamptz{Time: lockedUntil, Valid: true}})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	deleted, err := store.DeleteLoginFailure(ctx, db.DeleteLoginFailureParams{Scope: key.Scope, Key: key.Key})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+	deleted, err = store.DeleteLoginFailure(ctx, db.DeleteLoginFailureParams{Scope: key.Scope, Key: key.Key})
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+	_, err = store.GetLoginFailure(ctx, key)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	got, err := store.GetLoginFailure(ctx, db.GetLoginFailureParams{Scope: db.LoginFailureScopeIp, Key: username})
+	require.NoError(t, err)
+	require.Equal(t, ip, got)
+}
+
+func testVerifyEmailTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	require.False(t, user.EmailVerifiedAt.Valid)
+
+	found, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: strings.ToUpper(user.Email)})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, found.Username)
+
+	expiresAt := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	verifyEmail, err := store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{Username: user.Username, Email: user.Email, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	require.False(t, verifyEmail.UsedAt.Valid)
+	_, err = store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{Username: util.RandomString(12), Email: user.Email, ExpiresAt: expiresAt})
+	requirePgError(t, err, "23503")
+
+	got, err := store.GetVerifyEmail(ctx, verifyEmail.ID)
+	require.NoError(t, err)
+	require.Equal(t, verifyEmail, got)
+
+	// A code is good once.
+	verified, err := store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{ID: verifyEmail.ID, Username: user.Username, Email: user.Email})
+	require.NoError(t, err)
+	require.True(t, verified.EmailVerifiedAt.Valid)
+	_, err = store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{ID: verifyEmail.ID, Username: user.Username, Email: user.Email})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	expired, err := store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username: user.Username, Email: user.Email, ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	require.NoError(t, err)
+	_, err = store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{ID: expired.ID, Username: user.Username, Email: user.Email})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// Changing the name or the case of the address, which is not a change of address, keeps it verified.
+	updated, err := store.UpdateUser(ctx, db.UpdateUserParams{
+		Username: user.Username,
+		FullName: pgtype.Text{String: "New Name", Valid: true},
+		Email:    pgtype.Text{String: strings.ToUpper(user.Email), Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "New Name", updated.FullName)
+	require.Equal(t, strings.ToUpper(user.Email), updated.Email)
+	require.True(t, updated.EmailVerifiedAt.Valid)
+
+	// A new address has to be verified, and a code sent to the old one no longer does it.
+	stale, err := store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{Username: user.Username, Email: updated.Email, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	newEmail := util.RandomString(12) + "@example.com"
+	updated, err = store.UpdateUser(ctx, db.UpdateUserParams{Username: user.Username, Email: pgtype.Text{String: newEmail, Valid: true}, EmailChanged: true})
+	require.NoError(t, err)
+	require.Equal(t, "New Name", updated.FullName)
+	require.Equal(t, newEmail, updated.Email)
+	require.False(t, updated.EmailVerifiedAt.Valid)
+	_, err = store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{ID: stale.ID, Username: user.Username, Email: stale.Email})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	other := createUser(t, store)
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{Username: user.Username, Email: pgtype.Text{String: strings.ToUpper(other.Email), Valid: true}, EmailChanged: true})
+	requirePgError(t, err, "23505")
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{Username: util.RandomString(12), FullName: pgtype.Text{String: "Nobody", Valid: true}})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+// testRekey stores made-up ciphertext, as the pii package would: the store only keeps it.
+func testRekey(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	keyID := pgtype.Text{String: "v1", Valid: true}
+
+	// A user in plaintext is listed for every key, after a username before theirs.
+	users, err := store.ListUsersToRekey(ctx, db.ListUsersToRekeyParams{
+		KeyID: keyID.String, AfterUsername: user.Username[:len(user.Username)-1], LimitCount: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, user.Username, users[0].Username)
+
+	rekey := db.RekeyUserParams{
+		FullName:    "sealed name",
+		Email:       "sealed " + user.Email,
+		EmailIndex:  []byte(user.Username),
+		DataKey:     []byte("data key 1"),
+		DataKeyID:   keyID,
+		Username:    user.Username,
+		OldFullName: user.FullName,
+		OldEmail:    "changed meanwhile",
+	}
+	rows, err := store.RekeyUser(ctx, rekey)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+	rekey.OldEmail = user.Email
+	rows, err = store.RekeyUser(ctx, rekey)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	users, err = store.ListUsersToRekey(ctx, db.ListUsersToRekeyParams{
+		KeyID: keyID.String, AfterUsername: user.Username[:len(user.Username)-1], LimitCount: 1,
+	})
+	require.NoError(t, err)
+	if len(users) > 0 {
+		require.NotEqual(t, user.Username, users[0].Username)
+	}
+
+	// An encrypted email is found, and kept unique, by its index.
+	found, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{EmailIndex: rekey.EmailIndex, Email: user.Email})
+	require.NoError(t, err)
+	require.Equal(t, rekey.Email, found.Email)
+	require.Equal(t, rekey.DataKey, found.DataKey)
+	_, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: user.Email})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	other := createUser(t, store)
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{
+		Username:   other.Username,
+		Email:      pgtype.Text{String: "sealed again", Valid: true},
+		EmailIndex: rekey.EmailIndex,
+	})
+	requirePgError(t, err, "23505")
+
+	// An update made with a data key that has since changed is refused.
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{Username: user.Username, FullName: pgtype.Text{String: "stale", Valid: true}})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	updated, err := store.UpdateUser(ctx, db.UpdateUserParams{
+		Username:        user.Username,
+		FullName:        pgtype.Text{String: "sealed new name", Valid: true},
+		PreviousDataKey: rekey.DataKey,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "sealed new name", updated.FullName)
+	require.Equal(t, rekey.Email, updated.Email)
+	require.Equal(t, rekey.DataKey, updated.DataKey)
+
+	verified, err := store.SetUserEmailVerified(ctx, db.SetUserEmailVerifiedParams{Username: user.Username, EmailIndex: rekey.EmailIndex})
+	require.NoError(t, err)
+	require.True(t, verified.EmailVerifiedAt.Valid)
+
+	verifyEmail, err := store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username: user.Username, Email: user.Email, ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+	verifyEmails, err := store.ListVerifyEmailsToRekey(ctx, db.ListVerifyEmailsToRekeyParams{KeyID: keyID.String, AfterID: verifyEmail.ID - 1, LimitCount: 1})
+	require.NoError(t, err)
+	require.Len(t, verifyEmails, 1)
+	require.Equal(t, verifyEmail.ID, verifyEmails[0].ID)
+
+	rows, err = store.RekeyVerifyEmail(ctx, db.RekeyVerifyEmailParams{
+		Email: "sealed", DataKey: []byte("data key 2"), DataKeyID: keyID, ID: verifyEmail.ID, OldEmail: verifyEmail.Email,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+	got, err := store.GetVerifyEmail(ctx, verifyEmail.ID)
+	require.NoError(t, err)
+	require.Equal(t, "sealed", got.Email)
+	require.Equal(t, keyID, got.DataKeyID)
+	verifyEmails, err = store.ListVerifyEmailsToRekey(ctx, db.ListVerifyEmailsToRekeyParams{KeyID: keyID.String, AfterID: verifyEmail.ID - 1, LimitCount: 1})
+	require.NoError(t, err)
+	if len(verifyEmails) > 0 {
+		require.NotEqual(t, verifyEmail.ID, verifyEmails[0].ID)
+	}
+}
+
+func testIndexUserEmail(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	legacy := createUser(t, store)
+	arg := db.ListUsersToIndexParams{AfterUsername: legacy.Username[:len(legacy.Username)-1], LimitCount: 1}
+
+	// A user in plaintext without an index is listed until they get one.
+	users, err := store.ListUsersToIndex(ctx, arg)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, legacy.Username, users[0].Username)
+
+	index := []byte("index " + legacy.Username)
+	rows, err := store.IndexUserEmail(ctx, db.IndexUserEmailParams{EmailIndex: index, Username: legacy.Username, OldEmail: "changed meanwhile"})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+	rows, err = store.IndexUserEmail(ctx, db.IndexUserEmailParams{EmailIndex: index, Username: legacy.Username, OldEmail: legacy.Email})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	users, err = store.ListUsersToIndex(ctx, arg)
+	require.NoError(t, err)
+	if len(users) > 0 {
+		require.NotEqual(t, legacy.Username, users[0].Username)
+	}
+
+	// The user is still found by their plaintext email, and now by its index.
+	found, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: strings.ToUpper(legacy.Email)})
+	require.NoError(t, err)
+	require.Equal(t, legacy.Username, found.Username)
+	found, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{EmailIndex: index, Email: "sealed"})
+	require.NoError(t, err)
+	require.Equal(t, legacy.Username, found.Username)
+
+	// A user with the same email, encrypted, is refused.
+	username := util.RandomString(12)
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username:     username,
+		PasswordHash: util.RandomString(32),
+		FullName:     "sealed name",
+		Email:        "sealed " + legacy.Email,
+		EmailIndex:   index,
+		DataKey:      []byte("data key"),
+		DataKeyID:    pgtype.Text{String: "v1", Valid: true},
+	})
+	requirePgError(t, err, "23505")
+}
+
+func testApiKeys(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	arg := db.CreateApiKeyParams{
+		Username:      user.Username,
+		Name:          "billing",
+		KeyHash:       util.RandomString(32),
+		Prefix:        "sbk_abcd",
+		Scopes:        []string{"accounts:read", "transfers:write"},
+		AllowedIps:    []string{"192.0.2.0/24"},
+		ExpiresAt:     pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		SigningSecret: pgtype.Text{String: "sks_" + util.RandomString(32), Valid: true},
+	}
+	key, err := store.CreateApiKey(ctx, arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Scopes, key.Scopes)
+	require.Equal(t, arg.AllowedIps, key.AllowedIps)
+	require.Equal(t, arg.SigningSecret, key.SigningSecret)
+	require.False(t, key.LastUsedAt.Valid)
+	require.False(t, key.RevokedAt.Valid)
+
+	got, err := store.GetApiKeyByHash(ctx, arg.KeyHash)
+	require.NoError(t, err)
+	require.Equal(t, key, got)
+	_, err = store.GetApiKeyByHash(ctx, util.RandomString(32))
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	got, err = store.GetApiKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.Equal(t, key, got)
+	_, err = store.GetApiKey(ctx, key.ID+1000)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// Names are unique among a user's keys that are not revoked.
+	duplicate := arg
+	duplicate.KeyHash = util.RandomString(32)
+	_, err = store.CreateApiKey(ctx, duplicate)
+	requirePgError(t, err, "23505")
+	duplicate.Username = createUser(t, store).Username
+	_, err = store.CreateApiKey(ctx, duplicate)
+	require.NoError(t, err)
+
+	unrestricted := arg
+	unrestricted.Name = "reports"
+	unrestricted.KeyHash = util.RandomString(32)
+	unrestricted.AllowedIps = []string{}
+	unrestricted.ExpiresAt = pgtype.Timestamptz{}
+	unrestricted.SigningSecret = pgtype.Text{}
+	second, err := store.CreateApiKey(ctx, unrestricted)
+	require.NoError(t, err)
+	require.Empty(t, second.AllowedIps)
+	require.False(t, second.ExpiresAt.Valid)
+	require.False(t, second.SigningSecret.Valid)
+
+	require.NoError(t, store.TouchApiKey(ctx, key.ID))
+	got, err = store.GetApiKeyByHash(ctx, arg.KeyHash)
+	require.NoError(t, err)
+	require.True(t, got.LastUsedAt.Valid)
+
+	keys, err := store.ListApiKeys(ctx, user.Username)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, key.ID, keys[0].ID)
+	require.Equal(t, second.ID, keys[1].ID)
+
+	// Only the owner revokes a key, once. A revoked key stays, and frees its name.
+	revoked, err := store.RevokeApiKey(ctx, db.RevokeApiKeyParams{ID: key.ID, Username: duplicate.Username})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+	revoked, err = store.RevokeApiKey(ctx, db.RevokeApiKeyParams{ID: key.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+	revoked, err = store.RevokeApiKey(ctx, db.RevokeApiKeyParams{ID: key.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	got, err = store.GetApiKeyByHash(ctx, arg.KeyHash)
+	require.NoError(t, err)
+	require.True(t, got.RevokedAt.Valid)
+	keys, err = store.ListApiKeys(ctx, user.Username)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	arg.KeyHash = util.RandomString(32)
+	_, err = store.CreateApiKey(ctx, arg)
+	require.NoError(t, err)
+
+	arg.Username = util.RandomString(12)
+	arg.KeyHash = util.RandomString(32)
+	_, err = store.CreateApiKey(ctx, arg)
+	requirePgError(t, err, "23503")
+}
+
+func testRequestNonces(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	var keys [2]db.ApiKey
+	for i := range keys {
+		var err error
+		keys[i], err = store.CreateApiKey(ctx, db.CreateApiKeyParams{
+			Username: user.Username, Name: util.RandomString(8), KeyHash: util.RandomString(32), Prefix: "sbk_abcd",
+			Scopes: []string{"transfers:write"}, AllowedIps: []string{},
+		})
+		require.NoError(t, err)
+	}
+
+	use := func(apiKeyID int64, nonce string, expiresAt time.Time) int64 {
+		used, err := store.UseRequestNonce(ctx, db.UseRequestNonceParams{
+			ApiKeyID: apiKeyID, Nonce: nonce, ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		})
+		require.NoError(t, err)
+		return used
+	}
+
+	// A nonce is used once per key until it expires.
+	nonce := util.RandomString(16)
+	require.Equal(t, int64(1), use(keys[0].ID, nonce, time.Now().Add(time.Minute)))
+	require.Zero(t, use(keys[0].ID, nonce, time.Now().Add(time.Minute)), "replayed")
+	require.Equal(t, int64(1), use(keys[1].ID, nonce, time.Now().Add(time.Minute)), "nonces are per key")
+
+	expired := util.RandomString(16)
+	require.Equal(t, int64(1), use(keys[0].ID, expired, time.Now().Add(-time.Minute)))
+	require.Equal(t, int64(1), use(keys[0].ID, expired, time.Now().Add(-time.Minute)))
+
+	_, err := store.UseRequestNonce(ctx, db.UseRequestNonceParams{
+		ApiKeyID: -1, Nonce: nonce, ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	requirePgError(t, err, "23503")
+
+	// Sweeping deletes the expired nonces only.
+	deleted, err := store.DeleteExpiredRequestNonces(ctx)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, deleted, int64(1))
+	require.Zero(t, use(keys[0].ID, nonce, time.Now().Add(time.Minute)), "still replayed")
+}
+
+func testAccounts(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	var accounts []db.Account
+	for _, currency := range util.Currencies[:3] {
+		accounts = append(accounts, createAccount(t, store, user.Username, currency, "12.345"))
+	}
+
+	account := accounts[0]
+	require.Equal(t, user.Username, account.Owner)
+	requireAmount(t, "12.35", account.Balance)
+	requireAmount(t, "0", account.OverdraftLimit)
+	require.False(t, account.Frozen)
+	require.Equal(t, db.AccountTypeChecking, account.AccountType)
+
+	got, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.ID, got.ID)
+	requireAmount(t, "12.35", got.Balance)
+
+	got, err = store.GetAccountByOwnerCurrencyAndType(ctx, db.GetAccountByOwnerCurrencyAndTypeParams{
+		Owner:       user.Username,
+		Currency:    account.Currency,
+		AccountType: account.AccountType,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account.ID, got.ID)
+
+	_, err = store.GetAccountByOwnerCurrencyAndType(ctx, db.GetAccountByOwnerCurrencyAndTypeParams{
+		Owner:       user.Username,
+		Currency:    account.Currency,
+		AccountType: db.AccountTypeSavings,
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	page, err := store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: user.Username, Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, accounts[1].ID, page[0].ID)
+	require.Equal(t, accounts[2].ID, page[1].ID)
+
+	page, err = store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: user.Username, Limit: 2, Offset: 3})
+	require.NoError(t, err)
+	require.NotNil(t, page)
+	require.Empty(t, page)
+
+	_, err = store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: user.Username, Limit: -1})
+	require.Error(t, err)
+
+	// one account per owner, currency and account type
+	_, err = store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:       user.Username,
+		Balance:     numeric("0"),
+		Currency:    account.Currency,
+		AccountType: db.AccountTypeChecking,
+	})
+	requirePgError(t, err, "23505")
+
+	savings, err := store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:       user.Username,
+		Balance:     numeric("0"),
+		Currency:    account.Currency,
+		AccountType: db.AccountTypeSavings,
+	})
+	require.NoError(t, err)
+	got, err = store.GetAccountByOwnerCurrencyAndType(ctx, db.GetAccountByOwnerCurrencyAndTypeParams{
+		Owner:       user.Username,
+		Currency:    account.Currency,
+		AccountType: db.AccountTypeSavings,
+	})
+	require.NoError(t, err)
+	require.Equal(t, savings.ID, got.ID)
+
+	_, err = store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:       util.RandomString(12),
+		Balance:     numeric("0"),
+		Currency:    account.Currency,
+		AccountType: db.AccountTypeChecking,
+	})
+	requirePgError(t, err, "23503")
+
+	_, err = store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:       user.Username,
+		Balance:     numeric("100000000"),
+		Currency:    util.Currencies[3],
+		AccountType: db.AccountTypeChecking,
+	})
+	requirePgError(t, err, "22003")
+
+	_, err = store.GetAccount(ctx, -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = store.SetAccountFrozen(ctx, db.SetAccountFrozenParams{ID: -1, Frozen: true})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// updating a missing row with :exec is not an error
+	require.NoError(t, store.UpdateAccountBalance(ctx, db.UpdateAccountBalanceParams{ID: -1, Balance: numeric("1")}))
+
+	_, err = store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account.ID, OverdraftLimit: numeric("-1")})
+	requirePgError(t, err, "23514")
+
+	// an account with entries cannot be deleted; one without can
+	_, err = store.CreateEntry(ctx, db.CreateEntryParams{AccountID: account.ID, Amount: numeric("1")})
+	require.NoError(t, err)
+	requirePgError(t, store.DeleteAccount(ctx, account.ID), "23503")
+
+	require.NoError(t, store.DeleteAccount(ctx, accounts[2].ID))
+	_, err = store.GetAccount(ctx, accounts[2].ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testAccountBalances(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, createUser(t, store).Username, util.RandomCurrency(), "10")
+
+	updated, err := store.AddAccountBalance(ctx, db.AddAccountBalanceParams{ID: account.ID, Amount: numeric("2.50")})
+	require.NoError(t, err)
+	requireAmount(t, "12.50", updated.Balance)
+
+	require.NoError(t, store.SubtractAccountBalance(ctx, db.SubtractAccountBalanceParams{ID: account.ID, Amount: numeric("0.5")}))
+	requireBalance(t, store, account.ID, "12")
+
+	// a debit must stay within the overdraft limit
+	_, err = store.DebitAccountBalance(ctx, db.DebitAccountBalanceParams{ID: account.ID, Amount: numeric("12.01")})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	requireBalance(t, store, account.ID, "12")
+
+	_, err = store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account.ID, OverdraftLimit: numeric("5")})
+	require.NoError(t, err)
+
+	updated, err = store.DebitAccountBalance(ctx, db.DebitAccountBalanceParams{ID: account.ID, Amount: numeric("17")})
+	require.NoError(t, err)
+	requireAmount(t, "-5", updated.Balance)
+
+	overdrawn, err := store.ListOverdrawnAccounts(ctx)
+	require.NoError(t, err)
+	require.Contains(t, accountIDs(overdrawn), account.ID)
+}
+
+func testTransfers(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	owner := createUser(t, store).Username
+	account1 := createAccount(t, store, owner, util.Currencies[0], "0")
+	account2 := createAccount(t, store, owner, util.Currencies[1], "0")
+
+	_, err := store.CreateTransfer(ctx, db.CreateTransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: numeric("0.001")})
+	requirePgError(t, err, "23514")
+
+	_, err = store.CreateTransfer(ctx, db.CreateTransferParams{FromAccountID: account1.ID, ToAccountID: -1, Amount: numeric("1")})
+	requirePgError(t, err, "23503")
+
+	var transfers []db.Transfer
+	for i := 0; i < 3; i++ {
+		transfer, err := store.CreateTransfer(ctx, db.CreateTransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: numeric("1.50")})
+		require.NoError(t, err)
+		transfers = append(transfers, transfer)
+	}
+
+	got, err := store.GetTransferFromAndToAccount(ctx, db.GetTransferFromAndToAccountParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Limit:         10,
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+
+	got, err = store.GetTransferToAccount(ctx, db.GetTransferToAccountParams{ToAccountID: account1.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = store.SetTransferReversalOf(ctx, db.SetTransferReversalOfParams{ID: transfers[1].ID, ReversalOf: pgtype.Int8{Int64: transfers[0].ID, Valid: true}})
+	require.NoError(t, err)
+	_, err = store.SetTransferReversalOf(ctx, db.SetTransferReversalOfParams{ID: transfers[2].ID, ReversalOf: pgtype.Int8{Int64: transfers[0].ID, Valid: true}})
+	requirePgError(t, err, "23505")
+
+	requirePgError(t, store.DeleteTransfer(ctx, transfers[0].ID), "23503")
+	require.NoError(t, store.DeleteTransfer(ctx, transfers[2].ID))
+	_, err = store.GetTransfer(ctx, transfers[2].ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testExecTxRollback(t *testing.T, store db.Store) {
+	runner, ok := store.(db.TxRunner)
+	if !ok {
+		t.Skip("store does not run its own transactions")
+	}
+	ctx := context.Background()
+	errRollback := errors.New("roll back")
+
+	username := util.RandomString(12)
+	err := runner.ExecTx(ctx, func(q db.Querier) error {
+		_, err := q.CreateUser(ctx, db.CreateUserParams{
+			Username:     username,
+			PasswordHash: "hash",
+			FullName:     "Rolled Back",
+			Email:        username + "@example.com",
+		})
+		require.NoError(t, err)
+
+		// the transaction sees its own changes
+		_, err = q.GetUser(ctx, username)
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	_, err = store.GetUser(ctx, username)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testTransferTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, createUser(t, store).Username, "USD", "100")
+	account2 := createAccount(t, store, createUser(t, store).Username, "USD", "0")
+
+	result, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 30})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Attempts)
+
+	require.Equal(t, account1.ID, result.Transfer.FromAccountID)
+	require.Equal(t, account2.ID, result.Transfer.ToAccountID)
+	requireAmount(t, "30", result.Transfer.Amount)
+	require.NotZero(t, result.Transfer.CreatedAt)
+
+	require.Equal(t, account1.ID, result.FromEntry.AccountID)
+	requireAmount(t, "-30", result.FromEntry.Amount)
+	require.Equal(t, account2.ID, result.ToEntry.AccountID)
+	requireAmount(t, "30", result.ToEntry.Amount)
+
+	requireAmount(t, "70", result.FromAccount.Balance)
+	requireAmount(t, "30", result.ToAccount.Balance)
+	requireBalance(t, store, account1.ID, "70")
+	requireBalance(t, store, account2.ID, "30")
+
+	entries, err := store.ListEntriesForAccount(ctx, db.ListEntriesForAccountParams{AccountID: account2.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, result.ToEntry.ID, entries[0].ID)
+
+	latest, err := store.GetLatestEntryIDForAccount(ctx, account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.FromEntry.ID, latest)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: -1, Amount: 1})
+	requirePgError(t, err, "23503")
+	requireBalance(t, store, account1.ID, "70")
+}
+
+func testTransferTxConcurrent(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, createUser(t, store).Username, "EUR", "100")
+	account2 := createAccount(t, store, createUser(t, store).Username, "EUR", "100")
+
+	n := 5
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10})
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	requireBalance(t, store, account1.ID, "50")
+	requireBalance(t, store, account2.ID, "150")
+
+	// only the available money moves when transfers race for it
+	errs = make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 20})
+			errs <- err
+		}()
+	}
+	failed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, db.ErrInsufficientFunds)
+			failed++
+		}
+	}
+	require.Equal(t, n-2, failed)
+
+	requireBalance(t, store, account1.ID, "10")
+	requireBalance(t, store, account2.ID, "190")
+}
+
+func testTransferTxInsufficientFunds(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, createUser(t, store).Username, "CAD", "10")
+	account2 := createAccount(t, store, createUser(t, store).Username, "CAD", "0")
+
+	_, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 11})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	// the failed transfer left nothing behind
+	requireBalance(t, store, account1.ID, "10")
+	requireBalance(t, store, account2.ID, "0")
+	transfers, err := store.GetTransferFromAccount(ctx, db.GetTransferFromAccountParams{FromAccountID: account1.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+	entries, err := store.ListEntriesForAccount(ctx, db.ListEntriesForAccountParams{AccountID: account2.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	_, err = store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account1.ID, OverdraftLimit: numeric("5")})
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 15})
+	require.NoError(t, err)
+	requireAmount(t, "-5", result.FromAccount.Balance)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 1})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+	requireBalance(t, store, account1.ID, "-5")
+}
+
+func testTransferTxFrozen(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, createUser(t, store).Username, "SAR", "100")
+	account2 := createAccount(t, store, createUser(t, store).Username, "SAR", "100")
+
+	frozen, err := store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: account2.ID, Frozen: true})
+	require.NoError(t, err)
+	require.True(t, frozen.Frozen)
+
+	for _, arg := range []db.TransferTxParams{
+		{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10},
+		{FromAccountId: account2.ID, ToAccountId: account1.ID, Amount: 10},
+	} {
+		_, err = store.TransferTx(ctx, arg)
+		require.ErrorIs(t, err, db.ErrAccountFrozen)
+	}
+	requireBalance(t, store, account1.ID, "100")
+	requireBalance(t, store, account2.ID, "100")
+
+	_, err = store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: account2.ID, Frozen: false})
+	require.NoError(t, err)
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10})
+	require.NoError(t, err)
+
+	_, err = store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: -1, Frozen: true})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testReverseTransferTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, createUser(t, store).Username, "AED", "100")
+	account2 := createAccount(t, store, createUser(t, store).Username, "AED", "100")
+
+	original, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 40})
+	require.NoError(t, err)
+
+	// frozen accounts do not block a reversal
+	_, err = store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: account2.ID, Frozen: true})
+	require.NoError(t, err)
+
+	reversal, err := store.ReverseTransferTx(ctx, original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.ID, reversal.Transfer.FromAccountID)
+	require.Equal(t, account1.ID, reversal.Transfer.ToAccountID)
+	require.Equal(t, pgtype.Int8{Int64: original.Transfer.ID, Valid: true}, reversal.Transfer.ReversalOf)
+	requireBalance(t, store, account1.ID, "100")
+	requireBalance(t, store, account2.ID, "100")
+
+	_, err = store.ReverseTransferTx(ctx, original.Transfer.ID)
+	require.ErrorIs(t, err, db.ErrTransferReversed)
+
+	_, err = store.ReverseTransferTx(ctx, reversal.Transfer.ID)
+	require.ErrorIs(t, err, db.ErrReversalNotReversible)
+
+	_, err = store.ReverseTransferTx(ctx, -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// the recipient must still hold the money
+	_, err = store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: account2.ID, Frozen: false})
+	require.NoError(t, err)
+	spent, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10})
+	require.NoError(t, err)
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account2.ID, ToAccountId: account1.ID, Amount: 110})
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(ctx, spent.Transfer.ID)
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+}
+
+func testCloseAccountTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, createUser(t, store).Username, "ISK", "100")
+	account2 := createAccount(t, store, createUser(t, store).Username, "ISK", "0")
+
+	_, err := store.CloseAccountTx(ctx, account1.ID)
+	require.ErrorIs(t, err, db.ErrAccountNotEmpty)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10})
+	require.NoError(t, err)
+	_, err = store.CloseAccountTx(ctx, account2.ID)
+	require.ErrorIs(t, err, db.ErrAccountNotEmpty)
+	original, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account2.ID, ToAccountId: account1.ID, Amount: 10})
+	require.NoError(t, err)
+
+	closed, err := store.CloseAccountTx(ctx, account2.ID)
+	require.NoError(t, err)
+	require.True(t, closed.ClosedAt.Valid)
+
+	// closing it again changes nothing
+	again, err := store.CloseAccountTx(ctx, account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, closed.ClosedAt.Time.UTC(), again.ClosedAt.Time.UTC())
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 10})
+	require.ErrorIs(t, err, db.ErrAccountClosed)
+	_, err = store.ReverseTransferTx(ctx, original.Transfer.ID)
+	require.ErrorIs(t, err, db.ErrAccountClosed)
+	requireBalance(t, store, account1.ID, "100")
+	requireBalance(t, store, account2.ID, "0")
+
+	_, err = store.CloseAccountTx(ctx, -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testDataRequests(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	account := createAccount(t, store, user.Username, "HUF", "0")
+	other := createAccount(t, store, createUser(t, store).Username, "HUF", "50")
+	_, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: other.ID, ToAccountId: account.ID, Amount: 50})
+	require.NoError(t, err)
+
+	// An erasure waits for every account to be closed.
+	_, err = store.RequestErasureTx(ctx, db.RequestErasureTxParams{Username: user.Username})
+	require.ErrorIs(t, err, db.ErrAccountsOpen)
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account.ID, ToAccountId: other.ID, Amount: 50})
+	require.NoError(t, err)
+	_, err = store.CloseAccountTx(ctx, account.ID)
+	require.NoError(t, err)
+
+	request, err := store.RequestErasureTx(ctx, db.RequestErasureTxParams{Username: user.Username, Reason: "leaving"})
+	require.NoError(t, err)
+	require.Equal(t, db.DataRequestStatusPending, request.Status)
+	require.Equal(t, "leaving", request.Reason)
+
+	// One request of a kind at a time.
+	_, err = store.RequestErasureTx(ctx, db.RequestErasureTxParams{Username: user.Username})
+	requirePgError(t, err, "23505")
+
+	rejected, err := store.ReviewDataRequestTx(ctx, db.ReviewDataRequestTxParams{ID: request.ID, ReviewedBy: "admin", Note: "no"})
+	require.NoError(t, err)
+	require.Equal(t, db.DataRequestStatusRejected, rejected.Status)
+	require.Equal(t, "admin", rejected.ReviewedBy.String)
+	require.True(t, rejected.ReviewedAt.Valid)
+	unchanged, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.Email, unchanged.Email)
+
+	_, err = store.ReviewDataRequestTx(ctx, db.ReviewDataRequestTxParams{ID: request.ID, Approve: true})
+	require.ErrorIs(t, err, db.ErrDataRequestReviewed)
+
+	request, err = store.RequestErasureTx(ctx, db.RequestErasureTxParams{Username: user.Username})
+	require.NoError(t, err)
+	pending, err := store.ListDataRequests(ctx, db.ListDataRequestsParams{
+		Status:     db.NullDataRequestStatus{DataRequestStatus: db.DataRequestStatusPending, Valid: true},
+		LimitCount: 100,
+	})
+	require.NoError(t, err)
+	require.Contains(t, pending, request)
+
+	_, err = store.CreateApiKey(ctx, db.CreateApiKeyParams{
+		Username: user.Username, Name: "ci", KeyHash: util.RandomString(32), Prefix: "sb_", Scopes: []string{}, AllowedIps: []string{},
+	})
+	require.NoError(t, err)
+
+	// Approving it erases the user's personal data but keeps the financial records.
+	approved, err := store.ReviewDataRequestTx(ctx, db.ReviewDataRequestTxParams{ID: request.ID, Approve: true, ReviewedBy: "admin"})
+	require.NoError(t, err)
+	require.Equal(t, db.DataRequestStatusApproved, approved.Status)
+
+	erased, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.True(t, erased.ErasedAt.Valid)
+	require.Equal(t, db.ErasedFullName, erased.FullName)
+	require.NotEqual(t, user.Email, erased.Email)
+	require.NotEqual(t, user.PasswordHash, erased.PasswordHash)
+	require.True(t, erased.PasswordChangedAt.Time.After(user.PasswordChangedAt.Time))
+	_, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: user.Email})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	keys, err := store.ListApiKeys(ctx, user.Username)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	kept, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, kept.Owner)
+	transfers, err := store.ListTransfersByOwner(ctx, db.ListTransfersByOwnerParams{Owner: user.Username, LimitCount: 100})
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+
+	requests, err := store.ListDataRequestsByUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, []db.DataRequest{rejected, approved}, requests)
+}
+
+func testCreateAccountTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	subscription, err := store.CreateWebhookSubscription(ctx, db.CreateWebhookSubscriptionParams{
+		Username:   user.Username,
+		Url:        "https://example.com/hook",
+		Secret:     "secret",
+		EventTypes: []string{db.EventAccountCreated},
+	})
+	require.NoError(t, err)
+
+	account, err := store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:       user.Username,
+		Balance:     numeric("25"),
+		Currency:    "BHD",
+		AccountType: db.AccountTypeSavings,
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.AccountTypeSavings, account.AccountType)
+
+	// the opening balance is in the ledger
+	entries, err := store.ListEntriesForAccount(ctx, db.ListEntriesForAccountParams{AccountID: account.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	requireAmount(t, "25", entries[0].Amount)
+
+	rows, err := store.ReconcileAccounts(ctx)
+	require.NoError(t, err)
+	found := false
+	for _, row := range rows {
+		if row.Account.ID == account.ID {
+			found = true
+			requireAmount(t, "25", row.LedgerBalance)
+		}
+	}
+	require.True(t, found)
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{SubscriptionID: subscription.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	event, err := store.GetWebhookEvent(ctx, deliveries[0].EventID)
+	require.NoError(t, err)
+	require.Equal(t, db.EventAccountCreated, event.EventType)
+	require.Equal(t, user.Username, event.Username)
+
+	// a failed transaction opens nothing and publishes nothing
+	_, err = store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:       user.Username,
+		Balance:     numeric("0"),
+		Currency:    "BHD",
+		AccountType: db.AccountTypeSavings,
+	})
+	requirePgError(t, err, "23505")
+	deliveries, err = store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{SubscriptionID: subscription.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+}
+
+func testChargeOverdraftInterestTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, createUser(t, store).Username, "USD", "0")
+	_, err := store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account.ID, OverdraftLimit: numeric("2000")})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateAccountBalance(ctx, db.UpdateAccountBalanceParams{ID: account.ID, Balance: numeric("-1000")}))
+
+	arg := db.ChargeOverdraftInterestTxParams{
+		AccountID:  account.ID,
+		ChargeDate: time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC),
+		AnnualRate: numeric("0.365"),
+	}
+
+	result, err := store.ChargeOverdraftInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.True(t, result.Charged)
+	requireAmount(t, "1", result.Charge.Amount)
+	requireAmount(t, "-1001", result.Account.Balance)
+	require.Equal(t, result.Entry.ID, result.Charge.EntryID)
+
+	again, err := store.ChargeOverdraftInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.False(t, again.Charged)
+	requireBalance(t, store, account.ID, "-1001")
+
+	charges, err := store.ListOverdraftChargesForAccount(ctx, db.ListOverdraftChargesForAccountParams{AccountID: account.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, charges, 1)
+
+	// Interest does not take an account past its limit: 0.40 of the day's 1.00 is left.
+	_, err = store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account.ID, OverdraftLimit: numeric("1001.40")})
+	require.NoError(t, err)
+	arg.ChargeDate = arg.ChargeDate.AddDate(0, 0, 1)
+	result, err = store.ChargeOverdraftInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.True(t, result.Charged)
+	requireAmount(t, "0.40", result.Charge.Amount)
+	requireAmount(t, "-1001.40", result.Account.Balance)
+
+	// At the limit, nothing more is charged.
+	arg.ChargeDate = arg.ChargeDate.AddDate(0, 0, 1)
+	result, err = store.ChargeOverdraftInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.False(t, result.Charged)
+	requireBalance(t, store, account.ID, "-1001.40")
+}
+
+func testPostInterestTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, createUser(t, store).Username, "EUR", "100")
+	expense := createAccount(t, store, createUser(t, store).Username, "EUR", "0")
+
+	product, err := store.UpsertInterestProduct(ctx, db.UpsertInterestProductParams{
+		Currency:    account.Currency,
+		AccountType: account.AccountType,
+		AnnualRate:  numeric("0.05"),
+		DayCount:    db.DayCountConventionActual360,
+	})
+	require.NoError(t, err)
+
+	period := time.Date(2001, time.February, 1, 0, 0, 0, 0, time.UTC)
+	accrue := func(day time.Time) int64 {
+		n, err := store.CreateInterestAccrual(ctx, db.CreateInterestAccrualParams{
+			AccountID:   account.ID,
+			ProductID:   product.ID,
+			AccrualDate: pgtype.Date{Time: day, Valid: true},
+			Balance:     account.Balance,
+			AnnualRate:  product.AnnualRate,
+			DayCount:    product.DayCount,
+			Amount:      numeric("0.2500000001"),
+		})
+		require.NoError(t, err)
+		return n
+	}
+	for i := 0; i < 4; i++ {
+		require.Equal(t, int64(1), accrue(period.AddDate(0, 0, i)))
+	}
+	require.Zero(t, accrue(period))
+
+	// Other accruals in the store may be later.
+	latest, err := store.GetLatestInterestAccrualDate(ctx)
+	require.NoError(t, err)
+	require.True(t, latest.Valid)
+	require.False(t, latest.Time.Before(period.AddDate(0, 0, 3)))
+
+	total, err := store.SumUnpostedInterestAccruals(ctx, db.SumUnpostedInterestAccrualsParams{
+		AccountID:   account.ID,
+		PeriodStart: pgtype.Date{Time: period, Valid: true},
+		PeriodEnd:   pgtype.Date{Time: period.AddDate(0, 1, 0), Valid: true},
+	})
+	require.NoError(t, err)
+	requireAmount(t, "1.0000000004", total)
+
+	periods, err := store.ListUnpostedInterestPeriods(ctx, pgtype.Date{Time: period.AddDate(0, 1, 0), Valid: true})
+	require.NoError(t, err)
+	require.Contains(t, periods, db.ListUnpostedInterestPeriodsRow{AccountID: account.ID, Period: pgtype.Date{Time: period, Valid: true}})
+
+	arg := db.PostInterestTxParams{
+		AccountID:        account.ID,
+		ExpenseAccountID: expense.ID,
+		Period:           period.AddDate(0, 0, 10),
+	}
+	result, err := store.PostInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.True(t, result.Posted)
+	requireAmount(t, "1", result.Posting.Amount)
+	requireAmount(t, "101", result.Transfer.ToAccount.Balance)
+	requireAmount(t, "-1", result.Transfer.FromAccount.Balance)
+
+	again, err := store.PostInterestTx(ctx, arg)
+	require.NoError(t, err)
+	require.False(t, again.Posted)
+
+	accruals, err := store.ListInterestAccrualsForAccount(ctx, db.ListInterestAccrualsForAccountParams{AccountID: account.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, accruals, 4)
+	for _, accrual := range accruals {
+		require.Equal(t, pgtype.Int8{Int64: result.Posting.ID, Valid: true}, accrual.PostingID)
+	}
+}
+
+func testWebhookDeliveries(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	_, err := store.CreateWebhookSubscription(ctx, db.CreateWebhookSubscriptionParams{
+		Username:   util.RandomString(12),
+		Url:        "https://example.com/hook",
+		Secret:     "secret",
+		EventTypes: []string{db.EventTransferCreated},
+	})
+	requirePgError(t, err, "23503")
+
+	subscription, err := store.CreateWebhookSubscription(ctx, db.CreateWebhookSubscriptionParams{
+		Username:   user.Username,
+		Url:        "https://example.com/hook",
+		Secret:     "secret",
+		EventTypes: []string{db.EventTransferCreated},
+	})
+	require.NoError(t, err)
+	require.True(t, subscription.Active)
+
+	account1 := createAccount(t, store, user.Username, "USD", "50")
+	account2 := createAccount(t, store, user.Username, "EUR", "0")
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 5})
+	require.NoError(t, err)
+
+	// both accounts belong to the user, who hears about the transfer once
+	deliveries, err := store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{SubscriptionID: subscription.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+	require.Equal(t, db.WebhookDeliveryStatusPending, delivery.Status)
+
+	failed, err := store.MarkWebhookDeliveryFailed(ctx, db.MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         db.WebhookDeliveryStatusDead,
+		NextAttemptAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		LastStatusCode: pgtype.Int4{Int32: 500, Valid: true},
+		LastError:      pgtype.Text{String: "boom", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.WebhookDeliveryStatusDead, failed.Status)
+	require.Equal(t, int32(1), failed.Attempts)
+
+	replayed, err := store.ReplayWebhookDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.WebhookDeliveryStatusPending, replayed.Status)
+	require.Zero(t, replayed.Attempts)
+	require.False(t, replayed.LastError.Valid)
+
+	succeeded, err := store.MarkWebhookDeliverySucceeded(ctx, db.MarkWebhookDeliverySucceededParams{
+		ID:             delivery.ID,
+		LastStatusCode: pgtype.Int4{Int32: 204, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.WebhookDeliveryStatusSucceeded, succeeded.Status)
+	require.True(t, succeeded.DeliveredAt.Valid)
+
+	_, err = store.ReplayWebhookDelivery(ctx, -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// deleting a subscription takes its deliveries with it
+	n, err := store.DeleteWebhookSubscription(ctx, db.DeleteWebhookSubscriptionParams{ID: subscription.ID, Username: util.RandomString(12)})
+	require.NoError(t, err)
+	require.Zero(t, n)
+	n, err = store.DeleteWebhookSubscription(ctx, db.DeleteWebhookSubscriptionParams{ID: subscription.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	_, err = store.GetWebhookDelivery(ctx, delivery.ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testAuditLog(t *testing.T, store db.Store) {
+	user := createUser(t, store)
+	actor := db.AuditActor{Type: db.AuditActorUser, Username: user.Username, APIKeyID: 7, ClientIP: "192.0.2.1"}
+	ctx := db.WithAuditActor(context.Background(), actor)
+
+	// listResource lists the entries of one resource, oldest first.
+	listResource := func(resourceType, resourceID string) []db.AuditLog {
+		entries, err := store.ListAuditLog(ctx, db.ListAuditLogParams{
+			ResourceType: pgtype.Text{String: resourceType, Valid: true},
+			ResourceID:   pgtype.Text{String: resourceID, Valid: true},
+			LimitCount:   100,
+		})
+		require.NoError(t, err)
+		return entries
+	}
+
+	account1, err := store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:       user.Username,
+		Balance:     numeric("100"),
+		Currency:    "NOK",
+		AccountType: db.AccountTypeChecking,
+	})
+	require.NoError(t, err)
+	account2 := createAccount(t, store, createUser(t, store).Username, "NOK", "0")
+
+	// A transfer is recorded with it, by the actor of the context, with the balances before.
+	result, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 30})
+	require.NoError(t, err)
+	entries := listResource("transfer", fmt.Sprint(result.Transfer.ID))
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	require.Equal(t, "transfer.created", entry.Action)
+	require.Equal(t, db.AuditActorUser, entry.ActorType)
+	require.Equal(t, user.Username, entry.Actor.String)
+	require.Equal(t, int64(7), entry.ApiKeyID.Int64)
+	require.Equal(t, "192.0.2.1", entry.ClientIp.String)
+	require.NotZero(t, entry.CreatedAt)
+
+	var before map[string]db.Account
+	require.NoError(t, json.Unmarshal(entry.Before, &before))
+	requireAmount(t, "100", before["from_account"].Balance)
+	requireAmount(t, "0", before["to_account"].Balance)
+	var after db.TransferTxResult
+	require.NoError(t, json.Unmarshal(entry.After, &after))
+	requireAmount(t, "70", after.FromAccount.Balance)
+
+	// A failed transfer records nothing.
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 1000})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+	entries, err = store.ListAuditLog(ctx, db.ListAuditLogParams{AfterID: entry.ID, Action: pgtype.Text{String: "transfer.created", Valid: true}, LimitCount: 100})
+	require.NoError(t, err)
+	for _, other := range entries {
+		require.NotContains(t, string(other.After), fmt.Sprintf(`"from_account_id":%d`, account1.ID))
+	}
+
+	// Without an actor in the context, the change is the system's.
+	_, err = store.FreezeAccountTx(context.Background(), db.FreezeAccountTxParams{AccountID: account1.ID, Frozen: true})
+	require.NoError(t, err)
+	_, err = store.FreezeAccountTx(ctx, db.FreezeAccountTxParams{AccountID: account1.ID, Frozen: true})
+	require.NoError(t, err)
+	_, err = store.UpdateOverdraftLimitTx(ctx, db.UpdateAccountOverdraftLimitParams{ID: account1.ID, OverdraftLimit: numeric("50")})
+	require.NoError(t, err)
+	_, err = store.UpdateOverdraftLimitTx(ctx, db.UpdateAccountOverdraftLimitParams{ID: -1, OverdraftLimit: numeric("50")})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	entries = listResource("account", fmt.Sprint(account1.ID))
+	require.Len(t, entries, 3, "created, frozen once, limit changed")
+	require.Equal(t, "account.created", entries[0].Action)
+	require.Nil(t, entries[0].Before)
+	require.Equal(t, db.EventAccountFrozen, entries[1].Action)
+	require.Equal(t, db.AuditActorSystem, entries[1].ActorType)
+	require.False(t, entries[1].Actor.Valid)
+	require.Equal(t, "account.overdraft_limit_changed", entries[2].Action)
+	var limitBefore, limitAfter db.Account
+	require.NoError(t, json.Unmarshal(entries[2].Before, &limitBefore))
+	require.NoError(t, json.Unmarshal(entries[2].After, &limitAfter))
+	requireAmount(t, "0", limitBefore.OverdraftLimit)
+	requireAmount(t, "50", limitAfter.OverdraftLimit)
+
+	// Role changes keep the role and nothing else of the user.
+	_, err = store.UpdateUserRoleTx(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleAuditor})
+	require.NoError(t, err)
+	_, err = store.UpdateUserRoleTx(ctx, db.UpdateUserRoleParams{Username: util.RandomString(12), Role: db.UserRoleAdmin})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	entries = listResource("user", user.Username)
+	require.Len(t, entries, 1)
+	require.JSONEq(t, fmt.Sprintf(`{"username":%q,"role":"customer"}`, user.Username), string(entries[0].Before))
+	require.JSONEq(t, fmt.Sprintf(`{"username":%q,"role":"auditor"}`, user.Username), string(entries[0].After))
+
+	// Users are recorded created, changing their email and unlocked, each with the change.
+	username := util.RandomString(12)
+	created, err := store.CreateUserTx(ctx, db.CreateUserParams{
+		Username: username, PasswordHash: util.RandomString(32), FullName: util.RandomString(10), Email: username + "@example.com",
+	})
+	require.NoError(t, err)
+	_, err = store.CreateUserTx(ctx, db.CreateUserParams{
+		Username: username, PasswordHash: util.RandomString(32), FullName: util.RandomString(10), Email: util.RandomString(12) + "@example.com",
+	})
+	requirePgError(t, err, "23505")
+	_, err = store.UpdateUserTx(ctx, db.UpdateUserParams{Username: username, FullName: pgtype.Text{String: "Renamed", Valid: true}})
+	require.NoError(t, err)
+	updated, err := store.UpdateUserTx(ctx, db.UpdateUserParams{
+		Username: username, Email: pgtype.Text{String: username + "@example.org", Valid: true}, EmailChanged: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, username+"@example.org", updated.Email)
+	_, err = store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{Scope: db.LoginFailureScopeUsername, Key: username})
+	require.NoError(t, err)
+	unlocked, err := store.UnlockLoginTx(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, username, unlocked.Username)
+	_, err = store.GetLoginFailure(ctx, db.GetLoginFailureParams{Scope: db.LoginFailureScopeUsername, Key: username})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = store.UnlockLoginTx(ctx, util.RandomString(12))
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	entries = listResource("user", created.Username)
+	require.Len(t, entries, 3, "created, email changed, unlocked")
+	require.Equal(t, "user.created", entries[0].Action)
+	require.JSONEq(t, fmt.Sprintf(`{"username":%q,"role":"customer"}`, username), string(entries[0].After))
+	require.Equal(t, "user.email_changed", entries[1].Action)
+	require.NotContains(t, string(entries[1].After), "@")
+	require.Equal(t, "login.unlocked", entries[2].Action)
+
+	// API keys are recorded created and revoked, without their hash or signing secret.
+	apiKey, err := store.CreateApiKeyTx(ctx, db.CreateApiKeyParams{
+		Username:      username,
+		Name:          "billing",
+		KeyHash:       util.RandomString(32),
+		Prefix:        "sbk_abcd",
+		Scopes:        []string{"accounts:read"},
+		AllowedIps:    []string{},
+		SigningSecret: pgtype.Text{String: "sks_" + util.RandomString(32), Valid: true},
+	})
+	require.NoError(t, err)
+	revoked, err := store.RevokeApiKeyTx(ctx, db.RevokeApiKeyParams{ID: apiKey.ID, Username: username})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+	revoked, err = store.RevokeApiKeyTx(ctx, db.RevokeApiKeyParams{ID: apiKey.ID, Username: username})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	entries = listResource("api_key", fmt.Sprint(apiKey.ID))
+	require.Len(t, entries, 2, "created, revoked once")
+	require.Equal(t, "api_key.created", entries[0].Action)
+	require.JSONEq(t, fmt.Sprintf(`{"username":%q,"scopes":["accounts:read"],"signature_required":true}`, username), string(entries[0].After))
+	require.Equal(t, "api_key.revoked", entries[1].Action)
+
+	currency := strings.ToUpper(util.RandomString(3))
+	product, err := store.UpsertInterestProductTx(ctx, db.UpsertInterestProductParams{
+		Currency: currency, AccountType: db.AccountTypeSavings, AnnualRate: numeric("0.01"), DayCount: db.DayCountConventionActual365,
+	})
+	require.NoError(t, err)
+	_, err = store.UpsertInterestProductTx(ctx, db.UpsertInterestProductParams{
+		Currency: currency, AccountType: db.AccountTypeSavings, AnnualRate: numeric("0.02"), DayCount: db.DayCountConventionActual365,
+	})
+	require.NoError(t, err)
+	entries = listResource("interest_product", fmt.Sprint(product.ID))
+	require.Len(t, entries, 2)
+	require.Nil(t, entries[0].Before)
+	require.NotNil(t, entries[1].Before)
+
+	// The filters combine, and the log pages by ID.
+	entries, err = store.ListAuditLog(ctx, db.ListAuditLogParams{
+		Actor:      pgtype.Text{String: user.Username, Valid: true},
+		Since:      pgtype.Timestamptz{Time: entry.CreatedAt.Time, Valid: true},
+		Until:      pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+		LimitCount: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Less(t, entries[0].ID, entries[1].ID)
+	next, err := store.ListAuditLog(ctx, db.ListAuditLogParams{
+		Actor:      pgtype.Text{String: user.Username, Valid: true},
+		AfterID:    entries[1].ID,
+		LimitCount: 100,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+	require.Greater(t, next[0].ID, entries[1].ID)
+	for _, entry := range next {
+		require.Equal(t, user.Username, entry.Actor.String)
+	}
+
+	entries, err = store.ListAuditLog(ctx, db.ListAuditLogParams{
+		Actor:      pgtype.Text{String: user.Username, Valid: true},
+		Until:      pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+		LimitCount: 100,
+	})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Entries are written as given, even outside a transaction.
+	written, err := db.WriteAudit(ctx, store, db.AuditEntry{Action: "login.succeeded", ResourceType: "user", ResourceID: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, "login.succeeded", written.Action)
+	require.Nil(t, written.After)
+}
+
+func testPing(t *testing.T, store db.Store) {
+	require.NoError(t, store.Ping(context.Background()))
+}
+
+func createUser(t *testing.T, store db.Store) db.User {
+	t.Helper()
+
+	username := util.RandomString(12)
+	user, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Username:     username,
+		PasswordHash: util.RandomString(32),
+		FullName:     util.RandomString(10),
+		Email:        username + "@example.com",
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func createAccount(t *testing.T, store db.Store, owner, currency, balance string) db.Account {
+	t.Helper()
+
+	account, err := store.CreateAccount(context.Background(), db.CreateAccountParams{
+		Owner:       owner,
+		Balance:     numeric(balance),
+		Currency:    currency,
+		AccountType: db.AccountTypeChecking,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func accountIDs(accounts []db.Account) []int64 {
+	ids := make([]int64, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	return ids
+}
+
+func numeric(s string) pgtype.Numeric {
+	var n pgtype.Numeric
+	if err := n.Scan(s); err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// requireAmount compares amounts by value, since 1.5 and 1.50 are the same amount.
+func requireAmount(t *testing.T, expected string, actual pgtype.Numeric) {
+	t.Helper()
+
+	want, ok := new(big.Rat).SetString(expected)
+	require.True(t, ok)
+	require.True(t, actual.Valid, "amount is null")
+	require.Zero(t, want.Cmp(db.NumericToRat(actual)), "want %s, got %s", expected, db.NumericToRat(actual).FloatString(10))
+}
+
+func requireBalance(t *testing.T, store db.Store, accountID int64, expected string) {
+	t.Helper()
+
+	account, err := store.GetAccount(context.Background(), accountID)
+	require.NoError(t, err)
+	requireAmount(t, expected, account.Balance)
+}
+
+func requirePgError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr), "want Postgres error %s, got %v", code, err)
+	require.Equal(t, code, pgErr.Code)
+}
@@ -1,29 +1,568 @@
 package util
 
 import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/viper"
 )
 
+// Config is the configuration of the server and of the simplebank CLI. It is loaded in
+// layers: an env file such as app.env, then the environment, then command-line flags,
+// each overriding the one before. Keys without a default must be set.
+//
+// A secret key can also be read from the file named by <KEY>_FILE, such as a mounted
+// Docker or Kubernetes secret. Secrets are never accepted as flags, where they would
+// show up in the process list.
 type Config struct {
-	DbHost    string `mapstructure:"DB_HOST"`
-	DbPort    int    `mapstructure:"DB_PORT"`
+	// Store is where the bank keeps its data: postgres, or memory for local demos.
+	Store string `mapstructure:"STORE" default:"postgres"`
+
+	DbHost    string `mapstructure:"DB_HOST" default:"localhost"`
+	DbPort    int    `mapstructure:"DB_PORT" default:"5432"`
 	DbUser    string `mapstructure:"DB_USER"`
-	DbPass    string `mapstructure:"DB_PASSWORD"`
-	DbSslMode string `mapstructure:"DB_SSL_MODE"`
-	DbName    string `mapstructure:"DB_NAME"`
-	AppPort   string `mapstructure:"APP_PORT"`
+	DbPass    string `mapstructure:"DB_PASSWORD" secret:"true" default:""`
+	DbSslMode string `mapstructure:"DB_SSL_MODE" default:"prefer"`
+	DbName    string `mapstructure:"DB_NAME" default:"simple_bank"`
+
+	DbMaxConns          int32         `mapstructure:"DB_MAX_CONNS" default:"10"`
+	DbMinConns          int32         `mapstructure:"DB_MIN_CONNS" default:"0"`
+	DbMaxConnLifetime   time.Duration `mapstructure:"DB_MAX_CONN_LIFETIME" default:"1h"`
+	DbMaxConnIdleTime   time.Duration `mapstructure:"DB_MAX_CONN_IDLE_TIME" default:"30m"`
+	DbHealthCheckPeriod time.Duration `mapstructure:"DB_HEALTH_CHECK_PERIOD" default:"1m"`
+	DbConnectTimeout    time.Duration `mapstructure:"DB_CONNECT_TIMEOUT" default:"5s"`
+
+	AppPort  string `mapstructure:"APP_PORT" default:"8022"`
+	GrpcPort string `mapstructure:"GRPC_PORT" default:"9022"`
+
+	MigrateOnStart bool `mapstructure:"MIGRATE_ON_START" default:"false"`
+
+	HTTPReadHeaderTimeout time.Duration `mapstructure:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	HTTPReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT" default:"10s"`
+	HTTPWriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT" default:"30s"`
+	HTTPIdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	ShutdownTimeout       time.Duration `mapstructure:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in front of the
+	// server, whose X-Forwarded-For header names the client. Empty trusts no one, and the
+	// client is the peer of the connection.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES" default:""`
+
+	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY" secret:"true"`
+	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION" default:"15m"`
+
+	// TOTPEncryptionKey seals the users' TOTP secrets: 32 bytes, hex-encoded. Empty turns
+	// enrollment in two-factor authentication off; users already enrolled can still log in.
+	TOTPEncryptionKey     string        `mapstructure:"TOTP_ENCRYPTION_KEY" secret:"true" default:""`
+	TOTPIssuer            string        `mapstructure:"TOTP_ISSUER" default:"Simple Bank"`
+	TOTPChallengeDuration time.Duration `mapstructure:"TOTP_CHALLENGE_DURATION" default:"5m"`
+	// PIIMasterKeys wrap the keys that encrypt the users' names and emails: "id:key" pairs,
+	// the keys 32 bytes hex-encoded, separated by commas or, in a keyfile read through
+	// PII_MASTER_KEYS_FILE, newlines. The first is current; keep the older ones until the
+	// rekey job, which runs every PIIRekeyInterval, has moved everything off them. Empty
+	// leaves personal data in plaintext. PIIBlindIndexKey, 32 bytes hex-encoded, indexes the
+	// encrypted emails and cannot be changed once set.
+	PIIMasterKeys    string        `mapstructure:"PII_MASTER_KEYS" secret:"true" default:""`
+	PIIBlindIndexKey string        `mapstructure:"PII_BLIND_INDEX_KEY" secret:"true" default:""`
+	PIIRekeyInterval time.Duration `mapstructure:"PII_REKEY_INTERVAL" default:"1h"`
+	// HighRiskTransferAmount is the amount above which a transfer needs a fresh TOTP code
+	// from users enrolled in two-factor authentication.
+	HighRiskTransferAmount int64 `mapstructure:"HIGH_RISK_TRANSFER_AMOUNT" default:"1000"`
+
+	// PasswordMinLength and PasswordHistory are the password policy: the fewest characters
+	// a password may have, and how many of the user's latest passwords a new one must
+	// differ from. PasswordBreachedFile lists passwords to refuse, one per line.
+	PasswordMinLength          int           `mapstructure:"PASSWORD_MIN_LENGTH" default:"8"`
+	PasswordHistory            int           `mapstructure:"PASSWORD_HISTORY" default:"5"`
+	PasswordBreachedFile       string        `mapstructure:"PASSWORD_BREACHED_FILE" default:""`
+	PasswordResetTokenDuration time.Duration `mapstructure:"PASSWORD_RESET_TOKEN_DURATION" default:"30m"`
+
+	// Failed logins are counted per username and per client IP over LoginFailureWindow.
+	// After LoginBackoffAfter failures for a username, or LoginIPBackoffAfter for an IP,
+	// every further attempt waits LoginBackoffBase, doubled with each failure up to
+	// LoginBackoffMax. LoginLockoutAfter failures lock the username for
+	// LoginLockoutDuration or until an admin unlocks it; zero never locks.
+	LoginFailureWindow   time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW" default:"1h"`
+	LoginBackoffAfter    int32         `mapstructure:"LOGIN_BACKOFF_AFTER" default:"3"`
+	LoginIPBackoffAfter  int32         `mapstructure:"LOGIN_IP_BACKOFF_AFTER" default:"20"`
+	LoginBackoffBase     time.Duration `mapstructure:"LOGIN_BACKOFF_BASE" default:"1s"`
+	LoginBackoffMax      time.Duration `mapstructure:"LOGIN_BACKOFF_MAX" default:"5m"`
+	LoginLockoutAfter    int32         `mapstructure:"LOGIN_LOCKOUT_AFTER" default:"10"`
+	LoginLockoutDuration time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION" default:"30m"`
+
+	// EmailVerificationDuration is how long the code sent to verify an email address stays
+	// valid. Users must verify theirs before they open an account.
+	EmailVerificationDuration time.Duration `mapstructure:"EMAIL_VERIFICATION_DURATION" default:"24h"`
+
+	// SignatureWindow is how far the timestamp of a signed request may be from the server's
+	// clock. Nonces are remembered for as long, so a signed request is accepted only once.
+	SignatureWindow time.Duration `mapstructure:"SIGNATURE_WINDOW" default:"5m"`
+
+	// Notifier delivers the messages sent to users: log writes them to the server log,
+	// smtp mails them through SMTPAddr, and file writes them as emails to NotifyDir.
+	Notifier     string `mapstructure:"NOTIFIER" default:"log"`
+	SMTPAddr     string `mapstructure:"SMTP_ADDR" default:""`
+	SMTPFrom     string `mapstructure:"SMTP_FROM" default:"Simple Bank <no-reply@simplebank.local>"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME" default:""`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD" secret:"true" default:""`
+	NotifyDir    string `mapstructure:"NOTIFY_DIR" default:""`
+
+	OverdraftAnnualRate string `mapstructure:"OVERDRAFT_ANNUAL_RATE" default:"0.18"`
+
+	InterestExpenseOwner string `mapstructure:"INTEREST_EXPENSE_OWNER" default:"bank"`
+
+	DeprecatedRoutesSunset string `mapstructure:"DEPRECATED_ROUTES_SUNSET" default:""`
+
+	LogLevel      string   `mapstructure:"LOG_LEVEL" default:"info"`
+	LogRedactKeys []string `mapstructure:"LOG_REDACT_KEYS" default:"password,token,access_token,secret,authorization"`
+
+	TracingExporter     string `mapstructure:"TRACING_EXPORTER" default:"none"`
+	TracingOTLPEndpoint string `mapstructure:"TRACING_OTLP_ENDPOINT" default:"http://localhost:4317"`
+	TracingFile         string `mapstructure:"TRACING_FILE" default:"traces.json"`
+}
+
+// ConfigError reports an invalid or missing config value.
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func configError(key, format string, args ...any) error {
+	return &ConfigError{Key: key, Err: fmt.Errorf(format, args...)}
+}
+
+// secretFileSuffix turns a secret key into the key of the file holding it.
+const secretFileSuffix = "_FILE"
+
+// configKey describes a Config field, from its struct tags.
+type configKey struct {
+	name       string
+	field      int
+	secret     bool
+	def        string
+	hasDefault bool
 }
 
-func LoadConfig(path string) (config Config, err error) {
-	viper.SetConfigFile(path)
-	viper.SetConfigType("env")
-	viper.AutomaticEnv()
+var configKeys = func() []configKey {
+	t := reflect.TypeFor[Config]()
+	keys := make([]configKey, 0, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		def, hasDefault := field.Tag.Lookup("default")
+		keys = append(keys, configKey{
+			name:       field.Tag.Get("mapstructure"),
+			field:      i,
+			secret:     field.Tag.Get("secret") == "true",
+			def:        def,
+			hasDefault: hasDefault,
+		})
+	}
+	return keys
+}()
+
+// Loader loads a Config. Every Loader has its own state, so tests can load configs in
+// parallel.
+type Loader struct {
+	// File is the env file to read first. Empty skips it.
+	File string
+	// Environ is the environment, as KEY=VALUE pairs like os.Environ returns.
+	Environ []string
+
+	flags *flag.FlagSet
+}
+
+// NewLoader returns a Loader reading file and the process environment.
+func NewLoader(file string) *Loader {
+	return &Loader{File: file, Environ: os.Environ()}
+}
+
+// LoadConfig loads the config from the env file at path and the environment.
+func LoadConfig(path string) (Config, error) {
+	return NewLoader(path).Load()
+}
+
+// RegisterFlags adds a flag per config key to flags, named after the key in lower case
+// with dashes, so that --db-host overrides DB_HOST. Secret keys only get the flag of
+// their file, such as --db-password-file. Values are read from flags once it is parsed.
+func (loader *Loader) RegisterFlags(flags *flag.FlagSet) {
+	for _, key := range configKeys {
+		if key.secret {
+			flags.String(flagName(key.name+secretFileSuffix), "", "read "+key.name+" from this file")
+			continue
+		}
+		flags.String(flagName(key.name), "", "override "+key.name)
+	}
+	loader.flags = flags
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// Load reads the layers, decodes the values and validates the result. The error names
+// every key that is missing or invalid.
+func (loader *Loader) Load() (Config, error) {
+	values := make(map[string]string)
+	for _, key := range configKeys {
+		if key.hasDefault {
+			values[key.name] = key.def
+		}
+	}
+
+	var errs []error
+
+	if loader.File != "" {
+		file, err := readEnvFile(loader.File)
+		if err != nil {
+			return Config{}, err
+		}
+		for name := range file {
+			if !knownKey(name) {
+				errs = append(errs, configError(name, "unknown key in %s", loader.File))
+			}
+		}
+		errs = append(errs, applyLayer(values, file)...)
+	}
+
+	env := make(map[string]string)
+	for _, pair := range loader.Environ {
+		if name, value, ok := strings.Cut(pair, "="); ok {
+			env[name] = value
+		}
+	}
+	errs = append(errs, applyLayer(values, env)...)
+
+	if loader.flags != nil {
+		flags := make(map[string]string)
+		loader.flags.Visit(func(f *flag.Flag) {
+			for _, key := range configKeys {
+				for _, name := range []string{key.name, key.name + secretFileSuffix} {
+					if f.Name == flagName(name) {
+						flags[name] = f.Value.String()
+					}
+				}
+			}
+		})
+		errs = append(errs, applyLayer(values, flags)...)
+	}
+
+	var config Config
+	errs = append(errs, decode(&config, values)...)
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func knownKey(name string) bool {
+	for _, key := range configKeys {
+		if name == key.name || (key.secret && name == key.name+secretFileSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyLayer copies the keys a layer sets into values, reading secrets from their files.
+func applyLayer(values, layer map[string]string) []error {
+	var errs []error
+	for _, key := range configKeys {
+		value, ok := layer[key.name]
+		path, fromFile := layer[key.name+secretFileSuffix]
+		if !key.secret {
+			fromFile = false
+		}
+
+		switch {
+		case ok && fromFile:
+			errs = append(errs, configError(key.name, "set both %s and %s%s", key.name, key.name, secretFileSuffix))
+		case fromFile:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, configError(key.name+secretFileSuffix, "%w", err))
+				continue
+			}
+			values[key.name] = strings.TrimRight(string(content), "\r\n")
+		case ok:
+			values[key.name] = value
+		}
+	}
+	return errs
+}
+
+// readEnvFile reads the KEY=VALUE lines of an env file.
+func readEnvFile(path string) (map[string]string, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("env")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for _, name := range v.AllKeys() {
+		values[strings.ToUpper(name)] = v.GetString(name)
+	}
+	return values, nil
+}
+
+// decode parses values into the fields of config.
+func decode(config *Config, values map[string]string) []error {
+	var errs []error
+	fields := reflect.ValueOf(config).Elem()
+
+	for _, key := range configKeys {
+		value, ok := values[key.name]
+		if !ok {
+			errs = append(errs, configError(key.name, "is required"))
+			continue
+		}
+
+		field := fields.Field(key.field)
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(value)
+		case []string:
+			items := []string{}
+			for item := range strings.SplitSeq(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		case bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, configError(key.name, "invalid boolean %q", value))
+				continue
+			}
+			field.SetBool(b)
+		case time.Duration:
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, configError(key.name, "invalid duration %q", value))
+				continue
+			}
+			field.SetInt(int64(d))
+		case int, int32, int64:
+			n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+			if err != nil {
+				errs = append(errs, configError(key.name, "invalid integer %q", value))
+				continue
+			}
+			field.SetInt(n)
+		default:
+			panic("util: unsupported config field type " + field.Type().String())
+		}
+	}
+	return errs
+}
+
+// The stores STORE can name.
+const (
+	StorePostgres = "postgres"
+	StoreMemory   = "memory"
+)
+
+var (
+	stores           = []string{StorePostgres, StoreMemory}
+	sslModes         = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	tracingExporters = []string{"none", "otlp", "stdout", "file"}
+	notifiers        = []string{"log", "smtp", "file"}
+)
+
+// minTokenKeySize is the shortest symmetric key the token maker accepts.
+const minTokenKeySize = 32
+
+// totpKeySize is the size of the AES-256 key that seals TOTP secrets.
+const totpKeySize = 32
+
+// piiKeySize is the size of the AES-256 master keys and of the blind index key.
+const piiKeySize = 32
+
+// maxPasswordLength is the longest password bcrypt can hash, in bytes.
+const maxPasswordLength = 72
+
+// Validate checks the values of config and names every invalid key.
+func (config Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, configError(key, format, args...))
+		}
+	}
+
+	check(slices.Contains(stores, config.Store), "STORE", "must be one of %s", strings.Join(stores, ", "))
+	check(config.DbHost != "", "DB_HOST", "must not be empty")
+	check(config.DbPort > 0 && config.DbPort <= 65535, "DB_PORT", "must be a port between 1 and 65535")
+	check(config.DbUser != "", "DB_USER", "must not be empty")
+	check(config.DbName != "", "DB_NAME", "must not be empty")
+	check(slices.Contains(sslModes, config.DbSslMode), "DB_SSL_MODE", "must be one of %s", strings.Join(sslModes, ", "))
+
+	check(config.DbMaxConns > 0, "DB_MAX_CONNS", "must be positive")
+	check(config.DbMinConns >= 0 && config.DbMinConns <= config.DbMaxConns, "DB_MIN_CONNS", "must be between 0 and DB_MAX_CONNS")
+	check(config.DbMaxConnLifetime > 0, "DB_MAX_CONN_LIFETIME", "must be positive")
+	check(config.DbMaxConnIdleTime > 0, "DB_MAX_CONN_IDLE_TIME", "must be positive")
+	check(config.DbHealthCheckPeriod > 0, "DB_HEALTH_CHECK_PERIOD", "must be positive")
+	check(config.DbConnectTimeout > 0, "DB_CONNECT_TIMEOUT", "must be positive")
+
+	check(validPort(config.AppPort), "APP_PORT", "must be a port between 1 and 65535")
+	check(validPort(config.GrpcPort), "GRPC_PORT", "must be a port between 1 and 65535")
+
+	check(config.HTTPReadHeaderTimeout > 0, "HTTP_READ_HEADER_TIMEOUT", "must be positive")
+	check(config.HTTPReadTimeout > 0, "HTTP_READ_TIMEOUT", "must be positive")
+	check(config.HTTPWriteTimeout > 0, "HTTP_WRITE_TIMEOUT", "must be positive")
+	check(config.HTTPIdleTimeout > 0, "HTTP_IDLE_TIMEOUT", "must be positive")
+	check(config.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT", "must be positive")
+
+	check(len(config.TokenSymmetricKey) >= minTokenKeySize, "TOKEN_SYMMETRIC_KEY", "must be at least %d characters", minTokenKeySize)
+	check(config.AccessTokenDuration > 0, "ACCESS_TOKEN_DURATION", "must be positive")
+
+	if config.TOTPEncryptionKey != "" {
+		key, err := hex.DecodeString(config.TOTPEncryptionKey)
+		check(err == nil && len(key) == totpKeySize, "TOTP_ENCRYPTION_KEY", "must be %d hex-encoded bytes", totpKeySize)
+	}
+	check(config.TOTPIssuer != "" && !strings.Contains(config.TOTPIssuer, ":"), "TOTP_ISSUER", "must not be empty or contain a colon")
+	check(config.TOTPChallengeDuration > 0, "TOTP_CHALLENGE_DURATION", "must be positive")
+	if config.PIIMasterKeys != "" {
+		check(validPIIMasterKeys(config.PIIMasterKeys), "PII_MASTER_KEYS", "must be id:key pairs with unique ids and %d hex-encoded byte keys", piiKeySize)
+		key, err := hex.DecodeString(config.PIIBlindIndexKey)
+		check(err == nil && len(key) == piiKeySize, "PII_BLIND_INDEX_KEY", "must be %d hex-encoded bytes", piiKeySize)
+	}
+	check(config.PIIRekeyInterval > 0, "PII_REKEY_INTERVAL", "must be positive")
+	check(config.HighRiskTransferAmount >= 0, "HIGH_RISK_TRANSFER_AMOUNT", "must not be negative")
+
+	check(config.PasswordMinLength > 0 && config.PasswordMinLength <= maxPasswordLength, "PASSWORD_MIN_LENGTH", "must be between 1 and %d", maxPasswordLength)
+	check(config.PasswordHistory >= 0, "PASSWORD_HISTORY", "must not be negative")
+	if config.PasswordBreachedFile != "" {
+		_, err := os.Stat(config.PasswordBreachedFile)
+		check(err == nil, "PASSWORD_BREACHED_FILE", "cannot be read: %v", err)
+	}
+	check(config.PasswordResetTokenDuration > 0, "PASSWORD_RESET_TOKEN_DURATION", "must be positive")
+
+	check(config.LoginFailureWindow > 0, "LOGIN_FAILURE_WINDOW", "must be positive")
+	check(config.LoginBackoffAfter > 0, "LOGIN_BACKOFF_AFTER", "must be positive")
+	check(config.LoginIPBackoffAfter > 0, "LOGIN_IP_BACKOFF_AFTER", "must be positive")
+	check(config.LoginBackoffBase > 0, "LOGIN_BACKOFF_BASE", "must be positive")
+	check(config.LoginBackoffMax >= config.LoginBackoffBase, "LOGIN_BACKOFF_MAX", "must not be less than LOGIN_BACKOFF_BASE")
+	check(config.LoginLockoutAfter >= 0, "LOGIN_LOCKOUT_AFTER", "must not be negative")
+	check(config.LoginLockoutDuration > 0, "LOGIN_LOCKOUT_DURATION", "must be positive")
+
+	check(config.EmailVerificationDuration > 0, "EMAIL_VERIFICATION_DURATION", "must be positive")
+	check(config.SignatureWindow > 0, "SIGNATURE_WINDOW", "must be positive")
+	check(slices.Contains(notifiers, config.Notifier), "NOTIFIER", "must be one of %s", strings.Join(notifiers, ", "))
+	if config.Notifier == "smtp" {
+		_, _, err := net.SplitHostPort(config.SMTPAddr)
+		check(err == nil, "SMTP_ADDR", "must be a host:port")
+		_, err = mail.ParseAddress(config.SMTPFrom)
+		check(err == nil, "SMTP_FROM", "must be an email address")
+	}
+	if config.Notifier == "file" {
+		info, err := os.Stat(config.NotifyDir)
+		check(err == nil && info.IsDir(), "NOTIFY_DIR", "must be a directory")
+	}
+
+	_, ok := new(big.Rat).SetString(config.OverdraftAnnualRate)
+	check(ok, "OVERDRAFT_ANNUAL_RATE", "invalid decimal %q", config.OverdraftAnnualRate)
+	check(config.InterestExpenseOwner != "", "INTEREST_EXPENSE_OWNER", "must not be empty")
+
+	if config.DeprecatedRoutesSunset != "" {
+		_, err := time.Parse(time.DateOnly, config.DeprecatedRoutesSunset)
+		check(err == nil, "DEPRECATED_ROUTES_SUNSET", "must be a date such as 2027-04-30")
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(strings.ToUpper(config.LogLevel))) == nil, "LOG_LEVEL", "must be debug, info, warn or error")
+
+	check(slices.Contains(tracingExporters, config.TracingExporter), "TRACING_EXPORTER", "must be one of %s", strings.Join(tracingExporters, ", "))
+	if config.TracingExporter == "otlp" {
+		_, err := url.Parse(config.TracingOTLPEndpoint)
+		check(config.TracingOTLPEndpoint != "" && err == nil, "TRACING_OTLP_ENDPOINT", "must be a URL")
+	}
+	if config.TracingExporter == "file" {
+		check(config.TracingFile != "", "TRACING_FILE", "must not be empty")
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// validPIIMasterKeys reports whether keys is a list of id:key pairs, as the pii package
+// reads them.
+func validPIIMasterKeys(keys string) bool {
+	ids := make(map[string]bool)
+	for _, entry := range strings.FieldsFunc(keys, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, hexKey, ok := strings.Cut(entry, ":")
+		key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+		if !ok || id == "" || ids[id] || err != nil || len(key) != piiKeySize {
+			return false
+		}
+		ids[id] = true
+	}
+	return len(ids) > 0
+}
+
+// DatabaseURL returns the connection string of the configured database, with the user,
+// password and database name escaped.
+func (config Config) DatabaseURL() string {
+	user := url.User(config.DbUser)
+	if config.DbPass != "" {
+		user = url.UserPassword(config.DbUser, config.DbPass)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgresql",
+		User:     user,
+		Host:     net.JoinHostPort(config.DbHost, strconv.Itoa(config.DbPort)),
+		Path:     "/" + config.DbName,
+		RawQuery: url.Values{"sslmode": {config.DbSslMode}}.Encode(),
+	}
+	return dsn.String()
+}
 
-	err = viper.ReadInConfig()
+// PoolConfig returns the connection pool configuration of the configured database.
+func (config Config) PoolConfig() (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DatabaseURL())
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	err = viper.Unmarshal(&config)
-	return
+	poolConfig.MaxConns = config.DbMaxConns
+	poolConfig.MinConns = config.DbMinConns
+	poolConfig.MaxConnLifetime = config.DbMaxConnLifetime
+	poolConfig.MaxConnIdleTime = config.DbMaxConnIdleTime
+	poolConfig.HealthCheckPeriod = config.DbHealthCheckPeriod
+	poolConfig.ConnConfig.ConnectTimeout = config.DbConnectTimeout
+	return poolConfig, nil
 }
@@ -0,0 +1,240 @@
+package util
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+const testTokenKey = "12345678901234567890123456789012"
+
+// testPIIKey is a valid 32-byte hex-encoded PII key.
+const testPIIKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigFromAppEnv(t *testing.T) {
+	t.Parallel()
+
+	loader := &Loader{File: "../../app.env"}
+	config, err := loader.Load()
+	require.NoError(t, err)
+	require.Equal(t, "simple_bank", config.DbName)
+	require.Equal(t, 5432, config.DbPort)
+	require.Equal(t, 10*time.Second, config.HTTPReadTimeout)
+	require.Contains(t, config.LogRedactKeys, "email")
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	t.Parallel()
+
+	file := writeFile(t, "app.env", "DB_USER=file\nDB_HOST=file-host\nAPP_PORT=1000\nTOKEN_SYMMETRIC_KEY="+testTokenKey+"\n")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := &Loader{File: file, Environ: []string{"DB_HOST=env-host", "APP_PORT=2000", "UNRELATED=1"}}
+	loader.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--app-port", "3000", "--db-max-conns=20"}))
+
+	config, err := loader.Load()
+	require.NoError(t, err)
+	require.Equal(t, "file", config.DbUser)
+	require.Equal(t, "env-host", config.DbHost)
+	require.Equal(t, "3000", config.AppPort)
+	require.Equal(t, int32(20), config.DbMaxConns)
+
+	// keys nobody set keep their defaults
+	require.Equal(t, "simple_bank", config.DbName)
+	require.Equal(t, time.Hour, config.DbMaxConnLifetime)
+	require.Equal(t, []string{"password", "token", "access_token", "secret", "authorization"}, config.LogRedactKeys)
+}
+
+func TestLoadConfigSecretFiles(t *testing.T) {
+	t.Parallel()
+
+	password := writeFile(t, "password", "s3cret\n")
+	tokenKey := writeFile(t, "token", testTokenKey)
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Parallel()
+
+		loader := &Loader{Environ: []string{"DB_USER=root", "DB_PASSWORD_FILE=" + password, "TOKEN_SYMMETRIC_KEY_FILE=" + tokenKey}}
+		config, err := loader.Load()
+		require.NoError(t, err)
+		require.Equal(t, "s3cret", config.DbPass)
+		require.Equal(t, testTokenKey, config.TokenSymmetricKey)
+	})
+
+	t.Run("Keyfile", func(t *testing.T) {
+		t.Parallel()
+
+		keys := "v2:" + testPIIKey + "\nv1:" + testPIIKey + "\n"
+		loader := &Loader{Environ: []string{
+			"DB_USER=root", "TOKEN_SYMMETRIC_KEY=" + testTokenKey,
+			"PII_MASTER_KEYS_FILE=" + writeFile(t, "pii_keys", keys), "PII_BLIND_INDEX_KEY=" + testPIIKey,
+		}}
+		config, err := loader.Load()
+		require.NoError(t, err)
+		require.Equal(t, keys[:len(keys)-1], config.PIIMasterKeys)
+	})
+
+	t.Run("Flag Overrides Environment", func(t *testing.T) {
+		t.Parallel()
+
+		other := writeFile(t, "other", "from-flag")
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		loader := &Loader{Environ: []string{"DB_USER=root", "DB_PASSWORD=from-env", "TOKEN_SYMMETRIC_KEY=" + testTokenKey}}
+		loader.RegisterFlags(flags)
+		require.NoError(t, flags.Parse([]string{"--db-password-file", other}))
+
+		config, err := loader.Load()
+		require.NoError(t, err)
+		require.Equal(t, "from-flag", config.DbPass)
+	})
+
+	t.Run("No Secret Flags", func(t *testing.T) {
+		t.Parallel()
+
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		(&Loader{}).RegisterFlags(flags)
+		require.Error(t, flags.Parse([]string{"--db-password", "secret"}))
+	})
+
+	t.Run("Both Set", func(t *testing.T) {
+		t.Parallel()
+
+		loader := &Loader{Environ: []string{"DB_USER=root", "DB_PASSWORD=x", "DB_PASSWORD_FILE=" + password, "TOKEN_SYMMETRIC_KEY=" + testTokenKey}}
+		_, err := loader.Load()
+		requireConfigError(t, err, "DB_PASSWORD")
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		t.Parallel()
+
+		loader := &Loader{Environ: []string{"DB_USER=root", "DB_PASSWORD_FILE=/does/not/exist", "TOKEN_SYMMETRIC_KEY=" + testTokenKey}}
+		_, err := loader.Load()
+		requireConfigError(t, err, "DB_PASSWORD_FILE")
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{"DB_USER=root", "TOKEN_SYMMETRIC_KEY=" + testTokenKey}
+
+	testCases := []struct {
+		Name    string
+		Environ []string
+		Key     string
+	}{
+		{Name: "Required", Environ: []string{"TOKEN_SYMMETRIC_KEY=" + testTokenKey}, Key: "DB_USER"},
+		{Name: "Invalid Integer", Environ: append([]string{"DB_PORT=abc"}, valid...), Key: "DB_PORT"},
+		{Name: "Port Out Of Range", Environ: append([]string{"DB_PORT=70000"}, valid...), Key: "DB_PORT"},
+		{Name: "Invalid Duration", Environ: append([]string{"HTTP_READ_TIMEOUT=10"}, valid...), Key: "HTTP_READ_TIMEOUT"},
+		{Name: "Invalid Boolean", Environ: append([]string{"MIGRATE_ON_START=maybe"}, valid...), Key: "MIGRATE_ON_START"},
+		{Name: "Short Token Key", Environ: []string{"DB_USER=root", "TOKEN_SYMMETRIC_KEY=short"}, Key: "TOKEN_SYMMETRIC_KEY"},
+		{Name: "Store", Environ: append([]string{"STORE=sqlite"}, valid...), Key: "STORE"},
+		{Name: "SSL Mode", Environ: append([]string{"DB_SSL_MODE=sometimes"}, valid...), Key: "DB_SSL_MODE"},
+		{Name: "Min Over Max Conns", Environ: append([]string{"DB_MAX_CONNS=2", "DB_MIN_CONNS=5"}, valid...), Key: "DB_MIN_CONNS"},
+		{Name: "Log Level", Environ: append([]string{"LOG_LEVEL=loud"}, valid...), Key: "LOG_LEVEL"},
+		{Name: "Tracing Exporter", Environ: append([]string{"TRACING_EXPORTER=jaeger"}, valid...), Key: "TRACING_EXPORTER"},
+		{Name: "Sunset", Environ: append([]string{"DEPRECATED_ROUTES_SUNSET=next year"}, valid...), Key: "DEPRECATED_ROUTES_SUNSET"},
+		{Name: "Overdraft Rate", Environ: append([]string{"OVERDRAFT_ANNUAL_RATE=high"}, valid...), Key: "OVERDRAFT_ANNUAL_RATE"},
+		{Name: "TOTP Key", Environ: append([]string{"TOTP_ENCRYPTION_KEY=" + testTokenKey}, valid...), Key: "TOTP_ENCRYPTION_KEY"},
+		{Name: "PII Master Keys", Environ: append([]string{"PII_MASTER_KEYS=v1:" + testTokenKey, "PII_BLIND_INDEX_KEY=" + testPIIKey}, valid...), Key: "PII_MASTER_KEYS"},
+		{Name: "Duplicate PII Master Keys", Environ: append([]string{"PII_MASTER_KEYS=v1:" + testPIIKey + ",v1:" + testPIIKey, "PII_BLIND_INDEX_KEY=" + testPIIKey}, valid...), Key: "PII_MASTER_KEYS"},
+		{Name: "PII Blind Index Key", Environ: append([]string{"PII_MASTER_KEYS=v1:" + testPIIKey}, valid...), Key: "PII_BLIND_INDEX_KEY"},
+		{Name: "High Risk Amount", Environ: append([]string{"HIGH_RISK_TRANSFER_AMOUNT=-1"}, valid...), Key: "HIGH_RISK_TRANSFER_AMOUNT"},
+		{Name: "Password Min Length", Environ: append([]string{"PASSWORD_MIN_LENGTH=100"}, valid...), Key: "PASSWORD_MIN_LENGTH"},
+		{Name: "Breached Password File", Environ: append([]string{"PASSWORD_BREACHED_FILE=/nonexistent/breached.txt"}, valid...), Key: "PASSWORD_BREACHED_FILE"},
+		{Name: "Login Backoff Max", Environ: append([]string{"LOGIN_BACKOFF_BASE=1m", "LOGIN_BACKOFF_MAX=30s"}, valid...), Key: "LOGIN_BACKOFF_MAX"},
+		{Name: "Notifier", Environ: append([]string{"NOTIFIER=pigeon"}, valid...), Key: "NOTIFIER"},
+		{Name: "SMTP Address", Environ: append([]string{"NOTIFIER=smtp", "SMTP_ADDR=mail.example.com"}, valid...), Key: "SMTP_ADDR"},
+		{Name: "Notify Dir", Environ: append([]string{"NOTIFIER=file", "NOTIFY_DIR=/nonexistent/mail"}, valid...), Key: "NOTIFY_DIR"},
+		{Name: "Signature Window", Environ: append([]string{"SIGNATURE_WINDOW=0s"}, valid...), Key: "SIGNATURE_WINDOW"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := (&Loader{Environ: tc.Environ}).Load()
+			requireConfigError(t, err, tc.Key)
+		})
+	}
+
+	t.Run("Unknown File Key", func(t *testing.T) {
+		t.Parallel()
+
+		file := writeFile(t, "app.env", "DB_USER=root\nTOKEN_SYMMETRIC_KEY="+testTokenKey+"\nDB_PASWORD=typo\n")
+		_, err := (&Loader{File: file}).Load()
+		requireConfigError(t, err, "DB_PASWORD")
+	})
+}
+
+func TestDatabaseURL(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		DbHost:    "db.internal",
+		DbPort:    5433,
+		DbUser:    "bank user",
+		DbPass:    "p@ss:w/rd?#%&=",
+		DbName:    "simple/bank",
+		DbSslMode: "require",
+	}
+
+	parsed, err := pgxpool.ParseConfig(config.DatabaseURL())
+	require.NoError(t, err)
+	require.Equal(t, "db.internal", parsed.ConnConfig.Host)
+	require.Equal(t, uint16(5433), parsed.ConnConfig.Port)
+	require.Equal(t, config.DbUser, parsed.ConnConfig.User)
+	require.Equal(t, config.DbPass, parsed.ConnConfig.Password)
+	require.Equal(t, config.DbName, parsed.ConnConfig.Database)
+}
+
+func TestPoolConfig(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		DbHost:              "localhost",
+		DbPort:              5432,
+		DbUser:              "root",
+		DbName:              "simple_bank",
+		DbSslMode:           "disable",
+		DbMaxConns:          7,
+		DbMinConns:          2,
+		DbMaxConnLifetime:   time.Hour,
+		DbMaxConnIdleTime:   time.Minute,
+		DbHealthCheckPeriod: 30 * time.Second,
+		DbConnectTimeout:    3 * time.Second,
+	}
+
+	poolConfig, err := config.PoolConfig()
+	require.NoError(t, err)
+	require.Equal(t, int32(7), poolConfig.MaxConns)
+	require.Equal(t, int32(2), poolConfig.MinConns)
+	require.Equal(t, time.Hour, poolConfig.MaxConnLifetime)
+	require.Equal(t, time.Minute, poolConfig.MaxConnIdleTime)
+	require.Equal(t, 30*time.Second, poolConfig.HealthCheckPeriod)
+	require.Equal(t, 3*time.Second, poolConfig.ConnConfig.ConnectTimeout)
+}
+
+func requireConfigError(t *testing.T, err error, key string) {
+	t.Helper()
+
+	var configErr *ConfigError
+	require.True(t, errors.As(err, &configErr), "got %v", err)
+	require.ErrorContains(t, err, key+": ")
+}
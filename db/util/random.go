@@ -47,7 +47,5 @@ func RandomMoney() pgtype.Numeric {
 }
 
 func RandomCurrency() string {
-	currencies := []string{"EUR", "BHD", "USD", "AED", "SAR", "CAD"}
-	n := len(currencies)
-	return currencies[rand.Intn(n)]
+	return Currencies[rand.Intn(len(Currencies))]
 }
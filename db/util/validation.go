@@ -1,6 +1,13 @@
 package util
 
-import "github.com/go-playground/validator/v10"
+import (
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Currencies lists the currencies accounts and transfers may use.
+var Currencies = []string{"EUR", "BHD", "USD", "AED", "SAR", "CAD"}
 
 
 
@@ -19,10 +26,5 @@ var Currency validator.Func = func(fl validator.FieldLevel) bool {
 
 
 func validCurrency(curr string) bool {
-	switch curr {
-		case "EUR", "BHD", "USD", "AED", "SAR", "CAD" :
-			return true
-		default:
-			return false
-	}	
-}
\ No newline at end of file
+	return slices.Contains(Currencies, curr)
+}
@@ -0,0 +1,75 @@
+// Package logging builds the JSON slog logger shared by the HTTP server, the gRPC server,
+// the background workers and main. Every record logged with a context carries the
+// request ID and trace ID stored in it, and sensitive attributes are masked before they
+// are written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// Attribute names of the request ID and of the current trace in log lines.
+const (
+	RequestIDAttr = "request_id"
+	TraceIDAttr   = "trace_id"
+	SpanIDAttr    = "span_id"
+)
+
+// WithRequestID returns a context that carries the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, or "".
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// New returns a JSON logger writing to w at the given level ("debug", "info", "warn"
+// or "error"; "" means info) that masks values as configured in redactor.
+func New(w io.Writer, level string, redactor *Redactor) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redactor.ReplaceAttr,
+	})
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler adds the request ID and the trace from the record's context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String(RequestIDAttr, requestID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String(TraceIDAttr, span.TraceID().String()), slog.String(SpanIDAttr, span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
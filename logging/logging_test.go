@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "debug", NewRedactor(nil))
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	logger.With("component", "test").InfoContext(ctx, "hello")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "req-123", line[RequestIDAttr])
+	require.Equal(t, "test", line["component"])
+	require.Equal(t, "hello", line["msg"])
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", NewRedactor(nil))
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	require.Zero(t, buf.Len())
+
+	_, err = New(&buf, "loud", NewRedactor(nil))
+	require.Error(t, err)
+}
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor(nil)
+
+	require.Equal(t, redacted, redactor.Value("password", "hunter2"))
+	require.Equal(t, redacted, redactor.Value("Authorization", "Bearer abc"))
+	require.Equal(t, "a***@example.com", redactor.Value("email", "alice@example.com"))
+	require.Equal(t, "***42", redactor.Value("account_id", "12342"))
+	require.Equal(t, "**", redactor.Value("to_account_id", "71"))
+	require.Equal(t, "plain", redactor.Value("username", "plain"))
+	require.Equal(t, "mail b***@example.org now", redactor.Value("note", "mail bob@example.org now"))
+
+	query := redactor.Query(url.Values{"username": {"alice"}, "password": {"hunter2"}})
+	require.Equal(t, "password=%5BREDACTED%5D&username=alice", query)
+
+	custom := NewRedactor([]string{"pin"})
+	require.Equal(t, redacted, custom.Value("PIN", "1234"))
+	require.Equal(t, redacted, custom.Value("password", "hunter2"), "the defaults stay masked")
+}
+
+func TestRedactorMasksAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", NewRedactor(nil))
+	require.NoError(t, err)
+
+	logger.Info("login",
+		slog.String("password", "hunter2"),
+		slog.Int64("account_id", 123456),
+		slog.Any("error", errors.New("no user carol@example.com")),
+		slog.Group("user", slog.String("email", "carol@example.com")),
+	)
+
+	out := buf.String()
+	require.NotContains(t, out, "hunter2")
+	require.NotContains(t, out, "123456")
+	require.NotContains(t, out, "carol@example.com")
+	require.Contains(t, out, `"account_id":"****56"`)
+	require.Contains(t, out, "c***@example.com")
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// DefaultRedactKeys are always masked, whatever keys are configured besides.
+var DefaultRedactKeys = []string{
+	"password", "token", "access_token", "secret", "authorization",
+	"email", "account_id", "from_account_id", "to_account_id",
+}
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+// Redactor masks sensitive values by attribute or query parameter name:
+//   - keys containing "email" keep the first character and the domain: a***@example.com
+//   - keys naming an account (account_id, account_number, ...) keep the last two digits
+//   - any other configured key is replaced entirely
+//
+// Email addresses are also masked wherever they appear inside other string values.
+type Redactor struct {
+	keys map[string]bool
+}
+
+// NewRedactor masks DefaultRedactKeys and the given keys, matched case-insensitively.
+func NewRedactor(keys []string) *Redactor {
+	redactor := &Redactor{keys: map[string]bool{}}
+	for _, key := range slices.Concat(DefaultRedactKeys, keys) {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			redactor.keys[key] = true
+		}
+	}
+	return redactor
+}
+
+// Value returns value masked as appropriate for key.
+func (r *Redactor) Value(key, value string) string {
+	key = strings.ToLower(key)
+	if !r.keys[key] {
+		return maskEmails(value)
+	}
+
+	switch {
+	case strings.Contains(key, "email"):
+		return maskEmails(value)
+	case strings.Contains(key, "account"):
+		return maskAccountNumber(value)
+	default:
+		return redacted
+	}
+}
+
+// Query returns the encoded query with every parameter value masked by its name.
+func (r *Redactor) Query(query url.Values) string {
+	masked := url.Values{}
+	for key, values := range query {
+		for _, value := range values {
+			masked.Add(key, r.Value(key, value))
+		}
+	}
+	return masked.Encode()
+}
+
+// ReplaceAttr is a slog.HandlerOptions.ReplaceAttr that masks attribute values.
+func (r *Redactor) ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+		return a
+	}
+
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Value(a.Key, value.String()))
+	case slog.KindInt64, slog.KindUint64:
+		if r.keys[strings.ToLower(a.Key)] {
+			return slog.String(a.Key, r.Value(a.Key, value.String()))
+		}
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(a.Key, maskEmails(err.Error()))
+		}
+		if r.keys[strings.ToLower(a.Key)] {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+func maskEmails(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	return emailPattern.ReplaceAllString(value, "$1***@$2")
+}
+
+func maskAccountNumber(value string) string {
+	if len(value) <= 2 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-2) + value[len(value)-2:]
+}
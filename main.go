@@ -2,50 +2,278 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"example.com/api"
+	"example.com/db/memstore"
 	"example.com/db/sqlc"
 	"example.com/db/util"
+	"example.com/logging"
+	"example.com/metrics"
+	"example.com/pii"
+	"example.com/stream"
+	"example.com/tracing"
+	"example.com/worker"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
-	// Load configuration
-	config, err := util.LoadConfig("app.env")
+	// Load configuration: the env file, then the environment, then flags
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configFile := flags.String("config", "app.env", "env file to load before the environment and flags")
+	loader := util.NewLoader("")
+	loader.RegisterFlags(flags)
+	flags.Parse(os.Args[1:])
+
+	loader.File = *configFile
+	config, err := loader.Load()
+	if err != nil {
+		fatal("failed to load config", err)
+	}
+
+	// Log JSON through slog everywhere, including the standard log package
+	logger, err := logging.New(os.Stdout, config.LogLevel, logging.NewRedactor(config.LogRedactKeys))
+	if err != nil {
+		fatal("failed to create logger", err)
+	}
+	slog.SetDefault(logger)
+
+	// Export traces; the file exporter lets them be checked locally without a collector
+	traceTarget := config.TracingOTLPEndpoint
+	if config.TracingExporter == tracing.ExporterFile {
+		traceTarget = config.TracingFile
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), config.TracingExporter, traceTarget)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
+	// Open the store: Postgres, or an empty in-memory bank for local demos
+	var dbPool *pgxpool.Pool
+	var memStore *memstore.Store
+	var store db.Store
+	args := flags.Args()
+
+	if config.Store == util.StoreMemory {
+		if len(args) > 0 && args[0] == "migrate" {
+			fatal("cannot migrate", errors.New("the in-memory store has no schema to migrate"))
+		}
+		memStore = memstore.New()
+		store = memStore
+		slog.Warn("using the in-memory store; nothing survives a restart")
+	} else {
+		// Initialize DB connection pool, tracing every query
+		poolConfig, err := config.PoolConfig()
+		if err != nil {
+			fatal("invalid database configuration", err)
+		}
+		poolConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
+
+		dbPool, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			fatal("failed to connect to database", err)
+		}
+
+		// "migrate" manages the schema instead of serving
+		if len(args) > 0 && args[0] == "migrate" {
+			err := runMigrate(dbPool, args[1:], os.Stdout)
+			dbPool.Close()
+			if err != nil {
+				fatal("migration failed", err)
+			}
+			return
+		}
+
+		// Apply pending migrations if asked to; replicas take turns under an advisory lock
+		if config.MigrateOnStart {
+			if err := migrateUp(dbPool); err != nil {
+				fatal("failed to migrate database", err)
+			}
+		}
+
+		store = db.NewStore(dbPool)
+		metrics.RegisterPool(dbPool)
+	}
+
+	// Encrypt the users' personal data at rest, if there is a master key to do it with
+	var piiStore *pii.Store
+	if config.PIIMasterKeys != "" {
+		keys, err := pii.NewLocalKeyProvider(config.PIIMasterKeys, config.PIIBlindIndexKey)
+		if err != nil {
+			fatal("invalid PII keys", err)
+		}
+		piiStore = pii.NewStore(store, keys)
+		store = piiStore
+	} else {
+		slog.Warn("PII_MASTER_KEYS is not set; personal data is stored in plaintext")
+	}
+	store = metrics.NewStore(store)
+
+	// Create server, refusing to serve a schema older than this build expects
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), 10*time.Second)
+	err = store.Ping(pingCtx)
+	cancelPing()
+	if err != nil {
+		fatal("refusing to start", err)
+	}
+
+	// Index the emails still in plaintext before taking writes, so no new user can take one
+	if piiStore != nil {
+		indexCtx, cancelIndex := context.WithTimeout(context.Background(), time.Minute)
+		indexed, err := piiStore.IndexEmails(indexCtx, 500)
+		cancelIndex()
+		if err != nil {
+			fatal("failed to index emails", err)
+		}
+		if indexed > 0 {
+			slog.Info("indexed emails in plaintext", "users", indexed)
+		}
+	}
+
+	server, err := api.NewServer(config, store)
+	if err != nil {
+		fatal("failed to create server", err)
+	}
+
+	// Stop on SIGINT or SIGTERM; everything below drains before the pool is closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	background := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	// Fan committed account changes out to streaming clients
+	if memStore != nil {
+		memStore.Listen(func(event db.AccountEvent) { server.Broker.Publish(event.AccountID) })
+	} else {
+		background(func(ctx context.Context) { stream.Listen(ctx, dbPool, server.Broker) })
+	}
+
+	// Start background jobs
+	overdraftJob, err := worker.NewOverdraftInterestJob(store, config.OverdraftAnnualRate)
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
-	}
-
-	// Build DB connection string
-	dbURL := fmt.Sprintf(
-		"postgresql://%s:%s@%s:%d/%s?sslmode=%s",
-		config.DbUser,
-		config.DbPass,
-		config.DbHost,
-		config.DbPort,
-		config.DbName,
-		config.DbSslMode,
-	)
-
-	// Initialize DB connection pool
-	dbPool, err := pgxpool.New(context.Background(), dbURL)
+		fatal("failed to create overdraft interest job", err)
+	}
+	background(func(ctx context.Context) { worker.RunDaily(ctx, overdraftJob, 24*time.Hour) })
+	background(func(ctx context.Context) { worker.RunDaily(ctx, worker.NewInterestAccrualJob(store), 24*time.Hour) })
+	background(func(ctx context.Context) {
+		worker.RunDaily(ctx, worker.NewInterestPostingJob(store, config.InterestExpenseOwner), 24*time.Hour)
+	})
+	if piiStore != nil {
+		background(func(ctx context.Context) {
+			worker.RunDaily(ctx, worker.NewPIIRekeyJob(piiStore), config.PIIRekeyInterval)
+		})
+	}
+	background(func(ctx context.Context) {
+		worker.RunDaily(ctx, worker.NewRequestNonceSweepJob(store), config.SignatureWindow)
+	})
+	background(func(ctx context.Context) {
+		worker.NewWebhookDispatcher(store, worker.NewWebhookClient(10*time.Second)).Start(ctx, 5*time.Second)
+	})
+
+	// Serve gRPC alongside HTTP
+	grpcAddr := fmt.Sprintf(":%s", config.GrpcPort)
+	grpcListener, err := net.Listen("tcp", grpcAddr)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		fatal("failed to listen on "+grpcAddr, err)
 	}
-	defer dbPool.Close()
+	grpcServer := server.NewGRPCServer()
 
-	// Create store and server
-	store := db.NewStore(dbPool)
-	server := api.NewServer(store)
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("starting gRPC server", "addr", grpcAddr)
+		serveErr <- grpcServer.Serve(grpcListener)
+	}()
 
 	// Start server
 	addr := fmt.Sprintf(":%s", config.AppPort)
-	log.Printf("starting server on %s...", addr)
+	go func() {
+		slog.Info("starting server", "addr", addr)
+		serveErr <- server.Start(addr)
+	}()
+
+	failed := false
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", config.ShutdownTimeout)
+	case err := <-serveErr:
+		slog.Error("server stopped with error", "error", err)
+		failed = true
+	}
+	stop()
+
+	// Drain: stop accepting requests, let in-flight transfers and worker runs finish, then close the pool
+	drainCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
 
-	if err := server.Start(addr); err != nil {
-		log.Fatalf("server stopped with error: %v", err)
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	if err := server.Shutdown(drainCtx); err != nil {
+		slog.Error("HTTP server did not drain", "error", err)
+		failed = true
 	}
+	select {
+	case <-grpcStopped:
+	case <-drainCtx.Done():
+		slog.Error("gRPC server did not drain", "error", drainCtx.Err())
+		grpcServer.Stop()
+		failed = true
+	}
+	if err := wait(drainCtx, &workers); err != nil {
+		slog.Error("background workers did not finish", "error", err)
+		failed = true
+	}
+
+	if dbPool != nil {
+		dbPool.Close()
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+	slog.Info("stopped")
+}
+
+// wait waits for wg until ctx expires.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fatal logs err through the default logger and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,56 @@
+// Package metrics defines the Prometheus metrics of the service. HTTP metrics are recorded
+// by the api package; business and database metrics are recorded here, by a db.Store
+// decorator and a pgxpool collector, so that the db package stays free of them.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "simplebank"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	Transfers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "Transfers by outcome (created or failed), failure reason and currency.",
+	}, []string{"outcome", "reason", "currency"})
+
+	TransferVolume = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_volume_total",
+		Help:      "Amount moved by created transfers, in units of the currency.",
+	}, []string{"currency"})
+
+	TransferTxRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_tx_retries_total",
+		Help:      "TransferTx transactions run again after a deadlock or serialization failure.",
+	})
+
+	TransferTxDeadlocks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_tx_deadlocks_total",
+		Help:      "TransferTx transactions aborted by Postgres as deadlocked.",
+	})
+
+	AccountsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accounts_created_total",
+		Help:      "Accounts opened by currency and account type.",
+	}, []string{"currency", "account_type"})
+)
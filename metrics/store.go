@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Failure reasons of the transfers_total metric.
+const (
+	ReasonInsufficientFunds = "insufficient_funds"
+	ReasonAccountNotFound   = "account_not_found"
+	ReasonAccountFrozen     = "account_frozen"
+	ReasonAccountClosed     = "account_closed"
+	ReasonDeadlock          = "deadlock"
+	ReasonCanceled          = "canceled"
+	ReasonError             = "error"
+)
+
+// unknownCurrency labels failed transfers whose source account cannot be read.
+const unknownCurrency = "unknown"
+
+// Store records business metrics around the wrapped store's transactions.
+type Store struct {
+	db.Store
+}
+
+func NewStore(store db.Store) db.Store {
+	return &Store{Store: store}
+}
+
+func (store *Store) TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+	result, err := store.Store.TransferTx(ctx, arg)
+
+	if result.Attempts > 1 {
+		TransferTxRetries.Add(float64(result.Attempts - 1))
+	}
+	if result.Deadlocks > 0 {
+		TransferTxDeadlocks.Add(float64(result.Deadlocks))
+	}
+
+	if err != nil {
+		Transfers.WithLabelValues("failed", transferFailureReason(err), store.accountCurrency(ctx, arg.FromAccountId)).Inc()
+		return result, err
+	}
+
+	currency := result.FromAccount.Currency
+	Transfers.WithLabelValues("created", "", currency).Inc()
+	amount, _ := db.NumericToRat(result.Transfer.Amount).Float64()
+	TransferVolume.WithLabelValues(currency).Add(amount)
+
+	return result, nil
+}
+
+func (store *Store) CreateAccountTx(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	account, err := store.Store.CreateAccountTx(ctx, arg)
+	if err == nil {
+		AccountsCreated.WithLabelValues(account.Currency, string(account.AccountType)).Inc()
+	}
+	return account, err
+}
+
+// accountCurrency looks up the currency of a failed transfer's source account. The
+// lookup only happens on failures, which keeps the happy path at one transaction.
+func (store *Store) accountCurrency(ctx context.Context, accountID int64) string {
+	account, err := store.Store.GetAccount(ctx, accountID)
+	if err != nil {
+		return unknownCurrency
+	}
+	return account.Currency
+}
+
+func transferFailureReason(err error) string {
+	switch {
+	case errors.Is(err, db.ErrInsufficientFunds):
+		return ReasonInsufficientFunds
+	case errors.Is(err, pgx.ErrNoRows), isForeignKeyViolation(err):
+		return ReasonAccountNotFound
+	case errors.Is(err, db.ErrAccountFrozen):
+		return ReasonAccountFrozen
+	case errors.Is(err, db.ErrAccountClosed):
+		return ReasonAccountClosed
+	case db.IsDeadlock(err):
+		return ReasonDeadlock
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ReasonCanceled
+	default:
+		return ReasonError
+	}
+}
+
+// isForeignKeyViolation catches transfers that reference an account that does not exist.
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStoreTransferTx(t *testing.T) {
+	arg := db.TransferTxParams{FromAccountId: 1, ToAccountId: 2, Amount: 25}
+
+	testCases := []struct {
+		Name      string
+		BuildStub func(*mock.MockStore)
+		Retries   float64
+		Deadlocks float64
+		Check     func(t *testing.T, err error)
+	}{
+		{
+			Name: "Created",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), arg).Times(1).Return(db.TransferTxResult{
+					Transfer:    db.Transfer{Amount: pgtype.Numeric{Int: big.NewInt(25), Valid: true}},
+					FromAccount: db.Account{ID: 1, Currency: "CAD"},
+					Attempts:    1,
+				}, nil)
+			},
+			Check: func(t *testing.T, err error) {
+				require.NoError(t, err)
+				require.Equal(t, float64(1), testutil.ToFloat64(Transfers.WithLabelValues("created", "", "CAD")))
+				require.Equal(t, float64(25), testutil.ToFloat64(TransferVolume.WithLabelValues("CAD")))
+			},
+		},
+		{
+			Name: "Insufficient Funds",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), arg).Times(1).Return(db.TransferTxResult{Attempts: 1}, db.ErrInsufficientFunds)
+				ms.EXPECT().GetAccount(gomock.Any(), arg.FromAccountId).Times(1).Return(db.Account{ID: 1, Currency: "SAR"}, nil)
+			},
+			Check: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, db.ErrInsufficientFunds)
+				require.Equal(t, float64(1), testutil.ToFloat64(Transfers.WithLabelValues("failed", ReasonInsufficientFunds, "SAR")))
+			},
+		},
+		{
+			Name: "Account Closed",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), arg).Times(1).Return(db.TransferTxResult{Attempts: 1}, db.ErrAccountClosed)
+				ms.EXPECT().GetAccount(gomock.Any(), arg.FromAccountId).Times(1).Return(db.Account{ID: 1, Currency: "NOK"}, nil)
+			},
+			Check: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, db.ErrAccountClosed)
+				require.Equal(t, float64(1), testutil.ToFloat64(Transfers.WithLabelValues("failed", ReasonAccountClosed, "NOK")))
+			},
+		},
+		{
+			Name: "Deadlocked Then Retried",
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().TransferTx(gomock.Any(), arg).Times(1).Return(db.TransferTxResult{Attempts: 3, Deadlocks: 3}, &pgconn.PgError{Code: "40P01"})
+				ms.EXPECT().GetAccount(gomock.Any(), arg.FromAccountId).Times(1).Return(db.Account{}, fmt.Errorf("connection lost"))
+			},
+			Retries:   2,
+			Deadlocks: 3,
+			Check: func(t *testing.T, err error) {
+				require.Error(t, err)
+				require.Equal(t, float64(1), testutil.ToFloat64(Transfers.WithLabelValues("failed", ReasonDeadlock, unknownCurrency)))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			Transfers.Reset()
+			TransferVolume.Reset()
+			retries, deadlocks := testutil.ToFloat64(TransferTxRetries), testutil.ToFloat64(TransferTxDeadlocks)
+
+			ctrl := gomock.NewController(t)
+			mockStore := mock.NewMockStore(ctrl)
+			tc.BuildStub(mockStore)
+
+			_, err := NewStore(mockStore).TransferTx(context.Background(), arg)
+			tc.Check(t, err)
+
+			// Plain counters cannot be reset, so they are compared by their increase.
+			require.Equal(t, retries+tc.Retries, testutil.ToFloat64(TransferTxRetries))
+			require.Equal(t, deadlocks+tc.Deadlocks, testutil.ToFloat64(TransferTxDeadlocks))
+		})
+	}
+}
+
+func TestStoreCreateAccountTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mock.NewMockStore(ctrl)
+	mockStore.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).
+		Return(db.Account{ID: 1, Currency: "EUR", AccountType: db.AccountTypeSavings}, nil)
+
+	before := testutil.ToFloat64(AccountsCreated.WithLabelValues("EUR", "savings"))
+	_, err := NewStore(mockStore).CreateAccountTx(context.Background(), db.CreateAccountParams{})
+	require.NoError(t, err)
+	require.Equal(t, before+1, testutil.ToFloat64(AccountsCreated.WithLabelValues("EUR", "savings")))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+
+	"example.com/db/migration"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = "usage: migrate up [N] | down N | down all | status | force VERSION"
+
+// runMigrate runs the migrate subcommand: up [N], down N, down all, status or force VERSION.
+// Every command prints the resulting status.
+func runMigrate(pool *pgxpool.Pool, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migration.New(pool)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = migrator.Up()
+	case args[0] == "down" && len(args) == 2 && args[1] == "all":
+		err = migrator.DownAll()
+	case args[0] == "up" && len(args) == 2, args[0] == "down" && len(args) == 2:
+		var n int
+		if n, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid number of migrations %q: %w", args[1], err)
+		}
+		if args[0] == "up" {
+			err = migrator.UpN(n)
+		} else {
+			err = migrator.DownN(n)
+		}
+	case args[0] == "force" && len(args) == 2:
+		var version int
+		if version, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[1], err)
+		}
+		err = migrator.Force(version)
+	case args[0] == "status" && len(args) == 1:
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	printStatus(out, status)
+	return nil
+}
+
+// migrateUp applies the pending migrations at startup.
+func migrateUp(pool *pgxpool.Pool) error {
+	migrator, err := migration.New(pool)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(); err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	slog.Info("database migrated", "version", status.Version)
+	return nil
+}
+
+func printStatus(out io.Writer, status migration.Status) {
+	fmt.Fprintf(out, "version: %d\n", status.Version)
+	fmt.Fprintf(out, "dirty:   %t\n", status.Dirty)
+	fmt.Fprintf(out, "latest:  %d\n", status.Latest)
+	if len(status.Pending) == 0 {
+		fmt.Fprintln(out, "pending: none")
+		return
+	}
+	fmt.Fprintf(out, "pending: %v\n", status.Pending)
+}
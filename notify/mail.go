@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+var errHeaderInjection = errors.New("message header contains a line break")
+
+// formatMessage renders msg as an email from the address from.
+func formatMessage(from string, msg Message, date time.Time) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errHeaderInjection
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	// Email lines end in CRLF.
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes(), nil
+}
+
+// SMTPNotifier sends messages as email through an SMTP server. It upgrades the connection
+// with STARTTLS when the server offers it, and logs in when a username is set.
+type SMTPNotifier struct {
+	// Addr is the host:port of the server.
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	data, err := formatMessage(n.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", n.Addr, err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileNotifier writes every message as an email to a file of its own in Dir, for local
+// development and tests. The file names sort in the order the messages were sent.
+type FileNotifier struct {
+	Dir  string
+	From string
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := formatMessage(n.From, msg, now)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(n.Dir, now.UTC().Format("20060102T150405.000000000")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testMessage = Message{
+	Username: "alice",
+	To:       "alice@example.com",
+	Subject:  "Verify your email",
+	Body:     "Your code is 1.abc\n.\nThanks",
+}
+
+// smtpStandIn accepts one SMTP session on a local port and sends what it received on
+// the channel: the envelope recipient and the message data.
+func smtpStandIn(t *testing.T) (string, <-chan [2]string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan [2]string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		var rcpt, data string
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				text.PrintfLine("250-localhost")
+				text.PrintfLine("250 8BITMIME")
+			case "RCPT":
+				rcpt = arg
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				b, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				data = string(b)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				received <- [2]string{rcpt, data}
+				return
+			default:
+				text.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, received := smtpStandIn(t)
+
+	notifier := &SMTPNotifier{Addr: addr, From: "bank@example.com"}
+	require.NoError(t, notifier.Notify(t.Context(), testMessage))
+
+	got := <-received
+	require.Equal(t, "TO:<alice@example.com>", got[0])
+	require.Contains(t, got[1], "From: bank@example.com\n")
+	require.Contains(t, got[1], "To: alice@example.com\n")
+	require.Contains(t, got[1], "Subject: Verify your email\n")
+	require.True(t, strings.HasSuffix(got[1], "\nYour code is 1.abc\n.\nThanks\n"), got[1])
+}
+
+func TestSMTPNotifierUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	notifier := &SMTPNotifier{Addr: addr, From: "bank@example.com"}
+	require.Error(t, notifier.Notify(t.Context(), testMessage))
+}
+
+func TestFileNotifier(t *testing.T) {
+	dir := t.TempDir()
+	notifier := &FileNotifier{Dir: dir, From: "bank@example.com"}
+
+	second := testMessage
+	second.Subject = "Ünïcödé"
+	require.NoError(t, notifier.Notify(t.Context(), testMessage))
+	require.NoError(t, notifier.Notify(t.Context(), second))
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+
+	first, err := os.ReadFile(names[0])
+	require.NoError(t, err)
+	require.Contains(t, string(first), "Subject: Verify your email\r\n")
+	require.True(t, strings.HasSuffix(string(first), "\r\n\r\nYour code is 1.abc\r\n.\r\nThanks"))
+
+	last, err := os.ReadFile(names[1])
+	require.NoError(t, err)
+	require.Contains(t, string(last), "Subject: =?utf-8?q?")
+}
+
+func TestHeaderInjection(t *testing.T) {
+	notifier := &FileNotifier{Dir: t.TempDir(), From: "bank@example.com"}
+
+	msg := testMessage
+	msg.To = "alice@example.com\r\nBcc: mallory@example.com"
+	require.ErrorIs(t, notifier.Notify(t.Context(), msg), errHeaderInjection)
+
+	msg = testMessage
+	msg.Subject = "Hello\nBcc: mallory@example.com"
+	require.ErrorIs(t, notifier.Notify(t.Context(), msg), errHeaderInjection)
+}
@@ -0,0 +1,62 @@
+// Package notify sends users messages outside of the API, such as the password reset
+// tokens that must reach the owner of an account rather than whoever asked for them. The
+// messages go out by email through an SMTPNotifier, or to files or the log for local
+// development.
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Message is a notification to one user.
+type Message struct {
+	Username string
+	// To is the address to send the message to: the user's email address.
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the log instead of delivering them, so that they can be
+// read there during development. Its log lines carry secrets such as reset tokens.
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	n.logger.InfoContext(ctx, "notification", "to", msg.To, "user", msg.Username, "subject", msg.Subject, "message", msg.Body)
+	return nil
+}
+
+// Recorder keeps the messages it is given, for tests.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (r *Recorder) Notify(ctx context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// Messages returns the messages recorded so far, oldest first.
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Message(nil), r.messages...)
+}
@@ -0,0 +1,462 @@
+// Package openapi builds an OpenAPI 3.1 document from the Go types that handlers bind
+// requests into and render responses from, including their binding constraints.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	// In and Name locate the key of an apiKey scheme, such as a header.
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema is the subset of JSON Schema 2020-12 the generator emits.
+// Type is either a string or, for nullable values, a list of strings.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Route documents one handler. URI and Query are structs with uri and form tags,
+// Body and Response are the values bound from and rendered to JSON. A nil Response
+// documents a response without a body.
+type Route struct {
+	Method   string
+	Path     string
+	Summary  string
+	Tags     []string
+	Security string
+
+	URI   any
+	Query any
+	Body  any
+
+	Status      int
+	Response    any
+	ContentType string
+}
+
+type Generator struct {
+	doc          Document
+	types        map[reflect.Type]Schema
+	validations  map[string]Schema
+	errorType    any
+	alternatives map[string][]string
+}
+
+func New(title, version string) *Generator {
+	return &Generator{
+		doc: Document{
+			OpenAPI: Version,
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]PathItem{},
+			Components: Components{
+				Schemas: map[string]*Schema{},
+			},
+		},
+		types: map[reflect.Type]Schema{
+			reflect.TypeOf(time.Time{}): {Type: "string", Format: "date-time"},
+		},
+		validations: map[string]Schema{
+			"email": {Format: "email"},
+			"url":   {Format: "uri"},
+			"uuid":  {Format: "uuid"},
+		},
+	}
+}
+
+// Type documents every value of the same type as value with schema, for types
+// whose JSON form differs from their Go structure.
+func (g *Generator) Type(value any, schema Schema) {
+	g.types[reflect.TypeOf(value)] = schema
+}
+
+// Enum documents a named string type as a string restricted to values.
+func (g *Generator) Enum(value any, values ...any) {
+	g.types[reflect.TypeOf(value)] = Schema{Type: "string", Enum: values}
+}
+
+// Validation documents a custom binding tag, such as a validator registered with gin,
+// by merging schema into every field that uses it.
+func (g *Generator) Validation(tag string, schema Schema) {
+	g.validations[tag] = schema
+}
+
+// ErrorResponse documents the body of every error response.
+func (g *Generator) ErrorResponse(value any) {
+	g.errorType = value
+}
+
+func (g *Generator) SecurityScheme(name string, scheme SecurityScheme) {
+	if g.doc.Components.SecuritySchemes == nil {
+		g.doc.Components.SecuritySchemes = map[string]SecurityScheme{}
+	}
+	g.doc.Components.SecuritySchemes[name] = scheme
+}
+
+// AlternativeSecurity documents that the routes secured by scheme also accept
+// alternative in its place.
+func (g *Generator) AlternativeSecurity(scheme, alternative string) {
+	if g.alternatives == nil {
+		g.alternatives = map[string][]string{}
+	}
+	g.alternatives[scheme] = append(g.alternatives[scheme], alternative)
+}
+
+func (g *Generator) Add(route Route) {
+	op := &Operation{
+		Summary:   route.Summary,
+		Tags:      route.Tags,
+		Responses: map[string]Response{},
+	}
+
+	if route.Security != "" {
+		op.Security = []map[string][]string{{route.Security: {}}}
+		for _, alternative := range g.alternatives[route.Security] {
+			op.Security = append(op.Security, map[string][]string{alternative: {}})
+		}
+	}
+
+	op.Parameters = append(op.Parameters, g.parameters(route.URI, "uri", "path")...)
+	op.Parameters = append(op.Parameters, g.parameters(route.Query, "form", "query")...)
+
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(route.Body))}},
+		}
+	}
+
+	response := Response{Description: http.StatusText(route.Status)}
+	if route.Response != nil {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		response.Content = map[string]MediaType{contentType: {Schema: g.schema(reflect.TypeOf(route.Response))}}
+	}
+	op.Responses[strconv.Itoa(route.Status)] = response
+
+	if g.errorType != nil {
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(g.errorType))}},
+		}
+	}
+
+	path := Path(route.Path)
+	if g.doc.Paths[path] == nil {
+		g.doc.Paths[path] = PathItem{}
+	}
+	g.doc.Paths[path][strings.ToLower(route.Method)] = op
+}
+
+func (g *Generator) Document() *Document {
+	return &g.doc
+}
+
+// Path converts a gin route path such as /accounts/:id into an OpenAPI path template.
+func Path(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (g *Generator) parameters(value any, tag, in string) []Parameter {
+	if value == nil {
+		return nil
+	}
+
+	var params []Parameter
+	t := indirect(reflect.TypeOf(value))
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		// Like gin, take the parameters of an embedded struct as the struct's own.
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			params = append(params, g.parameters(reflect.New(indirect(field.Type)).Interface(), tag, in)...)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+
+		schema := g.schema(field.Type)
+		required := g.constrain(schema, field.Tag.Get("binding"))
+		params = append(params, Parameter{
+			Name:     name,
+			In:       in,
+			Required: required || in == "path",
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// schema returns the schema of t. Named structs are added to the components and referenced.
+func (g *Generator) schema(t reflect.Type) *Schema {
+	if schema, ok := g.types[t]; ok {
+		return &schema
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			// Reserve the name first so that recursive types terminate.
+			g.doc.Components.Schemas[name] = &Schema{}
+			*g.doc.Components.Schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(schema, t)
+	return schema
+}
+
+func (g *Generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			g.addFields(schema, indirect(field.Type))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		if g.constrain(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// constrain applies the validator rules in a binding tag to schema and reports
+// whether the field is required. Rules after "dive" apply to array items.
+func (g *Generator) constrain(schema *Schema, binding string) bool {
+	if binding == "" {
+		return false
+	}
+
+	required := false
+	target := schema
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			// The items schema may be shared, so constrain a copy.
+			items := *target.Items
+			target.Items = &items
+			target = target.Items
+		case "min", "max", "gte", "lte", "gt", "lt", "len":
+			limit(target, name, param)
+		case "oneof":
+			target.Enum = nil
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, value)
+			}
+		case "numeric":
+			target.Pattern = `^[-+]?[0-9]+(\.[0-9]+)?$`
+		default:
+			if extra, ok := g.validations[name]; ok {
+				merge(target, extra)
+			}
+		}
+	}
+	return required
+}
+
+func limit(schema *Schema, rule, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	lower := rule == "min" || rule == "gte" || rule == "gt" || rule == "len"
+	upper := rule == "max" || rule == "lte" || rule == "lt" || rule == "len"
+
+	switch schema.Type {
+	case "integer", "number":
+		if rule == "gt" {
+			n++
+		} else if rule == "lt" {
+			n--
+		}
+		if lower {
+			schema.Minimum = &n
+		}
+		if upper {
+			schema.Maximum = &n
+		}
+	case "string":
+		setCount(&schema.MinLength, &schema.MaxLength, int(n), lower, upper)
+	case "array":
+		setCount(&schema.MinItems, &schema.MaxItems, int(n), lower, upper)
+	}
+}
+
+func setCount(min, max **int, n int, lower, upper bool) {
+	if lower {
+		*min = &n
+	}
+	if upper {
+		*max = &n
+	}
+}
+
+func merge(schema *Schema, extra Schema) {
+	if extra.Type != nil {
+		schema.Type = extra.Type
+	}
+	if extra.Format != "" {
+		schema.Format = extra.Format
+	}
+	if extra.Enum != nil {
+		schema.Enum = extra.Enum
+	}
+	if extra.Pattern != "" {
+		schema.Pattern = extra.Pattern
+	}
+	if extra.Description != "" {
+		schema.Description = extra.Description
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// componentName names a struct's schema after its type, capitalized so that
+// unexported request and response types read like the rest.
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
@@ -0,0 +1,93 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	ID int64 `json:"id"`
+}
+
+type widget struct {
+	base
+	Name   string   `json:"name" binding:"required,min=3,max=20"`
+	Kind   string   `json:"kind" binding:"omitempty,oneof=small large"`
+	Colors []string `json:"colors" binding:"required,min=1,dive,color"`
+	Link   string   `json:"link" binding:"url"`
+	hidden string
+}
+
+type widgetFilter struct {
+	Kind string `form:"kind"`
+}
+
+type widgetQuery struct {
+	widgetFilter
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+type widgetURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func TestGenerator(t *testing.T) {
+	g := New("Widgets", "1.0.0")
+	g.Validation("color", Schema{Enum: []any{"red", "blue"}})
+	g.Add(Route{
+		Method: http.MethodPost, Path: "/widgets/:id",
+		URI: widgetURI{}, Query: widgetQuery{}, Body: widget{},
+		Status: http.StatusCreated, Response: []widget{},
+	})
+
+	doc := g.Document()
+	op := doc.Paths["/widgets/{id}"]["post"]
+	require.NotNil(t, op)
+
+	require.Len(t, op.Parameters, 3)
+	require.Equal(t, "path", op.Parameters[0].In)
+	require.True(t, op.Parameters[0].Required)
+	require.Equal(t, float64(1), *op.Parameters[0].Schema.Minimum)
+	require.Equal(t, "query", op.Parameters[1].In)
+	require.Equal(t, "kind", op.Parameters[1].Name, "embedded")
+	require.False(t, op.Parameters[1].Required)
+	require.Equal(t, float64(10), *op.Parameters[2].Schema.Maximum)
+
+	require.Equal(t, "#/components/schemas/Widget", op.RequestBody.Content["application/json"].Schema.Ref)
+	response := op.Responses["201"].Content["application/json"].Schema
+	require.Equal(t, "array", response.Type)
+	require.Equal(t, "#/components/schemas/Widget", response.Items.Ref)
+
+	schema := doc.Components.Schemas["Widget"]
+	require.ElementsMatch(t, []string{"name", "colors"}, schema.Required)
+	require.Contains(t, schema.Properties, "id")
+	require.NotContains(t, schema.Properties, "hidden")
+	require.Equal(t, 3, *schema.Properties["name"].MinLength)
+	require.Equal(t, 20, *schema.Properties["name"].MaxLength)
+	require.Equal(t, []any{"small", "large"}, schema.Properties["kind"].Enum)
+	require.Equal(t, 1, *schema.Properties["colors"].MinItems)
+	require.Equal(t, []any{"red", "blue"}, schema.Properties["colors"].Items.Enum)
+	require.Equal(t, "uri", schema.Properties["link"].Format)
+}
+
+func TestAlternativeSecurity(t *testing.T) {
+	g := New("Widgets", "1.0.0")
+	g.SecurityScheme("bearerAuth", SecurityScheme{Type: "http", Scheme: "bearer"})
+	g.SecurityScheme("apiKeyAuth", SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"})
+	g.AlternativeSecurity("bearerAuth", "apiKeyAuth")
+	g.Add(Route{Method: http.MethodGet, Path: "/widgets", Security: "bearerAuth", Status: http.StatusOK})
+	g.Add(Route{Method: http.MethodGet, Path: "/gadgets", Security: "apiKeyAuth", Status: http.StatusOK})
+
+	doc := g.Document()
+	require.Equal(t, []map[string][]string{{"bearerAuth": {}}, {"apiKeyAuth": {}}}, doc.Paths["/widgets"]["get"].Security)
+	require.Equal(t, []map[string][]string{{"apiKeyAuth": {}}}, doc.Paths["/gadgets"]["get"].Security)
+	require.Equal(t, "X-API-Key", doc.Components.SecuritySchemes["apiKeyAuth"].Name)
+}
+
+func TestPath(t *testing.T) {
+	require.Equal(t, "/accounts/{id}/stream", Path("/accounts/:id/stream"))
+	require.Equal(t, "/docs/{any}", Path("/docs/*any"))
+	require.Equal(t, "/users", Path("/users"))
+}
@@ -0,0 +1,89 @@
+// Package password enforces the password policy: a minimum length, no passwords known from
+// breaches, and no reuse of the user's last few passwords.
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxLength is the longest password bcrypt can hash, in bytes.
+const MaxLength = 72
+
+var (
+	ErrTooShort = errors.New("password is too short")
+	ErrTooLong  = fmt.Errorf("password is longer than %d bytes", MaxLength)
+	ErrBreached = errors.New("password appears in a list of breached passwords")
+	ErrReused   = errors.New("password was used recently")
+)
+
+// Policy decides which passwords users may choose.
+type Policy struct {
+	// MinLength is the fewest characters a password may have.
+	MinLength int
+	// History is how many of the user's latest passwords, the current one included, a new
+	// password must differ from. Zero allows any.
+	History int
+
+	breached map[string]struct{}
+}
+
+// NewPolicy returns a policy that also rejects the passwords listed in breachedFile, one
+// per line, ignoring case. Blank lines and lines starting with # are skipped. An empty
+// breachedFile rejects none.
+func NewPolicy(minLength, history int, breachedFile string) (*Policy, error) {
+	policy := &Policy{MinLength: minLength, History: history, breached: map[string]struct{}{}}
+	if breachedFile == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(breachedFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read breached passwords: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read breached passwords: %w", err)
+	}
+	return policy, nil
+}
+
+// Check rejects a password that is too short, too long or breached.
+func (p *Policy) Check(password string) error {
+	switch {
+	case utf8.RuneCountInString(password) < p.MinLength:
+		return fmt.Errorf("%w: it must have at least %d characters", ErrTooShort, p.MinLength)
+	case len(password) > MaxLength:
+		return ErrTooLong
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return ErrBreached
+	}
+	return nil
+}
+
+// CheckReuse rejects a password that matches one of the bcrypt hashes of the user's
+// passwords, newest first. Only the first History hashes count.
+func (p *Policy) CheckReuse(password string, hashes []string) error {
+	for _, hash := range hashes[:min(len(hashes), p.History)] {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return ErrReused
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(file, []byte("# top passwords\npassword1\n\n  Qwertyuiop \n"), 0o600))
+
+	policy, err := NewPolicy(8, 3, file)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		Password string
+		Err      error
+	}{
+		{Password: "correct horse", Err: nil},
+		{Password: "ünïcödé", Err: ErrTooShort},
+		{Password: "ünïcödé!", Err: nil},
+		{Password: "short", Err: ErrTooShort},
+		{Password: strings.Repeat("a", MaxLength+1), Err: ErrTooLong},
+		{Password: "password1", Err: ErrBreached},
+		{Password: "PASSWORD1", Err: ErrBreached},
+		{Password: "qwertyuiop", Err: ErrBreached},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Password, func(t *testing.T) {
+			err := policy.Check(tc.Password)
+			if tc.Err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.Err)
+			}
+		})
+	}
+}
+
+func TestNewPolicyMissingFile(t *testing.T) {
+	_, err := NewPolicy(8, 3, filepath.Join(t.TempDir(), "missing.txt"))
+	require.Error(t, err)
+
+	policy, err := NewPolicy(8, 3, "")
+	require.NoError(t, err)
+	require.NoError(t, policy.Check("password1"))
+}
+
+func TestCheckReuse(t *testing.T) {
+	var hashes []string
+	for _, password := range []string{"newest password", "older password", "oldest password"} {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		require.NoError(t, err)
+		hashes = append(hashes, string(hash))
+	}
+
+	policy := &Policy{History: 2}
+	require.ErrorIs(t, policy.CheckReuse("newest password", hashes), ErrReused)
+	require.ErrorIs(t, policy.CheckReuse("older password", hashes), ErrReused)
+	require.NoError(t, policy.CheckReuse("oldest password", hashes))
+	require.NoError(t, policy.CheckReuse("another password", hashes))
+
+	policy.History = 0
+	require.NoError(t, policy.CheckReuse("newest password", hashes))
+}
+
+func TestResetToken(t *testing.T) {
+	token, hash := NewResetToken()
+	require.Len(t, token, 26)
+	require.Equal(t, hash, HashResetToken(token))
+
+	other, otherHash := NewResetToken()
+	require.NotEqual(t, token, other)
+	require.NotEqual(t, hash, otherHash)
+}
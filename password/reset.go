@@ -0,0 +1,21 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NewResetToken returns a random reset token for the user and the hash to store in its
+// place.
+func NewResetToken() (token, hash string) {
+	token = rand.Text()
+	return token, HashResetToken(token)
+}
+
+// HashResetToken hashes a reset token for storage. Tokens are random, so a fast hash is
+// enough.
+func HashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
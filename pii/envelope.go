@@ -0,0 +1,97 @@
+package pii
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errSealedTooShort = errors.New("sealed value is too short")
+
+// envelope seals the fields of one row with the row's own data key. A sealed field is the
+// nonce followed by the ciphertext, base64-encoded to fit the varchar column, and is bound
+// to the table, the row and the field, so that a value copied elsewhere does not open.
+type envelope struct {
+	aead cipher.AEAD
+	// dataKey is the data key wrapped, and keyID the master key that wrapped it, as stored.
+	dataKey []byte
+	keyID   string
+}
+
+// newEnvelope returns an envelope with a new data key, wrapped by the current master key.
+func newEnvelope(ctx context.Context, keys KeyProvider) (*envelope, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return envelopeFor(dataKey, keyID, wrapped)
+}
+
+// openEnvelope returns the envelope of a row from its wrapped data key.
+func openEnvelope(ctx context.Context, keys KeyProvider, keyID pgtype.Text, wrapped []byte) (*envelope, error) {
+	dataKey, err := keys.UnwrapKey(ctx, keyID.String, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return envelopeFor(dataKey, keyID.String, wrapped)
+}
+
+func envelopeFor(dataKey []byte, keyID string, wrapped []byte) (*envelope, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, dataKey: wrapped, keyID: keyID}, nil
+}
+
+func (e *envelope) keyIDText() pgtype.Text {
+	return pgtype.Text{String: e.keyID, Valid: true}
+}
+
+func (e *envelope) seal(value string, binding ...string) (string, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := e.aead.Seal(nonce, nonce, []byte(value), additionalData(binding))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (e *envelope) open(sealed string, binding ...string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	value, err := open(e.aead, raw, additionalData(binding))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errSealedTooShort
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func additionalData(binding []string) []byte {
+	return []byte(strings.Join(binding, "\x00"))
+}
+
+// emailIndex returns the blind index of an email address, which like the unique index on
+// it ignores case.
+func emailIndex(ctx context.Context, keys KeyProvider, email string) ([]byte, error) {
+	return keys.BlindIndex(ctx, []byte(strings.ToLower(email)))
+}
@@ -0,0 +1,142 @@
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// KeySize is the size of the master keys, the data keys and the blind index key: AES-256.
+const KeySize = 32
+
+// KeyProvider holds the master keys that wrap the data keys, and the key of the blind
+// index. LocalKeyProvider holds them in memory; a KMS can stand in for it, wrapping and
+// unwrapping remotely.
+type KeyProvider interface {
+	// CurrentKeyID is the ID of the master key WrapKey wraps with. Data keys wrapped by
+	// any other are re-wrapped by Rekey.
+	CurrentKeyID() string
+	// WrapKey encrypts a data key with the current master key and returns its ID.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped by the master key with keyID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// BlindIndex returns a keyed hash of value that is the same every time, to look up
+	// and compare values without decrypting them. Its key cannot be rotated without
+	// recomputing every index.
+	BlindIndex(ctx context.Context, value []byte) ([]byte, error)
+}
+
+// ErrUnknownKey is returned for a data key wrapped by a master key the provider does not hold.
+var ErrUnknownKey = errors.New("unknown master key")
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// LocalKeyProvider is a KeyProvider with its keys in memory, read from the configuration
+// or a keyfile.
+type LocalKeyProvider struct {
+	currentKeyID string
+	masterKeys   map[string]cipher.AEAD
+	indexKey     []byte
+}
+
+var _ KeyProvider = (*LocalKeyProvider)(nil)
+
+// NewLocalKeyProvider returns a LocalKeyProvider for masterKeys, a list of "id:key" pairs
+// separated by commas or newlines, and a blind index key. The keys are KeySize bytes,
+// hex-encoded. The first master key is the current one; the others are older keys, kept
+// until Rekey has moved every data key off them.
+func NewLocalKeyProvider(masterKeys, blindIndexKey string) (*LocalKeyProvider, error) {
+	provider := &LocalKeyProvider{masterKeys: make(map[string]cipher.AEAD)}
+
+	for _, entry := range strings.FieldsFunc(masterKeys, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, hexKey, ok := strings.Cut(entry, ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid master key %q: must be id:key", id)
+		}
+		if _, ok := provider.masterKeys[id]; ok {
+			return nil, fmt.Errorf("duplicate master key %q", id)
+		}
+		key, err := decodeKey(hexKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %q: %w", id, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		provider.masterKeys[id] = aead
+		if provider.currentKeyID == "" {
+			provider.currentKeyID = id
+		}
+	}
+	if provider.currentKeyID == "" {
+		return nil, errors.New("no master key")
+	}
+
+	indexKey, err := decodeKey(blindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blind index key: %w", err)
+	}
+	provider.indexKey = indexKey
+	return provider, nil
+}
+
+func decodeKey(hexKey string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: must be %d bytes", KeySize)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (provider *LocalKeyProvider) CurrentKeyID() string {
+	return provider.currentKeyID
+}
+
+// WrapKey seals the data key with AES-GCM, bound to the ID of the master key.
+func (provider *LocalKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	aead := provider.masterKeys[provider.currentKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return provider.currentKeyID, aead.Seal(nonce, nonce, dataKey, []byte(provider.currentKeyID)), nil
+}
+
+func (provider *LocalKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := provider.masterKeys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// BlindIndex is an HMAC-SHA256 of value.
+func (provider *LocalKeyProvider) BlindIndex(ctx context.Context, value []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, provider.indexKey)
+	mac.Write(value)
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,173 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CurrentKeyID is the ID of the master key Rekey moves the data keys onto.
+func (store *Store) CurrentKeyID() string {
+	return store.keys.CurrentKeyID()
+}
+
+// Rekey moves every row with personal data onto the current master key, batchSize rows at
+// a time, and returns how many it changed. A row in plaintext is encrypted with a new
+// data key; one whose data key is wrapped by an older master key has it unwrapped and
+// wrapped again, which leaves its fields as they are. A row changed while Rekey had it is
+// left for the next run. Once a run changes nothing, the older master keys can be dropped.
+func (store *Store) Rekey(ctx context.Context, batchSize int32) (int, error) {
+	users, err := store.rekeyUsers(ctx, batchSize)
+	if err != nil {
+		return users, err
+	}
+	verifyEmails, err := store.rekeyVerifyEmails(ctx, batchSize)
+	return users + verifyEmails, err
+}
+
+// IndexEmails gives every user still in plaintext the blind index of their email,
+// batchSize users at a time, and returns how many it changed. Until it has, a new user
+// could take the email of one of them, as emails are kept unique by their index; run it
+// before the store takes writes. Rekey encrypts the users later.
+func (store *Store) IndexEmails(ctx context.Context, batchSize int32) (int, error) {
+	indexed := 0
+	arg := db.ListUsersToIndexParams{LimitCount: batchSize}
+	for {
+		users, err := store.Store.ListUsersToIndex(ctx, arg)
+		if err != nil {
+			return indexed, err
+		}
+
+		for _, user := range users {
+			index, err := emailIndex(ctx, store.keys, user.Email)
+			if err != nil {
+				return indexed, err
+			}
+			rows, err := store.Store.IndexUserEmail(ctx, db.IndexUserEmailParams{
+				EmailIndex: index,
+				Username:   user.Username,
+				OldEmail:   user.Email,
+			})
+			if err != nil {
+				return indexed, fmt.Errorf("index email of user %s: %w", user.Username, err)
+			}
+			indexed += int(rows)
+		}
+
+		if len(users) < int(batchSize) {
+			return indexed, nil
+		}
+		arg.AfterUsername = users[len(users)-1].Username
+	}
+}
+
+func (store *Store) rekeyUsers(ctx context.Context, batchSize int32) (int, error) {
+	rekeyed := 0
+	arg := db.ListUsersToRekeyParams{KeyID: store.keys.CurrentKeyID(), LimitCount: batchSize}
+	for {
+		users, err := store.Store.ListUsersToRekey(ctx, arg)
+		if err != nil {
+			return rekeyed, err
+		}
+
+		for _, user := range users {
+			rekey := db.RekeyUserParams{
+				FullName:    user.FullName,
+				Email:       user.Email,
+				EmailIndex:  user.EmailIndex,
+				Username:    user.Username,
+				OldFullName: user.FullName,
+				OldEmail:    user.Email,
+				OldDataKey:  user.DataKey,
+			}
+			if user.DataKey == nil {
+				e, err := newEnvelope(ctx, store.keys)
+				if err != nil {
+					return rekeyed, err
+				}
+				rekey.FullName, rekey.Email, rekey.EmailIndex, err = store.sealUser(ctx, e, user.Username, user.FullName, user.Email)
+				if err != nil {
+					return rekeyed, fmt.Errorf("encrypt user %s: %w", user.Username, err)
+				}
+				rekey.DataKey, rekey.DataKeyID = e.dataKey, e.keyIDText()
+			} else {
+				rekey.DataKey, rekey.DataKeyID, err = store.rewrap(ctx, user.DataKeyID, user.DataKey)
+				if err != nil {
+					return rekeyed, fmt.Errorf("rewrap data key of user %s: %w", user.Username, err)
+				}
+			}
+
+			rows, err := store.Store.RekeyUser(ctx, rekey)
+			if err != nil {
+				return rekeyed, fmt.Errorf("rekey user %s: %w", user.Username, err)
+			}
+			rekeyed += int(rows)
+		}
+
+		if len(users) < int(batchSize) {
+			return rekeyed, nil
+		}
+		arg.AfterUsername = users[len(users)-1].Username
+	}
+}
+
+func (store *Store) rekeyVerifyEmails(ctx context.Context, batchSize int32) (int, error) {
+	rekeyed := 0
+	arg := db.ListVerifyEmailsToRekeyParams{KeyID: store.keys.CurrentKeyID(), LimitCount: batchSize}
+	for {
+		verifyEmails, err := store.Store.ListVerifyEmailsToRekey(ctx, arg)
+		if err != nil {
+			return rekeyed, err
+		}
+
+		for _, verifyEmail := range verifyEmails {
+			rekey := db.RekeyVerifyEmailParams{
+				Email:      verifyEmail.Email,
+				ID:         verifyEmail.ID,
+				OldEmail:   verifyEmail.Email,
+				OldDataKey: verifyEmail.DataKey,
+			}
+			if verifyEmail.DataKey == nil {
+				e, err := newEnvelope(ctx, store.keys)
+				if err != nil {
+					return rekeyed, err
+				}
+				if rekey.Email, err = e.seal(verifyEmail.Email, "verify_emails", verifyEmail.Username, "email"); err != nil {
+					return rekeyed, fmt.Errorf("encrypt verification code %d: %w", verifyEmail.ID, err)
+				}
+				rekey.DataKey, rekey.DataKeyID = e.dataKey, e.keyIDText()
+			} else {
+				rekey.DataKey, rekey.DataKeyID, err = store.rewrap(ctx, verifyEmail.DataKeyID, verifyEmail.DataKey)
+				if err != nil {
+					return rekeyed, fmt.Errorf("rewrap data key of verification code %d: %w", verifyEmail.ID, err)
+				}
+			}
+
+			rows, err := store.Store.RekeyVerifyEmail(ctx, rekey)
+			if err != nil {
+				return rekeyed, fmt.Errorf("rekey verification code %d: %w", verifyEmail.ID, err)
+			}
+			rekeyed += int(rows)
+		}
+
+		if len(verifyEmails) < int(batchSize) {
+			return rekeyed, nil
+		}
+		arg.AfterID = verifyEmails[len(verifyEmails)-1].ID
+	}
+}
+
+// rewrap wraps a data key again with the current master key.
+func (store *Store) rewrap(ctx context.Context, keyID pgtype.Text, wrapped []byte) ([]byte, pgtype.Text, error) {
+	dataKey, err := store.keys.UnwrapKey(ctx, keyID.String, wrapped)
+	if err != nil {
+		return nil, pgtype.Text{}, err
+	}
+	newKeyID, rewrapped, err := store.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, pgtype.Text{}, err
+	}
+	return rewrapped, pgtype.Text{String: newKeyID, Valid: true}, nil
+}
@@ -0,0 +1,313 @@
+// Package pii encrypts the personal data of the users at rest. Every row that holds some
+// has a data key of its own, which encrypts its fields with AES-GCM and is stored wrapped
+// by a master key of a KeyProvider. Emails also get a blind index, a keyed hash, to look
+// users up by email and keep emails unique.
+package pii
+
+import (
+	"context"
+	"errors"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// updateAttempts is how many times UpdateUser and UpdateUserTx read a user and try again
+// when Rekey changed its data key in between.
+const updateAttempts = 3
+
+// Store encrypts the full names and emails of the users, and the emails verification
+// codes were sent to, before the wrapped store writes them, and decrypts them when it
+// reads them, so that the callers only ever see plaintext. Rows still in plaintext, from
+// before encryption was turned on, are read as they are until Rekey encrypts them.
+type Store struct {
+	db.Store
+	keys KeyProvider
+}
+
+func NewStore(store db.Store, keys KeyProvider) *Store {
+	return &Store{Store: store, keys: keys}
+}
+
+// sealUser encrypts the full name and email of username with e and returns them with the
+// blind index of the email.
+func (store *Store) sealUser(ctx context.Context, e *envelope, username, fullName, email string) (string, string, []byte, error) {
+	sealedName, err := e.seal(fullName, "users", username, "full_name")
+	if err != nil {
+		return "", "", nil, err
+	}
+	sealedEmail, err := e.seal(email, "users", username, "email")
+	if err != nil {
+		return "", "", nil, err
+	}
+	index, err := emailIndex(ctx, store.keys, email)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return sealedName, sealedEmail, index, nil
+}
+
+// openUser decrypts the full name and email of a user read with err.
+func (store *Store) openUser(ctx context.Context, user db.User, err error) (db.User, error) {
+	if err != nil || user.DataKey == nil {
+		return user, err
+	}
+	e, err := openEnvelope(ctx, store.keys, user.DataKeyID, user.DataKey)
+	if err != nil {
+		return db.User{}, err
+	}
+	if user.FullName, err = e.open(user.FullName, "users", user.Username, "full_name"); err != nil {
+		return db.User{}, err
+	}
+	if user.Email, err = e.open(user.Email, "users", user.Username, "email"); err != nil {
+		return db.User{}, err
+	}
+	return user, nil
+}
+
+// openVerifyEmail decrypts the email of a verification code read with err.
+func (store *Store) openVerifyEmail(ctx context.Context, verifyEmail db.VerifyEmail, err error) (db.VerifyEmail, error) {
+	if err != nil || verifyEmail.DataKey == nil {
+		return verifyEmail, err
+	}
+	e, err := openEnvelope(ctx, store.keys, verifyEmail.DataKeyID, verifyEmail.DataKey)
+	if err != nil {
+		return db.VerifyEmail{}, err
+	}
+	if verifyEmail.Email, err = e.open(verifyEmail.Email, "verify_emails", verifyEmail.Username, "email"); err != nil {
+		return db.VerifyEmail{}, err
+	}
+	return verifyEmail, nil
+}
+
+func (store *Store) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	arg, err := store.sealCreate(ctx, arg)
+	if err != nil {
+		return db.User{}, err
+	}
+	user, err := store.Store.CreateUser(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) CreateUserTx(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	arg, err := store.sealCreate(ctx, arg)
+	if err != nil {
+		return db.User{}, err
+	}
+	user, err := store.Store.CreateUserTx(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+// sealCreate encrypts the full name and email of a new user with a data key of its own.
+func (store *Store) sealCreate(ctx context.Context, arg db.CreateUserParams) (db.CreateUserParams, error) {
+	e, err := newEnvelope(ctx, store.keys)
+	if err != nil {
+		return arg, err
+	}
+	arg.FullName, arg.Email, arg.EmailIndex, err = store.sealUser(ctx, e, arg.Username, arg.FullName, arg.Email)
+	if err != nil {
+		return arg, err
+	}
+	arg.DataKey, arg.DataKeyID = e.dataKey, e.keyIDText()
+	return arg, nil
+}
+
+func (store *Store) GetUser(ctx context.Context, username string) (db.User, error) {
+	user, err := store.Store.GetUser(ctx, username)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) GetUserByEmail(ctx context.Context, arg db.GetUserByEmailParams) (db.User, error) {
+	index, err := emailIndex(ctx, store.keys, arg.Email)
+	if err != nil {
+		return db.User{}, err
+	}
+	arg.EmailIndex = index
+
+	user, err := store.Store.GetUserByEmail(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+// UpdateUser encrypts the new full name or email with the user's data key. A user still in
+// plaintext gets a data key, and has both encrypted.
+func (store *Store) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	return store.updateUser(ctx, arg, store.Store.UpdateUser)
+}
+
+// UpdateUserTx encrypts like UpdateUser.
+func (store *Store) UpdateUserTx(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	return store.updateUser(ctx, arg, store.Store.UpdateUserTx)
+}
+
+// updateUser seals arg and updates the user with update, again if Rekey changed their
+// data key in between.
+func (store *Store) updateUser(ctx context.Context, arg db.UpdateUserParams, update func(context.Context, db.UpdateUserParams) (db.User, error)) (db.User, error) {
+	for attempt := 1; ; attempt++ {
+		user, err := store.Store.GetUser(ctx, arg.Username)
+		if err != nil {
+			return db.User{}, err
+		}
+		sealed, err := store.sealUpdate(ctx, user, arg)
+		if err != nil {
+			return db.User{}, err
+		}
+
+		updated, err := update(ctx, sealed)
+		if errors.Is(err, pgx.ErrNoRows) && attempt < updateAttempts {
+			continue
+		}
+		return store.openUser(ctx, updated, err)
+	}
+}
+
+func (store *Store) sealUpdate(ctx context.Context, user db.User, arg db.UpdateUserParams) (db.UpdateUserParams, error) {
+	if user.DataKey == nil {
+		e, err := newEnvelope(ctx, store.keys)
+		if err != nil {
+			return arg, err
+		}
+		fullName, email := user.FullName, user.Email
+		if arg.FullName.Valid {
+			fullName = arg.FullName.String
+		}
+		if arg.Email.Valid {
+			email = arg.Email.String
+		}
+		sealedName, sealedEmail, index, err := store.sealUser(ctx, e, user.Username, fullName, email)
+		if err != nil {
+			return arg, err
+		}
+		arg.FullName = pgtype.Text{String: sealedName, Valid: true}
+		arg.Email = pgtype.Text{String: sealedEmail, Valid: true}
+		arg.EmailIndex, arg.DataKey, arg.DataKeyID, arg.PreviousDataKey = index, e.dataKey, e.keyIDText(), nil
+		return arg, nil
+	}
+
+	e, err := openEnvelope(ctx, store.keys, user.DataKeyID, user.DataKey)
+	if err != nil {
+		return arg, err
+	}
+	if arg.FullName.Valid {
+		if arg.FullName.String, err = e.seal(arg.FullName.String, "users", user.Username, "full_name"); err != nil {
+			return arg, err
+		}
+	}
+	if arg.Email.Valid {
+		if arg.EmailIndex, err = emailIndex(ctx, store.keys, arg.Email.String); err != nil {
+			return arg, err
+		}
+		if arg.Email.String, err = e.seal(arg.Email.String, "users", user.Username, "email"); err != nil {
+			return arg, err
+		}
+	}
+	arg.PreviousDataKey = user.DataKey
+	return arg, nil
+}
+
+func (store *Store) UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	user, err := store.Store.UpdateUserRole(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	user, err := store.Store.UpdateUserPassword(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) SetUserTOTPSecret(ctx context.Context, arg db.SetUserTOTPSecretParams) (db.User, error) {
+	user, err := store.Store.SetUserTOTPSecret(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) EnableUserTOTP(ctx context.Context, arg db.EnableUserTOTPParams) (db.User, error) {
+	user, err := store.Store.EnableUserTOTP(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) SetUserEmailVerified(ctx context.Context, arg db.SetUserEmailVerifiedParams) (db.User, error) {
+	index, err := emailIndex(ctx, store.keys, arg.Email)
+	if err != nil {
+		return db.User{}, err
+	}
+	arg.EmailIndex = index
+
+	user, err := store.Store.SetUserEmailVerified(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) ListUsersToRekey(ctx context.Context, arg db.ListUsersToRekeyParams) ([]db.User, error) {
+	users, err := store.Store.ListUsersToRekey(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i], err = store.openUser(ctx, users[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func (store *Store) EnableTOTPTx(ctx context.Context, arg db.EnableTOTPTxParams) (db.User, error) {
+	user, err := store.Store.EnableTOTPTx(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) ChangePasswordTx(ctx context.Context, arg db.ChangePasswordTxParams) (db.User, error) {
+	user, err := store.Store.ChangePasswordTx(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) VerifyEmailTx(ctx context.Context, arg db.VerifyEmailTxParams) (db.User, error) {
+	index, err := emailIndex(ctx, store.keys, arg.Email)
+	if err != nil {
+		return db.User{}, err
+	}
+	arg.EmailIndex = index
+
+	user, err := store.Store.VerifyEmailTx(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) UpdateUserRoleTx(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	user, err := store.Store.UpdateUserRoleTx(ctx, arg)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) UnlockLoginTx(ctx context.Context, username string) (db.User, error) {
+	user, err := store.Store.UnlockLoginTx(ctx, username)
+	return store.openUser(ctx, user, err)
+}
+
+func (store *Store) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+	e, err := newEnvelope(ctx, store.keys)
+	if err != nil {
+		return db.VerifyEmail{}, err
+	}
+	if arg.Email, err = e.seal(arg.Email, "verify_emails", arg.Username, "email"); err != nil {
+		return db.VerifyEmail{}, err
+	}
+	arg.DataKey, arg.DataKeyID = e.dataKey, e.keyIDText()
+
+	verifyEmail, err := store.Store.CreateVerifyEmail(ctx, arg)
+	return store.openVerifyEmail(ctx, verifyEmail, err)
+}
+
+func (store *Store) GetVerifyEmail(ctx context.Context, id int64) (db.VerifyEmail, error) {
+	verifyEmail, err := store.Store.GetVerifyEmail(ctx, id)
+	return store.openVerifyEmail(ctx, verifyEmail, err)
+}
+
+func (store *Store) ListVerifyEmailsToRekey(ctx context.Context, arg db.ListVerifyEmailsToRekeyParams) ([]db.VerifyEmail, error) {
+	verifyEmails, err := store.Store.ListVerifyEmailsToRekey(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range verifyEmails {
+		if verifyEmails[i], err = store.openVerifyEmail(ctx, verifyEmails[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return verifyEmails, nil
+}
@@ -0,0 +1,221 @@
+package pii
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/db/memstore"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKey1     = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testKey2     = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+	testIndexKey = "202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f"
+)
+
+func newTestKeys(t *testing.T, masterKeys string) *LocalKeyProvider {
+	keys, err := NewLocalKeyProvider(masterKeys, testIndexKey)
+	require.NoError(t, err)
+	return keys
+}
+
+func TestNewLocalKeyProvider(t *testing.T) {
+	keys, err := NewLocalKeyProvider(" v2:"+testKey2+"\nv1:"+testKey1+"\n", testIndexKey)
+	require.NoError(t, err)
+	require.Equal(t, "v2", keys.CurrentKeyID())
+
+	for _, masterKeys := range []string{"", "v1", "v1:abc", "v1:" + testKey1 + ",v1:" + testKey2, "v 1:" + testKey1} {
+		_, err := NewLocalKeyProvider(masterKeys, testIndexKey)
+		require.Error(t, err, masterKeys)
+	}
+	_, err = NewLocalKeyProvider("v1:"+testKey1, "")
+	require.Error(t, err)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	raw := memstore.New()
+	store := NewStore(raw, newTestKeys(t, "v1:"+testKey1))
+
+	user, err := store.CreateUser(ctx, db.CreateUserParams{
+		Username: "alice", FullName: "Alice Liddell", Email: "Alice@example.com", PasswordHash: "hash",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Alice Liddell", user.FullName)
+	require.Equal(t, "Alice@example.com", user.Email)
+
+	// What is stored is ciphertext.
+	stored, err := raw.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	require.NotContains(t, stored.FullName, "Alice")
+	require.NotContains(t, stored.Email, "example.com")
+	require.Equal(t, pgtype.Text{String: "v1", Valid: true}, stored.DataKeyID)
+
+	got, err := store.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, user, got)
+
+	// The email is found, and kept unique, in any case.
+	found, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: "ALICE@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "alice", found.Username)
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username: "bob", FullName: "Bob", Email: "alice@EXAMPLE.com", PasswordHash: "hash",
+	})
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, "users_email_index_key", pgErr.ConstraintName)
+
+	// Changing one field keeps the other, and the data key.
+	updated, err := store.UpdateUser(ctx, db.UpdateUserParams{Username: "alice", FullName: pgtype.Text{String: "Alice L.", Valid: true}})
+	require.NoError(t, err)
+	require.Equal(t, "Alice L.", updated.FullName)
+	require.Equal(t, "Alice@example.com", updated.Email)
+	require.Equal(t, stored.DataKey, updated.DataKey)
+
+	updated, err = store.UpdateUserTx(ctx, db.UpdateUserParams{
+		Username: "alice", Email: pgtype.Text{String: "alice@example.org", Valid: true}, EmailChanged: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Alice L.", updated.FullName)
+	require.Equal(t, "alice@example.org", updated.Email)
+	_, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: "alice@example.com"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// A verification code holds the address it was sent to encrypted, and verifies it.
+	verification, err := store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username: "alice", Email: updated.Email, ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.org", verification.Email)
+	storedVerification, err := raw.GetVerifyEmail(ctx, verification.ID)
+	require.NoError(t, err)
+	require.NotContains(t, storedVerification.Email, "example.org")
+
+	verification, err = store.GetVerifyEmail(ctx, verification.ID)
+	require.NoError(t, err)
+	verified, err := store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{ID: verification.ID, Username: "alice", Email: verification.Email})
+	require.NoError(t, err)
+	require.True(t, verified.EmailVerifiedAt.Valid)
+	require.Equal(t, "alice@example.org", verified.Email)
+	unlocked, err := store.UnlockLoginTx(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.org", unlocked.Email)
+
+	// A field moved to another user's row does not decrypt.
+	_, err = store.CreateUserTx(ctx, db.CreateUserParams{Username: "carol", FullName: "Carol", Email: "carol@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	carol, err := raw.GetUser(ctx, "carol")
+	require.NoError(t, err)
+	require.NotContains(t, carol.Email, "example.com")
+	_, err = raw.RekeyUser(ctx, db.RekeyUserParams{
+		FullName: stored.FullName, Email: carol.Email, EmailIndex: carol.EmailIndex, DataKey: stored.DataKey, DataKeyID: stored.DataKeyID,
+		Username: "carol", OldFullName: carol.FullName, OldEmail: carol.Email, OldDataKey: carol.DataKey,
+	})
+	require.NoError(t, err)
+	_, err = store.GetUser(ctx, "carol")
+	require.Error(t, err)
+}
+
+func TestRekey(t *testing.T) {
+	ctx := context.Background()
+	raw := memstore.New()
+
+	// A user from before encryption was turned on is read as it is.
+	_, err := raw.CreateUser(ctx, db.CreateUserParams{Username: "alice", FullName: "Alice", Email: "alice@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	_, err = raw.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username: "alice", Email: "alice@example.com", ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+
+	store := NewStore(raw, newTestKeys(t, "v1:"+testKey1))
+	user, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: "Alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "Alice", user.FullName)
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{Username: "bob", FullName: "Bob", Email: "bob@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	// Rekey encrypts alice, her verification code and the bank's house user, and leaves bob alone.
+	rekeyed, err := store.Rekey(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, rekeyed)
+	rekeyed, err = store.Rekey(ctx, 1)
+	require.NoError(t, err)
+	require.Zero(t, rekeyed)
+
+	stored, err := raw.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	require.False(t, strings.Contains(stored.Email, "alice"))
+	user, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: "alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "Alice", user.FullName)
+
+	// A new master key takes over, and Rekey moves every data key onto it.
+	rotated := NewStore(raw, newTestKeys(t, "v2:"+testKey2+",v1:"+testKey1))
+	rekeyed, err = rotated.Rekey(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 4, rekeyed)
+
+	restored, err := raw.GetUser(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, pgtype.Text{String: "v2", Valid: true}, restored.DataKeyID)
+	require.Equal(t, stored.Email, restored.Email, "the fields are left as they are")
+
+	// v1 can then be dropped.
+	retired := NewStore(raw, newTestKeys(t, "v2:"+testKey2))
+	for _, username := range []string{"alice", "bob", "bank"} {
+		_, err := retired.GetUser(ctx, username)
+		require.NoError(t, err)
+	}
+	_, err = store.GetUser(ctx, "alice")
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestIndexEmails(t *testing.T) {
+	ctx := context.Background()
+	raw := memstore.New()
+
+	_, err := raw.CreateUser(ctx, db.CreateUserParams{Username: "alice", FullName: "Alice", Email: "alice@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	store := NewStore(raw, newTestKeys(t, "v1:"+testKey1))
+	indexed, err := store.IndexEmails(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, indexed, "alice and the bank's house user")
+	indexed, err = store.IndexEmails(ctx, 1)
+	require.NoError(t, err)
+	require.Zero(t, indexed)
+
+	// A new, encrypted, user cannot take the email of one still in plaintext.
+	_, err = store.CreateUser(ctx, db.CreateUserParams{Username: "mallory", FullName: "Mallory", Email: "ALICE@example.com", PasswordHash: "hash"})
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, "users_email_index_key", pgErr.ConstraintName)
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{Username: "bob", FullName: "Bob", Email: "bob@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{
+		Username: "bob", Email: pgtype.Text{String: "Alice@Example.com", Valid: true}, EmailChanged: true,
+	})
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, "users_email_index_key", pgErr.ConstraintName)
+
+	// Alice is still in plaintext, found by her email, and Rekey encrypts her as before.
+	user, err := store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: "alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "alice", user.Username)
+	_, err = store.Rekey(ctx, 10)
+	require.NoError(t, err)
+	user, err = store.GetUserByEmail(ctx, db.GetUserByEmailParams{Email: "alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "Alice", user.FullName)
+}
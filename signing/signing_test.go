@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"from_account_id":1,"to_account_id":2,"amount":10,"currency":"USD"}`)
+	nonce := NewNonce()
+	header := Sign("secret", http.MethodPost, "/v2/transfers", now, nonce, body)
+
+	signature, err := Verify("secret", header, http.MethodPost, "/v2/transfers", body, time.Minute, now)
+	require.NoError(t, err)
+	require.Equal(t, nonce, signature.Nonce)
+	require.Equal(t, now.Unix(), signature.Timestamp.Unix())
+
+	verify := func(secret, header, method, path string, body []byte, now time.Time) error {
+		_, err := Verify(secret, header, method, path, body, time.Minute, now)
+		return err
+	}
+	require.ErrorIs(t, verify("secret", "", http.MethodPost, "/v2/transfers", body, now), ErrMissingSignature)
+	require.ErrorIs(t, verify("other-secret", header, http.MethodPost, "/v2/transfers", body, now), ErrInvalidSignature)
+	require.ErrorIs(t, verify("secret", header, http.MethodPost, "/v2/transfers",
+		[]byte(strings.Replace(string(body), `"amount":10`, `"amount":1000`, 1)), now), ErrInvalidSignature, "tampered body")
+	require.ErrorIs(t, verify("secret", header, http.MethodPut, "/v2/transfers", body, now), ErrInvalidSignature)
+	require.ErrorIs(t, verify("secret", header, http.MethodPost, "/v2/transfers?x=1", body, now), ErrInvalidSignature)
+	require.ErrorIs(t, verify("secret", header, http.MethodPost, "/v2/transfers", body, now.Add(2*time.Minute)), ErrStaleSignature)
+	require.ErrorIs(t, verify("secret", header, http.MethodPost, "/v2/transfers", body, now.Add(-2*time.Minute)), ErrStaleSignature)
+
+	tampered := strings.Replace(header, "nonce="+nonce, "nonce="+NewNonce(), 1)
+	require.ErrorIs(t, verify("secret", tampered, http.MethodPost, "/v2/transfers", body, now), ErrInvalidSignature)
+	short := Sign("secret", http.MethodPost, "/v2/transfers", now, "abc", body)
+	require.ErrorIs(t, verify("secret", short, http.MethodPost, "/v2/transfers", body, now), ErrInvalidSignature)
+}
+
+func TestSignRequest(t *testing.T) {
+	body := `{"amount":10}`
+	req, err := http.NewRequest(http.MethodPost, "https://bank.example/v2/transfers?dry_run=1", strings.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, SignRequest(req, "secret"))
+
+	// The body can still be sent.
+	sent, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, string(sent))
+
+	_, err = Verify("secret", req.Header.Get(Header), http.MethodPost, "/v2/transfers?dry_run=1", sent, time.Minute, time.Now())
+	require.NoError(t, err)
+
+	get, err := http.NewRequest(http.MethodGet, "https://bank.example/v2/accounts", nil)
+	require.NoError(t, err)
+	require.NoError(t, SignRequest(get, "secret"))
+	_, err = Verify("secret", get.Header.Get(Header), http.MethodGet, "/v2/accounts", nil, time.Minute, time.Now())
+	require.NoError(t, err)
+}
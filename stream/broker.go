@@ -0,0 +1,71 @@
+package stream
+
+import "sync"
+
+// Broker fans account change signals out to the streams watching each account.
+// A signal only says that something changed; subscribers read the new entries themselves,
+// so signals can be coalesced and a slow subscriber never blocks the others.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan struct{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[int64]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a signal after each change to the account,
+// and a function that must be called to stop receiving them.
+func (broker *Broker) Subscribe(accountID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	broker.mu.Lock()
+	if broker.subscribers[accountID] == nil {
+		broker.subscribers[accountID] = make(map[chan struct{}]struct{})
+	}
+	broker.subscribers[accountID][ch] = struct{}{}
+	broker.mu.Unlock()
+
+	unsubscribe := func() {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		delete(broker.subscribers[accountID], ch)
+		if len(broker.subscribers[accountID]) == 0 {
+			delete(broker.subscribers, accountID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish signals every subscriber of the account.
+func (broker *Broker) Publish(accountID int64) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for ch := range broker.subscribers[accountID] {
+		signal(ch)
+	}
+}
+
+// PublishAll signals every subscriber, for when notifications may have been missed.
+func (broker *Broker) PublishAll() {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for _, subscribers := range broker.subscribers {
+		for ch := range subscribers {
+			signal(ch)
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,61 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Listen holds a connection that LISTENs on the account events channel and publishes every
+// notification to the broker, so that streams on every replica hear about commits made on any of them.
+// It reconnects after failures and returns when ctx is cancelled.
+func Listen(ctx context.Context, pool *pgxpool.Pool, broker *Broker) {
+	for {
+		err := listen(ctx, pool, broker)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.ErrorContext(ctx, "account events listener failed", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func listen(ctx context.Context, pool *pgxpool.Pool, broker *Broker) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+db.AccountEventsChannel); err != nil {
+		return err
+	}
+
+	// Anything committed while we were not listening would otherwise go unnoticed.
+	broker.PublishAll()
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			// The connection may still be subscribed; do not hand it back to the pool.
+			conn.Hijack().Close(context.Background())
+			return err
+		}
+
+		var event db.AccountEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			slog.WarnContext(ctx, "account events listener: bad payload", "payload", notification.Payload, "error", err)
+			continue
+		}
+		broker.Publish(event.AccountID)
+	}
+}
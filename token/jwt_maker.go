@@ -0,0 +1,74 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minSecretKeySize = 32
+
+// JWTMaker creates HS256 signed JSON web tokens.
+type JWTMaker struct {
+	secretKey string
+}
+
+func NewJWTMaker(secretKey string) (Maker, error) {
+	if len(secretKey) < minSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+	return &JWTMaker{secretKey: secretKey}, nil
+}
+
+func (maker *JWTMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return maker.sign(payload)
+}
+
+func (maker *JWTMaker) CreatePurposeToken(username string, purpose string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, "", duration)
+	if err != nil {
+		return "", nil, err
+	}
+	payload.Purpose = purpose
+	return maker.sign(payload)
+}
+
+func (maker *JWTMaker) sign(payload *Payload) (string, *Payload, error) {
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	token, err := jwtToken.SignedString([]byte(maker.secretKey))
+	return token, payload, err
+}
+
+func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
+	return maker.VerifyPurposeToken(token, "")
+}
+
+func (maker *JWTMaker) VerifyPurposeToken(token string, purpose string) (*Payload, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return []byte(maker.secretKey), nil
+	}
+
+	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	payload, ok := jwtToken.Claims.(*Payload)
+	if !ok || payload.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
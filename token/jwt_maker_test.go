@@ -0,0 +1,81 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"example.com/db/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTMaker(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	role := "teller"
+	duration := time.Minute
+
+	token, payload, err := maker.CreateToken(username, role, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	verified, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+	require.Equal(t, username, verified.Username)
+	require.Equal(t, role, verified.Role)
+	require.WithinDuration(t, payload.ExpiredAt, verified.ExpiredAt, time.Second)
+}
+
+func TestExpiredJWTToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(util.RandomOwner(), "customer", -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.ErrorIs(t, err, ErrExpiredToken)
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTTokenAlgNone(t *testing.T) {
+	payload, err := NewPayload(util.RandomOwner(), "customer", time.Minute)
+	require.NoError(t, err)
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodNone, payload)
+	token, err := jwtToken.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Nil(t, payload)
+}
+
+func TestJWTPurposeToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	token, payload, err := maker.CreatePurposeToken(username, PurposeTOTPChallenge, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, payload.Role)
+
+	verified, err := maker.VerifyPurposeToken(token, PurposeTOTPChallenge)
+	require.NoError(t, err)
+	require.Equal(t, username, verified.Username)
+
+	// A token made for a purpose is not an access token, and the other way around.
+	_, err = maker.VerifyToken(token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	accessToken, _, err := maker.CreateToken(username, "customer", time.Minute)
+	require.NoError(t, err)
+	_, err = maker.VerifyPurposeToken(accessToken, PurposeTOTPChallenge)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,14 @@
+package token
+
+import "time"
+
+// Maker creates and verifies access tokens.
+type Maker interface {
+	CreateToken(username string, role string, duration time.Duration) (string, *Payload, error)
+	// VerifyToken verifies an access token and rejects tokens made for a purpose.
+	VerifyToken(token string) (*Payload, error)
+	// CreatePurposeToken creates a token that is only good for purpose.
+	CreatePurposeToken(username string, purpose string, duration time.Duration) (string, *Payload, error)
+	// VerifyPurposeToken verifies a token made for purpose.
+	VerifyPurposeToken(token string, purpose string) (*Payload, error)
+}
@@ -0,0 +1,37 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// BackupCodeCount is how many backup codes a user gets when they enroll.
+const BackupCodeCount = 10
+
+// BackupCodes returns BackupCodeCount random codes such as "k7dm-x3qa", of 40 bits each.
+// Each can be used once in place of a TOTP code to log in, for when the authenticator
+// is lost.
+func BackupCodes() []string {
+	codes := make([]string, BackupCodeCount)
+	for i := range codes {
+		code := strings.ToLower(rand.Text()[:8])
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+	return codes
+}
+
+// HashBackupCode returns the hash a backup code is stored as. Codes are compared without
+// case, spaces or dashes, the way people type them back.
+func HashBackupCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
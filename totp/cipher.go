@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of the key that seals secrets: AES-256.
+const KeySize = 32
+
+var errSealedTooShort = errors.New("sealed secret is too short")
+
+// Cipher seals secrets with AES-GCM for storage. A sealed secret is the nonce followed by
+// the ciphertext, and is bound to the user it belongs to, so that a secret copied to
+// another user's row does not open.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a Cipher for a hex-encoded KeySize-byte key.
+func NewCipher(hexKey string) (*Cipher, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: must be %d bytes", KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts the secret of username.
+func (c *Cipher) Seal(username, secret string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, []byte(secret), []byte(username)), nil
+}
+
+// Open decrypts a secret sealed for username.
+func (c *Cipher) Open(username string, sealed []byte) (string, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errSealedTooShort
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, []byte(username))
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
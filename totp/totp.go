@@ -0,0 +1,89 @@
+// Package totp implements two-factor authentication with time-based one-time passwords
+// (RFC 6238): the 6-digit, 30-second, HMAC-SHA1 codes of authenticator apps. It enrolls
+// secrets, checks codes, seals secrets for storage and makes one-time backup codes.
+package totp
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"image/png"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// Period is how long a code is valid.
+	Period = 30 * time.Second
+	// Skew is how many periods before or after the current one a code may come from, to
+	// allow for clock drift and for the time it takes to type the code.
+	Skew = 1
+
+	qrCodeSize = 256
+)
+
+var validateOpts = hotp.ValidateOpts{Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// Enrollment is a new secret and the two ways to hand it to an authenticator app.
+type Enrollment struct {
+	// Secret is the base32 secret. Store it sealed; it is shown to the user only once.
+	Secret string
+	// URI is the otpauth:// provisioning URI.
+	URI string
+	// QRCodePNG is URI as a QR code.
+	QRCodePNG []byte
+}
+
+// Enroll creates a random secret for the account accountName at issuer.
+func Enroll(issuer, accountName string) (Enrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      uint(Period / time.Second),
+		Digits:      validateOpts.Digits,
+		Algorithm:   validateOpts.Algorithm,
+	})
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	image, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		return Enrollment{}, err
+	}
+
+	return Enrollment{Secret: key.Secret(), URI: key.URL(), QRCodePNG: qrCode.Bytes()}, nil
+}
+
+// Step returns the time step t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code of secret for a time step.
+func Code(secret string, step int64) (string, error) {
+	return hotp.GenerateCodeCustom(secret, uint64(step), validateOpts)
+}
+
+// Validate checks code against the steps around now and returns the step it matched. The
+// caller must remember the step and refuse codes from it and earlier steps, so that a code
+// that has been seen cannot be used again.
+func Validate(secret, code string, now time.Time) (int64, bool) {
+	current := Step(now)
+	for step := current - Skew; step <= current+Skew; step++ {
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
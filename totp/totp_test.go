@@ -0,0 +1,125 @@
+package totp
+
+import (
+	"bytes"
+	"image/png"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; ours are their last 6 digits.
+	vectors := []struct {
+		Time int64
+		Code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, v := range vectors {
+		code, err := Code(rfcSecret, Step(time.Unix(v.Time, 0)))
+		require.NoError(t, err)
+		require.Equal(t, v.Code, code, "time %d", v.Time)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := Code(rfcSecret, step+offset)
+		require.NoError(t, err)
+
+		matched, ok := Validate(rfcSecret, code, now)
+		require.True(t, ok, "offset %d", offset)
+		require.Equal(t, step+offset, matched)
+	}
+
+	for _, offset := range []int64{-2, 2} {
+		code, err := Code(rfcSecret, step+offset)
+		require.NoError(t, err)
+
+		_, ok := Validate(rfcSecret, code, now)
+		require.False(t, ok, "offset %d", offset)
+	}
+
+	_, ok := Validate(rfcSecret, "12345", now)
+	require.False(t, ok)
+}
+
+func TestEnroll(t *testing.T) {
+	enrollment, err := Enroll("Simple Bank", "alice")
+	require.NoError(t, err)
+	require.NotEmpty(t, enrollment.Secret)
+
+	uri, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+	require.Equal(t, "Simple Bank", uri.Query().Get("issuer"))
+
+	image, err := png.Decode(bytes.NewReader(enrollment.QRCodePNG))
+	require.NoError(t, err)
+	require.Equal(t, qrCodeSize, image.Bounds().Dx())
+
+	code, err := Code(enrollment.Secret, Step(time.Now()))
+	require.NoError(t, err)
+	_, ok := Validate(enrollment.Secret, code, time.Now())
+	require.True(t, ok)
+}
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	require.NoError(t, err)
+
+	sealed, err := c.Seal("alice", rfcSecret)
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), rfcSecret)
+
+	secret, err := c.Open("alice", sealed)
+	require.NoError(t, err)
+	require.Equal(t, rfcSecret, secret)
+
+	_, err = c.Open("bob", sealed)
+	require.Error(t, err, "a secret only opens for its user")
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, err = c.Open("alice", tampered)
+	require.Error(t, err)
+
+	_, err = c.Open("alice", sealed[:4])
+	require.Error(t, err)
+
+	_, err = NewCipher("not hex")
+	require.Error(t, err)
+	_, err = NewCipher("0001")
+	require.Error(t, err)
+}
+
+func TestBackupCodes(t *testing.T) {
+	codes := BackupCodes()
+	require.Len(t, codes, BackupCodeCount)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}$`, code)
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+
+	require.Equal(t, HashBackupCode("abcd-efgh"), HashBackupCode(" ABCD EFGH"))
+	require.NotEqual(t, HashBackupCode("abcd-efgh"), HashBackupCode("abcd-efgi"))
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "example.com/tracing"
+
+// Attributes of query spans, named after the OpenTelemetry database conventions.
+const (
+	attrDBSystem     = attribute.Key("db.system.name")
+	attrDBOperation  = attribute.Key("db.operation.name")
+	attrDBQueryText  = attribute.Key("db.query.text")
+	attrDBRows       = attribute.Key("db.response.returned_rows")
+	attrDBStatusCode = attribute.Key("db.response.status_code")
+)
+
+// sqlcName matches the "-- name: GetAccount :one" comment sqlc puts in front of every query.
+var sqlcName = regexp.MustCompile(`^\s*--\s*name:\s*(\w+)`)
+
+// QueryTracer is a pgx.QueryTracer that records one span per query, named after its sqlc
+// query. Queries only get a span inside an existing trace, so that polling loops outside
+// a request do not fill the exporter with root spans.
+type QueryTracer struct {
+	tracer trace.Tracer
+}
+
+func NewQueryTracer() *QueryTracer {
+	return &QueryTracer{tracer: otel.Tracer(instrumentation)}
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	name := QueryName(data.SQL)
+	ctx, _ = t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrDBSystem.String("postgresql"),
+			attrDBOperation.String(name),
+			attrDBQueryText.String(data.SQL),
+		),
+	)
+	return ctx
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	if data.Err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(data.Err, &pgErr) {
+			span.SetAttributes(attrDBStatusCode.String(pgErr.Code))
+		}
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		return
+	}
+	span.SetAttributes(attrDBRows.Int64(data.CommandTag.RowsAffected()))
+}
+
+// QueryName returns the sqlc name of a query, or its first keyword for statements that
+// pgx issues itself, such as BEGIN and COMMIT.
+func QueryName(sql string) string {
+	if match := sqlcName.FindStringSubmatch(sql); match != nil {
+		return match[1]
+	}
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "query"
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newRecorder installs a tracer provider that keeps finished spans in memory.
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestQueryName(t *testing.T) {
+	require.Equal(t, "GetAccount", QueryName("-- name: GetAccount :one\nSELECT * FROM accounts WHERE id = $1"))
+	require.Equal(t, "BEGIN", QueryName("begin isolation level read committed"))
+	require.Equal(t, "LISTEN", QueryName("\n  listen account_changes"))
+	require.Equal(t, "query", QueryName(""))
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := newRecorder(t)
+	queryTracer := NewQueryTracer()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	queryCtx := queryTracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "-- name: ListAccounts :many\nSELECT 1"})
+	queryTracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+
+	failedCtx := queryTracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "-- name: CreateUser :one\nINSERT"})
+	queryTracer.TraceQueryEnd(failedCtx, nil, pgx.TraceQueryEndData{Err: &pgconn.PgError{Code: "23505"}})
+
+	// Without a trace in the context, the query gets no span.
+	untraced := queryTracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	queryTracer.TraceQueryEnd(untraced, nil, pgx.TraceQueryEndData{Err: errors.New("ignored")})
+
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	list := spans[0]
+	require.Equal(t, "ListAccounts", list.Name())
+	require.Equal(t, parent.SpanContext().SpanID(), list.Parent().SpanID())
+	require.Contains(t, list.Attributes(), attrDBRows.Int64(3))
+	require.Contains(t, list.Attributes(), attribute.String("db.system.name", "postgresql"))
+
+	create := spans[1]
+	require.Equal(t, "CreateUser", create.Name())
+	require.Equal(t, codes.Error, create.Status().Code)
+	require.Contains(t, create.Attributes(), attrDBStatusCode.String("23505"))
+
+	require.Equal(t, "request", spans[2].Name())
+}
@@ -0,0 +1,98 @@
+// Package tracing configures OpenTelemetry for the service and traces the SQL queries
+// pgx runs. The HTTP middleware lives in the api package and the transaction spans in
+// the db package; both only use the global otel API, which is a no-op until Setup runs.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const serviceName = "simplebank"
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+//
+// exporter is one of ExporterNone (or ""), ExporterOTLP, ExporterStdout or ExporterFile.
+// For OTLP, target is the collector's gRPC endpoint URL, e.g. "http://localhost:4317"
+// (an empty target falls back to the OTEL_EXPORTER_OTLP_* environment variables); for
+// the file exporter, target is the path spans are appended to as JSON. The returned
+// function flushes pending spans and must be called before the process exits.
+func Setup(ctx context.Context, exporter, target string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	var option sdktrace.TracerProviderOption
+	var closer io.Closer
+
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var options []otlptracegrpc.Option
+		if target != "" {
+			options = append(options, otlptracegrpc.WithEndpointURL(target))
+		}
+		spanExporter, err := otlptracegrpc.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create OTLP exporter: %w", err)
+		}
+		option = sdktrace.WithBatcher(spanExporter)
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		option = sdktrace.WithSyncer(spanExporter)
+	case ExporterFile:
+		if target == "" {
+			return nil, errors.New("the file trace exporter needs a file path")
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open trace file: %w", err)
+		}
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		option = sdktrace.WithSyncer(spanExporter)
+		closer = file
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(option, sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
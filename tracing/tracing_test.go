@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), ExporterFile, path)
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "checked locally")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `"Name":"checked locally"`)
+	require.Contains(t, string(contents), `"Value":"simplebank"`)
+}
+
+func TestSetupExporters(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone, "")
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), ExporterFile, "")
+	require.Error(t, err)
+
+	_, err = Setup(context.Background(), "zipkin", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// InterestAccrualJob records one day of interest for every account that has a matching interest product.
+// Accruals are unique per account and day, so rerunning a day records nothing new. A run also
+// accrues the days missed since the last accrual, such as while the server was down, at the
+// balances of the run.
+type InterestAccrualJob struct {
+	store db.Store
+}
+
+func NewInterestAccrualJob(store db.Store) *InterestAccrualJob {
+	return &InterestAccrualJob{store: store}
+}
+
+func (job *InterestAccrualJob) Name() string {
+	return "interest_accrual"
+}
+
+func (job *InterestAccrualJob) Run(ctx context.Context, day time.Time) error {
+	rows, err := job.store.ListInterestBearingAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	today := truncateToDay(day)
+	from := today
+	latest, err := job.store.GetLatestInterestAccrualDate(ctx)
+	if err != nil {
+		return err
+	}
+	if latest.Valid && latest.Time.Before(today) {
+		from = latest.Time.AddDate(0, 0, 1)
+	}
+
+	var accrued int64
+	for date := from; !date.After(today); date = date.AddDate(0, 0, 1) {
+		n, err := job.accrue(ctx, rows, date)
+		if err != nil {
+			return err
+		}
+		accrued += n
+	}
+
+	slog.InfoContext(ctx, "accrued interest", "job", job.Name(), "accrued", accrued, "accounts", len(rows),
+		"from", from.Format(time.DateOnly), "day", today.Format(time.DateOnly))
+	return nil
+}
+
+// accrue records the interest of date for the accounts of rows and returns how many
+// accruals are new.
+func (job *InterestAccrualJob) accrue(ctx context.Context, rows []db.ListInterestBearingAccountsRow, date time.Time) (int64, error) {
+	accrualDate := pgtype.Date{Time: date, Valid: true}
+
+	var accrued int64
+	for _, row := range rows {
+		amount, err := db.DailyInterest(row.Account.Balance, row.InterestProduct.AnnualRate, row.InterestProduct.DayCount, date)
+		if err != nil {
+			return accrued, fmt.Errorf("accrue account %d: %w", row.Account.ID, err)
+		}
+
+		n, err := job.store.CreateInterestAccrual(ctx, db.CreateInterestAccrualParams{
+			AccountID:   row.Account.ID,
+			ProductID:   row.InterestProduct.ID,
+			AccrualDate: accrualDate,
+			Balance:     row.Account.Balance,
+			AnnualRate:  row.InterestProduct.AnnualRate,
+			DayCount:    row.InterestProduct.DayCount,
+			Amount:      amount,
+		})
+		if err != nil {
+			return accrued, fmt.Errorf("accrue account %d on %s: %w", row.Account.ID, date.Format(time.DateOnly), err)
+		}
+		accrued += n
+	}
+	return accrued, nil
+}
+
+// InterestPostingJob credits the interest accrued in every completed month, paying it from the
+// house interest-expense account owned by expenseOwner in the account's currency.
+type InterestPostingJob struct {
+	store        db.Store
+	expenseOwner string
+}
+
+func NewInterestPostingJob(store db.Store, expenseOwner string) *InterestPostingJob {
+	return &InterestPostingJob{
+		store:        store,
+		expenseOwner: expenseOwner,
+	}
+}
+
+func (job *InterestPostingJob) Name() string {
+	return "interest_posting"
+}
+
+func (job *InterestPostingJob) Run(ctx context.Context, day time.Time) error {
+	firstOfMonth := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	// A month is posted once, so a month the accrual job is still catching up on waits
+	// until the day after it has been accrued.
+	latest, err := job.store.GetLatestInterestAccrualDate(ctx)
+	if err != nil {
+		return err
+	}
+	if latest.Valid {
+		next := latest.Time.AddDate(0, 0, 1)
+		if accrued := time.Date(next.Year(), next.Month(), 1, 0, 0, 0, 0, time.UTC); accrued.Before(firstOfMonth) {
+			firstOfMonth = accrued
+		}
+	}
+
+	periods, err := job.store.ListUnpostedInterestPeriods(ctx, pgtype.Date{Time: firstOfMonth, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	expenseAccounts := make(map[string]int64)
+	posted := 0
+
+	for _, period := range periods {
+		account, err := job.store.GetAccount(ctx, period.AccountID)
+		if err != nil {
+			return fmt.Errorf("post account %d: %w", period.AccountID, err)
+		}
+
+		expenseAccountID, ok := expenseAccounts[account.Currency]
+		if !ok {
+			expenseAccountID, err = job.expenseAccount(ctx, account.Currency)
+			if err != nil {
+				return fmt.Errorf("interest-expense account for %s: %w", account.Currency, err)
+			}
+			expenseAccounts[account.Currency] = expenseAccountID
+		}
+
+		result, err := job.store.PostInterestTx(ctx, db.PostInterestTxParams{
+			AccountID:        account.ID,
+			ExpenseAccountID: expenseAccountID,
+			Period:           period.Period.Time,
+		})
+		if err != nil {
+			return fmt.Errorf("post account %d: %w", account.ID, err)
+		}
+		if result.Posted {
+			posted++
+		}
+	}
+
+	slog.InfoContext(ctx, "posted interest", "job", job.Name(), "posted", posted, "periods", len(periods), "before", firstOfMonth.Format(time.DateOnly))
+	return nil
+}
+
+// expenseAccount returns the house interest-expense account for a currency, a business
+// account, opening it on first use.
+func (job *InterestPostingJob) expenseAccount(ctx context.Context, currency string) (int64, error) {
+	account, err := job.store.GetAccountByOwnerCurrencyAndType(ctx, db.GetAccountByOwnerCurrencyAndTypeParams{
+		Owner:       job.expenseOwner,
+		Currency:    currency,
+		AccountType: db.AccountTypeBusiness,
+	})
+	if err == nil {
+		return account.ID, nil
+	}
+	if err != pgx.ErrNoRows {
+		return 0, err
+	}
+
+	account, err = job.store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:       job.expenseOwner,
+		Balance:     pgtype.Numeric{Int: big.NewInt(0), Valid: true},
+		Currency:    currency,
+		AccountType: db.AccountTypeBusiness,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return account.ID, nil
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
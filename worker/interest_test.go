@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestInterestAccrualJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	var balance, rate pgtype.Numeric
+	require.NoError(t, balance.Scan("3650.00"))
+	require.NoError(t, rate.Scan("0.10"))
+
+	row := db.ListInterestBearingAccountsRow{
+		Account:         db.Account{ID: 7, Balance: balance, Currency: "USD", AccountType: db.AccountTypeSavings},
+		InterestProduct: db.InterestProduct{ID: 3, AnnualRate: rate, DayCount: db.DayCountConventionActual365},
+	}
+	day := time.Date(2025, time.May, 17, 23, 30, 0, 0, time.UTC)
+
+	store.EXPECT().ListInterestBearingAccounts(gomock.Any()).Times(1).Return([]db.ListInterestBearingAccountsRow{row}, nil)
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).
+		Return(pgtype.Date{Time: time.Date(2025, time.May, 16, 0, 0, 0, 0, time.UTC), Valid: true}, nil)
+	store.EXPECT().CreateInterestAccrual(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
+			require.Equal(t, int64(7), arg.AccountID)
+			require.Equal(t, int64(3), arg.ProductID)
+			require.Equal(t, time.Date(2025, time.May, 17, 0, 0, 0, 0, time.UTC), arg.AccrualDate.Time)
+			require.Zero(t, db.NumericToRat(arg.Amount).Cmp(db.NumericToRat(mustNumeric(t, "1"))))
+			return 1, nil
+		})
+
+	require.NoError(t, NewInterestAccrualJob(store).Run(context.Background(), day))
+}
+
+func TestInterestAccrualJobCatchesUp(t *testing.T) {
+	row := db.ListInterestBearingAccountsRow{
+		Account:         db.Account{ID: 7, Balance: mustNumeric(t, "3650.00"), Currency: "USD", AccountType: db.AccountTypeSavings},
+		InterestProduct: db.InterestProduct{ID: 3, AnnualRate: mustNumeric(t, "0.10"), DayCount: db.DayCountConventionActual365},
+	}
+	day := time.Date(2025, time.May, 17, 8, 0, 0, 0, time.UTC)
+	date := func(d int) time.Time { return time.Date(2025, time.May, d, 0, 0, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		Name   string
+		Latest pgtype.Date
+		Dates  []time.Time
+	}{
+		{Name: "First Run", Dates: []time.Time{date(17)}},
+		{Name: "Days Missed", Latest: pgtype.Date{Time: date(14), Valid: true}, Dates: []time.Time{date(15), date(16), date(17)}},
+		{Name: "Day Rerun", Latest: pgtype.Date{Time: date(17), Valid: true}, Dates: []time.Time{date(17)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mock.NewMockStore(ctrl)
+
+			var dates []time.Time
+			store.EXPECT().ListInterestBearingAccounts(gomock.Any()).Times(1).Return([]db.ListInterestBearingAccountsRow{row}, nil)
+			store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).Return(tc.Latest, nil)
+			store.EXPECT().CreateInterestAccrual(gomock.Any(), gomock.Any()).Times(len(tc.Dates)).
+				DoAndReturn(func(_ context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
+					dates = append(dates, arg.AccrualDate.Time)
+					return 1, nil
+				})
+
+			require.NoError(t, NewInterestAccrualJob(store).Run(context.Background(), day))
+			require.Equal(t, tc.Dates, dates)
+		})
+	}
+}
+
+func TestInterestPostingJobOpensExpenseAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	account := db.Account{ID: 7, Currency: "USD"}
+	expense := db.Account{ID: 99, Owner: "bank", Currency: "USD"}
+	period := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).
+		Return(pgtype.Date{Time: time.Date(2025, time.May, 2, 0, 0, 0, 0, time.UTC), Valid: true}, nil)
+	store.EXPECT().ListUnpostedInterestPeriods(gomock.Any(), pgtype.Date{Time: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+		Times(1).
+		Return([]db.ListUnpostedInterestPeriodsRow{{AccountID: account.ID, Period: pgtype.Date{Time: period, Valid: true}}}, nil)
+	store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+	store.EXPECT().GetAccountByOwnerCurrencyAndType(gomock.Any(), db.GetAccountByOwnerCurrencyAndTypeParams{Owner: "bank", Currency: "USD", AccountType: db.AccountTypeBusiness}).
+		Times(1).
+		Return(db.Account{}, pgx.ErrNoRows)
+	store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateAccountParams) (db.Account, error) {
+			require.Equal(t, "bank", arg.Owner)
+			require.Equal(t, "USD", arg.Currency)
+			return expense, nil
+		})
+	store.EXPECT().PostInterestTx(gomock.Any(), db.PostInterestTxParams{
+		AccountID:        account.ID,
+		ExpenseAccountID: expense.ID,
+		Period:           period,
+	}).Times(1).Return(db.PostInterestTxResult{Posted: true}, nil)
+
+	job := NewInterestPostingJob(store, "bank")
+	require.NoError(t, job.Run(context.Background(), time.Date(2025, time.May, 3, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestInterestPostingJobWaitsForAccruals(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	// April is accrued through the 20th only: the accrual job is still catching up on it.
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).
+		Return(pgtype.Date{Time: time.Date(2025, time.April, 20, 0, 0, 0, 0, time.UTC), Valid: true}, nil)
+	store.EXPECT().ListUnpostedInterestPeriods(gomock.Any(), pgtype.Date{Time: time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC), Valid: true}).
+		Times(1).
+		Return([]db.ListUnpostedInterestPeriodsRow{}, nil)
+	store.EXPECT().PostInterestTx(gomock.Any(), gomock.Any()).Times(0)
+
+	job := NewInterestPostingJob(store, "bank")
+	require.NoError(t, job.Run(context.Background(), time.Date(2025, time.May, 3, 0, 0, 0, 0, time.UTC)))
+}
+
+func mustNumeric(t *testing.T, s string) pgtype.Numeric {
+	var n pgtype.Numeric
+	require.NoError(t, n.Scan(s))
+	return n
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	db "example.com/db/sqlc"
+)
+
+// RequestNonceSweepJob deletes the nonces of signed requests whose signatures are too old
+// to be accepted again, which no longer need remembering.
+type RequestNonceSweepJob struct {
+	store db.Store
+}
+
+func NewRequestNonceSweepJob(store db.Store) *RequestNonceSweepJob {
+	return &RequestNonceSweepJob{store: store}
+}
+
+func (job *RequestNonceSweepJob) Name() string {
+	return "request_nonce_sweep"
+}
+
+func (job *RequestNonceSweepJob) Run(ctx context.Context, day time.Time) error {
+	deleted, err := job.store.DeleteExpiredRequestNonces(ctx)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "swept request nonces", "job", job.Name(), "rows", deleted)
+	return nil
+}
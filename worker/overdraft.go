@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// OverdraftInterestJob charges a day of overdraft interest to every account with a negative balance.
+type OverdraftInterestJob struct {
+	store      db.Store
+	annualRate pgtype.Numeric
+}
+
+func NewOverdraftInterestJob(store db.Store, annualRate string) (*OverdraftInterestJob, error) {
+	var rate pgtype.Numeric
+	if err := rate.Scan(annualRate); err != nil {
+		return nil, fmt.Errorf("invalid overdraft annual rate %q: %w", annualRate, err)
+	}
+
+	return &OverdraftInterestJob{
+		store:      store,
+		annualRate: rate,
+	}, nil
+}
+
+func (job *OverdraftInterestJob) Name() string {
+	return "overdraft_interest"
+}
+
+func (job *OverdraftInterestJob) Run(ctx context.Context, day time.Time) error {
+	accounts, err := job.store.ListOverdrawnAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	charged := 0
+	for _, account := range accounts {
+		result, err := job.store.ChargeOverdraftInterestTx(ctx, db.ChargeOverdraftInterestTxParams{
+			AccountID:  account.ID,
+			ChargeDate: day,
+			AnnualRate: job.annualRate,
+		})
+		if err != nil {
+			return fmt.Errorf("charge account %d: %w", account.ID, err)
+		}
+		if result.Charged {
+			charged++
+		}
+	}
+
+	slog.InfoContext(ctx, "charged overdraft interest", "job", job.Name(), "charged", charged, "accounts", len(accounts), "day", day.Format(time.DateOnly))
+	return nil
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"example.com/pii"
+)
+
+// rekeyBatchSize is how many rows PIIRekeyJob reads at a time.
+const rekeyBatchSize = 100
+
+// PIIRekeyJob encrypts the personal data still in plaintext, and moves the data keys
+// wrapped by an older master key onto the current one, so that it can be retired. A run
+// picks up where the last one left off, or what changed under it.
+type PIIRekeyJob struct {
+	store *pii.Store
+}
+
+func NewPIIRekeyJob(store *pii.Store) *PIIRekeyJob {
+	return &PIIRekeyJob{store: store}
+}
+
+func (job *PIIRekeyJob) Name() string {
+	return "pii_rekey"
+}
+
+func (job *PIIRekeyJob) Run(ctx context.Context, day time.Time) error {
+	rekeyed, err := job.store.Rekey(ctx, rekeyBatchSize)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "rekeyed personal data", "job", job.Name(), "rows", rekeyed)
+	return nil
+}
@@ -0,0 +1,350 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrWebhookAddressBlocked   = errors.New("webhook address is not public")
+)
+
+// WebhookEnvelope is the JSON body posted to a subscriber for every event.
+type WebhookEnvelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SignWebhook returns the value of the signature header for body: "t=<unix time>,v1=<hex>",
+// where the hex part is the HMAC-SHA256 of "<unix time>.<body>" keyed with the subscription secret.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, webhookMAC(secret, ts, body))
+}
+
+// VerifyWebhook checks a signature header produced by SignWebhook and rejects signatures
+// whose timestamp is further than tolerance from now. Receivers use it to authenticate deliveries.
+func VerifyWebhook(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidWebhookSignature
+	}
+
+	age := now.Sub(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(webhookMAC(secret, ts, body))) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
+
+func webhookMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// specialPurposePrefixes are the address blocks of the IANA special-purpose registries that
+// are not globally reachable, or not meant to be reached at all: our own network, shared and
+// reserved space, benchmarking, documentation and multicast.
+var specialPurposePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("3fff::/20"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// NAT64 and 6to4 addresses embed an IPv4 address, which the packets end up sent to.
+var (
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+)
+
+// PublicWebhookAddress reports whether subscribers may be reached at ip: not at a
+// special-purpose address, which belongs to our own network or to nobody, nor at an IPv6
+// address that embeds one.
+func PublicWebhookAddress(ip netip.Addr) bool {
+	ip = ip.Unmap().WithZone("")
+	if !ip.IsValid() {
+		return false
+	}
+	for _, prefix := range specialPurposePrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	raw := ip.As16()
+	switch {
+	case nat64Prefix.Contains(ip):
+		return PublicWebhookAddress(netip.AddrFrom4([4]byte(raw[12:16])))
+	case sixToFourPrefix.Contains(ip):
+		return PublicWebhookAddress(netip.AddrFrom4([4]byte(raw[2:6])))
+	}
+	return true
+}
+
+// NewWebhookClient returns the HTTP client deliveries are sent with. The address is checked when
+// the connection is made, after DNS resolution, so a subscriber hostname that resolves to an
+// internal address is refused too. Redirects are not followed.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: webhookDialControl}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicWebhookAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, address)
+	}
+	return nil
+}
+
+// WebhookDispatcher delivers queued webhook deliveries from the outbox. Failed deliveries are
+// retried with exponential backoff until MaxAttempts, after which they are dead-lettered.
+type WebhookDispatcher struct {
+	store  db.Store
+	client *http.Client
+	now    func() time.Time
+
+	MaxAttempts int32
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	BatchSize   int32
+	Lease       time.Duration
+}
+
+func NewWebhookDispatcher(store db.Store, client *http.Client) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		store:       store,
+		client:      client,
+		now:         time.Now,
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		BatchSize:   20,
+		Lease:       2 * time.Minute,
+	}
+}
+
+// Start polls for due deliveries every interval until ctx is cancelled. A batch being
+// delivered when ctx is cancelled is finished first.
+func (dispatcher *WebhookDispatcher) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := dispatcher.DeliverDue(context.WithoutCancel(ctx)); err != nil {
+			slog.ErrorContext(ctx, "webhook dispatcher failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue claims one batch of due deliveries and attempts each of them once. A delivery
+// that cannot be attempted is logged and skipped, so it does not hold up the rest of the batch;
+// it is claimed again once its lease ends. It returns the number of deliveries that succeeded
+// and the errors of the ones that could not be attempted, joined.
+func (dispatcher *WebhookDispatcher) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := dispatcher.store.ClaimDueWebhookDeliveries(ctx, db.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: pgtype.Timestamptz{Time: dispatcher.now().Add(dispatcher.Lease), Valid: true},
+		BatchSize:  dispatcher.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	var errs []error
+	for _, delivery := range deliveries {
+		ok, err := dispatcher.deliver(ctx, delivery)
+		if err != nil {
+			slog.ErrorContext(ctx, "webhook delivery failed", "delivery", delivery.ID, "error", err)
+			errs = append(errs, fmt.Errorf("delivery %d: %w", delivery.ID, err))
+			continue
+		}
+		if ok {
+			succeeded++
+		}
+	}
+
+	if len(errs) > 0 {
+		slog.ErrorContext(ctx, "webhook deliveries failed", "failed", len(errs), "deliveries", len(deliveries))
+	}
+	return succeeded, errors.Join(errs...)
+}
+
+func (dispatcher *WebhookDispatcher) deliver(ctx context.Context, delivery db.WebhookDelivery) (bool, error) {
+	subscription, err := dispatcher.store.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return false, err
+	}
+
+	event, err := dispatcher.store.GetWebhookEvent(ctx, delivery.EventID)
+	if err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(WebhookEnvelope{
+		ID:        event.ID,
+		Type:      event.EventType,
+		CreatedAt: event.CreatedAt.Time,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	statusCode, sendErr := dispatcher.send(ctx, subscription, delivery, event.EventType, body)
+	if sendErr == nil {
+		_, err = dispatcher.store.MarkWebhookDeliverySucceeded(ctx, db.MarkWebhookDeliverySucceededParams{
+			ID:             delivery.ID,
+			LastStatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: true},
+		})
+		return err == nil, err
+	}
+
+	attempts := delivery.Attempts + 1
+	status := db.WebhookDeliveryStatusPending
+	if attempts >= dispatcher.MaxAttempts || !subscription.Active {
+		status = db.WebhookDeliveryStatusDead
+	}
+
+	_, err = dispatcher.store.MarkWebhookDeliveryFailed(ctx, db.MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  pgtype.Timestamptz{Time: dispatcher.now().Add(dispatcher.backoff(attempts)), Valid: true},
+		LastStatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      pgtype.Text{String: sendErr.Error(), Valid: true},
+	})
+	return false, err
+}
+
+func (dispatcher *WebhookDispatcher) send(ctx context.Context, subscription db.WebhookSubscription, delivery db.WebhookDelivery, eventType string, body []byte) (int, error) {
+	if !subscription.Active {
+		return 0, errors.New("subscription is inactive")
+	}
+
+	// Subscriptions made before https was required are not delivered to.
+	if target, err := url.Parse(subscription.Url); err != nil || target.Scheme != "https" {
+		return 0, errors.New("webhook url must use https")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, dispatcher.now(), body))
+
+	resp, err := dispatcher.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func (dispatcher *WebhookDispatcher) backoff(attempts int32) time.Duration {
+	delay := dispatcher.BaseBackoff
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= dispatcher.MaxBackoff {
+			return dispatcher.MaxBackoff
+		}
+	}
+	return delay
+}
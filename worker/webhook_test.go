@@ -0,0 +1,296 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/db/mock"
+	db "example.com/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type receivedWebhook struct {
+	Header http.Header
+	Body   []byte
+}
+
+// webhookReceiver is a local subscriber that answers with the queued status codes in order.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	received []receivedWebhook
+}
+
+func (receiver *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.received = append(receiver.received, receivedWebhook{Header: r.Header.Clone(), Body: body})
+
+	status := http.StatusOK
+	if len(receiver.statuses) > 0 {
+		status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	secret := "whsec_test"
+
+	event := db.WebhookEvent{
+		ID:        11,
+		Username:  "alice",
+		EventType: db.EventTransferCreated,
+		Payload:   []byte(`{"id":5,"amount":10}`),
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	}
+
+	testCases := []struct {
+		Name      string
+		Status    int
+		Attempts  int32
+		Inactive  bool
+		PlainHTTP bool
+		BuildStub func(*mock.MockStore)
+		Check     func(*testing.T, *webhookReceiver, int)
+	}{
+		{
+			Name:   "Delivered",
+			Status: http.StatusNoContent,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().MarkWebhookDeliverySucceeded(gomock.Any(), db.MarkWebhookDeliverySucceededParams{
+					ID:             1,
+					LastStatusCode: pgtype.Int4{Int32: http.StatusNoContent, Valid: true},
+				}).Times(1)
+			},
+			Check: func(t *testing.T, receiver *webhookReceiver, succeeded int) {
+				require.Equal(t, 1, succeeded)
+				require.Len(t, receiver.received, 1)
+
+				got := receiver.received[0]
+				require.Equal(t, db.EventTransferCreated, got.Header.Get(WebhookEventHeader))
+				require.Equal(t, "1", got.Header.Get(WebhookDeliveryHeader))
+				require.NoError(t, VerifyWebhook(secret, got.Header.Get(WebhookSignatureHeader), got.Body, 5*time.Minute, now))
+
+				var envelope WebhookEnvelope
+				require.NoError(t, json.Unmarshal(got.Body, &envelope))
+				require.Equal(t, event.ID, envelope.ID)
+				require.Equal(t, event.EventType, envelope.Type)
+				require.JSONEq(t, string(event.Payload), string(envelope.Data))
+			},
+		},
+		{
+			Name:     "Retried With Backoff",
+			Status:   http.StatusInternalServerError,
+			Attempts: 2,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().MarkWebhookDeliveryFailed(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+						require.Equal(t, db.WebhookDeliveryStatusPending, arg.Status)
+						// third failed attempt waits 30s * 2^2
+						require.Equal(t, now.Add(2*time.Minute), arg.NextAttemptAt.Time)
+						require.Equal(t, int32(http.StatusInternalServerError), arg.LastStatusCode.Int32)
+						require.True(t, arg.LastError.Valid)
+						return db.WebhookDelivery{}, nil
+					})
+			},
+			Check: func(t *testing.T, receiver *webhookReceiver, succeeded int) {
+				require.Zero(t, succeeded)
+				require.Len(t, receiver.received, 1)
+			},
+		},
+		{
+			Name:     "Dead Lettered",
+			Status:   http.StatusBadGateway,
+			Attempts: 7,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().MarkWebhookDeliveryFailed(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+						require.Equal(t, db.WebhookDeliveryStatusDead, arg.Status)
+						return db.WebhookDelivery{}, nil
+					})
+			},
+			Check: func(t *testing.T, receiver *webhookReceiver, succeeded int) {
+				require.Zero(t, succeeded)
+			},
+		},
+		{
+			Name:     "Inactive Subscription",
+			Inactive: true,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().MarkWebhookDeliveryFailed(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+						require.Equal(t, db.WebhookDeliveryStatusDead, arg.Status)
+						require.False(t, arg.LastStatusCode.Valid)
+						return db.WebhookDelivery{}, nil
+					})
+			},
+			Check: func(t *testing.T, receiver *webhookReceiver, succeeded int) {
+				require.Empty(t, receiver.received)
+			},
+		},
+		{
+			Name:      "Plain HTTP",
+			Status:    http.StatusOK,
+			PlainHTTP: true,
+			BuildStub: func(ms *mock.MockStore) {
+				ms.EXPECT().MarkWebhookDeliveryFailed(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.MarkWebhookDeliveryFailedParams) (db.WebhookDelivery, error) {
+						require.Contains(t, arg.LastError.String, "https")
+						return db.WebhookDelivery{}, nil
+					})
+			},
+			Check: func(t *testing.T, receiver *webhookReceiver, succeeded int) {
+				require.Zero(t, succeeded)
+				require.Empty(t, receiver.received)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			receiver := &webhookReceiver{statuses: []int{tc.Status}}
+			ts := httptest.NewUnstartedServer(receiver)
+			if tc.PlainHTTP {
+				ts.Start()
+			} else {
+				ts.StartTLS()
+			}
+			defer ts.Close()
+
+			ctrl := gomock.NewController(t)
+			store := mock.NewMockStore(ctrl)
+
+			subscription := db.WebhookSubscription{ID: 3, Username: "alice", Url: ts.URL, Secret: secret, Active: !tc.Inactive}
+			delivery := db.WebhookDelivery{ID: 1, EventID: event.ID, SubscriptionID: subscription.ID, Attempts: tc.Attempts}
+
+			store.EXPECT().ClaimDueWebhookDeliveries(gomock.Any(), gomock.Any()).Times(1).Return([]db.WebhookDelivery{delivery}, nil)
+			store.EXPECT().GetWebhookSubscription(gomock.Any(), subscription.ID).Times(1).Return(subscription, nil)
+			store.EXPECT().GetWebhookEvent(gomock.Any(), event.ID).Times(1).Return(event, nil)
+			tc.BuildStub(store)
+
+			dispatcher := NewWebhookDispatcher(store, ts.Client())
+			dispatcher.now = func() time.Time { return now }
+
+			succeeded, err := dispatcher.DeliverDue(context.Background())
+			require.NoError(t, err)
+			tc.Check(t, receiver, succeeded)
+		})
+	}
+}
+
+func TestWebhookDispatcherContinuesAfterError(t *testing.T) {
+	receiver := &webhookReceiver{}
+	ts := httptest.NewTLSServer(receiver)
+	defer ts.Close()
+
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	subscription := db.WebhookSubscription{ID: 3, Username: "alice", Url: ts.URL, Secret: "whsec_test", Active: true}
+	event := db.WebhookEvent{ID: 11, Username: "alice", EventType: db.EventTransferCreated, Payload: []byte(`{}`)}
+	broken := db.WebhookDelivery{ID: 1, EventID: event.ID, SubscriptionID: 99}
+	working := db.WebhookDelivery{ID: 2, EventID: event.ID, SubscriptionID: subscription.ID}
+	lookupErr := errors.New("lookup failed")
+
+	store.EXPECT().ClaimDueWebhookDeliveries(gomock.Any(), gomock.Any()).Times(1).Return([]db.WebhookDelivery{broken, working}, nil)
+	store.EXPECT().GetWebhookSubscription(gomock.Any(), broken.SubscriptionID).Times(1).Return(db.WebhookSubscription{}, lookupErr)
+	store.EXPECT().GetWebhookSubscription(gomock.Any(), subscription.ID).Times(1).Return(subscription, nil)
+	store.EXPECT().GetWebhookEvent(gomock.Any(), event.ID).Times(1).Return(event, nil)
+	store.EXPECT().MarkWebhookDeliverySucceeded(gomock.Any(), gomock.Any()).Times(1)
+
+	succeeded, err := NewWebhookDispatcher(store, ts.Client()).DeliverDue(context.Background())
+	require.ErrorIs(t, err, lookupErr)
+	require.Equal(t, 1, succeeded, "the failed delivery does not hold up the next one")
+	require.Len(t, receiver.received, 1)
+}
+
+func TestWebhookClient(t *testing.T) {
+	// The test server listens on loopback, which subscribers may not use.
+	ts := httptest.NewTLSServer(&webhookReceiver{})
+	defer ts.Close()
+
+	_, err := NewWebhookClient(time.Second).Post(ts.URL, "application/json", nil)
+	require.ErrorIs(t, err, ErrWebhookAddressBlocked)
+
+	for addr, public := range map[string]bool{
+		"8.8.8.8":              true,
+		"2606:4700:4700::1111": true,
+		"::ffff:8.8.8.8":       true,
+		"64:ff9b::808:808":     true,
+		"2002:808:808::1":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"::ffff:127.0.0.1":     false,
+		"::127.0.0.1":          false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.0.1":          false,
+		"fd00::1":              false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fe80::1%eth0":         false,
+		"0.0.0.0":              false,
+		"0.1.2.3":              false,
+		"::":                   false,
+		"224.0.0.1":            false,
+		"ff02::1":              false,
+		"100.64.0.1":           false,
+		"100.127.255.254":      false,
+		"192.0.0.8":            false,
+		"192.0.2.1":            false,
+		"198.18.0.1":           false,
+		"198.19.255.255":       false,
+		"203.0.113.10":         false,
+		"240.0.0.1":            false,
+		"255.255.255.255":      false,
+		"2001:db8::1":          false,
+		"2001::1":              false,
+		"fec0::1":              false,
+		"64:ff9b::7f00:1":      false,
+		"64:ff9b::a9fe:a9fe":   false,
+		"64:ff9b:1::a00:1":     false,
+		"2002:7f00:1::1":       false,
+		"2002:a9fe:a9fe::1":    false,
+		"2002:c0a8:101:1::1":   false,
+	} {
+		require.Equal(t, public, PublicWebhookAddress(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":1}`)
+	header := SignWebhook("secret", now, body)
+
+	require.NoError(t, VerifyWebhook("secret", header, body, time.Minute, now))
+	require.ErrorIs(t, VerifyWebhook("other-secret", header, body, time.Minute, now), ErrInvalidWebhookSignature)
+	require.ErrorIs(t, VerifyWebhook("secret", header, []byte(`{"id":2}`), time.Minute, now), ErrInvalidWebhookSignature)
+	require.ErrorIs(t, VerifyWebhook("secret", header, body, time.Minute, now.Add(2*time.Minute)), ErrInvalidWebhookSignature)
+	require.ErrorIs(t, VerifyWebhook("secret", "v1=deadbeef", body, time.Minute, now), ErrInvalidWebhookSignature)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	dispatcher := NewWebhookDispatcher(nil, nil)
+	dispatcher.BaseBackoff = time.Second
+	dispatcher.MaxBackoff = 10 * time.Second
+
+	require.Equal(t, time.Second, dispatcher.backoff(1))
+	require.Equal(t, 2*time.Second, dispatcher.backoff(2))
+	require.Equal(t, 8*time.Second, dispatcher.backoff(4))
+	require.Equal(t, 10*time.Second, dispatcher.backoff(5))
+	require.Equal(t, 10*time.Second, dispatcher.backoff(30))
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a unit of background work that is run once per business day.
+// Implementations must be idempotent for a given day so that reruns are safe.
+type Job interface {
+	Name() string
+	Run(ctx context.Context, day time.Time) error
+}
+
+// RunDaily runs job immediately and then on every tick of interval until ctx is cancelled.
+// A run in progress when ctx is cancelled is finished first, so a shutdown never cuts a
+// job off halfway.
+func RunDaily(ctx context.Context, job Job, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(context.WithoutCancel(ctx), time.Now().UTC()); err != nil {
+			slog.ErrorContext(ctx, "job failed", "job", job.Name(), "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}